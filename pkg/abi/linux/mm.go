@@ -31,6 +31,13 @@ const (
 	PROT_GROWSUP   = 1 << 25
 )
 
+// Access rights for pkey_alloc(2).
+const (
+	PKEY_DISABLE_ACCESS = 0x1
+	PKEY_DISABLE_WRITE  = 0x2
+	PKEY_ACCESS_MASK    = PKEY_DISABLE_ACCESS | PKEY_DISABLE_WRITE
+)
+
 // Flags for mmap(2).
 const (
 	MAP_SHARED     = 1 << 0
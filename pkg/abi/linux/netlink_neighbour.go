@@ -0,0 +1,93 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linux
+
+// NeighborMessage is struct ndmsg, from uapi/linux/neighbour.h.
+//
+// +marshal
+type NeighborMessage struct {
+	Family uint8
+	Pad1   uint8
+	Pad2   uint16
+	Index  int32
+	State  uint16
+	Flags  uint8
+	Type   uint8
+}
+
+// SizeOfNeighborMessage is the size of NeighborMessage.
+const SizeOfNeighborMessage = 12
+
+// Neighbor attributes, from uapi/linux/neighbour.h.
+const (
+	NDA_UNSPEC       = 0
+	NDA_DST          = 1
+	NDA_LLADDR       = 2
+	NDA_CACHEINFO    = 3
+	NDA_PROBES       = 4
+	NDA_VLAN         = 5
+	NDA_PORT         = 6
+	NDA_VNI          = 7
+	NDA_IFINDEX      = 8
+	NDA_MASTER       = 9
+	NDA_LINK_NETNSID = 10
+	NDA_SRC_VNI      = 11
+	NDA_PROTOCOL     = 12
+)
+
+// Neighbor flags, from uapi/linux/neighbour.h.
+const (
+	NTF_USE         = 1 << 0
+	NTF_SELF        = 1 << 1
+	NTF_MASTER      = 1 << 2
+	NTF_PROXY       = 1 << 3
+	NTF_EXT_LEARNED = 1 << 4
+	NTF_OFFLOADED   = 1 << 5
+	NTF_STICKY      = 1 << 6
+	NTF_ROUTER      = 1 << 7
+)
+
+// Neighbor cache entry states, from uapi/linux/neighbour.h.
+const (
+	NUD_NONE       = 0x00
+	NUD_INCOMPLETE = 0x01
+	NUD_REACHABLE  = 0x02
+	NUD_STALE      = 0x04
+	NUD_DELAY      = 0x08
+	NUD_PROBE      = 0x10
+	NUD_FAILED     = 0x20
+	NUD_NOARP      = 0x40
+	NUD_PERMANENT  = 0x80
+)
+
+// NeighborCacheInfo is struct nda_cacheinfo, from uapi/linux/neighbour.h.
+//
+// +marshal
+type NeighborCacheInfo struct {
+	Confirmed uint32
+	Used      uint32
+	Updated   uint32
+	RefCnt    uint32
+}
+
+// ARP flags, from uapi/linux/if_arp.h. They are reported in /proc/net/arp.
+const (
+	ATF_COM         = 0x02
+	ATF_PERM        = 0x04
+	ATF_PUBL        = 0x08
+	ATF_USETRAILERS = 0x10
+	ATF_NETMASK     = 0x20
+	ATF_DONTPUB     = 0x40
+)
@@ -84,6 +84,48 @@ const (
 	RTM_GETNSID = 90
 )
 
+// rtnetlink multicast groups, from uapi/linux/rtnetlink.h.
+const (
+	RTNLGRP_NONE          = 0
+	RTNLGRP_LINK          = 1
+	RTNLGRP_NOTIFY        = 2
+	RTNLGRP_NEIGH         = 3
+	RTNLGRP_TC            = 4
+	RTNLGRP_IPV4_IFADDR   = 5
+	RTNLGRP_IPV4_MROUTE   = 6
+	RTNLGRP_IPV4_ROUTE    = 7
+	RTNLGRP_IPV4_RULE     = 8
+	RTNLGRP_IPV6_IFADDR   = 9
+	RTNLGRP_IPV6_MROUTE   = 10
+	RTNLGRP_IPV6_ROUTE    = 11
+	RTNLGRP_IPV6_IFINFO   = 12
+	RTNLGRP_DECnet_IFADDR = 13
+	RTNLGRP_NOP2          = 14
+	RTNLGRP_DECnet_ROUTE  = 15
+	RTNLGRP_DECnet_RULE   = 16
+	RTNLGRP_NOP4          = 17
+	RTNLGRP_IPV6_PREFIX   = 18
+	RTNLGRP_IPV6_RULE     = 19
+	RTNLGRP_ND_USEROPT    = 20
+	RTNLGRP_PHONET_IFADDR = 21
+	RTNLGRP_PHONET_ROUTE  = 22
+	RTNLGRP_DCB           = 23
+	RTNLGRP_IPV4_NETCONF  = 24
+	RTNLGRP_IPV6_NETCONF  = 25
+	RTNLGRP_MDB           = 26
+	RTNLGRP_MPLS_ROUTE    = 27
+	RTNLGRP_NSID          = 28
+	RTNLGRP_MPLS_NETCONF  = 29
+	RTNLGRP_IPV4_MROUTE_R = 30
+	RTNLGRP_IPV6_MROUTE_R = 31
+	RTNLGRP_NEXTHOP       = 32
+	RTNLGRP_BRVLAN        = 33
+	RTNLGRP_MCTP_IFADDR   = 34
+	RTNLGRP_TUNNEL        = 35
+	RTNLGRP_STATS         = 36
+	RTNLGRP_MAX           = RTNLGRP_STATS
+)
+
 // InterfaceInfoMessage is struct ifinfomsg, from uapi/linux/rtnetlink.h.
 //
 // +marshal
@@ -183,6 +225,50 @@ const (
 	VETH_INFO_PEER = 1
 )
 
+// VLAN attributes, from uapi/linux/if_link.h.
+const (
+	IFLA_VLAN_UNSPEC      = 0
+	IFLA_VLAN_ID          = 1
+	IFLA_VLAN_FLAGS       = 2
+	IFLA_VLAN_EGRESS_QOS  = 3
+	IFLA_VLAN_INGRESS_QOS = 4
+	IFLA_VLAN_PROTOCOL    = 5
+)
+
+// VXLAN attributes, from uapi/linux/if_link.h.
+const (
+	IFLA_VXLAN_UNSPEC            = 0
+	IFLA_VXLAN_ID                = 1
+	IFLA_VXLAN_GROUP             = 2
+	IFLA_VXLAN_LINK              = 3
+	IFLA_VXLAN_LOCAL             = 4
+	IFLA_VXLAN_TTL               = 5
+	IFLA_VXLAN_TOS               = 6
+	IFLA_VXLAN_LEARNING          = 7
+	IFLA_VXLAN_AGEING            = 8
+	IFLA_VXLAN_LIMIT             = 9
+	IFLA_VXLAN_PORT_RANGE        = 10
+	IFLA_VXLAN_PROXY             = 11
+	IFLA_VXLAN_RSC               = 12
+	IFLA_VXLAN_L2MISS            = 13
+	IFLA_VXLAN_L3MISS            = 14
+	IFLA_VXLAN_PORT              = 15
+	IFLA_VXLAN_GROUP6            = 16
+	IFLA_VXLAN_LOCAL6            = 17
+	IFLA_VXLAN_UDP_CSUM          = 18
+	IFLA_VXLAN_UDP_ZERO_CSUM6_TX = 19
+	IFLA_VXLAN_UDP_ZERO_CSUM6_RX = 20
+	IFLA_VXLAN_REMCSUM_TX        = 21
+	IFLA_VXLAN_REMCSUM_RX        = 22
+	IFLA_VXLAN_GBP               = 23
+	IFLA_VXLAN_REMCSUM_NOPARTIAL = 24
+	IFLA_VXLAN_COLLECT_METADATA  = 25
+	IFLA_VXLAN_LABEL             = 26
+	IFLA_VXLAN_GPE               = 27
+	IFLA_VXLAN_TTL_INHERIT       = 28
+	IFLA_VXLAN_DF                = 29
+)
+
 // InterfaceAddrMessage is struct ifaddrmsg, from uapi/linux/if_addr.h.
 //
 // +marshal
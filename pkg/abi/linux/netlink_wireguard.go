@@ -0,0 +1,79 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linux
+
+// WireGuard generic netlink family, from uapi/linux/wireguard.h.
+const (
+	WG_GENL_NAME    = "wireguard"
+	WG_GENL_VERSION = 1
+
+	// WG_KEY_LEN is the length of WireGuard public, private and preshared
+	// keys.
+	WG_KEY_LEN = 32
+)
+
+// WireGuard commands, from uapi/linux/wireguard.h.
+const (
+	WG_CMD_GET_DEVICE = 0
+	WG_CMD_SET_DEVICE = 1
+)
+
+// WireGuard device flags, from uapi/linux/wireguard.h.
+const (
+	WGDEVICE_F_REPLACE_PEERS = 1 << 0
+)
+
+// WireGuard device attributes, from uapi/linux/wireguard.h.
+const (
+	WGDEVICE_A_UNSPEC      = 0
+	WGDEVICE_A_IFINDEX     = 1
+	WGDEVICE_A_IFNAME      = 2
+	WGDEVICE_A_PRIVATE_KEY = 3
+	WGDEVICE_A_PUBLIC_KEY  = 4
+	WGDEVICE_A_FLAGS       = 5
+	WGDEVICE_A_LISTEN_PORT = 6
+	WGDEVICE_A_FWMARK      = 7
+	WGDEVICE_A_PEERS       = 8
+)
+
+// WireGuard peer flags, from uapi/linux/wireguard.h.
+const (
+	WGPEER_F_REMOVE_ME          = 1 << 0
+	WGPEER_F_REPLACE_ALLOWEDIPS = 1 << 1
+	WGPEER_F_UPDATE_ONLY        = 1 << 2
+)
+
+// WireGuard peer attributes, from uapi/linux/wireguard.h.
+const (
+	WGPEER_A_UNSPEC                        = 0
+	WGPEER_A_PUBLIC_KEY                    = 1
+	WGPEER_A_PRESHARED_KEY                 = 2
+	WGPEER_A_FLAGS                         = 3
+	WGPEER_A_ENDPOINT                      = 4
+	WGPEER_A_PERSISTENT_KEEPALIVE_INTERVAL = 5
+	WGPEER_A_LAST_HANDSHAKE_TIME           = 6
+	WGPEER_A_RX_BYTES                      = 7
+	WGPEER_A_TX_BYTES                      = 8
+	WGPEER_A_ALLOWEDIPS                    = 9
+	WGPEER_A_PROTOCOL_VERSION              = 10
+)
+
+// WireGuard allowed IP attributes, from uapi/linux/wireguard.h.
+const (
+	WGALLOWEDIP_A_UNSPEC    = 0
+	WGALLOWEDIP_A_FAMILY    = 1
+	WGALLOWEDIP_A_IPADDR    = 2
+	WGALLOWEDIP_A_CIDR_MASK = 3
+)
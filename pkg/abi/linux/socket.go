@@ -332,6 +332,13 @@ const (
 	SO_RCVPRIORITY           = 82
 )
 
+// Minimum socket buffer sizes from include/net/sock.h, assuming a 64-bit
+// kernel.
+const (
+	SOCK_MIN_SNDBUF = 4608
+	SOCK_MIN_RCVBUF = 2304
+)
+
 // enum socket_state, from uapi/linux/net.h.
 const (
 	SS_FREE          = 0 // Not allocated.
@@ -59,6 +59,7 @@ const (
 	MAX_TCP_KEEPIDLE  = 32767
 	MAX_TCP_KEEPINTVL = 32767
 	MAX_TCP_KEEPCNT   = 127
+	MAX_TCP_SYNCNT    = 127
 )
 
 // Congestion control states from include/uapi/linux/tcp.h.
@@ -0,0 +1,187 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linux
+
+// Flags for userfaultfd(2), from include/uapi/linux/userfaultfd.h.
+const (
+	UFFD_USER_MODE_ONLY = 1
+)
+
+// UFFD_API is the userfaultfd API version, from
+// include/uapi/linux/userfaultfd.h.
+const UFFD_API = 0xAA
+
+// Userfaultfd events, from include/uapi/linux/userfaultfd.h.
+const (
+	UFFD_EVENT_PAGEFAULT = 0x12
+	UFFD_EVENT_FORK      = 0x13
+	UFFD_EVENT_REMAP     = 0x14
+	UFFD_EVENT_REMOVE    = 0x15
+	UFFD_EVENT_UNMAP     = 0x16
+)
+
+// Flags for UFFD_EVENT_PAGEFAULT, from include/uapi/linux/userfaultfd.h.
+const (
+	UFFD_PAGEFAULT_FLAG_WRITE = 1 << 0
+	UFFD_PAGEFAULT_FLAG_WP    = 1 << 1
+	UFFD_PAGEFAULT_FLAG_MINOR = 1 << 2
+)
+
+// Userfaultfd features, from include/uapi/linux/userfaultfd.h.
+const (
+	UFFD_FEATURE_PAGEFAULT_FLAG_WP  = 1 << 0
+	UFFD_FEATURE_EVENT_FORK         = 1 << 1
+	UFFD_FEATURE_EVENT_REMAP        = 1 << 2
+	UFFD_FEATURE_EVENT_REMOVE       = 1 << 3
+	UFFD_FEATURE_MISSING_HUGETLBFS  = 1 << 4
+	UFFD_FEATURE_MISSING_SHMEM      = 1 << 5
+	UFFD_FEATURE_EVENT_UNMAP        = 1 << 6
+	UFFD_FEATURE_SIGBUS             = 1 << 7
+	UFFD_FEATURE_THREAD_ID          = 1 << 8
+	UFFD_FEATURE_MINOR_HUGETLBFS    = 1 << 9
+	UFFD_FEATURE_MINOR_SHMEM        = 1 << 10
+	UFFD_FEATURE_EXACT_ADDRESS      = 1 << 11
+	UFFD_FEATURE_WP_HUGETLBFS_SHMEM = 1 << 12
+	UFFD_FEATURE_WP_UNPOPULATED     = 1 << 13
+	UFFD_FEATURE_POISON             = 1 << 14
+	UFFD_FEATURE_WP_ASYNC           = 1 << 15
+	UFFD_FEATURE_MOVE               = 1 << 16
+)
+
+// UFFDIO is the userfaultfd ioctl type, from include/uapi/linux/userfaultfd.h.
+const UFFDIO = 0xAA
+
+// Userfaultfd ioctl ids, from include/uapi/linux/userfaultfd.h.
+const (
+	UFFDIO_REGISTER_NR     = 0x00
+	UFFDIO_UNREGISTER_NR   = 0x01
+	UFFDIO_WAKE_NR         = 0x02
+	UFFDIO_COPY_NR         = 0x03
+	UFFDIO_ZEROPAGE_NR     = 0x04
+	UFFDIO_MOVE_NR         = 0x05
+	UFFDIO_WRITEPROTECT_NR = 0x06
+	UFFDIO_CONTINUE_NR     = 0x07
+	UFFDIO_POISON_NR       = 0x08
+	UFFDIO_API_NR          = 0x3F
+)
+
+// Userfaultfd ioctls, from include/uapi/linux/userfaultfd.h.
+var (
+	UFFDIO_API          = IOWR(UFFDIO, UFFDIO_API_NR, 24)
+	UFFDIO_REGISTER     = IOWR(UFFDIO, UFFDIO_REGISTER_NR, 32)
+	UFFDIO_UNREGISTER   = IOR(UFFDIO, UFFDIO_UNREGISTER_NR, 16)
+	UFFDIO_WAKE         = IOR(UFFDIO, UFFDIO_WAKE_NR, 16)
+	UFFDIO_COPY         = IOWR(UFFDIO, UFFDIO_COPY_NR, 40)
+	UFFDIO_ZEROPAGE     = IOWR(UFFDIO, UFFDIO_ZEROPAGE_NR, 32)
+	UFFDIO_WRITEPROTECT = IOWR(UFFDIO, UFFDIO_WRITEPROTECT_NR, 24)
+
+	// USERFAULTFD_IOC_NEW creates a userfaultfd from /dev/userfaultfd.
+	USERFAULTFD_IOC_NEW = IO(UFFDIO, 0x00)
+)
+
+// UFFD_API_IOCTLS is the mask of ioctls reported by UFFDIO_API, from
+// include/uapi/linux/userfaultfd.h.
+const UFFD_API_IOCTLS = 1<<UFFDIO_REGISTER_NR | 1<<UFFDIO_UNREGISTER_NR | 1<<UFFDIO_API_NR
+
+// Modes for UFFDIO_REGISTER, from include/uapi/linux/userfaultfd.h.
+const (
+	UFFDIO_REGISTER_MODE_MISSING = 1 << 0
+	UFFDIO_REGISTER_MODE_WP      = 1 << 1
+	UFFDIO_REGISTER_MODE_MINOR   = 1 << 2
+)
+
+// Modes for UFFDIO_COPY, UFFDIO_ZEROPAGE and UFFDIO_WRITEPROTECT, from
+// include/uapi/linux/userfaultfd.h.
+const (
+	UFFDIO_COPY_MODE_DONTWAKE = 1 << 0
+	UFFDIO_COPY_MODE_WP       = 1 << 1
+
+	UFFDIO_ZEROPAGE_MODE_DONTWAKE = 1 << 0
+
+	UFFDIO_WRITEPROTECT_MODE_WP       = 1 << 0
+	UFFDIO_WRITEPROTECT_MODE_DONTWAKE = 1 << 1
+)
+
+// UffdMsg is struct uffd_msg, from include/uapi/linux/userfaultfd.h. Only the
+// pagefault variant of the event argument union is represented.
+//
+// +marshal
+type UffdMsg struct {
+	Event   uint8
+	_       uint8
+	_       uint16
+	_       uint32
+	Flags   uint64
+	Address uint64
+	PTID    uint32
+	_       uint32
+}
+
+// UffdioAPI is struct uffdio_api, from include/uapi/linux/userfaultfd.h.
+//
+// +marshal
+type UffdioAPI struct {
+	API      uint64
+	Features uint64
+	Ioctls   uint64
+}
+
+// UffdioRange is struct uffdio_range, from include/uapi/linux/userfaultfd.h.
+//
+// +marshal
+type UffdioRange struct {
+	Start uint64
+	Len   uint64
+}
+
+// UffdioRegister is struct uffdio_register, from
+// include/uapi/linux/userfaultfd.h.
+//
+// +marshal
+type UffdioRegister struct {
+	Range  UffdioRange
+	Mode   uint64
+	Ioctls uint64
+}
+
+// UffdioCopy is struct uffdio_copy, from include/uapi/linux/userfaultfd.h.
+//
+// +marshal
+type UffdioCopy struct {
+	Dst  uint64
+	Src  uint64
+	Len  uint64
+	Mode uint64
+	Copy int64
+}
+
+// UffdioZeropage is struct uffdio_zeropage, from
+// include/uapi/linux/userfaultfd.h.
+//
+// +marshal
+type UffdioZeropage struct {
+	Range    UffdioRange
+	Mode     uint64
+	Zeropage int64
+}
+
+// UffdioWriteprotect is struct uffdio_writeprotect, from
+// include/uapi/linux/userfaultfd.h.
+//
+// +marshal
+type UffdioWriteprotect struct {
+	Range UffdioRange
+	Mode  uint64
+}
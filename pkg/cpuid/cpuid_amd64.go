@@ -379,6 +379,7 @@ var (
 	maxXsaveSize    = native(In{Eax: uint32(xSaveInfo)}).Ecx
 	amxTileCfgSize  = native(In{Eax: uint32(xSaveInfo), Ecx: 17}).Eax
 	amxTileDataSize = native(In{Eax: uint32(xSaveInfo), Ecx: 18}).Eax
+	pkruOffset      = native(In{Eax: uint32(xSaveInfo), Ecx: 9}).Ebx
 )
 
 const (
@@ -424,6 +425,17 @@ func (fs FeatureSet) AMXExtendedStateSize() uint {
 	return 0
 }
 
+// PKRUOffset returns the offset in bytes of the PKRU state component in the
+// standard (non-compacted) XSAVE area, and whether the PKRU register is
+// available to applications with this feature set and saved by XSAVE on the
+// host.
+func (fs FeatureSet) PKRUOffset() (uint, bool) {
+	if !fs.UseXsave() || !fs.HasFeature(X86FeatureOSPKE) || xgetbv(0)&XSAVEFeaturePKRU == 0 {
+		return 0, false
+	}
+	return uint(pkruOffset), true
+}
+
 // ValidXCR0Mask returns the valid bits in control register XCR0.
 //
 // Always exclude AMX bits, because we do not support it.
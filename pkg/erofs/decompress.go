@@ -0,0 +1,117 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs
+
+import (
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// ExtentData returns the physical data of the mapped extent ext.
+func (i *Image) ExtentData(ext *Extent) ([]byte, error) {
+	if !ext.Mapped() {
+		return nil, linuxerr.EINVAL
+	}
+	return i.BytesAt(ext.PhysicalOff, ext.PhysicalLen)
+}
+
+// Decompress decodes the encoded extent ext into dst, which must be exactly
+// ext.LogicalLen bytes long.
+//
+// Decompress does not cache anything; callers are expected to cache the
+// decompressed data if necessary.
+func (i *Image) Decompress(ext *Extent, dst []byte) error {
+	if !ext.Encoded() || uint64(len(dst)) != ext.LogicalLen {
+		return linuxerr.EINVAL
+	}
+	src, err := i.ExtentData(ext)
+	if err != nil {
+		return err
+	}
+
+	switch ext.Algorithm {
+	case CompressionShifted, CompressionInterlaced:
+		return i.transformPlain(ext, src, dst)
+
+	case CompressionLZ4:
+		if i.sb.FeatureIncompat&FeatureIncompatZeroPadding != 0 {
+			// The compressed data is placed at the end of the physical
+			// cluster, with zero padding ahead of it. Otherwise, the
+			// compressed data starts at the beginning of the physical
+			// cluster and is followed by garbage, which is never reached
+			// since decoding stops once dst is full.
+			if src, err = skipZeroPadding(src); err != nil {
+				return err
+			}
+		}
+		if n, err := lz4DecompressBlock(dst, src); err != nil || n != len(dst) {
+			log.Warningf("Failed to decompress LZ4 extent at 0x%x: %d of %d bytes, err: %v", ext.PhysicalOff, n, len(dst), err)
+			return linuxerr.EUCLEAN
+		}
+		return nil
+
+	case CompressionLZMA:
+		if src, err = skipZeroPadding(src); err != nil {
+			return err
+		}
+		if err := microLZMADecompress(dst, src, i.lzmaDictSize); err != nil {
+			log.Warningf("Failed to decompress MicroLZMA extent at 0x%x: %v", ext.PhysicalOff, err)
+			return linuxerr.EUCLEAN
+		}
+		return nil
+
+	default:
+		log.Warningf("Unsupported compression algorithm %d", ext.Algorithm)
+		return linuxerr.ENOTSUP
+	}
+}
+
+// skipZeroPadding skips the zero padding at the start of the compressed data
+// in src. Similar to Linux's fs/erofs/decompressor.c:z_erofs_fixup_insize(),
+// the padding never exceeds a page.
+func skipZeroPadding(src []byte) ([]byte, error) {
+	limit := min(len(src), hostarch.PageSize)
+	for n := 0; n < limit; n++ {
+		if src[n] != 0 {
+			return src[n:], nil
+		}
+	}
+	return nil, linuxerr.EUCLEAN
+}
+
+// transformPlain copies the uncompressed data of a plain physical cluster
+// src to dst. This matches Linux's
+// fs/erofs/decompressor.c:z_erofs_transform_plain().
+func (i *Image) transformPlain(ext *Extent, src, dst []byte) error {
+	if len(dst) > len(src) {
+		return linuxerr.EUCLEAN
+	}
+	if ext.Algorithm == CompressionShifted {
+		copy(dst, src)
+		return nil
+	}
+	// Interlaced physical clusters keep each byte at its offset within the
+	// block, so the head of the extent wraps around to the end of src.
+	blockSize := uint64(i.BlockSize())
+	wrapped := blockSize - ext.LogicalOff&(blockSize-1)
+	if wrapped > uint64(len(src)) {
+		return linuxerr.EUCLEAN
+	}
+	head := min(wrapped, uint64(len(dst)))
+	copy(dst[:head], src[uint64(len(src))-wrapped:])
+	copy(dst[head:], src)
+	return nil
+}
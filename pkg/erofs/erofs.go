@@ -89,15 +89,45 @@ const (
 //
 // This is not exhaustive, unused features are not listed.
 const (
-	FeatureIncompatSupported = 0x0
+	FeatureIncompatZeroPadding   = 0x00000001
+	FeatureIncompatComprCfgs     = 0x00000002
+	FeatureIncompatBigPcluster   = 0x00000002
+	FeatureIncompatChunkedFile   = 0x00000004
+	FeatureIncompatDeviceTable   = 0x00000008
+	FeatureIncompatComprHead2    = 0x00000008
+	FeatureIncompatZtailpacking  = 0x00000010
+	FeatureIncompatFragments     = 0x00000020
+	FeatureIncompatDedupe        = 0x00000020
+	FeatureIncompatXattrPrefixes = 0x00000040
+
+	FeatureIncompatSupported = FeatureIncompatZeroPadding |
+		FeatureIncompatComprCfgs |
+		FeatureIncompatChunkedFile |
+		FeatureIncompatDeviceTable |
+		FeatureIncompatZtailpacking
 )
 
 // Sizes of on-disk structures in bytes.
 const (
 	SuperBlockSize    = 128
+	SuperBlockExtSize = 16
 	InodeCompactSize  = 32
 	InodeExtendedSize = 64
 	DirentSize        = 12
+	ChunkIndexSize    = 8
+	BlockMapEntrySize = 4
+	MapHeaderSize     = 8
+	LclusterIndexSize = 8
+)
+
+// NullAddr is the block address of a hole in chunk-based files.
+const NullAddr = 0xffffffff
+
+// Bit definitions for the chunk format of chunk-based inodes.
+const (
+	ChunkFormatBlockBitsMask = 0x001f
+	ChunkFormatIndexes       = 0x0020
+	ChunkFormatAll           = ChunkFormatBlockBitsMask | ChunkFormatIndexes
 )
 
 // SuperBlock represents on-disk superblock.
@@ -126,6 +156,11 @@ type SuperBlock struct {
 	Reserved        [38]uint8
 }
 
+// Size returns the size of the superblock including its extended slots.
+func (sb *SuperBlock) Size() uint64 {
+	return SuperBlockSize + uint64(sb.ExtSlots)*SuperBlockExtSize
+}
+
 // BlockSize returns the block size.
 func (sb *SuperBlock) BlockSize() uint32 {
 	return 1 << sb.BlockSizeBits
@@ -200,6 +235,15 @@ func (d *Dirent) Nid() uint64 {
 	return (uint64(d.NidHigh) << 32) | uint64(d.NidLow)
 }
 
+// ChunkIndex represents on-disk chunk index of chunk-based inodes.
+//
+// +marshal
+type ChunkIndex struct {
+	Advise    uint16
+	DeviceID  uint16
+	BlockAddr uint32
+}
+
 // Image represents an open EROFS image.
 //
 // +stateify savable
@@ -207,6 +251,13 @@ type Image struct {
 	src   *os.File `state:"nosave"`
 	bytes []byte   `state:"nosave"`
 	sb    SuperBlock
+
+	// comprAlgs is the bitmask of compression algorithms which may be used
+	// by the compressed inodes in this image.
+	comprAlgs uint16
+
+	// lzmaDictSize is the dictionary size for MicroLZMA compressed data.
+	lzmaDictSize uint32
 }
 
 // OpenImage returns an Image providing access to the contents in the image file src.
@@ -231,6 +282,12 @@ func OpenImage(src *os.File) (*Image, error) {
 	if err := i.initSuperBlock(); err != nil {
 		return nil, err
 	}
+	if err := i.checkDevices(); err != nil {
+		return nil, err
+	}
+	if err := i.initCompression(); err != nil {
+		return nil, err
+	}
 	cu.Release()
 	return i, nil
 }
@@ -287,6 +344,17 @@ func (i *Image) initSuperBlock() error {
 	return nil
 }
 
+// checkDevices checks that the image does not require extra devices (blobs),
+// which are not supported.
+func (i *Image) checkDevices() error {
+	// FeatureIncompatDeviceTable shares its bit with FeatureIncompatComprHead2,
+	// so only the device count identifies images that use a device table.
+	if i.sb.FeatureIncompat&FeatureIncompatDeviceTable != 0 && i.sb.ExtraDevices != 0 {
+		return fmt.Errorf("unsupported extra devices: %d", i.sb.ExtraDevices)
+	}
+	return nil
+}
+
 // verifyChecksum verifies the checksum of the superblock.
 func (i *Image) verifyChecksum() error {
 	if i.sb.FeatureCompat&FeatureCompatSuperBlockChecksum == 0 {
@@ -472,6 +540,21 @@ func (i *Image) Inode(nid uint64) (Inode, error) {
 	inode.blocks = (inode.size + (blockSize - 1)) / blockSize
 
 	switch dataLayout := inode.DataLayout(); dataLayout {
+	case InodeDataLayoutChunkBased, InodeDataLayoutFlatCompressionLegacy, InodeDataLayoutFlatCompression:
+		if !inode.IsRegular() {
+			log.Warningf("Unsupported data layout 0x%x for non-regular file at inode (nid=%v)", dataLayout, nid)
+			return Inode{}, linuxerr.ENOTSUP
+		}
+		if dataLayout == InodeDataLayoutChunkBased {
+			if err := inode.initChunks(off, uint64(inodeSize), rawBlockAddr); err != nil {
+				return Inode{}, err
+			}
+		} else {
+			if err := inode.initCompressed(off, uint64(inodeSize)); err != nil {
+				return Inode{}, err
+			}
+		}
+
 	case InodeDataLayoutFlatInline:
 		// Check that whether the file data in the last block fits into
 		// the remaining room of the metadata block.
@@ -517,6 +600,14 @@ type Inode struct {
 	// format is the format of this inode.
 	format uint16
 
+	// chunkFormat and chunkBits describe the chunks of chunk-based inodes.
+	// The chunk indexes or block map entries are stored at dataOff.
+	chunkFormat uint16
+	chunkBits   uint8
+
+	// z contains the information of compressed inodes.
+	z zInfo
+
 	// Metadata.
 	mode      uint16
 	nid       uint64
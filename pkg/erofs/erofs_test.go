@@ -15,6 +15,8 @@
 package erofs
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -34,4 +36,106 @@ func TestOnDiskStructureSizes(t *testing.T) {
 	if d := new(Dirent); d.SizeBytes() != DirentSize {
 		t.Errorf("wrong dirent size: want %d, got %d", DirentSize, d.SizeBytes())
 	}
+
+	if c := new(ChunkIndex); c.SizeBytes() != ChunkIndexSize {
+		t.Errorf("wrong chunk index size: want %d, got %d", ChunkIndexSize, c.SizeBytes())
+	}
+
+	if h := new(MapHeader); h.SizeBytes() != MapHeaderSize {
+		t.Errorf("wrong map header size: want %d, got %d", MapHeaderSize, h.SizeBytes())
+	}
+
+	if l := new(LclusterIndex); l.SizeBytes() != LclusterIndexSize {
+		t.Errorf("wrong lcluster index size: want %d, got %d", LclusterIndexSize, l.SizeBytes())
+	}
+}
+
+func TestLZ4DecompressBlock(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		src     []byte
+		dstSize int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "literals only",
+			src:     []byte{0x50, 'e', 'r', 'o', 'f', 's'},
+			dstSize: 5,
+			want:    "erofs",
+		},
+		{
+			name:    "overlapping match",
+			src:     []byte{0x44, 'a', 'b', 'c', 'd', 4, 0, 0x30, 'x', 'y', 'z'},
+			dstSize: 15,
+			want:    "abcdabcdabcdxyz",
+		},
+		{
+			name:    "extended lengths",
+			src:     append(append([]byte{0xff, 0x00}, bytes.Repeat([]byte{'g'}, 15)...), 1, 0, 0x01, 0x10),
+			dstSize: 15 + 15 + 1 + 4,
+			want:    strings.Repeat("g", 35),
+		},
+		{
+			name:    "partial decoding",
+			src:     []byte{0x44, 'a', 'b', 'c', 'd', 4, 0, 0x30, 'x', 'y', 'z', 0xde, 0xad},
+			dstSize: 6,
+			want:    "abcdab",
+		},
+		{
+			name:    "offset out of range",
+			src:     []byte{0x14, 'a', 2, 0},
+			dstSize: 16,
+			wantErr: true,
+		},
+		{
+			name:    "truncated literals",
+			src:     []byte{0x50, 'e', 'r'},
+			dstSize: 5,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := make([]byte, tc.dstSize)
+			n, err := lz4DecompressBlock(dst, tc.src)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("lz4DecompressBlock succeeded unexpectedly")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lz4DecompressBlock failed: %v", err)
+			}
+			if got := string(dst[:n]); got != tc.want {
+				t.Errorf("lz4DecompressBlock got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMicroLZMADecompress(t *testing.T) {
+	// Generated from the raw LZMA1 stream (lc=3, lp=0, pb=2) of want, with
+	// the first byte replaced by the negated properties byte.
+	src := []byte{
+		0xa2, 0x33, 0x95, 0x89, 0x27, 0x6d, 0x91, 0xb3, 0x3b, 0x4d, 0x38, 0x0d,
+		0xe1, 0x30, 0xfa, 0xa3, 0x6c, 0x8a, 0xca, 0xf2, 0xf6, 0x91, 0x52, 0xcb,
+		0xac, 0x0f, 0x00, 0x2d, 0x54, 0x3b, 0x9b, 0x08, 0x2f, 0xff, 0xff, 0xf1,
+		0x0d, 0x00, 0x00,
+	}
+	want := strings.Repeat("gVisor EROFS MicroLZMA ", 16)
+
+	dst := make([]byte, len(want))
+	if err := microLZMADecompress(dst, src, 4096); err != nil {
+		t.Fatalf("microLZMADecompress failed: %v", err)
+	}
+	if got := string(dst); got != want {
+		t.Errorf("microLZMADecompress got %q, want %q", got, want)
+	}
+
+	// Corrupted properties must be rejected.
+	bad := append([]byte{0x00}, src[1:]...)
+	if err := microLZMADecompress(dst, bad, 4096); err == nil {
+		t.Errorf("microLZMADecompress succeeded with invalid properties")
+	}
 }
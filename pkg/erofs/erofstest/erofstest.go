@@ -0,0 +1,447 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package erofstest builds EROFS images for tests.
+package erofstest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/erofs"
+	"gvisor.dev/gvisor/pkg/marshal"
+)
+
+const (
+	// BlockSize is the block size of images built by Builder.
+	BlockSize = 4096
+
+	blockSizeBits = 12
+
+	// metaBlockAddr is the first block of the metadata area. Block 0 holds
+	// the superblock and the compression configurations.
+	metaBlockAddr = 1
+
+	// rootBlockAddr is the metadata block holding the root directory inode.
+	rootBlockAddr = 1
+
+	// configSize is the size of each compression configuration record.
+	configSize = 14
+)
+
+// Builder builds EROFS images whose root directory contains regular files
+// with the data layouts chosen by the caller.
+//
+// Every inode occupies a metadata block of its own, so that its inline data
+// and indexes never cross a block boundary. Data blocks are allocated after
+// the inode that refers to them.
+type Builder struct {
+	image           []byte
+	featureIncompat uint32
+	comprAlgs       uint16
+	lzmaDictSize    uint32
+	dirents         []dirent
+}
+
+type dirent struct {
+	name     string
+	nid      uint64
+	fileType uint8
+}
+
+// ZExtent describes a physical cluster of a compressed file. Each extent
+// starts at a logical cluster (block) boundary, so the length of Data must be
+// a multiple of BlockSize unless the extent is the last one of the file.
+type ZExtent struct {
+	// Type is the type of the extent's head logical cluster, i.e. one of
+	// erofs.LclusterTypePlain, erofs.LclusterTypeHead1 and
+	// erofs.LclusterTypeHead2.
+	Type uint8
+
+	// Data is the decompressed data of the extent.
+	Data []byte
+
+	// Compressed is the compressed data of the extent. It is ignored for
+	// erofs.LclusterTypePlain, whose data is stored uncompressed.
+	Compressed []byte
+}
+
+// NewBuilder returns a Builder for an empty image.
+func NewBuilder() *Builder {
+	return &Builder{
+		// Block 0 holds the superblock, block 1 the root directory inode.
+		image: make([]byte, (rootBlockAddr+1)*BlockSize),
+	}
+}
+
+// allocBlocks allocates n zeroed blocks and returns the address of the first.
+func (b *Builder) allocBlocks(n int) uint32 {
+	addr := uint32(len(b.image) / BlockSize)
+	b.image = append(b.image, make([]byte, n*BlockSize)...)
+	return addr
+}
+
+// blockOffset returns the offset of the block at addr in the image.
+func blockOffset(addr uint32) int {
+	return int(addr) * BlockSize
+}
+
+// nid returns the inode number of the inode at the start of the metadata
+// block at addr.
+func nid(addr uint32) uint64 {
+	return uint64(addr-metaBlockAddr) * BlockSize >> erofs.InodeSlotBits
+}
+
+// newInode allocates a metadata block for a regular file inode named name.
+// It returns the block address, which is also where the inode must be
+// written.
+func (b *Builder) newInode(name string) uint32 {
+	addr := b.allocBlocks(1)
+	b.dirents = append(b.dirents, dirent{
+		name:     name,
+		nid:      nid(addr),
+		fileType: linux.FT_REG_FILE,
+	})
+	return addr
+}
+
+// writeInode writes a compact inode to the start of the metadata block at
+// addr.
+func (b *Builder) writeInode(addr uint32, dataLayout uint16, mode uint16, nlink uint16, size int, rawBlockAddr uint32) {
+	ino := erofs.InodeCompact{
+		Format:       dataLayout << erofs.InodeDataLayoutBit,
+		Mode:         mode,
+		Nlink:        nlink,
+		Size:         uint32(size),
+		RawBlockAddr: rawBlockAddr,
+		Ino:          uint32(nid(addr)),
+	}
+	copy(b.image[blockOffset(addr):], marshal.Marshal(&ino))
+}
+
+// writeData allocates blocks for data, copies data into them, and returns the
+// address of the first block.
+func (b *Builder) writeData(data []byte) uint32 {
+	addr := b.allocBlocks((len(data) + BlockSize - 1) / BlockSize)
+	copy(b.image[blockOffset(addr):], data)
+	return addr
+}
+
+// AddFile adds a regular file whose data is stored in contiguous blocks.
+func (b *Builder) AddFile(name string, data []byte) {
+	addr := b.newInode(name)
+	b.writeInode(addr, erofs.InodeDataLayoutFlatPlain, linux.S_IFREG|0644, 1, len(data), b.writeData(data))
+}
+
+// AddInlineFile adds a regular file whose last partial block is stored
+// inline, right after its inode.
+func (b *Builder) AddInlineFile(name string, data []byte) {
+	full := len(data) &^ (BlockSize - 1)
+	tail := data[full:]
+	if len(tail) == 0 || len(tail) > BlockSize-erofs.InodeCompactSize {
+		panic(fmt.Sprintf("tail of %d bytes can't be inlined", len(tail)))
+	}
+	addr := b.newInode(name)
+	var dataAddr uint32
+	if full != 0 {
+		dataAddr = b.writeData(data[:full])
+	}
+	b.writeInode(addr, erofs.InodeDataLayoutFlatInline, linux.S_IFREG|0644, 1, len(data), dataAddr)
+	copy(b.image[blockOffset(addr)+erofs.InodeCompactSize:], tail)
+}
+
+// AddChunkedFile adds a chunk-based regular file, whose chunks are
+// BlockSize<<chunkBlockBits bytes long. If indexes is true, chunks are
+// described by chunk indexes; otherwise, by block map entries. Chunks that
+// contain only zeroes are stored as holes.
+func (b *Builder) AddChunkedFile(name string, data []byte, chunkBlockBits uint16, indexes bool) {
+	b.featureIncompat |= erofs.FeatureIncompatChunkedFile
+	addr := b.newInode(name)
+	format := chunkBlockBits
+	unit := erofs.BlockMapEntrySize
+	if indexes {
+		format |= erofs.ChunkFormatIndexes
+		unit = erofs.ChunkIndexSize
+	}
+	b.writeInode(addr, erofs.InodeDataLayoutChunkBased, linux.S_IFREG|0644, 1, len(data), uint32(format))
+
+	chunkSize := BlockSize << chunkBlockBits
+	off := alignUp(blockOffset(addr)+erofs.InodeCompactSize, unit)
+	for start := 0; start < len(data); start += chunkSize {
+		chunk := data[start:min(start+chunkSize, len(data))]
+		chunkAddr := uint32(erofs.NullAddr)
+		if !allZeroes(chunk) {
+			chunkAddr = b.writeData(chunk)
+		}
+		if indexes {
+			idx := erofs.ChunkIndex{BlockAddr: chunkAddr}
+			copy(b.image[off:], marshal.Marshal(&idx))
+		} else {
+			binary.LittleEndian.PutUint32(b.image[off:], chunkAddr)
+		}
+		off += unit
+	}
+	if off > blockOffset(addr)+BlockSize {
+		panic(fmt.Sprintf("too many chunks: %d", (len(data)+chunkSize-1)/chunkSize))
+	}
+}
+
+// AddCompressedFile adds a compressed regular file with full (non-compacted)
+// logical cluster indexes. algorithms are the compression algorithms of
+// HEAD1 and HEAD2 logical clusters respectively. If inlineTail is true, the
+// compressed data of the last extent is stored inline (ztailpacking).
+//
+// Compressed data is placed at the end of its physical cluster, preceded by
+// zero padding.
+func (b *Builder) AddCompressedFile(name string, algorithms [2]uint8, extents []ZExtent, inlineTail bool) {
+	b.featureIncompat |= erofs.FeatureIncompatZeroPadding | erofs.FeatureIncompatComprCfgs
+	for _, ext := range extents {
+		switch ext.Type {
+		case erofs.LclusterTypeHead1:
+			b.comprAlgs |= 1 << algorithms[0]
+		case erofs.LclusterTypeHead2:
+			b.featureIncompat |= erofs.FeatureIncompatComprHead2
+			b.comprAlgs |= 1 << algorithms[1]
+		}
+	}
+
+	addr := b.newInode(name)
+	size := 0
+	for _, ext := range extents {
+		size += len(ext.Data)
+	}
+	b.writeInode(addr, erofs.InodeDataLayoutFlatCompressionLegacy, linux.S_IFREG|0644, 1, size, 0)
+
+	// The map header is followed by an 8-byte reserved area and the full
+	// indexes.
+	hdrOff := alignUp(blockOffset(addr)+erofs.InodeCompactSize, 8)
+	idxOff := hdrOff + erofs.MapHeaderSize + 8
+	hdr := erofs.MapHeader{
+		AlgorithmType: algorithms[0] | algorithms[1]<<4,
+	}
+	for n, ext := range extents {
+		if n != len(extents)-1 && len(ext.Data)%BlockSize != 0 {
+			panic(fmt.Sprintf("extent %d is not block-aligned", n))
+		}
+		inline := inlineTail && n == len(extents)-1
+		var pblk uint32
+		switch {
+		case inline:
+			b.featureIncompat |= erofs.FeatureIncompatZtailpacking
+			hdr.Advise |= erofs.AdviseInlinePcluster
+			hdr.IdataSize = uint16(len(ext.Compressed))
+		case ext.Type == erofs.LclusterTypePlain:
+			if len(ext.Data) > BlockSize {
+				panic(fmt.Sprintf("plain extent %d is larger than a block", n))
+			}
+			pblk = b.writeData(ext.Data)
+		default:
+			if len(ext.Compressed) > BlockSize {
+				panic(fmt.Sprintf("compressed extent %d is larger than a block", n))
+			}
+			pblk = b.allocBlocks(1)
+			copy(b.image[blockOffset(pblk+1)-len(ext.Compressed):], ext.Compressed)
+		}
+
+		lclusters := (len(ext.Data) + BlockSize - 1) / BlockSize
+		head := erofs.LclusterIndex{
+			Advise:    uint16(ext.Type),
+			BlockAddr: pblk,
+		}
+		copy(b.image[idxOff:], marshal.Marshal(&head))
+		idxOff += erofs.LclusterIndexSize
+		for j := 1; j < lclusters; j++ {
+			// delta[0] is the distance to the head logical cluster, and
+			// delta[1] is the distance to the next head logical cluster.
+			nonHead := erofs.LclusterIndex{
+				Advise:    erofs.LclusterTypeNonHead,
+				BlockAddr: uint32(j) | uint32(lclusters-j)<<16,
+			}
+			copy(b.image[idxOff:], marshal.Marshal(&nonHead))
+			idxOff += erofs.LclusterIndexSize
+		}
+		if inline {
+			// The inline physical cluster follows the last index.
+			copy(b.image[idxOff:], ext.Compressed)
+			idxOff += len(ext.Compressed)
+		}
+	}
+	if idxOff > blockOffset(addr)+BlockSize {
+		panic("compressed file metadata doesn't fit in a block")
+	}
+	copy(b.image[hdrOff:], marshal.Marshal(&hdr))
+}
+
+// SetLZMADictSize sets the dictionary size of MicroLZMA compressed data.
+func (b *Builder) SetLZMADictSize(size uint32) {
+	b.lzmaDictSize = size
+}
+
+// Build returns the image.
+func (b *Builder) Build() []byte {
+	b.buildRoot()
+
+	sb := erofs.SuperBlock{
+		Magic:           erofs.SuperBlockMagicV1,
+		BlockSizeBits:   blockSizeBits,
+		RootNid:         uint16(nid(rootBlockAddr)),
+		Inodes:          uint64(len(b.dirents) - 1),
+		Blocks:          uint32(len(b.image) / BlockSize),
+		MetaBlockAddr:   metaBlockAddr,
+		FeatureIncompat: b.featureIncompat,
+	}
+	if b.featureIncompat&erofs.FeatureIncompatComprCfgs != 0 {
+		sb.Union1 = b.comprAlgs
+		off := alignUp(erofs.SuperBlockOffset+erofs.SuperBlockSize, 4)
+		for alg := 0; alg < erofs.CompressionMax; alg++ {
+			if b.comprAlgs&(1<<alg) == 0 {
+				continue
+			}
+			off = alignUp(off, 4)
+			binary.LittleEndian.PutUint16(b.image[off:], configSize)
+			off += 2
+			if alg == erofs.CompressionLZMA {
+				binary.LittleEndian.PutUint32(b.image[off:], b.lzmaDictSize)
+			}
+			off += configSize
+		}
+	}
+	copy(b.image[erofs.SuperBlockOffset:], marshal.Marshal(&sb))
+	return b.image
+}
+
+// buildRoot writes the root directory, which contains all files added to b.
+func (b *Builder) buildRoot() {
+	rootNid := nid(rootBlockAddr)
+	b.dirents = append(b.dirents,
+		dirent{name: ".", nid: rootNid, fileType: linux.FT_DIR},
+		dirent{name: "..", nid: rootNid, fileType: linux.FT_DIR})
+	sort.Slice(b.dirents, func(i, j int) bool {
+		return b.dirents[i].name < b.dirents[j].name
+	})
+
+	var data []byte
+	nameOff := len(b.dirents) * erofs.DirentSize
+	for _, d := range b.dirents {
+		de := erofs.Dirent{
+			NidLow:   uint32(d.nid),
+			NidHigh:  uint32(d.nid >> 32),
+			NameOff:  uint16(nameOff),
+			FileType: d.fileType,
+		}
+		data = append(data, marshal.Marshal(&de)...)
+		nameOff += len(d.name)
+	}
+	for _, d := range b.dirents {
+		data = append(data, d.name...)
+	}
+	if len(data) > BlockSize {
+		panic("root directory doesn't fit in a block")
+	}
+	b.writeInode(rootBlockAddr, erofs.InodeDataLayoutFlatPlain, linux.S_IFDIR|0755, 2, len(data), b.writeData(data))
+}
+
+// WriteImage builds the image and writes it to a temporary file, which is
+// returned opened for reading.
+func (b *Builder) WriteImage(tb testing.TB) *os.File {
+	path := filepath.Join(tb.TempDir(), "image.erofs")
+	if err := os.WriteFile(path, b.Build(), 0644); err != nil {
+		tb.Fatalf("failed to write image: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		tb.Fatalf("failed to open image: %v", err)
+	}
+	return f
+}
+
+// LZ4Compress compresses src into a single LZ4 block, using a simple greedy
+// match finder.
+func LZ4Compress(src []byte) []byte {
+	const (
+		minMatch = 4
+		// The last match must start at least 12 bytes before the end of the
+		// block, and the last 5 bytes are always literals.
+		matchStartLimit = 12
+		lastLiterals    = 5
+	)
+	var dst []byte
+	table := make(map[uint32]int)
+	anchor := 0
+	for i := 0; i+matchStartLimit < len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		ref, ok := table[seq]
+		table[seq] = i
+		if !ok || i-ref > 0xffff {
+			i++
+			continue
+		}
+		n := minMatch
+		for i+n < len(src)-lastLiterals && src[ref+n] == src[i+n] {
+			n++
+		}
+		dst = appendLZ4Sequence(dst, src[anchor:i], i-ref, n)
+		i += n
+		anchor = i
+	}
+	return appendLZ4Sequence(dst, src[anchor:], 0, 0)
+}
+
+// appendLZ4Sequence appends an LZ4 sequence with the given literals and
+// match to dst. If matchLen is 0, the sequence is the last one and has no
+// match.
+func appendLZ4Sequence(dst, literals []byte, offset, matchLen int) []byte {
+	token := byte(min(len(literals), 15)) << 4
+	if matchLen != 0 {
+		token |= byte(min(matchLen-4, 15))
+	}
+	dst = append(dst, token)
+	dst = appendLZ4Length(dst, len(literals))
+	dst = append(dst, literals...)
+	if matchLen != 0 {
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(offset))
+		dst = appendLZ4Length(dst, matchLen-4)
+	}
+	return dst
+}
+
+// appendLZ4Length appends the extra bytes encoding length n, whose first 15
+// are encoded in the token, to dst.
+func appendLZ4Length(dst []byte, n int) []byte {
+	if n < 15 {
+		return dst
+	}
+	for n -= 15; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+func alignUp(off, align int) int {
+	return (off + align - 1) &^ (align - 1)
+}
+
+func allZeroes(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,298 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/erofs"
+	"gvisor.dev/gvisor/pkg/erofs/erofstest"
+)
+
+// microLZMAData and microLZMACompressed are a MicroLZMA test vector, whose
+// dictionary size is 4096.
+var (
+	microLZMAData       = []byte(strings.Repeat("gVisor EROFS MicroLZMA ", 16))
+	microLZMACompressed = []byte{
+		0xa2, 0x33, 0x95, 0x89, 0x27, 0x6d, 0x91, 0xb3, 0x3b, 0x4d, 0x38, 0x0d,
+		0xe1, 0x30, 0xfa, 0xa3, 0x6c, 0x8a, 0xca, 0xf2, 0xf6, 0x91, 0x52, 0xcb,
+		0xac, 0x0f, 0x00, 0x2d, 0x54, 0x3b, 0x9b, 0x08, 0x2f, 0xff, 0xff, 0xf1,
+		0x0d, 0x00, 0x00,
+	}
+)
+
+// testData returns n bytes of compressible, non-zero data.
+func testData(n int, seed string) []byte {
+	return []byte(strings.Repeat(seed, n/len(seed)+1)[:n])
+}
+
+// incompressibleData returns n bytes of data that LZ4 can't compress.
+func incompressibleData(n int) []byte {
+	data := make([]byte, n)
+	x := uint32(1)
+	for i := range data {
+		// xorshift32.
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		data[i] = byte(x)
+	}
+	return data
+}
+
+func openImage(t *testing.T, b *erofstest.Builder) *erofs.Image {
+	t.Helper()
+	f := b.WriteImage(t)
+	image, err := erofs.OpenImage(f)
+	if err != nil {
+		f.Close()
+		t.Fatalf("OpenImage failed: %v", err)
+	}
+	t.Cleanup(image.Close)
+	return image
+}
+
+func lookup(t *testing.T, image *erofs.Image, name string) erofs.Inode {
+	t.Helper()
+	root, err := image.Inode(image.RootNid())
+	if err != nil {
+		t.Fatalf("failed to get root inode: %v", err)
+	}
+	nid, err := root.Lookup(name)
+	if err != nil {
+		t.Fatalf("failed to look up %q: %v", name, err)
+	}
+	inode, err := image.Inode(nid)
+	if err != nil {
+		t.Fatalf("failed to get inode of %q: %v", name, err)
+	}
+	return inode
+}
+
+// readExtents reads the whole file data of inode extent by extent, and
+// returns the data and the extents.
+func readExtents(t *testing.T, image *erofs.Image, inode *erofs.Inode) ([]byte, []erofs.Extent) {
+	t.Helper()
+	var (
+		data    []byte
+		extents []erofs.Extent
+	)
+	for off := uint64(0); off < inode.Size(); {
+		ext, err := inode.MapBlocks(off)
+		if err != nil {
+			t.Fatalf("MapBlocks(%d) failed: %v", off, err)
+		}
+		if ext.LogicalOff != off {
+			t.Fatalf("MapBlocks(%d) returned extent at %d", off, ext.LogicalOff)
+		}
+		buf := make([]byte, ext.LogicalLen)
+		switch {
+		case !ext.Mapped():
+		case ext.Encoded():
+			if err := image.Decompress(&ext, buf); err != nil {
+				t.Fatalf("Decompress(%+v) failed: %v", ext, err)
+			}
+		default:
+			src, err := image.ExtentData(&ext)
+			if err != nil {
+				t.Fatalf("ExtentData(%+v) failed: %v", ext, err)
+			}
+			copy(buf, src)
+		}
+		data = append(data, buf...)
+		extents = append(extents, ext)
+		off += ext.LogicalLen
+	}
+	return data, extents
+}
+
+// checkExtent checks that the extent containing off starts at logicalOff, is
+// logicalLen bytes long and has the given flags.
+func checkExtent(t *testing.T, inode *erofs.Inode, off, logicalOff, logicalLen uint64, flags uint32) erofs.Extent {
+	t.Helper()
+	ext, err := inode.MapBlocks(off)
+	if err != nil {
+		t.Fatalf("MapBlocks(%d) failed: %v", off, err)
+	}
+	if ext.LogicalOff != logicalOff || ext.LogicalLen != logicalLen || ext.Flags != flags {
+		t.Errorf("MapBlocks(%d) got extent %+v, want logical range [%d, %d) and flags %#x", off, ext, logicalOff, logicalOff+logicalLen, flags)
+	}
+	return ext
+}
+
+func TestFlatFiles(t *testing.T) {
+	plain := testData(2*erofstest.BlockSize+100, "plain ")
+	inline := testData(erofstest.BlockSize+100, "inline ")
+	b := erofstest.NewBuilder()
+	b.AddFile("plain", plain)
+	b.AddInlineFile("inline", inline)
+	image := openImage(t, b)
+
+	p := lookup(t, image, "plain")
+	checkExtent(t, &p, erofstest.BlockSize, 0, uint64(len(plain)), erofs.ExtentMapped)
+	if got, _ := readExtents(t, image, &p); !bytes.Equal(got, plain) {
+		t.Errorf("plain file data mismatch")
+	}
+
+	i := lookup(t, image, "inline")
+	checkExtent(t, &i, 0, 0, erofstest.BlockSize, erofs.ExtentMapped)
+	checkExtent(t, &i, erofstest.BlockSize+1, erofstest.BlockSize, 100, erofs.ExtentMapped|erofs.ExtentMeta)
+	if got, _ := readExtents(t, image, &i); !bytes.Equal(got, inline) {
+		t.Errorf("inline file data mismatch")
+	}
+}
+
+func TestChunkedFile(t *testing.T) {
+	// Chunks are two blocks long. The second chunk is a hole, and the last
+	// chunk is partial.
+	const chunkSize = 2 * erofstest.BlockSize
+	data := testData(3*chunkSize+100, "chunk ")
+	copy(data[chunkSize:], make([]byte, chunkSize))
+
+	for _, indexes := range []bool{true, false} {
+		name := "blockmap"
+		if indexes {
+			name = "indexes"
+		}
+		t.Run(name, func(t *testing.T) {
+			b := erofstest.NewBuilder()
+			b.AddChunkedFile("file", data, 1 /* chunkBlockBits */, indexes)
+			image := openImage(t, b)
+			inode := lookup(t, image, "file")
+
+			checkExtent(t, &inode, chunkSize-1, 0, chunkSize, erofs.ExtentMapped)
+			checkExtent(t, &inode, chunkSize, chunkSize, chunkSize, 0)
+			checkExtent(t, &inode, 2*chunkSize+erofstest.BlockSize, 2*chunkSize, chunkSize, erofs.ExtentMapped)
+			ext := checkExtent(t, &inode, 3*chunkSize, 3*chunkSize, 100, erofs.ExtentMapped)
+			if ext.PhysicalLen != erofstest.BlockSize {
+				t.Errorf("partial chunk got physical length %d, want %d", ext.PhysicalLen, erofstest.BlockSize)
+			}
+			if _, err := image.ExtentData(&erofs.Extent{}); err == nil {
+				t.Errorf("ExtentData succeeded for a hole")
+			}
+
+			if got, _ := readExtents(t, image, &inode); !bytes.Equal(got, data) {
+				t.Errorf("chunked file data mismatch")
+			}
+		})
+	}
+}
+
+func TestCompressedFile(t *testing.T) {
+	// A HEAD1 extent spanning two logical clusters, a PLAIN extent and a
+	// HEAD2 extent at the end of the file.
+	head1 := testData(2*erofstest.BlockSize, "gVisor EROFS LZ4 ")
+	plain := incompressibleData(erofstest.BlockSize)
+	var data []byte
+	data = append(data, head1...)
+	data = append(data, plain...)
+	data = append(data, microLZMAData...)
+
+	b := erofstest.NewBuilder()
+	b.SetLZMADictSize(4096)
+	b.AddCompressedFile("file", [2]uint8{erofs.CompressionLZ4, erofs.CompressionLZMA}, []erofstest.ZExtent{
+		{Type: erofs.LclusterTypeHead1, Data: head1, Compressed: erofstest.LZ4Compress(head1)},
+		{Type: erofs.LclusterTypePlain, Data: plain},
+		{Type: erofs.LclusterTypeHead2, Data: microLZMAData, Compressed: microLZMACompressed},
+	}, false /* inlineTail */)
+	image := openImage(t, b)
+	inode := lookup(t, image, "file")
+
+	const encoded = erofs.ExtentMapped | erofs.ExtentEncoded
+	// Offsets in the non-head logical cluster map to the head one.
+	for _, off := range []uint64{0, erofstest.BlockSize, 2*erofstest.BlockSize - 1} {
+		ext := checkExtent(t, &inode, off, 0, 2*erofstest.BlockSize, encoded)
+		if ext.Algorithm != erofs.CompressionLZ4 || ext.PhysicalLen != erofstest.BlockSize {
+			t.Errorf("MapBlocks(%d) got algorithm %d and physical length %d, want %d and %d", off, ext.Algorithm, ext.PhysicalLen, erofs.CompressionLZ4, erofstest.BlockSize)
+		}
+	}
+	if ext := checkExtent(t, &inode, 2*erofstest.BlockSize, 2*erofstest.BlockSize, erofstest.BlockSize, encoded); ext.Algorithm != erofs.CompressionShifted {
+		t.Errorf("plain extent got algorithm %d, want %d", ext.Algorithm, erofs.CompressionShifted)
+	}
+	if ext := checkExtent(t, &inode, uint64(len(data)-1), 3*erofstest.BlockSize, uint64(len(microLZMAData)), encoded); ext.Algorithm != erofs.CompressionLZMA {
+		t.Errorf("HEAD2 extent got algorithm %d, want %d", ext.Algorithm, erofs.CompressionLZMA)
+	}
+
+	got, extents := readExtents(t, image, &inode)
+	if len(extents) != 3 {
+		t.Errorf("got %d extents, want 3", len(extents))
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("compressed file data mismatch")
+	}
+
+	// dst must be exactly as long as the extent.
+	ext := extents[0]
+	if err := image.Decompress(&ext, make([]byte, ext.LogicalLen-1)); err == nil {
+		t.Errorf("Decompress succeeded with a short buffer")
+	}
+}
+
+func TestTailPackedFile(t *testing.T) {
+	head := testData(erofstest.BlockSize, "head ")
+	tail := testData(1000, "tail ")
+	var data []byte
+	data = append(data, head...)
+	data = append(data, tail...)
+
+	b := erofstest.NewBuilder()
+	b.AddCompressedFile("file", [2]uint8{erofs.CompressionLZ4, erofs.CompressionLZ4}, []erofstest.ZExtent{
+		{Type: erofs.LclusterTypeHead1, Data: head, Compressed: erofstest.LZ4Compress(head)},
+		{Type: erofs.LclusterTypeHead1, Data: tail, Compressed: erofstest.LZ4Compress(tail)},
+	}, true /* inlineTail */)
+	image := openImage(t, b)
+	inode := lookup(t, image, "file")
+
+	checkExtent(t, &inode, 0, 0, erofstest.BlockSize, erofs.ExtentMapped|erofs.ExtentEncoded)
+	ext := checkExtent(t, &inode, erofstest.BlockSize, erofstest.BlockSize, uint64(len(tail)), erofs.ExtentMapped|erofs.ExtentEncoded|erofs.ExtentMeta)
+	if want := uint64(len(erofstest.LZ4Compress(tail))); ext.PhysicalLen != want {
+		t.Errorf("tail extent got physical length %d, want %d", ext.PhysicalLen, want)
+	}
+
+	if got, _ := readExtents(t, image, &inode); !bytes.Equal(got, data) {
+		t.Errorf("tail-packed file data mismatch")
+	}
+}
+
+func TestExtraDevices(t *testing.T) {
+	b := erofstest.NewBuilder()
+	b.AddFile("file", []byte("data"))
+	data := b.Build()
+
+	var sb erofs.SuperBlock
+	sb.UnmarshalBytes(data[erofs.SuperBlockOffset:])
+	sb.FeatureIncompat |= erofs.FeatureIncompatDeviceTable
+	sb.ExtraDevices = 1
+	sb.MarshalBytes(data[erofs.SuperBlockOffset:])
+
+	path := filepath.Join(t.TempDir(), "image.erofs")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open image: %v", err)
+	}
+	image, err := erofs.OpenImage(f)
+	if err == nil {
+		image.Close()
+		t.Fatalf("OpenImage succeeded with extra devices")
+	}
+	f.Close()
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs
+
+import (
+	"errors"
+)
+
+// lz4MinMatch is the minimum length of an LZ4 match.
+const lz4MinMatch = 4
+
+var errLZ4Corrupted = errors.New("corrupted LZ4 block")
+
+// lz4ReadLength reads the extended length following a 4-bit length of 15 in
+// an LZ4 sequence.
+func lz4ReadLength(src []byte, si *int) (int, error) {
+	n := 0
+	for {
+		if *si >= len(src) {
+			return 0, errLZ4Corrupted
+		}
+		b := src[*si]
+		*si++
+		n += int(b)
+		if b != 255 {
+			return n, nil
+		}
+		if n > len(src)<<8 {
+			// The length can't possibly be satisfied by the input.
+			return 0, errLZ4Corrupted
+		}
+	}
+}
+
+// lz4DecompressBlock decompresses the raw LZ4 block src into dst, and returns
+// the number of bytes written to dst.
+//
+// Similar to LZ4_decompress_safe_partial(), decoding stops as soon as dst is
+// full, so any input after that point is ignored.
+func lz4DecompressBlock(dst, src []byte) (int, error) {
+	di, si := 0, 0
+	for si < len(src) {
+		token := src[si]
+		si++
+
+		// Copy literals.
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			n, err := lz4ReadLength(src, &si)
+			if err != nil {
+				return di, err
+			}
+			litLen += n
+		}
+		if litLen > len(src)-si {
+			return di, errLZ4Corrupted
+		}
+		n := copy(dst[di:], src[si:si+litLen])
+		di += n
+		si += litLen
+		if di == len(dst) || si == len(src) {
+			// Either dst is full, or this is the last sequence, which
+			// only contains literals.
+			return di, nil
+		}
+
+		// Copy the match.
+		if len(src)-si < 2 {
+			return di, errLZ4Corrupted
+		}
+		offset := int(src[si]) | int(src[si+1])<<8
+		si += 2
+		if offset == 0 || offset > di {
+			return di, errLZ4Corrupted
+		}
+		matchLen := int(token & 0xf)
+		if matchLen == 15 {
+			n, err := lz4ReadLength(src, &si)
+			if err != nil {
+				return di, err
+			}
+			matchLen += n
+		}
+		matchLen += lz4MinMatch
+		end := min(di+matchLen, len(dst))
+		if offset >= matchLen {
+			di += copy(dst[di:end], dst[di-offset:])
+		} else {
+			// The match overlaps with the bytes being written, so it must
+			// be copied byte by byte.
+			for ; di < end; di++ {
+				dst[di] = dst[di-offset]
+			}
+		}
+		if di == len(dst) {
+			return di, nil
+		}
+	}
+	return di, nil
+}
@@ -0,0 +1,414 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs
+
+import (
+	"errors"
+)
+
+// This file implements a decoder for MicroLZMA, the raw LZMA variant used by
+// EROFS. A MicroLZMA stream is an LZMA stream without the header, whose first
+// byte (which is always zero in LZMA) holds the bitwise negation of the LZMA
+// properties byte. The dictionary size and the uncompressed size are known
+// out of band.
+//
+// The decoder follows the LZMA specification (LzmaSpec.cpp in the LZMA SDK).
+// Since EROFS always decompresses a whole physical cluster at once, the
+// output buffer doubles as the dictionary.
+
+const (
+	// lzmaConfigSize is the size of struct z_erofs_lzma_cfgs.
+	lzmaConfigSize = 14
+
+	// lzmaMaxDictSize is the maximum dictionary size supported by Linux.
+	lzmaMaxDictSize = 8 << 20
+
+	lzmaNumStates          = 12
+	lzmaNumPosBitsMax      = 4
+	lzmaNumLenToPosStates  = 4
+	lzmaNumAlignBits       = 4
+	lzmaStartPosModelIndex = 4
+	lzmaEndPosModelIndex   = 14
+	lzmaNumFullDistances   = 1 << (lzmaEndPosModelIndex >> 1)
+	lzmaMatchMinLen        = 2
+
+	lzmaNumBitModelTotalBits = 11
+	lzmaBitModelTotal        = 1 << lzmaNumBitModelTotalBits
+	lzmaNumMoveBits          = 5
+	lzmaTopValue             = 1 << 24
+	lzmaProbInitValue        = lzmaBitModelTotal / 2
+)
+
+var errLZMACorrupted = errors.New("corrupted MicroLZMA stream")
+
+// lzmaRangeDecoder is the LZMA range decoder.
+type lzmaRangeDecoder struct {
+	src   []byte
+	pos   int
+	rng   uint32
+	code  uint32
+	error bool
+}
+
+func (rc *lzmaRangeDecoder) readByte() uint32 {
+	if rc.pos >= len(rc.src) {
+		rc.error = true
+		return 0
+	}
+	b := rc.src[rc.pos]
+	rc.pos++
+	return uint32(b)
+}
+
+// init initializes the range decoder. The first byte of the stream is
+// skipped, since it holds the properties byte in MicroLZMA.
+func (rc *lzmaRangeDecoder) init(src []byte) {
+	rc.src = src
+	rc.pos = 1
+	rc.rng = 0xffffffff
+	for i := 0; i < 4; i++ {
+		rc.code = rc.code<<8 | rc.readByte()
+	}
+	if rc.code == rc.rng {
+		rc.error = true
+	}
+}
+
+func (rc *lzmaRangeDecoder) normalize() {
+	if rc.rng < lzmaTopValue {
+		rc.rng <<= 8
+		rc.code = rc.code<<8 | rc.readByte()
+	}
+}
+
+func (rc *lzmaRangeDecoder) decodeDirectBits(numBits uint32) uint32 {
+	var res uint32
+	for ; numBits > 0; numBits-- {
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		t := 0 - (rc.code >> 31)
+		rc.code += rc.rng & t
+		if rc.code == rc.rng {
+			rc.error = true
+		}
+		rc.normalize()
+		res = res<<1 + t + 1
+	}
+	return res
+}
+
+func (rc *lzmaRangeDecoder) decodeBit(prob *uint16) uint32 {
+	v := uint32(*prob)
+	bound := (rc.rng >> lzmaNumBitModelTotalBits) * v
+	var symbol uint32
+	if rc.code < bound {
+		v += (lzmaBitModelTotal - v) >> lzmaNumMoveBits
+		rc.rng = bound
+	} else {
+		v -= v >> lzmaNumMoveBits
+		rc.code -= bound
+		rc.rng -= bound
+		symbol = 1
+	}
+	*prob = uint16(v)
+	rc.normalize()
+	return symbol
+}
+
+func lzmaInitProbs(probs []uint16) {
+	for i := range probs {
+		probs[i] = lzmaProbInitValue
+	}
+}
+
+// lzmaBitTreeDecode decodes a numBits-bit symbol with the bit tree probs.
+func lzmaBitTreeDecode(probs []uint16, numBits uint32, rc *lzmaRangeDecoder) uint32 {
+	m := uint32(1)
+	for i := uint32(0); i < numBits; i++ {
+		m = m<<1 + rc.decodeBit(&probs[m])
+	}
+	return m - (1 << numBits)
+}
+
+// lzmaBitTreeReverseDecode decodes a numBits-bit symbol with the bit tree
+// probs, least significant bit first.
+func lzmaBitTreeReverseDecode(probs []uint16, numBits uint32, rc *lzmaRangeDecoder) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := uint32(0); i < numBits; i++ {
+		bit := rc.decodeBit(&probs[m])
+		m = m<<1 + bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+// lzmaLenDecoder decodes match lengths.
+type lzmaLenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << lzmaNumPosBitsMax][1 << 3]uint16
+	mid     [1 << lzmaNumPosBitsMax][1 << 3]uint16
+	high    [1 << 8]uint16
+}
+
+func (ld *lzmaLenDecoder) init() {
+	ld.choice = lzmaProbInitValue
+	ld.choice2 = lzmaProbInitValue
+	for i := range ld.low {
+		lzmaInitProbs(ld.low[i][:])
+		lzmaInitProbs(ld.mid[i][:])
+	}
+	lzmaInitProbs(ld.high[:])
+}
+
+func (ld *lzmaLenDecoder) decode(rc *lzmaRangeDecoder, posState uint32) uint32 {
+	if rc.decodeBit(&ld.choice) == 0 {
+		return lzmaBitTreeDecode(ld.low[posState][:], 3, rc)
+	}
+	if rc.decodeBit(&ld.choice2) == 0 {
+		return 8 + lzmaBitTreeDecode(ld.mid[posState][:], 3, rc)
+	}
+	return 16 + lzmaBitTreeDecode(ld.high[:], 8, rc)
+}
+
+// lzmaDecoder holds the state of an LZMA decoder.
+type lzmaDecoder struct {
+	rc lzmaRangeDecoder
+
+	lc, lp, pb uint32
+
+	literalProbs []uint16
+	posSlot      [lzmaNumLenToPosStates][1 << 6]uint16
+	posDecoders  [1 + lzmaNumFullDistances - lzmaEndPosModelIndex]uint16
+	align        [1 << lzmaNumAlignBits]uint16
+	isMatch      [lzmaNumStates << lzmaNumPosBitsMax]uint16
+	isRep        [lzmaNumStates]uint16
+	isRepG0      [lzmaNumStates]uint16
+	isRepG1      [lzmaNumStates]uint16
+	isRepG2      [lzmaNumStates]uint16
+	isRep0Long   [lzmaNumStates << lzmaNumPosBitsMax]uint16
+	lenDecoder   lzmaLenDecoder
+	repLen       lzmaLenDecoder
+
+	// out is the output buffer, and pos is the number of bytes written.
+	out []byte
+	pos int
+}
+
+func (d *lzmaDecoder) init(props byte) error {
+	if props >= 9*5*5 {
+		return errLZMACorrupted
+	}
+	d.lc = uint32(props % 9)
+	props /= 9
+	d.lp = uint32(props % 5)
+	d.pb = uint32(props / 5)
+	d.literalProbs = make([]uint16, 0x300<<(d.lc+d.lp))
+	lzmaInitProbs(d.literalProbs)
+	for i := range d.posSlot {
+		lzmaInitProbs(d.posSlot[i][:])
+	}
+	lzmaInitProbs(d.posDecoders[:])
+	lzmaInitProbs(d.align[:])
+	lzmaInitProbs(d.isMatch[:])
+	lzmaInitProbs(d.isRep[:])
+	lzmaInitProbs(d.isRepG0[:])
+	lzmaInitProbs(d.isRepG1[:])
+	lzmaInitProbs(d.isRepG2[:])
+	lzmaInitProbs(d.isRep0Long[:])
+	d.lenDecoder.init()
+	d.repLen.init()
+	return nil
+}
+
+// getByte returns the byte dist bytes before the current position.
+//
+// Precondition: 0 < dist <= d.pos.
+func (d *lzmaDecoder) getByte(dist uint32) byte {
+	return d.out[d.pos-int(dist)]
+}
+
+func (d *lzmaDecoder) decodeLiteral(state, rep0 uint32) {
+	var prevByte uint32
+	if d.pos > 0 {
+		prevByte = uint32(d.getByte(1))
+	}
+	symbol := uint32(1)
+	litState := ((uint32(d.pos) & ((1 << d.lp) - 1)) << d.lc) + (prevByte >> (8 - d.lc))
+	probs := d.literalProbs[0x300*litState : 0x300*(litState+1)]
+	if state >= 7 {
+		matchByte := uint32(d.getByte(rep0 + 1))
+		for symbol < 0x100 {
+			matchBit := (matchByte >> 7) & 1
+			matchByte <<= 1
+			bit := d.rc.decodeBit(&probs[((1+matchBit)<<8)+symbol])
+			symbol = symbol<<1 | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | d.rc.decodeBit(&probs[symbol])
+	}
+	d.out[d.pos] = byte(symbol - 0x100)
+	d.pos++
+}
+
+func (d *lzmaDecoder) decodeDistance(length uint32) uint32 {
+	lenState := min(length, lzmaNumLenToPosStates-1)
+	posSlot := lzmaBitTreeDecode(d.posSlot[lenState][:], 6, &d.rc)
+	if posSlot < lzmaStartPosModelIndex {
+		return posSlot
+	}
+	numDirectBits := (posSlot >> 1) - 1
+	dist := (2 | (posSlot & 1)) << numDirectBits
+	if posSlot < lzmaEndPosModelIndex {
+		dist += lzmaBitTreeReverseDecode(d.posDecoders[dist-posSlot:], numDirectBits, &d.rc)
+	} else {
+		dist += d.rc.decodeDirectBits(numDirectBits-lzmaNumAlignBits) << lzmaNumAlignBits
+		dist += lzmaBitTreeReverseDecode(d.align[:], lzmaNumAlignBits, &d.rc)
+	}
+	return dist
+}
+
+func lzmaUpdateStateLiteral(state uint32) uint32 {
+	switch {
+	case state < 4:
+		return 0
+	case state < 10:
+		return state - 3
+	default:
+		return state - 6
+	}
+}
+
+func lzmaUpdateStateMatch(state uint32) uint32 {
+	if state < 7 {
+		return 7
+	}
+	return 10
+}
+
+func lzmaUpdateStateRep(state uint32) uint32 {
+	if state < 7 {
+		return 8
+	}
+	return 11
+}
+
+func lzmaUpdateStateShortRep(state uint32) uint32 {
+	if state < 7 {
+		return 9
+	}
+	return 11
+}
+
+// decode decodes the stream until the output buffer is full.
+func (d *lzmaDecoder) decode(dictSize uint32) error {
+	var rep0, rep1, rep2, rep3, state uint32
+	for d.pos < len(d.out) {
+		if d.rc.error {
+			return errLZMACorrupted
+		}
+		posState := uint32(d.pos) & ((1 << d.pb) - 1)
+		if d.rc.decodeBit(&d.isMatch[state<<lzmaNumPosBitsMax+posState]) == 0 {
+			d.decodeLiteral(state, rep0)
+			state = lzmaUpdateStateLiteral(state)
+			continue
+		}
+
+		var length uint32
+		if d.rc.decodeBit(&d.isRep[state]) != 0 {
+			if d.pos == 0 {
+				return errLZMACorrupted
+			}
+			if d.rc.decodeBit(&d.isRepG0[state]) == 0 {
+				if d.rc.decodeBit(&d.isRep0Long[state<<lzmaNumPosBitsMax+posState]) == 0 {
+					state = lzmaUpdateStateShortRep(state)
+					d.out[d.pos] = d.getByte(rep0 + 1)
+					d.pos++
+					continue
+				}
+			} else {
+				var dist uint32
+				if d.rc.decodeBit(&d.isRepG1[state]) == 0 {
+					dist = rep1
+				} else {
+					if d.rc.decodeBit(&d.isRepG2[state]) == 0 {
+						dist = rep2
+					} else {
+						dist = rep3
+						rep3 = rep2
+					}
+					rep2 = rep1
+				}
+				rep1 = rep0
+				rep0 = dist
+			}
+			length = d.repLen.decode(&d.rc, posState)
+			state = lzmaUpdateStateRep(state)
+		} else {
+			rep3 = rep2
+			rep2 = rep1
+			rep1 = rep0
+			length = d.lenDecoder.decode(&d.rc, posState)
+			state = lzmaUpdateStateMatch(state)
+			rep0 = d.decodeDistance(length)
+			if rep0 == 0xffffffff {
+				// This is the end marker, which must not appear before
+				// the output is complete.
+				return errLZMACorrupted
+			}
+			if rep0 >= dictSize || int(rep0) >= d.pos {
+				return errLZMACorrupted
+			}
+		}
+
+		// Matches may be truncated at the end of the output.
+		length += lzmaMatchMinLen
+		end := min(d.pos+int(length), len(d.out))
+		src := d.pos - int(rep0) - 1
+		for ; d.pos < end; d.pos++ {
+			d.out[d.pos] = d.out[src]
+			src++
+		}
+	}
+	if d.rc.error {
+		return errLZMACorrupted
+	}
+	return nil
+}
+
+// microLZMADecompress decompresses the MicroLZMA stream src into dst, which
+// must be exactly as long as the uncompressed data.
+func microLZMADecompress(dst, src []byte, dictSize uint32) error {
+	if len(src) < 5 {
+		return errLZMACorrupted
+	}
+	var d lzmaDecoder
+	if err := d.init(^src[0]); err != nil {
+		return err
+	}
+	// Matches can't reach beyond the start of dst, so a dictionary larger
+	// than the output is never needed.
+	if dictSize == 0 || int(dictSize) > len(dst) {
+		dictSize = uint32(min(len(dst), lzmaMaxDictSize))
+	}
+	d.rc.init(src)
+	d.out = dst
+	return d.decode(dictSize)
+}
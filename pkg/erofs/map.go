@@ -0,0 +1,232 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// Extent flags.
+const (
+	// ExtentMapped indicates that the extent is backed by data in the image.
+	// Extents without this flag are holes, which read as zeroes.
+	ExtentMapped = 1 << iota
+
+	// ExtentMeta indicates that the physical data of the extent is inlined in
+	// the metadata area of the image.
+	ExtentMeta
+
+	// ExtentEncoded indicates that the physical data of the extent must be
+	// decoded with Image.Decompress before use.
+	ExtentEncoded
+)
+
+// Extent describes a contiguous range of file data and where it is stored.
+type Extent struct {
+	// LogicalOff and LogicalLen describe the range of file data covered by
+	// this extent.
+	LogicalOff uint64
+	LogicalLen uint64
+
+	// PhysicalOff and PhysicalLen describe the range of the image that
+	// stores the (possibly encoded) data of this extent.
+	PhysicalOff uint64
+	PhysicalLen uint64
+
+	// Algorithm is the algorithm used to encode the extent. It is only
+	// meaningful if Flags contains ExtentEncoded.
+	Algorithm uint8
+
+	// Flags is a combination of Extent* flags.
+	Flags uint32
+}
+
+// Mapped returns true if the extent is backed by data in the image.
+func (e *Extent) Mapped() bool {
+	return e.Flags&ExtentMapped != 0
+}
+
+// Encoded returns true if the extent must be decompressed before use.
+func (e *Extent) Encoded() bool {
+	return e.Flags&ExtentEncoded != 0
+}
+
+// MapBlocks returns the extent containing the file data at offset off.
+//
+// Precondition: off < i.Size().
+func (i *Inode) MapBlocks(off uint64) (Extent, error) {
+	if off >= i.size {
+		return Extent{}, linuxerr.EINVAL
+	}
+	switch dataLayout := i.DataLayout(); dataLayout {
+	case InodeDataLayoutFlatPlain, InodeDataLayoutFlatInline:
+		blockSize := uint64(i.image.BlockSize())
+		if i.idataOff != 0 && off >= (i.blocks-1)*blockSize {
+			tailOff := (i.blocks - 1) * blockSize
+			return Extent{
+				LogicalOff:  tailOff,
+				LogicalLen:  i.size - tailOff,
+				PhysicalOff: i.idataOff,
+				PhysicalLen: i.size - tailOff,
+				Flags:       ExtentMapped | ExtentMeta,
+			}, nil
+		}
+		size := i.size
+		if i.idataOff != 0 {
+			size = (i.blocks - 1) * blockSize
+		}
+		return Extent{
+			LogicalLen:  size,
+			PhysicalOff: i.dataOff,
+			PhysicalLen: size,
+			Flags:       ExtentMapped,
+		}, nil
+
+	case InodeDataLayoutChunkBased:
+		return i.mapChunk(off)
+
+	case InodeDataLayoutFlatCompressionLegacy, InodeDataLayoutFlatCompression:
+		return i.mapCompressed(off)
+
+	default:
+		log.Warningf("Unsupported data layout 0x%x at inode (nid=%v)", dataLayout, i.Nid())
+		return Extent{}, linuxerr.ENOTSUP
+	}
+}
+
+// alignUp rounds off up to a multiple of align, which must be a power of 2.
+func alignUp(off, align uint64) uint64 {
+	return (off + align - 1) &^ (align - 1)
+}
+
+// initChunks initializes the chunk information of chunk-based inode i, whose
+// on-disk inode of size inodeSize is located at off. info is the raw chunk
+// information stored in the on-disk inode.
+func (i *Inode) initChunks(off, inodeSize uint64, info uint32) error {
+	// The chunk information is stored in the low 16 bits of i_u, see
+	// struct erofs_inode_chunk_info.
+	i.chunkFormat = uint16(info)
+	if i.chunkFormat&^ChunkFormatAll != 0 {
+		log.Warningf("Unsupported chunk format 0x%x at inode (nid=%v)", i.chunkFormat, i.nid)
+		return linuxerr.ENOTSUP
+	}
+	i.chunkBits = i.image.sb.BlockSizeBits + uint8(i.chunkFormat&ChunkFormatBlockBitsMask)
+	if i.chunkBits >= 64 {
+		log.Warningf("Invalid chunk size at inode (nid=%v)", i.nid)
+		return linuxerr.EUCLEAN
+	}
+	unit := uint64(BlockMapEntrySize)
+	if i.chunkFormat&ChunkFormatIndexes != 0 {
+		unit = ChunkIndexSize
+	}
+	i.dataOff = alignUp(off+inodeSize, unit)
+	return nil
+}
+
+// mapChunk returns the extent of the chunk containing offset off. This
+// matches Linux's fs/erofs/data.c:erofs_map_blocks().
+func (i *Inode) mapChunk(off uint64) (Extent, error) {
+	chunkNr := off >> i.chunkBits
+	ext := Extent{
+		LogicalOff: chunkNr << i.chunkBits,
+	}
+	ext.LogicalLen = min(uint64(1)<<i.chunkBits, i.size-ext.LogicalOff)
+
+	var blockAddr uint32
+	if i.chunkFormat&ChunkFormatIndexes != 0 {
+		var idx ChunkIndex
+		if err := i.image.unmarshalAt(&idx, i.dataOff+chunkNr*ChunkIndexSize); err != nil {
+			return Extent{}, err
+		}
+		// Extra devices are not supported, so idx.DeviceID is ignored as
+		// in Linux's fs/erofs/data.c:erofs_map_blocks() when the image has
+		// no device table.
+		blockAddr = idx.BlockAddr
+	} else {
+		bytes, err := i.image.BytesAt(i.dataOff+chunkNr*BlockMapEntrySize, BlockMapEntrySize)
+		if err != nil {
+			return Extent{}, err
+		}
+		blockAddr = binary.LittleEndian.Uint32(bytes)
+	}
+	if blockAddr == NullAddr {
+		// This is a hole.
+		return ext, nil
+	}
+	ext.Flags = ExtentMapped
+	ext.PhysicalOff = i.image.sb.BlockAddrToOffset(blockAddr)
+	ext.PhysicalLen = alignUp(ext.LogicalLen, uint64(i.image.BlockSize()))
+	return ext, nil
+}
+
+// initCompression initializes the compression configurations of this image.
+// This matches Linux's fs/erofs/decompressor.c:z_erofs_parse_cfgs().
+func (i *Image) initCompression() error {
+	if i.sb.FeatureIncompat&FeatureIncompatComprCfgs == 0 {
+		// Union1 holds lz4_max_distance in this case, which we don't need.
+		i.comprAlgs = 1 << CompressionLZ4
+		return nil
+	}
+
+	i.comprAlgs = i.sb.Union1
+	if unsupported := i.comprAlgs &^ supportedCompressionAlgs; unsupported != 0 {
+		return fmt.Errorf("unsupported compression algorithms: 0x%x", unsupported)
+	}
+
+	off := SuperBlockOffset + i.sb.Size()
+	for alg := uint8(0); alg < CompressionMax; alg++ {
+		if i.comprAlgs&(1<<alg) == 0 {
+			continue
+		}
+		// Each configuration is a 4-byte aligned, length-prefixed record,
+		// see Linux's fs/erofs/super.c:erofs_read_metadata().
+		off = alignUp(off, 4)
+		lenBytes, err := i.BytesAt(off, 2)
+		if err != nil {
+			return fmt.Errorf("invalid compression configurations")
+		}
+		size := uint64(binary.LittleEndian.Uint16(lenBytes))
+		if size == 0 {
+			size = 1 << 16
+		}
+		off += 2
+		cfg, err := i.BytesAt(off, size)
+		if err != nil {
+			return fmt.Errorf("invalid compression configurations")
+		}
+		off += size
+
+		switch alg {
+		case CompressionLZ4:
+			// The LZ4 configurations only carry hints for the compressor.
+		case CompressionLZMA:
+			if size < lzmaConfigSize {
+				return fmt.Errorf("invalid MicroLZMA configurations")
+			}
+			if format := binary.LittleEndian.Uint16(cfg[4:]); format != 0 {
+				return fmt.Errorf("unsupported MicroLZMA format: %d", format)
+			}
+			i.lzmaDictSize = binary.LittleEndian.Uint32(cfg)
+			if i.lzmaDictSize > lzmaMaxDictSize {
+				return fmt.Errorf("unsupported MicroLZMA dictionary size: 0x%x", i.lzmaDictSize)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,579 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs
+
+import (
+	"encoding/binary"
+
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// Compression algorithms.
+const (
+	CompressionLZ4 = iota
+	CompressionLZMA
+	CompressionDeflate
+	CompressionZstd
+	CompressionMax
+
+	// CompressionShifted and CompressionInterlaced are not on-disk
+	// algorithms. They are used for uncompressed physical clusters stored
+	// in compressed inodes.
+	CompressionShifted       = CompressionMax
+	CompressionInterlaced    = CompressionMax + 1
+	supportedCompressionAlgs = 1<<CompressionLZ4 | 1<<CompressionLZMA
+)
+
+// Bit definitions for MapHeader::Advise.
+const (
+	AdviseCompacted2B         = 0x0001
+	AdviseBigPcluster1        = 0x0002
+	AdviseBigPcluster2        = 0x0004
+	AdviseInlinePcluster      = 0x0008
+	AdviseInterlacedPcluster  = 0x0010
+	AdviseFragmentPcluster    = 0x0020
+	mapHeaderFragmentInodeBit = 7
+)
+
+// Logical cluster types.
+const (
+	LclusterTypePlain   = 0
+	LclusterTypeHead1   = 1
+	LclusterTypeNonHead = 2
+	LclusterTypeHead2   = 3
+)
+
+// Bit definitions for LclusterIndex.
+const (
+	lclusterTypeMask  = 0x3
+	lclusterD0CblkCnt = 1 << 11
+)
+
+// MapHeader represents on-disk map header of compressed inodes.
+//
+// +marshal
+type MapHeader struct {
+	Reserved      uint16
+	IdataSize     uint16
+	Advise        uint16
+	AlgorithmType uint8
+	ClusterBits   uint8
+}
+
+// LclusterIndex represents on-disk full (non-compacted) logical cluster
+// index of compressed inodes.
+//
+// +marshal
+type LclusterIndex struct {
+	Advise     uint16
+	ClusterOfs uint16
+	// BlockAddr holds the physical block address for head logical clusters,
+	// or two 16-bit deltas for non-head logical clusters.
+	BlockAddr uint32
+}
+
+// zInfo contains the information of a compressed inode.
+//
+// +stateify savable
+type zInfo struct {
+	// advise is a combination of Advise* flags.
+	advise uint16
+
+	// algorithms are the compression algorithms for HEAD1 and HEAD2
+	// logical clusters.
+	algorithms [2]uint8
+
+	// lclusterBits is the logical cluster size in bit shift.
+	lclusterBits uint8
+
+	// idataOff and idataSize describe the inline (tail-packing) physical
+	// cluster if advise contains AdviseInlinePcluster.
+	idataOff  uint64
+	idataSize uint16
+
+	// tailHeadLcn is the head logical cluster number of the tail extent.
+	tailHeadLcn uint64
+}
+
+// initCompressed initializes the compression information of compressed
+// inode i, whose on-disk inode of size inodeSize is located at off. This
+// matches Linux's fs/erofs/zmap.c:z_erofs_fill_inode_lazy().
+func (i *Inode) initCompressed(off, inodeSize uint64) error {
+	sb := &i.image.sb
+	// The lcluster indexes are located right after the map header, so the
+	// map header offset is saved in dataOff.
+	i.dataOff = alignUp(off+inodeSize, 8)
+	var h MapHeader
+	if err := i.image.unmarshalAt(&h, i.dataOff); err != nil {
+		return err
+	}
+	if h.ClusterBits>>mapHeaderFragmentInodeBit != 0 {
+		log.Warningf("Unsupported fragment at compressed inode (nid=%v)", i.nid)
+		return linuxerr.ENOTSUP
+	}
+	i.z.advise = h.Advise
+	i.z.algorithms[0] = h.AlgorithmType & 0xf
+	i.z.algorithms[1] = h.AlgorithmType >> 4
+	for _, alg := range i.z.algorithms {
+		if alg >= CompressionMax {
+			log.Warningf("Unknown compression algorithm %d at inode (nid=%v)", alg, i.nid)
+			return linuxerr.ENOTSUP
+		}
+	}
+	if i.z.advise&AdviseFragmentPcluster != 0 {
+		log.Warningf("Unsupported fragment at compressed inode (nid=%v)", i.nid)
+		return linuxerr.ENOTSUP
+	}
+	// Logical clusters larger than a block are only produced by legacy
+	// mkfs.erofs versions; they aren't supported here.
+	if h.ClusterBits&7 != 0 {
+		log.Warningf("Unsupported logical cluster size at inode (nid=%v)", i.nid)
+		return linuxerr.ENOTSUP
+	}
+	i.z.lclusterBits = sb.BlockSizeBits
+	if sb.FeatureIncompat&FeatureIncompatBigPcluster == 0 &&
+		i.z.advise&(AdviseBigPcluster1|AdviseBigPcluster2) != 0 {
+		log.Warningf("Big pcluster without superblock feature at inode (nid=%v)", i.nid)
+		return linuxerr.EUCLEAN
+	}
+	if i.DataLayout() == InodeDataLayoutFlatCompression &&
+		(i.z.advise&AdviseBigPcluster1 == 0) != (i.z.advise&AdviseBigPcluster2 == 0) {
+		log.Warningf("Inconsistent big pcluster of compact indexes at inode (nid=%v)", i.nid)
+		return linuxerr.EUCLEAN
+	}
+
+	if i.z.advise&AdviseInlinePcluster != 0 && i.size > 0 {
+		i.z.idataSize = h.IdataSize
+		ext, err := i.zMapBlocks(i.size-1, true /* findTail */)
+		if err != nil {
+			return err
+		}
+		blockSize := uint64(i.image.BlockSize())
+		if ext.PhysicalLen == 0 || ext.PhysicalOff&(blockSize-1)+ext.PhysicalLen > blockSize {
+			log.Warningf("Invalid tail-packing pcluster size %d at inode (nid=%v)", ext.PhysicalLen, i.nid)
+			return linuxerr.EUCLEAN
+		}
+	}
+	return nil
+}
+
+// zMapRecorder records the state of a logical cluster lookup.
+type zMapRecorder struct {
+	inode *Inode
+
+	lcn            uint64
+	typ            uint8
+	headType       uint8
+	clusterOfs     uint32
+	delta          [2]uint32
+	pblk           uint32
+	compressedBlks uint32
+	nextPackOff    uint64
+
+	// la is the logical offset of the extent being mapped.
+	la uint64
+}
+
+// loadFullLcluster loads the full logical cluster index for lcn. This matches
+// Linux's fs/erofs/zmap.c:z_erofs_load_full_lcluster().
+func (m *zMapRecorder) loadFullLcluster(lcn uint64) error {
+	i := m.inode
+	// There is an 8-byte reserved area between the map header and the
+	// full indexes.
+	pos := i.dataOff + MapHeaderSize + 8 + lcn*LclusterIndexSize
+	var di LclusterIndex
+	if err := i.image.unmarshalAt(&di, pos); err != nil {
+		return err
+	}
+	m.lcn = lcn
+	m.nextPackOff = pos + LclusterIndexSize
+	m.typ = uint8(di.Advise & lclusterTypeMask)
+	if m.typ == LclusterTypeNonHead {
+		m.clusterOfs = 1 << i.z.lclusterBits
+		m.delta[0] = di.BlockAddr & 0xffff
+		if m.delta[0]&lclusterD0CblkCnt != 0 {
+			if i.z.advise&(AdviseBigPcluster1|AdviseBigPcluster2) == 0 {
+				return linuxerr.EUCLEAN
+			}
+			m.compressedBlks = m.delta[0] &^ lclusterD0CblkCnt
+			m.delta[0] = 1
+		}
+		m.delta[1] = di.BlockAddr >> 16
+		return nil
+	}
+	m.clusterOfs = uint32(di.ClusterOfs)
+	if m.clusterOfs >= 1<<i.z.lclusterBits {
+		return linuxerr.EUCLEAN
+	}
+	m.pblk = di.BlockAddr
+	return nil
+}
+
+// decodeCompactedBits decodes the compacted index at bit position pos in in.
+func decodeCompactedBits(loBits uint32, in []byte, pos uint32) (uint32, uint8) {
+	var buf [4]byte
+	copy(buf[:], in[pos/8:])
+	v := binary.LittleEndian.Uint32(buf[:]) >> (pos & 7)
+	lo := v & ((1 << loBits) - 1)
+	return lo, uint8((v >> loBits) & 3)
+}
+
+// getCompactedLookaheadDistance matches Linux's
+// fs/erofs/zmap.c:get_compacted_la_distance().
+func getCompactedLookaheadDistance(loBits, encodeBits, vcnt uint32, in []byte, i uint32) uint32 {
+	var lo, d1 uint32
+	for ; i < vcnt; i++ {
+		var typ uint8
+		lo, typ = decodeCompactedBits(loBits, in, encodeBits*i)
+		if typ != LclusterTypeNonHead {
+			return d1
+		}
+		d1++
+	}
+	// The last item of the pack is a non-head logical cluster.
+	if lo&lclusterD0CblkCnt == 0 {
+		d1 += lo - 1
+	}
+	return d1
+}
+
+// unpackCompactedIndex unpacks the compacted index at pos. This matches
+// Linux's fs/erofs/zmap.c:unpack_compacted_index().
+func (m *zMapRecorder) unpackCompactedIndex(amortizedShift uint32, pos uint64, lookahead bool) error {
+	i := m.inode
+	lclusterBits := uint32(i.z.lclusterBits)
+	var vcnt uint32
+	switch {
+	case amortizedShift == 2 && lclusterBits <= 14:
+		vcnt = 2
+	case amortizedShift == 1 && lclusterBits <= 12:
+		vcnt = 16
+	default:
+		return linuxerr.ENOTSUP
+	}
+
+	packSize := uint64(vcnt << amortizedShift)
+	packOff := pos &^ (packSize - 1)
+	in, err := i.image.BytesAt(packOff, packSize)
+	if err != nil {
+		return err
+	}
+	m.nextPackOff = packOff + packSize
+	bigPcluster := i.z.advise&AdviseBigPcluster1 != 0
+	loBits := max(lclusterBits, 12)
+	encodeBits := uint32((packSize-4)*8) / vcnt
+	idx := int(uint32(pos-packOff) >> amortizedShift)
+
+	lo, typ := decodeCompactedBits(loBits, in, encodeBits*uint32(idx))
+	m.typ = typ
+	if typ == LclusterTypeNonHead {
+		m.clusterOfs = 1 << lclusterBits
+		if lookahead {
+			m.delta[1] = getCompactedLookaheadDistance(loBits, encodeBits, vcnt, in, uint32(idx))
+		}
+		if lo&lclusterD0CblkCnt != 0 {
+			if !bigPcluster {
+				return linuxerr.EUCLEAN
+			}
+			m.compressedBlks = lo &^ lclusterD0CblkCnt
+			m.delta[0] = 1
+			return nil
+		} else if uint32(idx)+1 != vcnt {
+			m.delta[0] = lo
+			return nil
+		}
+		// The last logical cluster in the pack is special, since lo saves
+		// delta[1] rather than delta[0]. Hence, get delta[0] from the
+		// previous logical cluster indirectly.
+		lo, typ = decodeCompactedBits(loBits, in, encodeBits*uint32(idx-1))
+		if typ != LclusterTypeNonHead {
+			lo = 0
+		} else if lo&lclusterD0CblkCnt != 0 {
+			lo = 1
+		}
+		m.delta[0] = lo + 1
+		return nil
+	}
+	m.clusterOfs = lo
+	m.delta[0] = 0
+	// Figure out the block address of head logical clusters.
+	var nblk uint32
+	if !bigPcluster {
+		nblk = 1
+		for idx > 0 {
+			idx--
+			lo, typ = decodeCompactedBits(loBits, in, encodeBits*uint32(idx))
+			if typ == LclusterTypeNonHead {
+				idx -= int(lo)
+			}
+			if idx >= 0 {
+				nblk++
+			}
+		}
+	} else {
+		for idx > 0 {
+			idx--
+			lo, typ = decodeCompactedBits(loBits, in, encodeBits*uint32(idx))
+			if typ == LclusterTypeNonHead {
+				if lo&lclusterD0CblkCnt != 0 {
+					idx--
+					nblk += lo &^ lclusterD0CblkCnt
+					continue
+				}
+				// A big pcluster shouldn't have plain d0 == 1.
+				if lo <= 1 {
+					return linuxerr.EUCLEAN
+				}
+				idx -= int(lo) - 2
+				continue
+			}
+			nblk++
+		}
+	}
+	m.pblk = binary.LittleEndian.Uint32(in[packSize-4:]) + nblk
+	return nil
+}
+
+// loadCompactLcluster loads the compacted logical cluster index for lcn. This
+// matches Linux's fs/erofs/zmap.c:z_erofs_load_compact_lcluster().
+func (m *zMapRecorder) loadCompactLcluster(lcn uint64, lookahead bool) error {
+	i := m.inode
+	ebase := i.dataOff + MapHeaderSize
+	totalIdx := i.blocks
+	if lcn >= totalIdx {
+		return linuxerr.EINVAL
+	}
+	m.lcn = lcn
+
+	// The compacted 2B indexes are aligned to 32 bytes, and the gap is
+	// filled with compacted 4B indexes.
+	compacted4BInitial := ((32 - ebase%32) / 4) & 7
+	var compacted2B uint64
+	if i.z.advise&AdviseCompacted2B != 0 && compacted4BInitial < totalIdx {
+		compacted2B = (totalIdx - compacted4BInitial) &^ 15
+	}
+
+	pos := ebase
+	amortizedShift := uint32(2)
+	if lcn >= compacted4BInitial {
+		pos += compacted4BInitial * 4
+		lcn -= compacted4BInitial
+		if lcn < compacted2B {
+			amortizedShift = 1
+		} else {
+			pos += compacted2B * 2
+			lcn -= compacted2B
+		}
+	}
+	pos += lcn << amortizedShift
+	return m.unpackCompactedIndex(amortizedShift, pos, lookahead)
+}
+
+// loadLcluster loads the logical cluster index for lcn.
+func (m *zMapRecorder) loadLcluster(lcn uint64, lookahead bool) error {
+	if m.inode.DataLayout() == InodeDataLayoutFlatCompressionLegacy {
+		return m.loadFullLcluster(lcn)
+	}
+	return m.loadCompactLcluster(lcn, lookahead)
+}
+
+// extentLookback finds the head logical cluster of the current extent. This
+// matches Linux's fs/erofs/zmap.c:z_erofs_extent_lookback().
+func (m *zMapRecorder) extentLookback(lookbackDistance uint32) error {
+	for m.lcn >= uint64(lookbackDistance) {
+		lcn := m.lcn - uint64(lookbackDistance)
+		if err := m.loadLcluster(lcn, false); err != nil {
+			return err
+		}
+		switch m.typ {
+		case LclusterTypeNonHead:
+			lookbackDistance = m.delta[0]
+			if lookbackDistance == 0 {
+				return linuxerr.EUCLEAN
+			}
+		case LclusterTypePlain, LclusterTypeHead1, LclusterTypeHead2:
+			m.headType = m.typ
+			m.la = lcn<<m.inode.z.lclusterBits | uint64(m.clusterOfs)
+			return nil
+		default:
+			return linuxerr.ENOTSUP
+		}
+	}
+	return linuxerr.EUCLEAN
+}
+
+// extentCompressedLen returns the physical length of the current extent.
+// This matches Linux's fs/erofs/zmap.c:z_erofs_get_extent_compressedlen().
+func (m *zMapRecorder) extentCompressedLen() (uint64, error) {
+	i := m.inode
+	lclusterBits := i.z.lclusterBits
+	if m.headType == LclusterTypePlain ||
+		(m.headType == LclusterTypeHead1 && i.z.advise&AdviseBigPcluster1 == 0) ||
+		(m.headType == LclusterTypeHead2 && i.z.advise&AdviseBigPcluster2 == 0) {
+		return 1 << lclusterBits, nil
+	}
+	if m.compressedBlks == 0 {
+		if err := m.loadLcluster(m.lcn+1, false); err != nil {
+			return 0, err
+		}
+		switch m.typ {
+		case LclusterTypePlain, LclusterTypeHead1, LclusterTypeHead2:
+			// If the first non-head logical cluster is actually a head
+			// one, the physical cluster is a single block.
+			m.compressedBlks = 1
+		case LclusterTypeNonHead:
+			if m.delta[0] != 1 || m.compressedBlks == 0 {
+				log.Warningf("Invalid CBLKCNT at lcn %d of inode (nid=%v)", m.lcn, i.nid)
+				return 0, linuxerr.EUCLEAN
+			}
+		default:
+			return 0, linuxerr.EUCLEAN
+		}
+	}
+	return uint64(m.compressedBlks) << i.image.sb.BlockSizeBits, nil
+}
+
+// extentDecompressedLen returns the full logical length of the current
+// extent. This matches Linux's
+// fs/erofs/zmap.c:z_erofs_get_extent_decompressedlen().
+func (m *zMapRecorder) extentDecompressedLen() (uint64, error) {
+	i := m.inode
+	lclusterBits := i.z.lclusterBits
+	lcn := m.lcn
+	headLcn := m.la >> lclusterBits
+	for {
+		// Handle the last EOF physical cluster (no next head).
+		if lcn<<lclusterBits >= i.size {
+			return i.size - m.la, nil
+		}
+		if err := m.loadLcluster(lcn, true); err != nil {
+			return 0, err
+		}
+		switch m.typ {
+		case LclusterTypeNonHead:
+		case LclusterTypePlain, LclusterTypeHead1, LclusterTypeHead2:
+			// Go on until the next head logical cluster.
+			if lcn != headLcn {
+				return lcn<<lclusterBits + uint64(m.clusterOfs) - m.la, nil
+			}
+			m.delta[1] = 1
+		default:
+			return 0, linuxerr.ENOTSUP
+		}
+		if m.delta[1] == 0 {
+			return lcn<<lclusterBits + uint64(m.clusterOfs) - m.la, nil
+		}
+		lcn += uint64(m.delta[1])
+	}
+}
+
+// mapCompressed returns the extent of compressed inode i that contains
+// offset off.
+func (i *Inode) mapCompressed(off uint64) (Extent, error) {
+	return i.zMapBlocks(off, false /* findTail */)
+}
+
+// zMapBlocks returns the extent of compressed inode i that contains offset
+// off. Unlike Linux, the returned extent always covers the full decompressed
+// range of the physical cluster, so it can be decompressed at once. If
+// findTail is true, the tail extent information of i is initialized. This
+// matches Linux's fs/erofs/zmap.c:z_erofs_do_map_blocks().
+func (i *Inode) zMapBlocks(off uint64, findTail bool) (Extent, error) {
+	lclusterBits := i.z.lclusterBits
+	inlinePcluster := i.z.advise&AdviseInlinePcluster != 0
+	m := zMapRecorder{inode: i}
+	initialLcn := off >> lclusterBits
+	endOff := uint32(off & ((1 << lclusterBits) - 1))
+	if err := m.loadLcluster(initialLcn, false); err != nil {
+		return Extent{}, err
+	}
+	if inlinePcluster && findTail {
+		i.z.idataOff = m.nextPackOff
+	}
+
+	switch m.typ {
+	case LclusterTypePlain, LclusterTypeHead1, LclusterTypeHead2:
+		if endOff >= m.clusterOfs {
+			m.headType = m.typ
+			m.la = m.lcn<<lclusterBits | uint64(m.clusterOfs)
+			break
+		}
+		// m.lcn should be >= 1 if endOff < m.clusterOfs.
+		if m.lcn == 0 {
+			log.Warningf("Invalid logical cluster 0 at inode (nid=%v)", i.nid)
+			return Extent{}, linuxerr.EUCLEAN
+		}
+		m.delta[0] = 1
+		fallthrough
+	case LclusterTypeNonHead:
+		// Get the corresponding first logical cluster.
+		if err := m.extentLookback(m.delta[0]); err != nil {
+			return Extent{}, err
+		}
+	default:
+		return Extent{}, linuxerr.ENOTSUP
+	}
+
+	ext := Extent{
+		LogicalOff: m.la,
+		Flags:      ExtentMapped | ExtentEncoded,
+	}
+	if findTail {
+		i.z.tailHeadLcn = m.lcn
+	}
+	if inlinePcluster && m.lcn == i.z.tailHeadLcn {
+		ext.Flags |= ExtentMeta
+		ext.PhysicalOff = i.z.idataOff
+		ext.PhysicalLen = uint64(i.z.idataSize)
+	} else {
+		ext.PhysicalOff = i.image.sb.BlockAddrToOffset(m.pblk)
+		plen, err := m.extentCompressedLen()
+		if err != nil {
+			return Extent{}, err
+		}
+		ext.PhysicalLen = plen
+	}
+
+	llen, err := m.extentDecompressedLen()
+	if err != nil {
+		return Extent{}, err
+	}
+	ext.LogicalLen = llen
+	if ext.LogicalLen == 0 || ext.LogicalOff+ext.LogicalLen <= off {
+		log.Warningf("Invalid extent [0x%x, 0x%x) for offset 0x%x at inode (nid=%v)", ext.LogicalOff, ext.LogicalOff+ext.LogicalLen, off, i.nid)
+		return Extent{}, linuxerr.EUCLEAN
+	}
+
+	if m.headType == LclusterTypePlain {
+		if ext.LogicalLen > ext.PhysicalLen {
+			return Extent{}, linuxerr.EUCLEAN
+		}
+		ext.Algorithm = CompressionShifted
+		if i.z.advise&AdviseInterlacedPcluster != 0 {
+			ext.Algorithm = CompressionInterlaced
+		}
+	} else {
+		ext.Algorithm = i.z.algorithms[0]
+		if m.headType == LclusterTypeHead2 {
+			ext.Algorithm = i.z.algorithms[1]
+		}
+		if i.image.comprAlgs&(1<<ext.Algorithm) == 0 {
+			log.Warningf("Inconsistent compression algorithm %d at inode (nid=%v)", ext.Algorithm, i.nid)
+			return Extent{}, linuxerr.EUCLEAN
+		}
+	}
+	return ext, nil
+}
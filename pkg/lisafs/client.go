@@ -311,6 +311,34 @@ func (c *Client) SyncFDs(ctx context.Context, fds []FDID) error {
 	return err
 }
 
+// WatchInit makes the WatchInit RPC. On success, it returns a non-blocking
+// host inotify FD from which change notifications for files watched via
+// ClientFD.AddWatch can be read. The caller owns the returned FD.
+func (c *Client) WatchInit(ctx context.Context) (int, error) {
+	var (
+		req      WatchInitReq
+		resp     WatchInitResp
+		notifyFD [1]int
+	)
+	ctx.UninterruptibleSleepStart(false)
+	err := c.SndRcvMessage(WatchInit, uint32(req.SizeBytes()), req.MarshalBytes, resp.CheckedUnmarshal, notifyFD[:], req.String, resp.String)
+	ctx.UninterruptibleSleepFinish(false)
+	if err == nil && notifyFD[0] < 0 {
+		err = unix.EBADF
+	}
+	return notifyFD[0], err
+}
+
+// RemoveWatch makes the RemoveWatch RPC.
+func (c *Client) RemoveWatch(ctx context.Context, wd int32) error {
+	req := RemoveWatchReq{WD: wd}
+	var resp RemoveWatchResp
+	ctx.UninterruptibleSleepStart(false)
+	err := c.SndRcvMessage(RemoveWatch, uint32(req.SizeBytes()), req.MarshalUnsafe, resp.CheckedUnmarshal, nil, req.String, resp.String)
+	ctx.UninterruptibleSleepFinish(false)
+	return err
+}
+
 // SndRcvMessage invokes reqMarshal to marshal the request onto the payload
 // buffer, wakes up the server to process the request, waits for the response
 // and invokes respUnmarshal with the response payload. respFDs is populated
@@ -600,6 +600,20 @@ func (f *ClientFD) RemoveXattr(ctx context.Context, name string) error {
 	return err
 }
 
+// AddWatch makes the AddWatch RPC. Events for this file are delivered on the
+// inotify FD returned by Client.WatchInit with the returned watch descriptor.
+func (f *ClientFD) AddWatch(ctx context.Context, mask uint32) (int32, error) {
+	req := AddWatchReq{
+		FD:   f.fd,
+		Mask: mask,
+	}
+	var resp AddWatchResp
+	ctx.UninterruptibleSleepStart(false)
+	err := f.client.SndRcvMessage(AddWatch, uint32(req.SizeBytes()), req.MarshalUnsafe, resp.CheckedUnmarshal, nil, req.String, resp.String)
+	ctx.UninterruptibleSleepFinish(false)
+	return resp.WD, err
+}
+
 // ClientBoundSocketFD corresponds to a bound socket on the server. It
 // implements transport.BoundSocketFD.
 //
@@ -80,6 +80,13 @@ type Connection struct {
 	fds map[FDID]genericFD
 	// nextFDID is the next available FDID. It is protected by fdsMu.
 	nextFDID FDID
+
+	// inotifyMu protects inotify.
+	inotifyMu sync.Mutex
+	// inotify is the host inotify instance used to watch files on behalf of
+	// the client. It is created lazily by the WatchInit RPC and is -1 until
+	// then.
+	inotify int
 }
 
 // CreateConnection initializes a new connection which will be mounted at
@@ -100,6 +107,7 @@ func (s *Server) CreateConnection(sock *unet.Socket, mountPath string, readonly
 		channels:       make([]*channel, 0, maxChannels()),
 		fds:            make(map[FDID]genericFD),
 		nextFDID:       InvalidFDID + 1,
+		inotify:        -1,
 	}
 
 	alloc, err := flipcall.NewPacketWindowAllocator()
@@ -242,6 +250,14 @@ func (c *Connection) close() {
 	// Ensure the connection is closed.
 	c.sockComm.destroy()
 
+	// Close the inotify instance. This drops all host watches.
+	c.inotifyMu.Lock()
+	if c.inotify >= 0 {
+		unix.Close(c.inotify)
+		c.inotify = -1
+	}
+	c.inotifyMu.Unlock()
+
 	// Cleanup all FDs.
 	c.fdsMu.Lock()
 	defer c.fdsMu.Unlock()
@@ -251,6 +267,20 @@ func (c *Connection) close() {
 	}
 }
 
+// inotifyFD returns the connection's inotify instance, creating it if needed.
+func (c *Connection) inotifyFD() (int, error) {
+	c.inotifyMu.Lock()
+	defer c.inotifyMu.Unlock()
+	if c.inotify < 0 {
+		fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+		if err != nil {
+			return -1, err
+		}
+		c.inotify = fd
+	}
+	return c.inotify, nil
+}
+
 // Postcondition: The caller gains a ref on the FD on success.
 func (c *Connection) lookupFD(id FDID) (genericFD, error) {
 	c.fdsMu.RLock()
@@ -501,6 +501,13 @@ type ControlFDImpl interface {
 	// On the server, BindAt has a write concurrency guarantee.
 	BindAt(name string, sockType uint32, mode linux.FileMode, uid UID, gid GID) (*ControlFD, linux.Statx, *BoundSocketFD, int, error)
 
+	// AddWatch adds a watch for this file to the host inotify instance
+	// inotifyFD, with the given inotify(7) event mask. It returns the watch
+	// descriptor, which identifies events for this file read from inotifyFD.
+	//
+	// On the server, AddWatch has a read concurrency guarantee.
+	AddWatch(inotifyFD int, mask uint32) (int32, error)
+
 	// UnlinkAt the file identified by name in this directory.
 	//
 	// Flags are Linux unlinkat(2) flags.
@@ -81,6 +81,9 @@ var handlers = [...]RPCHandler{
 	Listen:           ListenHandler,
 	Accept:           AcceptHandler,
 	ConnectWithCreds: ConnectWithCredsHandler,
+	WatchInit:        WatchInitHandler,
+	AddWatch:         AddWatchHandler,
+	RemoveWatch:      RemoveWatchHandler,
 }
 
 // ErrorHandler handles Error message.
@@ -1524,6 +1527,79 @@ func FRemoveXattrHandler(c *Connection, comm Communicator, payloadLen uint32) (u
 	})
 }
 
+// WatchInitHandler handles the WatchInit RPC.
+func WatchInitHandler(c *Connection, comm Communicator, payloadLen uint32) (uint32, error) {
+	ifd, err := c.inotifyFD()
+	if err != nil {
+		return 0, err
+	}
+	// Donate a duplicate so that the connection retains ownership of the
+	// inotify instance, which it needs for subsequent AddWatch RPCs.
+	dupFD, err := unix.FcntlInt(uintptr(ifd), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	comm.DonateFD(dupFD)
+	return 0, nil
+}
+
+// AddWatchHandler handles the AddWatch RPC.
+func AddWatchHandler(c *Connection, comm Communicator, payloadLen uint32) (uint32, error) {
+	var req AddWatchReq
+	if _, ok := req.CheckedUnmarshal(comm.PayloadBuf(payloadLen)); !ok {
+		return 0, unix.EIO
+	}
+
+	c.inotifyMu.Lock()
+	ifd := c.inotify
+	c.inotifyMu.Unlock()
+	if ifd < 0 {
+		// WatchInit must be called first.
+		return 0, unix.EINVAL
+	}
+
+	fd, err := c.lookupControlFD(req.FD)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.DecRef(nil)
+
+	var wd int32
+	if err := fd.safelyRead(func() error {
+		if fd.node.isDeleted() {
+			return unix.ENOENT
+		}
+		wd, err = fd.impl.AddWatch(ifd, req.Mask)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	resp := AddWatchResp{WD: wd}
+	respLen := uint32(resp.SizeBytes())
+	resp.MarshalUnsafe(comm.PayloadBuf(respLen))
+	return respLen, nil
+}
+
+// RemoveWatchHandler handles the RemoveWatch RPC.
+func RemoveWatchHandler(c *Connection, comm Communicator, payloadLen uint32) (uint32, error) {
+	var req RemoveWatchReq
+	if _, ok := req.CheckedUnmarshal(comm.PayloadBuf(payloadLen)); !ok {
+		return 0, unix.EIO
+	}
+
+	c.inotifyMu.Lock()
+	ifd := c.inotify
+	c.inotifyMu.Unlock()
+	if ifd < 0 {
+		return 0, unix.EINVAL
+	}
+	if _, err := unix.InotifyRmWatch(ifd, uint32(req.WD)); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
 // checkSafeName validates the name and returns nil or returns an error.
 func checkSafeName(name string) error {
 	if name != "" && !strings.Contains(name, "/") && name != "." && name != ".." {
@@ -176,6 +176,19 @@ const (
 	// ConnectWithCreds is analogous to connect(2) but it asks the server
 	// to connect with the provided effective uid/gid.
 	ConnectWithCreds MID = 32
+
+	// WatchInit requests the server to create an inotify instance for this
+	// connection. The server donates the inotify FD to the client, which can
+	// read host change notifications from it.
+	WatchInit MID = 33
+
+	// AddWatch is analogous to inotify_add_watch(2) on the connection's
+	// inotify instance.
+	AddWatch MID = 34
+
+	// RemoveWatch is analogous to inotify_rm_watch(2) on the connection's
+	// inotify instance.
+	RemoveWatch MID = 35
 )
 
 const (
@@ -1871,3 +1884,68 @@ func (l *FListXattrResp) MarshalBytes(dst []byte) []byte {
 func (l *FListXattrResp) CheckedUnmarshal(src []byte) ([]byte, bool) {
 	return l.Xattrs.CheckedUnmarshal(src)
 }
+
+// WatchInitReq is used to make WatchInit requests.
+type WatchInitReq struct{ EmptyMessage }
+
+// String implements fmt.Stringer.String.
+func (*WatchInitReq) String() string {
+	return "WatchInitReq{}"
+}
+
+// WatchInitResp is an empty response to WatchInitReq. The inotify FD is
+// donated along with the response.
+type WatchInitResp struct{ EmptyMessage }
+
+// String implements fmt.Stringer.String.
+func (*WatchInitResp) String() string {
+	return "WatchInitResp{}"
+}
+
+// AddWatchReq is used to make AddWatch requests.
+//
+// +marshal boundCheck
+type AddWatchReq struct {
+	FD   FDID
+	Mask uint32
+	_    uint32
+}
+
+// String implements fmt.Stringer.String.
+func (a *AddWatchReq) String() string {
+	return fmt.Sprintf("AddWatchReq{FD: %d, Mask: %#x}", a.FD, a.Mask)
+}
+
+// AddWatchResp is used to respond to AddWatch requests.
+//
+// +marshal boundCheck
+type AddWatchResp struct {
+	WD int32
+	_  uint32
+}
+
+// String implements fmt.Stringer.String.
+func (a *AddWatchResp) String() string {
+	return fmt.Sprintf("AddWatchResp{WD: %d}", a.WD)
+}
+
+// RemoveWatchReq is used to make RemoveWatch requests.
+//
+// +marshal boundCheck
+type RemoveWatchReq struct {
+	WD int32
+	_  uint32
+}
+
+// String implements fmt.Stringer.String.
+func (r *RemoveWatchReq) String() string {
+	return fmt.Sprintf("RemoveWatchReq{WD: %d}", r.WD)
+}
+
+// RemoveWatchResp is an empty response to RemoveWatchReq.
+type RemoveWatchResp struct{ EmptyMessage }
+
+// String implements fmt.Stringer.String.
+func (*RemoveWatchResp) String() string {
+	return "RemoveWatchResp{}"
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/moby/sys/capability"
 	"golang.org/x/sys/unix"
@@ -80,6 +81,8 @@ var localFSTests = map[string]TestFunc{
 	"Mknod":           testMknod,
 	"UDS":             testUDS,
 	"Getdents":        testGetdents,
+	"Watch":           testWatch,
+	"WatchErrors":     testWatchErrors,
 }
 
 // RunTest runs the passed test function as a subtest.
@@ -706,3 +709,75 @@ func testGetdents(ctx context.Context, t *testing.T, tester Tester, root lisafs.
 		}
 	}
 }
+
+func testWatch(ctx context.Context, t *testing.T, tester Tester, root lisafs.ClientFD) {
+	tempDir, _ := mkdir(ctx, t, root, "tempDir")
+	defer closeFD(ctx, t, tempDir)
+	defer unlinkFile(ctx, t, root, "tempDir", true /* isDir */)
+
+	inotifyFD, err := root.Client().WatchInit(ctx)
+	if err != nil {
+		t.Fatalf("WatchInit failed: %v", err)
+	}
+	defer unix.Close(inotifyFD)
+
+	wd, err := tempDir.AddWatch(ctx, linux.IN_CREATE)
+	if err != nil {
+		t.Fatalf("AddWatch failed: %v", err)
+	}
+
+	newFile, _ := mknod(ctx, t, tempDir, "file")
+	defer closeFD(ctx, t, newFile)
+	defer unlinkFile(ctx, t, tempDir, "file", false /* isDir */)
+
+	pfd := []unix.PollFd{{Fd: int32(inotifyFD), Events: unix.POLLIN}}
+	if n, err := unix.Poll(pfd, 5000 /* ms */); err != nil || n != 1 {
+		t.Fatalf("poll on inotify FD returned n=%d, err=%v", n, err)
+	}
+	buf := make([]byte, unix.SizeofInotifyEvent+unix.NAME_MAX+1)
+	n, err := unix.Read(inotifyFD, buf)
+	if err != nil {
+		t.Fatalf("reading inotify event failed: %v", err)
+	}
+	if n < unix.SizeofInotifyEvent {
+		t.Fatalf("short inotify event read: %d bytes", n)
+	}
+	event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+	name := string(bytes.TrimRight(buf[unix.SizeofInotifyEvent:unix.SizeofInotifyEvent+int(event.Len)], "\x00"))
+	if event.Wd != wd || event.Mask != linux.IN_CREATE || name != "file" {
+		t.Errorf("got event {wd: %d, mask: %#x, name: %q}, want {wd: %d, mask: %#x, name: %q}", event.Wd, event.Mask, name, wd, linux.IN_CREATE, "file")
+	}
+
+	if err := root.Client().RemoveWatch(ctx, wd); err != nil {
+		t.Errorf("RemoveWatch failed: %v", err)
+	}
+}
+
+func testWatchErrors(ctx context.Context, t *testing.T, tester Tester, root lisafs.ClientFD) {
+	// Watches are added to the inotify instance created by WatchInit.
+	if _, err := root.AddWatch(ctx, linux.IN_CREATE); err != unix.EINVAL {
+		t.Errorf("AddWatch before WatchInit: got %v, want %v", err, unix.EINVAL)
+	}
+	if err := root.Client().RemoveWatch(ctx, 1); err != unix.EINVAL {
+		t.Errorf("RemoveWatch before WatchInit: got %v, want %v", err, unix.EINVAL)
+	}
+
+	inotifyFD, err := root.Client().WatchInit(ctx)
+	if err != nil {
+		t.Fatalf("WatchInit failed: %v", err)
+	}
+	defer unix.Close(inotifyFD)
+
+	// The watch descriptor doesn't exist.
+	if err := root.Client().RemoveWatch(ctx, 1); err != unix.EINVAL {
+		t.Errorf("RemoveWatch of unknown watch: got %v, want %v", err, unix.EINVAL)
+	}
+
+	// Deleted files can't be watched.
+	file, _ := mknod(ctx, t, root, "file")
+	defer closeFD(ctx, t, file)
+	unlinkFile(ctx, t, root, "file", false /* isDir */)
+	if _, err := file.AddWatch(ctx, linux.IN_MODIFY); err != unix.ENOENT {
+		t.Errorf("AddWatch on deleted file: got %v, want %v", err, unix.ENOENT)
+	}
+}
@@ -316,6 +316,53 @@ func (s *State) GetMXCSR() uint32 {
 	return hostarch.ByteOrder.Uint32((*s)[mxcsrOffset:])
 }
 
+// NumPkeys returns the number of memory protection keys available to
+// applications with the given feature set.
+func NumPkeys(featureSet cpuid.FeatureSet) int {
+	if _, ok := featureSet.PKRUOffset(); !ok {
+		return 0
+	}
+	// PKRU contains 2 bits for each of 16 protection keys.
+	return 16
+}
+
+// pkruOffset returns the offset of the PKRU register in s, or false if s
+// cannot contain it.
+func (s *State) pkruOffset() (uint, bool) {
+	off, ok := cpuid.HostFeatureSet().PKRUOffset()
+	if !ok || len(*s) < minXstateBytes || off+4 > uint(len(s.Slice())) {
+		return 0, false
+	}
+	return off, true
+}
+
+// PKRU returns the value of the PKRU register in s.
+func (s *State) PKRU() uint32 {
+	off, ok := s.pkruOffset()
+	if !ok {
+		return 0
+	}
+	f := *s
+	if hostarch.ByteOrder.Uint64(f[xstateBVOffset:])&cpuid.XSAVEFeaturePKRU == 0 {
+		// PKRU is in its initial configuration.
+		return 0
+	}
+	return hostarch.ByteOrder.Uint32(f[off:])
+}
+
+// SetPKRU sets the value of the PKRU register in s. It has no effect if the
+// host does not support protection keys.
+func (s *State) SetPKRU(pkru uint32) {
+	off, ok := s.pkruOffset()
+	if !ok {
+		return
+	}
+	f := *s
+	hostarch.ByteOrder.PutUint32(f[off:], pkru)
+	xstateBV := hostarch.ByteOrder.Uint64(f[xstateBVOffset:])
+	hostarch.ByteOrder.PutUint64(f[xstateBVOffset:], xstateBV|cpuid.XSAVEFeaturePKRU)
+}
+
 // BytePointer returns a pointer to the first byte of the state.
 //
 //go:nosplit
@@ -17,6 +17,10 @@
 
 package fpu
 
+import (
+	"gvisor.dev/gvisor/pkg/cpuid"
+)
+
 const (
 	// fpsimdMagic is the magic number which is used in fpsimd_context.
 	fpsimdMagic = 0x46508001
@@ -57,6 +61,23 @@ func (s *State) Fork() State {
 	return n
 }
 
+// NumPkeys returns the number of memory protection keys available to
+// applications with the given feature set. Memory protection keys are not
+// supported on arm64.
+func NumPkeys(featureSet cpuid.FeatureSet) int {
+	return 0
+}
+
+// PKRU returns the value of the PKRU register in s. Since arm64 has no PKRU
+// register, it always returns 0.
+func (s *State) PKRU() uint32 {
+	return 0
+}
+
+// SetPKRU sets the value of the PKRU register in s. It has no effect on
+// arm64.
+func (s *State) SetPKRU(pkru uint32) {}
+
 // BytePointer returns a pointer to the first byte of the state.
 //
 //go:nosplit
@@ -295,12 +295,21 @@ func (proc *Proc) execAsync(args *ExecArgs) (*kernel.ThreadGroup, kernel.ThreadI
 	// Set cgroups to the new exec task if cgroups are mounted.
 	cgroupRegistry := proc.Kernel.CgroupRegistry()
 	initialCgrps := map[kernel.Cgroup]struct{}{}
-	for _, ctrl := range kernel.CgroupCtrls {
+	ctrls := kernel.CgroupCtrls
+	if cgroupRegistry.HasV2Hierarchy() {
+		ctrls = kernel.CgroupCtrlsV2
+	}
+	for _, ctrl := range ctrls {
 		cg, err := cgroupRegistry.FindCgroup(ctx, ctrl, "/"+args.ContainerID)
 		if err != nil {
 			log.Warningf("cgroup mount for controller %v not found", ctrl)
 			continue
 		}
+		if _, ok := initialCgrps[cg]; ok {
+			// All controllers share a single cgroup on the unified hierarchy.
+			cg.DecRef(ctx)
+			continue
+		}
 		initialCgrps[cg] = struct{}{}
 	}
 	if len(initialCgrps) > 0 {
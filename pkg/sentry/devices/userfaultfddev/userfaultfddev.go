@@ -0,0 +1,106 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package userfaultfddev implements the /dev/userfaultfd device.
+package userfaultfddev
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+// See include/linux/miscdevice.h.
+const userfaultfdDevMinor = 126
+
+// userfaultfdDevice implements vfs.Device for /dev/userfaultfd.
+//
+// +stateify savable
+type userfaultfdDevice struct{}
+
+// Open implements vfs.Device.Open.
+func (userfaultfdDevice) Open(ctx context.Context, mnt *vfs.Mount, vfsd *vfs.Dentry, opts vfs.OpenOptions) (*vfs.FileDescription, error) {
+	fd := &userfaultfdFD{}
+	if err := fd.vfsfd.Init(fd, opts.Flags, mnt, vfsd, &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+	}); err != nil {
+		return nil, err
+	}
+	return &fd.vfsfd, nil
+}
+
+// userfaultfdFD implements vfs.FileDescriptionImpl for /dev/userfaultfd.
+// Unlike userfaultfd(2), creating userfaultfds through /dev/userfaultfd
+// requires no capabilities; access is instead controlled by the device's file
+// permissions.
+//
+// +stateify savable
+type userfaultfdFD struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+}
+
+// Release implements vfs.FileDescriptionImpl.Release.
+func (fd *userfaultfdFD) Release(context.Context) {
+	// As in Linux, userfaultfds created by USERFAULTFD_IOC_NEW are
+	// independent of fd; each unregisters its ranges when it is released
+	// (see mm.UserfaultFD.Release).
+}
+
+// Ioctl implements vfs.FileDescriptionImpl.Ioctl.
+func (fd *userfaultfdFD) Ioctl(ctx context.Context, uio usermem.IO, sysno uintptr, args arch.SyscallArguments) (uintptr, error) {
+	t := kernel.TaskFromContext(ctx)
+	if t == nil {
+		panic("Ioctl should be called from a task context")
+	}
+
+	switch args[1].Uint() {
+	case linux.USERFAULTFD_IOC_NEW:
+		flags := args[2].Int()
+		if flags&^(linux.O_CLOEXEC|linux.O_NONBLOCK|linux.UFFD_USER_MODE_ONLY) != 0 {
+			return 0, linuxerr.EINVAL
+		}
+		file, err := mm.NewUserfaultFD(t, t.Kernel().VFS(), t.MemoryManager(), uint32(flags&linux.O_NONBLOCK), flags&linux.UFFD_USER_MODE_ONLY != 0)
+		if err != nil {
+			return 0, err
+		}
+		defer file.DecRef(t)
+		newFD, err := t.NewFDFrom(0, file, kernel.FDFlags{
+			CloseOnExec: flags&linux.O_CLOEXEC != 0,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return uintptr(newFD), nil
+
+	default:
+		// See fs/userfaultfd.c:userfaultfd_dev_ioctl().
+		return 0, linuxerr.EINVAL
+	}
+}
+
+// Register registers all devices implemented by this package in vfsObj.
+func Register(vfsObj *vfs.VirtualFilesystem) error {
+	return vfsObj.RegisterDevice(vfs.CharDevice, linux.MISC_MAJOR, userfaultfdDevMinor, userfaultfdDevice{}, &vfs.RegisterDeviceOptions{
+		Pathname:  "userfaultfd",
+		FilePerms: 0600,
+	})
+}
@@ -29,6 +29,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/usermem"
 )
@@ -167,6 +168,29 @@ type cgroupInode struct {
 	//
 	// ts, and cgroup membership in general is protected by fs.tasksMu.
 	ts map[*kernel.Task]struct{}
+
+	// parent is the parent cgroup, or nil for the root cgroup. Immutable.
+	parent *cgroupInode
+
+	// The following fields are only used on the unified hierarchy, see
+	// cgroup2.go. They're protected by fs.tasksMu.
+
+	// subtreeControl is the set of controllers enabled for children through
+	// cgroup.subtree_control.
+	subtreeControl map[kernel.CgroupControllerType]struct{}
+
+	// populatedChildren is the number of child cgroups which contain tasks,
+	// directly or through their descendants.
+	populatedChildren int
+
+	// events is the cgroup.events control file. Nil for the root cgroup.
+	// Immutable.
+	events *eventsFile
+
+	// pressure is the pressure stall information of the cgroup's tasks and
+	// those of its descendants. For the root cgroup, it's the system-wide
+	// pressure stall information. Immutable.
+	pressure *psi.Group
 }
 
 var _ kernel.CgroupImpl = (*cgroupInode)(nil)
@@ -176,6 +200,7 @@ func (fs *filesystem) newCgroupInode(ctx context.Context, creds *auth.Credential
 		dir:         dir{fs: fs},
 		ts:          make(map[*kernel.Task]struct{}),
 		controllers: make(map[kernel.CgroupControllerType]controller),
+		parent:      parent,
 	}
 	c.dir.cgi = c
 
@@ -191,7 +216,11 @@ func (fs *filesystem) newCgroupInode(ctx context.Context, creds *auth.Credential
 
 	contents := make(map[string]kernfs.Inode)
 	contents["cgroup.procs"] = fs.newControllerWritableFile(ctx, creds, &cgroupProcsData{c}, false)
-	contents["tasks"] = fs.newControllerWritableFile(ctx, creds, &tasksData{c}, false)
+	if fs.v2 {
+		fs.addV2CoreFiles(ctx, creds, c, contents)
+	} else {
+		contents["tasks"] = fs.newControllerWritableFile(ctx, creds, &tasksData{c}, false)
+	}
 
 	if parent != nil {
 		for ty, ctl := range parent.controllers {
@@ -248,23 +277,32 @@ func (c *cgroupInode) tasks() []*kernel.Task {
 // Enter implements kernel.CgroupImpl.Enter.
 func (c *cgroupInode) Enter(t *kernel.Task) {
 	c.fs.tasksMu.Lock()
-	defer c.fs.tasksMu.Unlock()
-
-	c.ts[t] = struct{}{}
+	changed := c.setTaskLocked(t, true)
 	for _, ctl := range c.controllers {
 		ctl.Enter(t)
 	}
+	if c.fs.v2 {
+		t.SetPressureGroup(c.pressure)
+	}
+	c.fs.tasksMu.Unlock()
+
+	notifyPopulated(t, changed)
 }
 
 // Leave implements kernel.CgroupImpl.Leave.
 func (c *cgroupInode) Leave(t *kernel.Task) {
 	c.fs.tasksMu.Lock()
-	defer c.fs.tasksMu.Unlock()
 
 	for _, ctl := range c.controllers {
 		ctl.Leave(t)
 	}
-	delete(c.ts, t)
+	if c.fs.v2 {
+		t.SetPressureGroup(nil)
+	}
+	changed := c.setTaskLocked(t, false)
+	c.fs.tasksMu.Unlock()
+
+	notifyPopulated(t, changed)
 }
 
 // PrepareMigrate implements kernel.CgroupImpl.PrepareMigrate.
@@ -290,15 +328,19 @@ func (c *cgroupInode) PrepareMigrate(t *kernel.Task, src *kernel.Cgroup) error {
 // CommitMigrate implements kernel.CgroupImpl.CommitMigrate.
 func (c *cgroupInode) CommitMigrate(t *kernel.Task, src *kernel.Cgroup) {
 	c.fs.tasksMu.Lock()
-	defer c.fs.tasksMu.Unlock()
-
 	for srcType, srcCtl := range src.CgroupImpl.(*cgroupInode).controllers {
 		c.controllers[srcType].CommitMigrate(t, srcCtl)
 	}
+	if c.fs.v2 {
+		t.SetPressureGroup(c.pressure)
+	}
 
 	srcI := src.CgroupImpl.(*cgroupInode)
-	delete(srcI.ts, t)
-	c.ts[t] = struct{}{}
+	changed := srcI.setTaskLocked(t, false)
+	changed = append(changed, c.setTaskLocked(t, true)...)
+	c.fs.tasksMu.Unlock()
+
+	notifyPopulated(t, changed)
 }
 
 // AbortMigrate implements kernel.CgroupImpl.AbortMigrate.
@@ -451,6 +493,15 @@ func (d *cgroupProcsData) Write(ctx context.Context, fd *vfs.FileDescription, sr
 	if targetTG == nil {
 		return 0, linuxerr.EINVAL
 	}
+	if d.fs.v2 {
+		leader := targetTG.Leader()
+		if leader == nil {
+			return 0, linuxerr.ESRCH
+		}
+		if err := d.checkV2Migration(ctx, leader); err != nil {
+			return 0, err
+		}
+	}
 	return n, targetTG.MigrateCgroup(d.CgroupFromControlFileFD(fd))
 }
 
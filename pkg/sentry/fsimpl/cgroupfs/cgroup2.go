@@ -0,0 +1,717 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroupfs
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// This file implements the cgroup v2 unified hierarchy, see
+// Documentation/admin-guide/cgroup-v2.rst in Linux.
+//
+// The unified hierarchy shares the controller backends with v1 hierarchies;
+// the controllers only expose a different set of control files when attached
+// to it. Unlike Linux, the control files of all attached controllers are
+// present in every non-root cgroup regardless of the parent's
+// cgroup.subtree_control, and the controllers are always active.
+// cgroup.subtree_control is nonetheless tracked and validated, as it
+// determines cgroup.controllers and the "no internal processes" rule that
+// delegated subtrees rely on.
+
+// V2FilesystemType implements vfs.FilesystemType for cgroup2.
+//
+// +stateify savable
+type V2FilesystemType struct{}
+
+// Name implements vfs.FilesystemType.Name.
+func (V2FilesystemType) Name() string {
+	return V2Name
+}
+
+// Release implements vfs.FilesystemType.Release.
+func (V2FilesystemType) Release(ctx context.Context) {}
+
+// GetFilesystem implements vfs.FilesystemType.GetFilesystem.
+func (fsType V2FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials, source string, opts vfs.GetFilesystemOptions) (*vfs.Filesystem, *vfs.Dentry, error) {
+	mopts := vfs.GenericParseMountOptions(opts.Data)
+	maxCachedDentries, err := consumeDentryCacheLimit(ctx, mopts)
+	if err != nil {
+		return nil, nil, err
+	}
+	// These only affect the semantics of features we don't implement:
+	// delegation across cgroup namespace boundaries and memory protection.
+	// Accept them, since they are carried over from the host mount table.
+	delete(mopts, "nsdelegate")
+	delete(mopts, "memory_recursiveprot")
+	if len(mopts) != 0 {
+		ctx.Debugf("cgroupfs.V2FilesystemType.GetFilesystem: unknown options: %v", mopts)
+		return nil, nil, linuxerr.EINVAL
+	}
+
+	k := kernel.KernelFromContext(ctx)
+	r := k.CgroupRegistry()
+
+	// There is a single unified hierarchy, all cgroup2 mounts are views into
+	// it.
+	if vfsfs := r.FindV2Hierarchy(); vfsfs != nil {
+		return newHierarchyView(ctx, vfsfs)
+	}
+
+	devMinor, err := vfsObj.GetAnonBlockDevMinor()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// "All controllers which support v2 and are not bound to a v1 hierarchy
+	// are automatically bound to the v2 hierarchy and show up at the root."
+	//   -- Documentation/admin-guide/cgroup-v2.rst
+	return newHierarchy(ctx, vfsObj, creds, &fsType, opts, hierarchyOptions{
+		devMinor:          devMinor,
+		maxCachedDentries: maxCachedDentries,
+		controllers:       r.UnboundControllers(kernel.CgroupCtrlsV2),
+		v2:                true,
+	})
+}
+
+// v2ControllerVisible returns whether ty is listed in cgroup.controllers and
+// can be enabled through cgroup.subtree_control. The cpuacct controller is an
+// implementation detail on the unified hierarchy, where CPU usage accounting
+// is always enabled and backs cpu.stat.
+func v2ControllerVisible(ty kernel.CgroupControllerType) bool {
+	return ty != kernel.CgroupControllerCPUAcct
+}
+
+// addV2CoreFiles adds the cgroup.* interface files for the unified hierarchy,
+// except for cgroup.procs which is common with v1.
+func (fs *filesystem) addV2CoreFiles(ctx context.Context, creds *auth.Credentials, c *cgroupInode, contents map[string]kernfs.Inode) {
+	c.subtreeControl = make(map[kernel.CgroupControllerType]struct{})
+	contents["cgroup.controllers"] = fs.newControllerFile(ctx, creds, &cgroupControllersData{c}, true)
+	contents["cgroup.subtree_control"] = fs.newControllerWritableFile(ctx, creds, &cgroupSubtreeControlData{c}, true)
+	contents["cgroup.threads"] = fs.newControllerWritableFile(ctx, creds, &cgroupThreadsData{c}, false)
+	contents["cgroup.stat"] = fs.newControllerFile(ctx, creds, &cgroupStatData{c}, true)
+	fs.addPressureFiles(ctx, creds, c, contents)
+	if c.parent != nil {
+		// Linux, kernel/cgroup/cgroup.c:cgroup_base_files marks these
+		// CFTYPE_NOT_ON_ROOT.
+		c.events = fs.newEventsFile(ctx, creds, &cgroupEventsData{c})
+		contents["cgroup.events"] = c.events
+		contents["cgroup.type"] = fs.newControllerWritableFile(ctx, creds, &cgroupTypeData{}, true)
+	}
+}
+
+// addPressureFiles adds the <resource>.pressure files reporting the pressure
+// stall information of c, see Linux, Documentation/accounting/psi.rst. As in
+// Linux, the root cgroup reports the system-wide pressure stall information.
+func (fs *filesystem) addPressureFiles(ctx context.Context, creds *auth.Credentials, c *cgroupInode, contents map[string]kernfs.Inode) {
+	k := kernel.KernelFromContext(ctx)
+	if c.parent == nil {
+		c.pressure = k.PressureGroup()
+	} else {
+		c.pressure = psi.NewGroup(c.parent.pressure, k.MonotonicClock().Now().Nanoseconds())
+	}
+	for r := psi.Resource(0); r < psi.NumResources; r++ {
+		contents[r.String()+".pressure"] = psi.NewFile(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), writableFileMode, c.pressure, r, k.MonotonicClock())
+	}
+}
+
+// availableControllersLocked returns the controllers listed in
+// cgroup.controllers, i.e. those that can be enabled for c's children.
+//
+// Precondition: Caller must hold c.fs.tasksMu.
+func (c *cgroupInode) availableControllersLocked() []kernel.CgroupControllerType {
+	var ctypes []kernel.CgroupControllerType
+	for _, ctl := range c.fs.controllers {
+		ty := ctl.Type()
+		if !v2ControllerVisible(ty) {
+			continue
+		}
+		if c.parent != nil {
+			if _, ok := c.parent.subtreeControl[ty]; !ok {
+				continue
+			}
+		}
+		ctypes = append(ctypes, ty)
+	}
+	return ctypes
+}
+
+// populatedLocked returns whether c or any of its descendants contain tasks.
+//
+// Precondition: Caller must hold c.fs.tasksMu.
+func (c *cgroupInode) populatedLocked() bool {
+	return len(c.ts) > 0 || c.populatedChildren > 0
+}
+
+// setTaskLocked adds t to c if member is true, and removes it otherwise. On the
+// unified hierarchy, it also maintains the populated state reported by
+// cgroup.events, and returns the cgroups whose state changed. The caller must
+// pass them to notifyPopulated once it releases c.fs.tasksMu.
+//
+// Precondition: Caller must hold c.fs.tasksMu for writing.
+func (c *cgroupInode) setTaskLocked(t *kernel.Task, member bool) []*cgroupInode {
+	if _, ok := c.ts[t]; ok == member {
+		return nil
+	}
+	wasPopulated := c.populatedLocked()
+	if member {
+		c.ts[t] = struct{}{}
+	} else {
+		delete(c.ts, t)
+	}
+	if !c.fs.v2 || c.populatedLocked() == wasPopulated {
+		return nil
+	}
+
+	// Propagate the transition to ancestors, up to the first one whose state
+	// doesn't change.
+	changed := []*cgroupInode{c}
+	for cg := c; cg.parent != nil; cg = cg.parent {
+		p := cg.parent
+		wasPopulated := p.populatedLocked()
+		if member {
+			p.populatedChildren++
+		} else {
+			p.populatedChildren--
+		}
+		if p.populatedLocked() == wasPopulated {
+			break
+		}
+		changed = append(changed, p)
+	}
+	return changed
+}
+
+// notifyPopulated signals a change of the populated state of cgs.
+func notifyPopulated(ctx context.Context, cgs []*cgroupInode) {
+	for _, cg := range cgs {
+		if cg.events != nil {
+			cg.events.notify(ctx)
+		}
+	}
+}
+
+// checkV2Migration checks whether the current task may move t into c through
+// cgroup.procs, per the "no internal processes" rule and the delegation
+// containment rules of the unified hierarchy.
+func (c *cgroupInode) checkV2Migration(ctx context.Context, t *kernel.Task) error {
+	src, ok := t.CgroupForHierarchy(c.fs.hierarchyID)
+	if !ok {
+		return linuxerr.EINVAL
+	}
+	srcCG := src.CgroupImpl.(*cgroupInode)
+
+	c.fs.tasksMu.RLock()
+	internal := c.parent != nil && len(c.subtreeControl) > 0
+	c.fs.tasksMu.RUnlock()
+	if internal {
+		// "Non-root cgroups can distribute domain resources to their
+		// children only when they don't have any processes of their own."
+		//   -- Documentation/admin-guide/cgroup-v2.rst
+		return linuxerr.EBUSY
+	}
+
+	// "The writer must have write access to the "cgroup.procs" file of the
+	// common ancestor of the source and destination cgroups."
+	//   -- Documentation/admin-guide/cgroup-v2.rst
+	ancestors := make(map[*cgroupInode]struct{})
+	for cg := srcCG; cg != nil; cg = cg.parent {
+		ancestors[cg] = struct{}{}
+	}
+	common := c
+	for ; common != nil; common = common.parent {
+		if _, ok := ancestors[common]; ok {
+			break
+		}
+	}
+	if common == nil {
+		// Both cgroups are on the same hierarchy, so they at least share the
+		// root.
+		panic("cgroupfs: cgroups on the unified hierarchy have no common ancestor")
+	}
+	procs, err := common.OrderedChildren.Lookup(ctx, "cgroup.procs")
+	if err != nil {
+		return err
+	}
+	return procs.CheckPermissions(ctx, auth.CredentialsFromContext(ctx), vfs.MayWrite)
+}
+
+// +stateify savable
+type cgroupControllersData struct {
+	*cgroupInode
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cgroupControllersData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	d.fs.tasksMu.RLock()
+	ctypes := d.availableControllersLocked()
+	d.fs.tasksMu.RUnlock()
+	writeControllerList(buf, ctypes)
+	return nil
+}
+
+// +stateify savable
+type cgroupSubtreeControlData struct {
+	*cgroupInode
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cgroupSubtreeControlData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	d.fs.tasksMu.RLock()
+	var ctypes []kernel.CgroupControllerType
+	for _, ctl := range d.fs.controllers {
+		if _, ok := d.subtreeControl[ctl.Type()]; ok {
+			ctypes = append(ctypes, ctl.Type())
+		}
+	}
+	d.fs.tasksMu.RUnlock()
+	writeControllerList(buf, ctypes)
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (d *cgroupSubtreeControlData) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	return d.WriteBackground(ctx, src)
+}
+
+// WriteBackground implements writableControllerFileImpl.WriteBackground.
+//
+// See Linux, kernel/cgroup/cgroup.c:cgroup_subtree_control_write().
+func (d *cgroupSubtreeControlData) WriteBackground(ctx context.Context, src usermem.IOSequence) (int64, error) {
+	str, n, err := copyInString(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	enable, disable, err := parseSubtreeControl(str)
+	if err != nil {
+		return 0, err
+	}
+
+	d.fs.tasksMu.Lock()
+	defer d.fs.tasksMu.Unlock()
+
+	available := make(map[kernel.CgroupControllerType]struct{})
+	for _, ty := range d.availableControllersLocked() {
+		available[ty] = struct{}{}
+	}
+	for ty := range enable {
+		if _, ok := available[ty]; !ok {
+			return 0, linuxerr.ENOENT
+		}
+		if _, ok := d.subtreeControl[ty]; ok {
+			delete(enable, ty)
+		}
+	}
+	for ty := range disable {
+		if _, ok := d.subtreeControl[ty]; !ok {
+			delete(disable, ty)
+			continue
+		}
+		// Controllers must be disabled bottom-up.
+		busy := false
+		d.forEachChildDir(func(child *dir) {
+			if _, ok := child.cgi.subtreeControl[ty]; ok {
+				busy = true
+			}
+		})
+		if busy {
+			return 0, linuxerr.EBUSY
+		}
+	}
+	if len(enable) > 0 && d.parent != nil && len(d.ts) > 0 {
+		// The "no internal processes" rule, see checkV2Migration.
+		return 0, linuxerr.EBUSY
+	}
+
+	for ty := range enable {
+		d.subtreeControl[ty] = struct{}{}
+	}
+	for ty := range disable {
+		delete(d.subtreeControl, ty)
+	}
+	return n, nil
+}
+
+// parseSubtreeControl parses a write to cgroup.subtree_control, a space
+// separated list of controller names prefixed with '+' or '-' to enable or
+// disable them. If a controller appears more than once, the last occurrence
+// wins.
+func parseSubtreeControl(str string) (enable, disable map[kernel.CgroupControllerType]struct{}, err error) {
+	enable = make(map[kernel.CgroupControllerType]struct{})
+	disable = make(map[kernel.CgroupControllerType]struct{})
+	for _, tok := range strings.Fields(str) {
+		ty, err := kernel.ParseCgroupController(tok[1:])
+		if err != nil || !v2ControllerVisible(ty) || !isV2Controller(ty) {
+			return nil, nil, linuxerr.EINVAL
+		}
+		switch tok[0] {
+		case '+':
+			enable[ty] = struct{}{}
+			delete(disable, ty)
+		case '-':
+			disable[ty] = struct{}{}
+			delete(enable, ty)
+		default:
+			return nil, nil, linuxerr.EINVAL
+		}
+	}
+	return enable, disable, nil
+}
+
+// isV2Controller returns whether ty can be attached to the unified hierarchy.
+func isV2Controller(ty kernel.CgroupControllerType) bool {
+	for _, v2ty := range kernel.CgroupCtrlsV2 {
+		if ty == v2ty {
+			return true
+		}
+	}
+	return false
+}
+
+func writeControllerList(buf *bytes.Buffer, ctypes []kernel.CgroupControllerType) {
+	names := make([]string, 0, len(ctypes))
+	for _, ty := range ctypes {
+		names = append(names, string(ty))
+	}
+	fmt.Fprintf(buf, "%s\n", strings.Join(names, " "))
+}
+
+// +stateify savable
+type cgroupThreadsData struct {
+	*cgroupInode
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cgroupThreadsData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	return (&tasksData{d.cgroupInode}).Generate(ctx, buf)
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (d *cgroupThreadsData) Write(ctx context.Context, fd *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	tid, n, err := parseInt64FromString(ctx, src)
+	if err != nil {
+		return n, err
+	}
+
+	t := kernel.TaskFromContext(ctx)
+	currPidns := t.ThreadGroup().PIDNamespace()
+	targetTask := t
+	if tid != 0 {
+		targetTask = currPidns.TaskWithID(kernel.ThreadID(tid))
+	}
+	if targetTask == nil {
+		return 0, linuxerr.EINVAL
+	}
+	cg, ok := targetTask.CgroupForHierarchy(d.fs.hierarchyID)
+	if !ok {
+		return 0, linuxerr.EINVAL
+	}
+	if cg.CgroupImpl.(*cgroupInode) != d.cgroupInode {
+		// Threads may only move within a threaded subtree, which isn't
+		// supported; all cgroups are domain cgroups. See Linux,
+		// kernel/cgroup/cgroup.c:cgroup_attach_permissions().
+		return 0, linuxerr.EOPNOTSUPP
+	}
+	return n, nil
+}
+
+// +stateify savable
+type cgroupStatData struct {
+	*cgroupInode
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cgroupStatData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	var descendants int
+	var count func(*dir)
+	count = func(child *dir) {
+		descendants++
+		child.forEachChildDir(count)
+	}
+	d.forEachChildDir(count)
+	fmt.Fprintf(buf, "nr_descendants %d\n", descendants)
+	fmt.Fprintf(buf, "nr_dying_descendants 0\n")
+	return nil
+}
+
+// +stateify savable
+type cgroupEventsData struct {
+	*cgroupInode
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cgroupEventsData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	d.fs.tasksMu.RLock()
+	populated := d.populatedLocked()
+	d.fs.tasksMu.RUnlock()
+	if populated {
+		fmt.Fprintf(buf, "populated 1\n")
+	} else {
+		fmt.Fprintf(buf, "populated 0\n")
+	}
+	// Freezing cgroups isn't supported.
+	fmt.Fprintf(buf, "frozen 0\n")
+	return nil
+}
+
+// cgroupTypeData implements cgroup.type. Threaded cgroups aren't supported, so
+// all cgroups are domain cgroups.
+//
+// +stateify savable
+type cgroupTypeData struct{}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (*cgroupTypeData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	fmt.Fprintf(buf, "domain\n")
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (d *cgroupTypeData) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	return d.WriteBackground(ctx, src)
+}
+
+// WriteBackground implements writableControllerFileImpl.WriteBackground.
+func (*cgroupTypeData) WriteBackground(ctx context.Context, src usermem.IOSequence) (int64, error) {
+	str, n, err := copyInString(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.TrimSpace(str) {
+	case "domain":
+		return n, nil
+	case "threaded":
+		return 0, linuxerr.EOPNOTSUPP
+	default:
+		return 0, linuxerr.EINVAL
+	}
+}
+
+// eventsFile is a read-only control file reporting events, like cgroup.events
+// and memory.events. As in Linux, changes are signalled to poll(2) as
+// POLLPRI|POLLERR until the file is read again, and to inotify as IN_MODIFY.
+//
+// +stateify savable
+type eventsFile struct {
+	controllerFile
+
+	// queue is notified on every change of the file contents.
+	queue waiter.Queue
+
+	// gen is incremented on every change of the file contents.
+	gen atomicbitops.Uint64
+}
+
+func (fs *filesystem) newEventsFile(ctx context.Context, creds *auth.Credentials, data vfs.DynamicBytesSource) *eventsFile {
+	f := &eventsFile{
+		controllerFile: controllerFile{
+			allowBackgroundAccess: true,
+		},
+	}
+	f.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), data, readonlyFileMode)
+	return f
+}
+
+// Open implements kernfs.Inode.Open.
+func (f *eventsFile) Open(ctx context.Context, rp *vfs.ResolvingPath, d *kernfs.Dentry, opts vfs.OpenOptions) (*vfs.FileDescription, error) {
+	fd := &eventsFD{file: f}
+	fd.seen.Store(f.gen.Load())
+	if err := fd.Init(rp.Mount(), d, f.Data(), f.Locks(), opts.Flags); err != nil {
+		return nil, err
+	}
+	return &fd.vfsfd, nil
+}
+
+// notify signals a change of the file contents.
+func (f *eventsFile) notify(ctx context.Context) {
+	f.gen.Add(1)
+	f.queue.Notify(waiter.EventPri | waiter.EventErr)
+	f.Watches().Notify(ctx, "", linux.IN_MODIFY, 0, vfs.InodeEvent, false /* unlinked */)
+}
+
+// eventsFD implements vfs.FileDescriptionImpl for an eventsFile. It's mostly
+// similar to kernfs.DynamicBytesFD, but implements readiness.
+//
+// +stateify savable
+type eventsFD struct {
+	vfs.FileDescriptionDefaultImpl
+	vfs.DynamicBytesFileDescriptionImpl
+	vfs.LockFD
+
+	vfsfd vfs.FileDescription
+	file  *eventsFile
+
+	// seen is the value of file.gen when the file was last read through fd.
+	seen atomicbitops.Uint64
+}
+
+// Init initializes an eventsFD. Mostly copied from kernfs.DynamicBytesFD.Init,
+// but uses the eventsFD as FileDescriptionImpl.
+func (fd *eventsFD) Init(m *vfs.Mount, d *kernfs.Dentry, data vfs.DynamicBytesSource, locks *vfs.FileLocks, flags uint32) error {
+	fd.LockFD.Init(locks)
+	if err := fd.vfsfd.Init(fd, flags, m, d.VFSDentry(), &vfs.FileDescriptionOptions{
+		DenySpliceIn: true,
+	}); err != nil {
+		return err
+	}
+	fd.DynamicBytesFileDescriptionImpl.Init(&fd.vfsfd, data)
+	return nil
+}
+
+// Seek implements vfs.FileDescriptionImpl.Seek.
+func (fd *eventsFD) Seek(ctx context.Context, offset int64, whence int32) (int64, error) {
+	return fd.DynamicBytesFileDescriptionImpl.Seek(ctx, offset, whence)
+}
+
+// Read implements vfs.FileDescriptionImpl.Read.
+func (fd *eventsFD) Read(ctx context.Context, dst usermem.IOSequence, opts vfs.ReadOptions) (int64, error) {
+	fd.seen.Store(fd.file.gen.Load())
+	return fd.DynamicBytesFileDescriptionImpl.Read(ctx, dst, opts)
+}
+
+// PRead implements vfs.FileDescriptionImpl.PRead.
+func (fd *eventsFD) PRead(ctx context.Context, dst usermem.IOSequence, offset int64, opts vfs.ReadOptions) (int64, error) {
+	fd.seen.Store(fd.file.gen.Load())
+	return fd.DynamicBytesFileDescriptionImpl.PRead(ctx, dst, offset, opts)
+}
+
+// Write implements vfs.FileDescriptionImpl.Write.
+func (fd *eventsFD) Write(ctx context.Context, src usermem.IOSequence, opts vfs.WriteOptions) (int64, error) {
+	return fd.DynamicBytesFileDescriptionImpl.Write(ctx, src, opts)
+}
+
+// PWrite implements vfs.FileDescriptionImpl.PWrite.
+func (fd *eventsFD) PWrite(ctx context.Context, src usermem.IOSequence, offset int64, opts vfs.WriteOptions) (int64, error) {
+	return fd.DynamicBytesFileDescriptionImpl.PWrite(ctx, src, offset, opts)
+}
+
+// Release implements vfs.FileDescriptionImpl.Release.
+func (fd *eventsFD) Release(context.Context) {}
+
+// Stat implements vfs.FileDescriptionImpl.Stat.
+func (fd *eventsFD) Stat(ctx context.Context, opts vfs.StatOptions) (linux.Statx, error) {
+	fs := fd.vfsfd.VirtualDentry().Mount().Filesystem()
+	return fd.file.Stat(ctx, fs, opts)
+}
+
+// SetStat implements vfs.FileDescriptionImpl.SetStat.
+func (fd *eventsFD) SetStat(context.Context, vfs.SetStatOptions) error {
+	// DynamicBytesFiles are immutable.
+	return linuxerr.EPERM
+}
+
+// Readiness implements waiter.Waitable.Readiness similar to Linux,
+// fs/kernfs/file.c:kernfs_generic_poll().
+func (fd *eventsFD) Readiness(mask waiter.EventMask) waiter.EventMask {
+	ready := waiter.ReadableEvents | waiter.WritableEvents
+	if fd.seen.Load() != fd.file.gen.Load() {
+		ready |= waiter.EventPri | waiter.EventErr
+	}
+	return ready & mask
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (fd *eventsFD) EventRegister(e *waiter.Entry) error {
+	fd.file.queue.EventRegister(e)
+	return nil
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (fd *eventsFD) EventUnregister(e *waiter.Entry) {
+	fd.file.queue.EventUnregister(e)
+}
+
+// Epollable implements vfs.FileDescriptionImpl.Epollable.
+func (fd *eventsFD) Epollable() bool {
+	return true
+}
+
+// parseMaxValue parses a non-negative integer, or "max" which is returned as
+// unlimited.
+func parseMaxValue(str string, unlimited int64) (int64, error) {
+	str = strings.TrimSpace(str)
+	if str == "max" {
+		return unlimited, nil
+	}
+	val, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || val < 0 {
+		return 0, linuxerr.EINVAL
+	}
+	return min(val, unlimited), nil
+}
+
+// copyInString copies in the contents of a control file write from src, up to
+// a page.
+func copyInString(ctx context.Context, src usermem.IOSequence) (string, int64, error) {
+	if src.NumBytes() > hostarch.PageSize {
+		return "", 0, linuxerr.EINVAL
+	}
+	buf := copyScratchBufferFromContext(ctx, hostarch.PageSize)
+	n, err := src.CopyIn(ctx, buf)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(buf[:n]), int64(n), nil
+}
+
+// Bounds of the CFS bandwidth period and quota in microseconds, see Linux,
+// kernel/sched/core.c.
+const (
+	cfsMinPeriod = 1000
+	cfsMaxPeriod = 1000000
+	cfsMinQuota  = 1000
+)
+
+// parseCPUMax parses a write to cpu.max, "$MAX [$PERIOD]", where $MAX is a
+// quota in microseconds or "max" for no limit. A quota of -1 indicates no
+// limit, as in cpu.cfs_quota_us. If the period is omitted, period is
+// returned unchanged.
+func parseCPUMax(str string, period int64) (int64, int64, error) {
+	fields := strings.Fields(str)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, linuxerr.EINVAL
+	}
+	if len(fields) == 2 {
+		p, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || p < cfsMinPeriod || p > cfsMaxPeriod {
+			return 0, 0, linuxerr.EINVAL
+		}
+		period = p
+	}
+	if fields[0] == "max" {
+		return -1, period, nil
+	}
+	quota, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || quota < cfsMinQuota || quota > math.MaxInt64/1000 {
+		return 0, 0, linuxerr.EINVAL
+	}
+	return quota, period, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroupfs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+)
+
+func controllerSet(m map[kernel.CgroupControllerType]struct{}) string {
+	var s []string
+	for ty := range m {
+		s = append(s, string(ty))
+	}
+	sort.Strings(s)
+	return strings.Join(s, " ")
+}
+
+func TestParseSubtreeControl(t *testing.T) {
+	tests := []struct {
+		input      string
+		enable     string
+		disable    string
+		shouldFail bool
+	}{
+		{"", "", "", false},
+		{"+memory", "memory", "", false},
+		{"+memory -cpu", "memory", "cpu", false},
+		{"+cpu +pids\n", "cpu pids", "", false},
+		{"+memory -memory", "", "memory", false},
+		{"-memory +memory", "memory", "", false},
+		{"memory", "", "", true},
+		{"+cpuacct", "", "", true},
+		{"+devices", "", "", true},
+		{"+foo", "", "", true},
+		{"+", "", "", true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", i), func(t *testing.T) {
+			enable, disable, err := parseSubtreeControl(tt.input)
+			if tt.shouldFail {
+				if err == nil {
+					t.Fatalf("Expected parsing %q to fail, got enable %v, disable %v", tt.input, enable, disable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.input, err)
+			}
+			if got := controllerSet(enable); got != tt.enable {
+				t.Errorf("Enabled controllers: got %v, want %v", got, tt.enable)
+			}
+			if got := controllerSet(disable); got != tt.disable {
+				t.Errorf("Disabled controllers: got %v, want %v", got, tt.disable)
+			}
+		})
+	}
+}
+
+func TestParseCPUMax(t *testing.T) {
+	tests := []struct {
+		input      string
+		quota      int64
+		period     int64
+		shouldFail bool
+	}{
+		{"max", -1, 100000, false},
+		{"max 50000", -1, 50000, false},
+		{"50000", 50000, 100000, false},
+		{"200000 1000000\n", 200000, 1000000, false},
+		{"", 0, 0, true},
+		{"100", 0, 0, true},
+		{"50000 10", 0, 0, true},
+		{"50000 2000000", 0, 0, true},
+		{"50000 100000 1", 0, 0, true},
+		{"abc", 0, 0, true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", i), func(t *testing.T) {
+			quota, period, err := parseCPUMax(tt.input, 100000)
+			if tt.shouldFail {
+				if err == nil {
+					t.Fatalf("Expected parsing %q to fail, got quota %d, period %d", tt.input, quota, period)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.input, err)
+			}
+			if quota != tt.quota || period != tt.period {
+				t.Errorf("Got quota %d, period %d, want quota %d, period %d", quota, period, tt.quota, tt.period)
+			}
+		})
+	}
+}
+
+func TestParseMaxValue(t *testing.T) {
+	tests := []struct {
+		input      string
+		output     int64
+		shouldFail bool
+	}{
+		{"max", math.MaxInt64, false},
+		{"max\n", math.MaxInt64, false},
+		{"0", 0, false},
+		{"1048576\n", 1048576, false},
+		{"-1", 0, true},
+		{"1M", 0, true},
+		{"", 0, true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", i), func(t *testing.T) {
+			val, err := parseMaxValue(tt.input, math.MaxInt64)
+			if tt.shouldFail {
+				if err == nil {
+					t.Fatalf("Expected parsing %q to fail, got %d", tt.input, val)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.input, err)
+			}
+			if val != tt.output {
+				t.Errorf("Got %d, want %d", val, tt.output)
+			}
+		})
+	}
+}
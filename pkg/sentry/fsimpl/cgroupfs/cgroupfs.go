@@ -77,7 +77,10 @@ import (
 
 const (
 	// Name is the default filesystem name.
-	Name             = "cgroup"
+	Name = "cgroup"
+	// V2Name is the name of the cgroup v2 filesystem.
+	V2Name = "cgroup2"
+
 	readonlyFileMode = linux.FileMode(0444)
 	writableFileMode = linux.FileMode(0644)
 	defaultDirMode   = linux.FileMode(0555) | linux.ModeDirectory
@@ -155,6 +158,10 @@ type filesystem struct {
 	// Immutable after initialization.
 	hierarchyName string
 
+	// v2 indicates this is the cgroup v2 unified hierarchy, see cgroup2.go.
+	// Immutable.
+	v2 bool
+
 	// controllers and kcontrollers are both the list of controllers attached to
 	// this cgroupfs. Both lists are the same set of controllers, but typecast
 	// to different interfaces for convenience. Both must stay in sync, and are
@@ -189,6 +196,11 @@ func (fs *filesystem) RootCgroup() kernel.Cgroup {
 	}
 }
 
+// IsV2 implements kernel.cgroupFS.IsV2.
+func (fs *filesystem) IsV2() bool {
+	return fs.v2
+}
+
 // Name implements vfs.FilesystemType.Name.
 func (FilesystemType) Name() string {
 	return Name
@@ -205,14 +217,9 @@ func (fsType FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 	}
 
 	mopts := vfs.GenericParseMountOptions(opts.Data)
-	maxCachedDentries := defaultMaxCachedDentries
-	if str, ok := mopts["dentry_cache_limit"]; ok {
-		delete(mopts, "dentry_cache_limit")
-		maxCachedDentries, err = strconv.ParseUint(str, 10, 64)
-		if err != nil {
-			ctx.Warningf("sys.FilesystemType.GetFilesystem: invalid dentry cache limit: dentry_cache_limit=%s", str)
-			return nil, nil, linuxerr.EINVAL
-		}
+	maxCachedDentries, err := consumeDentryCacheLimit(ctx, mopts)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var wantControllers []kernel.CgroupControllerType
@@ -310,13 +317,7 @@ func (fsType FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		return nil, nil, err
 	}
 	if vfsfs != nil {
-		fs := vfsfs.Impl().(*filesystem)
-		ctx.Debugf("cgroupfs.FilesystemType.GetFilesystem: mounting new view to hierarchy %v", fs.hierarchyID)
-		fs.root.IncRef()
-		if fs.effectiveRoot != fs.root {
-			fs.effectiveRoot.IncRef()
-		}
-		return vfsfs, fs.root.VFSDentry(), nil
+		return newHierarchyView(ctx, vfsfs)
 	}
 
 	// No existing hierarchy with the exactly controllers found. Make a new
@@ -324,12 +325,82 @@ func (fsType FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 	// or more of the requested controllers are already on existing
 	// hierarchies. We'll find out about such collisions when we try to register
 	// the new hierarchy later.
+	return newHierarchy(ctx, vfsObj, creds, &fsType, opts, hierarchyOptions{
+		devMinor:          devMinor,
+		maxCachedDentries: maxCachedDentries,
+		name:              name,
+		controllers:       wantControllers,
+	})
+}
+
+// consumeDentryCacheLimit removes the "dentry_cache_limit" option from mopts
+// and returns its value, or the default if it's absent.
+func consumeDentryCacheLimit(ctx context.Context, mopts map[string]string) (uint64, error) {
+	str, ok := mopts["dentry_cache_limit"]
+	if !ok {
+		return defaultMaxCachedDentries, nil
+	}
+	delete(mopts, "dentry_cache_limit")
+	maxCachedDentries, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		ctx.Warningf("cgroupfs.FilesystemType.GetFilesystem: invalid dentry cache limit: dentry_cache_limit=%s", str)
+		return 0, linuxerr.EINVAL
+	}
+	return maxCachedDentries, nil
+}
+
+// newHierarchyView returns a new view into the existing hierarchy backed by
+// vfsfs. The view is rooted at the root of the mounting task's cgroup
+// namespace in the hierarchy. The caller's reference on vfsfs is transferred
+// to the returned filesystem.
+func newHierarchyView(ctx context.Context, vfsfs *vfs.Filesystem) (*vfs.Filesystem, *vfs.Dentry, error) {
+	fs := vfsfs.Impl().(*filesystem)
+	ctx.Debugf("cgroupfs.FilesystemType.GetFilesystem: mounting new view to hierarchy %v", fs.hierarchyID)
+	root := fs.root
+	ns := kernel.KernelFromContext(ctx).GetCgroupNamespaceFromContext(ctx)
+	if c, ok := ns.Root(fs.hierarchyID); ok {
+		root = c.Dentry
+	}
+	root.IncRef()
+	ns.DecRef(ctx)
+	if fs.effectiveRoot != fs.root {
+		fs.effectiveRoot.IncRef()
+	}
+	return vfsfs, root.VFSDentry(), nil
+}
+
+// hierarchyOptions are the properties of a new hierarchy.
+type hierarchyOptions struct {
+	devMinor          uint32
+	maxCachedDentries uint64
+	name              string
+	controllers       []kernel.CgroupControllerType
+	v2                bool
+}
+
+// newHierarchy creates and registers a new hierarchy.
+func newHierarchy(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials, fsType vfs.FilesystemType, opts vfs.GetFilesystemOptions, hopts hierarchyOptions) (*vfs.Filesystem, *vfs.Dentry, error) {
+	k := kernel.KernelFromContext(ctx)
+	r := k.CgroupRegistry()
+	name := hopts.name
+	wantControllers := hopts.controllers
+
+	// Hierarchies may only be created in the initial cgroup namespace. See
+	// kernel/cgroup/cgroup-v1.c:cgroup1_root_to_use().
+	ns := k.GetCgroupNamespaceFromContext(ctx)
+	ns.DecRef(ctx)
+	if ns != k.RootCgroupNamespace() {
+		vfsObj.PutAnonBlockDevMinor(hopts.devMinor)
+		return nil, nil, linuxerr.EPERM
+	}
+
 	fs := &filesystem{
-		devMinor:      devMinor,
+		devMinor:      hopts.devMinor,
 		hierarchyName: name,
+		v2:            hopts.v2,
 	}
-	fs.MaxCachedDentries = maxCachedDentries
-	fs.VFSFilesystem().Init(vfsObj, &fsType, fs)
+	fs.MaxCachedDentries = hopts.maxCachedDentries
+	fs.VFSFilesystem().Init(vfsObj, fsType, fs)
 
 	var defaults map[string]int64
 	if opts.InternalData != nil {
@@ -483,6 +554,10 @@ func (fs *filesystem) Release(ctx context.Context) {
 
 // MountOptions implements vfs.FilesystemImpl.MountOptions.
 func (fs *filesystem) MountOptions() string {
+	if fs.v2 {
+		// The unified hierarchy always has all available controllers.
+		return ""
+	}
 	var cnames []string
 	for _, c := range fs.controllers {
 		cnames = append(cnames, string(c.Type()))
@@ -490,11 +565,26 @@ func (fs *filesystem) MountOptions() string {
 	return strings.Join(cnames, ",")
 }
 
+// ShowPath implements vfs.ShowPathExtension.ShowPath. Mount roots are
+// displayed relative to the root of the reader's cgroup namespace. See
+// kernel/cgroup/cgroup.c:cgroup_show_path().
+func (fs *filesystem) ShowPath(ctx context.Context, d *vfs.Dentry) (string, bool) {
+	ns := kernel.KernelFromContext(ctx).GetCgroupNamespaceFromContext(ctx)
+	defer ns.DecRef(ctx)
+	if _, ok := ns.Root(fs.hierarchyID); !ok {
+		return "", false
+	}
+	return ns.RelativePath(fs.hierarchyID, d.Impl().(*kernfs.Dentry).FSLocalPath()), true
+}
+
 // +stateify savable
 type implStatFS struct{}
 
 // StatFS implements kernfs.Inode.StatFS.
-func (*implStatFS) StatFS(context.Context, *vfs.Filesystem) (linux.Statfs, error) {
+func (*implStatFS) StatFS(_ context.Context, vfsfs *vfs.Filesystem) (linux.Statfs, error) {
+	if vfsfs.Impl().(*filesystem).v2 {
+		return vfs.GenericStatFS(linux.CGROUP2_SUPER_MAGIC), nil
+	}
 	return vfs.GenericStatFS(linux.CGROUP_SUPER_MAGIC), nil
 }
 
@@ -640,6 +730,9 @@ func (d *dir) RmDir(ctx context.Context, name string, child kernfs.Inode) error
 	err := d.OrderedChildren.RmDir(ctx, name, child)
 	if err == nil {
 		d.InodeAttrs.DecLinks()
+		if ctl, ok := cgi.controllers[kernel.CgroupControllerMemory]; ok {
+			ctl.(*memoryController).release()
+		}
 	}
 	return err
 }
@@ -15,25 +15,43 @@
 package cgroupfs
 
 import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/atomicbitops"
 	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
 )
 
 // +stateify savable
 type cpuController struct {
 	controllerCommon
-	controllerStateless
 	controllerNoResource
 
+	// mu serializes updates to the group's limit and weight.
+	mu sync.Mutex `state:"nosave"`
+
 	// CFS bandwidth control parameters, values in microseconds.
 	cfsPeriod atomicbitops.Int64
 	cfsQuota  atomicbitops.Int64
 
 	// CPU shares, values should be (num core * 1024).
 	shares atomicbitops.Int64
+
+	// CPU weight on the unified hierarchy, in the range [1, 10000].
+	weight atomicbitops.Int64
+
+	// group enforces the bandwidth limit and weight for tasks in the cgroup.
+	// group is immutable.
+	group *kernel.CPUGroup
 }
 
 var _ controller = (*cpuController)(nil)
@@ -44,6 +62,8 @@ func newCPUController(fs *filesystem, defaults map[string]int64) *cpuController
 		cfsPeriod: atomicbitops.FromInt64(100000),
 		cfsQuota:  atomicbitops.FromInt64(-1),
 		shares:    atomicbitops.FromInt64(1024),
+		weight:    atomicbitops.FromInt64(kernel.DefaultCPUWeight),
+		group:     kernel.NewCPUGroup(nil),
 	}
 
 	if val, ok := defaults["cpu.cfs_period_us"]; ok {
@@ -65,18 +85,275 @@ func newCPUController(fs *filesystem, defaults map[string]int64) *cpuController
 
 // Clone implements controller.Clone.
 func (c *cpuController) Clone() controller {
+	// As on Linux, a new cgroup starts out unlimited with the default weight,
+	// but remains subject to the limits of its ancestors.
 	new := &cpuController{
 		cfsPeriod: atomicbitops.FromInt64(c.cfsPeriod.Load()),
-		cfsQuota:  atomicbitops.FromInt64(c.cfsQuota.Load()),
-		shares:    atomicbitops.FromInt64(c.shares.Load()),
+		cfsQuota:  atomicbitops.FromInt64(-1),
+		shares:    atomicbitops.FromInt64(1024),
+		weight:    atomicbitops.FromInt64(kernel.DefaultCPUWeight),
+		group:     kernel.NewCPUGroup(c.group),
 	}
 	new.controllerCommon.cloneFromParent(c)
 	return new
 }
 
 // AddControlFiles implements controller.AddControlFiles.
-func (c *cpuController) AddControlFiles(ctx context.Context, creds *auth.Credentials, _ *cgroupInode, contents map[string]kernfs.Inode) {
-	contents["cpu.cfs_period_us"] = c.fs.newStubControllerFile(ctx, creds, &c.cfsPeriod, true)
-	contents["cpu.cfs_quota_us"] = c.fs.newStubControllerFile(ctx, creds, &c.cfsQuota, true)
-	contents["cpu.shares"] = c.fs.newStubControllerFile(ctx, creds, &c.shares, true)
+func (c *cpuController) AddControlFiles(ctx context.Context, creds *auth.Credentials, cg *cgroupInode, contents map[string]kernfs.Inode) {
+	// Apply parameters configured when the cgroup was created.
+	c.updateLimit(ctx)
+	c.updateWeight(ctx)
+
+	if c.fs.v2 {
+		if cg.parent != nil {
+			contents["cpu.max"] = c.fs.newCPUBandwidthFile(ctx, creds, c, cpuMax)
+			contents["cpu.weight"] = c.fs.newCPUWeightFile(ctx, creds, c)
+		}
+		return
+	}
+	contents["cpu.cfs_period_us"] = c.fs.newCPUBandwidthFile(ctx, creds, c, cpuCFSPeriod)
+	contents["cpu.cfs_quota_us"] = c.fs.newCPUBandwidthFile(ctx, creds, c, cpuCFSQuota)
+	contents["cpu.shares"] = c.fs.newCPUWeightFile(ctx, creds, c)
+	contents["cpu.stat"] = c.fs.newControllerFile(ctx, creds, &cpuBandwidthStatData{c}, true)
+}
+
+// Enter implements controller.Enter.
+func (c *cpuController) Enter(t *kernel.Task) {
+	t.SetCPUGroup(c.group)
+}
+
+// Leave implements controller.Leave.
+func (c *cpuController) Leave(t *kernel.Task) {
+	t.SetCPUGroup(nil)
+}
+
+// PrepareMigrate implements controller.PrepareMigrate.
+func (c *cpuController) PrepareMigrate(t *kernel.Task, src controller) error {
+	return nil
+}
+
+// CommitMigrate implements controller.CommitMigrate.
+func (c *cpuController) CommitMigrate(t *kernel.Task, src controller) {
+	t.SetCPUGroup(c.group)
+}
+
+// AbortMigrate implements controller.AbortMigrate.
+func (c *cpuController) AbortMigrate(t *kernel.Task, src controller) {}
+
+// updateLimit applies the cgroup's CFS bandwidth parameters to its tasks.
+func (c *cpuController) updateLimit(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := kernel.KernelFromContext(ctx)
+	quota := c.cfsQuota.Load()
+	if quota >= 0 {
+		k.CgroupRegistry().EnableCPULimits()
+	}
+	c.group.SetLimit(k, time.Duration(quota)*time.Microsecond, time.Duration(c.cfsPeriod.Load())*time.Microsecond)
+}
+
+// updateWeight applies the cgroup's weight, or its shares on cgroup v1, to
+// its tasks.
+func (c *cpuController) updateWeight(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	weight := c.weight.Load()
+	if !c.fs.v2 {
+		weight = sharesToWeight(c.shares.Load())
+	}
+	if weight != kernel.DefaultCPUWeight {
+		kernel.KernelFromContext(ctx).CgroupRegistry().EnableCPULimits()
+	}
+	c.group.SetWeight(weight)
+}
+
+// Bounds for cpu.shares. See Linux, kernel/sched/sched.h:MIN_SHARES and
+// MAX_SHARES.
+const (
+	cpuMinShares = 2
+	cpuMaxShares = 1 << 18
+)
+
+// sharesToWeight converts cpu.shares to a cpu.weight-scale weight, such that
+// the default shares of 1024 map to the default weight.
+func sharesToWeight(shares int64) int64 {
+	return max(1, shares*kernel.DefaultCPUWeight/1024)
+}
+
+// cpuBandwidthParam identifies the CFS bandwidth parameters read and written
+// by a cpuBandwidthFile.
+type cpuBandwidthParam int
+
+const (
+	// cpuCFSPeriod is cpu.cfs_period_us.
+	cpuCFSPeriod cpuBandwidthParam = iota
+
+	// cpuCFSQuota is cpu.cfs_quota_us.
+	cpuCFSQuota
+
+	// cpuMax is cpu.max, the unified hierarchy equivalent of
+	// cpu.cfs_quota_us and cpu.cfs_period_us.
+	cpuMax
+)
+
+// cpuBandwidthFile implements the cpu.cfs_period_us, cpu.cfs_quota_us and
+// cpu.max control files.
+//
+// +stateify savable
+type cpuBandwidthFile struct {
+	controllerFile
+
+	c     *cpuController
+	param cpuBandwidthParam
+}
+
+var _ controllerFileImpl = (*cpuBandwidthFile)(nil)
+
+func (fs *filesystem) newCPUBandwidthFile(ctx context.Context, creds *auth.Credentials, c *cpuController, param cpuBandwidthParam) kernfs.Inode {
+	f := &cpuBandwidthFile{
+		controllerFile: controllerFile{
+			allowBackgroundAccess: true,
+		},
+		c:     c,
+		param: param,
+	}
+	f.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), f, writableFileMode)
+	return f
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (f *cpuBandwidthFile) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	switch f.param {
+	case cpuCFSPeriod:
+		fmt.Fprintf(buf, "%d\n", f.c.cfsPeriod.Load())
+	case cpuCFSQuota:
+		fmt.Fprintf(buf, "%d\n", f.c.cfsQuota.Load())
+	case cpuMax:
+		if quota := f.c.cfsQuota.Load(); quota >= 0 {
+			fmt.Fprintf(buf, "%d %d\n", quota, f.c.cfsPeriod.Load())
+		} else {
+			fmt.Fprintf(buf, "max %d\n", f.c.cfsPeriod.Load())
+		}
+	}
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (f *cpuBandwidthFile) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	return f.WriteBackground(ctx, src)
+}
+
+// WriteBackground implements writableControllerFileImpl.WriteBackground.
+func (f *cpuBandwidthFile) WriteBackground(ctx context.Context, src usermem.IOSequence) (int64, error) {
+	if f.param == cpuMax {
+		str, n, err := copyInString(ctx, src)
+		if err != nil {
+			return 0, err
+		}
+		quota, period, err := parseCPUMax(str, f.c.cfsPeriod.Load())
+		if err != nil {
+			return 0, err
+		}
+		f.c.cfsPeriod.Store(period)
+		f.c.cfsQuota.Store(quota)
+		f.c.updateLimit(ctx)
+		return n, nil
+	}
+
+	val, n, err := parseInt64FromString(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	// See Linux, kernel/sched/core.c:tg_set_cfs_bandwidth().
+	switch f.param {
+	case cpuCFSPeriod:
+		if val < cfsMinPeriod || val > cfsMaxPeriod {
+			return 0, linuxerr.EINVAL
+		}
+		f.c.cfsPeriod.Store(val)
+	case cpuCFSQuota:
+		if val < 0 {
+			val = -1
+		} else if val < cfsMinQuota {
+			return 0, linuxerr.EINVAL
+		}
+		f.c.cfsQuota.Store(val)
+	}
+	f.c.updateLimit(ctx)
+	return n, nil
+}
+
+// cpuWeightFile implements the cpu.shares and cpu.weight control files.
+//
+// +stateify savable
+type cpuWeightFile struct {
+	controllerFile
+
+	c *cpuController
+}
+
+var _ controllerFileImpl = (*cpuWeightFile)(nil)
+
+func (fs *filesystem) newCPUWeightFile(ctx context.Context, creds *auth.Credentials, c *cpuController) kernfs.Inode {
+	f := &cpuWeightFile{
+		controllerFile: controllerFile{
+			allowBackgroundAccess: true,
+		},
+		c: c,
+	}
+	f.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), f, writableFileMode)
+	return f
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (f *cpuWeightFile) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	if f.c.fs.v2 {
+		fmt.Fprintf(buf, "%d\n", f.c.weight.Load())
+	} else {
+		fmt.Fprintf(buf, "%d\n", f.c.shares.Load())
+	}
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (f *cpuWeightFile) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	return f.WriteBackground(ctx, src)
+}
+
+// WriteBackground implements writableControllerFileImpl.WriteBackground.
+func (f *cpuWeightFile) WriteBackground(ctx context.Context, src usermem.IOSequence) (int64, error) {
+	val, n, err := parseInt64FromString(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	if f.c.fs.v2 {
+		// See Linux, kernel/sched/core.c:cpu_weight_write_u64().
+		if val < 1 || val > 10000 {
+			return 0, linuxerr.ERANGE
+		}
+		f.c.weight.Store(val)
+	} else {
+		// Out of range shares are clamped. See Linux,
+		// kernel/sched/fair.c:sched_group_set_shares().
+		f.c.shares.Store(min(max(val, cpuMinShares), cpuMaxShares))
+	}
+	f.c.updateWeight(ctx)
+	return n, nil
+}
+
+// cpuBandwidthStatData implements cpu.stat on cgroup v1. On the unified
+// hierarchy, cpu.stat is provided by cpuStatData.
+//
+// +stateify savable
+type cpuBandwidthStatData struct {
+	c *cpuController
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cpuBandwidthStatData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	stats := d.c.group.Stats(kernel.KernelFromContext(ctx))
+	fmt.Fprintf(buf, "nr_periods %d\n", stats.NrPeriods)
+	fmt.Fprintf(buf, "nr_throttled %d\n", stats.NrThrottled)
+	fmt.Fprintf(buf, "throttled_time %d\n", stats.ThrottledTime)
+	return nil
 }
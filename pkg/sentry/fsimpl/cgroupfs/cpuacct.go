@@ -81,6 +81,12 @@ func (c *cpuacctController) Clone() controller {
 // AddControlFiles implements controller.AddControlFiles.
 func (c *cpuacctController) AddControlFiles(ctx context.Context, creds *auth.Credentials, cg *cgroupInode, contents map[string]kernfs.Inode) {
 	cpuacctCG := &cpuacctCgroup{cg}
+	if c.fs.v2 {
+		// CPU usage accounting is always enabled on the unified hierarchy,
+		// and is reported through cpu.stat.
+		contents["cpu.stat"] = c.fs.newControllerFile(ctx, creds, &cpuStatData{cpuacctCG}, true)
+		return
+	}
 	contents["cpuacct.stat"] = c.fs.newControllerFile(ctx, creds, &cpuacctStatData{cpuacctCG}, true)
 	contents["cpuacct.usage"] = c.fs.newControllerFile(ctx, creds, &cpuacctUsageData{cpuacctCG}, true)
 	contents["cpuacct.usage_user"] = c.fs.newControllerFile(ctx, creds, &cpuacctUsageUserData{cpuacctCG}, true)
@@ -213,3 +219,27 @@ func (d *cpuacctUsageSysData) Generate(ctx context.Context, buf *bytes.Buffer) e
 	fmt.Fprintf(buf, "%d\n", cs.SysTime.Nanoseconds())
 	return nil
 }
+
+// cpuStatData implements cpu.stat on the unified hierarchy.
+//
+// +stateify savable
+type cpuStatData struct {
+	*cpuacctCgroup
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cpuStatData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	cs := d.collectCPUStats()
+	fmt.Fprintf(buf, "usage_usec %d\n", (cs.UserTime + cs.SysTime).Microseconds())
+	fmt.Fprintf(buf, "user_usec %d\n", cs.UserTime.Microseconds())
+	fmt.Fprintf(buf, "system_usec %d\n", cs.SysTime.Microseconds())
+	// Bandwidth statistics are only reported if the cpu controller is
+	// enabled. See Linux, kernel/sched/core.c:cpu_extra_stat_show().
+	if ctl, ok := d.controllers[kernel.CgroupControllerCPU]; ok {
+		stats := ctl.(*cpuController).group.Stats(kernel.KernelFromContext(ctx))
+		fmt.Fprintf(buf, "nr_periods %d\n", stats.NrPeriods)
+		fmt.Fprintf(buf, "nr_throttled %d\n", stats.NrThrottled)
+		fmt.Fprintf(buf, "throttled_usec %d\n", stats.ThrottledTime/1000)
+	}
+	return nil
+}
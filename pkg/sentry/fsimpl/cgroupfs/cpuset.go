@@ -81,11 +81,34 @@ func (c *cpusetController) Clone() controller {
 }
 
 // AddControlFiles implements controller.AddControlFiles.
-func (c *cpusetController) AddControlFiles(ctx context.Context, creds *auth.Credentials, _ *cgroupInode, contents map[string]kernfs.Inode) {
+func (c *cpusetController) AddControlFiles(ctx context.Context, creds *auth.Credentials, cg *cgroupInode, contents map[string]kernfs.Inode) {
+	if c.fs.v2 {
+		// Read-only, as otherwise a writer with CAP_DAC_OVERRIDE could
+		// still write through the source.
+		contents["cpuset.cpus.effective"] = c.fs.newControllerFile(ctx, creds, &cpusetEffectiveData{&cpusData{c: c}}, true)
+		contents["cpuset.mems.effective"] = c.fs.newControllerFile(ctx, creds, &cpusetEffectiveData{&memsData{c: c}}, true)
+		if cg.parent == nil {
+			return
+		}
+	}
 	contents["cpuset.cpus"] = c.fs.newControllerWritableFile(ctx, creds, &cpusData{c: c}, true)
 	contents["cpuset.mems"] = c.fs.newControllerWritableFile(ctx, creds, &memsData{c: c}, true)
 }
 
+// cpusetEffectiveData implements the read-only cpuset.*.effective files on the
+// unified hierarchy. Since the configured masks are always honored, they're
+// identical to the configured ones.
+//
+// +stateify savable
+type cpusetEffectiveData struct {
+	src vfs.DynamicBytesSource
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *cpusetEffectiveData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	return d.src.Generate(ctx, buf)
+}
+
 // +stateify savable
 type cpusData struct {
 	c *cpusetController
@@ -18,16 +18,41 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"strings"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/atomicbitops"
 	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
 )
 
+// oomVictimTimeout is how long the OOM handler waits for an OOM victim to
+// exit and release its memory before failed allocations are retried.
+const oomVictimTimeout = time.Second
+
+// memoryController enforces memory limits on the memory cgroup. Allocations
+// are charged to the task's memory cgroup by the MemoryFile, using a
+// pgalloc.MemoryCgroupCounter for each memory cgroup; when a charge would
+// exceed the limit of the cgroup or one of its ancestors, the charge fails
+// and the controller whose limit would be exceeded handles the out-of-memory
+// condition by reclaiming evictable memory such as page cache charged to its
+// cgroup, and then killing the task with the highest OOM badness in the
+// cgroup.
+//
+// Charges count allocated memory, whether or not it has been committed, so
+// usage may be somewhat higher than in Linux, which charges pages as they
+// are faulted in.
+//
 // +stateify savable
 type memoryController struct {
 	controllerCommon
@@ -38,11 +63,58 @@ type memoryController struct {
 	moveChargeAtImmigrate atomicbitops.Int64
 	pressureLevel         int64
 
+	// highBytes is the memory.high throttle limit on the unified hierarchy.
+	highBytes atomicbitops.Int64
+
+	// Counters reported by memory.events on the unified hierarchy.
+	lowEvents     atomicbitops.Uint64
+	highEvents    atomicbitops.Uint64
+	maxEvents     atomicbitops.Uint64
+	oomEvents     atomicbitops.Uint64
+	oomKillEvents atomicbitops.Uint64
+
+	// oomKillDisable and underOOM are reported by memory.oom_control on
+	// cgroup v1. If oomKillDisable is non-zero, tasks whose allocations fail
+	// wait for memory to be freed instead of triggering the OOM killer.
+	oomKillDisable atomicbitops.Uint32
+	underOOM       atomicbitops.Uint32
+
+	// oomMu protects oom.
+	oomMu sync.Mutex `state:"nosave"`
+
+	// oom is the out-of-memory condition currently being handled in this
+	// cgroup, or nil if there is none. Tasks waiting for the OOM to be
+	// handled are interrupted by save, and retry their allocations after
+	// restore.
+	oom *memoryOOM `state:"nosave"`
+
+	// oomWaiting is true while the OOM handler is waiting for memory to be
+	// freed in this cgroup. It allows wakeOOM, which is called whenever
+	// memory is uncharged, to avoid locking oomMu otherwise.
+	oomWaiting atomicbitops.Bool `state:"nosave"`
+
+	// events is the memory.events control file, or nil if the controller
+	// isn't attached to the unified hierarchy or belongs to the root cgroup.
+	events *eventsFile
+
 	// memCg is the memory cgroup for this controller.
 	memCg *memoryCgroup
+
+	// parent is the memory controller of the parent cgroup, or nil for the
+	// root cgroup.
+	parent *memoryController `state:"wait"`
+
+	// k is the kernel that owns the cgroup.
+	k *kernel.Kernel
+
+	// counter is charged for memory allocated by tasks in the cgroup and its
+	// descendants. counter is immutable after it is created by
+	// AddControlFiles, or by afterLoad after restore.
+	counter *pgalloc.MemoryCgroupCounter `state:"nosave"`
 }
 
 var _ controller = (*memoryController)(nil)
+var _ pgalloc.MemoryCgroupEvents = (*memoryController)(nil)
 
 func newMemoryController(fs *filesystem, defaults map[string]int64) *memoryController {
 	c := &memoryController{
@@ -52,6 +124,7 @@ func newMemoryController(fs *filesystem, defaults map[string]int64) *memoryContr
 
 		limitBytes:     atomicbitops.FromInt64(math.MaxInt64),
 		softLimitBytes: atomicbitops.FromInt64(math.MaxInt64),
+		highBytes:      atomicbitops.FromInt64(math.MaxInt64),
 	}
 
 	consumeDefault := func(name string, valPtr *atomicbitops.Int64) {
@@ -75,6 +148,7 @@ func (c *memoryController) Clone() controller {
 		limitBytes:            atomicbitops.FromInt64(c.limitBytes.Load()),
 		softLimitBytes:        atomicbitops.FromInt64(c.softLimitBytes.Load()),
 		moveChargeAtImmigrate: atomicbitops.FromInt64(c.moveChargeAtImmigrate.Load()),
+		highBytes:             atomicbitops.FromInt64(c.highBytes.Load()),
 	}
 	new.controllerCommon.cloneFromParent(c)
 	return new
@@ -83,13 +157,82 @@ func (c *memoryController) Clone() controller {
 // AddControlFiles implements controller.AddControlFiles.
 func (c *memoryController) AddControlFiles(ctx context.Context, creds *auth.Credentials, cg *cgroupInode, contents map[string]kernfs.Inode) {
 	c.memCg = &memoryCgroup{cg}
+	c.k = kernel.KernelFromContext(ctx)
+	if cg.parent != nil {
+		c.parent = cg.parent.controllers[kernel.CgroupControllerMemory].(*memoryController)
+	}
+	c.initCounter(c.k.MemoryFile())
+	if c.fs.v2 {
+		contents["memory.stat"] = c.fs.newControllerFile(ctx, creds, &memoryStatData{memCg: &memoryCgroup{cg}}, true)
+		if cg.parent != nil {
+			contents["memory.current"] = c.fs.newControllerFile(ctx, creds, &memoryUsageInBytesData{memCg: &memoryCgroup{cg}}, true)
+			contents["memory.max"] = c.fs.newMemoryLimitFile(ctx, creds, c, &c.limitBytes)
+			contents["memory.high"] = c.fs.newMemoryLimitFile(ctx, creds, c, &c.highBytes)
+			c.events = c.fs.newEventsFile(ctx, creds, &memoryEventsData{c})
+			contents["memory.events"] = c.events
+		}
+		return
+	}
 	contents["memory.usage_in_bytes"] = c.fs.newControllerFile(ctx, creds, &memoryUsageInBytesData{memCg: &memoryCgroup{cg}}, true)
-	contents["memory.limit_in_bytes"] = c.fs.newStubControllerFile(ctx, creds, &c.limitBytes, true)
+	contents["memory.limit_in_bytes"] = c.fs.newMemoryLimitFile(ctx, creds, c, &c.limitBytes)
+	contents["memory.failcnt"] = c.fs.newControllerFile(ctx, creds, &memoryFailcntData{c: c}, true)
+	contents["memory.oom_control"] = c.fs.newMemoryOOMControlFile(ctx, creds, c)
 	contents["memory.soft_limit_in_bytes"] = c.fs.newStubControllerFile(ctx, creds, &c.softLimitBytes, true)
 	contents["memory.move_charge_at_immigrate"] = c.fs.newStubControllerFile(ctx, creds, &c.moveChargeAtImmigrate, true)
 	contents["memory.pressure_level"] = c.fs.newStaticControllerFile(ctx, creds, linux.FileMode(0644), fmt.Sprintf("%d\n", c.pressureLevel))
 }
 
+// afterLoad is invoked by stateify.
+func (c *memoryController) afterLoad(ctx context.Context) {
+	// Counters aren't saved. Recreate c.counter, which is charged for memory
+	// restored in the MemoryFile when it's registered.
+	c.initCounter(pgalloc.MemoryFileFromContext(ctx))
+}
+
+// initCounter creates c.counter and registers it with mf, if mf is not nil.
+//
+// Preconditions: c.parent.counter has been created.
+func (c *memoryController) initCounter(mf *pgalloc.MemoryFile) {
+	var parent *pgalloc.MemoryCgroupCounter
+	if c.parent != nil {
+		parent = c.parent.counter
+	}
+	c.counter = pgalloc.NewMemoryCgroupCounter(parent, c)
+	c.updateCounterLimits()
+	if mf != nil {
+		mf.SetMemoryCgroupCounter(c.memCg.ID(), c.counter)
+	}
+}
+
+// updateCounterLimits propagates c's limits to c.counter.
+func (c *memoryController) updateCounterLimits() {
+	c.counter.SetLimit(uint64(c.limitBytes.Load()))
+	c.counter.SetHigh(uint64(c.highBytes.Load()))
+}
+
+// release unregisters c.counter when c's cgroup is destroyed.
+func (c *memoryController) release() {
+	if mf := c.k.MemoryFile(); mf != nil {
+		mf.RemoveMemoryCgroupCounter(c.memCg.ID())
+	}
+}
+
+// OnHigh implements pgalloc.MemoryCgroupEvents.OnHigh.
+func (c *memoryController) OnHigh() {
+	c.highEvents.Add(1)
+}
+
+// OnLimit implements pgalloc.MemoryCgroupEvents.OnLimit.
+func (c *memoryController) OnLimit(length uint64) pgalloc.MemoryCgroupOOM {
+	c.maxEvents.Add(1)
+	return c.startOOM(length)
+}
+
+// OnUncharge implements pgalloc.MemoryCgroupEvents.OnUncharge.
+func (c *memoryController) OnUncharge() {
+	c.wakeOOM()
+}
+
 // Enter implements controller.Enter.
 func (c *memoryController) Enter(t *kernel.Task) {
 	// Update the new cgroup id for the task.
@@ -100,6 +243,11 @@ func (c *memoryController) Enter(t *kernel.Task) {
 func (c *memoryController) Leave(t *kernel.Task) {
 	// Update the cgroup id for the task to zero.
 	t.SetMemCgID(0)
+	// An exiting task leaves its cgroups after releasing its MM, which may
+	// end an OOM in this cgroup or one of its ancestors.
+	for mc := c; mc != nil; mc = mc.parent {
+		mc.wakeOOM()
+	}
 }
 
 // PrepareMigrate implements controller.PrepareMigrate.
@@ -158,3 +306,355 @@ func (d *memoryUsageInBytesData) Generate(ctx context.Context, buf *bytes.Buffer
 	fmt.Fprintf(buf, "%d\n", totalBytes)
 	return nil
 }
+
+// +stateify savable
+type memoryStatData struct {
+	memCg *memoryCgroup
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *memoryStatData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	k := kernel.KernelFromContext(ctx)
+
+	memCgIDs := make(map[uint32]struct{})
+	d.memCg.collectMemCgIDs(memCgIDs)
+	k.MemoryFile().UpdateUsage(memCgIDs)
+	var stats usage.MemoryStats
+	for id := range memCgIDs {
+		s, _ := usage.MemoryAccounting.CopyPerCg(id)
+		stats.System += s.System
+		stats.Anonymous += s.Anonymous
+		stats.PageCache += s.PageCache
+		stats.Tmpfs += s.Tmpfs
+		stats.Mapped += s.Mapped
+		stats.Ramdiskfs += s.Ramdiskfs
+	}
+	fmt.Fprintf(buf, "anon %d\n", stats.Anonymous)
+	fmt.Fprintf(buf, "file %d\n", stats.PageCache+stats.Tmpfs+stats.Ramdiskfs)
+	fmt.Fprintf(buf, "kernel %d\n", stats.System)
+	fmt.Fprintf(buf, "shmem %d\n", stats.Tmpfs)
+	fmt.Fprintf(buf, "file_mapped %d\n", stats.Mapped)
+	return nil
+}
+
+// +stateify savable
+type memoryEventsData struct {
+	c *memoryController
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *memoryEventsData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	fmt.Fprintf(buf, "low %d\n", d.c.lowEvents.Load())
+	fmt.Fprintf(buf, "high %d\n", d.c.highEvents.Load())
+	fmt.Fprintf(buf, "max %d\n", d.c.maxEvents.Load())
+	fmt.Fprintf(buf, "oom %d\n", d.c.oomEvents.Load())
+	fmt.Fprintf(buf, "oom_kill %d\n", d.c.oomKillEvents.Load())
+	return nil
+}
+
+// +stateify savable
+type memoryFailcntData struct {
+	c *memoryController
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *memoryFailcntData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	fmt.Fprintf(buf, "%d\n", d.c.maxEvents.Load())
+	return nil
+}
+
+// memoryLimitFile implements the memory.limit_in_bytes, memory.max and
+// memory.high control files.
+//
+// +stateify savable
+type memoryLimitFile struct {
+	controllerFile
+
+	// c is the controller whose limit is set by the file.
+	c *memoryController
+
+	// limit is one of c's limits, and is accessed through atomic ops.
+	limit *atomicbitops.Int64
+
+	// v2 indicates the limit is read and written as a non-negative integer
+	// or "max", as on cgroup v2. Otherwise, -1 may be written for no limit.
+	v2 bool
+}
+
+var _ controllerFileImpl = (*memoryLimitFile)(nil)
+
+// newMemoryLimitFile creates a new control file that loads and stores a
+// memory limit of c from limit.
+func (fs *filesystem) newMemoryLimitFile(ctx context.Context, creds *auth.Credentials, c *memoryController, limit *atomicbitops.Int64) kernfs.Inode {
+	f := &memoryLimitFile{
+		controllerFile: controllerFile{
+			allowBackgroundAccess: true,
+		},
+		c:     c,
+		limit: limit,
+		v2:    fs.v2,
+	}
+	f.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), f, writableFileMode)
+	return f
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (f *memoryLimitFile) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	if val := f.limit.Load(); !f.v2 || val < math.MaxInt64 {
+		fmt.Fprintf(buf, "%d\n", val)
+	} else {
+		fmt.Fprintf(buf, "max\n")
+	}
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (f *memoryLimitFile) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	return f.WriteBackground(ctx, src)
+}
+
+// WriteBackground implements writableControllerFileImpl.WriteBackground.
+func (f *memoryLimitFile) WriteBackground(ctx context.Context, src usermem.IOSequence) (int64, error) {
+	str, n, err := copyInString(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	var val int64
+	if str = strings.TrimSpace(str); !f.v2 && str == "-1" {
+		// On cgroup v1, -1 removes the limit.
+		val = math.MaxInt64
+	} else if val, err = parseMaxValue(str, math.MaxInt64); err != nil {
+		return 0, err
+	}
+	f.limit.Store(val)
+	f.c.updateCounterLimits()
+	f.c.wakeOOM()
+	return n, nil
+}
+
+// memoryOOMControlFile implements the memory.oom_control control file.
+//
+// +stateify savable
+type memoryOOMControlFile struct {
+	controllerFile
+
+	c *memoryController
+}
+
+var _ controllerFileImpl = (*memoryOOMControlFile)(nil)
+
+// newMemoryOOMControlFile creates the memory.oom_control control file for c.
+func (fs *filesystem) newMemoryOOMControlFile(ctx context.Context, creds *auth.Credentials, c *memoryController) kernfs.Inode {
+	f := &memoryOOMControlFile{
+		controllerFile: controllerFile{
+			allowBackgroundAccess: true,
+		},
+		c: c,
+	}
+	f.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), f, writableFileMode)
+	return f
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (f *memoryOOMControlFile) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	fmt.Fprintf(buf, "oom_kill_disable %d\n", f.c.oomKillDisable.Load())
+	fmt.Fprintf(buf, "under_oom %d\n", f.c.underOOM.Load())
+	fmt.Fprintf(buf, "oom_kill %d\n", f.c.oomKillEvents.Load())
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (f *memoryOOMControlFile) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	return f.WriteBackground(ctx, src)
+}
+
+// WriteBackground implements writableControllerFileImpl.WriteBackground.
+func (f *memoryOOMControlFile) WriteBackground(ctx context.Context, src usermem.IOSequence) (int64, error) {
+	val, n, err := parseInt64FromString(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	if val != 0 && val != 1 {
+		return 0, linuxerr.EINVAL
+	}
+	f.c.oomKillDisable.Store(uint32(val))
+	f.c.wakeOOM()
+	return n, nil
+}
+
+// overLimit returns true if length bytes can't be charged to c's cgroup
+// without exceeding its limit.
+func (c *memoryController) overLimit(length uint64) bool {
+	return c.counter.Usage()+length > uint64(c.limitBytes.Load())
+}
+
+// notifyEvents notifies watchers of memory.events of a change.
+func (c *memoryController) notifyEvents(ctx context.Context) {
+	if c.events != nil {
+		c.events.notify(ctx)
+	}
+}
+
+// startOOM returns the out-of-memory condition being handled in c, starting to
+// handle a new one if necessary. length is the size of the allocation that
+// failed.
+func (c *memoryController) startOOM(length uint64) *memoryOOM {
+	c.oomMu.Lock()
+	defer c.oomMu.Unlock()
+	if c.oom == nil {
+		c.oom = &memoryOOM{
+			done: make(chan struct{}),
+			wake: make(chan struct{}, 1),
+		}
+		go c.handleOOM(c.oom, length) // S/R-SAFE: see memoryController.oom.
+	}
+	return c.oom
+}
+
+// wakeOOM wakes the OOM handler if it is waiting for memory to be freed in c.
+// wakeOOM may be called with MemoryFile locks held, so it must not block.
+func (c *memoryController) wakeOOM() {
+	if !c.oomWaiting.Load() {
+		return
+	}
+	c.oomMu.Lock()
+	defer c.oomMu.Unlock()
+	if c.oom != nil {
+		select {
+		case c.oom.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// handleOOM handles an out-of-memory condition in c. It first reclaims
+// evictable memory such as page cache charged to c's cgroup and its
+// descendants. If that doesn't free enough memory to
+// charge length bytes, it kills the task with the highest OOM badness in the
+// cgroup, unless the OOM killer is disabled.
+//
+// This is analogous to Linux's mm/memcontrol.c:mem_cgroup_oom().
+func (c *memoryController) handleOOM(oom *memoryOOM, length uint64) {
+	defer func() {
+		c.oomMu.Lock()
+		c.oom = nil
+		c.oomMu.Unlock()
+		close(oom.done)
+	}()
+
+	k := c.k
+	mf := k.MemoryFile()
+	mf.StartMemoryCgroupEvictions(c.counter)
+	mf.WaitForEvictions()
+	if !c.overLimit(length) {
+		oom.retry = true
+		return
+	}
+
+	ctx := k.SupervisorContext()
+	c.oomEvents.Add(1)
+	c.notifyEvents(ctx)
+	if c.oomKillDisable.Load() != 0 {
+		// Wait until memory is freed or the limit is raised, or the OOM killer
+		// is enabled again.
+		c.underOOM.Store(1)
+		c.oomWaiting.Store(true)
+		for c.oomKillDisable.Load() != 0 && c.overLimit(length) {
+			<-oom.wake
+		}
+		c.oomWaiting.Store(false)
+		c.underOOM.Store(0)
+		if !c.overLimit(length) {
+			oom.retry = true
+			return
+		}
+	}
+
+	victim := c.selectOOMVictim(k)
+	if victim == nil {
+		log.Warningf("Memory cgroup %d is out of memory, but has no killable task", c.memCg.ID())
+		return
+	}
+	tid := k.TaskSet().Root.IDOfTask(victim)
+	log.Infof("Memory cgroup %d is out of memory: killing task %d (%s) in container %q", c.memCg.ID(), tid, victim.Name(), victim.ContainerID())
+	if err := victim.ThreadGroup().SendSignal(kernel.SignalInfoPriv(linux.SIGKILL)); err != nil {
+		log.Warningf("Failed to kill OOM victim %d: %v", tid, err)
+		return
+	}
+	c.oomKillEvents.Add(1)
+	c.notifyEvents(ctx)
+
+	// Wait for the victim to release its memory, so that retried allocations
+	// don't select another victim for the same OOM. The victim leaves its
+	// cgroups after releasing its MM.
+	timeout := time.NewTimer(oomVictimTimeout)
+	defer timeout.Stop()
+	c.oomWaiting.Store(true)
+	defer c.oomWaiting.Store(false)
+	for victim.MemCgID() != 0 && c.overLimit(length) {
+		select {
+		case <-oom.wake:
+		case <-timeout.C:
+			log.Warningf("OOM victim %d in memory cgroup %d did not exit within %v", tid, c.memCg.ID(), oomVictimTimeout)
+			oom.retry = true
+			return
+		}
+	}
+	oom.retry = true
+}
+
+// selectOOMVictim returns the task with the highest OOM badness in c's cgroup
+// and its descendants, or nil if there is no killable task.
+//
+// This is analogous to Linux's mm/oom_kill.c:select_bad_process().
+func (c *memoryController) selectOOMVictim(k *kernel.Kernel) *kernel.Task {
+	totalBytes := uint64(c.limitBytes.Load())
+	globalInit := k.GlobalInit()
+	var (
+		victim     *kernel.Task
+		maxBadness int64 = math.MinInt64
+	)
+	var visit func(cg *cgroupInode)
+	visit = func(cg *cgroupInode) {
+		for _, t := range cg.tasks() {
+			// Killing the global init would take down the whole sandbox.
+			if t.ExitState() != kernel.TaskExitNone || t.ThreadGroup() == globalInit {
+				continue
+			}
+			if badness := t.OOMBadness(totalBytes); badness > maxBadness {
+				victim = t
+				maxBadness = badness
+			}
+		}
+		cg.forEachChildDir(func(d *dir) {
+			visit(d.cgi)
+		})
+	}
+	visit(c.memCg.cgroupInode)
+	return victim
+}
+
+// memoryOOM implements pgalloc.MemoryCgroupOOM.
+type memoryOOM struct {
+	// done is closed when the out-of-memory condition has been handled.
+	done chan struct{}
+
+	// retry is true if allocations that failed due to the out-of-memory
+	// condition should be retried. retry is immutable after done is closed.
+	retry bool
+
+	// wake is signalled by memoryController.wakeOOM when memory may have been
+	// freed, the cgroup's limit may have been raised, or the OOM killer may
+	// have been enabled.
+	wake chan struct{}
+}
+
+// Wait implements pgalloc.MemoryCgroupOOM.Wait.
+func (o *memoryOOM) Wait(ctx context.Context) bool {
+	if err := ctx.Block(o.done); err != nil {
+		// Retry the allocation after the interruption has been handled; if
+		// the task has been killed, it will exit instead.
+		return true
+	}
+	return o.retry
+}
@@ -26,8 +26,10 @@ import (
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/erofs"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/fsutil"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/sync"
 )
@@ -68,6 +70,10 @@ type filesystem struct {
 	// mf implements memmap.File for this image.
 	mf imageMemmapFile
 
+	// memFile is used to allocate the page cache of files that can't be
+	// mapped directly from the image.
+	memFile *pgalloc.MemoryFile `state:"nosave"`
+
 	// inodeBuckets contains the inodes in use. Multiple buckets are used to
 	// reduce the lock contention. Bucket is chosen based on the hash calculation
 	// on nid in filesystem.inodeBucket.
@@ -132,6 +138,7 @@ func (fstype FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		image:    image,
 		devMinor: devMinor,
 		mf:       imageMemmapFile{image: image},
+		memFile:  pgalloc.MemoryFileFromContext(ctx),
 	}
 	fs.vfsfs.Init(vfsObj, &fstype, fs)
 	cu.Add(func() { fs.vfsfs.DecRef(ctx) })
@@ -275,6 +282,15 @@ type inode struct {
 	// +checklocks:mapsMu
 	mappings memmap.MappingSet
 
+	// dataMu protects cache.
+	dataMu sync.Mutex `state:"nosave"`
+
+	// cache caches the decoded file data of regular files that can't be
+	// mapped directly from the image. Its contents are dropped before save,
+	// see inode.InvalidateUnsavable.
+	// +checklocks:dataMu
+	cache fsutil.FileRangeSet
+
 	// locks supports POSIX and BSD style locks.
 	locks vfs.FileLocks
 
@@ -316,6 +332,9 @@ func (i *inode) DecRef(ctx context.Context) {
 	i.inodeRefs.DecRef(func() {
 		nid := i.Nid()
 		i.fs.inodeBucket(nid).removeInode(nid)
+		if i.IsRegular() && !i.directlyMappable() {
+			i.dropCache()
+		}
 	})
 }
 
@@ -442,6 +461,9 @@ func (d *dentry) Watches() *vfs.Watches {
 // OnZeroWatches implements vfs.DentryImpl.OnZeroWatches.
 func (d *dentry) OnZeroWatches(ctx context.Context) {}
 
+// OnFirstWatch implements vfs.DentryImpl.OnFirstWatch.
+func (d *dentry) OnFirstWatch(ctx context.Context) {}
+
 func (d *dentry) open(ctx context.Context, rp *vfs.ResolvingPath, opts *vfs.OpenOptions) (*vfs.FileDescription, error) {
 	ats := vfs.AccessTypesForOpenFlags(opts)
 	if err := d.inode.checkPermissions(rp.Credentials(), ats); err != nil {
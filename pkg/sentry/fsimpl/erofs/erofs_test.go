@@ -0,0 +1,230 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/erofs"
+	"gvisor.dev/gvisor/pkg/erofs/erofstest"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/safemem"
+	"gvisor.dev/gvisor/pkg/sentry/contexttest"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+// testFile is a file added to the test image.
+type testFile struct {
+	name string
+	data []byte
+}
+
+// newTestFiles adds files of each supported data layout to b and returns
+// them.
+func newTestFiles(b *erofstest.Builder) []testFile {
+	data := func(n int, seed string) []byte {
+		return []byte(strings.Repeat(seed, n/len(seed)+1)[:n])
+	}
+
+	plain := data(2*erofstest.BlockSize+100, "plain ")
+	b.AddFile("plain", plain)
+
+	inline := data(erofstest.BlockSize+100, "inline ")
+	b.AddInlineFile("inline", inline)
+
+	// The second chunk is a hole.
+	chunked := data(3*erofstest.BlockSize+100, "chunked ")
+	copy(chunked[erofstest.BlockSize:], make([]byte, erofstest.BlockSize))
+	b.AddChunkedFile("chunked", chunked, 0 /* chunkBlockBits */, true /* indexes */)
+
+	head := data(2*erofstest.BlockSize, "compressed ")
+	tail := data(1000, "tail ")
+	compressed := append(append([]byte(nil), head...), tail...)
+	b.AddCompressedFile("compressed", [2]uint8{erofs.CompressionLZ4, erofs.CompressionLZ4}, []erofstest.ZExtent{
+		{Type: erofs.LclusterTypeHead1, Data: head, Compressed: erofstest.LZ4Compress(head)},
+		{Type: erofs.LclusterTypeHead1, Data: tail, Compressed: erofstest.LZ4Compress(tail)},
+	}, true /* inlineTail */)
+
+	return []testFile{
+		{name: "plain", data: plain},
+		{name: "inline", data: inline},
+		{name: "chunked", data: chunked},
+		{name: "compressed", data: compressed},
+	}
+}
+
+// newErofsRoot mounts the image built by b, and returns the root. cleanup
+// must be called when the root is no longer needed.
+func newErofsRoot(t *testing.T, ctx context.Context, b *erofstest.Builder) (*vfs.VirtualFilesystem, vfs.VirtualDentry, func()) {
+	t.Helper()
+	creds := auth.CredentialsFromContext(ctx)
+
+	f := b.WriteImage(t)
+	defer f.Close()
+	// The filesystem takes ownership of the image FD.
+	ifd, err := unix.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("dup failed: %v", err)
+	}
+
+	vfsObj := &vfs.VirtualFilesystem{}
+	if err := vfsObj.Init(ctx); err != nil {
+		t.Fatalf("VFS init: %v", err)
+	}
+	vfsObj.MustRegisterFilesystemType(Name, FilesystemType{}, &vfs.RegisterFilesystemTypeOptions{
+		AllowUserMount: true,
+	})
+	mntns, err := vfsObj.NewMountNamespace(ctx, creds, "", Name, &vfs.MountOptions{
+		GetFilesystemOptions: vfs.GetFilesystemOptions{
+			Data: fmt.Sprintf("%s=%d", moptImageFD, ifd),
+		},
+	}, nil)
+	if err != nil {
+		unix.Close(ifd)
+		t.Fatalf("failed to create erofs root mount: %v", err)
+	}
+	root := mntns.Root(ctx)
+	return vfsObj, root, func() {
+		root.DecRef(ctx)
+		mntns.DecRef(ctx)
+	}
+}
+
+func openFile(t *testing.T, ctx context.Context, vfsObj *vfs.VirtualFilesystem, root vfs.VirtualDentry, name string) *vfs.FileDescription {
+	t.Helper()
+	fd, err := vfsObj.OpenAt(ctx, auth.CredentialsFromContext(ctx), &vfs.PathOperation{
+		Root:  root,
+		Start: root,
+		Path:  fspath.Parse(name),
+	}, &vfs.OpenOptions{
+		Flags: linux.O_RDONLY,
+	})
+	if err != nil {
+		t.Fatalf("failed to open %q: %v", name, err)
+	}
+	return fd
+}
+
+func TestRead(t *testing.T) {
+	ctx := contexttest.Context(t)
+	b := erofstest.NewBuilder()
+	files := newTestFiles(b)
+	vfsObj, root, cleanup := newErofsRoot(t, ctx, b)
+	defer cleanup()
+
+	for _, tf := range files {
+		t.Run(tf.name, func(t *testing.T) {
+			fd := openFile(t, ctx, vfsObj, root, tf.name)
+			defer fd.DecRef(ctx)
+
+			if got, want := fd.Impl().(*regularFileFD).inode().directlyMappable(), tf.name == "plain"; got != want {
+				t.Errorf("directlyMappable got %t, want %t", got, want)
+			}
+
+			size := int64(len(tf.data))
+			for _, r := range []struct {
+				off int64
+				len int64
+			}{
+				{0, size},
+				// Across a block boundary.
+				{erofstest.BlockSize - 10, 20},
+				// Within the tail.
+				{size - 50, 40},
+				// Beyond EOF.
+				{size - 10, 100},
+			} {
+				buf := make([]byte, r.len)
+				n, err := fd.PRead(ctx, usermem.BytesIOSequence(buf), r.off, vfs.ReadOptions{})
+				if err != nil && err != io.EOF {
+					t.Fatalf("PRead(%d, %d) failed: %v", r.off, r.len, err)
+				}
+				want := tf.data[r.off:min(r.off+r.len, size)]
+				if !bytes.Equal(buf[:n], want) {
+					t.Errorf("PRead(%d, %d) got %d bytes that don't match the file data", r.off, r.len, n)
+				}
+			}
+
+			// Reads at EOF return nothing.
+			n, err := fd.PRead(ctx, usermem.BytesIOSequence(make([]byte, 10)), size, vfs.ReadOptions{})
+			if n != 0 || err != io.EOF {
+				t.Errorf("PRead at EOF got (%d, %v), want (0, EOF)", n, err)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	ctx := contexttest.Context(t)
+	b := erofstest.NewBuilder()
+	files := newTestFiles(b)
+	vfsObj, root, cleanup := newErofsRoot(t, ctx, b)
+	defer cleanup()
+
+	for _, tf := range files {
+		t.Run(tf.name, func(t *testing.T) {
+			fd := openFile(t, ctx, vfsObj, root, tf.name)
+			defer fd.DecRef(ctx)
+			i := fd.Impl().(*regularFileFD).inode()
+
+			// Translate the whole file, and read it back through the
+			// translations as a memory mapping would.
+			pgend, _ := hostarch.PageRoundUp(uint64(len(tf.data)))
+			mr := memmap.MappableRange{0, pgend}
+			ts, err := i.Translate(ctx, mr, mr, hostarch.Read)
+			if err != nil {
+				t.Fatalf("Translate failed: %v", err)
+			}
+			buf := make([]byte, pgend)
+			var end uint64
+			for _, tr := range ts {
+				if tr.Source.Start != end {
+					t.Fatalf("Translate returned non-contiguous translation at %#x, want %#x", tr.Source.Start, end)
+				}
+				bs, err := tr.File.MapInternal(tr.FileRange(), hostarch.Read)
+				if err != nil {
+					t.Fatalf("MapInternal(%v) failed: %v", tr.FileRange(), err)
+				}
+				dst := safemem.BlockSeqOf(safemem.BlockFromSafeSlice(buf[tr.Source.Start:tr.Source.End]))
+				if _, err := safemem.CopySeq(dst, bs); err != nil {
+					t.Fatalf("CopySeq failed: %v", err)
+				}
+				end = tr.Source.End
+			}
+			if end != pgend {
+				t.Fatalf("Translate covered [0, %#x), want [0, %#x)", end, pgend)
+			}
+			if !bytes.Equal(buf[:len(tf.data)], tf.data) {
+				t.Errorf("mapped data doesn't match the file data")
+			}
+
+			// Translations must not extend beyond the last page.
+			if _, err := i.Translate(ctx, memmap.MappableRange{pgend, pgend + hostarch.PageSize}, memmap.MappableRange{pgend, pgend + hostarch.PageSize}, hostarch.Read); err == nil {
+				t.Errorf("Translate beyond EOF succeeded")
+			}
+		})
+	}
+}
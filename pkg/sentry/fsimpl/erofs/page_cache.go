@@ -0,0 +1,230 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erofs
+
+import (
+	"io"
+
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/erofs"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/safemem"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+	"gvisor.dev/gvisor/pkg/sentry/usage"
+)
+
+// Regular files whose data isn't stored contiguously in the image (i.e.
+// compressed, chunk-based and tail-packed files) can't be mapped directly
+// from the image. Their data is decoded into the page cache, which is backed
+// by the sentry's MemoryFile, and both reads and memory mappings are served
+// from there. Cached pages that aren't memory-mapped are evictable, so the
+// page cache doubles as a bounded cache of decompressed blocks.
+
+// directlyMappable returns true if the file data of i can be mapped directly
+// from the image.
+func (i *inode) directlyMappable() bool {
+	return i.DataLayout() == erofs.InodeDataLayoutFlatPlain
+}
+
+// readToBlocksAt reads the file data of i at offset off into dsts, decoding
+// it as necessary. It never caches anything.
+func (i *inode) readToBlocksAt(ctx context.Context, dsts safemem.BlockSeq, off uint64) (uint64, error) {
+	var (
+		done uint64
+		buf  []byte
+	)
+	image := i.fs.image
+	size := i.Size()
+	for !dsts.IsEmpty() && off < size {
+		ext, err := i.MapBlocks(off)
+		if err != nil {
+			return done, err
+		}
+		extOff := off - ext.LogicalOff
+		n := min(ext.LogicalLen-extOff, dsts.NumBytes())
+
+		var cp uint64
+		switch {
+		case !ext.Mapped():
+			// Holes read as zeroes.
+			cp, err = safemem.ZeroSeq(dsts.TakeFirst64(n))
+
+		case ext.Encoded():
+			if uint64(cap(buf)) < ext.LogicalLen {
+				buf = make([]byte, ext.LogicalLen)
+			}
+			buf = buf[:ext.LogicalLen]
+			if err := image.Decompress(&ext, buf); err != nil {
+				return done, err
+			}
+			cp, err = safemem.CopySeq(dsts, safemem.BlockSeqOf(safemem.BlockFromSafeSlice(buf[extOff:extOff+n])))
+
+		default:
+			var data []byte
+			if data, err = image.ExtentData(&ext); err != nil {
+				return done, err
+			}
+			if uint64(len(data)) < extOff+n {
+				return done, io.ErrUnexpectedEOF
+			}
+			cp, err = safemem.CopySeq(dsts, safemem.BlockSeqOf(safemem.BlockFromSafeSlice(data[extOff:extOff+n])))
+		}
+		done += cp
+		off += cp
+		dsts = dsts.DropFirst64(cp)
+		if err != nil {
+			return done, err
+		}
+	}
+	return done, nil
+}
+
+// fillCacheLocked populates the page cache of i for required, and may do so
+// for the pages in optional as well.
+//
+// Preconditions:
+//   - i.dataMu must be locked.
+//   - required and optional must be page-aligned.
+//   - optional.IsSupersetOf(required).
+func (i *inode) fillCacheLocked(ctx context.Context, required, optional memmap.MappableRange) error {
+	mf := i.fs.memFile
+	_, err := i.cache.Fill(ctx, required, maxFillRange(required, optional), i.Size(), mf, pgalloc.AllocOpts{
+		Kind:    usage.PageCache,
+		MemCgID: pgalloc.MemoryCgroupIDFromContext(ctx),
+		Mode:    pgalloc.AllocateAndWritePopulate,
+	}, i.readToBlocksAt)
+	return err
+}
+
+// maxFillRange returns the range to fill into the page cache, given the
+// required and optional ranges.
+func maxFillRange(required, optional memmap.MappableRange) memmap.MappableRange {
+	const maxReadahead = 64 << 10 // 64 KB, chosen arbitrarily
+	if required.Length() >= maxReadahead {
+		return required
+	}
+	if optional.Length() <= maxReadahead {
+		return optional
+	}
+	optional.Start = required.Start
+	if optional.Length() <= maxReadahead {
+		return optional
+	}
+	optional.End = optional.Start + maxReadahead
+	return optional
+}
+
+// cachedReader implements safemem.Reader for files using the page cache.
+type cachedReader struct {
+	ctx   context.Context
+	inode *inode
+	off   uint64
+}
+
+// ReadToBlocks implements safemem.Reader.ReadToBlocks.
+func (r *cachedReader) ReadToBlocks(dsts safemem.BlockSeq) (uint64, error) {
+	if dsts.IsEmpty() {
+		return 0, nil
+	}
+	i := r.inode
+	mf := i.fs.memFile
+	i.dataMu.Lock()
+	defer i.dataMu.Unlock()
+
+	// Compute the range to read (limited by file size and overflow-checked).
+	end := i.Size()
+	if r.off >= end {
+		return 0, io.EOF
+	}
+	if rend := r.off + dsts.NumBytes(); rend > r.off && rend < end {
+		end = rend
+	}
+
+	var done uint64
+	seg, gap := i.cache.Find(r.off)
+	for r.off < end {
+		mr := memmap.MappableRange{r.off, end}
+		switch {
+		case seg.Ok():
+			// Get internal mappings from the cache.
+			ims, err := mf.MapInternal(seg.FileRangeOf(seg.Range().Intersect(mr)), hostarch.Read)
+			if err != nil {
+				return done, err
+			}
+
+			// Copy from internal mappings.
+			n, err := safemem.CopySeq(dsts, ims)
+			done += n
+			r.off += n
+			dsts = dsts.DropFirst64(n)
+			if err != nil {
+				return done, err
+			}
+
+			// Continue.
+			seg, gap = seg.NextNonEmpty()
+
+		case gap.Ok():
+			// Decode into the cache, then re-enter the loop to read from
+			// the cache.
+			gapMR := gap.Range().Intersect(mr)
+			gapEnd, _ := hostarch.PageRoundUp(gapMR.End)
+			reqMR := memmap.MappableRange{
+				Start: hostarch.PageRoundDown(gapMR.Start),
+				End:   gapEnd,
+			}
+			optMR := gap.Range()
+			err := i.fillCacheLocked(r.ctx, reqMR, optMR)
+			mf.MarkEvictable(i, pgalloc.EvictableRange{optMR.Start, optMR.End}, pgalloc.MemoryCgroupIDFromContext(r.ctx))
+			seg, gap = i.cache.Find(r.off)
+			if !seg.Ok() {
+				return done, err
+			}
+			// err might have occurred in part of gap.Range() outside gapMR.
+			// Forget about it for now; if the error matters and persists,
+			// we'll run into it again in a later iteration of this loop.
+		}
+	}
+	return done, nil
+}
+
+// Evict implements pgalloc.EvictableMemoryUser.Evict.
+func (i *inode) Evict(ctx context.Context, er pgalloc.EvictableRange) {
+	mr := memmap.MappableRange{er.Start, er.End}
+	mf := i.fs.memFile
+	i.mapsMu.Lock()
+	defer i.mapsMu.Unlock()
+	i.dataMu.Lock()
+	defer i.dataMu.Unlock()
+
+	// Only allow pages that are no longer memory-mapped to be evicted.
+	for mgap := i.mappings.LowerBoundGap(mr.Start); mgap.Ok() && mgap.Start() < mr.End; mgap = mgap.NextGap() {
+		mgapMR := mgap.Range().Intersect(mr)
+		if mgapMR.Length() == 0 {
+			continue
+		}
+		i.cache.Drop(mgapMR, mf)
+	}
+}
+
+// dropCache releases the page cache of i.
+func (i *inode) dropCache() {
+	mf := i.fs.memFile
+	mf.MarkAllUnevictable(i)
+	i.dataMu.Lock()
+	i.cache.DropAll(mf)
+	i.dataMu.Unlock()
+}
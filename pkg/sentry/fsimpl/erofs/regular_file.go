@@ -26,6 +26,7 @@ import (
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/safemem"
 	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/usermem"
 )
@@ -59,6 +60,14 @@ func (fd *regularFileFD) PRead(ctx context.Context, dst usermem.IOSequence, offs
 		return 0, nil
 	}
 
+	if i := fd.inode(); !i.directlyMappable() {
+		return dst.CopyOutFrom(ctx, &cachedReader{
+			ctx:   ctx,
+			inode: i,
+			off:   uint64(offset),
+		})
+	}
+
 	data, err := fd.inode().Data()
 	if err != nil {
 		return 0, err
@@ -136,7 +145,15 @@ func (fd *regularFileFD) ConfigureMMap(ctx context.Context, opts *memmap.MMapOpt
 // AddMapping implements memmap.Mappable.AddMapping.
 func (i *inode) AddMapping(ctx context.Context, ms memmap.MappingSpace, ar hostarch.AddrRange, offset uint64, writable bool) error {
 	i.mapsMu.Lock()
-	i.mappings.AddMapping(ms, ar, offset, writable)
+	mapped := i.mappings.AddMapping(ms, ar, offset, writable)
+	if !i.directlyMappable() {
+		// i.Evict() will refuse to evict memory-mapped pages, so tell the
+		// MemoryFile to not bother trying.
+		mf := i.fs.memFile
+		for _, r := range mapped {
+			mf.MarkUnevictable(i, pgalloc.EvictableRange{r.Start, r.End})
+		}
+	}
 	i.mapsMu.Unlock()
 	return nil
 }
@@ -144,7 +161,16 @@ func (i *inode) AddMapping(ctx context.Context, ms memmap.MappingSpace, ar hosta
 // RemoveMapping implements memmap.Mappable.RemoveMapping.
 func (i *inode) RemoveMapping(ctx context.Context, ms memmap.MappingSpace, ar hostarch.AddrRange, offset uint64, writable bool) {
 	i.mapsMu.Lock()
-	i.mappings.RemoveMapping(ms, ar, offset, writable)
+	unmapped := i.mappings.RemoveMapping(ms, ar, offset, writable)
+	if !i.directlyMappable() {
+		// Pages that are no longer referenced by any application memory
+		// mappings are now considered unused; allow MemoryFile to evict them
+		// when necessary.
+		mf := i.fs.memFile
+		for _, r := range unmapped {
+			mf.MarkEvictable(i, pgalloc.EvictableRange{r.Start, r.End}, pgalloc.MemoryCgroupIDFromContext(ctx))
+		}
+	}
 	i.mapsMu.Unlock()
 }
 
@@ -173,6 +199,9 @@ func (i *inode) Translate(ctx context.Context, required, optional memmap.Mappabl
 		})
 		return nil, &memmap.BusError{linuxerr.EROFS}
 	}
+	if !i.directlyMappable() {
+		return i.translateCached(ctx, required, optional)
+	}
 	offset, err := i.DataOffset()
 	if err != nil {
 		return nil, &memmap.BusError{err}
@@ -190,11 +219,53 @@ func (i *inode) Translate(ctx context.Context, required, optional memmap.Mappabl
 
 var inodeTranslateWriteWarnOnce sync.Once
 
+// translateCached implements memmap.Mappable.Translate for files that can't
+// be mapped directly from the image, by translating to the page cache.
+//
+// Preconditions: required and optional are within the file size (rounded up).
+func (i *inode) translateCached(ctx context.Context, required, optional memmap.MappableRange) ([]memmap.Translation, error) {
+	i.dataMu.Lock()
+	defer i.dataMu.Unlock()
+
+	mf := i.fs.memFile
+	cerr := i.fillCacheLocked(ctx, required, optional)
+
+	var ts []memmap.Translation
+	var translatedEnd uint64
+	for seg := i.cache.FindSegment(required.Start); seg.Ok() && seg.Start() < required.End; seg, _ = seg.NextNonEmpty() {
+		segMR := seg.Range().Intersect(optional)
+		ts = append(ts, memmap.Translation{
+			Source: segMR,
+			File:   mf,
+			Offset: seg.FileRangeOf(segMR).Start,
+			Perms:  hostarch.ReadExecute,
+		})
+		translatedEnd = segMR.End
+	}
+
+	// Don't return the error returned by i.cache.Fill if it occurred outside
+	// of required.
+	if translatedEnd < required.End && cerr != nil {
+		return ts, &memmap.BusError{cerr}
+	}
+	return ts, nil
+}
+
 // InvalidateUnsavable implements memmap.Mappable.InvalidateUnsavable.
 func (i *inode) InvalidateUnsavable(ctx context.Context) error {
 	i.mapsMu.Lock()
+	defer i.mapsMu.Unlock()
 	i.mappings.InvalidateAll(memmap.InvalidateOpts{})
-	i.mapsMu.Unlock()
+
+	// Discard the page cache so that it's not stored in saved state. This is
+	// safe because per InvalidateUnsavable invariants, no new translations
+	// can have been returned after we invalidated all existing translations
+	// above. The page cache will be refilled from the image on demand.
+	if !i.directlyMappable() {
+		i.dataMu.Lock()
+		i.cache.DropAll(i.fs.memFile)
+		i.dataMu.Unlock()
+	}
 	return nil
 }
 
@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"gvisor.dev/gvisor/pkg/erofs"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 )
 
@@ -40,6 +41,7 @@ func (fs *filesystem) afterLoad(ctx context.Context) {
 	// We need to update the image in place, as there are other pointers
 	// pointing to this image as well.
 	*fs.image = *newImage
+	fs.memFile = pgalloc.MemoryFileFromContext(ctx)
 }
 
 // saveParent is called by stateify.
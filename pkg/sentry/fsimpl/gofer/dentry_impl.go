@@ -254,6 +254,25 @@ func (d *dentry) destroyImpl(ctx context.Context) {
 	}
 }
 
+// addHostWatchImpl subscribes to host change notifications for d via the
+// WatchInit inotify instance. directfs dentries use a lisafs control FD since
+// watches are placed by the gofer.
+//
+// Precondition: fs.renameMu must be locked.
+func (d *dentry) addHostWatchImpl(ctx context.Context, mask uint32) (int32, error) {
+	switch dt := d.impl.(type) {
+	case *lisafsDentry:
+		return dt.controlFD.AddWatch(ctx, mask)
+	case *directfsDentry:
+		if err := dt.ensureLisafsControlFD(ctx); err != nil {
+			return 0, err
+		}
+		return dt.controlFDLisa.AddWatch(ctx, mask)
+	default:
+		panic("unknown dentry implementation")
+	}
+}
+
 // Postcondition: Caller must do dentry caching appropriately.
 //
 // +checklocksread:d.opMu
@@ -502,6 +502,7 @@ func (fs *filesystem) doCreateAt(ctx context.Context, rp *vfs.ResolvingPath, dir
 		if dir {
 			ev |= linux.IN_ISDIR
 		}
+		parent.expectHostEvents(name, uint32(ev))
 		parent.watches.Notify(ctx, name, uint32(ev), 0, vfs.InodeEvent, false /* unlinked */)
 		return nil
 	}
@@ -534,6 +535,7 @@ func (fs *filesystem) doCreateAt(ctx context.Context, rp *vfs.ResolvingPath, dir
 	if dir {
 		ev |= linux.IN_ISDIR
 	}
+	parent.expectHostEvents(name, uint32(ev))
 	parent.watches.Notify(ctx, name, uint32(ev), 0, vfs.InodeEvent, false /* unlinked */)
 	return nil
 }
@@ -699,10 +701,13 @@ func (fs *filesystem) unlinkAt(ctx context.Context, rp *vfs.ResolvingPath, dir b
 
 	// Generate inotify events for rmdir or unlink.
 	if dir {
+		parent.expectHostEvents(name, linux.IN_DELETE)
 		parent.watches.Notify(ctx, name, linux.IN_DELETE|linux.IN_ISDIR, 0, vfs.InodeEvent, true /* unlinked */)
 	} else {
+		parent.expectHostEvents(name, linux.IN_DELETE)
 		var cw *vfs.Watches
 		if child != nil {
+			child.expectHostEvents("", linux.IN_ATTRIB)
 			cw = &child.watches
 		}
 		vfs.InotifyRemoveChild(ctx, cw, &parent.watches, name)
@@ -1333,6 +1338,7 @@ func (d *dentry) createAndOpenChildLocked(ctx context.Context, rp *vfs.Resolving
 		}
 		childVFSFD = &fd.vfsfd
 	}
+	d.expectHostEvents(name, linux.IN_CREATE)
 	d.watches.Notify(ctx, name, linux.IN_CREATE, 0, vfs.PathEvent, false /* unlinked */)
 	return childVFSFD, nil
 }
@@ -1564,6 +1570,9 @@ func (fs *filesystem) RenameAt(ctx context.Context, rp *vfs.ResolvingPath, oldPa
 			newParent.incLinks()
 		}
 	}
+	oldParent.expectHostEvents(oldName, linux.IN_MOVED_FROM)
+	newParent.expectHostEvents(newName, linux.IN_MOVED_TO)
+	renamed.expectHostEvents("", linux.IN_MOVE_SELF)
 	vfs.InotifyRename(ctx, &renamed.watches, &oldParent.watches, &newParent.watches, oldName, newName, renamed.isDir())
 	return nil
 }
@@ -252,6 +252,13 @@ type filesystem struct {
 
 	// released is nonzero once filesystem.Release has been called.
 	released atomicbitops.Int32
+
+	// hostWatcher delivers host change notifications for watched dentries in
+	// InteropModeShared. It is nil until the first dentry is watched. Host
+	// watches are re-established by CompleteRestore. hostWatcher is
+	// protected by hostWatcherMu.
+	hostWatcherMu sync.Mutex   `state:"nosave"`
+	hostWatcher   *hostWatcher `state:"nosave"`
 }
 
 // +stateify savable
@@ -735,6 +742,10 @@ func (fs *filesystem) Release(ctx context.Context) {
 		fs.root.DecRef(ctx)
 	}
 
+	// Stop receiving host change notifications before the connection to the
+	// server is closed.
+	fs.stopHostWatcher()
+
 	if !fs.iopts.LeakConnection {
 		// Close the connection to the server. This implicitly closes all FDs.
 		if fs.client != nil {
@@ -1020,6 +1031,11 @@ type dentry struct {
 	// a more in-depth discussion on this matter).
 	watches vfs.Watches
 
+	// hostWD is the host inotify watch descriptor used to receive host change
+	// notifications for this dentry, or 0 if there is none. hostWD is protected
+	// by filesystem.hostWatcher.mu. See host_watch.go.
+	hostWD int32 `state:"nosave"`
+
 	// forMountpoint marks directories that were created for mount points during
 	// container startup. This is used during restore, in case these mount points
 	// need to be recreated.
@@ -1581,8 +1597,10 @@ func (d *dentry) InotifyWithParent(ctx context.Context, events, cookie uint32, e
 	d.fs.ancestryMu.RLock()
 	// The ordering below is important, Linux always notifies the parent first.
 	if parent := d.parent.Load(); parent != nil {
+		parent.expectHostEvents(d.name, events)
 		parent.watches.Notify(ctx, d.name, events, cookie, et, d.isDeleted())
 	}
+	d.expectHostEvents("", events)
 	d.watches.Notify(ctx, "", events, cookie, et, d.isDeleted())
 	d.fs.ancestryMu.RUnlock()
 }
@@ -1596,9 +1614,18 @@ func (d *dentry) Watches() *vfs.Watches {
 //
 // If no watches are left on this dentry and it has no references, cache it.
 func (d *dentry) OnZeroWatches(ctx context.Context) {
+	d.removeHostWatch(ctx)
 	d.checkCachingLocked(ctx, false /* renameMuWriteLocked */)
 }
 
+// OnFirstWatch implements vfs.DentryImpl.OnFirstWatch.
+//
+// In InteropModeShared, subscribe to host change notifications for d so that
+// changes made outside of the sandbox are reported to in-sandbox watchers.
+func (d *dentry) OnFirstWatch(ctx context.Context) {
+	d.addHostWatch(ctx)
+}
+
 // checkCachingLocked should be called after d's reference count becomes 0 or
 // it becomes disowned.
 //
@@ -1827,6 +1854,9 @@ func (d *dentry) evictLocked(ctx context.Context) {
 func (d *dentry) destroyDisconnected(ctx context.Context) {
 	mf := d.fs.mf
 
+	// Stop receiving host change notifications for d.
+	d.removeHostWatch(ctx)
+
 	d.handleMu.Lock()
 	d.dataMu.Lock()
 
@@ -0,0 +1,514 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofer
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/fdnotifier"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/lisafs"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// hostWatchMask is the set of host inotify events that watched dentries
+// subscribe to. Access and open/close events are omitted: they are very noisy
+// on shared hosts and are already generated by the sandbox for files accessed
+// through it.
+const hostWatchMask = linux.IN_MODIFY | linux.IN_ATTRIB | linux.IN_MOVED_FROM |
+	linux.IN_MOVED_TO | linux.IN_CREATE | linux.IN_DELETE | linux.IN_DELETE_SELF |
+	linux.IN_MOVE_SELF
+
+// hostCookieBit is set on rename cookies received from the host. Cookies
+// generated by the sandbox are allocated sequentially from 1, so this keeps
+// the two from colliding.
+const hostCookieBit = 1 << 31
+
+// hostWatchBufSize is the size of the buffer used to read host inotify events.
+// It fits at least one event with the longest possible name.
+const hostWatchBufSize = 16 * (unix.SizeofInotifyEvent + unix.NAME_MAX + 1)
+
+// hostEventExpiry is how long an event generated by the sandbox suppresses the
+// matching event reported by the host. It bounds how long a host event that
+// was never reported, e.g. because the host coalesced it with an identical
+// one, can cause a later host event to be dropped.
+const hostEventExpiry = time.Second
+
+// hostWatcher delivers host change notifications for a gofer filesystem in
+// InteropModeShared.
+//
+// Watched dentries subscribe to changes on the host file via the AddWatch RPC.
+// The gofer places the watch on a host inotify instance whose FD was donated
+// to the sandbox by the WatchInit RPC. hostWatcher reads events from that FD,
+// revalidates the affected dentries and forwards the events to in-sandbox
+// watchers.
+//
+// Changes made through this sandbox are also reported by the host, but
+// in-sandbox watchers have already been notified of them by the sandbox. To
+// avoid reporting them twice, events generated by the sandbox on dentries with
+// host watches are recorded by expectHostEvents, and the matching host events
+// are dropped.
+type hostWatcher struct {
+	fs *filesystem
+
+	// fd is the host inotify FD. fd is immutable.
+	fd int
+
+	// queue is notified when fd becomes readable.
+	queue waiter.Queue
+
+	// stop is closed to stop the event loop. done is closed once the event
+	// loop has stopped.
+	stop chan struct{}
+	done chan struct{}
+
+	// mu protects dentries and dentry.hostWD for all dentries in fs.
+	mu sync.Mutex
+
+	// dentries maps host watch descriptors to the dentries watching them. The
+	// host returns the same watch descriptor for hard links to the same file,
+	// so multiple dentries may share one.
+	dentries map[int32]map[*dentry]struct{}
+
+	// expected tracks events generated by the sandbox that are yet to be
+	// reported by the host. expected is protected by mu.
+	expected map[hostEventKey]expectedHostEvent
+}
+
+// hostEventKey identifies an inotify event on a dentry. name is the name of
+// the child that the event is about, or empty if the event is about the
+// dentry itself. event is a single inotify event bit.
+type hostEventKey struct {
+	d     *dentry
+	name  string
+	event uint32
+}
+
+// expectedHostEvent counts events generated by the sandbox that are yet to be
+// reported by the host.
+type expectedHostEvent struct {
+	count    int
+	deadline time.Time
+}
+
+// hostWatchesEnabled returns true if fs should subscribe to host change
+// notifications for watched dentries.
+func (fs *filesystem) hostWatchesEnabled() bool {
+	return fs.opts.interop == InteropModeShared && fs.client.IsSupported(lisafs.WatchInit)
+}
+
+// getHostWatcher returns fs.hostWatcher, starting it if necessary.
+func (fs *filesystem) getHostWatcher(ctx context.Context) (*hostWatcher, error) {
+	fs.hostWatcherMu.Lock()
+	defer fs.hostWatcherMu.Unlock()
+	if fs.hostWatcher != nil {
+		return fs.hostWatcher, nil
+	}
+
+	fd, err := fs.client.WatchInit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hw := &hostWatcher{
+		fs:       fs,
+		fd:       fd,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		dentries: make(map[int32]map[*dentry]struct{}),
+		expected: make(map[hostEventKey]expectedHostEvent),
+	}
+	if err := fdnotifier.AddFD(int32(fd), &hw.queue); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	go hw.run() // S/R-SAFE: hostWatcher is not saved.
+	fs.hostWatcher = hw
+	return hw, nil
+}
+
+// stopHostWatcher stops fs.hostWatcher, if any, and releases its resources.
+func (fs *filesystem) stopHostWatcher() {
+	fs.hostWatcherMu.Lock()
+	hw := fs.hostWatcher
+	fs.hostWatcher = nil
+	fs.hostWatcherMu.Unlock()
+	if hw == nil {
+		return
+	}
+	close(hw.stop)
+	<-hw.done
+	fdnotifier.RemoveFD(int32(hw.fd))
+	_ = unix.Close(hw.fd)
+}
+
+// addHostWatch subscribes to host change notifications for d, if enabled.
+// Failures are not fatal: in-sandbox changes are still reported.
+func (d *dentry) addHostWatch(ctx context.Context) {
+	fs := d.fs
+	if d.isSynthetic() || !fs.hostWatchesEnabled() {
+		return
+	}
+	hw, err := fs.getHostWatcher(ctx)
+	if err != nil {
+		log.Warningf("gofer.dentry.addHostWatch: failed to start host watcher: %v", err)
+		return
+	}
+
+	fs.renameMu.RLock()
+	wd, err := d.addHostWatchImpl(ctx, hostWatchMask)
+	fs.renameMu.RUnlock()
+	if err != nil {
+		// This is expected if the file was removed on the host.
+		log.Debugf("gofer.dentry.addHostWatch: AddWatch failed: %v", err)
+		return
+	}
+
+	hw.mu.Lock()
+	if d.hostWD != 0 {
+		hw.mu.Unlock()
+		return
+	}
+	// OnFirstWatch is called without inotify locks held, so the last watch on
+	// d may have been removed, and OnZeroWatches called, before the host watch
+	// was added above. OnZeroWatches locks hw.mu to remove the host watch, so
+	// checking for watches with hw.mu locked ensures that the host watch isn't
+	// leaked.
+	if d.watches.Size() == 0 {
+		_, shared := hw.dentries[wd]
+		hw.mu.Unlock()
+		if !shared {
+			if err := fs.client.RemoveWatch(ctx, wd); err != nil {
+				log.Debugf("gofer.dentry.addHostWatch: RemoveWatch(%d) failed: %v", wd, err)
+			}
+		}
+		return
+	}
+	defer hw.mu.Unlock()
+	ds, ok := hw.dentries[wd]
+	if !ok {
+		ds = make(map[*dentry]struct{})
+		hw.dentries[wd] = ds
+	}
+	ds[d] = struct{}{}
+	d.hostWD = wd
+}
+
+// removeHostWatch unsubscribes d from host change notifications.
+func (d *dentry) removeHostWatch(ctx context.Context) {
+	fs := d.fs
+	fs.hostWatcherMu.Lock()
+	hw := fs.hostWatcher
+	fs.hostWatcherMu.Unlock()
+	if hw == nil {
+		return
+	}
+
+	hw.mu.Lock()
+	wd := d.hostWD
+	if wd == 0 {
+		hw.mu.Unlock()
+		return
+	}
+	d.hostWD = 0
+	hw.forgetExpectedLocked(d)
+	ds := hw.dentries[wd]
+	delete(ds, d)
+	last := len(ds) == 0
+	if last {
+		delete(hw.dentries, wd)
+	}
+	hw.mu.Unlock()
+
+	if last {
+		// This fails with EINVAL if the host already dropped the watch, e.g.
+		// because the file was deleted.
+		if err := fs.client.RemoveWatch(ctx, wd); err != nil {
+			log.Debugf("gofer.dentry.removeHostWatch: RemoveWatch(%d) failed: %v", wd, err)
+		}
+	}
+}
+
+// restoreHostWatches re-establishes host watches for watched dentries after
+// restore. Host watches are not saved, since they belong to a host inotify
+// instance that does not survive save/restore.
+func (fs *filesystem) restoreHostWatches(ctx context.Context) {
+	if !fs.hostWatchesEnabled() {
+		return
+	}
+	for _, d := range fs.watchedDentries() {
+		d.addHostWatch(ctx)
+	}
+}
+
+// watchedDentries returns the cached dentries in fs that have watches.
+func (fs *filesystem) watchedDentries() []*dentry {
+	var watched []*dentry
+	if fs.root.watches.Size() != 0 {
+		watched = append(watched, fs.root)
+	}
+	return fs.root.appendWatchedDescendants(watched)
+}
+
+// appendWatchedDescendants appends d's cached descendants with watches to
+// watched and returns the result.
+//
+// Preconditions: d is not synthetic.
+func (d *dentry) appendWatchedDescendants(watched []*dentry) []*dentry {
+	d.childrenMu.Lock()
+	defer d.childrenMu.Unlock()
+	for _, child := range d.children {
+		if child == nil || child.isSynthetic() {
+			continue
+		}
+		if child.watches.Size() != 0 {
+			watched = append(watched, child)
+		}
+		watched = child.appendWatchedDescendants(watched)
+	}
+	return watched
+}
+
+// expectHostEvents records that the sandbox generated events on d, so that
+// the matching events reported by the host are not reported again. If name is
+// not empty, the events are about d's child with that name.
+func (d *dentry) expectHostEvents(name string, events uint32) {
+	fs := d.fs
+	if !fs.hostWatchesEnabled() {
+		return
+	}
+	fs.hostWatcherMu.Lock()
+	hw := fs.hostWatcher
+	fs.hostWatcherMu.Unlock()
+	if hw == nil {
+		return
+	}
+
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	if d.hostWD == 0 {
+		return
+	}
+	hw.expectLocked(d, name, events, time.Now())
+}
+
+// expectLocked records that the sandbox generated events on d at time now.
+//
+// Preconditions: hw.mu must be locked.
+func (hw *hostWatcher) expectLocked(d *dentry, name string, events uint32, now time.Time) {
+	deadline := now.Add(hostEventExpiry)
+	for events &= hostWatchMask; events != 0; events &= events - 1 {
+		key := hostEventKey{d: d, name: name, event: events & -events}
+		e := hw.expected[key]
+		e.count++
+		e.deadline = deadline
+		hw.expected[key] = e
+	}
+}
+
+// consumeExpectedLocked returns true if the host event described by mask on d
+// was generated by the sandbox, and should not be reported again.
+//
+// Preconditions: hw.mu must be locked.
+func (hw *hostWatcher) consumeExpectedLocked(d *dentry, name string, mask uint32, now time.Time) bool {
+	key := hostEventKey{d: d, name: name, event: mask & hostWatchMask}
+	e, ok := hw.expected[key]
+	if !ok {
+		return false
+	}
+	if e.count--; e.count == 0 || now.After(e.deadline) {
+		delete(hw.expected, key)
+	} else {
+		hw.expected[key] = e
+	}
+	return !now.After(e.deadline)
+}
+
+// pruneExpectedLocked discards expected events that were not reported by the
+// host in time.
+//
+// Preconditions: hw.mu must be locked.
+func (hw *hostWatcher) pruneExpectedLocked(now time.Time) {
+	for key, e := range hw.expected {
+		if now.After(e.deadline) {
+			delete(hw.expected, key)
+		}
+	}
+}
+
+// forgetExpectedLocked discards expected events on d.
+//
+// Preconditions: hw.mu must be locked.
+func (hw *hostWatcher) forgetExpectedLocked(d *dentry) {
+	for key := range hw.expected {
+		if key.d == d {
+			delete(hw.expected, key)
+		}
+	}
+}
+
+// run reads and handles host inotify events until hw.stop is closed.
+func (hw *hostWatcher) run() {
+	defer close(hw.done)
+
+	e, ch := waiter.NewChannelEntry(waiter.ReadableEvents)
+	hw.queue.EventRegister(&e)
+	defer hw.queue.EventUnregister(&e)
+
+	buf := make([]byte, hostWatchBufSize)
+	for {
+		select {
+		case <-hw.stop:
+			return
+		default:
+		}
+
+		n, err := unix.Read(hw.fd, buf)
+		switch err {
+		case nil:
+			hw.handleEvents(buf[:n])
+		case unix.EINTR:
+		case unix.EAGAIN:
+			select {
+			case <-ch:
+			case <-hw.stop:
+				return
+			}
+		default:
+			log.Warningf("gofer.hostWatcher.run: failed to read host inotify events: %v", err)
+			return
+		}
+	}
+}
+
+// handleEvents handles the inotify events in buf.
+func (hw *hostWatcher) handleEvents(buf []byte) {
+	ctx := context.Background()
+	for len(buf) >= unix.SizeofInotifyEvent {
+		wd := int32(hostarch.ByteOrder.Uint32(buf[0:]))
+		mask := hostarch.ByteOrder.Uint32(buf[4:])
+		cookie := hostarch.ByteOrder.Uint32(buf[8:])
+		nameLen := int(hostarch.ByteOrder.Uint32(buf[12:]))
+		buf = buf[unix.SizeofInotifyEvent:]
+		if nameLen > len(buf) {
+			log.Warningf("gofer.hostWatcher.handleEvents: truncated event: name length %d, %d bytes left", nameLen, len(buf))
+			return
+		}
+		// The name is padded with NUL bytes.
+		name := strings.TrimRight(string(buf[:nameLen]), "\x00")
+		buf = buf[nameLen:]
+		hw.handleEvent(ctx, wd, mask, cookie, name)
+	}
+	hw.mu.Lock()
+	hw.pruneExpectedLocked(time.Now())
+	hw.mu.Unlock()
+}
+
+// handleEvent revalidates the dentries watching wd and forwards the event to
+// their in-sandbox watchers.
+func (hw *hostWatcher) handleEvent(ctx context.Context, wd int32, mask, cookie uint32, name string) {
+	if mask&linux.IN_Q_OVERFLOW != 0 {
+		log.Warningf("gofer.hostWatcher.handleEvent: host inotify queue overflowed, some host changes were not reported")
+		return
+	}
+
+	fs := hw.fs
+	var ds *[]*dentry
+	fs.renameMu.RLock()
+	defer fs.renameMuRUnlockAndCheckCaching(ctx, &ds)
+
+	now := time.Now()
+	hw.mu.Lock()
+	var targets []*dentry
+	for d := range hw.dentries[wd] {
+		// Dentries can not be destroyed while renameMu is locked, but they may
+		// already have been destroyed before it was locked.
+		if d.refs.Load() == -1 {
+			continue
+		}
+		if hw.consumeExpectedLocked(d, name, mask, now) {
+			continue
+		}
+		// Hold a reference so that revalidation below can not destroy d.
+		d.IncRef()
+		targets = append(targets, d)
+	}
+	if mask&linux.IN_IGNORED != 0 {
+		// The host dropped the watch, e.g. because the file was deleted.
+		for d := range hw.dentries[wd] {
+			d.hostWD = 0
+			hw.forgetExpectedLocked(d)
+		}
+		delete(hw.dentries, wd)
+	}
+	hw.mu.Unlock()
+
+	if cookie != 0 {
+		cookie |= hostCookieBit
+	}
+	events := mask & (linux.IN_ALL_EVENTS | linux.IN_ISDIR)
+	for _, d := range targets {
+		if events != 0 {
+			hw.revalidate(ctx, d, name, events, &ds)
+			d.watches.Notify(ctx, name, events, cookie, vfs.InodeEvent, false /* unlinked */)
+		}
+		d.decRefNoCaching()
+		ds = appendDentry(ds, d)
+	}
+}
+
+// revalidate updates the cached state affected by a host event on d. If name
+// is not empty, the event is about d's child with that name.
+//
+// Precondition: fs.renameMu must be locked.
+func (hw *hostWatcher) revalidate(ctx context.Context, d *dentry, name string, events uint32, ds **[]*dentry) {
+	fs := hw.fs
+	vfsObj := fs.vfsfs.VirtualFilesystem()
+	if name != "" {
+		// Refresh d's metadata (e.g. mtime and link count) along with the
+		// cached child, which may have been replaced or removed.
+		if err := d.updateMetadata(ctx); err != nil {
+			log.Debugf("gofer.hostWatcher.revalidate: updating metadata failed: %v", err)
+			return
+		}
+		if err := fs.revalidateOne(ctx, vfsObj, d, name, ds); err != nil {
+			log.Debugf("gofer.hostWatcher.revalidate: revalidating %q failed: %v", name, err)
+		}
+		return
+	}
+	if events&(linux.IN_DELETE_SELF|linux.IN_MOVE_SELF) != 0 {
+		// d is no longer reachable at its current path. Revalidating it via its
+		// parent invalidates it.
+		if parent := d.parent.Load(); parent != nil {
+			if err := parent.updateMetadata(ctx); err != nil {
+				log.Debugf("gofer.hostWatcher.revalidate: updating metadata failed: %v", err)
+				return
+			}
+			if err := fs.revalidateOne(ctx, vfsObj, parent, d.name, ds); err != nil {
+				log.Debugf("gofer.hostWatcher.revalidate: revalidating %q failed: %v", d.name, err)
+			}
+		}
+		return
+	}
+	if err := d.updateMetadata(ctx); err != nil {
+		log.Debugf("gofer.hostWatcher.revalidate: updating metadata failed: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofer
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/lisafs"
+	"gvisor.dev/gvisor/pkg/sentry/contexttest"
+	"gvisor.dev/gvisor/pkg/sentry/ktime"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+)
+
+func newTestHostWatcher(fs *filesystem) *hostWatcher {
+	return &hostWatcher{
+		fs:       fs,
+		dentries: make(map[int32]map[*dentry]struct{}),
+		expected: make(map[hostEventKey]expectedHostEvent),
+	}
+}
+
+// hostEvent returns a host inotify event as read from the host inotify FD.
+func hostEvent(wd int32, mask uint32, name string) []byte {
+	nameLen := 0
+	if name != "" {
+		// The name is NUL-terminated and padded.
+		nameLen = (len(name)/16 + 1) * 16
+	}
+	buf := make([]byte, unix.SizeofInotifyEvent+nameLen)
+	hostarch.ByteOrder.PutUint32(buf[0:], uint32(wd))
+	hostarch.ByteOrder.PutUint32(buf[4:], mask)
+	hostarch.ByteOrder.PutUint32(buf[12:], uint32(nameLen))
+	copy(buf[unix.SizeofInotifyEvent:], name)
+	return buf
+}
+
+func TestHostEventDedup(t *testing.T) {
+	d := &dentry{}
+	hw := newTestHostWatcher(nil)
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	now := time.Now()
+
+	// Only events in hostWatchMask are expected from the host.
+	hw.expectLocked(d, "child", linux.IN_CREATE|linux.IN_MODIFY|linux.IN_ACCESS, now)
+	if hw.consumeExpectedLocked(d, "child", linux.IN_ACCESS, now) {
+		t.Errorf("IN_ACCESS was consumed, but it is not reported by the host")
+	}
+	if !hw.consumeExpectedLocked(d, "child", linux.IN_CREATE, now) {
+		t.Errorf("expected IN_CREATE was not consumed")
+	}
+	if hw.consumeExpectedLocked(d, "child", linux.IN_CREATE, now) {
+		t.Errorf("IN_CREATE was consumed twice, but expected once")
+	}
+	if hw.consumeExpectedLocked(d, "other", linux.IN_MODIFY, now) {
+		t.Errorf("IN_MODIFY on another child was consumed")
+	}
+	// IN_ISDIR qualifies the event, and doesn't need to be expected.
+	if !hw.consumeExpectedLocked(d, "child", linux.IN_MODIFY|linux.IN_ISDIR, now) {
+		t.Errorf("expected IN_MODIFY with IN_ISDIR was not consumed")
+	}
+	if len(hw.expected) != 0 {
+		t.Errorf("expected events left after all were consumed: %v", hw.expected)
+	}
+
+	// Events generated more than once are consumed as many times.
+	hw.expectLocked(d, "", linux.IN_ATTRIB, now)
+	hw.expectLocked(d, "", linux.IN_ATTRIB, now)
+	for i := 0; i < 2; i++ {
+		if !hw.consumeExpectedLocked(d, "", linux.IN_ATTRIB, now) {
+			t.Errorf("expected IN_ATTRIB %d was not consumed", i)
+		}
+	}
+	if hw.consumeExpectedLocked(d, "", linux.IN_ATTRIB, now) {
+		t.Errorf("IN_ATTRIB was consumed three times, but expected twice")
+	}
+
+	// Expected events expire, so that host events that are never reported
+	// can't suppress later ones indefinitely.
+	later := now.Add(hostEventExpiry + time.Nanosecond)
+	hw.expectLocked(d, "", linux.IN_DELETE_SELF, now)
+	if hw.consumeExpectedLocked(d, "", linux.IN_DELETE_SELF, later) {
+		t.Errorf("expired IN_DELETE_SELF was consumed")
+	}
+	if len(hw.expected) != 0 {
+		t.Errorf("expired event was not discarded when consumed: %v", hw.expected)
+	}
+	hw.expectLocked(d, "child", linux.IN_DELETE, now)
+	hw.pruneExpectedLocked(later)
+	if len(hw.expected) != 0 {
+		t.Errorf("expired event was not pruned: %v", hw.expected)
+	}
+
+	// Expected events are discarded when d's host watch is removed.
+	other := &dentry{}
+	hw.expectLocked(d, "child", linux.IN_MOVED_FROM, now)
+	hw.expectLocked(other, "child", linux.IN_MOVED_FROM, now)
+	hw.forgetExpectedLocked(d)
+	if hw.consumeExpectedLocked(d, "child", linux.IN_MOVED_FROM, now) {
+		t.Errorf("forgotten IN_MOVED_FROM was consumed")
+	}
+	if !hw.consumeExpectedLocked(other, "child", linux.IN_MOVED_FROM, now) {
+		t.Errorf("IN_MOVED_FROM on another dentry was forgotten")
+	}
+}
+
+func TestHostWatcherHandleEvents(t *testing.T) {
+	fs := &filesystem{}
+	d := &dentry{fs: fs}
+	d.refs.Store(1)
+	d.hostWD = 1
+	hw := newTestHostWatcher(fs)
+	hw.dentries[1] = map[*dentry]struct{}{d: {}}
+
+	// Events generated by the sandbox are dropped when the host reports them.
+	hw.mu.Lock()
+	hw.expectLocked(d, "file", linux.IN_CREATE, time.Now())
+	hw.mu.Unlock()
+	hw.handleEvents(hostEvent(1, linux.IN_CREATE, "file"))
+	if len(hw.expected) != 0 {
+		t.Errorf("expected event was not consumed: %v", hw.expected)
+	}
+
+	// IN_IGNORED indicates that the host dropped the watch.
+	hw.handleEvents(hostEvent(1, linux.IN_IGNORED, ""))
+	if d.hostWD != 0 {
+		t.Errorf("d.hostWD after IN_IGNORED: got %d, want 0", d.hostWD)
+	}
+	if len(hw.dentries) != 0 {
+		t.Errorf("dentries left after IN_IGNORED: %v", hw.dentries)
+	}
+	if got := d.refs.Load(); got != 1 {
+		t.Errorf("d.refs after handling events: got %d, want 1", got)
+	}
+}
+
+func TestWatchedDentries(t *testing.T) {
+	ctx := contexttest.Context(t)
+	fs := &filesystem{
+		mf:          pgalloc.MemoryFileFromContext(ctx),
+		inoByKey:    make(map[inoKey]uint64),
+		clock:       ktime.RealtimeClockFromContext(ctx),
+		dentryCache: &dentryCache{maxCachedDentries: 0},
+		client:      &lisafs.Client{},
+	}
+	newDentry := func(controlFD lisafs.FDID, mode uint16) *dentry {
+		t.Helper()
+		inode := lisafs.Inode{
+			ControlFD: controlFD,
+			Stat: linux.Statx{
+				Mask: linux.STATX_TYPE | linux.STATX_MODE,
+				Mode: mode,
+			},
+		}
+		d, err := fs.newLisafsDentry(ctx, &inode)
+		if err != nil {
+			t.Fatalf("fs.newLisafsDentry(): %v", err)
+		}
+		// Hold a reference so that d isn't cached or destroyed when its
+		// watches are removed.
+		d.IncRef()
+		return d
+	}
+	addChild := func(parent, child *dentry, name string) {
+		parent.opMu.Lock()
+		parent.childrenMu.Lock()
+		parent.cacheNewChildLocked(child, name)
+		parent.childrenMu.Unlock()
+		parent.opMu.Unlock()
+	}
+
+	fs.root = newDentry(1, linux.S_IFDIR|0755)
+	dir := newDentry(2, linux.S_IFDIR|0755)
+	addChild(fs.root, dir, "dir")
+	file := newDentry(3, linux.S_IFREG|0644)
+	addChild(fs.root, file, "file")
+	nested := newDentry(4, linux.S_IFREG|0644)
+	addChild(dir, nested, "nested")
+	synthetic := fs.newSyntheticDentry(&createSyntheticOpts{
+		name: "synthetic",
+		mode: linux.S_IFDIR | 0755,
+	})
+	addChild(fs.root, synthetic, "synthetic")
+	fs.root.childrenMu.Lock()
+	fs.root.cacheNegativeLookupLocked("negative")
+	fs.root.childrenMu.Unlock()
+
+	vfsObj := &vfs.VirtualFilesystem{}
+	if err := vfsObj.Init(ctx); err != nil {
+		t.Fatalf("VFS init: %v", err)
+	}
+	fd, err := vfs.NewInotifyFD(ctx, vfsObj, 0)
+	if err != nil {
+		t.Fatalf("vfs.NewInotifyFD(): %v", err)
+	}
+	defer fd.DecRef(ctx)
+	inotify := fd.Impl().(*vfs.Inotify)
+
+	check := func(want ...*dentry) {
+		t.Helper()
+		got := make(map[*dentry]bool)
+		for _, d := range fs.watchedDentries() {
+			if got[d] {
+				t.Errorf("dentry %q returned twice", d.name)
+			}
+			got[d] = true
+		}
+		if len(got) != len(want) {
+			t.Errorf("got %d watched dentries, want %d", len(got), len(want))
+		}
+		for _, d := range want {
+			if !got[d] {
+				t.Errorf("watched dentry %q was not returned", d.name)
+			}
+		}
+	}
+
+	check()
+	// Watched descendants are found through unwatched directories, but
+	// synthetic dentries have no host file to watch.
+	for _, d := range []*dentry{file, nested, synthetic} {
+		inotify.AddWatch(ctx, &d.vfsd, linux.IN_ALL_EVENTS)
+	}
+	check(file, nested)
+	inotify.AddWatch(ctx, &fs.root.vfsd, linux.IN_ALL_EVENTS)
+	check(fs.root, file, nested)
+}
@@ -433,7 +433,7 @@ func (rw *dentryReadWriter) ReadToBlocks(dsts safemem.BlockSeq) (uint64, error)
 					MemCgID: memCgID,
 					Mode:    pgalloc.AllocateAndWritePopulate,
 				}, h.readToBlocksAt)
-				mf.MarkEvictable(rw.d, pgalloc.EvictableRange{optMR.Start, optMR.End})
+				mf.MarkEvictable(rw.d, pgalloc.EvictableRange{optMR.Start, optMR.End}, memCgID)
 				seg, gap = rw.d.cache.Find(rw.off)
 				if !seg.Ok() {
 					return done, err
@@ -732,7 +732,7 @@ func (d *dentry) RemoveMapping(ctx context.Context, ms memmap.MappingSpace, ar h
 			// Since these pages are no longer mapped, they are no longer
 			// concurrently dirtyable by a writable memory mapping.
 			d.dirty.AllowClean(r)
-			mf.MarkEvictable(d, pgalloc.EvictableRange{r.Start, r.End})
+			mf.MarkEvictable(d, pgalloc.EvictableRange{r.Start, r.End}, pgalloc.MemoryCgroupIDFromContext(ctx))
 		}
 		d.dataMu.Unlock()
 	}
@@ -360,6 +360,8 @@ func (fs *filesystem) CompleteRestore(ctx context.Context, opts vfs.CompleteRest
 	fs.savedDeletedOpenDentries = nil
 	fs.savedDentryRW = nil
 
+	fs.restoreHostWatches(ctx)
+
 	return nil
 }
 
@@ -591,6 +591,9 @@ func (d *Dentry) Watches() *vfs.Watches {
 // OnZeroWatches implements vfs.Dentry.OnZeroWatches.
 func (d *Dentry) OnZeroWatches(context.Context) {}
 
+// OnFirstWatch implements vfs.Dentry.OnFirstWatch.
+func (d *Dentry) OnFirstWatch(context.Context) {}
+
 // insertChild inserts child into the vfs dentry cache with the given name under
 // this dentry. This does not update the directory inode, so calling this on its
 // own isn't sufficient to insert a child into a directory.
@@ -803,6 +803,9 @@ func (d *dentry) OnZeroWatches(ctx context.Context) {
 	}
 }
 
+// OnFirstWatch implements vfs.DentryImpl.OnFirstWatch.
+func (d *dentry) OnFirstWatch(ctx context.Context) {}
+
 // iterLayers invokes yield on each layer comprising d, from top to bottom. If
 // any call to yield returns false, iterLayer stops iteration.
 func (d *dentry) iterLayers(yield func(vd vfs.VirtualDentry, isUpper bool) bool) {
@@ -18,9 +18,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
@@ -959,6 +961,29 @@ func (i *ioData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	return nil
 }
 
+// oomScoreData implements vfs.DynamicBytesSource for /proc/[pid]/oom_score.
+//
+// +stateify savable
+type oomScoreData struct {
+	kernfs.DynamicBytesFile
+
+	task *kernel.Task
+}
+
+var _ dynamicInode = (*oomScoreData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *oomScoreData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	if d.task.ExitState() == kernel.TaskExitDead {
+		return linuxerr.ESRCH
+	}
+	mf := kernel.KernelFromContext(ctx).MemoryFile()
+	_, totalUsage := usage.MemoryAccounting.Copy()
+	totalSize := usage.TotalMemory(mf.TotalSize(), totalUsage)
+	fmt.Fprintf(buf, "%d\n", d.task.OOMScore(totalSize))
+	return nil
+}
+
 // oomScoreAdj is a stub of the /proc/<pid>/oom_score_adj file.
 //
 // +stateify savable
@@ -1009,6 +1034,115 @@ func (o *oomScoreAdj) Write(ctx context.Context, _ *vfs.FileDescription, src use
 	return src.NumBytes(), nil
 }
 
+// maxTimensOffsetsLines is the maximum number of lines in a write to
+// /proc/[pid]/timens_offsets, one per clock with an offset.
+const maxTimensOffsetsLines = 2
+
+// timensOffsetsData implements vfs.WritableDynamicBytesSource for
+// /proc/[pid]/timens_offsets, which shows the clock offsets of the task's
+// time namespace for children.
+//
+// +stateify savable
+type timensOffsetsData struct {
+	kernfs.DynamicBytesFile
+
+	task *kernel.Task
+}
+
+var _ vfs.WritableDynamicBytesSource = (*timensOffsetsData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *timensOffsetsData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	timens := d.task.GetChildTimeNamespace()
+	if timens == nil {
+		return linuxerr.ESRCH
+	}
+	defer timens.DecRef(ctx)
+	monotonic, boottime := timens.Offsets()
+	for _, o := range []struct {
+		name   string
+		offset int64
+	}{
+		{"monotonic", monotonic},
+		{"boottime", boottime},
+	} {
+		// Like a timespec64, nanoseconds are never negative.
+		sec, nsec := o.offset/1e9, o.offset%1e9
+		if nsec < 0 {
+			sec--
+			nsec += 1e9
+		}
+		fmt.Fprintf(buf, "%-10s %10d %9d\n", o.name, sec, nsec)
+	}
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (d *timensOffsetsData) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	srclen := src.NumBytes()
+	if srclen >= hostarch.PageSize || offset != 0 {
+		return 0, linuxerr.EINVAL
+	}
+	b := make([]byte, srclen)
+	if _, err := src.CopyIn(ctx, b); err != nil {
+		return 0, err
+	}
+	offsets, err := parseTimensOffsets(b)
+	if err != nil {
+		return 0, err
+	}
+
+	timens := d.task.GetChildTimeNamespace()
+	if timens == nil {
+		return 0, linuxerr.ESRCH
+	}
+	defer timens.DecRef(ctx)
+	if !auth.CredentialsFromContext(ctx).HasCapabilityIn(linux.CAP_SYS_TIME, timens.UserNamespace()) {
+		return 0, linuxerr.EPERM
+	}
+	if err := timens.SetOffsets(d.task.Kernel().Timekeeper(), offsets); err != nil {
+		return 0, err
+	}
+	return srclen, nil
+}
+
+// parseTimensOffsets parses a write to /proc/[pid]/timens_offsets, returning
+// clock offsets in nanoseconds keyed by clock ID. See
+// fs/proc/base.c:timens_offsets_write().
+func parseTimensOffsets(b []byte) (map[int32]int64, error) {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	lines := bytes.Split(b, []byte("\n"))
+	if len(lines) > maxTimensOffsetsLines {
+		return nil, linuxerr.EINVAL
+	}
+
+	offsets := make(map[int32]int64, len(lines))
+	for _, l := range lines {
+		var (
+			clock string
+			sec   int64
+			nsec  uint32
+		)
+		if _, err := fmt.Sscan(string(l), &clock, &sec, &nsec); err != nil || nsec >= uint32(time.Second) {
+			return nil, linuxerr.EINVAL
+		}
+		var clockID int32
+		switch clock {
+		case "monotonic", strconv.Itoa(linux.CLOCK_MONOTONIC):
+			clockID = linux.CLOCK_MONOTONIC
+		case "boottime", strconv.Itoa(linux.CLOCK_BOOTTIME):
+			clockID = linux.CLOCK_BOOTTIME
+		default:
+			return nil, linuxerr.EINVAL
+		}
+		if sec > (math.MaxInt64-int64(nsec))/int64(time.Second) || sec < math.MinInt64/int64(time.Second) {
+			return nil, linuxerr.ERANGE
+		}
+		offsets[clockID] = sec*int64(time.Second) + int64(nsec)
+	}
+	return offsets, nil
+}
+
 // exeSymlink is an symlink for the /proc/[pid]/exe file.
 //
 // +stateify savable
@@ -1277,10 +1411,18 @@ type namespaceSymlink struct {
 
 	task   *kernel.Task
 	nsType int
+
+	// forChildren is true if the symlink refers to the namespace of the
+	// task's future children, as for time_for_children.
+	forChildren bool
 }
 
 func (fs *filesystem) newNamespaceSymlink(ctx context.Context, task *kernel.Task, ino uint64, nsType int) kernfs.Inode {
-	inode := &namespaceSymlink{task: task, nsType: nsType}
+	return fs.newNamespaceSymlinkFor(ctx, task, ino, nsType, false /* forChildren */)
+}
+
+func (fs *filesystem) newNamespaceSymlinkFor(ctx context.Context, task *kernel.Task, ino uint64, nsType int, forChildren bool) kernfs.Inode {
+	inode := &namespaceSymlink{task: task, nsType: nsType, forChildren: forChildren}
 
 	// Note: credentials are overridden by taskOwnedInode.
 	inode.Init(ctx, task.Credentials(), linux.UNNAMED_MAJOR, fs.devMinor, ino, "")
@@ -1333,6 +1475,22 @@ func (s *namespaceSymlink) getInode(t *kernel.Task) *nsfs.Inode {
 			return pidns.GetInode()
 		}
 		return nil
+	case linux.CLONE_NEWTIME:
+		var timens *kernel.TimeNamespace
+		if s.forChildren {
+			timens = t.GetChildTimeNamespace()
+		} else {
+			timens = t.GetTimeNamespace()
+		}
+		if timens != nil {
+			return timens.GetInode()
+		}
+		return nil
+	case linux.CLONE_NEWCGROUP:
+		if cgroupns := t.GetCgroupNamespace(); cgroupns != nil {
+			return cgroupns.GetInode()
+		}
+		return nil
 	default:
 		panic("unknown namespace")
 	}
@@ -1476,7 +1634,9 @@ func (d *taskCgroupData) Generate(ctx context.Context, buf *bytes.Buffer) error
 		return linuxerr.ESRCH
 	}
 
-	d.task.GenerateProcTaskCgroup(buf)
+	ns := d.task.Kernel().GetCgroupNamespaceFromContext(ctx)
+	defer ns.DecRef(ctx)
+	d.task.GenerateProcTaskCgroup(buf, ns)
 	return nil
 }
 
@@ -0,0 +1,121 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+)
+
+func TestParseTimensOffsets(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		want    map[int32]int64
+		wantErr error
+	}{
+		{
+			name:  "names",
+			input: "monotonic 1 2\nboottime -1 0\n",
+			want: map[int32]int64{
+				linux.CLOCK_MONOTONIC: 1e9 + 2,
+				linux.CLOCK_BOOTTIME:  -1e9,
+			},
+		},
+		{
+			name:  "clock IDs",
+			input: "1 5 0\n7 6 0",
+			want: map[int32]int64{
+				linux.CLOCK_MONOTONIC: 5e9,
+				linux.CLOCK_BOOTTIME:  6e9,
+			},
+		},
+		{
+			name:  "max nsec",
+			input: "monotonic 0 999999999",
+			want:  map[int32]int64{linux.CLOCK_MONOTONIC: 999999999},
+		},
+		{
+			name:    "nsec overflow",
+			input:   "monotonic 0 1000000000",
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "negative nsec",
+			input:   "monotonic 0 -1",
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:  "max offset",
+			input: "monotonic 9223372036 854775807",
+			want:  map[int32]int64{linux.CLOCK_MONOTONIC: math.MaxInt64},
+		},
+		{
+			name:    "max offset plus 1ns",
+			input:   "monotonic 9223372036 854775808",
+			wantErr: linuxerr.ERANGE,
+		},
+		{
+			name:    "sec overflow",
+			input:   "monotonic 9223372037 0",
+			wantErr: linuxerr.ERANGE,
+		},
+		{
+			name:  "min sec",
+			input: "boottime -9223372036 0",
+			want:  map[int32]int64{linux.CLOCK_BOOTTIME: -9223372036e9},
+		},
+		{
+			name:    "sec underflow",
+			input:   "boottime -9223372037 0",
+			wantErr: linuxerr.ERANGE,
+		},
+		{
+			name:    "unsupported clock",
+			input:   "realtime 1 0",
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "missing nsec",
+			input:   "monotonic 1",
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "too many lines",
+			input:   "monotonic 1 0\nboottime 1 0\nmonotonic 2 0",
+			wantErr: linuxerr.EINVAL,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseTimensOffsets([]byte(tc.input))
+			if tc.wantErr != nil {
+				if !linuxerr.Equals(tc.wantErr, err) {
+					t.Fatalf("parseTimensOffsets(%q) got err %v, want %v", tc.input, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimensOffsets(%q) failed: %v", tc.input, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseTimensOffsets(%q) got %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
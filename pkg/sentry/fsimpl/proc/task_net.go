@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"reflect"
+	"slices"
 	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
@@ -50,25 +52,26 @@ func (fs *filesystem) newTaskNetDir(ctx context.Context, task *kernel.Task) kern
 	}
 	if stack != nil {
 		const (
-			arp       = "IP address       HW type     Flags       HW address            Mask     Device\n"
 			netlink   = "sk       Eth Pid    Groups   Rmem     Wmem     Dump     Locks     Drops     Inode\n"
 			packet    = "sk       RefCnt Type Proto  Iface R Rmem   User   Inode\n"
 			protocols = "protocol  size sockets  memory press maxhdr  slab module     cl co di ac io in de sh ss gs se re sp bi br ha uh gp em\n"
 			ptype     = "Type Device      Function\n"
-			upd6      = "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
 		)
 		psched := fmt.Sprintf("%08x %08x %08x %08x\n", uint64(time.Microsecond/time.Nanosecond), 64, 1000000, uint64(time.Second/time.Nanosecond))
 
 		// TODO(gvisor.dev/issue/1833): Make sure file contents reflect the task
 		// network namespace.
 		contents = map[string]kernfs.Inode{
-			"dev":  fs.newInode(ctx, root, 0444, &netDevData{stack: stack}),
-			"snmp": fs.newInode(ctx, root, 0444, &netSnmpData{stack: stack}),
+			"arp":      fs.newInode(ctx, root, 0444, &netARPData{stack: stack}),
+			"dev":      fs.newInode(ctx, root, 0444, &netDevData{stack: stack}),
+			"igmp":     fs.newInode(ctx, root, 0444, &netIGMPData{stack: stack}),
+			"raw":      fs.newInode(ctx, root, 0444, &netRawData{kernel: k}),
+			"snmp":     fs.newInode(ctx, root, 0444, &netSnmpData{stack: stack}),
+			"sockstat": fs.newInode(ctx, root, 0444, &netSockstatData{kernel: k}),
 
 			// The following files are simple stubs until they are implemented in
 			// netstack, if the file contains a header the stub is just the header
 			// otherwise it is an empty file.
-			"arp":       fs.newInode(ctx, root, 0444, newStaticFile(arp)),
 			"netlink":   fs.newInode(ctx, root, 0444, newStaticFile(netlink)),
 			"netstat":   fs.newInode(ctx, root, 0444, &netStatData{}),
 			"packet":    fs.newInode(ctx, root, 0444, newStaticFile(packet)),
@@ -86,9 +89,13 @@ func (fs *filesystem) newTaskNetDir(ctx context.Context, task *kernel.Task) kern
 
 		if stack.SupportsIPv6() {
 			contents["if_inet6"] = fs.newInode(ctx, root, 0444, &ifinet6{stack: stack})
-			contents["ipv6_route"] = fs.newInode(ctx, root, 0444, newStaticFile(""))
+			contents["igmp6"] = fs.newInode(ctx, root, 0444, &netIGMP6Data{stack: stack})
+			contents["ipv6_route"] = fs.newInode(ctx, root, 0444, &netIPv6RouteData{stack: stack})
+			contents["raw6"] = fs.newInode(ctx, root, 0444, &netRaw6Data{kernel: k})
+			contents["snmp6"] = fs.newInode(ctx, root, 0444, &netSnmp6Data{stack: stack})
+			contents["sockstat6"] = fs.newInode(ctx, root, 0444, &netSockstat6Data{kernel: k})
 			contents["tcp6"] = fs.newInode(ctx, root, 0444, &netTCP6Data{kernel: k})
-			contents["udp6"] = fs.newInode(ctx, root, 0444, newStaticFile(upd6))
+			contents["udp6"] = fs.newInode(ctx, root, 0444, &netUDP6Data{kernel: k})
 		}
 	}
 
@@ -513,14 +520,92 @@ var _ dynamicInode = (*netUDPData)(nil)
 
 // Generate implements vfs.DynamicBytesSource.Generate.
 func (d *netUDPData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	buf.WriteString("  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops             \n")
+	return commonGenerateDatagram(ctx, buf, d.kernel, linux.AF_INET, linux.SOCK_DGRAM)
+}
+
+// netUDP6Data implements vfs.DynamicBytesSource for /proc/net/udp6.
+//
+// +stateify savable
+type netUDP6Data struct {
+	kernfs.DynamicBytesFile
+
+	kernel *kernel.Kernel
+}
+
+var _ dynamicInode = (*netUDP6Data)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *netUDP6Data) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	buf.WriteString("  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n")
+	return commonGenerateDatagram(ctx, buf, d.kernel, linux.AF_INET6, linux.SOCK_DGRAM)
+}
+
+// netRawData implements vfs.DynamicBytesSource for /proc/net/raw.
+//
+// +stateify savable
+type netRawData struct {
+	kernfs.DynamicBytesFile
+
+	kernel *kernel.Kernel
+}
+
+var _ dynamicInode = (*netRawData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *netRawData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	buf.WriteString("  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n")
+	return commonGenerateDatagram(ctx, buf, d.kernel, linux.AF_INET, linux.SOCK_RAW)
+}
+
+// netRaw6Data implements vfs.DynamicBytesSource for /proc/net/raw6.
+//
+// +stateify savable
+type netRaw6Data struct {
+	kernfs.DynamicBytesFile
+
+	kernel *kernel.Kernel
+}
+
+var _ dynamicInode = (*netRaw6Data)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *netRaw6Data) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	buf.WriteString("  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n")
+	return commonGenerateDatagram(ctx, buf, d.kernel, linux.AF_INET6, linux.SOCK_RAW)
+}
+
+// setSockAddrPort returns a copy of addr with the port set to port, which is
+// in host byte order.
+func setSockAddrPort(family int, addr linux.SockAddr, port uint16) linux.SockAddr {
+	switch family {
+	case linux.AF_INET:
+		var a linux.SockAddrInet
+		if addr != nil {
+			a = *addr.(*linux.SockAddrInet)
+		}
+		a.Port = socket.Htons(port)
+		return &a
+	case linux.AF_INET6:
+		var a linux.SockAddrInet6
+		if addr != nil {
+			a = *addr.(*linux.SockAddrInet6)
+		}
+		a.Port = socket.Htons(port)
+		return &a
+	}
+	return addr
+}
+
+// commonGenerateDatagram generates the contents of /proc/net/{udp,udp6,raw,raw6}
+// for sockets of the given family and type.
+func commonGenerateDatagram(ctx context.Context, buf *bytes.Buffer, k *kernel.Kernel, family int, stype linux.SockType) error {
 	// t may be nil here if our caller is not part of a task goroutine. This can
 	// happen for example if we're here for "sentryctl cat". When t is nil,
 	// degrade gracefully and retrieve what we can.
 	t := kernel.TaskFromContext(ctx)
 
-	buf.WriteString("  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops             \n")
-
-	for _, se := range d.kernel.ListSockets() {
+	for _, se := range k.ListSockets() {
 		s := se.Sock
 		if !s.TryIncRef() {
 			// Racing with socket destruction, this is ok.
@@ -530,34 +615,47 @@ func (d *netUDPData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 		if !ok {
 			panic(fmt.Sprintf("Found non-socket file in socket table: %+v", s))
 		}
-		if family, stype, _ := sops.Type(); family != linux.AF_INET || stype != linux.SOCK_DGRAM {
+		fa, st, protocol := sops.Type()
+		if fa != family || st != stype {
 			s.DecRef(ctx)
-			// Not udp4 socket.
+			// Not a socket of the requested kind.
 			continue
 		}
 
-		// For Linux's implementation, see net/ipv4/udp.c:udp4_format_sock().
+		// For Linux's implementation, see net/ipv4/udp.c:udp4_format_sock()
+		// and net/ipv4/raw.c:raw_sock_seq_show().
 
 		// Field: sl; entry number.
-		fmt.Fprintf(buf, "%5d: ", se.ID)
+		if stype == linux.SOCK_RAW {
+			fmt.Fprintf(buf, "%4d: ", se.ID)
+		} else {
+			fmt.Fprintf(buf, "%5d: ", se.ID)
+		}
 
 		// Field: local_adddress.
-		var localAddr linux.SockAddrInet
+		var localAddr linux.SockAddr
 		if t != nil {
 			if local, _, err := sops.GetSockName(t); err == nil {
-				localAddr = *local.(*linux.SockAddrInet)
+				localAddr = local
 			}
 		}
-		writeInetAddr(buf, linux.AF_INET, &localAddr)
 
 		// Field: rem_address.
-		var remoteAddr linux.SockAddrInet
+		var remoteAddr linux.SockAddr
 		if t != nil {
 			if remote, _, err := sops.GetPeerName(t); err == nil {
-				remoteAddr = *remote.(*linux.SockAddrInet)
+				remoteAddr = remote
 			}
 		}
-		writeInetAddr(buf, linux.AF_INET, &remoteAddr)
+
+		if stype == linux.SOCK_RAW {
+			// Linux reports the protocol of raw sockets as the local port
+			// and 0 as the remote port.
+			localAddr = setSockAddrPort(family, localAddr, uint16(protocol))
+			remoteAddr = setSockAddrPort(family, remoteAddr, 0)
+		}
+		writeInetAddr(buf, family, localAddr)
+		writeInetAddr(buf, family, remoteAddr)
 
 		// Field: state; socket state.
 		fmt.Fprintf(buf, "%02X ", sops.State())
@@ -566,10 +664,10 @@ func (d *netUDPData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 		// receive queue. Unimplemented.
 		fmt.Fprintf(buf, "%08X:%08X ", 0, 0)
 
-		// Field: tr, tm->when. Always 0 for UDP.
+		// Field: tr, tm->when. Always 0 for UDP and raw sockets.
 		fmt.Fprintf(buf, "%02X:%08X ", 0, 0)
 
-		// Field: retrnsmt. Always 0 for UDP.
+		// Field: retrnsmt. Always 0 for UDP and raw sockets.
 		fmt.Fprintf(buf, "%08X ", 0)
 
 		stat, statErr := s.Stat(ctx, vfs.StatOptions{Mask: linux.STATX_UID | linux.STATX_INO})
@@ -583,7 +681,7 @@ func (d *netUDPData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 			fmt.Fprintf(buf, "%5d ", uint32(auth.KUID(stat.UID).In(creds.UserNamespace).OrOverflow()))
 		}
 
-		// Field: timeout. Always 0 for UDP.
+		// Field: timeout. Always 0 for UDP and raw sockets.
 		fmt.Fprintf(buf, "%8d ", 0)
 
 		// Field: inode.
@@ -816,3 +914,360 @@ func (d *netStatData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 		"TCPWinProbe TCPKeepAlive TCPMTUPFail TCPMTUPSuccess\n")
 	return nil
 }
+
+// snmp6Names holds the names of the counters in each section of
+// /proc/net/snmp6. See Linux's net/ipv6/proc.c.
+var snmp6Names = [][]string{
+	{
+		"Ip6InReceives", "Ip6InHdrErrors", "Ip6InTooBigErrors", "Ip6InNoRoutes",
+		"Ip6InAddrErrors", "Ip6InUnknownProtos", "Ip6InTruncatedPkts",
+		"Ip6InDiscards", "Ip6InDelivers", "Ip6OutForwDatagrams", "Ip6OutRequests",
+		"Ip6OutDiscards", "Ip6OutNoRoutes", "Ip6ReasmTimeout", "Ip6ReasmReqds",
+		"Ip6ReasmOKs", "Ip6ReasmFails", "Ip6FragOKs", "Ip6FragFails",
+		"Ip6FragCreates", "Ip6InMcastPkts", "Ip6OutMcastPkts", "Ip6InOctets",
+		"Ip6OutOctets", "Ip6InMcastOctets", "Ip6OutMcastOctets",
+		"Ip6InBcastOctets", "Ip6OutBcastOctets", "Ip6InNoECTPkts",
+		"Ip6InECT1Pkts", "Ip6InECT0Pkts", "Ip6InCEPkts",
+	},
+	{
+		"Icmp6InMsgs", "Icmp6InErrors", "Icmp6OutMsgs", "Icmp6OutErrors",
+		"Icmp6InCsumErrors", "Icmp6InDestUnreachs", "Icmp6InPktTooBigs",
+		"Icmp6InTimeExcds", "Icmp6InParmProblems", "Icmp6InEchos",
+		"Icmp6InEchoReplies", "Icmp6InGroupMembQueries",
+		"Icmp6InGroupMembResponses", "Icmp6InGroupMembReductions",
+		"Icmp6InRouterSolicits", "Icmp6InRouterAdvertisements",
+		"Icmp6InNeighborSolicits", "Icmp6InNeighborAdvertisements",
+		"Icmp6InRedirects", "Icmp6InMLDv2Reports", "Icmp6OutDestUnreachs",
+		"Icmp6OutPktTooBigs", "Icmp6OutTimeExcds", "Icmp6OutParmProblems",
+		"Icmp6OutEchos", "Icmp6OutEchoReplies", "Icmp6OutGroupMembQueries",
+		"Icmp6OutGroupMembResponses", "Icmp6OutGroupMembReductions",
+		"Icmp6OutRouterSolicits", "Icmp6OutRouterAdvertisements",
+		"Icmp6OutNeighborSolicits", "Icmp6OutNeighborAdvertisements",
+		"Icmp6OutRedirects", "Icmp6OutMLDv2Reports",
+	},
+	{
+		"Udp6InDatagrams", "Udp6NoPorts", "Udp6InErrors", "Udp6OutDatagrams",
+		"Udp6RcvbufErrors", "Udp6SndbufErrors", "Udp6InCsumErrors",
+		"Udp6IgnoredMulti", "Udp6MemErrors",
+	},
+	{
+		"UdpLite6InDatagrams", "UdpLite6NoPorts", "UdpLite6InErrors",
+		"UdpLite6OutDatagrams", "UdpLite6RcvbufErrors", "UdpLite6SndbufErrors",
+		"UdpLite6InCsumErrors", "UdpLite6IgnoredMulti", "UdpLite6MemErrors",
+	},
+}
+
+// netSnmp6Data implements vfs.DynamicBytesSource for /proc/net/snmp6.
+//
+// +stateify savable
+type netSnmp6Data struct {
+	kernfs.DynamicBytesFile
+
+	stack inet.Stack
+}
+
+var _ dynamicInode = (*netSnmp6Data)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+// See Linux's net/ipv6/proc.c:snmp6_seq_show.
+func (d *netSnmp6Data) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	types := []any{
+		&inet.StatSNMP6IP{},
+		&inet.StatSNMP6ICMP{},
+		&inet.StatSNMP6UDP{},
+		&inet.StatSNMP6UDPLite{},
+	}
+	for i, stat := range types {
+		if err := d.stack.Statistics(stat, ""); err != nil {
+			if linuxerr.Equals(linuxerr.EOPNOTSUPP, err) {
+				// Omit counters that the stack can't report rather
+				// than showing them as 0.
+				log.Debugf("Failed to retrieve %s of /proc/net/snmp6: %v", snmp6Names[i][0], err)
+				continue
+			}
+			log.Warningf("Failed to retrieve %s of /proc/net/snmp6: %v", snmp6Names[i][0], err)
+		}
+		for j, v := range toSlice(stat) {
+			fmt.Fprintf(buf, "%-32s\t%d\n", snmp6Names[i][j], v)
+		}
+	}
+	return nil
+}
+
+// writeIPv6Addr writes addr as 32 hex digits, the way Linux formats IPv6
+// addresses with %pi6. A nil addr is written as the unspecified address.
+func writeIPv6Addr(w io.Writer, addr []byte) {
+	var a [header.IPv6AddressSize]byte
+	copy(a[:], addr)
+	fmt.Fprintf(w, "%x", a)
+}
+
+// netIPv6RouteData implements vfs.DynamicBytesSource for /proc/net/ipv6_route.
+//
+// +stateify savable
+type netIPv6RouteData struct {
+	kernfs.DynamicBytesFile
+
+	stack inet.Stack
+}
+
+var _ dynamicInode = (*netIPv6RouteData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+// See Linux's net/ipv6/route.c:ipv6_route_native_seq_show.
+func (d *netIPv6RouteData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	interfaces := d.stack.Interfaces()
+	for _, rt := range d.stack.RouteTable() {
+		if rt.Family != linux.AF_INET6 {
+			continue
+		}
+		iface, ok := interfaces[rt.OutputInterface]
+		if !ok {
+			continue
+		}
+
+		flags := uint32(linux.RTF_UP)
+		if len(rt.GatewayAddr) == header.IPv6AddressSize {
+			flags |= linux.RTF_GATEWAY
+		}
+		writeIPv6Addr(buf, rt.DstAddr)
+		fmt.Fprintf(buf, " %02x ", rt.DstLen)
+		writeIPv6Addr(buf, rt.SrcAddr)
+		fmt.Fprintf(buf, " %02x ", rt.SrcLen)
+		writeIPv6Addr(buf, rt.GatewayAddr)
+		fmt.Fprintf(buf, " %08x %08x %08x %08x %8s\n",
+			0, // Metric.
+			0, // RefCnt.
+			0, // Use.
+			flags,
+			iface.Name)
+	}
+	return nil
+}
+
+// sortedInterfaceIndexes returns the indexes of interfaces in ascending order.
+func sortedInterfaceIndexes(interfaces map[int32]inet.Interface) []int32 {
+	idxs := make([]int32, 0, len(interfaces))
+	for idx := range interfaces {
+		idxs = append(idxs, idx)
+	}
+	slices.Sort(idxs)
+	return idxs
+}
+
+// netARPData implements vfs.DynamicBytesSource for /proc/net/arp.
+//
+// +stateify savable
+type netARPData struct {
+	kernfs.DynamicBytesFile
+
+	stack inet.Stack
+}
+
+var _ dynamicInode = (*netARPData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+// See Linux's net/ipv4/arp.c:arp_seq_show.
+func (d *netARPData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	buf.WriteString("IP address       HW type     Flags       HW address            Mask     Device\n")
+
+	interfaces := d.stack.Interfaces()
+	nicNeighs := d.stack.Neighbors()
+	for _, idx := range sortedInterfaceIndexes(interfaces) {
+		iface := interfaces[idx]
+		for _, n := range nicNeighs[idx] {
+			// Entries that don't need resolution are skipped, as in Linux.
+			if n.Family != linux.AF_INET || len(n.Addr) != header.IPv4AddressSize || n.State&linux.NUD_NOARP != 0 {
+				continue
+			}
+			// See net/ipv4/arp.c:arp_state_to_flags.
+			var flags uint32
+			switch {
+			case n.State&linux.NUD_PERMANENT != 0:
+				flags = linux.ATF_PERM | linux.ATF_COM
+			case n.State&(linux.NUD_REACHABLE|linux.NUD_PROBE|linux.NUD_STALE|linux.NUD_DELAY) != 0:
+				flags = linux.ATF_COM
+			}
+			hwAddr := n.LinkAddr
+			if len(hwAddr) == 0 {
+				hwAddr = make([]byte, len(iface.Addr))
+			}
+			fmt.Fprintf(buf, "%-16s 0x%-10x0x%-10x%-17s     *        %s\n",
+				net.IP(n.Addr).String(), iface.DeviceType, flags, net.HardwareAddr(hwAddr).String(), iface.Name)
+		}
+	}
+	return nil
+}
+
+// netIGMPData implements vfs.DynamicBytesSource for /proc/net/igmp.
+//
+// +stateify savable
+type netIGMPData struct {
+	kernfs.DynamicBytesFile
+
+	stack inet.Stack
+}
+
+var _ dynamicInode = (*netIGMPData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+// See Linux's net/ipv4/igmp.c:igmp_mc_seq_show.
+func (d *netIGMPData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	buf.WriteString("Idx\tDevice    : Count Querier\tGroup    Users Timer\tReporter\n")
+
+	interfaces := d.stack.Interfaces()
+	nicGroups := d.stack.MulticastGroups()
+	for _, idx := range sortedInterfaceIndexes(interfaces) {
+		var groups []inet.MulticastGroup
+		for _, g := range nicGroups[idx] {
+			if g.Family == linux.AF_INET && len(g.Addr) == header.IPv4AddressSize {
+				groups = append(groups, g)
+			}
+		}
+		// netstack always starts in IGMPv3 mode.
+		fmt.Fprintf(buf, "%d\t%-10s: %5d %7s\n", idx, interfaces[idx].Name, len(groups), "V3")
+		for _, g := range groups {
+			// The group address is printed like a __be32, see writeInetAddr.
+			fmt.Fprintf(buf, "\t\t\t\t%08X %5d %d:%08X\t\t%d\n",
+				hostarch.ByteOrder.Uint32(g.Addr),
+				g.Users,
+				0, // Timer running.
+				0, // Timer expiry.
+				0, // Reporter.
+			)
+		}
+	}
+	return nil
+}
+
+// netIGMP6Data implements vfs.DynamicBytesSource for /proc/net/igmp6.
+//
+// +stateify savable
+type netIGMP6Data struct {
+	kernfs.DynamicBytesFile
+
+	stack inet.Stack
+}
+
+var _ dynamicInode = (*netIGMP6Data)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+// See Linux's net/ipv6/mcast.c:igmp6_mc_seq_show.
+func (d *netIGMP6Data) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	interfaces := d.stack.Interfaces()
+	nicGroups := d.stack.MulticastGroups()
+	for _, idx := range sortedInterfaceIndexes(interfaces) {
+		for _, g := range nicGroups[idx] {
+			if g.Family != linux.AF_INET6 {
+				continue
+			}
+			fmt.Fprintf(buf, "%-4d %-15s ", idx, interfaces[idx].Name)
+			writeIPv6Addr(buf, g.Addr)
+			fmt.Fprintf(buf, " %5d %08X %d\n",
+				g.Users,
+				0, // Flags.
+				0, // Timer expiry.
+			)
+		}
+	}
+	return nil
+}
+
+// inetSockCounts holds the number of sockets of each kind reported in
+// /proc/net/sockstat and /proc/net/sockstat6.
+type inetSockCounts struct {
+	total    int
+	tcp      [2]int // Indexed by isIPv6.
+	tcpInUse [2]int
+	udp      [2]int
+	raw      [2]int
+}
+
+// countInetSockets counts the sockets in k.
+func countInetSockets(ctx context.Context, k *kernel.Kernel) inetSockCounts {
+	var c inetSockCounts
+	for _, se := range k.ListSockets() {
+		s := se.Sock
+		if !s.TryIncRef() {
+			// Racing with socket destruction, this is ok.
+			continue
+		}
+		c.total++
+		sops, ok := s.Impl().(socket.Socket)
+		if !ok {
+			panic(fmt.Sprintf("Found non-socket file in socket table: %+v", s))
+		}
+		family, stype, _ := sops.Type()
+		var v6 int
+		switch family {
+		case linux.AF_INET:
+		case linux.AF_INET6:
+			v6 = 1
+		default:
+			s.DecRef(ctx)
+			continue
+		}
+		switch stype {
+		case linux.SOCK_STREAM:
+			c.tcp[v6]++
+			if sops.State() != linux.TCP_CLOSE {
+				c.tcpInUse[v6]++
+			}
+		case linux.SOCK_DGRAM:
+			c.udp[v6]++
+		case linux.SOCK_RAW:
+			c.raw[v6]++
+		}
+		s.DecRef(ctx)
+	}
+	return c
+}
+
+// netSockstatData implements vfs.DynamicBytesSource for /proc/net/sockstat.
+//
+// +stateify savable
+type netSockstatData struct {
+	kernfs.DynamicBytesFile
+
+	kernel *kernel.Kernel
+}
+
+var _ dynamicInode = (*netSockstatData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+// See Linux's net/ipv4/proc.c:sockstat_seq_show.
+func (d *netSockstatData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	c := countInetSockets(ctx, d.kernel)
+	// Orphaned and TIME_WAIT sockets aren't tracked by the socket table, and
+	// memory accounting is unimplemented.
+	fmt.Fprintf(buf, "sockets: used %d\n", c.total)
+	fmt.Fprintf(buf, "TCP: inuse %d orphan %d tw %d alloc %d mem %d\n", c.tcpInUse[0], 0, 0, c.tcp[0]+c.tcp[1], 0)
+	fmt.Fprintf(buf, "UDP: inuse %d mem %d\n", c.udp[0], 0)
+	fmt.Fprintf(buf, "UDPLITE: inuse %d\n", 0)
+	fmt.Fprintf(buf, "RAW: inuse %d\n", c.raw[0])
+	fmt.Fprintf(buf, "FRAG: inuse %d memory %d\n", 0, 0)
+	return nil
+}
+
+// netSockstat6Data implements vfs.DynamicBytesSource for /proc/net/sockstat6.
+//
+// +stateify savable
+type netSockstat6Data struct {
+	kernfs.DynamicBytesFile
+
+	kernel *kernel.Kernel
+}
+
+var _ dynamicInode = (*netSockstat6Data)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+// See Linux's net/ipv6/proc.c:sockstat6_seq_show.
+func (d *netSockstat6Data) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	c := countInetSockets(ctx, d.kernel)
+	fmt.Fprintf(buf, "TCP6: inuse %d\n", c.tcpInUse[1])
+	fmt.Fprintf(buf, "UDP6: inuse %d\n", c.udp[1])
+	fmt.Fprintf(buf, "UDPLITE6: inuse %d\n", 0)
+	fmt.Fprintf(buf, "RAW6: inuse %d\n", c.raw[1])
+	fmt.Fprintf(buf, "FRAG6: inuse %d memory %d\n", 0, 0)
+	return nil
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"runtime"
 	"strconv"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
@@ -27,6 +28,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
 	"gvisor.dev/gvisor/pkg/sentry/ktime"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
@@ -258,6 +260,16 @@ func (*loadavgData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	return nil
 }
 
+// newPressureDir returns /proc/pressure, which reports system-wide pressure
+// stall information.
+func (fs *filesystem) newPressureDir(ctx context.Context, creds *auth.Credentials, k *kernel.Kernel) kernfs.Inode {
+	contents := make(map[string]kernfs.Inode)
+	for r := psi.Resource(0); r < psi.NumResources; r++ {
+		contents[r.String()] = psi.NewFile(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), 0666, k.PressureGroup(), r, k.MonotonicClock())
+	}
+	return fs.newStaticDir(ctx, creds, contents)
+}
+
 // meminfoData implements vfs.DynamicBytesSource for /proc/meminfo.
 //
 // +stateify savable
@@ -326,8 +338,16 @@ func (*uptimeData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	k := kernel.KernelFromContext(ctx)
 	now := ktime.NowFromContext(ctx)
 
+	uptime := now.Sub(k.Timekeeper().BootTime())
+	if t := kernel.TaskFromContext(ctx); t != nil {
+		// Uptime is relative to CLOCK_BOOTTIME in the reader's time
+		// namespace.
+		_, boottime := t.TimeNamespace().Offsets()
+		uptime += time.Duration(boottime)
+	}
+
 	// Pretend that we've spent zero time sleeping (second number).
-	fmt.Fprintf(buf, "%.2f 0.00\n", now.Sub(k.Timekeeper().BootTime()).Seconds())
+	fmt.Fprintf(buf, "%.2f 0.00\n", uptime.Seconds())
 	return nil
 }
 
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"path"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/atomicbitops"
@@ -84,68 +85,72 @@ func (fs *filesystem) newSysNetDir(ctx context.Context, root *auth.Credentials,
 	var contents map[string]kernfs.Inode
 
 	// TODO(gvisor.dev/issue/1833): Support for using the network stack in the
-	// network namespace of the calling process.
+	// network namespace of the calling process for the files below that are
+	// not in inet.Sysctls.
 	if stack := k.RootNetworkNamespace().Stack(); stack != nil {
+		ipv4 := map[string]kernfs.Inode{
+			"ip_forward":          fs.newInode(ctx, root, 0444, &ipForwarding{stack: stack}),
+			"ip_local_port_range": fs.newInode(ctx, root, 0644, &portRange{stack: stack}),
+			"tcp_recovery":        fs.newInode(ctx, root, 0644, &tcpRecoveryData{stack: stack}),
+			"tcp_rmem":            fs.newInode(ctx, root, 0644, &tcpMemData{stack: stack, dir: tcpRMem}),
+			"tcp_sack":            fs.newInode(ctx, root, 0644, &tcpSackData{stack: stack}),
+			"tcp_wmem":            fs.newInode(ctx, root, 0644, &tcpMemData{stack: stack, dir: tcpWMem}),
+
+			// The following files are simple stubs until they are implemented in
+			// netstack, most of these files are configuration related. We use the
+			// value closest to the actual netstack behavior or any empty file, all
+			// of these files will have mode 0444 (read-only for all users).
+			"ip_local_reserved_ports": fs.newInode(ctx, root, 0444, newStaticFile("")),
+			"ipfrag_time":             fs.newInode(ctx, root, 0444, newStaticFile("30")),
+			"ip_nonlocal_bind":        fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"ip_no_pmtu_disc":         fs.newInode(ctx, root, 0444, newStaticFile("1")),
+
+			// tcp_allowed_congestion_control tell the user what they are able to
+			// do as an unprivledged process so we leave it empty.
+			"tcp_allowed_congestion_control": fs.newInode(ctx, root, 0444, newStaticFile("")),
+
+			// Many of the following stub files are features netstack doesn't
+			// support. The unsupported features return "0" to indicate they are
+			// disabled.
+			"tcp_base_mss":              fs.newInode(ctx, root, 0444, newStaticFile("1280")),
+			"tcp_dsack":                 fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_early_retrans":         fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_fack":                  fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_fastopen":              fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_fastopen_key":          fs.newInode(ctx, root, 0444, newStaticFile("")),
+			"tcp_invalid_ratelimit":     fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_mtu_probing":           fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_no_metrics_save":       fs.newInode(ctx, root, 0444, newStaticFile("1")),
+			"tcp_probe_interval":        fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_probe_threshold":       fs.newInode(ctx, root, 0444, newStaticFile("0")),
+			"tcp_retries1":              fs.newInode(ctx, root, 0444, newStaticFile("3")),
+			"tcp_rfc1337":               fs.newInode(ctx, root, 0444, newStaticFile("1")),
+			"tcp_slow_start_after_idle": fs.newInode(ctx, root, 0444, newStaticFile("1")),
+			"tcp_synack_retries":        fs.newInode(ctx, root, 0444, newStaticFile("5")),
+		}
+		core := map[string]kernfs.Inode{
+			"default_qdisc": fs.newInode(ctx, root, 0444, newStaticFile("pfifo_fast")),
+			"message_burst": fs.newInode(ctx, root, 0444, newStaticFile("10")),
+			"message_cost":  fs.newInode(ctx, root, 0444, newStaticFile("5")),
+			"optmem_max":    fs.newInode(ctx, root, 0444, newStaticFile("0")),
+		}
+
+		// Sysctls backed by the network stack are resolved in the network
+		// namespace of the calling task.
+		dirs := map[string]map[string]kernfs.Inode{"ipv4": ipv4, "core": core}
+		for i := range inet.Sysctls {
+			sysctl := &inet.Sysctls[i]
+			dir, name := path.Split(sysctl.Name)
+			mode := linux.FileMode(0644)
+			if sysctl.ReadOnly {
+				mode = 0444
+			}
+			dirs[path.Clean(dir)][name] = fs.newInode(ctx, root, mode, &netSysctlData{k: k, name: sysctl.Name})
+		}
+
 		contents = map[string]kernfs.Inode{
-			"ipv4": fs.newStaticDir(ctx, root, map[string]kernfs.Inode{
-				"ip_forward":          fs.newInode(ctx, root, 0444, &ipForwarding{stack: stack}),
-				"ip_local_port_range": fs.newInode(ctx, root, 0644, &portRange{stack: stack}),
-				"tcp_recovery":        fs.newInode(ctx, root, 0644, &tcpRecoveryData{stack: stack}),
-				"tcp_rmem":            fs.newInode(ctx, root, 0644, &tcpMemData{stack: stack, dir: tcpRMem}),
-				"tcp_sack":            fs.newInode(ctx, root, 0644, &tcpSackData{stack: stack}),
-				"tcp_wmem":            fs.newInode(ctx, root, 0644, &tcpMemData{stack: stack, dir: tcpWMem}),
-
-				// The following files are simple stubs until they are implemented in
-				// netstack, most of these files are configuration related. We use the
-				// value closest to the actual netstack behavior or any empty file, all
-				// of these files will have mode 0444 (read-only for all users).
-				"ip_local_reserved_ports": fs.newInode(ctx, root, 0444, newStaticFile("")),
-				"ipfrag_time":             fs.newInode(ctx, root, 0444, newStaticFile("30")),
-				"ip_nonlocal_bind":        fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"ip_no_pmtu_disc":         fs.newInode(ctx, root, 0444, newStaticFile("1")),
-
-				// tcp_allowed_congestion_control tell the user what they are able to
-				// do as an unprivledged process so we leave it empty.
-				"tcp_allowed_congestion_control":   fs.newInode(ctx, root, 0444, newStaticFile("")),
-				"tcp_available_congestion_control": fs.newInode(ctx, root, 0444, newStaticFile("reno")),
-				"tcp_congestion_control":           fs.newInode(ctx, root, 0444, newStaticFile("reno")),
-
-				// Many of the following stub files are features netstack doesn't
-				// support. The unsupported features return "0" to indicate they are
-				// disabled.
-				"tcp_base_mss":              fs.newInode(ctx, root, 0444, newStaticFile("1280")),
-				"tcp_dsack":                 fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_early_retrans":         fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_fack":                  fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_fastopen":              fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_fastopen_key":          fs.newInode(ctx, root, 0444, newStaticFile("")),
-				"tcp_invalid_ratelimit":     fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_keepalive_intvl":       fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_keepalive_probes":      fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_keepalive_time":        fs.newInode(ctx, root, 0444, newStaticFile("7200")),
-				"tcp_mtu_probing":           fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_no_metrics_save":       fs.newInode(ctx, root, 0444, newStaticFile("1")),
-				"tcp_probe_interval":        fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_probe_threshold":       fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"tcp_retries1":              fs.newInode(ctx, root, 0444, newStaticFile("3")),
-				"tcp_retries2":              fs.newInode(ctx, root, 0444, newStaticFile("15")),
-				"tcp_rfc1337":               fs.newInode(ctx, root, 0444, newStaticFile("1")),
-				"tcp_slow_start_after_idle": fs.newInode(ctx, root, 0444, newStaticFile("1")),
-				"tcp_synack_retries":        fs.newInode(ctx, root, 0444, newStaticFile("5")),
-				"tcp_syn_retries":           fs.newInode(ctx, root, 0444, newStaticFile("3")),
-				"tcp_timestamps":            fs.newInode(ctx, root, 0444, newStaticFile("1")),
-			}),
-			"core": fs.newStaticDir(ctx, root, map[string]kernfs.Inode{
-				"default_qdisc": fs.newInode(ctx, root, 0444, newStaticFile("pfifo_fast")),
-				"message_burst": fs.newInode(ctx, root, 0444, newStaticFile("10")),
-				"message_cost":  fs.newInode(ctx, root, 0444, newStaticFile("5")),
-				"optmem_max":    fs.newInode(ctx, root, 0444, newStaticFile("0")),
-				"rmem_default":  fs.newInode(ctx, root, 0444, newStaticFile("212992")),
-				"rmem_max":      fs.newInode(ctx, root, 0444, newStaticFile("212992")),
-				"somaxconn":     fs.newInode(ctx, root, 0444, newStaticFile("128")),
-				"wmem_default":  fs.newInode(ctx, root, 0444, newStaticFile("212992")),
-				"wmem_max":      fs.newInode(ctx, root, 0444, newStaticFile("212992")),
-			}),
+			"ipv4": fs.newStaticDir(ctx, root, ipv4),
+			"core": fs.newStaticDir(ctx, root, core),
 		}
 	}
 
@@ -188,6 +193,92 @@ func (*hostnameData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	return nil
 }
 
+// netSysctlData implements vfs.WritableDynamicBytesSource for the network
+// sysctls in inet.Sysctls. The sysctl is read and written in the network
+// namespace of the calling task.
+//
+// +stateify savable
+type netSysctlData struct {
+	kernfs.DynamicBytesFile
+
+	k *kernel.Kernel
+
+	// name is the inet.Sysctl.Name of the sysctl.
+	name string
+}
+
+var _ vfs.WritableDynamicBytesSource = (*netSysctlData)(nil)
+
+// netns returns the network namespace of the calling task, or the root network
+// namespace if there is no task. A reference is taken on the returned
+// namespace.
+func (d *netSysctlData) netns(ctx context.Context) *inet.Namespace {
+	if t := kernel.TaskFromContext(ctx); t != nil {
+		if netns := t.GetNetworkNamespace(); netns != nil {
+			return netns
+		}
+	}
+	netns := d.k.RootNetworkNamespace()
+	netns.IncRef()
+	return netns
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *netSysctlData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	netns := d.netns(ctx)
+	defer netns.DecRef(ctx)
+
+	v, err := netns.Sysctl(d.name)
+	if err != nil {
+		// The stack doesn't implement the sysctl, report the Linux default.
+		buf.WriteString(inet.LookupSysctl(d.name).Default)
+		buf.WriteString("\n")
+		return nil
+	}
+	buf.WriteString(v.String())
+	return nil
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (d *netSysctlData) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	if offset != 0 {
+		// No need to handle partial writes thus far.
+		return 0, linuxerr.EINVAL
+	}
+
+	netns := d.netns(ctx)
+	defer netns.DecRef(ctx)
+	if !auth.CredentialsFromContext(ctx).HasCapabilityIn(linux.CAP_NET_ADMIN, netns.UserNamespace()) {
+		return 0, linuxerr.EPERM
+	}
+	return writeNetSysctl(ctx, netns, d.name, src)
+}
+
+// writeNetSysctl parses src as a new value for the network sysctl name and sets
+// it in netns.
+func writeNetSysctl(ctx context.Context, netns *inet.Namespace, name string, src usermem.IOSequence) (int64, error) {
+	// Limit input size so as not to impact performance if input size is large.
+	src = src.TakeFirst(hostarch.PageSize - 1)
+	buf := make([]byte, src.NumBytes())
+	n, err := src.CopyIn(ctx, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	cur, err := netns.Sysctl(name)
+	if err != nil {
+		return 0, err
+	}
+	v, err := inet.LookupSysctl(name).Parse(string(buf[:n]), cur)
+	if err != nil {
+		return 0, err
+	}
+	if err := netns.SetSysctl(name, v); err != nil {
+		return 0, err
+	}
+	return int64(n), nil
+}
+
 // tcpSackData implements vfs.WritableDynamicBytesSource for
 // /proc/sys/net/tcp_sack.
 //
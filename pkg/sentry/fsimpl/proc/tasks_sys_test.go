@@ -16,14 +16,18 @@ package proc
 
 import (
 	"bytes"
+	"fmt"
 	"reflect"
 	"slices"
 	"testing"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/sentry/contexttest"
 	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/usermem"
 )
 
@@ -80,6 +84,109 @@ func TestIfinet6(t *testing.T) {
 	}
 }
 
+func TestIPv6Route(t *testing.T) {
+	s := newIPv6TestStack()
+	s.InterfacesMap[1] = inet.Interface{Name: "eth0"}
+	s.RouteList = []inet.Route{
+		{
+			Family:          linux.AF_INET,
+			DstLen:          8,
+			DstAddr:         []byte{10, 0, 0, 0},
+			OutputInterface: 1,
+		},
+		{
+			Family:          linux.AF_INET6,
+			DstLen:          64,
+			DstAddr:         []byte("\xfe\x80\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+			OutputInterface: 1,
+		},
+		{
+			Family:          linux.AF_INET6,
+			GatewayAddr:     []byte("\xfe\x80\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01"),
+			OutputInterface: 1,
+		},
+	}
+	want := "fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000000 00000000 00000000 00000001     eth0\n" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000000 00000000 00000000 00000003     eth0\n"
+
+	n := &netIPv6RouteData{stack: s}
+	var buf bytes.Buffer
+	if err := n.Generate(contexttest.Context(t), &buf); err != nil {
+		t.Fatalf("n.Generate() failed: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("n.Generate() generated:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestIGMP(t *testing.T) {
+	s := newIPv6TestStack()
+	s.InterfacesMap[1] = inet.Interface{Name: "lo"}
+	s.InterfacesMap[2] = inet.Interface{Name: "eth0"}
+	s.MulticastGroupsMap[2] = []inet.MulticastGroup{
+		{Family: linux.AF_INET, Addr: []byte{224, 0, 0, 1}, Users: 1},
+		{Family: linux.AF_INET6, Addr: []byte("\xff\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01"), Users: 2},
+	}
+
+	for _, test := range []struct {
+		name string
+		n    dynamicInode
+		want string
+	}{
+		{
+			name: "igmp",
+			n:    &netIGMPData{stack: s},
+			want: "Idx\tDevice    : Count Querier\tGroup    Users Timer\tReporter\n" +
+				"1\tlo        :     0      V3\n" +
+				"2\teth0      :     1      V3\n" +
+				fmt.Sprintf("\t\t\t\t%08X     1 0:00000000\t\t0\n", hostarch.ByteOrder.Uint32([]byte{224, 0, 0, 1})),
+		},
+		{
+			name: "igmp6",
+			n:    &netIGMP6Data{stack: s},
+			want: "2    eth0            ff020000000000000000000000000001     2 00000000 0\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := test.n.Generate(contexttest.Context(t), &buf); err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("Generate() generated:\n%q\nwant:\n%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestARP(t *testing.T) {
+	s := inet.NewTestStack()
+	s.InterfacesMap[2] = inet.Interface{
+		DeviceType: linux.ARPHRD_ETHER,
+		Name:       "eth0",
+		Addr:       []byte{0x02, 0, 0, 0, 0, 0x01},
+	}
+	s.NeighborsMap[2] = []inet.Neighbor{
+		{Family: linux.AF_INET, State: linux.NUD_REACHABLE, Addr: []byte{10, 0, 0, 1}, LinkAddr: []byte{0x02, 0, 0, 0, 0, 0x02}},
+		{Family: linux.AF_INET, State: linux.NUD_PERMANENT, Addr: []byte{10, 0, 0, 2}, LinkAddr: []byte{0x02, 0, 0, 0, 0, 0x03}},
+		{Family: linux.AF_INET, State: linux.NUD_INCOMPLETE, Addr: []byte{10, 0, 0, 3}},
+		{Family: linux.AF_INET6, State: linux.NUD_REACHABLE, Addr: []byte("\xfe\x80\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01"), LinkAddr: []byte{0x02, 0, 0, 0, 0, 0x02}},
+	}
+	want := "IP address       HW type     Flags       HW address            Mask     Device\n" +
+		"10.0.0.1         0x1         0x2         02:00:00:00:00:02     *        eth0\n" +
+		"10.0.0.2         0x1         0x6         02:00:00:00:00:03     *        eth0\n" +
+		"10.0.0.3         0x1         0x0         00:00:00:00:00:00     *        eth0\n"
+
+	n := &netARPData{stack: s}
+	var buf bytes.Buffer
+	if err := n.Generate(contexttest.Context(t), &buf); err != nil {
+		t.Fatalf("n.Generate() failed: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("n.Generate() generated:\n%q\nwant:\n%q", got, want)
+	}
+}
+
 // TestIPForwarding tests the implementation of
 // /proc/sys/net/ipv4/ip_forwarding
 func TestConfigureIPForwarding(t *testing.T) {
@@ -149,6 +256,82 @@ func TestConfigureIPForwarding(t *testing.T) {
 	}
 }
 
+// TestWriteNetSysctl tests writes to the network sysctls in inet.Sysctls.
+func TestWriteNetSysctl(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name    string
+		initial inet.SysctlValue
+		str     string
+		want    inet.SysctlValue
+		wantErr error
+	}{
+		{
+			name:    "ipv4/tcp_keepalive_time",
+			initial: inet.IntSysctl(7200),
+			str:     "600\n",
+			want:    inet.IntSysctl(600),
+		},
+		{
+			name:    "ipv4/tcp_keepalive_time",
+			initial: inet.IntSysctl(7200),
+			str:     "0",
+			want:    inet.IntSysctl(7200),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "ipv4/tcp_syn_retries",
+			initial: inet.IntSysctl(6),
+			str:     "128",
+			want:    inet.IntSysctl(6),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "ipv4/tcp_syncookies",
+			initial: inet.IntSysctl(1),
+			str:     "0",
+			want:    inet.IntSysctl(1),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "ipv4/tcp_syncookies",
+			initial: inet.IntSysctl(1),
+			str:     "two",
+			want:    inet.IntSysctl(1),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "ipv4/tcp_congestion_control",
+			initial: inet.SysctlValue{Str: "reno"},
+			str:     "cubic\n",
+			want:    inet.SysctlValue{Str: "cubic"},
+		},
+	} {
+		t.Run(fmt.Sprintf("%s=%q", tc.name, tc.str), func(t *testing.T) {
+			s := inet.NewTestStack()
+			s.SysctlMap[tc.name] = tc.initial
+			netns := inet.NewRootNamespace(s, nil, auth.NewRootUserNamespace())
+
+			src := usermem.BytesIOSequence([]byte(tc.str))
+			n, err := writeNetSysctl(ctx, netns, tc.name, src)
+			if err != tc.wantErr {
+				t.Errorf("writeNetSysctl(%q, %q) = %v, want %v", tc.name, tc.str, err, tc.wantErr)
+			}
+			if err == nil && n != int64(len(tc.str)) {
+				t.Errorf("writeNetSysctl(%q, %q) wrote %d bytes, want %d", tc.name, tc.str, n, len(tc.str))
+			}
+			got, err := netns.Sysctl(tc.name)
+			if err != nil {
+				t.Fatalf("netns.Sysctl(%q) = %v", tc.name, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("netns.Sysctl(%q) = %+v, want %+v", tc.name, got, tc.want)
+			}
+		})
+	}
+}
+
 func TestParseInt32Vec(t *testing.T) {
 	ctx := context.Background()
 	tests := []struct {
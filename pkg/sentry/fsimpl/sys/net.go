@@ -0,0 +1,479 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+// Network devices are exposed in /sys/devices/virtual/net/<ifname>/, and
+// /sys/class/net/<ifname> is a symlink to that directory. Both directories are
+// generated on demand from the interfaces of the network namespace that
+// mounted sysfs, similar to Linux's net/core/net-sysfs.c.
+
+// netStack returns the network stack of the network namespace associated
+// with fs, or nil if there is none.
+func (fs *filesystem) netStack() inet.Stack {
+	if fs.netns == nil {
+		return nil
+	}
+	return fs.netns.Stack()
+}
+
+// netInterfaces returns the network interfaces of fs's network namespace.
+func (fs *filesystem) netInterfaces() map[int32]inet.Interface {
+	stack := fs.netStack()
+	if stack == nil {
+		return nil
+	}
+	return stack.Interfaces()
+}
+
+// netInterface returns the network interface with index idx. It returns
+// ENODEV if the interface no longer exists.
+func (fs *filesystem) netInterface(idx int32) (inet.Interface, error) {
+	iface, ok := fs.netInterfaces()[idx]
+	if !ok {
+		return inet.Interface{}, linuxerr.ENODEV
+	}
+	return iface, nil
+}
+
+// netDir implements kernfs.Inode for /sys/class/net and
+// /sys/devices/virtual/net.
+//
+// +stateify savable
+type netDir struct {
+	dir
+
+	fs *filesystem
+
+	// symlinks is true if the directory contains symlinks to the device
+	// directories (/sys/class/net) rather than the device directories
+	// themselves (/sys/devices/virtual/net).
+	symlinks bool
+
+	// inosMu protects inos.
+	inosMu sync.Mutex `state:"nosave"`
+
+	// inos holds the inode numbers of the directory's entries, so that they
+	// are stable across lookups and listings.
+	//
+	// +checklocks:inosMu
+	inos map[netDeviceKey]uint64
+}
+
+// netDeviceKey identifies a network device entry in a netDir.
+//
+// +stateify savable
+type netDeviceKey struct {
+	idx  int32
+	name string
+}
+
+func (fs *filesystem) newNetDir(ctx context.Context, creds *auth.Credentials, symlinks bool) kernfs.Inode {
+	d := &netDir{
+		fs:       fs,
+		symlinks: symlinks,
+		inos:     make(map[netDeviceKey]uint64),
+	}
+	d.InodeAttrs.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), linux.ModeDirectory|0755)
+	d.OrderedChildren.Init(kernfs.OrderedChildrenOptions{})
+	d.InitRefs()
+	return d
+}
+
+// entryIno returns the inode number of the entry for the network interface
+// with index idx, allocating one if the entry has none yet. ifaces are the
+// current network interfaces; entries of interfaces that no longer exist are
+// forgotten.
+func (d *netDir) entryIno(ifaces map[int32]inet.Interface, idx int32) uint64 {
+	d.inosMu.Lock()
+	defer d.inosMu.Unlock()
+	for key := range d.inos {
+		if iface, ok := ifaces[key.idx]; !ok || iface.Name != key.name {
+			delete(d.inos, key)
+		}
+	}
+	key := netDeviceKey{idx: idx, name: ifaces[idx].Name}
+	ino, ok := d.inos[key]
+	if !ok {
+		ino = d.fs.NextIno()
+		d.inos[key] = ino
+	}
+	return ino
+}
+
+// Lookup implements kernfs.inodeDirectory.Lookup.
+func (d *netDir) Lookup(ctx context.Context, name string) (kernfs.Inode, error) {
+	ifaces := d.fs.netInterfaces()
+	for idx, iface := range ifaces {
+		if iface.Name != name {
+			continue
+		}
+		ino := d.entryIno(ifaces, idx)
+		if d.symlinks {
+			return d.fs.newNetDeviceSymlink(ctx, auth.CredentialsFromContext(ctx), idx, name, ino), nil
+		}
+		return d.fs.newNetDeviceDir(ctx, auth.CredentialsFromContext(ctx), idx, ino), nil
+	}
+	return nil, linuxerr.ENOENT
+}
+
+// IterDirents implements kernfs.inodeDirectory.IterDirents.
+func (d *netDir) IterDirents(ctx context.Context, mnt *vfs.Mount, cb vfs.IterDirentsCallback, offset, relOffset int64) (int64, error) {
+	ifaces := d.fs.netInterfaces()
+	idxs := make([]int, 0, len(ifaces))
+	for idx := range ifaces {
+		idxs = append(idxs, int(idx))
+	}
+	if relOffset >= int64(len(idxs)) {
+		return offset, nil
+	}
+	sort.Ints(idxs)
+
+	typ := uint8(linux.DT_DIR)
+	if d.symlinks {
+		typ = linux.DT_LNK
+	}
+	for _, idx := range idxs[relOffset:] {
+		dirent := vfs.Dirent{
+			Name:    ifaces[int32(idx)].Name,
+			Type:    typ,
+			Ino:     d.entryIno(ifaces, int32(idx)),
+			NextOff: offset + 1,
+		}
+		if err := cb.Handle(dirent); err != nil {
+			return offset, err
+		}
+		offset++
+	}
+	return offset, nil
+}
+
+// netDeviceValid returns true if the network interface with index idx still
+// exists and is named name.
+func (fs *filesystem) netDeviceValid(idx int32, name string) bool {
+	iface, err := fs.netInterface(idx)
+	return err == nil && iface.Name == name
+}
+
+// netDeviceSymlink implements kernfs.Inode for /sys/class/net/<ifname>.
+//
+// +stateify savable
+type netDeviceSymlink struct {
+	kernfs.StaticSymlink
+
+	fs  *filesystem
+	idx int32
+}
+
+func (fs *filesystem) newNetDeviceSymlink(ctx context.Context, creds *auth.Credentials, idx int32, name string, ino uint64) kernfs.Inode {
+	s := &netDeviceSymlink{fs: fs, idx: idx}
+	s.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, ino, "../../devices/virtual/net/"+name)
+	return s
+}
+
+// Valid implements kernfs.Inode.Valid.
+func (s *netDeviceSymlink) Valid(ctx context.Context, parent *kernfs.Dentry, name string) bool {
+	return s.fs.netDeviceValid(s.idx, name)
+}
+
+// netDeviceDir implements kernfs.Inode for /sys/devices/virtual/net/<ifname>.
+//
+// +stateify savable
+type netDeviceDir struct {
+	dir
+
+	fs  *filesystem
+	idx int32
+}
+
+// netDeviceAttrs are the read-only attributes of a network device, see
+// Linux's net/core/net-sysfs.c:net_class_attrs.
+var netDeviceAttrs = []string{
+	"addr_assign_type",
+	"addr_len",
+	"address",
+	"broadcast",
+	"carrier",
+	"dev_id",
+	"dev_port",
+	"dormant",
+	"flags",
+	"ifalias",
+	"ifindex",
+	"iflink",
+	"link_mode",
+	"operstate",
+	"speed",
+	"type",
+	"uevent",
+}
+
+// netDeviceWritableAttrs are the writable attributes of a network device.
+var netDeviceWritableAttrs = []string{
+	"mtu",
+	"tx_queue_len",
+}
+
+// netDeviceStats maps the files in /sys/class/net/<ifname>/statistics/ to
+// their index in inet.StatDev.
+var netDeviceStats = map[string]int{
+	"rx_bytes":          0,
+	"rx_packets":        1,
+	"rx_errors":         2,
+	"rx_dropped":        3,
+	"rx_fifo_errors":    4,
+	"rx_frame_errors":   5,
+	"rx_compressed":     6,
+	"multicast":         7,
+	"tx_bytes":          8,
+	"tx_packets":        9,
+	"tx_errors":         10,
+	"tx_dropped":        11,
+	"tx_fifo_errors":    12,
+	"collisions":        13,
+	"tx_carrier_errors": 14,
+	"tx_compressed":     15,
+}
+
+func (fs *filesystem) newNetDeviceDir(ctx context.Context, creds *auth.Credentials, idx int32, ino uint64) kernfs.Inode {
+	children := make(map[string]kernfs.Inode)
+	for _, attr := range netDeviceAttrs {
+		children[attr] = fs.newNetDeviceAttrFile(ctx, creds, idx, attr, defaultSysMode)
+	}
+	for _, attr := range netDeviceWritableAttrs {
+		children[attr] = fs.newNetDeviceWritableAttrFile(ctx, creds, idx, attr)
+	}
+	stats := make(map[string]kernfs.Inode)
+	for stat := range netDeviceStats {
+		stats[stat] = fs.newNetDeviceAttrFile(ctx, creds, idx, stat, defaultSysMode)
+	}
+	children["statistics"] = fs.newDir(ctx, creds, defaultSysDirMode, stats)
+	children["subsystem"] = kernfs.NewStaticSymlink(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), "../../../../class/net")
+
+	d := &netDeviceDir{fs: fs, idx: idx}
+	d.InodeAttrs.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, ino, linux.ModeDirectory|0755)
+	d.OrderedChildren.Init(kernfs.OrderedChildrenOptions{})
+	d.InitRefs()
+	d.IncLinks(d.OrderedChildren.Populate(children))
+	return d
+}
+
+// Valid implements kernfs.Inode.Valid.
+func (d *netDeviceDir) Valid(ctx context.Context, parent *kernfs.Dentry, name string) bool {
+	return d.fs.netDeviceValid(d.idx, name)
+}
+
+// netDeviceAttrFile implements vfs.DynamicBytesSource for the attribute files
+// of a network device.
+//
+// +stateify savable
+type netDeviceAttrFile struct {
+	implStatFS
+	kernfs.DynamicBytesFile
+
+	fs   *filesystem
+	idx  int32
+	attr string
+}
+
+var _ vfs.DynamicBytesSource = (*netDeviceAttrFile)(nil)
+
+func (fs *filesystem) newNetDeviceAttrFile(ctx context.Context, creds *auth.Credentials, idx int32, attr string, mode linux.FileMode) kernfs.Inode {
+	f := &netDeviceAttrFile{fs: fs, idx: idx, attr: attr}
+	f.DynamicBytesFile.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), f, mode)
+	return f
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (f *netDeviceAttrFile) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	iface, err := f.fs.netInterface(f.idx)
+	if err != nil {
+		return err
+	}
+	running := iface.Flags&linux.IFF_RUNNING != 0
+	loopback := iface.DeviceType == linux.ARPHRD_LOOPBACK
+
+	if i, ok := netDeviceStats[f.attr]; ok {
+		var stats inet.StatDev
+		if err := f.fs.netStack().Statistics(&stats, iface.Name); err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%d\n", stats[i])
+		return nil
+	}
+
+	switch f.attr {
+	case "addr_assign_type":
+		// NET_ADDR_PERM.
+		buf.WriteString("0\n")
+	case "addr_len":
+		fmt.Fprintf(buf, "%d\n", netDeviceAddrLen(&iface))
+	case "address":
+		buf.WriteString(formatHardwareAddr(netDeviceAddr(&iface)) + "\n")
+	case "broadcast":
+		brd := make([]byte, netDeviceAddrLen(&iface))
+		if len(iface.Addr) > 0 {
+			brd = bytes.Repeat([]byte{0xff}, len(iface.Addr))
+		}
+		buf.WriteString(formatHardwareAddr(brd) + "\n")
+	case "carrier":
+		if !running {
+			return linuxerr.EINVAL
+		}
+		buf.WriteString("1\n")
+	case "dev_id", "link_mode":
+		buf.WriteString("0x0\n")
+	case "dev_port", "dormant":
+		buf.WriteString("0\n")
+	case "flags":
+		fmt.Fprintf(buf, "0x%x\n", iface.Flags)
+	case "ifalias":
+		buf.WriteString("\n")
+	case "ifindex", "iflink":
+		fmt.Fprintf(buf, "%d\n", f.idx)
+	case "mtu":
+		fmt.Fprintf(buf, "%d\n", iface.MTU)
+	case "operstate":
+		// See Linux's net/core/net-sysfs.c:operstates.
+		switch {
+		case loopback:
+			buf.WriteString("unknown\n")
+		case running:
+			buf.WriteString("up\n")
+		default:
+			buf.WriteString("down\n")
+		}
+	case "speed":
+		// The link speed of netstack devices is unknown, and the loopback
+		// device doesn't report one at all.
+		if !running || loopback {
+			return linuxerr.EINVAL
+		}
+		buf.WriteString("-1\n")
+	case "tx_queue_len":
+		fmt.Fprintf(buf, "%d\n", iface.TxQueueLen)
+	case "type":
+		fmt.Fprintf(buf, "%d\n", iface.DeviceType)
+	case "uevent":
+		fmt.Fprintf(buf, "INTERFACE=%s\nIFINDEX=%d\n", iface.Name, f.idx)
+	default:
+		return linuxerr.EINVAL
+	}
+	return nil
+}
+
+// netDeviceWritableAttrFile implements vfs.WritableDynamicBytesSource for
+// the writable attribute files of a network device.
+//
+// +stateify savable
+type netDeviceWritableAttrFile struct {
+	netDeviceAttrFile
+}
+
+var _ vfs.WritableDynamicBytesSource = (*netDeviceWritableAttrFile)(nil)
+
+func (fs *filesystem) newNetDeviceWritableAttrFile(ctx context.Context, creds *auth.Credentials, idx int32, attr string) kernfs.Inode {
+	f := &netDeviceWritableAttrFile{netDeviceAttrFile{fs: fs, idx: idx, attr: attr}}
+	f.DynamicBytesFile.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), f, 0644)
+	return f
+}
+
+// Write implements vfs.WritableDynamicBytesSource.Write.
+func (f *netDeviceWritableAttrFile) Write(ctx context.Context, _ *vfs.FileDescription, src usermem.IOSequence, offset int64) (int64, error) {
+	// See Linux's net/core/net-sysfs.c:netdev_store().
+	if creds := auth.CredentialsFromContext(ctx); !creds.HasCapabilityIn(linux.CAP_NET_ADMIN, f.fs.netns.UserNamespace()) {
+		return 0, linuxerr.EPERM
+	}
+	if offset != 0 {
+		// No need to handle partial writes thus far.
+		return 0, linuxerr.EINVAL
+	}
+	if src.NumBytes() == 0 {
+		return 0, nil
+	}
+
+	// Limit input size so as not to impact performance if input size is large.
+	src = src.TakeFirst(hostarch.PageSize - 1)
+	str, err := usermem.CopyStringIn(ctx, src.IO, src.Addrs.Head().Start, int(src.Addrs.Head().Length()), src.Opts)
+	if err != nil && err != linuxerr.ENAMETOOLONG {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(str), 0, 32)
+	if err != nil {
+		return 0, linuxerr.EINVAL
+	}
+
+	stack := f.fs.netStack()
+	if _, err := f.fs.netInterface(f.idx); err != nil {
+		return 0, err
+	}
+	switch f.attr {
+	case "mtu":
+		err = stack.SetInterfaceMTU(f.idx, uint32(v))
+	case "tx_queue_len":
+		err = stack.SetInterfaceTxQueueLen(f.idx, uint32(v))
+	default:
+		err = linuxerr.EINVAL
+	}
+	if err != nil {
+		return 0, err
+	}
+	return src.NumBytes(), nil
+}
+
+// netDeviceAddrLen returns the hardware address length of iface.
+func netDeviceAddrLen(iface *inet.Interface) int {
+	if len(iface.Addr) > 0 {
+		return len(iface.Addr)
+	}
+	// Devices without a hardware address, e.g. loopback, still report an
+	// all-zero Ethernet address.
+	return 6
+}
+
+// netDeviceAddr returns the hardware address of iface.
+func netDeviceAddr(iface *inet.Interface) []byte {
+	if len(iface.Addr) > 0 {
+		return iface.Addr
+	}
+	return make([]byte, netDeviceAddrLen(iface))
+}
+
+// formatHardwareAddr formats addr as colon-separated hex bytes.
+func formatHardwareAddr(addr []byte) string {
+	parts := make([]string, 0, len(addr))
+	for _, b := range addr {
+		parts = append(parts, fmt.Sprintf("%02x", b))
+	}
+	return strings.Join(parts, ":")
+}
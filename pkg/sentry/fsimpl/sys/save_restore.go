@@ -54,12 +54,16 @@ func (fs *filesystem) CompleteRestore(ctx context.Context, opts vfs.CompleteRest
 	}
 
 	classSub := map[string]kernfs.Inode{
+		"net":          fs.newNetDir(ctx, creds, true /* symlinks */),
 		"power_supply": fs.newDir(ctx, creds, defaultSysDirMode, nil),
 	}
 	devicesSub := map[string]kernfs.Inode{
 		"system": fs.newDir(ctx, creds, defaultSysDirMode, map[string]kernfs.Inode{
 			"cpu": cpuDir(ctx, fs, creds),
 		}),
+		"virtual": fs.newDir(ctx, creds, defaultSysDirMode, map[string]kernfs.Inode{
+			"net": fs.newNetDir(ctx, creds, false /* symlinks */),
+		}),
 	}
 	busSub := make(map[string]kernfs.Inode)
 	kernelSub := kernelDir(ctx, fs, creds)
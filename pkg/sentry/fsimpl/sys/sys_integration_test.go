@@ -115,6 +115,21 @@ func TestCgroupMountpointExists(t *testing.T) {
 	s.AssertAllDirentTypes(s.ListDirents(pop), map[string]testutil.DirentType{ /*empty*/ })
 }
 
+func TestNetDirsExist(t *testing.T) {
+	// Note: The test kernel has no network stack, so no network devices are
+	// listed.
+	s := newTestSystem(t, "" /*pciTestDir*/)
+	defer s.Destroy()
+	for _, dir := range []string{"/class/net", "/devices/virtual/net"} {
+		pop := s.PathOpAtRoot(dir)
+		s.AssertAllDirentTypes(s.ListDirents(pop), map[string]testutil.DirentType{ /*empty*/ })
+	}
+	pop := s.PathOpAtRoot("/class/net/lo")
+	if _, err := s.VFS.OpenAt(s.Ctx, s.Creds, pop, &vfs.OpenOptions{}); err == nil {
+		t.Errorf("OpenAt(%q) succeeded, want ENOENT", "/class/net/lo")
+	}
+}
+
 // Check that sysfs creates the required PCI paths for V4 TPUs.
 func TestEnableTPUProxyPathsV4(t *testing.T) {
 	// Set up the fs tree that will be mirrored in the sentry.
@@ -16,6 +16,11 @@ package sys
 
 import (
 	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/contexttest"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
 )
 
 func TestFullCPUMask(t *testing.T) {
@@ -74,3 +79,69 @@ func TestOneCPUMask(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatHardwareAddr(t *testing.T) {
+	for _, test := range []struct {
+		addr []byte
+		want string
+	}{
+		{nil, ""},
+		{[]byte{0, 0, 0, 0, 0, 0}, "00:00:00:00:00:00"},
+		{[]byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}, "02:42:ac:11:00:02"},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "ff:ff:ff:ff:ff:ff"},
+	} {
+		if got := formatHardwareAddr(test.addr); got != test.want {
+			t.Errorf("formatHardwareAddr(%v): got %s, want %s", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestNetDirInos(t *testing.T) {
+	ctx := contexttest.Context(t)
+	creds := auth.CredentialsFromContext(ctx)
+	stack := inet.NewTestStack()
+	stack.InterfacesMap[1] = inet.Interface{Name: "lo"}
+	stack.InterfacesMap[2] = inet.Interface{Name: "eth0"}
+	fs := &filesystem{netns: inet.NewRootNamespace(stack, nil, auth.NewRootUserNamespace())}
+
+	for _, symlinks := range []bool{true, false} {
+		d := fs.newNetDir(ctx, creds, symlinks).(*netDir)
+		listInos := func() map[string]uint64 {
+			inos := make(map[string]uint64)
+			if _, err := d.IterDirents(ctx, nil, vfs.IterDirentsCallbackFunc(func(dirent vfs.Dirent) error {
+				inos[dirent.Name] = dirent.Ino
+				return nil
+			}), 0, 0); err != nil {
+				t.Fatalf("IterDirents failed: %v", err)
+			}
+			return inos
+		}
+
+		// Inode numbers are stable across listings, and match the inodes
+		// returned by Lookup.
+		first := listInos()
+		if len(first) != 2 || first["lo"] == first["eth0"] {
+			t.Fatalf("got entries %v, want distinct inode numbers for lo and eth0", first)
+		}
+		if second := listInos(); second["lo"] != first["lo"] || second["eth0"] != first["eth0"] {
+			t.Errorf("got inode numbers %v in the second listing, want %v", second, first)
+		}
+		inode, err := d.Lookup(ctx, "eth0")
+		if err != nil {
+			t.Fatalf("Lookup(eth0) failed: %v", err)
+		}
+		if got := inode.(interface{ Ino() uint64 }).Ino(); got != first["eth0"] {
+			t.Errorf("Lookup(eth0) got inode number %d, want %d", got, first["eth0"])
+		}
+		inode.DecRef(ctx)
+
+		// A new interface that reuses the index of a removed one gets a new
+		// inode number.
+		delete(stack.InterfacesMap, 2)
+		stack.InterfacesMap[2] = inet.Interface{Name: "eth1"}
+		if third := listInos(); third["eth1"] == first["eth0"] || third["lo"] != first["lo"] {
+			t.Errorf("got inode numbers %v after replacing eth0 with eth1, want lo's unchanged and a new one for eth1", third)
+		}
+		stack.InterfacesMap[2] = inet.Interface{Name: "eth0"}
+	}
+}
@@ -466,6 +466,9 @@ func (d *dentry) Watches() *vfs.Watches {
 // OnZeroWatches implements vfs.Dentry.OnZeroWatches.
 func (d *dentry) OnZeroWatches(context.Context) {}
 
+// OnFirstWatch implements vfs.Dentry.OnFirstWatch.
+func (d *dentry) OnFirstWatch(context.Context) {}
+
 // inode represents a filesystem object.
 //
 // +stateify savable
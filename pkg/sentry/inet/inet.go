@@ -38,6 +38,10 @@ type Stack interface {
 	// interface indexes to a slice of associated interface address properties.
 	InterfaceAddrs() map[int32][]InterfaceAddr
 
+	// MulticastGroups returns the multicast groups joined by each network
+	// interface as a mapping from interface indexes to a slice of groups.
+	MulticastGroups() map[int32][]MulticastGroup
+
 	// AddInterfaceAddr adds an address to the network interface identified by
 	// idx.
 	AddInterfaceAddr(idx int32, addr InterfaceAddr) error
@@ -49,6 +53,14 @@ type Stack interface {
 	// identified by idx.
 	RemoveInterfaceAddr(idx int32, addr InterfaceAddr) error
 
+	// SetInterfaceMTU sets the MTU of the network interface identified by
+	// idx.
+	SetInterfaceMTU(idx int32, mtu uint32) error
+
+	// SetInterfaceTxQueueLen sets the transmit queue length of the network
+	// interface identified by idx.
+	SetInterfaceTxQueueLen(idx int32, qlen uint32) error
+
 	// SupportsIPv6 returns true if the stack supports IPv6 connectivity.
 	SupportsIPv6() bool
 
@@ -79,6 +91,14 @@ type Stack interface {
 	// SetTCPRecovery attempts to change TCP loss detection algorithm.
 	SetTCPRecovery(recovery TCPLossRecovery) error
 
+	// Sysctl returns the value of the network sysctl name, which is one of
+	// Sysctls. It returns EOPNOTSUPP if the stack doesn't implement it.
+	Sysctl(name string) (SysctlValue, error)
+
+	// SetSysctl sets the network sysctl name to v, which has already been
+	// validated by Sysctl.Parse.
+	SetSysctl(name string, v SysctlValue) error
+
 	// Statistics reports stack statistics.
 	Statistics(stat any, arg string) error
 
@@ -91,6 +111,26 @@ type Stack interface {
 	// NewRoute adds the given route to the network stack's route table.
 	NewRoute(ctx context.Context, msg *nlmsg.Message) *syserr.Error
 
+	// RoutingRules returns the network stack's policy routing rules, sorted
+	// by priority.
+	RoutingRules() []RoutingRule
+
+	// NewRoutingRule adds the given policy routing rule.
+	NewRoutingRule(ctx context.Context, msg *nlmsg.Message) *syserr.Error
+
+	// RemoveRoutingRule deletes the specified policy routing rule.
+	RemoveRoutingRule(ctx context.Context, msg *nlmsg.Message) *syserr.Error
+
+	// Neighbors returns the neighbor table entries of each network interface
+	// as a mapping from interface indexes to a slice of entries.
+	Neighbors() map[int32][]Neighbor
+
+	// NewNeighbor adds or replaces the given neighbor table entry.
+	NewNeighbor(ctx context.Context, msg *nlmsg.Message) *syserr.Error
+
+	// RemoveNeighbor deletes the specified neighbor table entry.
+	RemoveNeighbor(ctx context.Context, msg *nlmsg.Message) *syserr.Error
+
 	// Pause pauses the network stack before save.
 	Pause()
 
@@ -160,6 +200,9 @@ type Interface struct {
 	// MTU is the maximum transmission unit.
 	MTU uint32
 
+	// TxQueueLen is the transmit queue length.
+	TxQueueLen uint32
+
 	// Features are the device features queried from the host at
 	// stack creation time. These are immutable after startup.
 	Features []linux.EthtoolGetFeaturesBlock
@@ -198,6 +241,19 @@ type TCPBufferSize struct {
 // interface.
 type StatDev [16]uint64
 
+// MulticastGroup contains information about a multicast group joined by a
+// network interface.
+type MulticastGroup struct {
+	// Family is the address family, a Linux AF_* constant.
+	Family uint8
+
+	// Addr is the group address.
+	Addr []byte
+
+	// Users is the number of times the group was joined.
+	Users uint64
+}
+
 // Route contains information about a network route.
 type Route struct {
 	// Family is the address family, a Linux AF_* constant.
@@ -212,8 +268,8 @@ type Route struct {
 	// TOS is the Type of Service filter.
 	TOS uint8
 
-	// Table is the routing table ID.
-	Table uint8
+	// Table is the routing table ID (RTA_TABLE).
+	Table uint32
 
 	// Protocol is the route origin, a Linux RTPROT_* constant.
 	Protocol uint8
@@ -238,6 +294,75 @@ type Route struct {
 
 	// GatewayAddr is the route gateway address (RTA_GATEWAY).
 	GatewayAddr []byte
+
+	// Priority is the route priority, also known as metric (RTA_PRIORITY).
+	Priority uint32
+}
+
+// RoutingRule contains information about a policy routing rule.
+type RoutingRule struct {
+	// Family is the address family, a Linux AF_* constant.
+	Family uint8
+
+	// DstLen is the length of the destination prefix.
+	DstLen uint8
+
+	// SrcLen is the length of the source prefix.
+	SrcLen uint8
+
+	// Action is the rule action, a Linux FR_ACT_* constant.
+	Action uint8
+
+	// Flags are rule flags, Linux FIB_RULE_* constants.
+	Flags uint32
+
+	// Table is the routing table ID (FRA_TABLE).
+	Table uint32
+
+	// Priority is the rule priority (FRA_PRIORITY).
+	Priority uint32
+
+	// DstAddr is the destination prefix (FRA_DST).
+	DstAddr []byte
+
+	// SrcAddr is the source prefix (FRA_SRC).
+	SrcAddr []byte
+
+	// InputInterface is the input interface name (FRA_IIFNAME).
+	InputInterface string
+
+	// OutputInterface is the output interface name (FRA_OIFNAME).
+	OutputInterface string
+
+	// Mark is the firewall mark (FRA_FWMARK).
+	Mark uint32
+
+	// MarkMask is the firewall mark mask (FRA_FWMASK).
+	MarkMask uint32
+
+	// UIDRange is the range of matching UIDs (FRA_UID_RANGE). It is only
+	// valid if HasUIDRange is true.
+	UIDRange    linux.FibRuleUIDRange
+	HasUIDRange bool
+}
+
+// Neighbor contains information about a neighbor table entry.
+type Neighbor struct {
+	// Family is the address family, a Linux AF_* constant.
+	Family uint8
+
+	// State is the entry state, a Linux NUD_* constant.
+	State uint16
+
+	// Flags are entry flags, Linux NTF_* constants.
+	Flags uint8
+
+	// Addr is the network layer address of the neighbor (NDA_DST).
+	Addr []byte
+
+	// LinkAddr is the link layer address of the neighbor (NDA_LLADDR). It is
+	// empty if the address has not been resolved yet.
+	LinkAddr []byte
 }
 
 // Below SNMP metrics are from Linux/usr/include/linux/snmp.h.
@@ -260,6 +385,18 @@ type StatSNMPUDP [8]uint64
 // StatSNMPUDPLite describes UdpLite line of /proc/net/snmp.
 type StatSNMPUDPLite [8]uint64
 
+// StatSNMP6IP describes the Ip6 lines of /proc/net/snmp6.
+type StatSNMP6IP [32]uint64
+
+// StatSNMP6ICMP describes the Icmp6 lines of /proc/net/snmp6.
+type StatSNMP6ICMP [35]uint64
+
+// StatSNMP6UDP describes the Udp6 lines of /proc/net/snmp6.
+type StatSNMP6UDP [9]uint64
+
+// StatSNMP6UDPLite describes the UdpLite6 lines of /proc/net/snmp6.
+type StatSNMP6UDPLite [9]uint64
+
 // TCPLossRecovery indicates TCP loss detection and recovery methods to use.
 type TCPLossRecovery int32
 
@@ -18,8 +18,11 @@ import (
 	goContext "context"
 
 	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sync"
 )
 
 // Namespace represents a network namespace. See network_namespaces(7).
@@ -45,6 +48,16 @@ type Namespace struct {
 
 	// abstractSockets tracks abstract sockets that are in use.
 	abstractSockets AbstractSocketNamespace
+
+	// sysctlsMu protects sysctls.
+	sysctlsMu sync.Mutex `state:"nosave"`
+
+	// sysctls holds the network sysctls that have been set in this
+	// namespace, keyed by Sysctl.Name. They are reapplied when the stack is
+	// recreated on restore.
+	//
+	// +checklocks:sysctlsMu
+	sysctls map[string]SysctlValue
 }
 
 // NewRootNamespace creates the root network namespace, with creator
@@ -129,6 +142,7 @@ func (n *Namespace) RestoreRootStack(stack Stack) {
 		panic("RestoreRootStack called after a stack has already been set")
 	}
 	n.stack = stack
+	n.applySysctls()
 }
 
 // ResetStack resets the stack in the network namespace to nil. This should
@@ -148,6 +162,7 @@ func (n *Namespace) init() {
 		if err != nil {
 			panic(err)
 		}
+		n.applySysctls()
 	}
 	n.abstractSockets.init()
 }
@@ -157,6 +172,45 @@ func (n *Namespace) afterLoad(goContext.Context) {
 	n.init()
 }
 
+// Sysctl returns the value of the network sysctl name in n.
+func (n *Namespace) Sysctl(name string) (SysctlValue, error) {
+	s := n.Stack()
+	if s == nil {
+		return SysctlValue{}, linuxerr.EOPNOTSUPP
+	}
+	return s.Sysctl(name)
+}
+
+// SetSysctl sets the network sysctl name to v in n. v must have been
+// validated by Sysctl.Parse.
+func (n *Namespace) SetSysctl(name string, v SysctlValue) error {
+	s := n.Stack()
+	if s == nil {
+		return linuxerr.EOPNOTSUPP
+	}
+	n.sysctlsMu.Lock()
+	defer n.sysctlsMu.Unlock()
+	if err := s.SetSysctl(name, v); err != nil {
+		return err
+	}
+	if n.sysctls == nil {
+		n.sysctls = make(map[string]SysctlValue)
+	}
+	n.sysctls[name] = v
+	return nil
+}
+
+// applySysctls sets the network sysctls recorded in n on its stack.
+func (n *Namespace) applySysctls() {
+	n.sysctlsMu.Lock()
+	defer n.sysctlsMu.Unlock()
+	for name, v := range n.sysctls {
+		if err := n.stack.SetSysctl(name, v); err != nil {
+			log.Warningf("Failed to restore sysctl net/%s = %q: %v", name, v.String(), err)
+		}
+	}
+}
+
 // AbstractSockets returns AbstractSocketNamespace.
 func (n *Namespace) AbstractSockets() *AbstractSocketNamespace {
 	return &n.abstractSockets
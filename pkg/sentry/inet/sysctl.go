@@ -0,0 +1,144 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inet
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+)
+
+// Sysctl describes a per-network-namespace sysctl under /proc/sys/net.
+type Sysctl struct {
+	// Name is the path of the sysctl relative to /proc/sys/net, e.g.
+	// "ipv4/tcp_syn_retries".
+	Name string
+
+	// Len is the number of integers in the value. If Len is 0, the value is
+	// a string.
+	Len int
+
+	// Min and Max bound every integer in the value.
+	Min int64
+	Max int64
+
+	// ReadOnly is true if the sysctl can't be written.
+	ReadOnly bool
+
+	// Default is reported when the stack doesn't implement the sysctl.
+	Default string
+}
+
+// Sysctls is the table of network sysctls that are backed by the network
+// stack. Entries are sorted by Name.
+var Sysctls = []Sysctl{
+	{Name: "core/rmem_default", Len: 1, Min: linux.SOCK_MIN_RCVBUF, Max: math.MaxInt32, Default: "212992"},
+	{Name: "core/rmem_max", Len: 1, Min: linux.SOCK_MIN_RCVBUF, Max: math.MaxInt32, Default: "212992"},
+	{Name: "core/somaxconn", Len: 1, Min: 0, Max: math.MaxInt32, Default: "1024"},
+	{Name: "core/wmem_default", Len: 1, Min: linux.SOCK_MIN_SNDBUF, Max: math.MaxInt32, Default: "212992"},
+	{Name: "core/wmem_max", Len: 1, Min: linux.SOCK_MIN_SNDBUF, Max: math.MaxInt32, Default: "212992"},
+	{Name: "ipv4/ip_default_ttl", Len: 1, Min: 1, Max: 255, Default: "64"},
+	{Name: "ipv4/tcp_available_congestion_control", ReadOnly: true, Default: "reno"},
+	{Name: "ipv4/tcp_congestion_control", Default: "reno"},
+	{Name: "ipv4/tcp_fin_timeout", Len: 1, Min: 0, Max: math.MaxInt32 / linux.CLOCKS_PER_SEC, Default: "60"},
+	{Name: "ipv4/tcp_keepalive_intvl", Len: 1, Min: 1, Max: linux.MAX_TCP_KEEPINTVL, Default: "75"},
+	{Name: "ipv4/tcp_keepalive_probes", Len: 1, Min: 1, Max: linux.MAX_TCP_KEEPCNT, Default: "9"},
+	{Name: "ipv4/tcp_keepalive_time", Len: 1, Min: 1, Max: linux.MAX_TCP_KEEPIDLE, Default: "7200"},
+	{Name: "ipv4/tcp_moderate_rcvbuf", Len: 1, Min: 0, Max: 1, Default: "1"},
+	{Name: "ipv4/tcp_retries2", Len: 1, Min: 0, Max: math.MaxInt32, Default: "15"},
+	{Name: "ipv4/tcp_syn_retries", Len: 1, Min: 1, Max: linux.MAX_TCP_SYNCNT, Default: "6"},
+	{Name: "ipv4/tcp_syncookies", Len: 1, Min: 1, Max: 2, Default: "1"},
+	{Name: "ipv4/tcp_timestamps", Len: 1, Min: 0, Max: 1, Default: "1"},
+	{Name: "ipv4/tcp_tw_reuse", Len: 1, Min: 0, Max: 2, Default: "2"},
+}
+
+// LookupSysctl returns the network sysctl with the given name, or nil if there
+// is no such sysctl.
+func LookupSysctl(name string) *Sysctl {
+	for i := range Sysctls {
+		if Sysctls[i].Name == name {
+			return &Sysctls[i]
+		}
+	}
+	return nil
+}
+
+// SysctlValue is the value of a network sysctl.
+//
+// +stateify savable
+type SysctlValue struct {
+	// Ints holds the value of integer sysctls.
+	Ints []int64
+
+	// Str holds the value of string sysctls.
+	Str string
+}
+
+// IntSysctl returns a SysctlValue holding the given integers.
+func IntSysctl(v ...int64) SysctlValue {
+	return SysctlValue{Ints: v}
+}
+
+// String returns v formatted as in /proc/sys/net, including the trailing
+// newline.
+func (v SysctlValue) String() string {
+	if v.Ints == nil {
+		return v.Str + "\n"
+	}
+	var b strings.Builder
+	for i, n := range v.Ints {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(strconv.FormatInt(n, 10))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// Parse parses str as a new value of s, validating it the way Linux does.
+//
+// As with Linux's proc_dointvec_minmax(), an integer vector may be written
+// partially: elements missing from str keep their value from cur.
+func (s *Sysctl) Parse(str string, cur SysctlValue) (SysctlValue, error) {
+	if s.Len == 0 {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			return SysctlValue{}, linuxerr.EINVAL
+		}
+		return SysctlValue{Str: str}, nil
+	}
+
+	fields := strings.Fields(str)
+	if len(fields) == 0 {
+		return SysctlValue{}, linuxerr.EINVAL
+	}
+	ints := make([]int64, s.Len)
+	copy(ints, cur.Ints)
+	for i, f := range fields {
+		if i == s.Len {
+			break
+		}
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || n < s.Min || n > s.Max {
+			return SysctlValue{}, linuxerr.EINVAL
+		}
+		ints[i] = n
+	}
+	return SysctlValue{Ints: ints}, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inet
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+)
+
+func TestSysctlsSorted(t *testing.T) {
+	if !sort.SliceIsSorted(Sysctls, func(i, j int) bool { return Sysctls[i].Name < Sysctls[j].Name }) {
+		t.Errorf("Sysctls is not sorted by name")
+	}
+}
+
+func TestSysctlParse(t *testing.T) {
+	vec := Sysctl{Name: "vec", Len: 3, Min: 1, Max: 100}
+	str := Sysctl{Name: "str"}
+	for _, tc := range []struct {
+		name    string
+		sysctl  *Sysctl
+		input   string
+		cur     SysctlValue
+		want    SysctlValue
+		wantErr error
+	}{
+		{
+			name:   "Full",
+			sysctl: &vec,
+			input:  "1 2 3\n",
+			cur:    IntSysctl(4, 5, 6),
+			want:   IntSysctl(1, 2, 3),
+		},
+		{
+			name:   "Partial",
+			sysctl: &vec,
+			input:  "7\t8",
+			cur:    IntSysctl(4, 5, 6),
+			want:   IntSysctl(7, 8, 6),
+		},
+		{
+			name:   "ExtraIgnored",
+			sysctl: &vec,
+			input:  "1 2 3 4",
+			cur:    IntSysctl(4, 5, 6),
+			want:   IntSysctl(1, 2, 3),
+		},
+		{
+			name:    "Empty",
+			sysctl:  &vec,
+			input:   " \n",
+			cur:     IntSysctl(4, 5, 6),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "BelowMin",
+			sysctl:  &vec,
+			input:   "0",
+			cur:     IntSysctl(4, 5, 6),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "AboveMax",
+			sysctl:  &vec,
+			input:   "1 101",
+			cur:     IntSysctl(4, 5, 6),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:    "NotANumber",
+			sysctl:  &vec,
+			input:   "1 x",
+			cur:     IntSysctl(4, 5, 6),
+			wantErr: linuxerr.EINVAL,
+		},
+		{
+			name:   "String",
+			sysctl: &str,
+			input:  "cubic\n",
+			want:   SysctlValue{Str: "cubic"},
+		},
+		{
+			name:    "EmptyString",
+			sysctl:  &str,
+			input:   "\n",
+			wantErr: linuxerr.EINVAL,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.sysctl.Parse(tc.input, tc.cur)
+			if err != tc.wantErr {
+				t.Fatalf("Parse(%q) = %v, want %v", tc.input, err, tc.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSysctlValueString(t *testing.T) {
+	if got, want := IntSysctl(4096, 131072, 6291456).String(), "4096\t131072\t6291456\n"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got, want := (SysctlValue{Str: "reno"}).String(), "reno\n"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
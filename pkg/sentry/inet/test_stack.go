@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
 	"gvisor.dev/gvisor/pkg/syserr"
 	"gvisor.dev/gvisor/pkg/tcpip"
@@ -30,15 +31,19 @@ var _ Stack = (*TestStack)(nil)
 
 // TestStack is a dummy implementation of Stack for tests.
 type TestStack struct {
-	InterfacesMap     map[int32]Interface
-	InterfaceAddrsMap map[int32][]InterfaceAddr
-	RouteList         []Route
-	SupportsIPv6Flag  bool
-	TCPRecvBufSize    TCPBufferSize
-	TCPSendBufSize    TCPBufferSize
-	TCPSACKFlag       bool
-	Recovery          TCPLossRecovery
-	IPForwarding      bool
+	InterfacesMap      map[int32]Interface
+	InterfaceAddrsMap  map[int32][]InterfaceAddr
+	MulticastGroupsMap map[int32][]MulticastGroup
+	RouteList          []Route
+	RoutingRuleList    []RoutingRule
+	NeighborsMap       map[int32][]Neighbor
+	SupportsIPv6Flag   bool
+	TCPRecvBufSize     TCPBufferSize
+	TCPSendBufSize     TCPBufferSize
+	TCPSACKFlag        bool
+	Recovery           TCPLossRecovery
+	IPForwarding       bool
+	SysctlMap          map[string]SysctlValue
 }
 
 // NewTestStack returns a TestStack with no network interfaces. The value of
@@ -46,8 +51,11 @@ type TestStack struct {
 // set them explicitly.
 func NewTestStack() *TestStack {
 	return &TestStack{
-		InterfacesMap:     make(map[int32]Interface),
-		InterfaceAddrsMap: make(map[int32][]InterfaceAddr),
+		InterfacesMap:      make(map[int32]Interface),
+		InterfaceAddrsMap:  make(map[int32][]InterfaceAddr),
+		MulticastGroupsMap: make(map[int32][]MulticastGroup),
+		NeighborsMap:       make(map[int32][]Neighbor),
+		SysctlMap:          make(map[string]SysctlValue),
 	}
 }
 
@@ -76,6 +84,11 @@ func (s *TestStack) InterfaceAddrs() map[int32][]InterfaceAddr {
 	return s.InterfaceAddrsMap
 }
 
+// MulticastGroups implements Stack.
+func (s *TestStack) MulticastGroups() map[int32][]MulticastGroup {
+	return s.MulticastGroupsMap
+}
+
 // AddInterfaceAddr implements Stack.
 func (s *TestStack) AddInterfaceAddr(idx int32, addr InterfaceAddr) error {
 	s.InterfaceAddrsMap[idx] = append(s.InterfaceAddrsMap[idx], addr)
@@ -100,6 +113,28 @@ func (s *TestStack) RemoveInterfaceAddr(idx int32, addr InterfaceAddr) error {
 	return nil
 }
 
+// SetInterfaceMTU implements Stack.
+func (s *TestStack) SetInterfaceMTU(idx int32, mtu uint32) error {
+	iface, ok := s.InterfacesMap[idx]
+	if !ok {
+		return fmt.Errorf("unknown idx: %d", idx)
+	}
+	iface.MTU = mtu
+	s.InterfacesMap[idx] = iface
+	return nil
+}
+
+// SetInterfaceTxQueueLen implements Stack.
+func (s *TestStack) SetInterfaceTxQueueLen(idx int32, qlen uint32) error {
+	iface, ok := s.InterfacesMap[idx]
+	if !ok {
+		return fmt.Errorf("unknown idx: %d", idx)
+	}
+	iface.TxQueueLen = qlen
+	s.InterfacesMap[idx] = iface
+	return nil
+}
+
 // SupportsIPv6 implements Stack.
 func (s *TestStack) SupportsIPv6() bool {
 	return s.SupportsIPv6Flag
@@ -149,6 +184,21 @@ func (s *TestStack) SetTCPRecovery(recovery TCPLossRecovery) error {
 	return nil
 }
 
+// Sysctl implements Stack.
+func (s *TestStack) Sysctl(name string) (SysctlValue, error) {
+	v, ok := s.SysctlMap[name]
+	if !ok {
+		return SysctlValue{}, linuxerr.EOPNOTSUPP
+	}
+	return v, nil
+}
+
+// SetSysctl implements Stack.
+func (s *TestStack) SetSysctl(name string, v SysctlValue) error {
+	s.SysctlMap[name] = v
+	return nil
+}
+
 // Statistics implements Stack.
 func (s *TestStack) Statistics(stat any, arg string) error {
 	return nil
@@ -169,6 +219,36 @@ func (s *TestStack) NewRoute(ctx context.Context, msg *nlmsg.Message) *syserr.Er
 	return syserr.ErrNotPermitted
 }
 
+// RoutingRules implements Stack.
+func (s *TestStack) RoutingRules() []RoutingRule {
+	return s.RoutingRuleList
+}
+
+// NewRoutingRule implements Stack.
+func (s *TestStack) NewRoutingRule(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotPermitted
+}
+
+// RemoveRoutingRule implements Stack.
+func (s *TestStack) RemoveRoutingRule(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	return nil
+}
+
+// Neighbors implements Stack.
+func (s *TestStack) Neighbors() map[int32][]Neighbor {
+	return s.NeighborsMap
+}
+
+// NewNeighbor implements Stack.
+func (s *TestStack) NewNeighbor(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotPermitted
+}
+
+// RemoveNeighbor implements Stack.
+func (s *TestStack) RemoveNeighbor(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	return nil
+}
+
 // Pause implements Stack.
 func (s *TestStack) Pause() {}
 
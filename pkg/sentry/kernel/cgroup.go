@@ -51,6 +51,11 @@ const (
 // CgroupCtrls is the list of cgroup controllers.
 var CgroupCtrls = []CgroupControllerType{"cpu", "cpuacct", "cpuset", "devices", "job", "memory", "pids"}
 
+// CgroupCtrlsV2 is the list of cgroup controllers available on the cgroup v2
+// unified hierarchy. The devices and job controllers have no v2 interface, and
+// cpuacct is implicit in v2, where it backs cpu.stat.
+var CgroupCtrlsV2 = []CgroupControllerType{"cpu", "cpuacct", "cpuset", "memory", "pids"}
+
 // ParseCgroupController parses a string as a CgroupControllerType.
 func ParseCgroupController(val string) (CgroupControllerType, error) {
 	switch val {
@@ -240,6 +245,9 @@ type hierarchy struct {
 	// fs is not owned by hierarchy. The FS is responsible for unregistering the
 	// hierarchy on destruction, which removes this association.
 	fs *vfs.Filesystem
+	// v2 indicates this is the cgroup v2 unified hierarchy. There is at most
+	// one such hierarchy on the system.
+	v2 bool
 }
 
 func (h *hierarchy) match(ctypes []CgroupControllerType) bool {
@@ -269,6 +277,9 @@ type cgroupFS interface {
 	// RootCgroup returns the root cgroup of this instance. This returns the
 	// actual root, and ignores any overrides setting an effective root.
 	RootCgroup() Cgroup
+
+	// IsV2 returns whether this is the cgroup v2 unified hierarchy.
+	IsV2() bool
 }
 
 // CgroupRegistry tracks the active set of cgroup controllers on the system.
@@ -310,6 +321,11 @@ type CgroupRegistry struct {
 	//
 	// +checklocks:mu
 	cgroups map[uint32]CgroupImpl
+
+	// cpuLimits is set once a bandwidth limit or weight has been configured
+	// on any cpu cgroup. Until then, the CPU clock ticker skips cpu cgroup
+	// accounting.
+	cpuLimits atomicbitops.Bool
 }
 
 func newCgroupRegistry() *CgroupRegistry {
@@ -361,7 +377,8 @@ func (r *CgroupRegistry) FindHierarchy(name string, ctypes []CgroupControllerTyp
 	}
 
 	for _, h := range r.hierarchies {
-		if h.match(ctypes) {
+		// The unified hierarchy only backs cgroup2 mounts.
+		if !h.v2 && h.match(ctypes) {
 			if !h.fs.TryIncRef() {
 				// Racing with filesystem destruction, namely h.fs.Release.
 				// Since we hold r.mu, we know the hierarchy hasn't been
@@ -387,6 +404,77 @@ func (r *CgroupRegistry) FindHierarchy(name string, ctypes []CgroupControllerTyp
 	return nil, nil
 }
 
+// FindV2Hierarchy returns the filesystem of the cgroup v2 unified hierarchy, or
+// nil if there is none. FindV2Hierarchy takes a reference on the returned FS,
+// which is transferred to the caller.
+func (r *CgroupRegistry) FindV2Hierarchy() *vfs.Filesystem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, h := range r.hierarchies {
+		if h.v2 {
+			if !h.fs.TryIncRef() {
+				// Racing with filesystem destruction, see FindHierarchy.
+				r.unregisterLocked(h.id)
+				return nil
+			}
+			return h.fs
+		}
+	}
+	return nil
+}
+
+// HasV2Hierarchy returns whether the cgroup v2 unified hierarchy exists.
+func (r *CgroupRegistry) HasV2Hierarchy() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, h := range r.hierarchies {
+		if h.v2 {
+			return true
+		}
+	}
+	return false
+}
+
+// UnboundControllers returns the controllers in ctypes that aren't attached to
+// any hierarchy. The result is a snapshot in time; Register fails if one of the
+// controllers is bound concurrently.
+func (r *CgroupRegistry) UnboundControllers(ctypes []CgroupControllerType) []CgroupControllerType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unbound []CgroupControllerType
+	for _, ty := range ctypes {
+		if _, ok := r.controllers[ty]; !ok {
+			unbound = append(unbound, ty)
+		}
+	}
+	return unbound
+}
+
+// hierarchyFS returns the filesystem of the hierarchy with ID hid, or nil if
+// there is none. hierarchyFS takes a reference on the returned FS, which is
+// transferred to the caller.
+func (r *CgroupRegistry) hierarchyFS(hid uint32) *vfs.Filesystem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.hierarchies[hid]
+	if !ok || !h.fs.TryIncRef() {
+		return nil
+	}
+	return h.fs
+}
+
+// isV2Hierarchy returns whether hid is the ID of the cgroup v2 unified
+// hierarchy.
+func (r *CgroupRegistry) isV2Hierarchy(hid uint32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hierarchies[hid].v2
+}
+
 // FindCgroup locates a cgroup with the given parameters.
 //
 // A cgroup is considered a match even if it contains other controllers on the
@@ -397,10 +485,7 @@ func (r *CgroupRegistry) FindCgroup(ctx context.Context, ctype CgroupControllerT
 		return Cgroup{}, fmt.Errorf("path must be absolute")
 	}
 	k := KernelFromContext(ctx)
-	vfsfs, err := r.FindHierarchy("", []CgroupControllerType{ctype})
-	if err != nil {
-		return Cgroup{}, err
-	}
+	vfsfs := r.findHierarchyForController(ctype)
 	if vfsfs == nil {
 		return Cgroup{}, fmt.Errorf("controller not active")
 	}
@@ -416,6 +501,26 @@ func (r *CgroupRegistry) FindCgroup(ctx context.Context, ctype CgroupControllerT
 	return rootCG.Walk(ctx, k.VFS(), p)
 }
 
+// findHierarchyForController returns the filesystem of the hierarchy ctype is
+// attached to, or nil if ctype is not active. findHierarchyForController takes
+// a reference on the returned FS, which is transferred to the caller.
+func (r *CgroupRegistry) findHierarchyForController(ctype CgroupControllerType) *vfs.Filesystem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctl, ok := r.controllers[ctype]
+	if !ok {
+		return nil
+	}
+	h := r.hierarchies[ctl.HierarchyID()]
+	if !h.fs.TryIncRef() {
+		// Racing with filesystem destruction, see FindHierarchy.
+		r.unregisterLocked(h.id)
+		return nil
+	}
+	return h.fs
+}
+
 // Register registers the provided set of controllers with the registry as a new
 // hierarchy. If any controller is already registered, the function returns an
 // error without modifying the registry. Register sets the hierarchy ID for the
@@ -424,7 +529,16 @@ func (r *CgroupRegistry) Register(name string, cs []CgroupController, fs cgroupF
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if name == "" && len(cs) == 0 {
+	v2 := fs.IsV2()
+	if v2 {
+		// The unified hierarchy may legitimately end up without controllers,
+		// if they're all bound to v1 hierarchies.
+		for _, h := range r.hierarchies {
+			if h.v2 {
+				return fmt.Errorf("cgroup v2 hierarchy already exists")
+			}
+		}
+	} else if name == "" && len(cs) == 0 {
 		return fmt.Errorf("can't register hierarchy with both no controllers and no name")
 	}
 
@@ -452,6 +566,7 @@ func (r *CgroupRegistry) Register(name string, cs []CgroupController, fs cgroupF
 		name:        name,
 		controllers: make(map[CgroupControllerType]CgroupController),
 		fs:          fs.VFSFilesystem(),
+		v2:          v2,
 	}
 	for _, c := range cs {
 		n := c.Type()
@@ -527,7 +642,13 @@ func (r *CgroupRegistry) GenerateProcCgroups(buf *bytes.Buffer) {
 		if c.Enabled() {
 			en = 1
 		}
-		entries = append(entries, fmt.Sprintf("%s\t%d\t%d\t%d\n", c.Type(), c.HierarchyID(), c.NumCgroups(), en))
+		hid := c.HierarchyID()
+		if r.hierarchies[hid].v2 {
+			// Linux reports controllers on the unified hierarchy with
+			// hierarchy ID 0.
+			hid = 0
+		}
+		entries = append(entries, fmt.Sprintf("%s\t%d\t%d\t%d\n", c.Type(), hid, c.NumCgroups(), en))
 	}
 	r.mu.Unlock()
 
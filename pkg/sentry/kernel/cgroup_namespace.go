@@ -0,0 +1,214 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// CgroupNamespace represents a cgroup namespace, which virtualizes the view of
+// a task's cgroups. See cgroup_namespaces(7).
+//
+// +stateify savable
+type CgroupNamespace struct {
+	// userns is the user namespace owning the CgroupNamespace. Privileged
+	// operations on this CgroupNamespace must have appropriate capabilities in
+	// userns. It is immutable.
+	userns *auth.UserNamespace
+
+	// roots maps hierarchy IDs to the root cgroup of the namespace in that
+	// hierarchy. A reference is held on each root. Hierarchies without an
+	// entry are rooted at the hierarchy root, as are all hierarchies in the
+	// initial cgroup namespace. roots is immutable.
+	roots map[uint32]Cgroup
+
+	// hierarchies holds a reference on the filesystem of each hierarchy in
+	// roots, which keeps the hierarchies from being torn down while the
+	// namespace is rooted in them. hierarchies is immutable.
+	hierarchies []*vfs.Filesystem
+
+	mu    sync.Mutex `state:"nosave"`
+	inode *nsfs.Inode
+}
+
+// newRootCgroupNamespace returns the initial cgroup namespace.
+func newRootCgroupNamespace(userns *auth.UserNamespace) *CgroupNamespace {
+	return &CgroupNamespace{
+		userns: userns,
+	}
+}
+
+// newCgroupNamespace returns a new cgroup namespace owned by userns, rooted
+// at t's current cgroups.
+//
+// Preconditions: t.mu must not be locked.
+func (t *Task) newCgroupNamespace(userns *auth.UserNamespace) *CgroupNamespace {
+	t.mu.Lock()
+	cgroups := make([]Cgroup, 0, len(t.cgroups))
+	for c := range t.cgroups {
+		c.IncRef()
+		cgroups = append(cgroups, c)
+	}
+	t.mu.Unlock()
+
+	ns := &CgroupNamespace{
+		userns: userns,
+		roots:  make(map[uint32]Cgroup, len(cgroups)),
+	}
+	r := t.k.CgroupRegistry()
+	for _, c := range cgroups {
+		vfsfs := r.hierarchyFS(c.HierarchyID())
+		if vfsfs == nil {
+			// The hierarchy is being torn down.
+			c.decRef()
+			continue
+		}
+		ns.roots[c.HierarchyID()] = c
+		ns.hierarchies = append(ns.hierarchies, vfsfs)
+	}
+	return ns
+}
+
+// CgroupNamespace returns the task's cgroup namespace.
+func (t *Task) CgroupNamespace() *CgroupNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cgroupns
+}
+
+// GetCgroupNamespace takes a reference on the task's cgroup namespace and
+// returns it. It will return nil if the task isn't alive.
+func (t *Task) GetCgroupNamespace() *CgroupNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cgroupns != nil {
+		t.cgroupns.IncRef()
+	}
+	return t.cgroupns
+}
+
+// GetCgroupNamespaceFromContext takes a reference on the cgroup namespace of
+// the task in ctx and returns it. If there is no such task, or it isn't alive,
+// the root cgroup namespace is returned instead.
+func (k *Kernel) GetCgroupNamespaceFromContext(ctx context.Context) *CgroupNamespace {
+	if t := TaskFromContext(ctx); t != nil {
+		if ns := t.GetCgroupNamespace(); ns != nil {
+			return ns
+		}
+	}
+	k.rootCgroupNamespace.IncRef()
+	return k.rootCgroupNamespace
+}
+
+// UserNamespace returns the user namespace owning ns.
+func (ns *CgroupNamespace) UserNamespace() *auth.UserNamespace {
+	return ns.userns
+}
+
+// Root returns the root cgroup of ns in the hierarchy with ID hid. If ns is
+// rooted at the hierarchy root, ok is false. The returned cgroup is only
+// valid while the caller holds a reference on ns.
+func (ns *CgroupNamespace) Root(hid uint32) (c Cgroup, ok bool) {
+	c, ok = ns.roots[hid]
+	return c, ok
+}
+
+// Path returns the path of c relative to the root of ns in c's hierarchy, as
+// displayed in /proc/[pid]/cgroup. Cgroups outside of the namespace root are
+// reached through ".." components. See kernel/cgroup/cgroup.c:cgroup_path_ns().
+func (ns *CgroupNamespace) Path(c Cgroup) string {
+	return ns.RelativePath(c.HierarchyID(), c.Path())
+}
+
+// RelativePath returns the absolute path p in the hierarchy with ID hid,
+// relative to the root of ns in that hierarchy.
+func (ns *CgroupNamespace) RelativePath(hid uint32, p string) string {
+	root, ok := ns.Root(hid)
+	if !ok {
+		return p
+	}
+	return relativeCgroupPath(root.Path(), p)
+}
+
+// relativeCgroupPath returns the absolute cgroup path p relative to the
+// absolute cgroup path root. See fs/kernfs/dir.c:kernfs_path_from_node().
+func relativeCgroupPath(root, p string) string {
+	rootComps := splitCgroupPath(root)
+	pComps := splitCgroupPath(p)
+	common := 0
+	for common < len(rootComps) && common < len(pComps) && rootComps[common] == pComps[common] {
+		common++
+	}
+	comps := make([]string, 0, len(rootComps)-common+len(pComps)-common)
+	for i := common; i < len(rootComps); i++ {
+		comps = append(comps, "..")
+	}
+	comps = append(comps, pComps[common:]...)
+	return "/" + strings.Join(comps, "/")
+}
+
+// splitCgroupPath returns the components of the absolute cgroup path p.
+func splitCgroupPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// Type implements nsfs.Namespace.Type.
+func (ns *CgroupNamespace) Type() string {
+	return "cgroup"
+}
+
+// Destroy implements nsfs.Namespace.Destroy.
+func (ns *CgroupNamespace) Destroy(ctx context.Context) {
+	for _, c := range ns.roots {
+		c.decRef()
+	}
+	for _, vfsfs := range ns.hierarchies {
+		vfsfs.DecRef(ctx)
+	}
+}
+
+// SetInode sets the nsfs `inode` to the cgroup namespace.
+func (ns *CgroupNamespace) SetInode(inode *nsfs.Inode) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode = inode
+}
+
+// GetInode returns the nsfs inode associated with the cgroup namespace.
+func (ns *CgroupNamespace) GetInode() *nsfs.Inode {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.inode
+}
+
+// IncRef increments the namespace's refcount.
+func (ns *CgroupNamespace) IncRef() {
+	ns.GetInode().IncRef()
+}
+
+// DecRef decrements the namespace's refcount.
+func (ns *CgroupNamespace) DecRef(ctx context.Context) {
+	ns.GetInode().DecRef(ctx)
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"testing"
+)
+
+func TestRelativeCgroupPath(t *testing.T) {
+	for _, test := range []struct {
+		root string
+		path string
+		want string
+	}{
+		{root: "/", path: "/", want: "/"},
+		{root: "/", path: "/a/b", want: "/a/b"},
+		{root: "/a", path: "/a", want: "/"},
+		{root: "/a", path: "/a/b/c", want: "/b/c"},
+		{root: "/a/b", path: "/a", want: "/.."},
+		{root: "/a/b", path: "/", want: "/../.."},
+		{root: "/a/b", path: "/a/c", want: "/../c"},
+		{root: "/a/b", path: "/ab", want: "/../../ab"},
+		{root: "/a", path: "/ab/c", want: "/../ab/c"},
+	} {
+		if got := relativeCgroupPath(test.root, test.path); got != test.want {
+			t.Errorf("relativeCgroupPath(%q, %q) = %q, want %q", test.root, test.path, got, test.want)
+		}
+	}
+}
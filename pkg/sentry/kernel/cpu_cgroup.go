@@ -0,0 +1,272 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"time"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/sched"
+	"gvisor.dev/gvisor/pkg/sentry/ktime"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// DefaultCPUWeight is the default weight of a cpu cgroup. See Linux,
+// kernel/sched/sched.h:CGROUP_WEIGHT_DFL.
+const DefaultCPUWeight = 100
+
+// cpuShareWindow is the interval over which CPU time is shared between cpu
+// cgroups in proportion to their weights while CPUs are contended.
+const cpuShareWindow = 10 * linux.ClockTick
+
+// CPUGroup is the scheduling state of a cpu cgroup: its CFS bandwidth limit,
+// and its weight relative to other cpu cgroups.
+//
+// +stateify savable
+type CPUGroup struct {
+	// parent is the group of the parent cgroup, or nil if this is the group of
+	// a root cgroup. parent is immutable.
+	parent *CPUGroup
+
+	// weight is the group's weight, in the range [1, 10000].
+	weight atomicbitops.Int64
+
+	mu sync.Mutex `state:"nosave"`
+
+	// bw tracks the group's CPU usage against its bandwidth limit and fair
+	// share.
+	//
+	// +checklocks:mu
+	bw sched.CPUBandwidth
+}
+
+// NewCPUGroup returns a new, unlimited CPU group with the default weight. If
+// parent is not nil, the new group is also subject to parent's limit.
+func NewCPUGroup(parent *CPUGroup) *CPUGroup {
+	return &CPUGroup{
+		parent: parent,
+		weight: atomicbitops.FromInt64(DefaultCPUWeight),
+	}
+}
+
+// SetLimit limits tasks in g to using quota of CPU time in every period. If
+// quota is negative, g is unlimited.
+func (g *CPUGroup) SetLimit(k *Kernel, quota, period time.Duration) {
+	now := k.MonotonicClock().Now().Nanoseconds()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.bw.SetLimit(now, quota.Nanoseconds(), period.Nanoseconds())
+}
+
+// SetWeight sets g's weight.
+func (g *CPUGroup) SetWeight(weight int64) {
+	g.weight.Store(weight)
+}
+
+// Stats returns g's bandwidth statistics.
+func (g *CPUGroup) Stats(k *Kernel) sched.CPUBandwidthStats {
+	now := k.MonotonicClock().Now().Nanoseconds()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.bw.Stats(now)
+}
+
+// charge charges d of CPU time used at now to g and its ancestors.
+func (g *CPUGroup) charge(now, d int64) {
+	for ; g != nil; g = g.parent {
+		g.mu.Lock()
+		g.bw.Charge(now, d)
+		g.mu.Unlock()
+	}
+}
+
+// chargeShare calls sched.CPUBandwidth.ChargeShare for g.
+func (g *CPUGroup) chargeShare(now, d, allowed, windowEnd int64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.bw.ChargeShare(now, d, allowed, windowEnd)
+}
+
+// throttledUntil returns the time until which tasks in g may not run, and
+// true, if g or any of its ancestors is throttled at now.
+func (g *CPUGroup) throttledUntil(now int64) (int64, bool) {
+	var until int64
+	for ; g != nil; g = g.parent {
+		g.mu.Lock()
+		if u, ok := g.bw.ThrottledUntil(now); ok {
+			until = max(until, u)
+		}
+		g.mu.Unlock()
+	}
+	return until, until != 0
+}
+
+// EnableCPULimits indicates that a bandwidth limit or weight has been
+// configured on a cpu cgroup, so the CPU clock ticker must enforce cpu cgroup
+// limits from now on.
+func (r *CgroupRegistry) EnableCPULimits() {
+	r.cpuLimits.Store(true)
+}
+
+// cpuLimitsEnabled returns true if cpu cgroup limits must be enforced.
+func (k *Kernel) cpuLimitsEnabled() bool {
+	return k.cgroupRegistry != nil && k.cgroupRegistry.cpuLimits.Load()
+}
+
+// SetCPUGroup sets the CPU group t is scheduled in. g may be nil if t isn't
+// in a cpu cgroup.
+func (t *Task) SetCPUGroup(g *CPUGroup) {
+	t.cpuGroup.Store(g)
+}
+
+// waitCPUThrottle blocks t until its CPU group may run again, if the group is
+// throttled. It returns false if t was interrupted while waiting.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) waitCPUThrottle() bool {
+	g := t.cpuGroup.Load()
+	if g == nil {
+		return true
+	}
+	defer t.cpuThrottled.Store(false)
+	for {
+		until, ok := g.throttledUntil(t.k.MonotonicClock().Now().Nanoseconds())
+		if !ok {
+			return true
+		}
+		t.cpuThrottled.Store(true)
+		if err := t.BlockWithDeadline(nil, true, ktime.FromNanoseconds(until)); err == linuxerr.ErrInterrupted {
+			return false
+		}
+	}
+}
+
+// cpuShare is the share of contended CPUs of a CPU group during a CPU clock
+// tick.
+type cpuShare struct {
+	// weight is the group's weight.
+	weight int64
+
+	// demand is the CPU time the group's running tasks could use.
+	demand int64
+
+	// used is the CPU time accounted to the group.
+	used int64
+
+	// allowed is the CPU time the group is entitled to.
+	allowed int64
+
+	// done is true once allowed has been computed.
+	done bool
+}
+
+// chargeCPUGroups charges the CPU clock tick accounted to each task in
+// incTasks to its CPU group, and interrupts running tasks in CPU groups that
+// are throttled as a result. runningTasks is the number of running tasks in
+// allTasks. shares is storage for use by chargeCPUGroups, and is left empty
+// on return.
+//
+// Preconditions: The caller must be the CPU clock ticker.
+func (k *Kernel) chargeCPUGroups(allTasks, incTasks []*Task, runningTasks int, shares map[*CPUGroup]*cpuShare) {
+	now := k.MonotonicClock().Now().Nanoseconds()
+	tick := linux.ClockTick.Nanoseconds()
+	for _, t := range incTasks {
+		if g := t.cpuGroup.Load(); g != nil {
+			g.charge(now, tick)
+		}
+	}
+
+	// While there are more running tasks than CPUs, each CPU group is
+	// entitled to a share of the CPUs proportional to its weight, but to no
+	// more than its running tasks can use. CPU time that a group can't use is
+	// redistributed among the other groups. Groups that use more than their
+	// share in a share window are throttled until the window ends. Tasks that
+	// aren't in a CPU group are accounted to a nil group with the default
+	// weight, and are never throttled.
+	if runningTasks > int(k.applicationCores) {
+		defer clear(shares)
+		for _, t := range allTasks {
+			if state := t.TaskGoroutineState(); state != TaskGoroutineRunningApp && state != TaskGoroutineRunningSys {
+				continue
+			}
+			g := t.cpuGroup.Load()
+			s, ok := shares[g]
+			if !ok {
+				s = &cpuShare{weight: DefaultCPUWeight}
+				if g != nil {
+					s.weight = g.weight.Load()
+				}
+				shares[g] = s
+			}
+			s.demand += tick
+		}
+		for _, t := range incTasks {
+			if s, ok := shares[t.cpuGroup.Load()]; ok {
+				s.used += tick
+			}
+		}
+		capacity := int64(len(incTasks)) * tick
+		for {
+			var totalWeight int64
+			for _, s := range shares {
+				if !s.done {
+					totalWeight += s.weight
+				}
+			}
+			if totalWeight == 0 {
+				break
+			}
+			capped := false
+			for _, s := range shares {
+				if !s.done && s.demand*totalWeight <= capacity*s.weight {
+					s.allowed = s.demand
+					s.done = true
+					capacity -= s.demand
+					capped = true
+				}
+			}
+			if !capped {
+				for _, s := range shares {
+					if !s.done {
+						s.allowed = capacity * s.weight / totalWeight
+						s.done = true
+					}
+				}
+				break
+			}
+		}
+		windowEnd := now - now%cpuShareWindow.Nanoseconds() + cpuShareWindow.Nanoseconds()
+		for g, s := range shares {
+			if g != nil {
+				g.chargeShare(now, s.used, s.allowed, windowEnd)
+			}
+		}
+	}
+
+	// Tasks running in the sentry check for throttling before returning to
+	// the application, so only tasks in application code are interrupted.
+	for _, t := range allTasks {
+		if t.TaskGoroutineState() != TaskGoroutineRunningApp {
+			continue
+		}
+		if g := t.cpuGroup.Load(); g != nil {
+			if _, ok := g.throttledUntil(now); ok {
+				t.interrupt()
+			}
+		}
+	}
+}
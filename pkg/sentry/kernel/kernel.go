@@ -64,6 +64,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/futex"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/ipc"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/sched"
 	"gvisor.dev/gvisor/pkg/sentry/ktime"
 	"gvisor.dev/gvisor/pkg/sentry/limits"
@@ -183,6 +184,8 @@ type Kernel struct {
 	vdsoParams           *VDSOParamPage
 	rootUTSNamespace     *UTSNamespace
 	rootIPCNamespace     *IPCNamespace
+	rootTimeNamespace    *TimeNamespace
+	rootCgroupNamespace  *CgroupNamespace
 
 	// futexes is the "root" futex.Manager, from which all others are forked.
 	// This is necessary to ensure that shared futexes are coherent across all
@@ -341,10 +344,15 @@ type Kernel struct {
 	// the system.
 	cgroupRegistry *CgroupRegistry
 
+	// pressure is the system-wide pressure stall information, which is also
+	// that of the root cgroup on the unified hierarchy.
+	pressure *psi.Group
+
 	// cgroupMountsMap maps the cgroup controller names to the cgroup mounts
 	// created for the root container. These mounts are then bind mounted
 	// for other application containers by creating their own container
-	// directories.
+	// directories. If the sandbox uses cgroup v2, the map instead holds a
+	// single entry for the unified hierarchy, keyed "cgroup2".
 	cgroupMountsMap   map[string]*CgroupMount
 	cgroupMountsMapMu cgroupMountsMutex `state:"nosave"`
 
@@ -477,6 +485,8 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	k.rootUserNamespace = args.RootUserNamespace
 	k.rootUTSNamespace = args.RootUTSNamespace
 	k.rootIPCNamespace = args.RootIPCNamespace
+	k.rootTimeNamespace = newRootTimeNamespace(args.Timekeeper, args.RootUserNamespace)
+	k.rootCgroupNamespace = newRootCgroupNamespace(args.RootUserNamespace)
 	k.rootNetworkNamespace = args.RootNetworkNamespace
 	if k.rootNetworkNamespace == nil {
 		k.rootNetworkNamespace = inet.NewRootNamespace(nil, nil, args.RootUserNamespace)
@@ -539,6 +549,8 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	k.rootNetworkNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootNetworkNamespace))
 	k.rootIPCNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootIPCNamespace))
 	k.rootUTSNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootUTSNamespace))
+	k.rootTimeNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootTimeNamespace))
+	k.rootCgroupNamespace.SetInode(nsfs.NewInode(ctx, k.nsfsMount, k.rootCgroupNamespace))
 
 	args.RootPIDNamespace.InitInode(ctx, k)
 
@@ -575,6 +587,7 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	k.sockets = make(map[*vfs.FileDescription]*SocketRecord)
 
 	k.cgroupRegistry = newCgroupRegistry()
+	k.pressure = psi.NewGroup(nil, 0)
 	k.UnixSocketOpts = args.UnixSocketOpts
 	return nil
 }
@@ -1530,6 +1543,16 @@ func (k *Kernel) RootIPCNamespace() *IPCNamespace {
 	return k.rootIPCNamespace
 }
 
+// RootTimeNamespace returns the root TimeNamespace.
+func (k *Kernel) RootTimeNamespace() *TimeNamespace {
+	return k.rootTimeNamespace
+}
+
+// RootCgroupNamespace returns the root CgroupNamespace.
+func (k *Kernel) RootCgroupNamespace() *CgroupNamespace {
+	return k.rootCgroupNamespace
+}
+
 // RootPIDNamespace returns the root PIDNamespace.
 func (k *Kernel) RootPIDNamespace() *PIDNamespace {
 	return k.tasks.Root
@@ -1918,6 +1941,8 @@ func (k *Kernel) Release() {
 	k.RootNetworkNamespace().DecRef(ctx)
 	k.rootIPCNamespace.DecRef(ctx)
 	k.rootUTSNamespace.DecRef(ctx)
+	k.rootTimeNamespace.DecRef(ctx)
+	k.rootCgroupNamespace.DecRef(ctx)
 	k.cleaupDevGofers()
 	k.mf.Destroy()
 	k.RootPIDNamespace().DecRef(ctx)
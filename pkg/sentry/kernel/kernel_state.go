@@ -17,6 +17,7 @@ package kernel
 import (
 	"context"
 
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
 	"gvisor.dev/gvisor/pkg/tcpip"
 )
 
@@ -67,6 +68,26 @@ func (t *Task) loadSeccomp(_ context.Context, seccompData *taskSeccomp) {
 	t.seccomp.Store(seccompData)
 }
 
+// saveCPUGroup is invoked by stateify.
+func (t *Task) saveCPUGroup() *CPUGroup {
+	return t.cpuGroup.Load()
+}
+
+// loadCPUGroup is invoked by stateify.
+func (t *Task) loadCPUGroup(_ context.Context, g *CPUGroup) {
+	t.cpuGroup.Store(g)
+}
+
+// savePressureGroup is invoked by stateify.
+func (t *Task) savePressureGroup() *psi.Group {
+	return t.pressureGroup.Load()
+}
+
+// loadPressureGroup is invoked by stateify.
+func (t *Task) loadPressureGroup(_ context.Context, g *psi.Group) {
+	t.pressureGroup.Store(g)
+}
+
 // saveAppCPUClockLast is invoked by stateify.
 func (tg *ThreadGroup) saveAppCPUClockLast() *Task {
 	return tg.appCPUClockLast.Load()
@@ -0,0 +1,76 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"math"
+
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+)
+
+// oomScoreAdjMin is the oom_score_adj value that exempts a thread group from
+// OOM killing. See Linux, include/uapi/linux/oom.h:OOM_SCORE_ADJ_MIN.
+const oomScoreAdjMin = -1000
+
+// OOMBadness returns the badness of t's thread group for OOM victim selection
+// when totalBytes of memory are available to it. Higher values make it a more
+// likely victim. It returns math.MinInt64 if the thread group must not be
+// killed.
+//
+// This is analogous to Linux's mm/oom_kill.c:oom_badness().
+func (t *Task) OOMBadness(totalBytes uint64) int64 {
+	adj := int64(t.OOMScoreAdj())
+	if adj == oomScoreAdjMin {
+		return math.MinInt64
+	}
+	var m *mm.MemoryManager
+	t.WithMuLocked(func(t *Task) {
+		m = t.MemoryManager()
+	})
+	if m == nil {
+		// t has exited and released its memory already.
+		return math.MinInt64
+	}
+	rss := m.ResidentSetSize()
+	totalPages := int64(totalBytes / hostarch.PageSize)
+	// Normalize oom_score_adj to a proportion of available memory.
+	return int64(rss/hostarch.PageSize) + adj*(totalPages/1000)
+}
+
+// OOMScore returns the value of /proc/[pid]/oom_score for t, when totalBytes
+// of memory are available to it.
+//
+// This is analogous to Linux's fs/proc/base.c:proc_oom_score().
+func (t *Task) OOMScore(totalBytes uint64) int64 {
+	badness := t.OOMBadness(totalBytes)
+	totalPages := int64(totalBytes / hostarch.PageSize)
+	if badness == math.MinInt64 || totalPages == 0 {
+		return 0
+	}
+	// Scale the badness back to the [0, 2000] range.
+	return (1000 + badness*1000/totalPages) * 2 / 3
+}
+
+// waitMemoryCgroupOOM waits for the memory cgroup out-of-memory condition
+// that caused err to be handled, by reclaiming memory or killing a task. It
+// returns true if the failed allocation should be retried. If t itself is the
+// OOM victim, the SIGKILL is handled when t next checks for interrupts.
+func (t *Task) waitMemoryCgroupOOM(err *pgalloc.MemoryCgroupLimitError) bool {
+	t.oomWaiting.Store(true)
+	defer t.oomWaiting.Store(false)
+	return err.OOM.Wait(t)
+}
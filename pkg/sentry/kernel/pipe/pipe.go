@@ -24,6 +24,7 @@ import (
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/safemem"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
 	"gvisor.dev/gvisor/pkg/waiter"
 )
 
@@ -131,18 +132,38 @@ type Pipe struct {
 	// mu protects all pipe internal state below.
 	mu pipeMutex `state:"nosave"`
 
-	// buf holds the pipe's data. buf is a circular buffer; the first valid
-	// byte in buf is at offset off, and the pipe contains size valid bytes.
-	// bufBlocks contains two identical safemem.Blocks representing buf; this
-	// avoids needing to heap-allocate a new safemem.Block slice when buf is
-	// resized. bufBlockSeq is a safemem.BlockSeq representing bufBlocks.
+	// buf holds the pipe's copied data. buf is a circular buffer; the first
+	// valid byte in buf is at offset off, and buf contains bufSize valid
+	// bytes. bufBlocks contains two identical safemem.Blocks representing
+	// buf; this avoids needing to heap-allocate a new safemem.Block slice
+	// when buf is resized. bufBlockSeq is a safemem.BlockSeq representing
+	// bufBlocks.
 	//
 	// These fields are protected by mu.
 	buf         []byte
 	bufBlocks   [2]safemem.Block `state:"nosave"`
 	bufBlockSeq safemem.BlockSeq `state:"nosave"`
 	off         int64
-	size        int64
+	bufSize     int64
+
+	// pinned holds data that was gifted to the pipe by vmsplice(2) without
+	// copying, in the order in which it was written. Data in buf that was
+	// written before pinned[0] is read first, followed by pinned[0], then
+	// data in buf written between pinned[0] and pinned[1], etc. bufTail is
+	// the number of bytes in buf that were written after the last pinned
+	// buffer (or all of buf if pinned is empty).
+	//
+	// pinned is always empty while the pipe is being saved; see beforeSave.
+	//
+	// These fields are protected by mu.
+	pinned  []pinnedBuf `state:"nosave"`
+	bufTail int64
+
+	// size is the total number of bytes in the pipe, including both buf and
+	// pinned.
+	//
+	// This is protected by mu.
+	size int64
 
 	// max is the maximum size of the pipe in bytes. When this max has been
 	// reached, writers will get EWOULDBLOCK.
@@ -159,6 +180,19 @@ type Pipe struct {
 	hadWriter bool
 }
 
+// pinnedBuf is a range of application memory that has been gifted to a pipe.
+type pinnedBuf struct {
+	// bufBytes is the number of bytes in Pipe.buf that precede this buffer
+	// in the pipe and follow the previous pinnedBuf, if any.
+	bufBytes int64
+
+	// prs holds references on the memory backing this buffer.
+	prs []mm.PinnedRange
+
+	// bs maps the unconsumed part of this buffer.
+	bs safemem.BlockSeq
+}
+
 // NewPipe initializes and returns a pipe.
 //
 // N.B. The size will be bounded.
@@ -210,17 +244,70 @@ func (p *Pipe) peekLocked(off, count int64, f func(safemem.BlockSeq) (uint64, er
 	}
 
 	// Prepare the view of the data to be read.
-	pipeOff := p.off + off
-	if max := int64(len(p.buf)); pipeOff >= max {
-		pipeOff -= max
+	var bs safemem.BlockSeq
+	if len(p.pinned) == 0 {
+		bs = p.bufViewLocked(off, count)
+	} else {
+		bs = p.pinnedViewLocked(off, count)
 	}
-	bs := p.bufBlockSeq.DropFirst64(uint64(pipeOff)).TakeFirst64(uint64(count))
 
 	// Perform the read.
 	done, err := f(bs)
 	return int64(done), err
 }
 
+// bufViewLocked returns a safemem.BlockSeq representing count bytes in p.buf,
+// starting at offset off from the first valid byte.
+//
+// Preconditions:
+//   - p.mu must be locked.
+//   - off+count <= p.bufSize.
+func (p *Pipe) bufViewLocked(off, count int64) safemem.BlockSeq {
+	pipeOff := p.off + off
+	if max := int64(len(p.buf)); pipeOff >= max {
+		pipeOff -= max
+	}
+	return p.bufBlockSeq.DropFirst64(uint64(pipeOff)).TakeFirst64(uint64(count))
+}
+
+// pinnedViewLocked is equivalent to bufViewLocked, but accounts for data in
+// p.pinned.
+//
+// Preconditions:
+//   - p.mu must be locked.
+//   - off+count <= p.size.
+func (p *Pipe) pinnedViewLocked(off, count int64) safemem.BlockSeq {
+	var blocks []safemem.Block
+	appendBlocks := func(bs safemem.BlockSeq) {
+		n := int64(bs.NumBytes())
+		if off >= n {
+			off -= n
+			return
+		}
+		bs = bs.DropFirst64(uint64(off)).TakeFirst64(uint64(count))
+		off = 0
+		count -= int64(bs.NumBytes())
+		for !bs.IsEmpty() {
+			blocks = append(blocks, bs.Head())
+			bs = bs.Tail()
+		}
+	}
+	var bufOff int64
+	for i := range p.pinned {
+		if count == 0 {
+			break
+		}
+		pb := &p.pinned[i]
+		appendBlocks(p.bufViewLocked(bufOff, pb.bufBytes))
+		bufOff += pb.bufBytes
+		appendBlocks(pb.bs)
+	}
+	if count != 0 {
+		appendBlocks(p.bufViewLocked(bufOff, p.bufTail))
+	}
+	return safemem.BlockSeqFromSlice(blocks)
+}
+
 // consumeLocked consumes the first n bytes in the pipe, such that they will no
 // longer be visible to future reads.
 //
@@ -228,11 +315,42 @@ func (p *Pipe) peekLocked(off, count int64, f func(safemem.BlockSeq) (uint64, er
 //   - p.mu must be locked.
 //   - The pipe must contain at least n bytes.
 func (p *Pipe) consumeLocked(n int64) {
+	p.size -= n
+	for n != 0 && len(p.pinned) != 0 {
+		pb := &p.pinned[0]
+		if pb.bufBytes != 0 {
+			k := min(n, pb.bufBytes)
+			p.consumeBufLocked(k)
+			pb.bufBytes -= k
+			n -= k
+			continue
+		}
+		k := min(n, int64(pb.bs.NumBytes()))
+		pb.bs = pb.bs.DropFirst64(uint64(k))
+		n -= k
+		if pb.bs.IsEmpty() {
+			mm.Unpin(pb.prs)
+			p.pinned[0] = pinnedBuf{}
+			p.pinned = p.pinned[1:]
+		}
+	}
+	if n != 0 {
+		p.consumeBufLocked(n)
+		p.bufTail -= n
+	}
+}
+
+// consumeBufLocked consumes the first n bytes in p.buf.
+//
+// Preconditions:
+//   - p.mu must be locked.
+//   - p.bufSize >= n.
+func (p *Pipe) consumeBufLocked(n int64) {
 	p.off += n
 	if max := int64(len(p.buf)); p.off >= max {
 		p.off -= max
 	}
-	p.size -= n
+	p.bufSize -= n
 }
 
 // writeLocked passes a safemem.BlockSeq representing the first count bytes of
@@ -272,7 +390,7 @@ func (p *Pipe) writeLocked(count int64, f func(safemem.BlockSeq) (uint64, error)
 	}
 
 	// Ensure that the buffer is big enough.
-	if newLen, oldCap := p.size+count, int64(len(p.buf)); newLen > oldCap {
+	if newLen, oldCap := p.bufSize+count, int64(len(p.buf)); newLen > oldCap {
 		// Allocate a new buffer.
 		newCap := oldCap * 2
 		if oldCap == 0 {
@@ -288,7 +406,7 @@ func (p *Pipe) writeLocked(count int64, f func(safemem.BlockSeq) (uint64, error)
 		// Copy the old buffer's contents to the beginning of the new one.
 		safemem.CopySeq(
 			safemem.BlockSeqOf(safemem.BlockFromSafeSlice(newBuf)),
-			p.bufBlockSeq.DropFirst64(uint64(p.off)).TakeFirst64(uint64(p.size)))
+			p.bufBlockSeq.DropFirst64(uint64(p.off)).TakeFirst64(uint64(p.bufSize)))
 		// Switch to the new buffer.
 		p.buf = newBuf
 		p.bufBlocks[0] = safemem.BlockFromSafeSlice(newBuf)
@@ -298,7 +416,7 @@ func (p *Pipe) writeLocked(count int64, f func(safemem.BlockSeq) (uint64, error)
 	}
 
 	// Prepare the view of the space to be written.
-	woff := p.off + p.size
+	woff := p.off + p.bufSize
 	if woff >= int64(len(p.buf)) {
 		woff -= int64(len(p.buf))
 	}
@@ -307,6 +425,8 @@ func (p *Pipe) writeLocked(count int64, f func(safemem.BlockSeq) (uint64, error)
 	// Perform the write.
 	doneU64, err := f(bs)
 	done := int64(doneU64)
+	p.bufSize += done
+	p.bufTail += done
 	p.size += done
 	if done < count || err != nil {
 		return done, err
@@ -20,7 +20,15 @@ import (
 
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/safemem"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
 	"gvisor.dev/gvisor/pkg/sentry/contexttest"
+	"gvisor.dev/gvisor/pkg/sentry/limits"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+	"gvisor.dev/gvisor/pkg/sentry/platform"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/usermem"
 	"gvisor.dev/gvisor/pkg/waiter"
@@ -147,3 +155,234 @@ func TestPipeWriteUntilEnd(t *testing.T) {
 		}
 	})
 }
+
+func TestPipePinnedOrder(t *testing.T) {
+	runTest(t, 65536, func(ctx context.Context, r *vfs.FileDescription, w *vfs.FileDescription) {
+		if _, err := w.Write(ctx, usermem.BytesIOSequence([]byte("ab")), vfs.WriteOptions{}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		// Simulate a buffer gifted by vmsplice(2).
+		p := w.Impl().(*VFSPipeFD).pipe
+		p.mu.Lock()
+		p.pinned = append(p.pinned, pinnedBuf{
+			bufBytes: p.bufTail,
+			bs:       safemem.BlockSeqOf(safemem.BlockFromSafeSlice([]byte("CD"))),
+		})
+		p.bufTail = 0
+		p.size += 2
+		p.mu.Unlock()
+		if _, err := w.Write(ctx, usermem.BytesIOSequence([]byte("ef")), vfs.WriteOptions{}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+
+		for _, want := range []string{"abC", "Def"} {
+			buf := make([]byte, len(want))
+			n, err := r.Read(ctx, usermem.BytesIOSequence(buf), vfs.ReadOptions{})
+			if n != int64(len(want)) || err != nil || string(buf) != want {
+				t.Fatalf("Read: got (%d, %v) %q, wanted (%d, nil) %q", n, err, buf, len(want), want)
+			}
+		}
+		if len(p.pinned) != 0 || p.size != 0 {
+			t.Errorf("got %d pinned buffers and %d bytes after reading all data, wanted 0 and 0", len(p.pinned), p.size)
+		}
+	})
+}
+
+// newTestMemoryManager returns a MemoryManager with a private anonymous
+// mapping that holds data, and the address of the mapping.
+func newTestMemoryManager(t *testing.T, ctx context.Context, data []byte) (*mm.MemoryManager, hostarch.Addr) {
+	t.Helper()
+	m := mm.NewMemoryManager(platform.FromContext(ctx), pgalloc.MemoryFileFromContext(ctx), false)
+	if _, err := m.SetMmapLayout(arch.New(arch.Host), limits.FromContext(ctx)); err != nil {
+		t.Fatalf("SetMmapLayout failed: %v", err)
+	}
+	length, _ := hostarch.Addr(len(data)).RoundUp()
+	addr, err := m.MMap(ctx, memmap.MMapOpts{
+		Length:   uint64(length),
+		Private:  true,
+		Perms:    hostarch.ReadWrite,
+		MaxPerms: hostarch.AnyAccess,
+	})
+	if err != nil {
+		m.DecUsers(ctx)
+		t.Fatalf("MMap failed: %v", err)
+	}
+	if _, err := m.CopyOut(ctx, addr, data, usermem.IOOpts{}); err != nil {
+		m.DecUsers(ctx)
+		t.Fatalf("CopyOut failed: %v", err)
+	}
+	return m, addr
+}
+
+// vmspliceIOSequence returns an IOSequence for the application memory at the
+// given ranges of m.
+func vmspliceIOSequence(m *mm.MemoryManager, ars ...hostarch.AddrRange) usermem.IOSequence {
+	return usermem.IOSequence{
+		IO:    m,
+		Addrs: hostarch.AddrRangeSeqFromSlice(ars),
+	}
+}
+
+func readAll(t *testing.T, ctx context.Context, r *vfs.FileDescription, n int) []byte {
+	t.Helper()
+	buf := make([]byte, n)
+	got, err := r.Read(ctx, usermem.BytesIOSequence(buf), vfs.ReadOptions{})
+	if got != int64(n) || err != nil {
+		t.Fatalf("Read: got (%d, %v), wanted (%d, nil)", got, err, n)
+	}
+	return buf
+}
+
+func TestVMSplicePinned(t *testing.T) {
+	runTest(t, 65536, func(ctx context.Context, r *vfs.FileDescription, w *vfs.FileDescription) {
+		data := bytes.Repeat([]byte("0123456789abcdef"), 2*hostarch.PageSize/16)
+		m, addr := newTestMemoryManager(t, ctx, data)
+		defer m.DecUsers(ctx)
+
+		if _, err := w.Write(ctx, usermem.BytesIOSequence([]byte("head")), vfs.WriteOptions{}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		// Unaligned ranges are pinned with the pages containing them.
+		ar := hostarch.AddrRange{addr + 8, addr + hostarch.Addr(len(data))}
+		n, err := w.Impl().(*VFSPipeFD).VMSplice(ctx, m, vmspliceIOSequence(m, ar))
+		if n != int64(ar.Length()) || err != nil {
+			t.Fatalf("VMSplice: got (%d, %v), wanted (%d, nil)", n, err, ar.Length())
+		}
+		p := w.Impl().(*VFSPipeFD).pipe
+		if len(p.pinned) != 1 {
+			t.Fatalf("got %d pinned buffers, wanted 1", len(p.pinned))
+		}
+
+		// The pipe references application memory, so later writes to it are
+		// visible to readers.
+		if _, err := m.CopyOut(ctx, addr+hostarch.PageSize, []byte("XYZ"), usermem.IOOpts{}); err != nil {
+			t.Fatalf("CopyOut failed: %v", err)
+		}
+		want := append([]byte("head"), data[8:]...)
+		copy(want[4+hostarch.PageSize-8:], "XYZ")
+		if got := readAll(t, ctx, r, len(want)); !bytes.Equal(got, want) {
+			t.Errorf("Read: got %q, wanted %q", got, want)
+		}
+		if len(p.pinned) != 0 || p.size != 0 {
+			t.Errorf("got %d pinned buffers and %d bytes after reading all data, wanted 0 and 0", len(p.pinned), p.size)
+		}
+	})
+}
+
+func TestVMSpliceCopied(t *testing.T) {
+	runTest(t, 65536, func(ctx context.Context, r *vfs.FileDescription, w *vfs.FileDescription) {
+		data := []byte("short vmsplice")
+		m, addr := newTestMemoryManager(t, ctx, data)
+		defer m.DecUsers(ctx)
+
+		// Ranges smaller than a page are copied rather than pinned.
+		ar := hostarch.AddrRange{addr, addr + hostarch.Addr(len(data))}
+		n, err := w.Impl().(*VFSPipeFD).VMSplice(ctx, m, vmspliceIOSequence(m, ar))
+		if n != int64(len(data)) || err != nil {
+			t.Fatalf("VMSplice: got (%d, %v), wanted (%d, nil)", n, err, len(data))
+		}
+		if p := w.Impl().(*VFSPipeFD).pipe; len(p.pinned) != 0 {
+			t.Fatalf("got %d pinned buffers, wanted 0", len(p.pinned))
+		}
+		if _, err := m.CopyOut(ctx, addr, []byte("SHORT"), usermem.IOOpts{}); err != nil {
+			t.Fatalf("CopyOut failed: %v", err)
+		}
+		if got := readAll(t, ctx, r, len(data)); !bytes.Equal(got, data) {
+			t.Errorf("Read: got %q, wanted %q", got, data)
+		}
+	})
+}
+
+func TestVMSpliceErrors(t *testing.T) {
+	runTest(t, hostarch.PageSize, func(ctx context.Context, r *vfs.FileDescription, w *vfs.FileDescription) {
+		data := make([]byte, 2*hostarch.PageSize)
+		m, addr := newTestMemoryManager(t, ctx, data)
+		defer m.DecUsers(ctx)
+		fd := w.Impl().(*VFSPipeFD)
+
+		// Unmapped memory can be neither pinned nor copied.
+		unmapped := hostarch.AddrRange{addr + 2*hostarch.PageSize, addr + 3*hostarch.PageSize}
+		if n, err := fd.VMSplice(ctx, m, vmspliceIOSequence(m, unmapped)); n != 0 || !linuxerr.Equals(linuxerr.EFAULT, err) {
+			t.Errorf("VMSplice of unmapped memory: got (%d, %v), wanted (0, EFAULT)", n, err)
+		}
+
+		// Only as much as fits in the pipe is written.
+		ar := hostarch.AddrRange{addr, addr + 2*hostarch.PageSize}
+		if n, err := fd.VMSplice(ctx, m, vmspliceIOSequence(m, ar)); n != hostarch.PageSize || err != nil {
+			t.Errorf("VMSplice to an empty pipe: got (%d, %v), wanted (%d, nil)", n, err, hostarch.PageSize)
+		}
+		if n, err := fd.VMSplice(ctx, m, vmspliceIOSequence(m, ar)); n != 0 || err != linuxerr.ErrWouldBlock {
+			t.Errorf("VMSplice to a full pipe: got (%d, %v), wanted (0, %v)", n, err, linuxerr.ErrWouldBlock)
+		}
+	})
+}
+
+func TestVMSpliceFlattenOnSave(t *testing.T) {
+	runTest(t, 65536, func(ctx context.Context, r *vfs.FileDescription, w *vfs.FileDescription) {
+		data := bytes.Repeat([]byte("s"), hostarch.PageSize)
+		m, addr := newTestMemoryManager(t, ctx, data)
+		defer m.DecUsers(ctx)
+
+		if _, err := w.Write(ctx, usermem.BytesIOSequence([]byte("ab")), vfs.WriteOptions{}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		ar := hostarch.AddrRange{addr, addr + hostarch.PageSize}
+		if _, err := w.Impl().(*VFSPipeFD).VMSplice(ctx, m, vmspliceIOSequence(m, ar)); err != nil {
+			t.Fatalf("VMSplice failed: %v", err)
+		}
+		if _, err := w.Write(ctx, usermem.BytesIOSequence([]byte("yz")), vfs.WriteOptions{}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+
+		p := w.Impl().(*VFSPipeFD).pipe
+		p.beforeSave()
+		if len(p.pinned) != 0 {
+			t.Fatalf("got %d pinned buffers after beforeSave, wanted 0", len(p.pinned))
+		}
+		p.afterLoad(ctx)
+
+		// Flattened data is a copy of application memory.
+		if _, err := m.CopyOut(ctx, addr, []byte("S"), usermem.IOOpts{}); err != nil {
+			t.Fatalf("CopyOut failed: %v", err)
+		}
+		want := append(append([]byte("ab"), data...), "yz"...)
+		if got := readAll(t, ctx, r, len(want)); !bytes.Equal(got, want) {
+			t.Errorf("Read: got %q, wanted %q", got, want)
+		}
+	})
+}
+
+func TestVMSpliceFlattenOnRelease(t *testing.T) {
+	ctx := contexttest.Context(t)
+	vfsObj := &vfs.VirtualFilesystem{}
+	if err := vfsObj.Init(ctx); err != nil {
+		t.Fatalf("VFS init: %v", err)
+	}
+	vd := vfsObj.NewAnonVirtualDentry("pipe")
+	defer vd.DecRef(ctx)
+
+	vp := NewVFSPipe(false /* isNamed */, 65536)
+	r, w, err := vp.ReaderWriterPair(ctx, vd.Mount(), vd.Dentry(), 0)
+	if err != nil {
+		t.Fatalf("ReaderWriterPair failed: %v", err)
+	}
+	data := bytes.Repeat([]byte("r"), hostarch.PageSize)
+	m, addr := newTestMemoryManager(t, ctx, data)
+	defer m.DecUsers(ctx)
+
+	ar := hostarch.AddrRange{addr, addr + hostarch.PageSize}
+	if _, err := w.Impl().(*VFSPipeFD).VMSplice(ctx, m, vmspliceIOSequence(m, ar)); err != nil {
+		t.Fatalf("VMSplice failed: %v", err)
+	}
+	p := w.Impl().(*VFSPipeFD).pipe
+
+	// Application memory is released once neither end is open.
+	w.DecRef(ctx)
+	if len(p.pinned) != 1 {
+		t.Errorf("got %d pinned buffers with an open reader, wanted 1", len(p.pinned))
+	}
+	r.DecRef(ctx)
+	if len(p.pinned) != 0 || p.size != int64(len(data)) || !bytes.Equal(p.buf, data) {
+		t.Errorf("got %d pinned buffers and %d bytes after release, wanted 0 and %d", len(p.pinned), p.size, len(data))
+	}
+}
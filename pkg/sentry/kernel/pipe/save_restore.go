@@ -20,6 +20,14 @@ import (
 	"gvisor.dev/gvisor/pkg/safemem"
 )
 
+// beforeSave is called by stateify.
+func (p *Pipe) beforeSave() {
+	// Application memory referenced by the pipe can't be saved with it.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flattenLocked()
+}
+
 // afterLoad is called by stateify.
 func (p *Pipe) afterLoad(context.Context) {
 	p.bufBlocks[0] = safemem.BlockFromSafeSlice(p.buf)
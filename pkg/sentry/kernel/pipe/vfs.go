@@ -189,6 +189,12 @@ func (fd *VFSPipeFD) Release(context.Context) {
 	if event == 0 {
 		panic("invalid pipe flags: must be readable, writable, or both")
 	}
+	if !fd.pipe.HasReaders() && !fd.pipe.HasWriters() {
+		// Don't hold application memory while nothing can read it.
+		fd.pipe.mu.Lock()
+		fd.pipe.flattenLocked()
+		fd.pipe.mu.Unlock()
+	}
 
 	fd.pipe.queue.Notify(event)
 }
@@ -0,0 +1,166 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipe
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/safemem"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// VMSplice implements the write direction of vmsplice(2): it writes the
+// contents of application memory in src to the pipe. Where possible, the pipe
+// references the memory backing src rather than copying it, so later writes
+// to that memory by the application may be visible to readers of the pipe.
+//
+// Unlike Write, VMSplice does not guarantee atomicity for writes of up to
+// PIPE_BUF bytes; it writes as much of src as fits in the pipe and returns
+// ErrWouldBlock only if no bytes could be written.
+//
+// Preconditions: src.IO == m.
+func (fd *VFSPipeFD) VMSplice(ctx context.Context, m *mm.MemoryManager, src usermem.IOSequence) (int64, error) {
+	p := fd.pipe
+	p.mu.Lock()
+	n, err := p.vmspliceLocked(ctx, m, src)
+	p.mu.Unlock()
+
+	if n > 0 {
+		p.queue.Notify(waiter.ReadableEvents)
+	}
+	if linuxerr.Equals(linuxerr.EPIPE, err) {
+		// If we are returning EPIPE send SIGPIPE to the task.
+		if sendSig := linux.SignalNoInfoFuncFromContext(ctx); sendSig != nil {
+			sendSig(linux.SIGPIPE)
+		}
+	}
+	return n, err
+}
+
+// Preconditions:
+//   - p.mu must be locked.
+//   - src.IO == m.
+func (p *Pipe) vmspliceLocked(ctx context.Context, m *mm.MemoryManager, src usermem.IOSequence) (int64, error) {
+	// Can't write to a pipe with no readers.
+	if !p.HasReaders() {
+		return 0, linuxerr.EPIPE
+	}
+	if src.NumBytes() == 0 {
+		return 0, nil
+	}
+	avail := p.max - p.size
+	if avail == 0 {
+		return 0, linuxerr.ErrWouldBlock
+	}
+	src = src.TakeFirst64(avail)
+
+	var done int64
+	for !src.Addrs.IsEmpty() {
+		ar := src.Addrs.Head()
+		if ar.Length() == 0 {
+			src.Addrs = src.Addrs.Tail()
+			continue
+		}
+		if !p.pinLocked(ctx, m, ar) {
+			// Fall back to copying. This also produces the appropriate
+			// error if ar is not readable.
+			n, err := p.writeLocked(int64(ar.Length()), func(dsts safemem.BlockSeq) (uint64, error) {
+				n, err := src.TakeFirst64(int64(ar.Length())).CopyInTo(ctx, &safemem.BlockSeqWriter{Blocks: dsts})
+				return uint64(n), err
+			})
+			done += n
+			if n != int64(ar.Length()) || err != nil {
+				return done, err
+			}
+		} else {
+			done += int64(ar.Length())
+		}
+		src.Addrs = src.Addrs.Tail()
+	}
+	return done, nil
+}
+
+// pinLocked attempts to append the application memory in ar to the pipe
+// without copying it. It returns false if ar must be copied instead.
+//
+// Preconditions:
+//   - p.mu must be locked.
+//   - ar.Length() <= p.max - p.size.
+func (p *Pipe) pinLocked(ctx context.Context, m *mm.MemoryManager, ar hostarch.AddrRange) bool {
+	// Copying is cheaper than pinning for small writes. Pinned buffers
+	// consume pipe slots, which are bounded by the pipe's capacity in pages
+	// as in Linux.
+	if ar.Length() < hostarch.PageSize || int64(len(p.pinned)) >= p.max/hostarch.PageSize {
+		return false
+	}
+	end, ok := ar.End.RoundUp()
+	if !ok {
+		return false
+	}
+	pinAR := hostarch.AddrRange{ar.Start.RoundDown(), end}
+	prs, err := m.Pin(ctx, pinAR, hostarch.Read, false /* ignorePermissions */)
+	if err != nil {
+		mm.Unpin(prs)
+		return false
+	}
+	var blocks []safemem.Block
+	for _, pr := range prs {
+		ims, err := pr.File.MapInternal(pr.FileRange(), hostarch.Read)
+		if err != nil {
+			mm.Unpin(prs)
+			return false
+		}
+		for !ims.IsEmpty() {
+			blocks = append(blocks, ims.Head())
+			ims = ims.Tail()
+		}
+	}
+	bs := safemem.BlockSeqFromSlice(blocks).DropFirst64(uint64(ar.Start - pinAR.Start)).TakeFirst64(uint64(ar.Length()))
+	p.pinned = append(p.pinned, pinnedBuf{
+		bufBytes: p.bufTail,
+		prs:      prs,
+		bs:       bs,
+	})
+	p.bufTail = 0
+	p.size += int64(ar.Length())
+	return true
+}
+
+// flattenLocked copies all data referenced by p.pinned into p.buf and releases
+// p.pinned.
+//
+// Preconditions: p.mu must be locked.
+func (p *Pipe) flattenLocked() {
+	if len(p.pinned) == 0 {
+		return
+	}
+	newBuf := make([]byte, p.size)
+	safemem.CopySeq(safemem.BlockSeqOf(safemem.BlockFromSafeSlice(newBuf)), p.pinnedViewLocked(0, p.size))
+	for i := range p.pinned {
+		mm.Unpin(p.pinned[i].prs)
+	}
+	p.pinned = nil
+	p.buf = newBuf
+	p.bufBlocks[0] = safemem.BlockFromSafeSlice(newBuf)
+	p.bufBlocks[1] = p.bufBlocks[0]
+	p.bufBlockSeq = safemem.BlockSeqFromSlice(p.bufBlocks[:])
+	p.off = 0
+	p.bufSize = p.size
+	p.bufTail = p.size
+}
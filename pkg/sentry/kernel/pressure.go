@@ -0,0 +1,149 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"time"
+
+	"gvisor.dev/gvisor/pkg/gohacks"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
+)
+
+// Pressure stall information is sampled by the CPU clock ticker: on every
+// tick, each task is classified as follows, and the counts are accounted to
+// the pressure group of the task's cgroup on the unified hierarchy and its
+// ancestors, or to the system-wide group if the task isn't in a cgroup on it.
+//
+//   - Running tasks are runnable, and on a CPU if they were among those
+//     accounted the tick's CPU time. Tasks whose cpu cgroup is throttled are
+//     runnable but not on a CPU.
+//
+//   - Tasks waiting for a memory cgroup OOM to be resolved, and running tasks
+//     whose memory allocation has been in progress for memoryStallThreshold,
+//     are stalled on memory.
+//
+//   - Tasks in uninterruptible sleep, which the sentry mostly uses for host
+//     I/O (e.g. gofer RPCs), are waiting for I/O.
+//
+// While no tasks are running, task states can't change until a task runs
+// again, so the ticker takes a last sample before it stops and the state
+// sampled persists until it restarts.
+
+// memoryStallThreshold is the duration after which a memory allocation in
+// progress is accounted as a memory stall. Allocations normally complete
+// much faster; slower allocations indicate that the host is reclaiming
+// memory to satisfy them.
+const memoryStallThreshold = time.Millisecond
+
+// PressureGroup returns the system-wide pressure stall information.
+func (k *Kernel) PressureGroup() *psi.Group {
+	return k.pressure
+}
+
+// SetPressureGroup sets the pressure stall information group of t's cgroup on
+// the unified hierarchy. g may be nil if t isn't in a cgroup on it.
+func (t *Task) SetPressureGroup(g *psi.Group) {
+	t.pressureGroup.Store(g)
+}
+
+// MemoryStallStart implements pgalloc.StallTracker.MemoryStallStart.
+func (t *Task) MemoryStallStart() {
+	t.allocStart.Store(gohacks.Nanotime())
+}
+
+// MemoryStallFinish implements pgalloc.StallTracker.MemoryStallFinish.
+func (t *Task) MemoryStallFinish() {
+	t.allocStart.Store(0)
+}
+
+// pressureCounts returns the contribution of t to the task counts of its
+// pressure groups, given the result of gohacks.Nanotime at the sample.
+func (t *Task) pressureCounts(nanotime int64) psi.TaskCounts {
+	var c psi.TaskCounts
+	switch t.TaskGoroutineState() {
+	case TaskGoroutineRunningApp, TaskGoroutineRunningSys:
+		c.Running = 1
+		if start := t.allocStart.Load(); start != 0 && nanotime-start >= memoryStallThreshold.Nanoseconds() {
+			c.MemoryStalled = 1
+			c.MemoryStalledRunning = 1
+		}
+	case TaskGoroutineBlockedInterruptible:
+		if t.cpuThrottled.Load() {
+			c.Running = 1
+		}
+		if t.oomWaiting.Load() {
+			c.MemoryStalled = 1
+		}
+	case TaskGoroutineBlockedUninterruptible:
+		c.IOWaiting = 1
+	}
+	return c
+}
+
+// addPressureCounts adds c to the counts of t's pressure groups in groups.
+func (k *Kernel) addPressureCounts(groups map[*psi.Group]*psi.TaskCounts, t *Task, c psi.TaskCounts) {
+	g := t.pressureGroup.Load()
+	if g == nil {
+		g = k.pressure
+	}
+	for ; g != nil; g = g.Parent() {
+		counts, ok := groups[g]
+		if !ok {
+			counts = &psi.TaskCounts{}
+			groups[g] = counts
+		}
+		counts.Add(c)
+	}
+}
+
+// samplePressure samples the state of allTasks, of which onCPU were
+// accounted the last CPU clock tick, and records it in their pressure groups.
+// groups maps the groups sampled with stalled or running tasks to their
+// counts; it's storage for use by samplePressure across calls, so that groups
+// whose tasks become idle are recorded as such.
+//
+// Preconditions: The caller must be the CPU clock ticker.
+func (k *Kernel) samplePressure(allTasks, onCPU []*Task, groups map[*psi.Group]*psi.TaskCounts) {
+	now := k.MonotonicClock().Now().Nanoseconds()
+	nanotime := gohacks.Nanotime()
+	for _, counts := range groups {
+		*counts = psi.TaskCounts{}
+	}
+	for _, t := range allTasks {
+		if c := t.pressureCounts(nanotime); c != (psi.TaskCounts{}) {
+			k.addPressureCounts(groups, t, c)
+		}
+	}
+	for _, t := range onCPU {
+		k.addPressureCounts(groups, t, psi.TaskCounts{OnCPU: 1})
+	}
+	for g, counts := range groups {
+		g.Record(now, *counts)
+		if *counts == (psi.TaskCounts{}) {
+			delete(groups, g)
+		}
+	}
+}
+
+// flushPressure brings groups up to date after the CPU clock ticker was
+// stopped.
+//
+// Preconditions: The caller must be the CPU clock ticker.
+func (k *Kernel) flushPressure(groups map[*psi.Group]*psi.TaskCounts) {
+	now := k.MonotonicClock().Now().Nanoseconds()
+	for g := range groups {
+		g.Flush(now)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package psi
+
+import (
+	"bytes"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/ktime"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// maxTriggerLen is the maximum length of a trigger written to a pressure
+// file, see Linux, kernel/sched/psi.c:psi_write().
+const maxTriggerLen = 32
+
+// File is a pressure file, like /proc/pressure/memory or memory.pressure in a
+// cgroup: reading it returns the statistics of a resource, and writing a
+// trigger to it makes poll(2) on the file description report POLLPRI when
+// the trigger fires.
+//
+// +stateify savable
+type File struct {
+	kernfs.DynamicBytesFile
+}
+
+var _ kernfs.Inode = (*File)(nil)
+
+// NewFile returns a pressure file for the statistics of r in g. clock is the
+// monotonic clock used to bring g up to date when the file is read.
+func NewFile(ctx context.Context, creds *auth.Credentials, devMajor, devMinor uint32, ino uint64, perm linux.FileMode, g *Group, r Resource, clock ktime.Clock) *File {
+	f := &File{}
+	f.Init(ctx, creds, devMajor, devMinor, ino, perm, g, r, clock)
+	return f
+}
+
+// Init initializes a pressure file, like NewFile.
+func (f *File) Init(ctx context.Context, creds *auth.Credentials, devMajor, devMinor uint32, ino uint64, perm linux.FileMode, g *Group, r Resource, clock ktime.Clock) {
+	f.DynamicBytesFile.Init(ctx, creds, devMajor, devMinor, ino, &fileData{group: g, res: r, clock: clock}, perm)
+}
+
+// Open implements kernfs.Inode.Open.
+func (f *File) Open(ctx context.Context, rp *vfs.ResolvingPath, d *kernfs.Dentry, opts vfs.OpenOptions) (*vfs.FileDescription, error) {
+	fd := &fileFD{
+		inode: f,
+		data:  f.Data().(*fileData),
+	}
+	fd.LockFD.Init(f.Locks())
+	if err := fd.vfsfd.Init(fd, opts.Flags, rp.Mount(), d.VFSDentry(), &vfs.FileDescriptionOptions{
+		DenySpliceIn: true,
+	}); err != nil {
+		return nil, err
+	}
+	fd.DynamicBytesFileDescriptionImpl.Init(&fd.vfsfd, fd.data)
+	return &fd.vfsfd, nil
+}
+
+// fileData implements vfs.DynamicBytesSource for a pressure file.
+//
+// +stateify savable
+type fileData struct {
+	group *Group
+	res   Resource
+	clock ktime.Clock
+}
+
+// now returns the current time of d's clock.
+func (d *fileData) now() int64 {
+	return d.clock.Now().Nanoseconds()
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *fileData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	d.group.Flush(d.now())
+	d.group.Write(buf, d.res)
+	return nil
+}
+
+// fileFD implements vfs.FileDescriptionImpl for a File. It's mostly similar to
+// kernfs.DynamicBytesFD, but supports triggers.
+//
+// +stateify savable
+type fileFD struct {
+	vfs.FileDescriptionDefaultImpl
+	vfs.DynamicBytesFileDescriptionImpl
+	vfs.LockFD
+
+	vfsfd vfs.FileDescription
+	inode *File
+	data  *fileData
+
+	mu sync.Mutex `state:"nosave"`
+
+	// trigger is the trigger set through fd, if any. A file description can
+	// only have one trigger.
+	//
+	// +checklocks:mu
+	trigger *Trigger
+}
+
+// Seek implements vfs.FileDescriptionImpl.Seek.
+func (fd *fileFD) Seek(ctx context.Context, offset int64, whence int32) (int64, error) {
+	return fd.DynamicBytesFileDescriptionImpl.Seek(ctx, offset, whence)
+}
+
+// Read implements vfs.FileDescriptionImpl.Read.
+func (fd *fileFD) Read(ctx context.Context, dst usermem.IOSequence, opts vfs.ReadOptions) (int64, error) {
+	return fd.DynamicBytesFileDescriptionImpl.Read(ctx, dst, opts)
+}
+
+// PRead implements vfs.FileDescriptionImpl.PRead.
+func (fd *fileFD) PRead(ctx context.Context, dst usermem.IOSequence, offset int64, opts vfs.ReadOptions) (int64, error) {
+	return fd.DynamicBytesFileDescriptionImpl.PRead(ctx, dst, offset, opts)
+}
+
+// Write implements vfs.FileDescriptionImpl.Write.
+func (fd *fileFD) Write(ctx context.Context, src usermem.IOSequence, opts vfs.WriteOptions) (int64, error) {
+	return fd.PWrite(ctx, src, 0, opts)
+}
+
+// PWrite implements vfs.FileDescriptionImpl.PWrite. It sets a trigger, see
+// Linux, kernel/sched/psi.c:psi_write().
+func (fd *fileFD) PWrite(ctx context.Context, src usermem.IOSequence, offset int64, opts vfs.WriteOptions) (int64, error) {
+	n := src.NumBytes()
+	if n == 0 {
+		return 0, linuxerr.EINVAL
+	}
+	buf := make([]byte, min(n, maxTriggerLen))
+	if _, err := src.CopyIn(ctx, buf); err != nil {
+		return 0, err
+	}
+	creds := auth.CredentialsFromContext(ctx)
+	privileged := creds.HasCapabilityIn(linux.CAP_SYS_RESOURCE, creds.UserNamespace.Root())
+	t, err := ParseTrigger(string(bytes.TrimRight(buf, "\x00")), fd.data.res, privileged)
+	if err != nil {
+		return 0, err
+	}
+
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	if fd.trigger != nil {
+		return 0, linuxerr.EBUSY
+	}
+	if err := fd.data.group.AddTrigger(t, fd.data.now()); err != nil {
+		return 0, err
+	}
+	fd.trigger = t
+	return n, nil
+}
+
+// Release implements vfs.FileDescriptionImpl.Release.
+func (fd *fileFD) Release(context.Context) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	if fd.trigger != nil {
+		fd.data.group.RemoveTrigger(fd.trigger)
+		fd.trigger = nil
+	}
+}
+
+// Stat implements vfs.FileDescriptionImpl.Stat.
+func (fd *fileFD) Stat(ctx context.Context, opts vfs.StatOptions) (linux.Statx, error) {
+	fs := fd.vfsfd.VirtualDentry().Mount().Filesystem()
+	return fd.inode.Stat(ctx, fs, opts)
+}
+
+// SetStat implements vfs.FileDescriptionImpl.SetStat.
+func (fd *fileFD) SetStat(context.Context, vfs.SetStatOptions) error {
+	return linuxerr.EPERM
+}
+
+// getTrigger returns fd's trigger, or nil if it has none.
+func (fd *fileFD) getTrigger() *Trigger {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return fd.trigger
+}
+
+// Readiness implements waiter.Waitable.Readiness, see Linux,
+// kernel/sched/psi.c:psi_trigger_poll(). As in Linux, reporting an event
+// consumes it.
+func (fd *fileFD) Readiness(mask waiter.EventMask) waiter.EventMask {
+	t := fd.getTrigger()
+	if t == nil {
+		return (waiter.ReadableEvents | waiter.WritableEvents) & mask
+	}
+	if mask&waiter.EventPri != 0 && t.Consume() {
+		return waiter.EventPri
+	}
+	return 0
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (fd *fileFD) EventRegister(e *waiter.Entry) error {
+	if t := fd.getTrigger(); t != nil {
+		t.EventRegister(e)
+	}
+	return nil
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (fd *fileFD) EventUnregister(e *waiter.Entry) {
+	if t := fd.getTrigger(); t != nil {
+		t.EventUnregister(e)
+	}
+}
+
+// Epollable implements vfs.FileDescriptionImpl.Epollable.
+func (fd *fileFD) Epollable() bool {
+	return true
+}
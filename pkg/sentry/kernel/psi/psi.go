@@ -0,0 +1,364 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package psi implements pressure stall information, which reports the share
+// of time that tasks are delayed waiting for CPUs, memory or I/O. See Linux,
+// Documentation/accounting/psi.rst.
+//
+// Unlike Linux, which accounts every task state change, the sentry samples the
+// state of all tasks on every CPU clock tick, and accounts the whole tick to
+// the states observed. All times are in nanoseconds, and must be taken from
+// the same monotonic clock.
+package psi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// Resource is a resource for which pressure is reported.
+type Resource int
+
+// Resources.
+const (
+	CPU Resource = iota
+	Memory
+	IO
+
+	// NumResources is the number of resources.
+	NumResources
+)
+
+// String implements fmt.Stringer.
+func (r Resource) String() string {
+	switch r {
+	case CPU:
+		return "cpu"
+	case Memory:
+		return "memory"
+	case IO:
+		return "io"
+	default:
+		return fmt.Sprintf("Resource(%d)", int(r))
+	}
+}
+
+// Stall kinds: "some" indicates that at least one task was stalled on a
+// resource, and "full" that all non-idle tasks were stalled on it
+// simultaneously.
+const (
+	some = iota
+	full
+	numKinds
+)
+
+// Averaging parameters, see Linux, kernel/sched/psi.c.
+const (
+	// avgPeriod is the interval at which running averages are updated.
+	avgPeriod = int64(2 * time.Second)
+
+	// numAvgs is the number of running averages.
+	numAvgs = 3
+)
+
+// avgWindows are the windows of the running averages.
+var avgWindows = [numAvgs]time.Duration{10 * time.Second, 60 * time.Second, 300 * time.Second}
+
+// avgDecay[i] is the factor by which the average over avgWindows[i] decays
+// in each avgPeriod.
+var avgDecay = func() (decay [numAvgs]float64) {
+	for i, w := range avgWindows {
+		decay[i] = math.Exp(-float64(avgPeriod) / float64(w.Nanoseconds()))
+	}
+	return decay
+}()
+
+// TaskCounts are the numbers of tasks of a group in each state at a sample,
+// with the meaning of the corresponding Linux task counts, see Linux,
+// include/linux/psi_types.h.
+type TaskCounts struct {
+	// Running is the number of runnable tasks, whether or not they are on a
+	// CPU.
+	Running int
+
+	// OnCPU is the number of running tasks that are on a CPU.
+	OnCPU int
+
+	// MemoryStalled is the number of tasks stalled on memory, whether or not
+	// they are running.
+	MemoryStalled int
+
+	// MemoryStalledRunning is the number of running tasks stalled on memory,
+	// e.g. while waiting for an allocation.
+	MemoryStalledRunning int
+
+	// IOWaiting is the number of tasks blocked on I/O.
+	IOWaiting int
+}
+
+// Add adds the counts of o to c.
+func (c *TaskCounts) Add(o TaskCounts) {
+	c.Running += o.Running
+	c.OnCPU += o.OnCPU
+	c.MemoryStalled += o.MemoryStalled
+	c.MemoryStalledRunning += o.MemoryStalledRunning
+	c.IOWaiting += o.IOWaiting
+}
+
+// stalled returns whether c is a stall of kind on r, see Linux,
+// kernel/sched/psi.c:test_state().
+func (c *TaskCounts) stalled(r Resource, kind int) bool {
+	switch r {
+	case CPU:
+		if kind == some {
+			return c.Running > c.OnCPU
+		}
+		return c.Running > 0 && c.OnCPU == 0
+	case Memory:
+		if kind == some {
+			return c.MemoryStalled > 0
+		}
+		return c.MemoryStalled > 0 && c.Running == c.MemoryStalledRunning
+	case IO:
+		if kind == some {
+			return c.IOWaiting > 0
+		}
+		return c.IOWaiting > 0 && c.Running == 0
+	default:
+		panic(fmt.Sprintf("invalid resource %d", r))
+	}
+}
+
+// Group accumulates the pressure stall information of a group of tasks,
+// either all tasks in the system or the tasks in a cgroup and its
+// descendants.
+//
+// +stateify savable
+type Group struct {
+	// parent is the group of the parent cgroup, or nil if this is the
+	// system-wide group. Immutable.
+	parent *Group
+
+	mu sync.Mutex `state:"nosave"`
+
+	// last are the task counts of the group at the last sample, and lastTime
+	// the time of that sample.
+	//
+	// +checklocks:mu
+	last TaskCounts
+	// +checklocks:mu
+	lastTime int64
+
+	// total[r][kind] is the total stall time of kind on r.
+	//
+	// +checklocks:mu
+	total [NumResources][numKinds]int64
+
+	// avgs[r][kind] are the running averages of the stall time of kind on
+	// r, in percent.
+	//
+	// +checklocks:mu
+	avgs [NumResources][numKinds][numAvgs]float64
+
+	// avgTotal are the values of total accounted in avgs, and avgLast the
+	// time avgs were last updated. avgNext is the time of the next update.
+	//
+	// +checklocks:mu
+	avgTotal [NumResources][numKinds]int64
+	// +checklocks:mu
+	avgLast int64
+	// +checklocks:mu
+	avgNext int64
+
+	// triggers are the triggers set on the group.
+	//
+	// +checklocks:mu
+	triggers []*Trigger
+}
+
+// NewGroup returns a new group whose statistics start at now. parent is the
+// group of the parent cgroup, or nil for the system-wide group.
+func NewGroup(parent *Group, now int64) *Group {
+	return &Group{
+		parent:   parent,
+		lastTime: now,
+		avgLast:  now,
+		avgNext:  now + avgPeriod,
+	}
+}
+
+// Parent returns the group of g's parent cgroup, or nil if g is the
+// system-wide group.
+func (g *Group) Parent() *Group {
+	return g.parent
+}
+
+// system returns whether g is the system-wide group. As in Linux, full CPU
+// pressure is undefined system-wide and reported as 0.
+func (g *Group) system() bool {
+	return g.parent == nil
+}
+
+// Record accounts the time since the last sample to the states of c, the
+// task counts of the group sampled at now.
+func (g *Group) Record(now int64, c TaskCounts) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.recordLocked(now, c)
+}
+
+// Flush accounts the time until now as if the states observed at the last
+// sample persisted. This brings g up to date when no samples are taken, e.g.
+// while no tasks are running.
+func (g *Group) Flush(now int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.recordLocked(now, g.last)
+}
+
+// +checklocks:g.mu
+func (g *Group) recordLocked(now int64, c TaskCounts) {
+	d := now - g.lastTime
+	g.last = c
+	if d <= 0 {
+		return
+	}
+	g.lastTime = now
+
+	stalled := false
+	for r := Resource(0); r < NumResources; r++ {
+		for kind := 0; kind < numKinds; kind++ {
+			if r == CPU && kind == full && g.system() {
+				continue
+			}
+			if c.stalled(r, kind) {
+				g.total[r][kind] += d
+				stalled = true
+			}
+		}
+	}
+	if now >= g.avgNext {
+		g.updateAvgsLocked(now)
+	}
+	if stalled || g.pendingTriggersLocked() {
+		for _, t := range g.triggers {
+			t.update(now, g.total[t.res][t.kind])
+		}
+	}
+}
+
+// updateAvgsLocked updates the running averages, see Linux,
+// kernel/sched/psi.c:update_averages().
+//
+// Preconditions: now >= g.avgNext.
+//
+// +checklocks:g.mu
+func (g *Group) updateAvgsLocked(now int64) {
+	// Periods in which no update happened have no recorded stall time, so
+	// the averages decay for each of them, and the stall time since the last
+	// update is accounted to the current period.
+	missed := (now - g.avgNext) / avgPeriod
+	g.avgNext += (1 + missed) * avgPeriod
+	period := now - (g.avgLast + missed*avgPeriod)
+	g.avgLast = now
+
+	for r := Resource(0); r < NumResources; r++ {
+		for kind := 0; kind < numKinds; kind++ {
+			sample := min(g.total[r][kind]-g.avgTotal[r][kind], period)
+			g.avgTotal[r][kind] += sample
+			pct := float64(sample) * 100 / float64(period)
+			for i := range g.avgs[r][kind] {
+				avg := g.avgs[r][kind][i] * math.Pow(avgDecay[i], float64(missed))
+				g.avgs[r][kind][i] = avg*avgDecay[i] + pct*(1-avgDecay[i])
+			}
+		}
+	}
+}
+
+// pendingTriggersLocked returns whether any trigger has crossed its
+// threshold but couldn't signal an event yet.
+//
+// +checklocks:g.mu
+func (g *Group) pendingTriggersLocked() bool {
+	for _, t := range g.triggers {
+		if t.pending {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTriggers returns whether any trigger is set on g.
+func (g *Group) HasTriggers() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.triggers) > 0
+}
+
+// Stalled returns whether any task of g was stalled at the last sample.
+func (g *Group) Stalled() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for r := Resource(0); r < NumResources; r++ {
+		if g.last.stalled(r, some) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats are the pressure stall statistics of a kind of stall.
+type Stats struct {
+	// Avg10, Avg60 and Avg300 are the percentages of time stalled, averaged
+	// over the last 10, 60 and 300 seconds.
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+
+	// Total is the total time stalled.
+	Total time.Duration
+}
+
+// Stats returns the statistics of "some" and "full" stalls on r, as of the
+// last sample.
+func (g *Group) Stats(r Resource) (someStats, fullStats Stats) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := func(kind int) Stats {
+		return Stats{
+			Avg10:  g.avgs[r][kind][0],
+			Avg60:  g.avgs[r][kind][1],
+			Avg300: g.avgs[r][kind][2],
+			Total:  time.Duration(g.total[r][kind]),
+		}
+	}
+	return stats(some), stats(full)
+}
+
+// Write writes the statistics of r in the format of the pressure files, see
+// Linux, kernel/sched/psi.c:psi_show().
+func (g *Group) Write(buf *bytes.Buffer, r Resource) {
+	someStats, fullStats := g.Stats(r)
+	for _, l := range []struct {
+		name  string
+		stats Stats
+	}{{"some", someStats}, {"full", fullStats}} {
+		fmt.Fprintf(buf, "%s avg10=%.2f avg60=%.2f avg300=%.2f total=%d\n",
+			l.name, l.stats.Avg10, l.stats.Avg60, l.stats.Avg300, l.stats.Total.Microseconds())
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package psi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const testTick = int64(10 * time.Millisecond)
+
+// record records c in g for d, one tick at a time, starting at *now.
+func record(g *Group, now *int64, d time.Duration, c TaskCounts) {
+	for end := *now + d.Nanoseconds(); *now < end; {
+		*now += testTick
+		g.Record(*now, c)
+	}
+}
+
+func TestStalls(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		counts TaskCounts
+		// want[r] are the expected "some" and "full" stalls on r.
+		want [NumResources][numKinds]bool
+	}{
+		{
+			name:   "idle",
+			counts: TaskCounts{},
+		},
+		{
+			name:   "running",
+			counts: TaskCounts{Running: 2, OnCPU: 2},
+		},
+		{
+			name:   "cpu contended",
+			counts: TaskCounts{Running: 3, OnCPU: 2},
+			want:   [NumResources][numKinds]bool{CPU: {true, false}},
+		},
+		{
+			name:   "cpu throttled",
+			counts: TaskCounts{Running: 1},
+			want:   [NumResources][numKinds]bool{CPU: {true, true}},
+		},
+		{
+			name:   "memory stall while others run",
+			counts: TaskCounts{Running: 2, OnCPU: 2, MemoryStalled: 1, MemoryStalledRunning: 1},
+			want:   [NumResources][numKinds]bool{Memory: {true, false}},
+		},
+		{
+			name:   "memory stall only",
+			counts: TaskCounts{Running: 1, OnCPU: 1, MemoryStalled: 2, MemoryStalledRunning: 1},
+			want:   [NumResources][numKinds]bool{Memory: {true, true}},
+		},
+		{
+			name:   "io wait while others run",
+			counts: TaskCounts{Running: 1, OnCPU: 1, IOWaiting: 1},
+			want:   [NumResources][numKinds]bool{IO: {true, false}},
+		},
+		{
+			name:   "io wait only",
+			counts: TaskCounts{IOWaiting: 3},
+			want:   [NumResources][numKinds]bool{IO: {true, true}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			now := int64(0)
+			g := NewGroup(NewGroup(nil, now), now)
+			record(g, &now, time.Second, test.counts)
+			for r := Resource(0); r < NumResources; r++ {
+				someStats, fullStats := g.Stats(r)
+				for kind, stats := range []Stats{someStats, fullStats} {
+					want := time.Duration(0)
+					if test.want[r][kind] {
+						want = time.Second
+					}
+					if stats.Total != want {
+						t.Errorf("%v kind %d: got total %v, want %v", r, kind, stats.Total, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSystemCPUFull(t *testing.T) {
+	now := int64(0)
+	g := NewGroup(nil, now)
+	record(g, &now, time.Second, TaskCounts{Running: 1})
+	if someStats, fullStats := g.Stats(CPU); someStats.Total != time.Second || fullStats.Total != 0 {
+		t.Errorf("got some/full totals %v/%v, want %v/0", someStats.Total, fullStats.Total, time.Second)
+	}
+	trigger, err := ParseTrigger("full 100000 2000000", CPU, false /* privileged */)
+	if err != nil {
+		t.Fatalf("ParseTrigger: %v", err)
+	}
+	if err := g.AddTrigger(trigger, now); err == nil {
+		t.Errorf("AddTrigger for system-wide full CPU pressure succeeded")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	now := int64(0)
+	g := NewGroup(nil, now)
+	record(g, &now, time.Second, TaskCounts{IOWaiting: 1})
+	// No samples are taken while no task is running; the last sample's state
+	// persists.
+	now += int64(time.Second)
+	g.Flush(now)
+	if someStats, _ := g.Stats(IO); someStats.Total != 2*time.Second {
+		t.Errorf("got total %v, want %v", someStats.Total, 2*time.Second)
+	}
+	// Flushing again doesn't account any more time.
+	g.Flush(now)
+	if someStats, _ := g.Stats(IO); someStats.Total != 2*time.Second {
+		t.Errorf("got total %v after second flush, want %v", someStats.Total, 2*time.Second)
+	}
+}
+
+func TestAverages(t *testing.T) {
+	now := int64(0)
+	g := NewGroup(nil, now)
+	// Stall half of the time for half an hour, so that all averages converge
+	// to 50%.
+	for i := 0; i < 1800; i++ {
+		record(g, &now, 500*time.Millisecond, TaskCounts{MemoryStalled: 1})
+		record(g, &now, 500*time.Millisecond, TaskCounts{Running: 1, OnCPU: 1})
+	}
+	someStats, fullStats := g.Stats(Memory)
+	for _, avg := range []float64{someStats.Avg10, someStats.Avg60, someStats.Avg300, fullStats.Avg10, fullStats.Avg60, fullStats.Avg300} {
+		if math.Abs(avg-50) > 1 {
+			t.Errorf("got averages %+v, %+v; want 50", someStats, fullStats)
+			break
+		}
+	}
+
+	// Averages decay while there are no samples.
+	now += int64(time.Minute)
+	g.Record(now, TaskCounts{})
+	someStats, _ = g.Stats(Memory)
+	if someStats.Avg10 > 1 || someStats.Avg60 > 50*math.Exp(-1)+1 || someStats.Avg300 < 40 {
+		t.Errorf("got averages %+v after a minute without stalls", someStats)
+	}
+
+	var buf bytes.Buffer
+	g.Write(&buf, IO)
+	if got, want := buf.String(), "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseTrigger(t *testing.T) {
+	for _, test := range []struct {
+		str        string
+		privileged bool
+		ok         bool
+	}{
+		{str: "some 150000 2000000", ok: true},
+		{str: "full 50000 10000000\n", ok: true},
+		{str: "some 150000 1000000", privileged: true, ok: true},
+		// Unprivileged windows must be multiples of 2s.
+		{str: "some 150000 1000000"},
+		{str: "avg 150000 2000000"},
+		{str: "some 150000"},
+		{str: "some 150000 2000000 1"},
+		{str: "some 0 2000000"},
+		{str: "some 3000000 2000000"},
+		{str: "some 1000 400000", privileged: true},
+		{str: "some 1000 12000000"},
+	} {
+		_, err := ParseTrigger(test.str, Memory, test.privileged)
+		if got := err == nil; got != test.ok {
+			t.Errorf("ParseTrigger(%q, privileged=%t): got err %v, want success %t", test.str, test.privileged, err, test.ok)
+		}
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	now := int64(0)
+	g := NewGroup(nil, now)
+	// Start well after boot, so that the first event isn't rate limited.
+	record(g, &now, 4*time.Second, TaskCounts{})
+	trigger, err := ParseTrigger("some 500000 2000000", IO, false /* privileged */)
+	if err != nil {
+		t.Fatalf("ParseTrigger: %v", err)
+	}
+	if err := g.AddTrigger(trigger, now); err != nil {
+		t.Fatalf("AddTrigger: %v", err)
+	}
+	e, ch := waiter.NewChannelEntry(waiter.EventPri)
+	trigger.EventRegister(&e)
+	defer trigger.EventUnregister(&e)
+
+	// 400ms of stall in the window is below the threshold.
+	record(g, &now, 400*time.Millisecond, TaskCounts{IOWaiting: 1})
+	if trigger.Consume() {
+		t.Fatalf("trigger fired below its threshold")
+	}
+	record(g, &now, 200*time.Millisecond, TaskCounts{IOWaiting: 1})
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("trigger didn't notify after crossing its threshold")
+	}
+	if !trigger.Consume() {
+		t.Fatalf("trigger didn't fire after crossing its threshold")
+	}
+	if trigger.Consume() {
+		t.Fatalf("event was not consumed")
+	}
+
+	// Events are limited to one per window.
+	record(g, &now, time.Second, TaskCounts{IOWaiting: 1})
+	if trigger.Consume() {
+		t.Fatalf("trigger fired twice in a window")
+	}
+	record(g, &now, time.Second, TaskCounts{IOWaiting: 1})
+	if !trigger.Consume() {
+		t.Fatalf("trigger didn't fire again in the next window")
+	}
+
+	g.RemoveTrigger(trigger)
+	record(g, &now, 4*time.Second, TaskCounts{IOWaiting: 1})
+	if trigger.Consume() {
+		t.Fatalf("removed trigger fired")
+	}
+}
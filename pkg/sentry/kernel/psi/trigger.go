@@ -0,0 +1,196 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package psi
+
+import (
+	"fmt"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// Bounds of trigger windows, see Linux, kernel/sched/psi.c.
+const (
+	minTriggerWindow = 500 * time.Millisecond
+	maxTriggerWindow = 10 * time.Second
+)
+
+// Trigger signals an event when the stall time of a kind on a resource
+// exceeds a threshold within a time window, at most once per window. See
+// Linux, Documentation/accounting/psi.rst, "Monitoring for pressure
+// thresholds".
+//
+// +stateify savable
+type Trigger struct {
+	// res, kind, threshold and window are the parameters of the trigger.
+	// Immutable.
+	res       Resource
+	kind      int
+	threshold int64
+	window    int64
+
+	// queue is notified when an event is signalled.
+	queue waiter.Queue
+
+	// event is true if an event was signalled and not yet consumed.
+	event atomicbitops.Bool
+
+	// The following fields are protected by the mutex of the group the
+	// trigger is set on.
+
+	// winStart is the start time of the current window, winStartTotal the
+	// stall time at that time, and prevGrowth the stall time growth during
+	// the previous window.
+	winStart      int64
+	winStartTotal int64
+	prevGrowth    int64
+
+	// lastTotal is the stall time at the last update.
+	lastTotal int64
+
+	// lastEvent is the time of the last event.
+	lastEvent int64
+
+	// pending is true if the threshold was crossed, but no event was signalled
+	// yet because the previous one was less than a window ago.
+	pending bool
+}
+
+// ParseTrigger parses a trigger written to a pressure file for r, "<some|full>
+// <threshold> <window>" with times in microseconds. Unless privileged is true,
+// the window must be a multiple of the averaging period, as in Linux.
+func ParseTrigger(str string, r Resource, privileged bool) (*Trigger, error) {
+	var (
+		kindName              string
+		thresholdUS, windowUS uint64
+		extra                 string
+	)
+	n, _ := fmt.Sscan(str, &kindName, &thresholdUS, &windowUS, &extra)
+	if n != 3 {
+		return nil, linuxerr.EINVAL
+	}
+	t := &Trigger{
+		res:       r,
+		threshold: int64(thresholdUS) * 1000,
+		window:    int64(windowUS) * 1000,
+	}
+	switch kindName {
+	case "some":
+		t.kind = some
+	case "full":
+		t.kind = full
+	default:
+		return nil, linuxerr.EINVAL
+	}
+	if windowUS > uint64(maxTriggerWindow.Microseconds()) || t.window < minTriggerWindow.Nanoseconds() {
+		return nil, linuxerr.EINVAL
+	}
+	if !privileged && t.window%avgPeriod != 0 {
+		return nil, linuxerr.EINVAL
+	}
+	if t.threshold == 0 || t.threshold > t.window {
+		return nil, linuxerr.EINVAL
+	}
+	return t, nil
+}
+
+// AddTrigger sets t on g at now.
+func (g *Group) AddTrigger(t *Trigger, now int64) error {
+	if t.res == CPU && t.kind == full && g.system() {
+		return linuxerr.EINVAL
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t.winStart = now
+	t.winStartTotal = g.total[t.res][t.kind]
+	t.lastTotal = t.winStartTotal
+	g.triggers = append(g.triggers, t)
+	return nil
+}
+
+// RemoveTrigger removes t from g.
+func (g *Group) RemoveTrigger(t *Trigger) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, other := range g.triggers {
+		if other == t {
+			g.triggers = append(g.triggers[:i], g.triggers[i+1:]...)
+			return
+		}
+	}
+}
+
+// update updates t with the stall time total at now, and signals an event if
+// the threshold is crossed. See Linux, kernel/sched/psi.c:update_triggers().
+//
+// Preconditions: The caller must hold the mutex of the group t is set on.
+func (t *Trigger) update(now, total int64) {
+	newStall := total != t.lastTotal
+	t.lastTotal = total
+	if !newStall && !t.pending {
+		return
+	}
+	if newStall {
+		growth := t.windowUpdate(now, total)
+		if !t.pending {
+			if growth < t.threshold {
+				return
+			}
+			t.pending = true
+		}
+	}
+	// Limit events to one per window.
+	if now < t.lastEvent+t.window {
+		return
+	}
+	t.lastEvent = now
+	t.pending = false
+	if !t.event.Swap(true) {
+		t.queue.Notify(waiter.EventPri)
+	}
+}
+
+// windowUpdate returns the stall time growth over the last window, where the
+// growth of the previous window is interpolated for the part of the window
+// before the current one started. See Linux,
+// kernel/sched/psi.c:window_update().
+func (t *Trigger) windowUpdate(now, total int64) int64 {
+	elapsed := now - t.winStart
+	growth := total - t.winStartTotal
+	if elapsed > t.window {
+		t.winStart = now
+		t.winStartTotal = total
+		t.prevGrowth = growth
+		return growth
+	}
+	return growth + t.prevGrowth*(t.window-elapsed)/t.window
+}
+
+// Consume returns whether an event was signalled since the last call.
+func (t *Trigger) Consume() bool {
+	return t.event.Swap(false)
+}
+
+// EventRegister registers e to be notified of events.
+func (t *Trigger) EventRegister(e *waiter.Entry) {
+	t.queue.EventRegister(e)
+}
+
+// EventUnregister unregisters e.
+func (t *Trigger) EventUnregister(e *waiter.Entry) {
+	t.queue.EventUnregister(e)
+}
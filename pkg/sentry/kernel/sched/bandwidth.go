@@ -0,0 +1,193 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sched
+
+// CPUBandwidth tracks the CPU time used by a group of tasks against the
+// group's CFS bandwidth limit, and against the group's fair share of CPU time
+// while CPUs are contended.
+//
+// All times are in nanoseconds, and must be taken from the same monotonic
+// clock.
+//
+// The zero value of CPUBandwidth is an unlimited group. CPUBandwidth is not
+// thread-safe.
+//
+// +stateify savable
+type CPUBandwidth struct {
+	// limited is true if the group has a bandwidth limit.
+	limited bool
+
+	// quota is the CPU time the group may use in each period.
+	quota int64
+
+	// period is the length of a bandwidth period.
+	period int64
+
+	// periodEnd is the time at which the current period ends. If periodEnd is
+	// 0, no period has started since the limit was set.
+	periodEnd int64
+
+	// used is the CPU time used in the current period.
+	used int64
+
+	// throttled is true if the group has exhausted its quota in the current
+	// period.
+	throttled bool
+
+	// throttledAt is the time at which the group was last throttled.
+	throttledAt int64
+
+	// shareWindowEnd is the end of the share window in which shareUsed and
+	// shareAllowed were accumulated.
+	shareWindowEnd int64
+
+	// shareUsed is the CPU time used by the group in the current share window
+	// while CPUs were contended.
+	shareUsed int64
+
+	// shareAllowed is the CPU time the group was entitled to in the current
+	// share window while CPUs were contended.
+	shareAllowed int64
+
+	// stats are cumulative bandwidth statistics. stats.ThrottledTime
+	// excludes the current throttling interval, if any.
+	stats CPUBandwidthStats
+}
+
+// CPUBandwidthStats are cumulative statistics for a CPUBandwidth, as reported
+// by cpu.stat.
+//
+// +stateify savable
+type CPUBandwidthStats struct {
+	// NrPeriods is the number of periods in which the group was runnable.
+	NrPeriods uint64
+
+	// NrThrottled is the number of periods in which the group exhausted its
+	// quota.
+	NrThrottled uint64
+
+	// ThrottledTime is the total time for which the group was throttled, in
+	// nanoseconds.
+	ThrottledTime int64
+}
+
+// SetLimit sets the group's bandwidth limit to quota in every period. If quota
+// is negative, the group is unlimited. A new period starts the next time the
+// group is charged.
+//
+// Preconditions: period > 0.
+func (b *CPUBandwidth) SetLimit(now, quota, period int64) {
+	b.endThrottle(now)
+	b.limited = quota >= 0
+	b.quota = quota
+	b.period = period
+	b.periodEnd = 0
+	b.used = 0
+}
+
+// Limited returns true if the group has a bandwidth limit.
+func (b *CPUBandwidth) Limited() bool {
+	return b.limited
+}
+
+// Charge charges d of CPU time used by the group at now against its quota. It
+// returns true if the group has exhausted its quota for the current period.
+func (b *CPUBandwidth) Charge(now, d int64) bool {
+	if !b.limited {
+		return false
+	}
+	b.advance(now)
+	b.used += d
+	if b.used >= b.quota && !b.throttled {
+		b.throttled = true
+		b.throttledAt = now
+		b.stats.NrThrottled++
+	}
+	return b.throttled
+}
+
+// ChargeShare charges d of CPU time used by the group at now, while CPUs are
+// contended, against its fair share of CPU time. allowed is the CPU time the
+// group was entitled to over the same interval, and windowEnd is the end of
+// the current share window; a group that uses more than it is entitled to in
+// a share window may not run until the window ends. ChargeShare returns true
+// if the group has exceeded its fair share.
+func (b *CPUBandwidth) ChargeShare(now, d, allowed, windowEnd int64) bool {
+	if b.shareWindowEnd != windowEnd {
+		b.shareWindowEnd = windowEnd
+		b.shareUsed = 0
+		b.shareAllowed = 0
+	}
+	b.shareUsed += d
+	b.shareAllowed += allowed
+	return b.shareUsed > b.shareAllowed
+}
+
+// ThrottledUntil returns the time until which the group may not run, and true,
+// if the group is throttled at now. Otherwise it returns (0, false).
+func (b *CPUBandwidth) ThrottledUntil(now int64) (int64, bool) {
+	var until int64
+	if b.limited {
+		b.advance(now)
+		if b.throttled {
+			until = b.periodEnd
+		}
+	}
+	if now < b.shareWindowEnd && b.shareUsed > b.shareAllowed {
+		until = max(until, b.shareWindowEnd)
+	}
+	return until, until != 0
+}
+
+// Stats returns the group's bandwidth statistics at now.
+func (b *CPUBandwidth) Stats(now int64) CPUBandwidthStats {
+	if b.limited {
+		b.advance(now)
+	}
+	stats := b.stats
+	if b.throttled {
+		stats.ThrottledTime += now - b.throttledAt
+	}
+	return stats
+}
+
+// advance starts a new period if the current one ended before now. Runtime
+// doesn't carry over between periods.
+//
+// Preconditions: b.limited.
+func (b *CPUBandwidth) advance(now int64) {
+	if now < b.periodEnd {
+		return
+	}
+	if b.periodEnd == 0 {
+		b.periodEnd = now + b.period
+	} else {
+		b.endThrottle(b.periodEnd)
+		b.periodEnd += ((now-b.periodEnd)/b.period + 1) * b.period
+	}
+	b.used = 0
+	b.stats.NrPeriods++
+}
+
+// endThrottle unthrottles the group at now, if it is throttled.
+func (b *CPUBandwidth) endThrottle(now int64) {
+	if !b.throttled {
+		return
+	}
+	b.throttled = false
+	if now > b.throttledAt {
+		b.stats.ThrottledTime += now - b.throttledAt
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sched
+
+import (
+	"testing"
+)
+
+const (
+	testTick   = 10
+	testPeriod = 100
+)
+
+func TestBandwidthUnlimited(t *testing.T) {
+	var b CPUBandwidth
+	for now := int64(0); now < 10*testPeriod; now += testTick {
+		if b.Charge(now, testTick) {
+			t.Fatalf("unlimited group throttled at %d", now)
+		}
+	}
+	if _, ok := b.ThrottledUntil(10 * testPeriod); ok {
+		t.Errorf("unlimited group throttled")
+	}
+	if got, want := b.Stats(10*testPeriod), (CPUBandwidthStats{}); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestBandwidthThrottle(t *testing.T) {
+	var b CPUBandwidth
+	b.SetLimit(0, 3*testTick, testPeriod)
+
+	// The first period starts at the first charge, at 0.
+	for now := int64(0); now < 2*testTick; now += testTick {
+		if b.Charge(now, testTick) {
+			t.Fatalf("group throttled at %d, before exhausting its quota", now)
+		}
+	}
+	if !b.Charge(2*testTick, testTick) {
+		t.Fatalf("group not throttled after exhausting its quota")
+	}
+	until, ok := b.ThrottledUntil(3 * testTick)
+	if !ok || until != testPeriod {
+		t.Fatalf("ThrottledUntil got (%d, %t), want (%d, true)", until, ok, testPeriod)
+	}
+	if got := b.Stats(5 * testTick).ThrottledTime; got != 3*testTick {
+		t.Errorf("got throttled time %d during throttling, want %d", got, 3*testTick)
+	}
+
+	// The group is unthrottled when the period ends.
+	if _, ok := b.ThrottledUntil(testPeriod); ok {
+		t.Fatalf("group still throttled in the next period")
+	}
+	want := CPUBandwidthStats{
+		NrPeriods:     2,
+		NrThrottled:   1,
+		ThrottledTime: testPeriod - 2*testTick,
+	}
+	if got := b.Stats(testPeriod); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+
+	// Skipping idle periods doesn't count them.
+	if b.Charge(10*testPeriod+testTick, testTick) {
+		t.Fatalf("group throttled after an idle interval")
+	}
+	if got := b.Stats(10*testPeriod + testTick).NrPeriods; got != 3 {
+		t.Errorf("got %d periods, want 3", got)
+	}
+}
+
+func TestBandwidthSetLimit(t *testing.T) {
+	var b CPUBandwidth
+	b.SetLimit(0, testTick, testPeriod)
+	if !b.Charge(0, testTick) {
+		t.Fatalf("group not throttled after exhausting its quota")
+	}
+	b.SetLimit(2*testTick, -1, testPeriod)
+	if _, ok := b.ThrottledUntil(2 * testTick); ok {
+		t.Fatalf("group throttled after removing its limit")
+	}
+	if got := b.Stats(2 * testTick).ThrottledTime; got != 2*testTick {
+		t.Errorf("got throttled time %d, want %d", got, 2*testTick)
+	}
+}
+
+func TestBandwidthShare(t *testing.T) {
+	var b CPUBandwidth
+	const windowEnd = testPeriod
+	if b.ChargeShare(0, testTick, testTick, windowEnd) {
+		t.Fatalf("group exceeded its share while using exactly its share")
+	}
+	if !b.ChargeShare(testTick, testTick, testTick/2, windowEnd) {
+		t.Fatalf("group didn't exceed its share while using more than its share")
+	}
+	until, ok := b.ThrottledUntil(2 * testTick)
+	if !ok || until != windowEnd {
+		t.Fatalf("ThrottledUntil got (%d, %t), want (%d, true)", until, ok, windowEnd)
+	}
+	if _, ok := b.ThrottledUntil(windowEnd); ok {
+		t.Fatalf("group throttled after its share window ended")
+	}
+	// Share throttling isn't reported as bandwidth throttling.
+	if got, want := b.Stats(windowEnd), (CPUBandwidthStats{}); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+	// A new window resets the share accounting.
+	if b.ChargeShare(windowEnd, testTick, testTick, 2*windowEnd) {
+		t.Errorf("group exceeded its share in a new window")
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/inet"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/futex"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/sched"
 	"gvisor.dev/gvisor/pkg/sentry/ktime"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
@@ -469,6 +470,24 @@ type Task struct {
 	// ipcns is protected by mu. ipcns is owned by the task goroutine.
 	ipcns *IPCNamespace
 
+	// timens is the task's time namespace.
+	//
+	// timens is protected by mu. timens is owned by the task goroutine.
+	timens *TimeNamespace
+
+	// childTimeNamespace is the time namespace of the task's children, if it
+	// differs from timens, as after unshare(CLONE_NEWTIME). Otherwise, it is
+	// nil.
+	//
+	// childTimeNamespace is protected by mu. It is owned by the task
+	// goroutine.
+	childTimeNamespace *TimeNamespace
+
+	// cgroupns is the task's cgroup namespace.
+	//
+	// cgroupns is protected by mu. cgroupns is owned by the task goroutine.
+	cgroupns *CgroupNamespace
+
 	// mountNamespace is the task's mount namespace.
 	//
 	// It is protected by mu. It is owned by the task goroutine.
@@ -618,6 +637,26 @@ type Task struct {
 	// memCgID is the memory cgroup id.
 	memCgID atomicbitops.Uint32
 
+	// cpuGroup is the CPU group of the task's cpu cgroup, or nil if the task
+	// isn't in a cpu cgroup.
+	cpuGroup atomic.Pointer[CPUGroup] `state:".(*CPUGroup)"`
+
+	// pressureGroup is the pressure stall information group of the task's
+	// cgroup on the unified hierarchy, or nil if the task isn't in a cgroup on
+	// it.
+	pressureGroup atomic.Pointer[psi.Group] `state:".(*psi.Group)"`
+
+	// The following fields indicate the stalls the task is in, for pressure
+	// stall information. See pressure.go. They're set by the task goroutine.
+	//
+	// allocStart is the gohacks.Nanotime at which the task's memory
+	// allocation in progress started, or 0 if there is none. cpuThrottled is
+	// true while the task waits for its cpu cgroup to be unthrottled, and
+	// oomWaiting while it waits for a memory cgroup OOM to be resolved.
+	allocStart   atomicbitops.Int64 `state:"nosave"`
+	cpuThrottled atomicbitops.Bool  `state:"nosave"`
+	oomWaiting   atomicbitops.Bool  `state:"nosave"`
+
 	// userCounters is a pointer to a set of user counters.
 	//
 	// The userCounters pointer is exclusive to the task goroutine, but the
@@ -180,6 +180,44 @@ func (tg *ThreadGroup) IOUsage() *usage.IO {
 	return &io
 }
 
+// ContainerIOUsage returns the total io usage of all live and exited thread
+// groups in the container with the given ID.
+func (ts *TaskSet) ContainerIOUsage(cid string) *usage.IO {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	var io usage.IO
+	if exited := ts.exitedIOUsage[cid]; exited != nil {
+		exited.Clone(&io)
+	}
+	for tg := range ts.Root.tgids {
+		if tg.leader == nil || tg.leader.containerID != cid {
+			continue
+		}
+		io.Accumulate(tg.ioUsage)
+		for t := tg.tasks.Front(); t != nil; t = t.Next() {
+			io.Accumulate(t.IOUsage())
+		}
+	}
+	return &io
+}
+
+// accountExitedIOUsageLocked adds the io usage of an exited thread group in
+// the container with the given ID to the container's total.
+//
+// Preconditions: ts.mu must be locked for writing.
+func (ts *TaskSet) accountExitedIOUsageLocked(cid string, io *usage.IO) {
+	if ts.exitedIOUsage == nil {
+		ts.exitedIOUsage = make(map[string]*usage.IO)
+	}
+	exited := ts.exitedIOUsage[cid]
+	if exited == nil {
+		exited = &usage.IO{}
+		ts.exitedIOUsage[cid] = exited
+	}
+	exited.Accumulate(io)
+}
+
 // Name returns t's name.
 func (t *Task) Name() string {
 	t.mu.Lock()
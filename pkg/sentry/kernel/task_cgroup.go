@@ -55,6 +55,12 @@ func (t *Task) EnterInitialCgroups(parent *Task, initCgroups map[Cgroup]struct{}
 	}
 }
 
+// MemCgID returns the id of the task's memory cgroup, or zero if the task
+// isn't in a memory cgroup.
+func (t *Task) MemCgID() uint32 {
+	return t.memCgID.Load()
+}
+
 // SetMemCgID sets the given memory cgroup id to the task.
 func (t *Task) SetMemCgID(memCgID uint32) {
 	t.memCgID.Store(memCgID)
@@ -223,8 +229,14 @@ type TaskCgroupEntry struct {
 }
 
 // GetCgroupEntries generates the contents of /proc/<pid>/cgroup as
-// a TaskCgroupEntry array.
+// a TaskCgroupEntry array, as seen from the root cgroup namespace.
 func (t *Task) GetCgroupEntries() []TaskCgroupEntry {
+	return t.cgroupEntries(t.k.rootCgroupNamespace)
+}
+
+// cgroupEntries generates the contents of /proc/<pid>/cgroup as a
+// TaskCgroupEntry array, with cgroup paths relative to the roots of ns.
+func (t *Task) cgroupEntries(ns *CgroupNamespace) []TaskCgroupEntry {
 	// Gather cgroups with t.mu held.
 	t.mu.Lock()
 	cgroups := make([]Cgroup, 0, len(t.cgroups))
@@ -235,8 +247,15 @@ func (t *Task) GetCgroupEntries() []TaskCgroupEntry {
 
 	// Don't hold t.mu here as that can lead to lock inversion with
 	// kernfs.ancestryRWMutex when calculating cgroup paths.
+	r := t.k.CgroupRegistry()
 	cgEntries := make([]TaskCgroupEntry, 0, len(cgroups))
 	for _, c := range cgroups {
+		if r.isV2Hierarchy(c.HierarchyID()) {
+			// The unified hierarchy is always displayed as "0::<path>".
+			cgEntries = append(cgEntries, TaskCgroupEntry{Path: ns.Path(c)})
+			continue
+		}
+
 		ctls := c.Controllers()
 		ctlNames := make([]string, 0, len(ctls))
 
@@ -256,7 +275,7 @@ func (t *Task) GetCgroupEntries() []TaskCgroupEntry {
 		cgEntries = append(cgEntries, TaskCgroupEntry{
 			HierarchyID: c.HierarchyID(),
 			Controllers: strings.Join(ctlNames, ","),
-			Path:        c.Path(),
+			Path:        ns.Path(c),
 		})
 	}
 
@@ -264,9 +283,10 @@ func (t *Task) GetCgroupEntries() []TaskCgroupEntry {
 	return cgEntries
 }
 
-// GenerateProcTaskCgroup writes the contents of /proc/<pid>/cgroup for t to buf.
-func (t *Task) GenerateProcTaskCgroup(buf *bytes.Buffer) {
-	cgEntries := t.GetCgroupEntries()
+// GenerateProcTaskCgroup writes the contents of /proc/<pid>/cgroup for t to
+// buf, as read by a task in the cgroup namespace ns.
+func (t *Task) GenerateProcTaskCgroup(buf *bytes.Buffer, ns *CgroupNamespace) {
+	cgEntries := t.cgroupEntries(ns)
 	for _, cgE := range cgEntries {
 		fmt.Fprintf(buf, "%d:%s:%s\n", cgE.HierarchyID, cgE.Controllers, cgE.Path)
 	}
@@ -294,3 +314,16 @@ func (t *Task) ChargeFor(other *Task, ctl CgroupControllerType, res CgroupResour
 	defer t.mu.Unlock()
 	return t.chargeLocked(other, ctl, res, value)
 }
+
+// CgroupForHierarchy returns the cgroup t belongs to on the hierarchy with the
+// given ID, if any. The returned cgroup doesn't hold an extra reference.
+func (t *Task) CgroupForHierarchy(hid uint32) (Cgroup, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for c := range t.cgroups {
+		if c.HierarchyID() == hid {
+			return c, true
+		}
+	}
+	return Cgroup{}, false
+}
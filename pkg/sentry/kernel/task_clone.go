@@ -37,7 +37,8 @@ const SupportedCloneFlags = linux.CLONE_VM | linux.CLONE_FS | linux.CLONE_FILES
 	linux.CLONE_CHILD_CLEARTID | linux.CLONE_CHILD_SETTID | linux.CLONE_PARENT |
 	linux.CLONE_PARENT_SETTID | linux.CLONE_SETTLS | linux.CLONE_NEWUSER | linux.CLONE_NEWUTS |
 	linux.CLONE_NEWIPC | linux.CLONE_NEWNET | linux.CLONE_PTRACE | linux.CLONE_UNTRACED |
-	linux.CLONE_IO | linux.CLONE_VFORK | linux.CLONE_DETACHED | linux.CLONE_NEWNS
+	linux.CLONE_IO | linux.CLONE_VFORK | linux.CLONE_DETACHED | linux.CLONE_NEWNS | linux.CLONE_NEWTIME |
+	linux.CLONE_NEWCGROUP
 
 // Clone implements the clone(2) syscall and returns the thread ID of the new
 // task in t's PID namespace. Clone may return both a non-zero thread ID and a
@@ -83,6 +84,12 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	if args.Flags&(linux.CLONE_FS|linux.CLONE_NEWNS) == linux.CLONE_FS|linux.CLONE_NEWNS {
 		return 0, nil, linuxerr.EINVAL
 	}
+	// A task that shares its address space must stay in the time namespace
+	// of that address space's VDSO parameter page. See
+	// kernel/fork.c:copy_process().
+	if args.Flags&(linux.CLONE_THREAD|linux.CLONE_VM) != 0 && t.childTimeNamespace != nil {
+		return 0, nil, linuxerr.EINVAL
+	}
 
 	// Pull task registers and FPU state, a cloned task will inherit the
 	// state of the current task.
@@ -115,7 +122,7 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 			return 0, nil, err
 		}
 	}
-	if args.Flags&(linux.CLONE_NEWPID|linux.CLONE_NEWNET|linux.CLONE_NEWUTS|linux.CLONE_NEWIPC) != 0 && !creds.HasCapabilityIn(linux.CAP_SYS_ADMIN, userns) {
+	if args.Flags&(linux.CLONE_NEWPID|linux.CLONE_NEWNET|linux.CLONE_NEWUTS|linux.CLONE_NEWIPC|linux.CLONE_NEWTIME|linux.CLONE_NEWCGROUP) != 0 && !creds.HasCapabilityIn(linux.CAP_SYS_ADMIN, userns) {
 		return 0, nil, linuxerr.EPERM
 	}
 
@@ -159,6 +166,48 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 		netns.DecRef(t)
 	})
 
+	// The new cgroup namespace is rooted at t's cgroups, which the child
+	// inherits.
+	var cgroupns *CgroupNamespace
+	if args.Flags&linux.CLONE_NEWCGROUP != 0 {
+		cgroupns = t.newCgroupNamespace(userns)
+		cgroupns.SetInode(nsfs.NewInode(t, t.k.nsfsMount, cgroupns))
+	} else {
+		cgroupns = t.GetCgroupNamespace()
+	}
+	cu.Add(func() {
+		cgroupns.DecRef(t)
+	})
+
+	// A new time namespace only becomes the time namespace for the child's
+	// children; the child itself enters the time namespace for children
+	// unless it shares t's address space. See
+	// kernel/nsproxy.c:copy_namespaces().
+	timens := t.timens
+	childTimens := t.childTimeNamespace
+	if args.Flags&linux.CLONE_NEWTIME != 0 {
+		parentTimens := childTimens
+		if parentTimens == nil {
+			parentTimens = timens
+		}
+		childTimens = parentTimens.Clone(userns)
+		childTimens.SetInode(nsfs.NewInode(t, t.k.nsfsMount, childTimens))
+	} else if childTimens != nil {
+		childTimens.IncRef()
+	}
+	if args.Flags&linux.CLONE_VM == 0 && childTimens != nil {
+		// Transfer the reference on childTimens to timens.
+		timens, childTimens = childTimens, nil
+	} else {
+		timens.IncRef()
+	}
+	cu.Add(func() {
+		timens.DecRef(t)
+		if childTimens != nil {
+			childTimens.DecRef(t)
+		}
+	})
+
 	// We must hold t.mu to access t.image, but we can't hold it during Fork(),
 	// since TaskImage.Fork()=>mm.Fork() takes mm.addressSpaceMu, which is ordered
 	// above Task.mu. So we copy t.image with t.mu held and call Fork() on the copy.
@@ -173,6 +222,11 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	cu.Add(func() {
 		image.release(t)
 	})
+	if timens != t.timens {
+		if err := t.k.enterTimeNamespace(t, timens, image.MemoryManager); err != nil {
+			return 0, nil, err
+		}
+	}
 
 	if args.Flags&linux.CLONE_NEWUSER != 0 {
 		// If the task is in a new user namespace, it cannot share keys.
@@ -248,25 +302,28 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	}
 
 	cfg := &TaskConfig{
-		Kernel:           t.k,
-		ThreadGroup:      tg,
-		SignalMask:       t.SignalMask(),
-		TaskImage:        image,
-		FSContext:        fsContext,
-		FDTable:          fdTable,
-		Credentials:      creds,
-		Niceness:         t.Niceness(),
-		NetworkNamespace: netns,
-		AllowedCPUMask:   t.CPUMask(),
-		UTSNamespace:     utsns,
-		IPCNamespace:     ipcns,
-		MountNamespace:   mntns,
-		RSeqAddr:         rseqAddr,
-		RSeqSignature:    rseqSignature,
-		ContainerID:      t.ContainerID(),
-		UserCounters:     uc,
-		SessionKeyring:   sessionKeyring,
-		Origin:           t.Origin,
+		Kernel:             t.k,
+		ThreadGroup:        tg,
+		SignalMask:         t.SignalMask(),
+		TaskImage:          image,
+		FSContext:          fsContext,
+		FDTable:            fdTable,
+		Credentials:        creds,
+		Niceness:           t.Niceness(),
+		NetworkNamespace:   netns,
+		AllowedCPUMask:     t.CPUMask(),
+		UTSNamespace:       utsns,
+		IPCNamespace:       ipcns,
+		TimeNamespace:      timens,
+		ChildTimeNamespace: childTimens,
+		CgroupNamespace:    cgroupns,
+		MountNamespace:     mntns,
+		RSeqAddr:           rseqAddr,
+		RSeqSignature:      rseqSignature,
+		ContainerID:        t.ContainerID(),
+		UserCounters:       uc,
+		SessionKeyring:     sessionKeyring,
+		Origin:             t.Origin,
 	}
 	if args.Flags&linux.CLONE_THREAD == 0 {
 		cfg.Parent = t
@@ -551,6 +608,54 @@ func (t *Task) Setns(fd *vfs.FileDescription, flags int32) error {
 		t.mu.Unlock()
 		oldNS.DecRef(t)
 		return nil
+	case *TimeNamespace:
+		if flags != 0 && flags != linux.CLONE_NEWTIME {
+			return linuxerr.EINVAL
+		}
+		// The caller's address space, whose VDSO parameter page is switched
+		// below, must not be shared. See
+		// kernel/time/namespace.c:timens_install().
+		t.tg.signalHandlers.mu.Lock()
+		singleThreaded := t.tg.tasksCount == 1
+		t.tg.signalHandlers.mu.Unlock()
+		if !singleThreaded || t.MemoryManager().Users() != 1 {
+			return linuxerr.EUSERS
+		}
+		if !t.HasCapabilityIn(linux.CAP_SYS_ADMIN, ns.UserNamespace()) ||
+			!t.Credentials().HasCapability(linux.CAP_SYS_ADMIN) {
+			return linuxerr.EPERM
+		}
+		if err := t.k.enterTimeNamespace(t, ns, t.MemoryManager()); err != nil {
+			return err
+		}
+
+		ns.IncRef()
+		t.mu.Lock()
+		oldNS := t.timens
+		oldChildNS := t.childTimeNamespace
+		t.timens = ns
+		t.childTimeNamespace = nil
+		t.mu.Unlock()
+		oldNS.DecRef(t)
+		if oldChildNS != nil {
+			oldChildNS.DecRef(t)
+		}
+		return nil
+	case *CgroupNamespace:
+		if flags != 0 && flags != linux.CLONE_NEWCGROUP {
+			return linuxerr.EINVAL
+		}
+		if !t.HasCapabilityIn(linux.CAP_SYS_ADMIN, ns.UserNamespace()) ||
+			!t.Credentials().HasCapability(linux.CAP_SYS_ADMIN) {
+			return linuxerr.EPERM
+		}
+		oldNS := t.CgroupNamespace()
+		ns.IncRef()
+		t.mu.Lock()
+		t.cgroupns = ns
+		t.mu.Unlock()
+		oldNS.DecRef(t)
+		return nil
 	case *PIDNamespace:
 		if flags != 0 && flags != linux.CLONE_NEWPID {
 			return linuxerr.EINVAL
@@ -661,6 +766,18 @@ func (t *Task) Unshare(flags int32) error {
 		t.mu.Unlock()
 		oldNetns.DecRef(t)
 	}
+	if flags&linux.CLONE_NEWCGROUP != 0 {
+		if !haveCapSysAdmin {
+			return linuxerr.EPERM
+		}
+		cgroupns := t.newCgroupNamespace(t.UserNamespace())
+		cgroupns.SetInode(nsfs.NewInode(t, t.k.nsfsMount, cgroupns))
+		t.mu.Lock()
+		oldCgroupns := t.cgroupns
+		t.cgroupns = cgroupns
+		t.mu.Unlock()
+		oldCgroupns.DecRef(t)
+	}
 
 	cu := cleanup.Cleanup{}
 	// All cu actions has to be executed after releasing t.mu.
@@ -691,6 +808,19 @@ func (t *Task) Unshare(flags int32) error {
 		t.ipcns.SetInode(nsfs.NewInode(t, t.k.nsfsMount, t.ipcns))
 		cu.Add(func() { oldIPCNS.DecRef(t) })
 	}
+	if flags&linux.CLONE_NEWTIME != 0 {
+		if !haveCapSysAdmin {
+			return linuxerr.EPERM
+		}
+		// The caller stays in its time namespace; only its future children
+		// enter the new one.
+		oldChildTimeNS := t.childTimeNamespace
+		t.childTimeNamespace = t.childTimeNamespaceLocked().Clone(creds.UserNamespace)
+		t.childTimeNamespace.SetInode(nsfs.NewInode(t, t.k.nsfsMount, t.childTimeNamespace))
+		if oldChildTimeNS != nil {
+			cu.Add(func() { oldChildTimeNS.DecRef(t) })
+		}
+	}
 	if flags&linux.CLONE_FILES != 0 {
 		oldFDTable := t.fdTable
 		t.fdTable = oldFDTable.Fork(t, MaxFdLimit)
@@ -120,6 +120,12 @@ func (t *Task) contextValue(key any, isTaskGoroutine bool) any {
 		}
 	case pgalloc.CtxMemoryCgroupID:
 		return t.memCgID.Load()
+	case pgalloc.CtxMemoryStallTracker:
+		if !isTaskGoroutine {
+			// Only allocations by the task goroutine stall the task.
+			return nil
+		}
+		return t
 	case pgalloc.CtxMemoryFile:
 		return t.k.mf
 	case platform.CtxPlatform:
@@ -251,7 +251,17 @@ func (r *runSyscallAfterExecStop) execute(t *Task) taskRunState {
 	t.mu.Lock()
 	oldImage := t.image
 	t.image = *r.image
+	// Enter the time namespace for children, whose VDSO parameter page was
+	// mapped by Kernel.LoadTaskImage.
+	oldTimeNS := t.childTimeNamespace
+	if oldTimeNS != nil {
+		oldTimeNS, t.timens = t.timens, t.childTimeNamespace
+		t.childTimeNamespace = nil
+	}
 	t.mu.Unlock()
+	if oldTimeNS != nil {
+		oldTimeNS.DecRef(t)
+	}
 
 	// Don't hold t.mu while calling t.image.release(), that may
 	// attempt to acquire TaskImage.MemoryManager.mappingMu, a lock order
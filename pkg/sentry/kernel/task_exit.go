@@ -311,6 +311,12 @@ func (*runExitMain) execute(t *Task) taskRunState {
 	t.utsns = nil
 	ipcns := t.ipcns
 	t.ipcns = nil
+	timens := t.timens
+	t.timens = nil
+	childTimeNS := t.childTimeNamespace
+	t.childTimeNamespace = nil
+	cgroupns := t.cgroupns
+	t.cgroupns = nil
 	netns := t.netns
 	t.netns = nil
 	childPIDNS := t.childPIDNamespace
@@ -319,6 +325,11 @@ func (*runExitMain) execute(t *Task) taskRunState {
 	mntns.DecRef(t)
 	utsns.DecRef(t)
 	ipcns.DecRef(t)
+	timens.DecRef(t)
+	if childTimeNS != nil {
+		childTimeNS.DecRef(t)
+	}
+	cgroupns.DecRef(t)
 	netns.DecRef(t)
 	if childPIDNS != nil {
 		childPIDNS.DecRef(t)
@@ -764,6 +775,7 @@ func (t *Task) exitNotifyLocked(fromPtraceDetach bool) {
 			t.tg.leader.exitNotifyLocked(false)
 		} else if tc == 0 {
 			t.tg.pidWithinNS.Store(0)
+			t.tg.pidns.owner.accountExitedIOUsageLocked(t.containerID, t.tg.ioUsage)
 			t.tg.processGroup.decRefWithParent(t.tg.parentPG())
 		}
 		if t.parent != nil {
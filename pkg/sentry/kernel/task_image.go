@@ -150,6 +150,21 @@ func (k *Kernel) LoadTaskImage(ctx context.Context, args loader.LoadArgs) (*Task
 	defer m.DecUsers(ctx)
 	args.MemoryManager = m
 
+	// A task executing a new image enters its time namespace for children,
+	// whose VDSO parameter page must be mapped instead of the kernel's. See
+	// fs/exec.c:begin_new_exec() => kernel/nsproxy.c:exec_task_namespaces().
+	if t := TaskFromContext(ctx); t != nil && args.VVAR == nil {
+		t.mu.Lock()
+		timens := t.childTimeNamespaceLocked()
+		t.mu.Unlock()
+		if timens != nil {
+			if err := timens.freeze(k); err != nil {
+				return nil, syserr.FromError(err)
+			}
+			args.VVAR = k.vvarFor(timens)
+		}
+	}
+
 	info, err := loader.Load(ctx, args, k.extraAuxv, k.vdso)
 	if err != nil {
 		return nil, err
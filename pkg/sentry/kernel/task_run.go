@@ -27,6 +27,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/hostcpu"
 	"gvisor.dev/gvisor/pkg/sentry/ktime"
 	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
 )
 
@@ -171,6 +172,11 @@ func (app *runApp) execute(t *Task) taskRunState {
 		}
 	}
 
+	// Don't return to the application while t's cpu cgroup is throttled.
+	if t.k.cpuLimitsEnabled() && !t.waitCPUThrottle() {
+		return (*runInterrupt)(nil)
+	}
+
 	// We're about to switch to the application again. If there's still an
 	// unhandled SyscallRestartErrno that wasn't translated to an EINTR,
 	// restart the syscall that was interrupted. If there's a saved signal
@@ -295,6 +301,24 @@ func (app *runApp) execute(t *Task) taskRunState {
 				return (*runApp)(nil)
 			}
 
+			// If the task was interrupted while waiting for a userfaultfd
+			// to resolve the fault, handle the interruption and then
+			// retry the fault.
+			if err == linuxerr.ErrInterrupted {
+				return (*runApp)(nil)
+			}
+
+			// If the fault couldn't be handled because the task's memory
+			// cgroup is over its limit, wait for the cgroup's OOM handling
+			// to reclaim memory or kill a task, then retry the fault.
+			if oomErr, ok := err.(*pgalloc.MemoryCgroupLimitError); ok {
+				if t.waitMemoryCgroupOOM(oomErr) {
+					return (*runApp)(nil)
+				}
+				sig = linux.SIGBUS
+				info.Signo = int32(linux.SIGBUS)
+			}
+
 			// Is this a vsyscall that we need emulate?
 			//
 			// Note that we don't track vsyscalls as part of a
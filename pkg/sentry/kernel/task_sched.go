@@ -24,6 +24,7 @@ import (
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/sentry/hostcpu"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/psi"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/sched"
 	"gvisor.dev/gvisor/pkg/sentry/ktime"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
@@ -196,11 +197,20 @@ func (k *Kernel) runCPUClockTicker() {
 	var (
 		allTasks []*Task
 		incTasks = make([]*Task, k.applicationCores)
+		shares   = make(map[*CPUGroup]*cpuShare)
+		pressure = make(map[*psi.Group]*psi.TaskCounts)
 	)
 
 	for {
 		// Stop CPU clocks while nothing is running.
 		if k.runningTasks.Load() == 0 {
+			// Task states can't change until a task runs again, so the
+			// pressure sampled now persists while the ticker is stopped.
+			allTasks = k.tasks.Root.TasksAppend(allTasks)
+			k.samplePressure(allTasks, nil, pressure)
+			clear(allTasks)
+			allTasks = allTasks[:0]
+
 			k.runningTasksMu.Lock()
 			if k.runningTasks.Load() == 0 {
 				k.cpuClockTickerRunning = false
@@ -210,6 +220,7 @@ func (k *Kernel) runCPUClockTicker() {
 				// (Kernel.incRunningTasks()). For reasons described there, we must
 				// process at least one CPU clock tick between calls to
 				// k.runningTasksCond.Wait().
+				k.flushPressure(pressure)
 			}
 			k.runningTasksMu.Unlock()
 		}
@@ -280,6 +291,13 @@ func (k *Kernel) runCPUClockTicker() {
 			}
 		}
 
+		// Enforce cpu cgroup bandwidth limits and weights.
+		if k.cpuLimitsEnabled() {
+			k.chargeCPUGroups(allTasks, incTasks[:numIncTasks], runningTasks, shares)
+		}
+
+		k.samplePressure(allTasks, incTasks[:numIncTasks], pressure)
+
 		// Reset storage for the next iteration.
 		clear(allTasks)
 		allTasks = allTasks[:0]
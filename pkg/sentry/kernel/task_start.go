@@ -81,6 +81,24 @@ type TaskConfig struct {
 	// IPCNamespace is the IPCNamespace of the new task.
 	IPCNamespace *IPCNamespace
 
+	// TimeNamespace is the TimeNamespace of the new task. If it is nil, the
+	// kernel's root time namespace is used. Otherwise, a reference must be
+	// held on it, which is transferred to TaskSet.NewTask whether or not it
+	// succeeds.
+	TimeNamespace *TimeNamespace
+
+	// ChildTimeNamespace is the time namespace of the new task's children, if
+	// it differs from TimeNamespace. If it is not nil, a reference must be
+	// held on it, which is transferred to TaskSet.NewTask whether or not it
+	// succeeds.
+	ChildTimeNamespace *TimeNamespace
+
+	// CgroupNamespace is the CgroupNamespace of the new task. If it is nil,
+	// the kernel's root cgroup namespace is used. Otherwise, a reference must
+	// be held on it, which is transferred to TaskSet.NewTask whether or not it
+	// succeeds.
+	CgroupNamespace *CgroupNamespace
+
 	// MountNamespace is the MountNamespace of the new task.
 	MountNamespace *vfs.MountNamespace
 
@@ -115,6 +133,14 @@ type TaskConfig struct {
 // Otherwise, NewTask releases them.
 func (ts *TaskSet) NewTask(ctx context.Context, cfg *TaskConfig) (*Task, error) {
 	var err error
+	if cfg.TimeNamespace == nil {
+		cfg.TimeNamespace = cfg.Kernel.rootTimeNamespace
+		cfg.TimeNamespace.IncRef()
+	}
+	if cfg.CgroupNamespace == nil {
+		cfg.CgroupNamespace = cfg.Kernel.rootCgroupNamespace
+		cfg.CgroupNamespace.IncRef()
+	}
 	cleanup := func() {
 		cfg.TaskImage.release(ctx)
 		cfg.FSContext.DecRef(ctx)
@@ -122,6 +148,11 @@ func (ts *TaskSet) NewTask(ctx context.Context, cfg *TaskConfig) (*Task, error)
 		cfg.UTSNamespace.DecRef(ctx)
 		cfg.IPCNamespace.DecRef(ctx)
 		cfg.NetworkNamespace.DecRef(ctx)
+		cfg.TimeNamespace.DecRef(ctx)
+		if cfg.ChildTimeNamespace != nil {
+			cfg.ChildTimeNamespace.DecRef(ctx)
+		}
+		cfg.CgroupNamespace.DecRef(ctx)
 		if cfg.MountNamespace != nil {
 			cfg.MountNamespace.DecRef(ctx)
 		}
@@ -151,31 +182,34 @@ func (ts *TaskSet) newTask(ctx context.Context, cfg *TaskConfig) (*Task, error)
 			parent:   cfg.Parent,
 			children: make(map[*Task]struct{}),
 		},
-		runState:        (*runApp)(nil),
-		interruptChan:   make(chan struct{}, 1),
-		signalMask:      atomicbitops.FromUint64(uint64(cfg.SignalMask)),
-		signalStack:     linux.SignalStack{Flags: linux.SS_DISABLE},
-		image:           *image,
-		fsContext:       cfg.FSContext,
-		fdTable:         cfg.FDTable,
-		k:               cfg.Kernel,
-		ptraceTracees:   make(map[*Task]struct{}),
-		allowedCPUMask:  cfg.AllowedCPUMask.Copy(),
-		ioUsage:         &usage.IO{},
-		niceness:        cfg.Niceness,
-		utsns:           cfg.UTSNamespace,
-		ipcns:           cfg.IPCNamespace,
-		mountNamespace:  cfg.MountNamespace,
-		rseqCPU:         -1,
-		rseqAddr:        cfg.RSeqAddr,
-		rseqSignature:   cfg.RSeqSignature,
-		futexWaiter:     futex.NewWaiter(),
-		containerID:     cfg.ContainerID,
-		cgroups:         make(map[Cgroup]struct{}),
-		userCounters:    cfg.UserCounters,
-		sessionKeyring:  cfg.SessionKeyring,
-		Origin:          cfg.Origin,
-		onDestroyAction: make(map[TaskDestroyAction]struct{}),
+		runState:           (*runApp)(nil),
+		interruptChan:      make(chan struct{}, 1),
+		signalMask:         atomicbitops.FromUint64(uint64(cfg.SignalMask)),
+		signalStack:        linux.SignalStack{Flags: linux.SS_DISABLE},
+		image:              *image,
+		fsContext:          cfg.FSContext,
+		fdTable:            cfg.FDTable,
+		k:                  cfg.Kernel,
+		ptraceTracees:      make(map[*Task]struct{}),
+		allowedCPUMask:     cfg.AllowedCPUMask.Copy(),
+		ioUsage:            &usage.IO{},
+		niceness:           cfg.Niceness,
+		utsns:              cfg.UTSNamespace,
+		ipcns:              cfg.IPCNamespace,
+		timens:             cfg.TimeNamespace,
+		childTimeNamespace: cfg.ChildTimeNamespace,
+		cgroupns:           cfg.CgroupNamespace,
+		mountNamespace:     cfg.MountNamespace,
+		rseqCPU:            -1,
+		rseqAddr:           cfg.RSeqAddr,
+		rseqSignature:      cfg.RSeqSignature,
+		futexWaiter:        futex.NewWaiter(),
+		containerID:        cfg.ContainerID,
+		cgroups:            make(map[Cgroup]struct{}),
+		userCounters:       cfg.UserCounters,
+		sessionKeyring:     cfg.SessionKeyring,
+		Origin:             cfg.Origin,
+		onDestroyAction:    make(map[TaskDestroyAction]struct{}),
 	}
 	t.netns = cfg.NetworkNamespace
 	t.creds.Store(cfg.Credentials)
@@ -29,6 +29,7 @@ import (
 	"gvisor.dev/gvisor/pkg/metric"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
 	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
 	"gvisor.dev/gvisor/pkg/sentry/seccheck"
 	pb "gvisor.dev/gvisor/pkg/sentry/seccheck/points/points_go_proto"
@@ -322,6 +323,14 @@ func (*runSyscallAfterSysemuStop) execute(t *Task) taskRunState {
 func (t *Task) doSyscallInvoke(sysno uintptr, args arch.SyscallArguments) taskRunState {
 	rval, ctrl, err := t.executeSyscall(sysno, args)
 
+	// If an allocation failed because the task's memory cgroup is over its
+	// limit, wait for the cgroup's OOM handling to reclaim memory or kill a
+	// task, then restart the syscall, as Linux's mm/memcontrol.c:try_charge()
+	// retries the charge after invoking the OOM killer.
+	if oomErr, ok := err.(*pgalloc.MemoryCgroupLimitError); ok && ctrl == nil && t.waitMemoryCgroupOOM(oomErr) {
+		err = linuxerr.ERESTARTNOINTR
+	}
+
 	if ctrl != nil {
 		if !ctrl.ignoreReturn {
 			t.Arch().SetReturn(rval)
@@ -472,6 +481,11 @@ func ExtractErrno(err error, sysno int) int {
 		// return EFAULT. See case in task_run.go where the fault is
 		// handled (and the SIGBUS is delivered).
 		return int(unix.EFAULT)
+	case *pgalloc.MemoryCgroupLimitError:
+		// Allocations that exceed a memory cgroup limit, and can't be
+		// retried after the OOM has been handled, fail with ENOMEM, as for
+		// Linux's mm/memcontrol.c:try_charge().
+		return int(unix.ENOMEM)
 	case memmap.BufferedIOFallbackErr:
 		// This situation has no equivalent in Linux. Return ENODEV for
 		// consistency with memmap.NoMapInternal.MapInternal().
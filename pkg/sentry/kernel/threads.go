@@ -21,6 +21,7 @@ import (
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/usage"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/waiter"
 )
@@ -104,6 +105,10 @@ type TaskSet struct {
 	// aioGoroutines is not saved but is required to be zero at the time of
 	// save.
 	aioGoroutines sync.WaitGroup `state:"nosave"`
+
+	// exitedIOUsage maps container IDs to the total I/O usage of thread groups
+	// in the container that have exited. exitedIOUsage is protected by mu.
+	exitedIOUsage map[string]*usage.IO
 }
 
 // newTaskSet returns a new, empty TaskSet.
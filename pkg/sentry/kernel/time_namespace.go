@@ -0,0 +1,300 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"math"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/nsfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/ktime"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+	sentrytime "gvisor.dev/gvisor/pkg/sentry/time"
+	"gvisor.dev/gvisor/pkg/sentry/usage"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// maxTimeNamespaceSec is the maximum value of a clock in a time namespace, in
+// seconds. It is half of KTIME_SEC_MAX, so that KTIME_MAX remains
+// unreachable; see kernel/time/namespace.c:proc_timens_set_offset().
+const maxTimeNamespaceSec = math.MaxInt64 / 1000000000 / 2
+
+// TimeNamespace represents a time namespace, which offsets CLOCK_MONOTONIC and
+// CLOCK_BOOTTIME for the tasks in it. See time_namespaces(7).
+//
+// +stateify savable
+type TimeNamespace struct {
+	// userns is the user namespace owning the TimeNamespace. Privileged
+	// operations on this TimeNamespace must have appropriate capabilities in
+	// userns. It is immutable.
+	userns *auth.UserNamespace
+
+	mu sync.Mutex `state:"nosave"`
+
+	// monotonicOffset and boottimeOffset are the offsets, in nanoseconds,
+	// of CLOCK_MONOTONIC and CLOCK_BOOTTIME in the namespace.
+	//
+	// +checklocks:mu
+	monotonicOffset int64
+	// +checklocks:mu
+	boottimeOffset int64
+
+	// frozen is true once a task has entered the namespace, after which the
+	// offsets may no longer be changed.
+	//
+	// +checklocks:mu
+	frozen bool
+
+	// The following fields are set by freeze, before any task enters the
+	// namespace, and are immutable afterward.
+
+	// monotonicClock and boottimeClock are the namespace's CLOCK_MONOTONIC
+	// and CLOCK_BOOTTIME.
+	monotonicClock *timekeeperClock
+	boottimeClock  *timekeeperClock
+
+	// If the namespace has non-zero offsets, vvar is the VDSO parameter page
+	// mapped by tasks in the namespace, and params manages it. Otherwise,
+	// both are nil and tasks map the kernel's parameter page.
+	vvar   *mm.SpecialMappable
+	params *VDSOParamPage
+
+	inode *nsfs.Inode
+}
+
+// newRootTimeNamespace returns the initial time namespace, which has no
+// offsets.
+func newRootTimeNamespace(tk *Timekeeper, userns *auth.UserNamespace) *TimeNamespace {
+	return &TimeNamespace{
+		userns:         userns,
+		frozen:         true,
+		monotonicClock: tk.monotonicClock,
+		boottimeClock:  tk.monotonicClock,
+	}
+}
+
+// TimeNamespace returns the task's time namespace.
+func (t *Task) TimeNamespace() *TimeNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timens
+}
+
+// GetTimeNamespace takes a reference on the task's time namespace and returns
+// it. It will return nil if the task isn't alive.
+func (t *Task) GetTimeNamespace() *TimeNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timens != nil {
+		t.timens.IncRef()
+	}
+	return t.timens
+}
+
+// GetChildTimeNamespace takes a reference on the time namespace that will be
+// used for the task's children and returns it. It will return nil if the task
+// isn't alive.
+func (t *Task) GetChildTimeNamespace() *TimeNamespace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ns := t.childTimeNamespaceLocked()
+	if ns != nil {
+		ns.IncRef()
+	}
+	return ns
+}
+
+// childTimeNamespaceLocked returns the time namespace that will be used for
+// the task's children.
+//
+// Preconditions: t.mu must be locked.
+func (t *Task) childTimeNamespaceLocked() *TimeNamespace {
+	if t.childTimeNamespace != nil {
+		return t.childTimeNamespace
+	}
+	return t.timens
+}
+
+// Clone returns a new, unfrozen time namespace owned by userns, with the same
+// offsets as ns.
+func (ns *TimeNamespace) Clone(userns *auth.UserNamespace) *TimeNamespace {
+	monotonic, boottime := ns.Offsets()
+	return &TimeNamespace{
+		userns:          userns,
+		monotonicOffset: monotonic,
+		boottimeOffset:  boottime,
+	}
+}
+
+// UserNamespace returns the user namespace owning ns.
+func (ns *TimeNamespace) UserNamespace() *auth.UserNamespace {
+	return ns.userns
+}
+
+// Offsets returns the CLOCK_MONOTONIC and CLOCK_BOOTTIME offsets of ns, in
+// nanoseconds.
+func (ns *TimeNamespace) Offsets() (monotonic, boottime int64) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.monotonicOffset, ns.boottimeOffset
+}
+
+// SetOffsets sets the offsets of ns, in nanoseconds, for the clocks in
+// offsets, which may only be linux.CLOCK_MONOTONIC and linux.CLOCK_BOOTTIME.
+// Offsets for other clocks are left unchanged.
+//
+// It returns EACCES if a task has already entered ns, and ERANGE if an offset
+// would make its clock negative or too large.
+func (ns *TimeNamespace) SetOffsets(tk *Timekeeper, offsets map[int32]int64) error {
+	now, err := tk.GetTime(sentrytime.Monotonic)
+	if err != nil {
+		return err
+	}
+	for _, off := range offsets {
+		if off > 0 && now > math.MaxInt64-off {
+			return linuxerr.ERANGE
+		}
+		if sum := now + off; sum < 0 || sum/1e9 > maxTimeNamespaceSec {
+			return linuxerr.ERANGE
+		}
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.frozen {
+		return linuxerr.EACCES
+	}
+	for clock, off := range offsets {
+		if clock == linux.CLOCK_BOOTTIME {
+			ns.boottimeOffset = off
+		} else {
+			ns.monotonicOffset = off
+		}
+	}
+	return nil
+}
+
+// freeze prevents further changes to the offsets of ns, and sets up its clocks
+// and VDSO parameter page. It must be called before a task enters ns.
+func (ns *TimeNamespace) freeze(k *Kernel) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.frozen {
+		return nil
+	}
+
+	tk := k.timekeeper
+	monotonicClock := &timekeeperClock{tk: tk, c: sentrytime.Monotonic, offset: ns.monotonicOffset}
+	boottimeClock := &timekeeperClock{tk: tk, c: sentrytime.Monotonic, offset: ns.boottimeOffset}
+	if ns.monotonicOffset != 0 || ns.boottimeOffset != 0 {
+		fr, err := k.mf.Allocate(hostarch.PageSize, pgalloc.AllocOpts{Kind: usage.System})
+		if err != nil {
+			return err
+		}
+		ns.vvar = mm.NewSpecialMappable("[vvar]", k.mf, fr)
+		ns.params = NewVDSOParamPage(k.mf, fr)
+		ns.params.monotonicOffset = ns.monotonicOffset
+		ns.params.boottimeOffset = ns.boottimeOffset
+		tk.addNamespaceParams(ns.params)
+	}
+	ns.monotonicClock = monotonicClock
+	ns.boottimeClock = boottimeClock
+	ns.frozen = true
+	return nil
+}
+
+// MonotonicClock returns CLOCK_MONOTONIC in ns.
+//
+// Preconditions: A task has entered ns.
+func (ns *TimeNamespace) MonotonicClock() ktime.SampledClock {
+	return ns.monotonicClock
+}
+
+// BoottimeClock returns CLOCK_BOOTTIME in ns.
+//
+// Preconditions: A task has entered ns.
+func (ns *TimeNamespace) BoottimeClock() ktime.SampledClock {
+	return ns.boottimeClock
+}
+
+// vvarFor returns the VDSO parameter page to be mapped by tasks in ns.
+//
+// Preconditions: A task has entered ns.
+func (k *Kernel) vvarFor(ns *TimeNamespace) *mm.SpecialMappable {
+	if ns.vvar != nil {
+		return ns.vvar
+	}
+	if k.vdso == nil {
+		return nil
+	}
+	return k.vdso.ParamPage
+}
+
+// enterTimeNamespace freezes ns and maps its VDSO parameter page into m, as
+// required when a task using m enters ns.
+func (k *Kernel) enterTimeNamespace(ctx context.Context, ns *TimeNamespace, m *mm.MemoryManager) error {
+	if err := ns.freeze(k); err != nil {
+		return err
+	}
+	if m == nil {
+		return nil
+	}
+	if vvar := k.vvarFor(ns); vvar != nil {
+		return m.RemapVVAR(ctx, vvar)
+	}
+	return nil
+}
+
+// Type implements nsfs.Namespace.Type.
+func (ns *TimeNamespace) Type() string {
+	return "time"
+}
+
+// Destroy implements nsfs.Namespace.Destroy.
+func (ns *TimeNamespace) Destroy(ctx context.Context) {
+	if ns.params != nil {
+		ns.monotonicClock.tk.removeNamespaceParams(ns.params)
+		ns.vvar.DecRef(ctx)
+	}
+}
+
+// SetInode sets the nsfs `inode` to the time namespace.
+func (ns *TimeNamespace) SetInode(inode *nsfs.Inode) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.inode = inode
+}
+
+// GetInode returns the nsfs inode associated with the time namespace.
+func (ns *TimeNamespace) GetInode() *nsfs.Inode {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.inode
+}
+
+// IncRef increments the namespace's refcount.
+func (ns *TimeNamespace) IncRef() {
+	ns.GetInode().IncRef()
+}
+
+// DecRef decrements the namespace's refcount.
+func (ns *TimeNamespace) DecRef(ctx context.Context) {
+	ns.GetInode().DecRef(ctx)
+}
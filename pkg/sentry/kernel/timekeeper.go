@@ -86,6 +86,15 @@ type Timekeeper struct {
 
 	// wg is used to indicate that the update goroutine has exited.
 	wg sync.WaitGroup `state:"nosave"`
+
+	// nsParamsMu protects nsParams.
+	nsParamsMu sync.Mutex `state:"nosave"`
+
+	// nsParams are the VDSO parameter pages of time namespaces with clock
+	// offsets, which are updated along with the kernel's parameter page.
+	//
+	// +checklocks:nsParamsMu
+	nsParams map[*VDSOParamPage]struct{}
 }
 
 // NewTimekeeper returns a Timekeeper that is automatically kept up-to-date.
@@ -110,7 +119,7 @@ func (t *Timekeeper) SetClocks(c sentrytime.Clocks, params *VDSOParamPage) {
 	// Update the params, marking them "not ready", as we may need to
 	// restart calibration on this new machine.
 	if t.restored != nil {
-		if err := params.Write(func() vdsoParams {
+		if err := t.writeParams(params, func() vdsoParams {
 			return vdsoParams{}
 		}); err != nil {
 			panic("unable to reset VDSO params: " + err.Error())
@@ -197,7 +206,7 @@ func (t *Timekeeper) startUpdater(params *VDSOParamPage) {
 			// Call Update within a Write block to prevent the VDSO
 			// from using the old params between Update and
 			// Write.
-			if err := params.Write(func() vdsoParams {
+			if err := t.writeParams(params, func() vdsoParams {
 				monotonicParams, monotonicOk, realtimeParams, realtimeOk := t.clocks.Update()
 
 				var p vdsoParams
@@ -227,6 +236,41 @@ func (t *Timekeeper) startUpdater(params *VDSOParamPage) {
 	}()
 }
 
+// writeParams writes the parameter pages of params and of all time
+// namespaces, as if by VDSOParamPage.Write.
+//
+// nsParamsMu is held for the duration of the write, so that the pages of
+// time namespaces are not released while being written.
+func (t *Timekeeper) writeParams(params *VDSOParamPage, f func() vdsoParams) error {
+	t.nsParamsMu.Lock()
+	defer t.nsParamsMu.Unlock()
+	pages := make([]*VDSOParamPage, 0, 1+len(t.nsParams))
+	pages = append(pages, params)
+	for p := range t.nsParams {
+		pages = append(pages, p)
+	}
+	return writeVDSOParamPages(pages, f)
+}
+
+// addNamespaceParams registers the parameter page of a time namespace, so
+// that it is kept up-to-date with the kernel's parameter page.
+func (t *Timekeeper) addNamespaceParams(params *VDSOParamPage) {
+	t.nsParamsMu.Lock()
+	defer t.nsParamsMu.Unlock()
+	if t.nsParams == nil {
+		t.nsParams = make(map[*VDSOParamPage]struct{})
+	}
+	t.nsParams[params] = struct{}{}
+}
+
+// removeNamespaceParams unregisters a parameter page registered by
+// addNamespaceParams.
+func (t *Timekeeper) removeNamespaceParams(params *VDSOParamPage) {
+	t.nsParamsMu.Lock()
+	defer t.nsParamsMu.Unlock()
+	delete(t.nsParams, params)
+}
+
 // stopUpdater stops the update goroutine, blocking until it exits.
 //
 // mu must be held.
@@ -307,6 +351,10 @@ type timekeeperClock struct {
 	tk *Timekeeper
 	c  sentrytime.ClockID
 
+	// offset is added to the time read from tk, in nanoseconds. It is
+	// non-zero only for clocks of time namespaces.
+	offset int64
+
 	// Implements ktime.SampledClock.WallTimeUntil.
 	ktime.WallRateClock `state:"nosave"`
 
@@ -321,7 +369,7 @@ func (tc *timekeeperClock) Now() ktime.Time {
 	if err != nil {
 		panic(fmt.Sprintf("timekeeperClock(ClockID=%v)).Now: %v", tc.c, err))
 	}
-	return ktime.FromNanoseconds(now)
+	return ktime.FromNanoseconds(now + tc.offset)
 }
 
 // NewTimer implements ktime.Clock.NewTimer.
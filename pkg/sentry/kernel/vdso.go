@@ -40,6 +40,11 @@ type vdsoParams struct {
 	realtimeBaseCycles int64
 	realtimeBaseRef    int64
 	realtimeFrequency  uint64
+
+	// monotonicOffset and boottimeOffset are the time namespace offsets, in
+	// nanoseconds, added to CLOCK_MONOTONIC and CLOCK_BOOTTIME respectively.
+	monotonicOffset int64
+	boottimeOffset  int64
 }
 
 // VDSOParamPage manages a VDSO parameter page.
@@ -77,6 +82,11 @@ type VDSOParamPage struct {
 	// the sentry, so reusing this buffer is a good tradeoff between memory
 	// usage and the cost of allocation.
 	copyScratchBuffer []byte
+
+	// monotonicOffset and boottimeOffset are the time namespace offsets
+	// written to the page along with every update. They are immutable.
+	monotonicOffset int64
+	boottimeOffset  int64
 }
 
 // afterLoad is invoked by stateify.
@@ -133,24 +143,36 @@ func (v *VDSOParamPage) incrementSeq(paramPage safemem.Block) error {
 // Write starts a write block, calls f to get the new parameters, writes
 // out the new parameters, then ends the write block.
 func (v *VDSOParamPage) Write(f func() vdsoParams) error {
-	paramPage, err := v.access()
+	paramPage, err := v.beginWrite()
 	if err != nil {
 		return err
 	}
+	return v.endWrite(paramPage, f())
+}
+
+// beginWrite starts a write block, returning the mapping of the param page.
+func (v *VDSOParamPage) beginWrite() (safemem.Block, error) {
+	paramPage, err := v.access()
+	if err != nil {
+		return safemem.Block{}, err
+	}
 
-	// Write begin.
 	next := v.seq + 1
 	if next%2 != 1 {
 		panic("Out-of-order sequence count")
 	}
 
-	err = v.incrementSeq(paramPage)
-	if err != nil {
-		return err
+	if err := v.incrementSeq(paramPage); err != nil {
+		return safemem.Block{}, err
 	}
+	return paramPage, nil
+}
 
-	// Get the new params.
-	p := f()
+// endWrite writes out p, with the page's time namespace offsets, then ends
+// the write block started by beginWrite.
+func (v *VDSOParamPage) endWrite(paramPage safemem.Block, p vdsoParams) error {
+	p.monotonicOffset = v.monotonicOffset
+	p.boottimeOffset = v.boottimeOffset
 	buf := v.copyScratchBuffer[:p.SizeBytes()]
 	p.MarshalUnsafe(buf)
 
@@ -159,6 +181,35 @@ func (v *VDSOParamPage) Write(f func() vdsoParams) error {
 		panic(fmt.Sprintf("Unable to get set VDSO parameters: %v", err))
 	}
 
-	// Write end.
 	return v.incrementSeq(paramPage)
 }
+
+// writeVDSOParamPages is equivalent to calling Write on each of pages, except
+// that f is called only once, within the write blocks of all of them.
+//
+// Pages whose write block cannot be started are skipped; the first error
+// encountered is returned.
+func writeVDSOParamPages(pages []*VDSOParamPage, f func() vdsoParams) error {
+	var firstErr error
+	begun := make([]*VDSOParamPage, 0, len(pages))
+	blocks := make([]safemem.Block, 0, len(pages))
+	for _, v := range pages {
+		paramPage, err := v.beginWrite()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		begun = append(begun, v)
+		blocks = append(blocks, paramPage)
+	}
+
+	p := f()
+	for i, v := range begun {
+		if err := v.endWrite(blocks[i], p); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
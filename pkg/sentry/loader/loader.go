@@ -86,6 +86,10 @@ type LoadArgs struct {
 
 	// Features specifies the CPU feature set for the executable.
 	Features cpuid.FeatureSet
+
+	// VVAR is the VDSO parameter page to map for the executable. If VVAR is
+	// nil, the VDSO's own parameter page is used.
+	VVAR *mm.SpecialMappable
 }
 
 // openPath opens args.Filename and checks that it is valid for loading.
@@ -276,7 +280,7 @@ func Load(ctx context.Context, args LoadArgs, extraAuxv []arch.AuxEntry, vdso *V
 	}
 
 	// Load the VDSO.
-	vdsoAddr, err := loadVDSO(ctx, args.MemoryManager, vdso, loaded)
+	vdsoAddr, err := loadVDSO(ctx, args.MemoryManager, vdso, args.VVAR, loaded)
 	if err != nil {
 		return ImageInfo{}, syserr.NewDynamic(fmt.Sprintf("error loading VDSO: %v", err), syserr.FromError(err).ToLinux())
 	}
@@ -244,8 +244,11 @@ func PrepareVDSO(mf *pgalloc.MemoryFile) (*VDSO, error) {
 // depend on parts of the ELF that would normally not be mapped.  To maintain
 // compatibility with such binaries, we load the VDSO much like Linux.
 //
+// If vvar is not nil, it is mapped as the parameter page instead of
+// v.ParamPage.
+//
 // loadVDSO takes a reference on the VDSO and parameter page FrameRegions.
-func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF) (hostarch.Addr, error) {
+func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, vvar *mm.SpecialMappable, bin loadedELF) (hostarch.Addr, error) {
 	if v.os != bin.os {
 		ctx.Warningf("Binary ELF OS %v and VDSO ELF OS %v differ", bin.os, v.os)
 		return 0, linuxerr.ENOEXEC
@@ -255,9 +258,13 @@ func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF)
 		return 0, linuxerr.ENOEXEC
 	}
 
+	if vvar == nil {
+		vvar = v.ParamPage
+	}
+
 	// Reserve address space for the VDSO and its parameter page, which is
 	// mapped just before the VDSO.
-	mapSize := v.vdso.Length() + v.ParamPage.Length()
+	mapSize := v.vdso.Length() + vvar.Length()
 	addr, err := m.MMap(ctx, memmap.MMapOpts{
 		Length:  mapSize,
 		Private: true,
@@ -269,9 +276,9 @@ func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF)
 
 	// Now map the param page.
 	_, err = m.MMap(ctx, memmap.MMapOpts{
-		Length:          v.ParamPage.Length(),
-		MappingIdentity: v.ParamPage,
-		Mappable:        v.ParamPage,
+		Length:          vvar.Length(),
+		MappingIdentity: vvar,
+		Mappable:        vvar,
 		Addr:            addr,
 		Fixed:           true,
 		Unmap:           true,
@@ -283,11 +290,12 @@ func loadVDSO(ctx context.Context, m *mm.MemoryManager, v *VDSO, bin loadedELF)
 		ctx.Infof("Unable to map VDSO param page: %v", err)
 		return 0, err
 	}
+	m.SetVVAR(addr)
 
 	// Now map the VDSO itself.
-	vdsoAddr, ok := addr.AddLength(v.ParamPage.Length())
+	vdsoAddr, ok := addr.AddLength(vvar.Length())
 	if !ok {
-		panic(fmt.Sprintf("Part of mapped range overflows? %#x + %#x", addr, v.ParamPage.Length()))
+		panic(fmt.Sprintf("Part of mapped range overflows? %#x + %#x", addr, vvar.Length()))
 	}
 	_, err = m.MMap(ctx, memmap.MMapOpts{
 		Length:          v.vdso.Length(),
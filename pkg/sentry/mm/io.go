@@ -498,8 +498,18 @@ func (mm *MemoryManager) handleASIOFault(ctx context.Context, addr hostarch.Addr
 		ar.End = vendaddr
 	}
 
-	// Ensure that we have usable pmas.
+	// Deliver faults to userfaultfds if necessary.
 	mm.activeMu.Lock()
+	if uf, ok := mm.findUserfaultLocked(vseg, ar, at); ok {
+		mm.activeMu.Unlock()
+		mm.mappingMu.RUnlock()
+		if err := uf.wait(ctx, false /* user */); err != nil {
+			return translateIOError(ctx, err)
+		}
+		return mm.handleASIOFault(ctx, addr, ioar, at)
+	}
+
+	// Ensure that we have usable pmas.
 	pseg, pend, err := mm.getPMAsLocked(ctx, vseg, ar, at, true /* callerIndirectCommit */)
 	mm.mappingMu.RUnlock()
 	if pendaddr := pend.Start(); pendaddr < ar.End {
@@ -543,6 +553,7 @@ func (mm *MemoryManager) withInternalMappings(ctx context.Context, ar hostarch.A
 
 	// Ensure that we have usable vmas.
 	mm.mappingMu.RLock()
+	ioar := ar
 	vseg, vend, verr := mm.getVMAsLocked(ctx, ar, at, ignorePermissions)
 	if vendaddr := vend.Start(); vendaddr < ar.End {
 		if vendaddr <= ar.Start {
@@ -552,8 +563,18 @@ func (mm *MemoryManager) withInternalMappings(ctx context.Context, ar hostarch.A
 		ar.End = vendaddr
 	}
 
-	// Ensure that we have usable pmas.
+	// Deliver faults to userfaultfds if necessary.
 	mm.activeMu.Lock()
+	if uf, ok := mm.findUserfaultLocked(vseg, ar, at); ok {
+		mm.activeMu.Unlock()
+		mm.mappingMu.RUnlock()
+		if err := uf.wait(ctx, false /* user */); err != nil {
+			return 0, translateIOError(ctx, err)
+		}
+		return mm.withInternalMappings(ctx, ioar, at, ignorePermissions, f)
+	}
+
+	// Ensure that we have usable pmas.
 	pseg, pend, perr := mm.getPMAsLocked(ctx, vseg, ar, at, true /* callerIndirectCommit */)
 	mm.mappingMu.RUnlock()
 	if pendaddr := pend.Start(); pendaddr < ar.End {
@@ -626,8 +647,18 @@ func (mm *MemoryManager) withVecInternalMappings(ctx context.Context, ars hostar
 		return 0, translateIOError(ctx, verr)
 	}
 
-	// Ensure that we have usable pmas.
+	// Deliver faults to userfaultfds if necessary.
 	mm.activeMu.Lock()
+	if uf, ok := mm.findVecUserfaultLocked(vars, at); ok {
+		mm.activeMu.Unlock()
+		mm.mappingMu.RUnlock()
+		if err := uf.wait(ctx, false /* user */); err != nil {
+			return 0, translateIOError(ctx, err)
+		}
+		return mm.withVecInternalMappings(ctx, ars, at, ignorePermissions, f)
+	}
+
+	// Ensure that we have usable pmas.
 	pars, perr := mm.getVecPMAsLocked(ctx, vars, at, true /* callerIndirectCommit */)
 	mm.mappingMu.RUnlock()
 	if pars.NumBytes() == 0 {
@@ -38,6 +38,7 @@ func NewMemoryManager(p platform.Platform, mf *pgalloc.MemoryFile, sleepForActiv
 		dumpability:        atomicbitops.FromInt32(int32(UserDumpable)),
 		aioManager:         aioManager{contexts: make(map[uint64]*AIOContext)},
 		sleepForActivation: sleepForActivation,
+		pkeys:              1,
 	}
 }
 
@@ -80,6 +81,7 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 		brk:      mm.brk,
 		usageAS:  mm.usageAS,
 		dataAS:   mm.dataAS,
+		pkeys:    mm.pkeys,
 		// "The child does not inherit its parent's memory locks (mlock(2),
 		// mlockall(2))." - fork(2). So lockedAS is 0 and defMLockMode is
 		// MLockNone, both of which are zero values. vma.mlockMode is reset
@@ -94,6 +96,7 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 		aioManager:         aioManager{contexts: make(map[uint64]*AIOContext)},
 		sleepForActivation: mm.sleepForActivation,
 		vdsoSigReturnAddr:  mm.vdsoSigReturnAddr,
+		vvarAddr:           mm.vvarAddr,
 	}
 
 	// Copy vmas.
@@ -102,6 +105,10 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 	for srcvseg := mm.vmas.FirstSegment(); srcvseg.Ok(); srcvseg = srcvseg.NextSegment() {
 		vma := srcvseg.ValuePtr().copy()
 		vmaAR := srcvseg.Range()
+		// The child isn't registered with the parent's userfaultfds, since we
+		// don't support UFFD_FEATURE_EVENT_FORK.
+		vma.uffd = nil
+		vma.uffdMode = 0
 
 		if vma.dontfork {
 			length := uint64(vmaAR.Length())
@@ -200,7 +207,9 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 		mm.mf.IncRef(fr, memCgID)
 		addrRange := srcpseg.Range()
 		mm2.addRSSLocked(addrRange)
-		dstpgap = mm2.pmas.Insert(dstpgap, addrRange, *pma).NextGap()
+		pma2 := *pma
+		pma2.uffdWP = false
+		dstpgap = mm2.pmas.Insert(dstpgap, addrRange, pma2).NextGap()
 	}
 	if unmapAR.Length() != 0 {
 		mm.unmapASLocked(unmapAR)
@@ -252,6 +261,11 @@ func (mm *MemoryManager) IncUsers() bool {
 	}
 }
 
+// Users returns mm's user count.
+func (mm *MemoryManager) Users() int32 {
+	return mm.users.Load()
+}
+
 // DecUsers decrements mm's user count. If the user count reaches 0, all
 // mappings in mm are unmapped.
 func (mm *MemoryManager) DecUsers(ctx context.Context) {
@@ -178,3 +178,17 @@ func (mm *MemoryManager) SetVDSOSigReturn(addr uint64) {
 	defer mm.metadataMu.Unlock()
 	mm.vdsoSigReturnAddr = addr
 }
+
+// VVAR returns the address of the VDSO parameter page.
+func (mm *MemoryManager) VVAR() hostarch.Addr {
+	mm.metadataMu.Lock()
+	defer mm.metadataMu.Unlock()
+	return mm.vvarAddr
+}
+
+// SetVVAR sets the address of the VDSO parameter page.
+func (mm *MemoryManager) SetVVAR(addr hostarch.Addr) {
+	mm.metadataMu.Lock()
+	defer mm.metadataMu.Unlock()
+	mm.vvarAddr = addr
+}
@@ -29,6 +29,7 @@
 //									memmap.File locks
 //					mm.aioManager.mu
 //						mm.AIOContext.mu
+//					mm.UserfaultFD.mu
 //
 // Only mm.MemoryManager.Fork is permitted to lock mm.MemoryManager.activeMu in
 // multiple mm.MemoryManagers, as it does so in a well-defined order (forked
@@ -123,6 +124,13 @@ type MemoryManager struct {
 	// defMLockMode is protected by mappingMu.
 	defMLockMode memmap.MLockMode
 
+	// pkeys is a bitmap of allocated memory protection keys, like
+	// mm_context_t::pkey_allocation_map on x86. Protection key 0 is allocated
+	// when the MemoryManager is created.
+	//
+	// pkeys is protected by mappingMu.
+	pkeys uint16
+
 	// activeMu is loosely analogous to Linux's struct
 	// mm_struct::page_table_lock.
 	activeMu activeRWMutex `state:"nosave"`
@@ -236,6 +244,10 @@ type MemoryManager struct {
 	// vdsoSigReturnAddr is the address of 'vdso_sigreturn'.
 	vdsoSigReturnAddr uint64
 
+	// vvarAddr is the address at which the VDSO parameter page was mapped.
+	// It is 0 if no parameter page has been mapped.
+	vvarAddr hostarch.Addr
+
 	// membarrierPrivateEnabled is non-zero if EnableMembarrierPrivate has
 	// previously been called. Since, as of this writing,
 	// MEMBARRIER_CMD_PRIVATE_EXPEDITED is implemented as a global memory
@@ -245,6 +257,11 @@ type MemoryManager struct {
 	// membarrierRSeqEnabled is non-zero if EnableMembarrierRSeq has previously
 	// been called.
 	membarrierRSeqEnabled atomicbitops.Uint32
+
+	// userfaultfds is the number of UserfaultFDs that have registered ranges
+	// in the MemoryManager. If it is 0, no vma may have a userfaultfd
+	// registered, and the fault and I/O paths skip checking for userfaults.
+	userfaultfds atomicbitops.Int32
 }
 
 // vma represents a virtual memory area.
@@ -308,6 +325,10 @@ type vma struct {
 	// numaNodemask is the NUMA nodemask for this vma set by mbind().
 	numaNodemask uint64
 
+	// pkey is the memory protection key for this vma set by
+	// pkey_mprotect().
+	pkey int32
+
 	// If id is not nil, it controls the lifecycle of mappable and provides vma
 	// metadata shown in /proc/[pid]/maps, and the vma holds a reference.
 	id memmap.MappingIdentity
@@ -324,6 +345,13 @@ type vma struct {
 	// This field can be read atomically, and written with mm.activeMu locked for
 	// writing and mm.mapping locked.
 	lastFault uintptr
+
+	// If uffd is not nil, faults in this vma of the kinds selected by
+	// uffdMode (a mask of linux.UFFDIO_REGISTER_MODE_*) are handled by uffd.
+	// The vma does not hold a reference on uffd; uffd unregisters itself
+	// from all vmas when it is released.
+	uffd     *UserfaultFD
+	uffdMode uint64
 }
 
 func (v *vma) copy() vma {
@@ -340,10 +368,13 @@ func (v *vma) copy() vma {
 		mlockMode:      v.mlockMode,
 		numaPolicy:     v.numaPolicy,
 		numaNodemask:   v.numaNodemask,
+		pkey:           v.pkey,
 		id:             v.id,
 		name:           v.name,
 		nameMut:        v.nameMut,
 		lastFault:      atomic.LoadUintptr(&v.lastFault),
+		uffd:           v.uffd,
+		uffdMode:       v.uffdMode,
 	}
 }
 
@@ -394,6 +425,12 @@ type pma struct {
 	// Invariant: If huge == true, then private == true.
 	huge bool
 
+	// If uffdWP is true, this pma is write-protected by a userfaultfd, and
+	// Write is disallowed in effectivePerms.
+	//
+	// Invariant: If uffdWP == true, then private == true.
+	uffdWP bool
+
 	// If internalMappings is not empty, it is the cached return value of
 	// file.MapInternal for the memmap.FileRange mapped by this pma.
 	internalMappings safemem.BlockSeq `state:"nosave"`
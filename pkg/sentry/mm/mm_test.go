@@ -17,6 +17,7 @@ package mm
 import (
 	"testing"
 
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/hostarch"
@@ -340,3 +341,121 @@ func TestGetAllocationDirection(t *testing.T) {
 		})
 	}
 }
+
+// findUserfault returns the fault, if any, that an access of type at to ar
+// must deliver to a userfaultfd.
+func (mm *MemoryManager) findUserfault(ar hostarch.AddrRange, at hostarch.AccessType) (pendingUserfault, bool) {
+	mm.mappingMu.RLock()
+	defer mm.mappingMu.RUnlock()
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+	uf, ok := mm.findUserfaultLocked(mm.vmas.FindSegment(ar.Start), ar, at)
+	if ok {
+		uf.fd.vfsfd.FileDescriptionRefs.DecRef(func() {})
+	}
+	return uf, ok
+}
+
+func TestUserfaultFill(t *testing.T) {
+	ctx := contexttest.Context(t)
+	mm := testMemoryManager(ctx)
+	defer mm.DecUsers(ctx)
+
+	addr, err := mm.MMap(ctx, memmap.MMapOpts{
+		Length:   2 * hostarch.PageSize,
+		Private:  true,
+		Perms:    hostarch.ReadWrite,
+		MaxPerms: hostarch.AnyAccess,
+	})
+	if err != nil {
+		t.Fatalf("MMap got err %v want nil", err)
+	}
+	ar, _ := addr.ToRange(2 * hostarch.PageSize)
+	firstAR, _ := addr.ToRange(hostarch.PageSize)
+	fd := &UserfaultFD{mm: mm}
+	fd.vfsfd.InitRefs()
+	if err := mm.registerUserfaultFD(fd, ar, linux.UFFDIO_REGISTER_MODE_MISSING|linux.UFFDIO_REGISTER_MODE_WP); err != nil {
+		t.Fatalf("registerUserfaultFD got err %v want nil", err)
+	}
+
+	// Both pages are missing.
+	if uf, ok := mm.findUserfault(ar, hostarch.Read); !ok || uf.addr != addr {
+		t.Errorf("findUserfault got (%+v, %t) want fault at %#x", uf, ok, addr)
+	}
+
+	// Fill the first page, write-protected.
+	data := make([]byte, hostarch.PageSize)
+	data[0] = 1
+	if err := mm.fillUserfaultPage(ctx, fd, addr, data, true /* wp */); err != nil {
+		t.Fatalf("fillUserfaultPage got err %v want nil", err)
+	}
+	if err := mm.fillUserfaultPage(ctx, fd, addr, data, false /* wp */); !linuxerr.Equals(linuxerr.EEXIST, err) {
+		t.Errorf("fillUserfaultPage of filled page got err %v want EEXIST", err)
+	}
+	if uf, ok := mm.findUserfault(ar, hostarch.Read); !ok || uf.addr != addr+hostarch.PageSize {
+		t.Errorf("findUserfault got (%+v, %t) want fault at %#x", uf, ok, addr+hostarch.PageSize)
+	}
+	if uf, ok := mm.findUserfault(firstAR, hostarch.Read); ok {
+		t.Errorf("findUserfault for read of filled page got %+v want none", uf)
+	}
+	wantFlags := uint64(linux.UFFD_PAGEFAULT_FLAG_WRITE | linux.UFFD_PAGEFAULT_FLAG_WP)
+	if uf, ok := mm.findUserfault(firstAR, hostarch.Write); !ok || uf.flags != wantFlags {
+		t.Errorf("findUserfault for write of write-protected page got (%+v, %t) want flags %#x", uf, ok, wantFlags)
+	}
+	b := make([]byte, 1)
+	if _, err := mm.CopyIn(ctx, addr, b, usermem.IOOpts{}); err != nil || b[0] != 1 {
+		t.Errorf("CopyIn got (%v, %v) want ([1], nil)", b, err)
+	}
+
+	// Writes succeed once write protection is cleared.
+	if err := mm.writeProtectUserfaultFD(fd, firstAR, false /* protect */); err != nil {
+		t.Fatalf("writeProtectUserfaultFD got err %v want nil", err)
+	}
+	if uf, ok := mm.findUserfault(firstAR, hostarch.Write); ok {
+		t.Errorf("findUserfault for write of unprotected page got %+v want none", uf)
+	}
+
+	// Unregistered pages don't fault to the userfaultfd.
+	mm.unregisterUserfaultFD(fd, ar)
+	if uf, ok := mm.findUserfault(ar, hostarch.Read); ok {
+		t.Errorf("findUserfault after unregistration got %+v want none", uf)
+	}
+}
+
+func TestPkeys(t *testing.T) {
+	ctx := contexttest.Context(t)
+	mm := testMemoryManager(ctx)
+	defer mm.DecUsers(ctx)
+
+	addr, err := mm.MMap(ctx, memmap.MMapOpts{
+		Length:   hostarch.PageSize,
+		Private:  true,
+		Perms:    hostarch.ReadWrite,
+		MaxPerms: hostarch.AnyAccess,
+	})
+	if err != nil {
+		t.Fatalf("MMap got err %v want nil", err)
+	}
+
+	// pkey_alloc(2) passes no platform protection keys, since they are not
+	// enforced, so only the default protection key 0 is usable.
+	if _, err := mm.PkeyAlloc(0); !linuxerr.Equals(linuxerr.ENOSPC, err) {
+		t.Errorf("PkeyAlloc got err %v want ENOSPC", err)
+	}
+	if err := mm.PkeyMProtect(addr, hostarch.PageSize, hostarch.Read, false, 1); !linuxerr.Equals(linuxerr.EINVAL, err) {
+		t.Errorf("PkeyMProtect with unallocated pkey got err %v want EINVAL", err)
+	}
+	if err := mm.PkeyMProtect(addr, hostarch.PageSize, hostarch.Read, false, 0); err != nil {
+		t.Errorf("PkeyMProtect with pkey 0 got err %v want nil", err)
+	}
+	// A pkey of -1 behaves like mprotect(2).
+	if err := mm.PkeyMProtect(addr, hostarch.PageSize, hostarch.ReadWrite, false, -1); err != nil {
+		t.Errorf("PkeyMProtect with pkey -1 got err %v want nil", err)
+	}
+	if got := mm.vmas.FindSegment(addr).ValuePtr().pkey; got != 0 {
+		t.Errorf("vma pkey got %d want 0", got)
+	}
+	if err := mm.PkeyFree(1); !linuxerr.Equals(linuxerr.EINVAL, err) {
+		t.Errorf("PkeyFree of unallocated pkey got err %v want EINVAL", err)
+	}
+}
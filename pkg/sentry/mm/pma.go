@@ -19,6 +19,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/hostarch"
@@ -56,6 +57,11 @@ func (mm *MemoryManager) existingPMAsLocked(ar hostarch.AddrRange, at hostarch.A
 		if !perms.SupersetOf(at) {
 			return pmaIterator{}
 		}
+		if at.Write && pma.uffdWP {
+			// Writes must check for write-protect userfaults, even if
+			// permissions are ignored.
+			return pmaIterator{}
+		}
 		if needInternalMappings && pma.internalMappings.IsEmpty() {
 			return pmaIterator{}
 		}
@@ -248,6 +254,7 @@ func (mm *MemoryManager) getPMAsInternalLocked(ctx context.Context, vseg vmaIter
 
 	vma := vseg.ValuePtr()
 	memCgID := pgalloc.MemoryCgroupIDFromContext(ctx)
+	stallTracker := pgalloc.StallTrackerFromContext(ctx)
 	allocDir := mm.getAllocationDirection(ar, vma)
 	atomic.StoreUintptr(&vma.lastFault, uintptr(ar.Start))
 
@@ -280,15 +287,21 @@ func (mm *MemoryManager) getPMAsInternalLocked(ctx context.Context, vseg vmaIter
 					// application page faults (that trap into the sentry) by
 					// creating AddressSpace mappings in advance.
 					allocAR := optAR.Intersect(hugeMaskAR)
+					if vma.uffdMode&linux.UFFDIO_REGISTER_MODE_MISSING != 0 {
+						// Pages outside of ar must remain missing, so that
+						// accessing them faults to the userfaultfd.
+						allocAR = optAR.Intersect(ar)
+					}
 					// Don't back stacks with huge pages due to low utilization
 					// and because they're often fragmented by copy-on-write.
 					huge := mm.mf.HugepagesEnabled() && allocAR.IsHugePageAligned() && !vma.growsDown && !vma.isStack
 					allocOpts := pgalloc.AllocOpts{
-						Kind:    usage.Anonymous,
-						MemCgID: memCgID,
-						Mode:    pgalloc.AllocateUncommitted,
-						Huge:    huge,
-						Dir:     allocDir,
+						Kind:         usage.Anonymous,
+						MemCgID:      memCgID,
+						Mode:         pgalloc.AllocateUncommitted,
+						Huge:         huge,
+						Dir:          allocDir,
+						StallTracker: stallTracker,
 					}
 					// If the allocation is hugepage-backed and
 					// callerIndirectCommit is true, the caller will commit every
@@ -385,6 +398,19 @@ func (mm *MemoryManager) getPMAsInternalLocked(ctx context.Context, vseg vmaIter
 
 			case pseg.Ok() && pseg.Start() < vsegAR.End:
 				oldpma := pseg.ValuePtr()
+				if at.Write && oldpma.uffdWP {
+					// Callers have already delivered write-protect faults
+					// to the userfaultfd, if any; a write now resolves the
+					// write protection, as for a vma that is no longer
+					// registered for write-protect faults.
+					pseg = mm.pmas.Isolate(pseg, ar)
+					pstart = pmaIterator{} // iterators invalidated
+					oldpma = pseg.ValuePtr()
+					oldpma.uffdWP = false
+					if !oldpma.needCOW {
+						oldpma.effectivePerms.Write = vma.effectivePerms.Write
+					}
+				}
 				if at.Write && mm.isPMACopyOnWriteLocked(vseg, pseg) {
 					// Break copy-on-write by copying.
 					if checkInvariants {
@@ -436,12 +462,13 @@ func (mm *MemoryManager) getPMAsInternalLocked(ctx context.Context, vseg vmaIter
 					huge := mm.mf.HugepagesEnabled() && copyAR.IsHugePageAligned()
 					reader := safemem.BlockSeqReader{Blocks: mm.internalMappingsLocked(pseg, copyAR)}
 					fr, err := mm.mf.Allocate(uint64(copyAR.Length()), pgalloc.AllocOpts{
-						Kind:       usage.Anonymous,
-						MemCgID:    memCgID,
-						Mode:       pgalloc.AllocateAndWritePopulate,
-						Huge:       huge,
-						Dir:        allocDir,
-						ReaderFunc: reader.ReadToBlocks,
+						Kind:         usage.Anonymous,
+						MemCgID:      memCgID,
+						Mode:         pgalloc.AllocateAndWritePopulate,
+						Huge:         huge,
+						Dir:          allocDir,
+						ReaderFunc:   reader.ReadToBlocks,
+						StallTracker: stallTracker,
 					})
 					if _, ok := err.(safecopy.BusError); ok {
 						// If we got SIGBUS during the copy, deliver SIGBUS to
@@ -618,6 +645,9 @@ func (mm *MemoryManager) isPMACopyOnWriteLocked(vseg vmaIterator, pseg pmaIterat
 		vma := vseg.ValuePtr()
 		pma.effectivePerms = vma.effectivePerms
 		pma.maxPerms = vma.maxPerms
+		if pma.uffdWP {
+			pma.effectivePerms.Write = false
+		}
 		return false
 	}
 	return true
@@ -947,7 +977,8 @@ func (pmaSetFunctions) Merge(ar1 hostarch.AddrRange, pma1 pma, ar2 hostarch.Addr
 		pma1.maxPerms != pma2.maxPerms ||
 		pma1.needCOW != pma2.needCOW ||
 		pma1.private != pma2.private ||
-		pma1.huge != pma2.huge {
+		pma1.huge != pma2.huge ||
+		pma1.uffdWP != pma2.uffdWP {
 		return pma{}, false
 	}
 
@@ -52,8 +52,18 @@ func (mm *MemoryManager) HandleUserFault(ctx context.Context, addr hostarch.Addr
 		return err
 	}
 
-	// Ensure that we have a usable pma.
+	// Deliver the fault to a userfaultfd if necessary.
 	mm.activeMu.Lock()
+	if uf, ok := mm.findUserfaultLocked(vseg, ar, at); ok {
+		mm.activeMu.Unlock()
+		mm.mappingMu.RUnlock()
+		if err := uf.wait(ctx, true /* user */); err != nil {
+			return err
+		}
+		return mm.HandleUserFault(ctx, addr, at, sp)
+	}
+
+	// Ensure that we have a usable pma.
 	pseg, _, err := mm.getPMAsLocked(ctx, vseg, ar, at, true /* callerIndirectCommit */)
 	mm.mappingMu.RUnlock()
 	if err != nil {
@@ -568,6 +578,11 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 		if vma.mappable != nil {
 			vma.off = vseg.mappableOffsetAt(oldAR.Start)
 		}
+		// Userfaultfd registrations don't follow mremap without
+		// UFFD_FEATURE_EVENT_REMAP, which we don't support. See
+		// mm/userfaultfd.c:mremap_userfaultfd_prep().
+		vma.uffd = nil
+		vma.uffdMode = 0
 		if vma.id != nil {
 			vma.id.IncRef()
 		}
@@ -599,6 +614,8 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 	// overlapping oldAR.
 	vseg = mm.vmas.Isolate(vseg, oldAR)
 	vma := vseg.ValuePtr().copy()
+	vma.uffd = nil
+	vma.uffdMode = 0
 	mm.vmas.Remove(vseg)
 	vseg = mm.vmas.Insert(mm.vmas.FindGap(newAR.Start), newAR, vma)
 	mm.usageAS = mm.usageAS - uint64(oldAR.Length()) + uint64(newAR.Length())
@@ -631,6 +648,12 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 
 // MProtect implements the semantics of Linux's mprotect(2).
 func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms hostarch.AccessType, growsDown bool) error {
+	return mm.PkeyMProtect(addr, length, realPerms, growsDown, -1)
+}
+
+// PkeyMProtect implements the semantics of Linux's pkey_mprotect(2). If pkey
+// is -1, the protection keys of affected vmas are unchanged.
+func (mm *MemoryManager) PkeyMProtect(addr hostarch.Addr, length uint64, realPerms hostarch.AccessType, growsDown bool, pkey int32) error {
 	addr = hostarch.UntaggedUserAddr(addr)
 	if addr.RoundDown() != addr {
 		return linuxerr.EINVAL
@@ -650,6 +673,9 @@ func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms h
 
 	mm.mappingMu.Lock()
 	defer mm.mappingMu.Unlock()
+	if pkey != -1 && !mm.pkeyAllocatedLocked(pkey) {
+		return linuxerr.EINVAL
+	}
 	// Non-growsDown mprotect requires that all of ar is mapped, and stops at
 	// the first non-empty gap. growsDown mprotect requires that the first vma
 	// be growsDown, but does not require it to extend all the way to ar.Start;
@@ -700,6 +726,9 @@ func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms h
 
 		vma.realPerms = realPerms
 		vma.effectivePerms = effectivePerms
+		if pkey != -1 {
+			vma.pkey = pkey
+		}
 		if vma.isPrivateDataLocked() {
 			mm.dataAS += uint64(vmaLength)
 		}
@@ -716,7 +745,7 @@ func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms h
 					didUnmapAS = true
 				}
 				pma.effectivePerms = effectivePerms.Intersect(pma.translatePerms)
-				if pma.needCOW {
+				if pma.needCOW || pma.uffdWP {
 					pma.effectivePerms.Write = false
 				}
 			}
@@ -734,6 +763,47 @@ func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms h
 	}
 }
 
+// MaxPkeys is the maximum number of memory protection keys in a
+// MemoryManager, equal to the number of protection keys in the x86 PKRU
+// register.
+//
+// Protection keys are recorded in vmas with the semantics of Linux's
+// pkey_mprotect(2), but are not propagated to platform mappings, so the
+// pkey_alloc(2) syscall does not yet allocate any keys.
+const MaxPkeys = 16
+
+// PkeyAlloc allocates an unused memory protection key, as for Linux's
+// pkey_alloc(2). numPkeys is the number of protection keys supported by the
+// platform; if it is 0, only protection key 0 is usable.
+func (mm *MemoryManager) PkeyAlloc(numPkeys int) (int32, error) {
+	mm.mappingMu.Lock()
+	defer mm.mappingMu.Unlock()
+	for pkey := int32(1); pkey < int32(numPkeys) && pkey < MaxPkeys; pkey++ {
+		if mm.pkeys&(1<<pkey) == 0 {
+			mm.pkeys |= 1 << pkey
+			return pkey, nil
+		}
+	}
+	return 0, linuxerr.ENOSPC
+}
+
+// PkeyFree frees a memory protection key allocated by PkeyAlloc, as for
+// Linux's pkey_free(2). As in Linux, vmas assigned pkey are unaffected.
+func (mm *MemoryManager) PkeyFree(pkey int32) error {
+	mm.mappingMu.Lock()
+	defer mm.mappingMu.Unlock()
+	if !mm.pkeyAllocatedLocked(pkey) {
+		return linuxerr.EINVAL
+	}
+	mm.pkeys &^= 1 << pkey
+	return nil
+}
+
+// Preconditions: mm.mappingMu must be locked.
+func (mm *MemoryManager) pkeyAllocatedLocked(pkey int32) bool {
+	return pkey >= 0 && pkey < MaxPkeys && mm.pkeys&(1<<pkey) != 0
+}
+
 // BrkSetup sets mm's brk address to addr and its brk size to 0.
 func (mm *MemoryManager) BrkSetup(ctx context.Context, addr hostarch.Addr) {
 	var droppedIDs []memmap.MappingIdentity
@@ -1539,3 +1609,38 @@ func (mm *MemoryManager) FindVMAByName(ar hostarch.AddrRange, name string) (host
 	}
 	return 0, 0, fmt.Errorf("could not find %q in %s", name, ar)
 }
+
+// RemapVVAR replaces the VDSO parameter page mapped by the loader with vvar,
+// as required when a task switches time namespaces.
+//
+// The replacement is skipped if the application has unmapped or replaced the
+// original parameter page mapping.
+func (mm *MemoryManager) RemapVVAR(ctx context.Context, vvar *SpecialMappable) error {
+	addr := mm.VVAR()
+	if addr == 0 {
+		return nil
+	}
+
+	mm.mappingMu.RLock()
+	var old *SpecialMappable
+	if vseg := mm.vmas.FindSegment(addr); vseg.Ok() && vseg.Start() == addr && uint64(vseg.Range().Length()) == vvar.Length() {
+		old, _ = vseg.ValuePtr().mappable.(*SpecialMappable)
+	}
+	mm.mappingMu.RUnlock()
+	if old == nil || old == vvar || old.name != vvar.name {
+		return nil
+	}
+
+	_, err := mm.MMap(ctx, memmap.MMapOpts{
+		Length:          vvar.Length(),
+		MappingIdentity: vvar,
+		Mappable:        vvar,
+		Addr:            addr,
+		Fixed:           true,
+		Unmap:           true,
+		Private:         true,
+		Perms:           hostarch.Read,
+		MaxPerms:        hostarch.Read,
+	})
+	return err
+}
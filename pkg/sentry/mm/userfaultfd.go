@@ -0,0 +1,800 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mm
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/safemem"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/syserr"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// userfaultFeatures is the set of features supported by UFFDIO_API.
+const userfaultFeatures = linux.UFFD_FEATURE_PAGEFAULT_FLAG_WP
+
+// userfaultRegisterModes is the set of modes supported by UFFDIO_REGISTER.
+const userfaultRegisterModes = linux.UFFDIO_REGISTER_MODE_MISSING | linux.UFFDIO_REGISTER_MODE_WP
+
+// UserfaultFD implements vfs.FileDescriptionImpl for userfaultfd(2), which
+// allows page faults in registered ranges of a MemoryManager to be handled by
+// the application. See Documentation/admin-guide/mm/userfaultfd.rst.
+//
+// Only private anonymous mappings may be registered, and only missing and
+// write-protect faults are supported. Non-cooperative events (fork, mremap,
+// munmap, and MADV_DONTNEED) are not reported.
+//
+// +stateify savable
+type UserfaultFD struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+
+	// mm is the MemoryManager whose faults are handled by the UserfaultFD. mm
+	// is immutable.
+	mm *MemoryManager
+
+	// If userModeOnly is true, only faults caused by application code are
+	// handled by the UserfaultFD; sentry accesses to application memory that
+	// would fault to the UserfaultFD fail with EFAULT instead. userModeOnly
+	// is immutable.
+	userModeOnly bool
+
+	// queue is notified when faults become available to read.
+	queue waiter.Queue
+
+	mu userfaultFDMutex `state:"nosave"`
+
+	// initialized is true if the UFFDIO_API handshake has completed.
+	initialized bool
+
+	// features is the set of features requested by UFFDIO_API.
+	features uint64
+
+	// If registered is true, vmas in mm may be registered to the UserfaultFD,
+	// and the UserfaultFD is counted by mm.userfaultfds.
+	registered bool
+
+	// released is true if the UserfaultFD has been released.
+	released bool
+
+	// faults contains faults waiting to be resolved, in the order they
+	// occurred. Tasks blocked on faults are interrupted before save, and
+	// retry their faults after restore.
+	faults []*userfault `state:"nosave"`
+}
+
+var _ vfs.FileDescriptionImpl = (*UserfaultFD)(nil)
+
+// userfault is a fault waiting to be resolved by a UserfaultFD.
+type userfault struct {
+	// addr is the page-aligned faulting address.
+	addr hostarch.Addr
+
+	// flags is a mask of linux.UFFD_PAGEFAULT_FLAG_* describing the fault.
+	flags uint64
+
+	// read is true if the fault has been returned by read(2).
+	read bool
+
+	// done is closed when the fault is resolved.
+	done chan struct{}
+}
+
+// NewUserfaultFD returns a new userfaultfd that handles faults in mm. flags
+// may contain linux.O_NONBLOCK.
+func NewUserfaultFD(ctx context.Context, vfsObj *vfs.VirtualFilesystem, mm *MemoryManager, flags uint32, userModeOnly bool) (*vfs.FileDescription, error) {
+	vd := vfsObj.NewAnonVirtualDentry("[userfaultfd]")
+	defer vd.DecRef(ctx)
+	fd := &UserfaultFD{
+		mm:           mm,
+		userModeOnly: userModeOnly,
+	}
+	if err := fd.vfsfd.Init(fd, linux.O_RDONLY|flags, vd.Mount(), vd.Dentry(), &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+		DenyPRead:         true,
+		DenyPWrite:        true,
+		DenySpliceIn:      true,
+	}); err != nil {
+		return nil, err
+	}
+	return &fd.vfsfd, nil
+}
+
+// Release implements vfs.FileDescriptionImpl.Release.
+func (fd *UserfaultFD) Release(ctx context.Context) {
+	fd.mu.Lock()
+	fd.released = true
+	faults := fd.faults
+	fd.faults = nil
+	registered := fd.registered
+	fd.registered = false
+	fd.mu.Unlock()
+
+	// Woken tasks retry their faults, which are no longer delivered to fd
+	// since references can't be taken on it.
+	for _, f := range faults {
+		close(f.done)
+	}
+	if registered {
+		fd.mm.unregisterUserfaultFD(fd, fd.mm.applicationAddrRange())
+		fd.mm.userfaultfds.Add(-1)
+	}
+}
+
+// Read implements vfs.FileDescriptionImpl.Read.
+func (fd *UserfaultFD) Read(ctx context.Context, dst usermem.IOSequence, _ vfs.ReadOptions) (int64, error) {
+	msgSize := (*linux.UffdMsg)(nil).SizeBytes()
+	if dst.NumBytes() < int64(msgSize) {
+		return 0, linuxerr.EINVAL
+	}
+
+	fd.mu.Lock()
+	if !fd.initialized {
+		fd.mu.Unlock()
+		return 0, linuxerr.EINVAL
+	}
+	var buf []byte
+	for _, f := range fd.faults {
+		if int64(len(buf)+msgSize) > dst.NumBytes() {
+			break
+		}
+		if f.read {
+			continue
+		}
+		f.read = true
+		msg := linux.UffdMsg{
+			Event:   linux.UFFD_EVENT_PAGEFAULT,
+			Flags:   f.flags,
+			Address: uint64(f.addr),
+		}
+		buf = append(buf, make([]byte, msgSize)...)
+		msg.MarshalBytes(buf[len(buf)-msgSize:])
+	}
+	fd.mu.Unlock()
+
+	if len(buf) == 0 {
+		return 0, linuxerr.ErrWouldBlock
+	}
+	n, err := dst.CopyOut(ctx, buf)
+	return int64(n), err
+}
+
+// Readiness implements waiter.Waitable.Readiness.
+func (fd *UserfaultFD) Readiness(mask waiter.EventMask) waiter.EventMask {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	for _, f := range fd.faults {
+		if !f.read {
+			return mask & waiter.ReadableEvents
+		}
+	}
+	return 0
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (fd *UserfaultFD) EventRegister(e *waiter.Entry) error {
+	fd.queue.EventRegister(e)
+	return nil
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (fd *UserfaultFD) EventUnregister(e *waiter.Entry) {
+	fd.queue.EventUnregister(e)
+}
+
+// Epollable implements vfs.FileDescriptionImpl.Epollable.
+func (fd *UserfaultFD) Epollable() bool {
+	return true
+}
+
+// Ioctl implements vfs.FileDescriptionImpl.Ioctl.
+func (fd *UserfaultFD) Ioctl(ctx context.Context, uio usermem.IO, sysno uintptr, args arch.SyscallArguments) (uintptr, error) {
+	cc := &usermem.IOCopyContext{
+		Ctx: ctx,
+		IO:  uio,
+		Opts: usermem.IOOpts{
+			AddressSpaceActive: true,
+		},
+	}
+	cmd := args[1].Uint()
+	argPtr := args[2].Pointer()
+	if cmd == linux.UFFDIO_API {
+		return 0, fd.api(cc, argPtr)
+	}
+
+	fd.mu.Lock()
+	initialized := fd.initialized
+	fd.mu.Unlock()
+	if !initialized {
+		return 0, linuxerr.EINVAL
+	}
+	switch cmd {
+	case linux.UFFDIO_REGISTER:
+		return 0, fd.register(cc, argPtr)
+	case linux.UFFDIO_UNREGISTER:
+		return 0, fd.unregister(cc, argPtr)
+	case linux.UFFDIO_WAKE:
+		return 0, fd.wakeIoctl(cc, argPtr)
+	case linux.UFFDIO_COPY:
+		return 0, fd.copy(cc, argPtr)
+	case linux.UFFDIO_ZEROPAGE:
+		return 0, fd.zeropage(cc, argPtr)
+	case linux.UFFDIO_WRITEPROTECT:
+		return 0, fd.writeProtect(cc, argPtr)
+	default:
+		return 0, linuxerr.EINVAL
+	}
+}
+
+// api handles UFFDIO_API.
+func (fd *UserfaultFD) api(cc *usermem.IOCopyContext, addr hostarch.Addr) error {
+	var api linux.UffdioAPI
+	if _, err := api.CopyIn(cc, addr); err != nil {
+		return err
+	}
+	fd.mu.Lock()
+	if fd.initialized || api.API != linux.UFFD_API || api.Features&^userfaultFeatures != 0 {
+		fd.mu.Unlock()
+		// Linux zeroes the struct on failure.
+		var zero linux.UffdioAPI
+		if _, err := zero.CopyOut(cc, addr); err != nil {
+			return err
+		}
+		return linuxerr.EINVAL
+	}
+	fd.initialized = true
+	fd.features = api.Features
+	fd.mu.Unlock()
+
+	api.Features = userfaultFeatures
+	api.Ioctls = linux.UFFD_API_IOCTLS
+	_, err := api.CopyOut(cc, addr)
+	return err
+}
+
+// register handles UFFDIO_REGISTER.
+func (fd *UserfaultFD) register(cc *usermem.IOCopyContext, addr hostarch.Addr) error {
+	var reg linux.UffdioRegister
+	if _, err := reg.CopyIn(cc, addr); err != nil {
+		return err
+	}
+	if reg.Mode == 0 || reg.Mode&^userfaultRegisterModes != 0 {
+		return linuxerr.EINVAL
+	}
+	ar, err := fd.mm.userfaultRange(reg.Range)
+	if err != nil {
+		return err
+	}
+	if !fd.mm.IncUsers() {
+		return linuxerr.EFAULT
+	}
+	defer fd.mm.DecUsers(cc.Ctx)
+	if err := fd.mm.registerUserfaultFD(fd, ar, reg.Mode); err != nil {
+		return err
+	}
+
+	reg.Ioctls = 1<<linux.UFFDIO_WAKE_NR | 1<<linux.UFFDIO_COPY_NR | 1<<linux.UFFDIO_ZEROPAGE_NR
+	if reg.Mode&linux.UFFDIO_REGISTER_MODE_WP != 0 {
+		reg.Ioctls |= 1 << linux.UFFDIO_WRITEPROTECT_NR
+	}
+	_, err = reg.CopyOut(cc, addr)
+	return err
+}
+
+// unregister handles UFFDIO_UNREGISTER.
+func (fd *UserfaultFD) unregister(cc *usermem.IOCopyContext, addr hostarch.Addr) error {
+	var r linux.UffdioRange
+	if _, err := r.CopyIn(cc, addr); err != nil {
+		return err
+	}
+	ar, err := fd.mm.userfaultRange(r)
+	if err != nil {
+		return err
+	}
+	if !fd.mm.IncUsers() {
+		return linuxerr.EFAULT
+	}
+	defer fd.mm.DecUsers(cc.Ctx)
+	fd.mm.unregisterUserfaultFD(fd, ar)
+	fd.wake(ar)
+	return nil
+}
+
+// wakeIoctl handles UFFDIO_WAKE.
+func (fd *UserfaultFD) wakeIoctl(cc *usermem.IOCopyContext, addr hostarch.Addr) error {
+	var r linux.UffdioRange
+	if _, err := r.CopyIn(cc, addr); err != nil {
+		return err
+	}
+	ar, err := fd.mm.userfaultRange(r)
+	if err != nil {
+		return err
+	}
+	fd.wake(ar)
+	return nil
+}
+
+// copy handles UFFDIO_COPY.
+func (fd *UserfaultFD) copy(cc *usermem.IOCopyContext, addr hostarch.Addr) error {
+	var cp linux.UffdioCopy
+	if _, err := cp.CopyIn(cc, addr); err != nil {
+		return err
+	}
+	if cp.Mode&^(linux.UFFDIO_COPY_MODE_DONTWAKE|linux.UFFDIO_COPY_MODE_WP) != 0 {
+		return linuxerr.EINVAL
+	}
+	ar, err := fd.mm.userfaultRange(linux.UffdioRange{Start: cp.Dst, Len: cp.Len})
+	if err != nil {
+		return err
+	}
+	if cp.Src+cp.Len <= cp.Src {
+		return linuxerr.EINVAL
+	}
+	srcAR := hostarch.AddrRange{hostarch.Addr(cp.Src), hostarch.Addr(cp.Src + cp.Len)}
+	if srcAR.Overlaps(ar) {
+		return linuxerr.EINVAL
+	}
+
+	n, err := fd.fill(cc, ar, hostarch.Addr(cp.Src), false /* zero */, cp.Mode&linux.UFFDIO_COPY_MODE_WP != 0)
+	cp.Copy = n
+	if n == 0 && err != nil {
+		cp.Copy = -int64(syserr.FromError(err).ToLinux())
+	}
+	if _, cerr := cp.CopyOut(cc, addr); cerr != nil {
+		return cerr
+	}
+	if n != 0 && cp.Mode&linux.UFFDIO_COPY_MODE_DONTWAKE == 0 {
+		fd.wake(hostarch.AddrRange{ar.Start, ar.Start + hostarch.Addr(n)})
+	}
+	if err == nil && uint64(n) < cp.Len {
+		err = linuxerr.EAGAIN
+	}
+	return err
+}
+
+// zeropage handles UFFDIO_ZEROPAGE.
+func (fd *UserfaultFD) zeropage(cc *usermem.IOCopyContext, addr hostarch.Addr) error {
+	var zp linux.UffdioZeropage
+	if _, err := zp.CopyIn(cc, addr); err != nil {
+		return err
+	}
+	if zp.Mode&^linux.UFFDIO_ZEROPAGE_MODE_DONTWAKE != 0 {
+		return linuxerr.EINVAL
+	}
+	ar, err := fd.mm.userfaultRange(zp.Range)
+	if err != nil {
+		return err
+	}
+
+	n, err := fd.fill(cc, ar, 0, true /* zero */, false /* wp */)
+	zp.Zeropage = n
+	if n == 0 && err != nil {
+		zp.Zeropage = -int64(syserr.FromError(err).ToLinux())
+	}
+	if _, cerr := zp.CopyOut(cc, addr); cerr != nil {
+		return cerr
+	}
+	if n != 0 && zp.Mode&linux.UFFDIO_ZEROPAGE_MODE_DONTWAKE == 0 {
+		fd.wake(hostarch.AddrRange{ar.Start, ar.Start + hostarch.Addr(n)})
+	}
+	if err == nil && uint64(n) < zp.Range.Len {
+		err = linuxerr.EAGAIN
+	}
+	return err
+}
+
+// fill installs pages in ar, which must be missing in fd.mm. If zero is
+// true, the installed pages are zero-filled; otherwise their contents are
+// copied from src in the caller's address space. If wp is true, the installed
+// pages are write-protected. fill returns the number of bytes filled.
+func (fd *UserfaultFD) fill(cc *usermem.IOCopyContext, ar hostarch.AddrRange, src hostarch.Addr, zero, wp bool) (int64, error) {
+	if !fd.mm.IncUsers() {
+		return 0, linuxerr.ESRCH
+	}
+	defer fd.mm.DecUsers(cc.Ctx)
+
+	var buf []byte
+	if !zero {
+		buf = make([]byte, hostarch.PageSize)
+	}
+	var done int64
+	for addr := ar.Start; addr < ar.End; addr += hostarch.PageSize {
+		if buf != nil {
+			// Copy the source page before locking fd.mm, which may be the
+			// caller's MemoryManager.
+			if _, err := cc.CopyInBytes(src+hostarch.Addr(done), buf); err != nil {
+				return done, err
+			}
+		}
+		if err := fd.mm.fillUserfaultPage(cc.Ctx, fd, addr, buf, wp); err != nil {
+			return done, err
+		}
+		done += hostarch.PageSize
+	}
+	return done, nil
+}
+
+// writeProtect handles UFFDIO_WRITEPROTECT.
+func (fd *UserfaultFD) writeProtect(cc *usermem.IOCopyContext, addr hostarch.Addr) error {
+	var wp linux.UffdioWriteprotect
+	if _, err := wp.CopyIn(cc, addr); err != nil {
+		return err
+	}
+	if wp.Mode&^(linux.UFFDIO_WRITEPROTECT_MODE_WP|linux.UFFDIO_WRITEPROTECT_MODE_DONTWAKE) != 0 {
+		return linuxerr.EINVAL
+	}
+	protect := wp.Mode&linux.UFFDIO_WRITEPROTECT_MODE_WP != 0
+	dontWake := wp.Mode&linux.UFFDIO_WRITEPROTECT_MODE_DONTWAKE != 0
+	if protect && dontWake {
+		return linuxerr.EINVAL
+	}
+	ar, err := fd.mm.userfaultRange(wp.Range)
+	if err != nil {
+		return err
+	}
+	if !fd.mm.IncUsers() {
+		return linuxerr.ENOENT
+	}
+	defer fd.mm.DecUsers(cc.Ctx)
+	if err := fd.mm.writeProtectUserfaultFD(fd, ar, protect); err != nil {
+		return err
+	}
+	if !protect && !dontWake {
+		fd.wake(ar)
+	}
+	return nil
+}
+
+// wake resolves all faults in ar.
+func (fd *UserfaultFD) wake(ar hostarch.AddrRange) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	faults := fd.faults[:0]
+	for _, f := range fd.faults {
+		if ar.Contains(f.addr) {
+			close(f.done)
+			continue
+		}
+		faults = append(faults, f)
+	}
+	for i := len(faults); i < len(fd.faults); i++ {
+		fd.faults[i] = nil
+	}
+	fd.faults = faults
+}
+
+// handleFault delivers a fault at addr to fd, and blocks until it is resolved
+// or ctx is interrupted.
+func (fd *UserfaultFD) handleFault(ctx context.Context, addr hostarch.Addr, flags uint64) error {
+	f := &userfault{
+		addr:  addr,
+		flags: flags,
+		done:  make(chan struct{}),
+	}
+	fd.mu.Lock()
+	if fd.released {
+		fd.mu.Unlock()
+		return nil
+	}
+	fd.faults = append(fd.faults, f)
+	fd.mu.Unlock()
+	fd.queue.Notify(waiter.ReadableEvents)
+
+	if err := ctx.Block(f.done); err != nil {
+		fd.mu.Lock()
+		for i, f2 := range fd.faults {
+			if f2 == f {
+				fd.faults = append(fd.faults[:i], fd.faults[i+1:]...)
+				break
+			}
+		}
+		fd.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// pendingUserfault is a fault that must be resolved by a UserfaultFD before
+// the faulting access can proceed.
+type pendingUserfault struct {
+	fd    *UserfaultFD
+	addr  hostarch.Addr
+	flags uint64
+}
+
+// findUserfaultLocked returns the first fault that an access of type at to ar
+// must deliver to a userfaultfd, if any. If ok is true, the caller must call
+// uf.wait() after unlocking mm.activeMu and mm.mappingMu, then retry the
+// access.
+//
+// Preconditions:
+//   - mm.mappingMu must be locked.
+//   - mm.activeMu must be locked.
+//   - vseg.Range().Contains(ar.Start).
+func (mm *MemoryManager) findUserfaultLocked(vseg vmaIterator, ar hostarch.AddrRange, at hostarch.AccessType) (uf pendingUserfault, ok bool) {
+	if mm.userfaultfds.Load() == 0 {
+		return pendingUserfault{}, false
+	}
+	for ; vseg.Ok() && vseg.Start() < ar.End; vseg = vseg.NextSegment() {
+		vma := vseg.ValuePtr()
+		if vma.uffd == nil {
+			continue
+		}
+		vsegAR := vseg.Range().Intersect(ar)
+		var addr hostarch.Addr
+		var flags uint64
+		found := false
+		if vma.uffdMode&linux.UFFDIO_REGISTER_MODE_MISSING != 0 {
+			for pgap := mm.pmas.LowerBoundGap(vsegAR.Start); pgap.Ok() && pgap.Start() < vsegAR.End; pgap = pgap.NextSegment().NextGap() {
+				if gapAR := pgap.Range().Intersect(vsegAR); gapAR.Length() != 0 {
+					addr = gapAR.Start
+					if at.Write {
+						flags = linux.UFFD_PAGEFAULT_FLAG_WRITE
+					}
+					found = true
+					break
+				}
+			}
+		}
+		if at.Write && vma.uffdMode&linux.UFFDIO_REGISTER_MODE_WP != 0 {
+			for pseg := mm.pmas.LowerBoundSegment(vsegAR.Start); pseg.Ok() && pseg.Start() < vsegAR.End; pseg = pseg.NextSegment() {
+				if found && pseg.Start() >= addr {
+					break
+				}
+				if pseg.ValuePtr().uffdWP {
+					addr = pseg.Range().Intersect(vsegAR).Start
+					flags = linux.UFFD_PAGEFAULT_FLAG_WRITE | linux.UFFD_PAGEFAULT_FLAG_WP
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+		if !vma.uffd.vfsfd.TryIncRef() {
+			// The userfaultfd is being released, and will unregister
+			// itself from vseg.
+			continue
+		}
+		return pendingUserfault{
+			fd:    vma.uffd,
+			addr:  addr.RoundDown(),
+			flags: flags,
+		}, true
+	}
+	return pendingUserfault{}, false
+}
+
+// findVecUserfaultLocked is equivalent to findUserfaultLocked, but for a
+// hostarch.AddrRangeSeq.
+//
+// Preconditions:
+//   - mm.mappingMu must be locked.
+//   - mm.activeMu must be locked.
+//   - vmas must exist for all addresses in ars.
+func (mm *MemoryManager) findVecUserfaultLocked(ars hostarch.AddrRangeSeq, at hostarch.AccessType) (uf pendingUserfault, ok bool) {
+	if mm.userfaultfds.Load() == 0 {
+		return pendingUserfault{}, false
+	}
+	for ; !ars.IsEmpty(); ars = ars.Tail() {
+		ar := ars.Head()
+		if ar.Length() == 0 {
+			continue
+		}
+		if uf, ok := mm.findUserfaultLocked(mm.vmas.FindSegment(ar.Start), ar, at); ok {
+			return uf, true
+		}
+	}
+	return pendingUserfault{}, false
+}
+
+// wait delivers uf to its userfaultfd, and blocks until it is resolved. user
+// is true if the fault was caused by application code.
+//
+// Preconditions: mm.mappingMu and mm.activeMu must be unlocked.
+func (uf *pendingUserfault) wait(ctx context.Context, user bool) error {
+	defer uf.fd.vfsfd.DecRef(ctx)
+	if !user && uf.fd.userModeOnly {
+		return linuxerr.EFAULT
+	}
+	return uf.fd.handleFault(ctx, uf.addr, uf.flags)
+}
+
+// userfaultRange returns the address range described by r, checking it as
+// Linux's mm/userfaultfd.c:validate_range() does.
+func (mm *MemoryManager) userfaultRange(r linux.UffdioRange) (hostarch.AddrRange, error) {
+	start := hostarch.Addr(r.Start)
+	if start.RoundDown() != start || hostarch.Addr(r.Len).RoundDown() != hostarch.Addr(r.Len) || r.Len == 0 {
+		return hostarch.AddrRange{}, linuxerr.EINVAL
+	}
+	ar, ok := start.ToRange(r.Len)
+	if !ok || !mm.applicationAddrRange().IsSupersetOf(ar) {
+		return hostarch.AddrRange{}, linuxerr.ENOMEM
+	}
+	return ar, nil
+}
+
+// registerUserfaultFD registers fd to handle faults of the kinds in mode in
+// all vmas in ar.
+func (mm *MemoryManager) registerUserfaultFD(fd *UserfaultFD, ar hostarch.AddrRange, mode uint64) error {
+	mm.mappingMu.Lock()
+	defer mm.mappingMu.Unlock()
+
+	// Check all vmas before changing any of them.
+	vseg := mm.vmas.LowerBoundSegment(ar.Start)
+	if !vseg.Ok() || vseg.Start() >= ar.End {
+		return linuxerr.EINVAL
+	}
+	for vseg := vseg; vseg.Ok() && vseg.Start() < ar.End; vseg = vseg.NextSegment() {
+		vma := vseg.ValuePtr()
+		if vma.mappable != nil {
+			return linuxerr.EINVAL
+		}
+		if vma.uffd != nil && vma.uffd != fd {
+			return linuxerr.EBUSY
+		}
+	}
+
+	for ; vseg.Ok() && vseg.Start() < ar.End; vseg = vseg.NextSegment() {
+		vseg = mm.vmas.Isolate(vseg, ar)
+		vma := vseg.ValuePtr()
+		vma.uffd = fd
+		vma.uffdMode = mode
+	}
+	mm.vmas.MergeInsideRange(ar)
+	mm.vmas.MergeOutsideRange(ar)
+
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	if !fd.registered {
+		fd.registered = true
+		mm.userfaultfds.Add(1)
+	}
+	return nil
+}
+
+// unregisterUserfaultFD unregisters fd from all vmas in ar, and clears
+// write protection from their pmas.
+func (mm *MemoryManager) unregisterUserfaultFD(fd *UserfaultFD, ar hostarch.AddrRange) {
+	mm.mappingMu.Lock()
+	defer mm.mappingMu.Unlock()
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+
+	for vseg := mm.vmas.LowerBoundSegment(ar.Start); vseg.Ok() && vseg.Start() < ar.End; vseg = vseg.NextSegment() {
+		if vseg.ValuePtr().uffd != fd {
+			continue
+		}
+		vseg = mm.vmas.Isolate(vseg, ar)
+		vma := vseg.ValuePtr()
+		vma.uffd = nil
+		vma.uffdMode = 0
+		mm.setUserfaultWPLocked(vseg, vseg.Range(), false)
+	}
+	mm.vmas.MergeInsideRange(ar)
+	mm.vmas.MergeOutsideRange(ar)
+	mm.pmas.MergeInsideRange(ar)
+	mm.pmas.MergeOutsideRange(ar)
+}
+
+// writeProtectUserfaultFD sets or clears write protection for all pmas in
+// ar, which must be registered to fd for write-protect faults.
+func (mm *MemoryManager) writeProtectUserfaultFD(fd *UserfaultFD, ar hostarch.AddrRange, protect bool) error {
+	mm.mappingMu.RLock()
+	defer mm.mappingMu.RUnlock()
+
+	// Check all vmas before changing any pmas.
+	vseg := mm.vmas.LowerBoundSegment(ar.Start)
+	for addr := ar.Start; addr < ar.End; {
+		if !vseg.Ok() || vseg.Start() > addr {
+			return linuxerr.ENOENT
+		}
+		if vma := vseg.ValuePtr(); vma.uffd != fd || vma.uffdMode&linux.UFFDIO_REGISTER_MODE_WP == 0 {
+			return linuxerr.ENOENT
+		}
+		addr = vseg.End()
+		vseg = vseg.NextSegment()
+	}
+
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+	for vseg := mm.vmas.FindSegment(ar.Start); vseg.Ok() && vseg.Start() < ar.End; vseg = vseg.NextSegment() {
+		mm.setUserfaultWPLocked(vseg, vseg.Range().Intersect(ar), protect)
+	}
+	mm.pmas.MergeInsideRange(ar)
+	mm.pmas.MergeOutsideRange(ar)
+	return nil
+}
+
+// setUserfaultWPLocked sets or clears write protection for all pmas in ar.
+//
+// Preconditions:
+//   - mm.mappingMu must be locked.
+//   - mm.activeMu must be locked for writing.
+//   - vseg.Range().IsSupersetOf(ar).
+func (mm *MemoryManager) setUserfaultWPLocked(vseg vmaIterator, ar hostarch.AddrRange, protect bool) {
+	vma := vseg.ValuePtr()
+	didUnmapAS := false
+	for pseg := mm.pmas.LowerBoundSegment(ar.Start); pseg.Ok() && pseg.Start() < ar.End; pseg = pseg.NextSegment() {
+		if pseg.ValuePtr().uffdWP == protect {
+			continue
+		}
+		pseg = mm.pmas.Isolate(pseg, ar)
+		pma := pseg.ValuePtr()
+		pma.uffdWP = protect
+		if protect {
+			pma.effectivePerms.Write = false
+			if !didUnmapAS {
+				// Unmap all of ar, not just pseg.Range(), to minimize host
+				// syscalls.
+				mm.unmapASLocked(ar)
+				didUnmapAS = true
+			}
+		} else {
+			pma.effectivePerms = vma.effectivePerms.Intersect(pma.translatePerms)
+			if pma.needCOW {
+				pma.effectivePerms.Write = false
+			}
+		}
+	}
+}
+
+// fillUserfaultPage installs a page at addr, which must be registered to fd
+// and missing. If data is not nil, it contains the page's contents; otherwise
+// the page is zero-filled. If wp is true, the page is write-protected.
+func (mm *MemoryManager) fillUserfaultPage(ctx context.Context, fd *UserfaultFD, addr hostarch.Addr, data []byte, wp bool) error {
+	ar := hostarch.AddrRange{addr, addr + hostarch.PageSize}
+	mm.mappingMu.RLock()
+	defer mm.mappingMu.RUnlock()
+	vseg := mm.vmas.FindSegment(addr)
+	if !vseg.Ok() || vseg.ValuePtr().uffd != fd {
+		return linuxerr.ENOENT
+	}
+	if wp && vseg.ValuePtr().uffdMode&linux.UFFDIO_REGISTER_MODE_WP == 0 {
+		return linuxerr.EINVAL
+	}
+
+	mm.activeMu.Lock()
+	defer mm.activeMu.Unlock()
+	if mm.pmas.FindSegment(addr).Ok() {
+		return linuxerr.EEXIST
+	}
+	// Private anonymous pmas are allocated zero-filled, and with permissions
+	// that don't depend on the access type.
+	pseg, _, err := mm.getPMAsLocked(ctx, vseg, ar, hostarch.Read, false /* callerIndirectCommit */)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := pseg.getInternalMappingsLocked(); err != nil {
+			return err
+		}
+		if _, err := safemem.CopySeq(mm.internalMappingsLocked(pseg, ar), safemem.BlockSeqOf(safemem.BlockFromSafeSlice(data))); err != nil {
+			return err
+		}
+	}
+	if wp {
+		mm.setUserfaultWPLocked(vseg, ar, true)
+	}
+	return nil
+}
@@ -471,10 +471,13 @@ func (vmaSetFunctions) Merge(ar1 hostarch.AddrRange, vma1 vma, ar2 hostarch.Addr
 		vma1.mlockMode != vma2.mlockMode ||
 		vma1.numaPolicy != vma2.numaPolicy ||
 		vma1.numaNodemask != vma2.numaNodemask ||
+		vma1.pkey != vma2.pkey ||
 		vma1.dontfork != vma2.dontfork ||
 		vma1.id != vma2.id ||
 		vma1.name != vma2.name ||
-		vma1.nameMut != vma2.nameMut {
+		vma1.nameMut != vma2.nameMut ||
+		vma1.uffd != vma2.uffd ||
+		vma1.uffdMode != vma2.uffdMode {
 		return vma{}, false
 	}
 
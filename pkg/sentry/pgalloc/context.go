@@ -31,6 +31,9 @@ const (
 	// CtxMemoryFileMap is a Context.Value key for mapping
 	// MemoryFileOpts.RestoreID to *MemoryFile. This is used for save/restore.
 	CtxMemoryFileMap
+
+	// CtxMemoryStallTracker is a Context.Value key for a StallTracker.
+	CtxMemoryStallTracker
 )
 
 // MemoryFileFromContext returns the MemoryFile used by ctx, or nil if no such
@@ -59,3 +62,12 @@ func MemoryFileMapFromContext(ctx context.Context) map[string]*MemoryFile {
 	}
 	return nil
 }
+
+// StallTrackerFromContext returns the StallTracker used by ctx, or nil if no
+// such StallTracker exists.
+func StallTrackerFromContext(ctx context.Context) StallTracker {
+	if v := ctx.Value(CtxMemoryStallTracker); v != nil {
+		return v.(StallTracker)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgalloc
+
+import (
+	"fmt"
+	"math"
+
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/context"
+)
+
+// MemoryCgroupEvents is notified of events in a memory cgroup's
+// MemoryCgroupCounter.
+type MemoryCgroupEvents interface {
+	// OnHigh is called when a charge causes the memory charged to the memory
+	// cgroup to exceed its high limit. OnHigh is called with MemoryFile locks
+	// held, so it must not block or call into the MemoryFile.
+	OnHigh()
+
+	// OnLimit is called when an allocation of length bytes fails because it
+	// would exceed the memory cgroup's limit, and returns the resulting
+	// out-of-memory condition. OnLimit is called without holding MemoryFile
+	// locks, but callers of MemoryFile.Allocate may hold arbitrary locks, so
+	// it must not block.
+	OnLimit(length uint64) MemoryCgroupOOM
+
+	// OnUncharge is called when memory is uncharged from the memory cgroup,
+	// e.g. because it was freed. OnUncharge is called with MemoryFile locks
+	// held, so it must not block or call into the MemoryFile.
+	OnUncharge()
+}
+
+// MemoryCgroupOOM represents an out-of-memory condition in a memory cgroup,
+// which is being handled asynchronously.
+type MemoryCgroupOOM interface {
+	// Wait blocks until the condition has been handled, e.g. by reclaiming
+	// memory or killing a task in the cgroup, or until ctx is interrupted. It
+	// returns true if the failed allocation should be retried.
+	Wait(ctx context.Context) bool
+}
+
+// MemoryCgroupCounter counts the memory charged to a memory cgroup and its
+// descendants, and enforces the memory cgroup's limit. Charges are
+// propagated to the counters of ancestor memory cgroups, and fail if they
+// would exceed the limit of any of them.
+//
+// This is analogous to Linux's struct page_counter.
+type MemoryCgroupCounter struct {
+	// parent is the counter of the parent memory cgroup, or nil if there is
+	// none. parent is immutable.
+	parent *MemoryCgroupCounter
+
+	// events is notified of events in the memory cgroup. events is
+	// immutable.
+	events MemoryCgroupEvents
+
+	// usage is the number of bytes charged to the memory cgroup and its
+	// descendants. usage is only mutated with MemoryFile.mu locked, but may
+	// be read at any time.
+	usage atomicbitops.Uint64
+
+	// limit and high are the memory cgroup's limit and high limit in bytes.
+	limit atomicbitops.Uint64
+	high  atomicbitops.Uint64
+
+	// removed is true if the memory cgroup has been removed from the
+	// MemoryFile by RemoveMemoryCgroupCounter, but memory is still charged
+	// to it. removed is protected by MemoryFile.mu.
+	removed bool
+}
+
+// NewMemoryCgroupCounter returns a new MemoryCgroupCounter with no limits,
+// whose charges are propagated to parent if it is not nil.
+func NewMemoryCgroupCounter(parent *MemoryCgroupCounter, events MemoryCgroupEvents) *MemoryCgroupCounter {
+	c := &MemoryCgroupCounter{
+		parent: parent,
+		events: events,
+	}
+	c.limit.Store(math.MaxUint64)
+	c.high.Store(math.MaxUint64)
+	return c
+}
+
+// Usage returns the number of bytes charged to the memory cgroup and its
+// descendants.
+func (c *MemoryCgroupCounter) Usage() uint64 {
+	return c.usage.Load()
+}
+
+// SetLimit sets the maximum number of bytes that may be charged to the memory
+// cgroup and its descendants. Memory that is already charged is unaffected.
+func (c *MemoryCgroupCounter) SetLimit(limit uint64) {
+	c.limit.Store(limit)
+}
+
+// SetHigh sets the number of bytes above which charges to the memory cgroup
+// and its descendants are reported to MemoryCgroupEvents.OnHigh.
+func (c *MemoryCgroupCounter) SetHigh(high uint64) {
+	c.high.Store(high)
+}
+
+// IsDescendantOf returns true if c is ancestor or a descendant of ancestor.
+func (c *MemoryCgroupCounter) IsDescendantOf(ancestor *MemoryCgroupCounter) bool {
+	for ; c != nil; c = c.parent {
+		if c == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// tryCharge charges length bytes to c and its ancestors. If this would exceed
+// the limit of c or any of its ancestors, tryCharge charges nothing and
+// returns the counter whose limit would be exceeded.
+//
+// This is analogous to Linux's mm/page_counter.c:page_counter_try_charge().
+//
+// Preconditions: MemoryFile.mu must be locked.
+func (c *MemoryCgroupCounter) tryCharge(length uint64) *MemoryCgroupCounter {
+	for cc := c; cc != nil; cc = cc.parent {
+		usage := cc.usage.Add(length)
+		if usage > cc.limit.Load() {
+			// Undo the charges made so far.
+			for uc := c; uc != cc.parent; uc = uc.parent {
+				uc.usage.Add(-length)
+			}
+			return cc
+		}
+		if high := cc.high.Load(); usage > high && usage-length <= high {
+			cc.events.OnHigh()
+		}
+	}
+	return nil
+}
+
+// charge charges length bytes to c and its ancestors, even if this exceeds
+// their limits.
+//
+// Preconditions: MemoryFile.mu must be locked.
+func (c *MemoryCgroupCounter) charge(length uint64) {
+	for cc := c; cc != nil; cc = cc.parent {
+		cc.usage.Add(length)
+	}
+}
+
+// uncharge uncharges length bytes from c and its ancestors.
+//
+// Preconditions: MemoryFile.mu must be locked.
+func (c *MemoryCgroupCounter) uncharge(length uint64) {
+	for cc := c; cc != nil; cc = cc.parent {
+		if usage := cc.usage.Load(); usage < length {
+			panic(fmt.Sprintf("uncharging %d bytes from memory cgroup counter with %d bytes charged", length, usage))
+		}
+		cc.usage.Add(-length)
+		cc.events.OnUncharge()
+	}
+}
+
+// MemoryCgroupLimitError is returned by MemoryFile.Allocate when an
+// allocation would exceed the limit of a memory cgroup.
+type MemoryCgroupLimitError struct {
+	// MemCgID is the memory cgroup to which the allocation would have been
+	// charged.
+	MemCgID uint32
+
+	// OOM is the out-of-memory condition caused by the allocation.
+	OOM MemoryCgroupOOM
+
+	// counter is the counter whose limit would have been exceeded, which may
+	// belong to an ancestor of MemCgID.
+	counter *MemoryCgroupCounter
+}
+
+// Error implements error.Error.
+func (e *MemoryCgroupLimitError) Error() string {
+	return fmt.Sprintf("memory cgroup %d limit exceeded", e.MemCgID)
+}
+
+// SetMemoryCgroupCounter sets the MemoryCgroupCounter charged for allocations
+// accounted to the memory cgroup memCgID. Memory that is already allocated
+// and accounted to memCgID is charged to c.
+func (f *MemoryFile) SetMemoryCgroupCounter(memCgID uint32, c *MemoryCgroupCounter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if old := f.memCgCounters[memCgID]; old != nil {
+		panic(fmt.Sprintf("memory cgroup %d already has a counter", memCgID))
+	}
+	f.memCgCounters[memCgID] = c
+	c.charge(f.memCgCharged[memCgID])
+}
+
+// RemoveMemoryCgroupCounter indicates that the memory cgroup memCgID has been
+// destroyed. Memory that remains charged to it continues to be charged to its
+// ancestors until it is freed.
+func (f *MemoryFile) RemoveMemoryCgroupCounter(memCgID uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.memCgCounters[memCgID]
+	if !ok {
+		return
+	}
+	if f.memCgCharged[memCgID] == 0 {
+		delete(f.memCgCounters, memCgID)
+		return
+	}
+	c.removed = true
+}
+
+// Preconditions: f.mu must be locked.
+func (f *MemoryFile) chargeMemoryCgroupLocked(memCgID uint32, length uint64) error {
+	if memCgID == 0 {
+		return nil
+	}
+	if c := f.memCgCounters[memCgID]; c != nil {
+		if over := c.tryCharge(length); over != nil {
+			return &MemoryCgroupLimitError{
+				MemCgID: memCgID,
+				counter: over,
+			}
+		}
+	}
+	f.memCgCharged[memCgID] += length
+	return nil
+}
+
+// Preconditions: f.mu must be locked.
+func (f *MemoryFile) unchargeMemoryCgroupLocked(memCgID uint32, length uint64) {
+	if memCgID == 0 {
+		return
+	}
+	charged := f.memCgCharged[memCgID]
+	if charged < length {
+		panic(fmt.Sprintf("uncharging %d bytes from memory cgroup %d with %d bytes charged", length, memCgID, charged))
+	}
+	c := f.memCgCounters[memCgID]
+	if c != nil {
+		c.uncharge(length)
+	}
+	if charged == length {
+		delete(f.memCgCharged, memCgID)
+		if c != nil && c.removed {
+			delete(f.memCgCounters, memCgID)
+		}
+		return
+	}
+	f.memCgCharged[memCgID] = charged - length
+}
+
+// resetMemoryCgroupChargesLocked recomputes memory cgroup charges from
+// f.memAcct, after it has been loaded.
+//
+// Preconditions: f.mu must be locked.
+func (f *MemoryFile) resetMemoryCgroupChargesLocked() {
+	clear(f.memCgCharged)
+	for maseg := f.memAcct.FirstSegment(); maseg.Ok(); maseg = maseg.NextSegment() {
+		if ma := maseg.ValuePtr(); !ma.wasteOrReleasing && ma.memCgID != 0 {
+			f.memCgCharged[ma.memCgID] += maseg.Range().Length()
+		}
+	}
+	// Counters may have been set before f.memAcct was loaded.
+	for _, c := range f.memCgCounters {
+		c.usage.Store(0)
+	}
+	for memCgID, c := range f.memCgCounters {
+		c.charge(f.memCgCharged[memCgID])
+	}
+}
@@ -164,6 +164,16 @@ type MemoryFile struct {
 	nextCommitScan      time.Time
 	isSaving            uint
 
+	// memCgCharged maps memory cgroup IDs to the number of bytes allocated
+	// and charged to them, whether or not those bytes are committed.
+	//
+	// memCgCounters maps memory cgroup IDs to the MemoryCgroupCounters that
+	// are charged for allocations accounted to them.
+	//
+	// memCgCharged and memCgCounters are protected by mu.
+	memCgCharged  map[uint32]uint64
+	memCgCounters map[uint32]*MemoryCgroupCounter
+
 	// evictable maps EvictableMemoryUsers to eviction state.
 	//
 	// evictable is protected by mu.
@@ -327,6 +337,10 @@ type evictableMemoryUserInfo struct {
 	// ranges tracks all evictable ranges for the given user.
 	ranges evictableRangeSet
 
+	// memCgIDs is the set of memory cgroups charged for the memory used by
+	// evictable ranges, as reported by MemoryFile.MarkEvictable.
+	memCgIDs map[uint32]struct{}
+
 	// If evicting is true, there is a goroutine currently evicting all
 	// evictable ranges for this user.
 	evicting bool
@@ -467,6 +481,8 @@ func (f *MemoryFile) initFields() {
 	f.unfreeSmall.InsertRange(fullFR, unfreeInfo{})
 	f.unfreeHuge.InsertRange(fullFR, unfreeInfo{})
 	f.subreleased = make(map[uint64]uint64)
+	f.memCgCharged = make(map[uint32]uint64)
+	f.memCgCounters = make(map[uint32]*MemoryCgroupCounter)
 	f.evictable = make(map[EvictableMemoryUser]*evictableMemoryUserInfo)
 	chunks := []chunkInfo(nil)
 	f.chunks.Store(&chunks)
@@ -572,6 +588,21 @@ type AllocOpts struct {
 	// page. If this is shorter than length bytes due to an error returned by
 	// ReaderFunc, it returns the partially filled fr and error.
 	ReaderFunc safemem.ReaderFunc
+
+	// If StallTracker is provided, it is notified while the allocation is in
+	// progress.
+	StallTracker StallTracker
+}
+
+// StallTracker is notified of memory allocations in progress, so that
+// allocations that take long, e.g. because the host must reclaim memory to
+// satisfy them, can be accounted as memory stalls of the allocating task.
+type StallTracker interface {
+	// MemoryStallStart is called when an allocation starts.
+	MemoryStallStart()
+
+	// MemoryStallFinish is called when an allocation ends.
+	MemoryStallFinish()
 }
 
 // Direction is the type of AllocOpts.Dir.
@@ -651,8 +682,18 @@ func (f *MemoryFile) Allocate(length uint64, opts AllocOpts) (memmap.FileRange,
 		huge:       opts.Huge && f.opts.ExpectHugepages,
 	}
 
+	if opts.StallTracker != nil {
+		opts.StallTracker.MemoryStallStart()
+		defer opts.StallTracker.MemoryStallFinish()
+	}
+
 	fr, err := f.findAllocatableAndMarkUsed(&alloc)
 	if err != nil {
+		if limitErr, ok := err.(*MemoryCgroupLimitError); ok {
+			// Start handling the out-of-memory condition without holding
+			// f.mu.
+			limitErr.OOM = limitErr.counter.events.OnLimit(length)
+		}
 		return fr, err
 	}
 
@@ -776,6 +817,12 @@ func (f *MemoryFile) findAllocatableAndMarkUsed(alloc *allocState) (fr memmap.Fi
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	// Charge the allocation to its memory cgroup before allocating, so that
+	// concurrent allocations can't exceed the memory cgroup's limit.
+	if err = f.chargeMemoryCgroupLocked(alloc.opts.MemCgID, alloc.length); err != nil {
+		return
+	}
+
 	if alloc.willCommit {
 		// Try to recycle waste pages, since this avoids the overhead of
 		// decommitting and then committing them again.
@@ -847,6 +894,7 @@ retryFree:
 		// Extend the file to create more chunks.
 		err = f.extendChunksLocked(alloc)
 		if err != nil {
+			f.unchargeMemoryCgroupLocked(alloc.opts.MemCgID, alloc.length)
 			return
 		}
 		// Retry the allocation using new chunks.
@@ -1228,6 +1276,9 @@ func (f *MemoryFile) DecRef(fr memmap.FileRange) {
 					if !f.opts.DisableMemoryAccounting && ma.knownCommitted {
 						usage.MemoryAccounting.Move(maseg.Range().Length(), usage.System, ma.kind, ma.memCgID)
 					}
+					if !ma.wasteOrReleasing {
+						f.unchargeMemoryCgroupLocked(ma.memCgID, maseg.Range().Length())
+					}
 					ma.kind = usage.System
 					ma.wasteOrReleasing = true
 					return true
@@ -1467,17 +1518,25 @@ func (f *MemoryFile) forEachMappingSlice(fr memmap.FileRange, fn func([]byte)) {
 }
 
 // MarkEvictable allows f to request memory deallocation by calling
-// user.Evict(er) in the future.
+// user.Evict(er) in the future. memCgID is the memory cgroup charged for the
+// memory used by er, or 0 if it is unknown; evictions requested by
+// StartMemoryCgroupEvictions are limited to users marked evictable with a
+// matching memCgID.
 //
 // Redundantly marking an already-evictable range as evictable has no effect.
-func (f *MemoryFile) MarkEvictable(user EvictableMemoryUser, er EvictableRange) {
+func (f *MemoryFile) MarkEvictable(user EvictableMemoryUser, er EvictableRange, memCgID uint32) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	info, ok := f.evictable[user]
 	if !ok {
-		info = &evictableMemoryUserInfo{}
+		info = &evictableMemoryUserInfo{
+			memCgIDs: make(map[uint32]struct{}),
+		}
 		f.evictable[user] = info
 	}
+	if memCgID != 0 {
+		info.memCgIDs[memCgID] = struct{}{}
+	}
 	gap := info.ranges.LowerBoundGap(er.Start)
 	for gap.Ok() && gap.Start() < er.End {
 		gapER := gap.Range().Intersect(er)
@@ -1857,6 +1916,25 @@ func (f *MemoryFile) StartEvictions() {
 	f.startEvictionsLocked()
 }
 
+// StartMemoryCgroupEvictions requests that f evict evictable allocations
+// charged to the memory cgroup whose counter is c, or to its descendants. Like
+// StartEvictions, it does not wait for eviction to complete.
+func (f *MemoryFile) StartMemoryCgroupEvictions(c *MemoryCgroupCounter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for user, info := range f.evictable {
+		if info.evicting {
+			continue
+		}
+		for memCgID := range info.memCgIDs {
+			if f.memCgCounters[memCgID].IsDescendantOf(c) {
+				f.startEvictionGoroutineLocked(user, info)
+				break
+			}
+		}
+	}
+}
+
 // Preconditions: f.mu must be locked.
 func (f *MemoryFile) startEvictionsLocked() bool {
 	startedAny := false
@@ -583,3 +583,188 @@ func TestFindAllocatable(t *testing.T) {
 		})
 	}
 }
+
+// newTestMemoryCgroupMemoryFile returns a fake MemoryFile with a single
+// small-page chunk.
+func newTestMemoryCgroupMemoryFile() *MemoryFile {
+	f := &MemoryFile{
+		opts: MemoryFileOpts{
+			DisableMemoryAccounting: true,
+		},
+	}
+	f.initFields()
+	chunks := []chunkInfo{{}}
+	f.unfreeSmall.RemoveRange(memmap.FileRange{0, chunkSize})
+	f.chunks.Store(&chunks)
+	return f
+}
+
+func TestMemoryCgroupCharges(t *testing.T) {
+	f := newTestMemoryCgroupMemoryFile()
+
+	allocate := func(length uint64, memCgID uint32, recycle bool) memmap.FileRange {
+		t.Helper()
+		alloc := allocState{
+			length: length,
+			opts: AllocOpts{
+				MemCgID: memCgID,
+			},
+		}
+		if recycle {
+			alloc.opts.Mode = AllocateCallerIndirectCommit
+			alloc.willCommit = true
+		}
+		fr, err := f.findAllocatableAndMarkUsed(&alloc)
+		if err != nil {
+			t.Fatalf("findAllocatableAndMarkUsed(%+v): failed: %v\n%v", alloc, err, f)
+		}
+		return fr
+	}
+	checkCharged := func(memCgID uint32, want uint64) {
+		t.Helper()
+		if got := f.memCgCharged[memCgID]; got != want {
+			t.Errorf("memory cgroup %d charged: got %#x, want %#x\n%v", memCgID, got, want, f)
+		}
+	}
+
+	fr1 := allocate(2*page, 1, false /* recycle */)
+	allocate(page, 2, false /* recycle */)
+	allocate(page, 0, false /* recycle */)
+	checkCharged(1, 2*page)
+	checkCharged(2, page)
+	checkCharged(0, 0)
+
+	// An additional reference doesn't change the charge, and neither does
+	// dropping it.
+	f.IncRef(fr1, 1)
+	f.DecRef(fr1)
+	checkCharged(1, 2*page)
+
+	// Freed pages are uncharged, and recycled waste pages are charged to the
+	// memory cgroup of the new allocation.
+	f.DecRef(fr1)
+	checkCharged(1, 0)
+	if fr := allocate(page, 3, true /* recycle */); fr.Start != fr1.Start {
+		t.Errorf("recycling allocation: got start=%#x, want %#x\n%v", fr.Start, fr1.Start, f)
+	}
+	checkCharged(1, 0)
+	checkCharged(3, page)
+
+	// Charges can be recomputed from memAcct, e.g. after restore, including
+	// those of counters that were set before memAcct was loaded.
+	c := NewMemoryCgroupCounter(nil, &testMemoryCgroupEvents{})
+	f.SetMemoryCgroupCounter(2, c)
+	f.mu.Lock()
+	f.resetMemoryCgroupChargesLocked()
+	f.mu.Unlock()
+	checkCharged(2, page)
+	checkCharged(3, page)
+	if got, want := c.Usage(), uint64(page); got != want {
+		t.Errorf("counter usage after reset: got %#x, want %#x", got, want)
+	}
+}
+
+type testMemoryCgroupEvents struct {
+	high     int
+	limit    int
+	uncharge int
+}
+
+// OnHigh implements MemoryCgroupEvents.OnHigh.
+func (e *testMemoryCgroupEvents) OnHigh() {
+	e.high++
+}
+
+// OnLimit implements MemoryCgroupEvents.OnLimit.
+func (e *testMemoryCgroupEvents) OnLimit(length uint64) MemoryCgroupOOM {
+	e.limit++
+	return nil
+}
+
+// OnUncharge implements MemoryCgroupEvents.OnUncharge.
+func (e *testMemoryCgroupEvents) OnUncharge() {
+	e.uncharge++
+}
+
+func TestMemoryCgroupCounters(t *testing.T) {
+	f := newTestMemoryCgroupMemoryFile()
+
+	// Memory cgroup 2 is the parent of memory cgroup 1.
+	parentEvents := &testMemoryCgroupEvents{}
+	parent := NewMemoryCgroupCounter(nil, parentEvents)
+	parent.SetLimit(3 * page)
+	childEvents := &testMemoryCgroupEvents{}
+	child := NewMemoryCgroupCounter(parent, childEvents)
+	child.SetHigh(page)
+
+	// Memory allocated before a counter is set is charged to it.
+	allocate := func(length uint64, memCgID uint32) (memmap.FileRange, error) {
+		return f.findAllocatableAndMarkUsed(&allocState{
+			length: length,
+			opts: AllocOpts{
+				MemCgID: memCgID,
+			},
+		})
+	}
+	fr1, err := allocate(page, 1)
+	if err != nil {
+		t.Fatalf("allocation without counter: got %v, want nil", err)
+	}
+	f.SetMemoryCgroupCounter(2, parent)
+	f.SetMemoryCgroupCounter(1, child)
+	checkUsage := func(c *MemoryCgroupCounter, want uint64) {
+		t.Helper()
+		if got := c.Usage(); got != want {
+			t.Errorf("counter usage: got %#x, want %#x", got, want)
+		}
+	}
+	checkUsage(child, page)
+	checkUsage(parent, page)
+
+	// Exceeding the child's high limit is reported, but doesn't fail.
+	fr2, err := allocate(page, 1)
+	if err != nil {
+		t.Fatalf("allocation above high limit: got %v, want nil", err)
+	}
+	checkUsage(child, 2*page)
+	checkUsage(parent, 2*page)
+	if childEvents.high != 1 || parentEvents.high != 0 {
+		t.Errorf("high events: got child %d, parent %d, want 1, 0", childEvents.high, parentEvents.high)
+	}
+
+	// Charges to the child are limited by the parent's limit, and failed
+	// charges are rolled back.
+	_, err = allocate(2*page, 1)
+	if limitErr, ok := err.(*MemoryCgroupLimitError); !ok || limitErr.MemCgID != 1 || limitErr.counter != parent {
+		t.Errorf("allocation above parent limit: got %v, want *MemoryCgroupLimitError for parent counter", err)
+	}
+	checkUsage(child, 2*page)
+	checkUsage(parent, 2*page)
+
+	// Allocations that aren't accounted to a memory cgroup aren't limited.
+	if _, err := allocate(4*page, 0); err != nil {
+		t.Errorf("allocation without memory cgroup: got %v, want nil", err)
+	}
+
+	// Freed memory is uncharged from the child and its ancestors.
+	f.DecRef(fr1)
+	checkUsage(child, page)
+	checkUsage(parent, page)
+	if childEvents.uncharge != 1 || parentEvents.uncharge != 1 {
+		t.Errorf("uncharge events: got child %d, parent %d, want 1, 1", childEvents.uncharge, parentEvents.uncharge)
+	}
+	if _, err := allocate(2*page, 2); err != nil {
+		t.Errorf("allocation after free: got %v, want nil", err)
+	}
+	checkUsage(parent, 3*page)
+
+	// Memory charged to a removed memory cgroup remains charged to its
+	// ancestors until it is freed.
+	f.RemoveMemoryCgroupCounter(1)
+	checkUsage(parent, 3*page)
+	f.DecRef(fr2)
+	checkUsage(parent, 2*page)
+	if _, ok := f.memCgCounters[1]; ok {
+		t.Errorf("counter for removed memory cgroup 1 still set after its memory was freed")
+	}
+}
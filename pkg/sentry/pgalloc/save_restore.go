@@ -298,6 +298,11 @@ func (f *MemoryFile) LoadFrom(ctx context.Context, r io.Reader, opts *LoadOpts)
 	if _, err := state.Load(ctx, r, &f.memAcct); err != nil {
 		return err
 	}
+	// Memory cgroup counters may be registered concurrently by kernel
+	// restore.
+	f.mu.Lock()
+	f.resetMemoryCgroupChargesLocked()
+	f.mu.Unlock()
 	if _, err := state.Load(ctx, r, &f.knownCommittedBytes); err != nil {
 		return err
 	}
@@ -27,6 +27,7 @@ import (
 	"gvisor.dev/gvisor/pkg/marshal"
 	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
 	"gvisor.dev/gvisor/pkg/tcpip"
 )
 
@@ -113,6 +114,46 @@ func getInterfaceAddrs() (map[int32][]inet.InterfaceAddr, error) {
 	return addrs, nil
 }
 
+func getNeighbors() (map[int32][]inet.Neighbor, error) {
+	data, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	neighs := make(map[int32][]inet.Neighbor)
+	for _, msg := range msgs {
+		if msg.Header.Type != unix.RTM_NEWNEIGH {
+			continue
+		}
+		if len(msg.Data) < linux.SizeOfNeighborMessage {
+			return nil, fmt.Errorf("RTM_GETNEIGH returned RTM_NEWNEIGH message with invalid data length (%d bytes, expected at least %d bytes)", len(msg.Data), linux.SizeOfNeighborMessage)
+		}
+		var ndmsg linux.NeighborMessage
+		ndmsg.UnmarshalUnsafe(msg.Data)
+		neigh := inet.Neighbor{
+			Family: ndmsg.Family,
+			State:  ndmsg.State,
+			Flags:  ndmsg.Flags,
+		}
+		// syscall.ParseNetlinkRouteAttr doesn't support neighbor messages.
+		attrs, ok := nlmsg.AttrsView(msg.Data[linux.SizeOfNeighborMessage:]).Parse()
+		if !ok {
+			return nil, fmt.Errorf("RTM_GETNEIGH returned RTM_NEWNEIGH message with invalid attributes")
+		}
+		if v, ok := attrs[linux.NDA_DST]; ok {
+			neigh.Addr = []byte(v)
+		}
+		if v, ok := attrs[linux.NDA_LLADDR]; ok {
+			neigh.LinkAddr = []byte(v)
+		}
+		neighs[ndmsg.Index] = append(neighs[ndmsg.Index], neigh)
+	}
+	return neighs, nil
+}
+
 func getRoutes() ([]inet.Route, error) {
 	data, err := syscall.NetlinkRIB(unix.RTM_GETROUTE, syscall.AF_UNSPEC)
 	if err != nil {
@@ -146,7 +187,7 @@ func extractRoutes(routeMsgs []syscall.NetlinkMessage) ([]inet.Route, error) {
 			DstLen:   ifRoute.DstLen,
 			SrcLen:   ifRoute.SrcLen,
 			TOS:      ifRoute.TOS,
-			Table:    ifRoute.Table,
+			Table:    uint32(ifRoute.Table),
 			Protocol: ifRoute.Protocol,
 			Scope:    ifRoute.Scope,
 			Type:     ifRoute.Type,
@@ -177,6 +218,14 @@ func extractRoutes(routeMsgs []syscall.NetlinkMessage) ([]inet.Route, error) {
 				var outputIF primitive.Int32
 				outputIF.UnmarshalUnsafe(attr.Value)
 				inetRoute.OutputInterface = int32(outputIF)
+			case unix.RTA_TABLE:
+				if len(attr.Value) == 4 {
+					inetRoute.Table = hostarch.ByteOrder.Uint32(attr.Value)
+				}
+			case unix.RTA_PRIORITY:
+				if len(attr.Value) == 4 {
+					inetRoute.Priority = hostarch.ByteOrder.Uint32(attr.Value)
+				}
 			}
 		}
 
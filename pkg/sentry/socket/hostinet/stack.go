@@ -166,6 +166,11 @@ func (s *Stack) InterfaceAddrs() map[int32][]inet.InterfaceAddr {
 	return addrs
 }
 
+// MulticastGroups implements inet.Stack.MulticastGroups.
+func (*Stack) MulticastGroups() map[int32][]inet.MulticastGroup {
+	return nil
+}
+
 // SetInterface implements inet.Stack.SetInterface.
 func (s *Stack) SetInterface(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
 	var ifinfomsg linux.InterfaceInfoMessage
@@ -234,6 +239,16 @@ func (*Stack) RemoveInterfaceAddr(idx int32, addr inet.InterfaceAddr) error {
 	return removeInterfaceAddr(idx, addr)
 }
 
+// SetInterfaceMTU implements inet.Stack.SetInterfaceMTU.
+func (*Stack) SetInterfaceMTU(int32, uint32) error {
+	return linuxerr.EACCES
+}
+
+// SetInterfaceTxQueueLen implements inet.Stack.SetInterfaceTxQueueLen.
+func (*Stack) SetInterfaceTxQueueLen(int32, uint32) error {
+	return linuxerr.EACCES
+}
+
 // SupportsIPv6 implements inet.Stack.SupportsIPv6.
 func (s *Stack) SupportsIPv6() bool {
 	return s.supportsIPv6
@@ -279,6 +294,16 @@ func (*Stack) SetTCPRecovery(inet.TCPLossRecovery) error {
 	return linuxerr.EACCES
 }
 
+// Sysctl implements inet.Stack.Sysctl.
+func (*Stack) Sysctl(string) (inet.SysctlValue, error) {
+	return inet.SysctlValue{}, linuxerr.EOPNOTSUPP
+}
+
+// SetSysctl implements inet.Stack.SetSysctl.
+func (*Stack) SetSysctl(string, inet.SysctlValue) error {
+	return linuxerr.EOPNOTSUPP
+}
+
 // getLine reads one line from proc file, with specified prefix.
 // The last argument, withHeader, specifies if it contains line header.
 func getLine(f *os.File, prefix string, withHeader bool) string {
@@ -392,6 +417,41 @@ func (*Stack) RemoveRoute(context.Context, *nlmsg.Message) *syserr.Error {
 	return syserr.ErrNotSupported
 }
 
+// RoutingRules implements inet.Stack.RoutingRules.
+func (*Stack) RoutingRules() []inet.RoutingRule {
+	return nil
+}
+
+// NewRoutingRule implements inet.Stack.NewRoutingRule.
+func (*Stack) NewRoutingRule(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
+// RemoveRoutingRule implements inet.Stack.RemoveRoutingRule.
+func (*Stack) RemoveRoutingRule(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
+// Neighbors implements inet.Stack.Neighbors.
+func (*Stack) Neighbors() map[int32][]inet.Neighbor {
+	neighs, err := getNeighbors()
+	if err != nil {
+		log.Warningf("failed to get neighbors: %v", err)
+		return nil
+	}
+	return neighs
+}
+
+// NewNeighbor implements inet.Stack.NewNeighbor.
+func (*Stack) NewNeighbor(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
+// RemoveNeighbor implements inet.Stack.RemoveNeighbor.
+func (*Stack) RemoveNeighbor(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
 // Pause implements inet.Stack.Pause.
 func (*Stack) Pause() {}
 
@@ -0,0 +1,267 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package genetlink provides a NETLINK_GENERIC socket protocol.
+//
+// Generic netlink multiplexes several families over a single netlink
+// protocol. Each family has a message type that is allocated dynamically and
+// can be resolved from the name of the family with the controller family
+// ("nlctrl"), which has the fixed message type GENL_ID_CTRL.
+package genetlink
+
+import (
+	"fmt"
+	"sort"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
+	"gvisor.dev/gvisor/pkg/syserr"
+)
+
+// Op describes a command of a family.
+type Op struct {
+	// Cmd is the command.
+	Cmd uint8
+
+	// Flags is a combination of GENL_ADMIN_PERM, GENL_UNS_ADMIN_PERM,
+	// GENL_CMD_CAP_DO and GENL_CMD_CAP_DUMP.
+	Flags uint32
+}
+
+// Family is a generic netlink family.
+type Family interface {
+	// Name returns the name of the family.
+	Name() string
+
+	// Version returns the version of the family protocol.
+	Version() uint8
+
+	// MaxAttr returns the greatest attribute type of the family.
+	MaxAttr() uint16
+
+	// Ops returns the commands supported by the family.
+	Ops() []Op
+
+	// ProcessMessage processes a message of the family. The command of the
+	// message has been checked against Ops. attrs are the attributes that
+	// follow the generic netlink header, and dump is true if NLM_F_DUMP is
+	// set.
+	ProcessMessage(ctx context.Context, s *netlink.Socket, id uint16, genHdr linux.GenericNetlinkMessageHeader, attrs nlmsg.AttrsView, dump bool, ms *nlmsg.MessageSet) *syserr.Error
+}
+
+// firstFamilyID is the first dynamically allocated family ID. Like Linux, IDs
+// below it are reserved for families with fixed IDs.
+const firstFamilyID = linux.GENL_ID_PMCRAID + 1
+
+// families maps family IDs to families. It is only modified by init
+// functions.
+var families = map[uint16]Family{
+	linux.GENL_ID_CTRL: ctrlFamily{},
+}
+
+// RegisterFamily registers a generic netlink family.
+//
+// Preconditions: May only be called before any netlink sockets are created.
+func RegisterFamily(f Family) {
+	for _, other := range families {
+		if other.Name() == f.Name() {
+			panic(fmt.Sprintf("generic netlink family %q already registered", f.Name()))
+		}
+	}
+	id := uint16(firstFamilyID)
+	for {
+		if _, ok := families[id]; !ok {
+			break
+		}
+		id++
+	}
+	families[id] = f
+}
+
+// Protocol implements netlink.Protocol.
+//
+// +stateify savable
+type Protocol struct{}
+
+var _ netlink.Protocol = (*Protocol)(nil)
+
+// NewProtocol creates a NETLINK_GENERIC netlink.Protocol.
+func NewProtocol(t *kernel.Task) (netlink.Protocol, *syserr.Error) {
+	return &Protocol{}, nil
+}
+
+// Protocol implements netlink.Protocol.Protocol.
+func (p *Protocol) Protocol() int {
+	return linux.NETLINK_GENERIC
+}
+
+// CanSend implements netlink.Protocol.CanSend.
+func (p *Protocol) CanSend() bool {
+	return true
+}
+
+// Groups implements netlink.Protocol.Groups. No family has multicast groups.
+func (p *Protocol) Groups() uint32 {
+	return 0
+}
+
+// NonRootRecv implements netlink.Protocol.NonRootRecv.
+func (p *Protocol) NonRootRecv() bool {
+	return true
+}
+
+// ProcessMessage implements netlink.Protocol.ProcessMessage.
+func (p *Protocol) ProcessMessage(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	hdr := msg.Header()
+
+	var genHdr linux.GenericNetlinkMessageHeader
+	attrs, ok := msg.GetData(&genHdr)
+	if !ok {
+		return syserr.ErrInvalidArgument
+	}
+	f, ok := families[hdr.Type]
+	if !ok {
+		return syserr.ErrNoFileOrDir
+	}
+
+	var op *Op
+	for _, o := range f.Ops() {
+		if o.Cmd == genHdr.Cmd {
+			op = &o
+			break
+		}
+	}
+	if op == nil {
+		return syserr.ErrNotSupported
+	}
+	if op.Flags&(linux.GENL_ADMIN_PERM|linux.GENL_UNS_ADMIN_PERM) != 0 {
+		creds := auth.CredentialsFromContext(ctx)
+		if !creds.HasCapability(linux.CAP_NET_ADMIN) {
+			return syserr.ErrPermissionDenied
+		}
+	}
+	dump := hdr.Flags&linux.NLM_F_DUMP == linux.NLM_F_DUMP
+	if (dump && op.Flags&linux.GENL_CMD_CAP_DUMP == 0) || (!dump && op.Flags&linux.GENL_CMD_CAP_DO == 0) {
+		return syserr.ErrNotSupported
+	}
+	return f.ProcessMessage(ctx, s, hdr.Type, genHdr, attrs, dump, ms)
+}
+
+// ctrlFamily is the generic netlink controller family, which describes the
+// registered families.
+type ctrlFamily struct{}
+
+// Name implements Family.Name.
+func (ctrlFamily) Name() string {
+	return "nlctrl"
+}
+
+// Version implements Family.Version.
+func (ctrlFamily) Version() uint8 {
+	return 2
+}
+
+// MaxAttr implements Family.MaxAttr.
+func (ctrlFamily) MaxAttr() uint16 {
+	return linux.CTRL_ATTR_OP
+}
+
+// Ops implements Family.Ops.
+func (ctrlFamily) Ops() []Op {
+	return []Op{{
+		Cmd:   linux.CTRL_CMD_GETFAMILY,
+		Flags: linux.GENL_CMD_CAP_DO | linux.GENL_CMD_CAP_DUMP,
+	}}
+}
+
+// ProcessMessage implements Family.ProcessMessage.
+func (ctrlFamily) ProcessMessage(ctx context.Context, s *netlink.Socket, id uint16, genHdr linux.GenericNetlinkMessageHeader, attrs nlmsg.AttrsView, dump bool, ms *nlmsg.MessageSet) *syserr.Error {
+	if dump {
+		ids := make([]int, 0, len(families))
+		for id := range families {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+		ms.Multi = true
+		for _, id := range ids {
+			putFamily(ms, uint16(id), families[uint16(id)])
+		}
+		return nil
+	}
+
+	parsed, ok := attrs.Parse()
+	if !ok {
+		return syserr.ErrInvalidArgument
+	}
+	if v, ok := parsed[linux.CTRL_ATTR_FAMILY_ID]; ok {
+		famID, ok := v.Uint16()
+		if !ok {
+			return syserr.ErrInvalidArgument
+		}
+		f, ok := families[famID]
+		if !ok {
+			return syserr.ErrNoFileOrDir
+		}
+		putFamily(ms, famID, f)
+		return nil
+	}
+	if v, ok := parsed[linux.CTRL_ATTR_FAMILY_NAME]; ok {
+		name := v.String()
+		for famID, f := range families {
+			if f.Name() == name {
+				putFamily(ms, famID, f)
+				return nil
+			}
+		}
+		return syserr.ErrNoFileOrDir
+	}
+	return syserr.ErrInvalidArgument
+}
+
+// putFamily adds a CTRL_CMD_NEWFAMILY message that describes f to ms.
+func putFamily(ms *nlmsg.MessageSet, id uint16, f Family) {
+	m := ms.AddMessage(linux.NetlinkMessageHeader{
+		Type: linux.GENL_ID_CTRL,
+	})
+	m.Put(&linux.GenericNetlinkMessageHeader{
+		Cmd:     linux.CTRL_CMD_NEWFAMILY,
+		Version: ctrlFamily{}.Version(),
+	})
+	m.PutAttrString(linux.CTRL_ATTR_FAMILY_NAME, f.Name())
+	m.PutAttr(linux.CTRL_ATTR_FAMILY_ID, primitive.AllocateUint16(id))
+	m.PutAttr(linux.CTRL_ATTR_VERSION, primitive.AllocateUint32(uint32(f.Version())))
+	m.PutAttr(linux.CTRL_ATTR_HDRSIZE, primitive.AllocateUint32(0))
+	m.PutAttr(linux.CTRL_ATTR_MAXATTR, primitive.AllocateUint32(uint32(f.MaxAttr())))
+	if ops := f.Ops(); len(ops) != 0 {
+		opsOff := m.BeginNestedAttr(linux.CTRL_ATTR_OPS)
+		for i, op := range ops {
+			// Like Linux, operations are numbered from 1.
+			opOff := m.BeginNestedAttr(uint16(i + 1))
+			m.PutAttr(linux.CTRL_ATTR_OP_ID, primitive.AllocateUint32(uint32(op.Cmd)))
+			m.PutAttr(linux.CTRL_ATTR_OP_FLAGS, primitive.AllocateUint32(op.Flags))
+			m.EndNestedAttr(opOff)
+		}
+		m.EndNestedAttr(opsOff)
+	}
+}
+
+// init registers the NETLINK_GENERIC provider.
+func init() {
+	netlink.RegisterProvider(linux.NETLINK_GENERIC, NewProtocol)
+}
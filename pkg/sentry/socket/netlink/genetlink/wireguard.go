@@ -0,0 +1,445 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genetlink
+
+import (
+	"time"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
+	"gvisor.dev/gvisor/pkg/sentry/socket"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netstack"
+	"gvisor.dev/gvisor/pkg/syserr"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/packetsocket"
+	"gvisor.dev/gvisor/pkg/tcpip/link/wireguard"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+const (
+	// maxDumpMessageSize is the size after which the peers of a device are
+	// continued in another message of a dump, so that nested attributes
+	// don't overflow.
+	maxDumpMessageSize = 32 << 10
+
+	// peerAttrsSize is an upper bound of the size of the attributes of a
+	// peer, without its allowed IPs.
+	peerAttrsSize = 256
+
+	// allowedIPAttrsSize is an upper bound of the size of the attributes of
+	// an allowed IP.
+	allowedIPAttrsSize = 40
+)
+
+// wireguardFamily is the WireGuard family, which configures WireGuard
+// devices like Linux's drivers/net/wireguard/netlink.c.
+type wireguardFamily struct{}
+
+// Name implements Family.Name.
+func (wireguardFamily) Name() string {
+	return linux.WG_GENL_NAME
+}
+
+// Version implements Family.Version.
+func (wireguardFamily) Version() uint8 {
+	return linux.WG_GENL_VERSION
+}
+
+// MaxAttr implements Family.MaxAttr.
+func (wireguardFamily) MaxAttr() uint16 {
+	return linux.WGDEVICE_A_PEERS
+}
+
+// Ops implements Family.Ops.
+func (wireguardFamily) Ops() []Op {
+	return []Op{
+		{
+			Cmd:   linux.WG_CMD_GET_DEVICE,
+			Flags: linux.GENL_UNS_ADMIN_PERM | linux.GENL_CMD_CAP_DUMP,
+		},
+		{
+			Cmd:   linux.WG_CMD_SET_DEVICE,
+			Flags: linux.GENL_UNS_ADMIN_PERM | linux.GENL_CMD_CAP_DO,
+		},
+	}
+}
+
+// ProcessMessage implements Family.ProcessMessage.
+func (wireguardFamily) ProcessMessage(ctx context.Context, s *netlink.Socket, id uint16, genHdr linux.GenericNetlinkMessageHeader, attrs nlmsg.AttrsView, dump bool, ms *nlmsg.MessageSet) *syserr.Error {
+	parsed, ok := attrs.Parse()
+	if !ok {
+		return syserr.ErrInvalidArgument
+	}
+	st, ok := s.Stack().(*netstack.Stack)
+	if !ok {
+		return syserr.ErrNotSupported
+	}
+	nicID, name, ep, err := findDevice(st.Stack, parsed)
+	if err != nil {
+		return err
+	}
+	switch genHdr.Cmd {
+	case linux.WG_CMD_GET_DEVICE:
+		getDevice(ms, id, nicID, name, ep)
+		return nil
+	case linux.WG_CMD_SET_DEVICE:
+		return setDevice(ep, parsed)
+	}
+	return syserr.ErrNotSupported
+}
+
+// findDevice returns the WireGuard device identified by the attributes of a
+// request.
+func findDevice(st *stack.Stack, attrs map[uint16]nlmsg.BytesView) (tcpip.NICID, string, *wireguard.Endpoint, *syserr.Error) {
+	var (
+		nicID tcpip.NICID
+		name  string
+	)
+	if v, ok := attrs[linux.WGDEVICE_A_IFINDEX]; ok {
+		idx, ok := v.Uint32()
+		if !ok {
+			return 0, "", nil, syserr.ErrInvalidArgument
+		}
+		info, ok := st.NICInfo()[tcpip.NICID(idx)]
+		if !ok {
+			return 0, "", nil, syserr.ErrNoDevice
+		}
+		nicID, name = tcpip.NICID(idx), info.Name
+	} else if v, ok := attrs[linux.WGDEVICE_A_IFNAME]; ok {
+		name = v.String()
+		found := false
+		for id, info := range st.NICInfo() {
+			if info.Name == name {
+				nicID, found = id, true
+				break
+			}
+		}
+		if !found {
+			return 0, "", nil, syserr.ErrNoDevice
+		}
+	} else {
+		return 0, "", nil, syserr.ErrInvalidArgument
+	}
+
+	// WireGuard NICs are wrapped in a packet socket endpoint.
+	pep, ok := st.GetLinkEndpointByName(name).(*packetsocket.Endpoint)
+	if !ok {
+		return 0, "", nil, syserr.ErrNotSupported
+	}
+	ep, ok := pep.Child().(*wireguard.Endpoint)
+	if !ok {
+		return 0, "", nil, syserr.ErrNotSupported
+	}
+	return nicID, name, ep, nil
+}
+
+// getDevice adds the messages that describe the device ep to ms. Like Linux,
+// peers that don't fit in one message are continued in further messages,
+// which are merged by userspace.
+func getDevice(ms *nlmsg.MessageSet, id uint16, nicID tcpip.NICID, name string, ep *wireguard.Endpoint) {
+	ms.Multi = true
+	newMessage := func() *nlmsg.Message {
+		m := ms.AddMessage(linux.NetlinkMessageHeader{Type: id})
+		m.Put(&linux.GenericNetlinkMessageHeader{
+			Cmd:     linux.WG_CMD_GET_DEVICE,
+			Version: linux.WG_GENL_VERSION,
+		})
+		return m
+	}
+
+	m := newMessage()
+	m.PutAttr(linux.WGDEVICE_A_LISTEN_PORT, primitive.AllocateUint16(ep.ListenPort()))
+	m.PutAttr(linux.WGDEVICE_A_FWMARK, primitive.AllocateUint32(ep.Fwmark()))
+	m.PutAttr(linux.WGDEVICE_A_IFINDEX, primitive.AllocateUint32(uint32(nicID)))
+	m.PutAttrString(linux.WGDEVICE_A_IFNAME, name)
+	if priv := ep.PrivateKey(); !priv.IsZero() {
+		pub := ep.PublicKey()
+		m.PutAttr(linux.WGDEVICE_A_PRIVATE_KEY, primitive.AsByteSlice(priv[:]))
+		m.PutAttr(linux.WGDEVICE_A_PUBLIC_KEY, primitive.AsByteSlice(pub[:]))
+	}
+
+	peers := ep.Peers()
+	if len(peers) == 0 {
+		return
+	}
+	size := 0
+	peersOff := m.BeginNestedAttr(linux.WGDEVICE_A_PEERS)
+	for i := range peers {
+		p := &peers[i]
+		allowedIPs := p.AllowedIPs
+		first := true
+		for first || len(allowedIPs) != 0 {
+			if size+peerAttrsSize+allowedIPAttrsSize > maxDumpMessageSize {
+				m.EndNestedAttr(peersOff)
+				m = newMessage()
+				peersOff = m.BeginNestedAttr(linux.WGDEVICE_A_PEERS)
+				size = 0
+			}
+			peerOff := m.BeginNestedAttr(0)
+			m.PutAttr(linux.WGPEER_A_PUBLIC_KEY, primitive.AsByteSlice(p.PublicKey[:]))
+			size += peerAttrsSize
+			if first {
+				putPeer(m, p)
+				first = false
+			}
+			if len(allowedIPs) != 0 {
+				ipsOff := m.BeginNestedAttr(linux.WGPEER_A_ALLOWEDIPS)
+				for len(allowedIPs) != 0 && size+allowedIPAttrsSize <= maxDumpMessageSize {
+					putAllowedIP(m, allowedIPs[0])
+					allowedIPs = allowedIPs[1:]
+					size += allowedIPAttrsSize
+				}
+				m.EndNestedAttr(ipsOff)
+			}
+			m.EndNestedAttr(peerOff)
+		}
+	}
+	m.EndNestedAttr(peersOff)
+}
+
+// putPeer adds the attributes of p, other than its public key and allowed
+// IPs, to m.
+func putPeer(m *nlmsg.Message, p *wireguard.PeerInfo) {
+	m.PutAttr(linux.WGPEER_A_PRESHARED_KEY, primitive.AsByteSlice(p.PresharedKey[:]))
+	var handshake linux.Timespec
+	if !p.LastHandshake.IsZero() {
+		handshake = linux.NsecToTimespec(p.LastHandshake.UnixNano())
+	}
+	m.PutAttr(linux.WGPEER_A_LAST_HANDSHAKE_TIME, &handshake)
+	m.PutAttr(linux.WGPEER_A_PERSISTENT_KEEPALIVE_INTERVAL, primitive.AllocateUint16(uint16(p.PersistentKeepalive/time.Second)))
+	m.PutAttr(linux.WGPEER_A_TX_BYTES, primitive.AllocateUint64(p.TxBytes))
+	m.PutAttr(linux.WGPEER_A_RX_BYTES, primitive.AllocateUint64(p.RxBytes))
+	m.PutAttr(linux.WGPEER_A_PROTOCOL_VERSION, primitive.AllocateUint32(1))
+	switch p.Endpoint.Addr.Len() {
+	case header.IPv4AddressSize:
+		addr, _ := socket.ConvertAddress(linux.AF_INET, p.Endpoint)
+		m.PutAttr(linux.WGPEER_A_ENDPOINT, addr)
+	case header.IPv6AddressSize:
+		addr, _ := socket.ConvertAddress(linux.AF_INET6, p.Endpoint)
+		m.PutAttr(linux.WGPEER_A_ENDPOINT, addr)
+	}
+}
+
+// putAllowedIP adds a nested attribute that describes subnet to m.
+func putAllowedIP(m *nlmsg.Message, subnet tcpip.Subnet) {
+	id := subnet.ID()
+	family := uint16(linux.AF_INET)
+	if id.Len() == header.IPv6AddressSize {
+		family = linux.AF_INET6
+	}
+	off := m.BeginNestedAttr(0)
+	m.PutAttr(linux.WGALLOWEDIP_A_CIDR_MASK, primitive.AllocateUint8(uint8(subnet.Prefix())))
+	m.PutAttr(linux.WGALLOWEDIP_A_FAMILY, primitive.AllocateUint16(family))
+	m.PutAttr(linux.WGALLOWEDIP_A_IPADDR, primitive.AsByteSlice(id.AsSlice()))
+	m.EndNestedAttr(off)
+}
+
+// parseKey parses a key attribute.
+func parseKey(v nlmsg.BytesView) (wireguard.Key, bool) {
+	var k wireguard.Key
+	if len(v) != wireguard.KeySize {
+		return k, false
+	}
+	copy(k[:], v)
+	return k, true
+}
+
+// setDevice applies the configuration in attrs to the device ep.
+func setDevice(ep *wireguard.Endpoint, attrs map[uint16]nlmsg.BytesView) *syserr.Error {
+	var flags uint32
+	if v, ok := attrs[linux.WGDEVICE_A_FLAGS]; ok {
+		if flags, ok = v.Uint32(); !ok {
+			return syserr.ErrInvalidArgument
+		}
+		if flags&^linux.WGDEVICE_F_REPLACE_PEERS != 0 {
+			return syserr.ErrNotSupported
+		}
+	}
+
+	// Parse all peers before anything is changed.
+	var peers []wireguard.PeerConfig
+	if v, ok := attrs[linux.WGDEVICE_A_PEERS]; ok {
+		for rest := nlmsg.AttrsView(v); !rest.Empty(); {
+			_, value, next, ok := rest.ParseFirst()
+			if !ok {
+				return syserr.ErrInvalidArgument
+			}
+			rest = next
+			c, err := parsePeer(value)
+			if err != nil {
+				return err
+			}
+			peers = append(peers, c)
+		}
+	}
+
+	if v, ok := attrs[linux.WGDEVICE_A_FWMARK]; ok {
+		mark, ok := v.Uint32()
+		if !ok {
+			return syserr.ErrInvalidArgument
+		}
+		ep.SetFwmark(mark)
+	}
+	if v, ok := attrs[linux.WGDEVICE_A_LISTEN_PORT]; ok {
+		port, ok := v.Uint16()
+		if !ok {
+			return syserr.ErrInvalidArgument
+		}
+		if err := ep.SetListenPort(port); err != nil {
+			return syserr.TranslateNetstackError(err)
+		}
+	}
+	if flags&linux.WGDEVICE_F_REPLACE_PEERS != 0 {
+		ep.RemoveAllPeers()
+	}
+	if v, ok := attrs[linux.WGDEVICE_A_PRIVATE_KEY]; ok {
+		k, ok := parseKey(v)
+		if !ok {
+			return syserr.ErrInvalidArgument
+		}
+		ep.SetPrivateKey(k)
+	}
+	for _, c := range peers {
+		if err := ep.ConfigurePeer(c); err != nil {
+			return syserr.TranslateNetstackError(err)
+		}
+	}
+	return nil
+}
+
+// parsePeer parses the nested attributes of a peer.
+func parsePeer(b []byte) (wireguard.PeerConfig, *syserr.Error) {
+	var c wireguard.PeerConfig
+	attrs, ok := nlmsg.AttrsView(b).Parse()
+	if !ok {
+		return c, syserr.ErrInvalidArgument
+	}
+	v, ok := attrs[linux.WGPEER_A_PUBLIC_KEY]
+	if !ok {
+		return c, syserr.ErrInvalidArgument
+	}
+	if c.PublicKey, ok = parseKey(v); !ok {
+		return c, syserr.ErrInvalidArgument
+	}
+	if v, ok := attrs[linux.WGPEER_A_PROTOCOL_VERSION]; ok {
+		if version, ok := v.Uint32(); !ok || version != 1 {
+			return c, syserr.ErrNotSupported
+		}
+	}
+	if v, ok := attrs[linux.WGPEER_A_FLAGS]; ok {
+		flags, ok := v.Uint32()
+		if !ok {
+			return c, syserr.ErrInvalidArgument
+		}
+		if flags&^(linux.WGPEER_F_REMOVE_ME|linux.WGPEER_F_REPLACE_ALLOWEDIPS|linux.WGPEER_F_UPDATE_ONLY) != 0 {
+			return c, syserr.ErrNotSupported
+		}
+		c.Remove = flags&linux.WGPEER_F_REMOVE_ME != 0
+		c.ReplaceAllowedIPs = flags&linux.WGPEER_F_REPLACE_ALLOWEDIPS != 0
+		c.UpdateOnly = flags&linux.WGPEER_F_UPDATE_ONLY != 0
+	}
+	if v, ok := attrs[linux.WGPEER_A_PRESHARED_KEY]; ok {
+		if c.PresharedKey, ok = parseKey(v); !ok {
+			return c, syserr.ErrInvalidArgument
+		}
+		c.HasPresharedKey = true
+	}
+	if v, ok := attrs[linux.WGPEER_A_ENDPOINT]; ok {
+		addr, family, err := socket.AddressAndFamily(v)
+		if err != nil {
+			return c, err
+		}
+		if family != linux.AF_INET && family != linux.AF_INET6 {
+			return c, syserr.ErrInvalidArgument
+		}
+		if header.IsV4MappedAddress(addr.Addr) {
+			addr.Addr = tcpip.AddrFrom4Slice(addr.Addr.AsSlice()[header.IPv6AddressSize-header.IPv4AddressSize:])
+		}
+		c.Endpoint = addr
+		c.HasEndpoint = true
+	}
+	if v, ok := attrs[linux.WGPEER_A_PERSISTENT_KEEPALIVE_INTERVAL]; ok {
+		interval, ok := v.Uint16()
+		if !ok {
+			return c, syserr.ErrInvalidArgument
+		}
+		c.PersistentKeepalive = time.Duration(interval) * time.Second
+		c.HasPersistentKeepalive = true
+	}
+	if v, ok := attrs[linux.WGPEER_A_ALLOWEDIPS]; ok {
+		for rest := nlmsg.AttrsView(v); !rest.Empty(); {
+			_, value, next, ok := rest.ParseFirst()
+			if !ok {
+				return c, syserr.ErrInvalidArgument
+			}
+			rest = next
+			a, err := parseAllowedIP(value)
+			if err != nil {
+				return c, err
+			}
+			c.AllowedIPs = append(c.AllowedIPs, a)
+		}
+	}
+	return c, nil
+}
+
+// parseAllowedIP parses the nested attributes of an allowed IP.
+func parseAllowedIP(b []byte) (tcpip.AddressWithPrefix, *syserr.Error) {
+	attrs, ok := nlmsg.AttrsView(b).Parse()
+	if !ok {
+		return tcpip.AddressWithPrefix{}, syserr.ErrInvalidArgument
+	}
+	var (
+		family uint16
+		mask   uint8
+	)
+	v, ok := attrs[linux.WGALLOWEDIP_A_FAMILY]
+	if ok {
+		family, ok = v.Uint16()
+	}
+	if !ok {
+		return tcpip.AddressWithPrefix{}, syserr.ErrInvalidArgument
+	}
+	v, ok = attrs[linux.WGALLOWEDIP_A_CIDR_MASK]
+	if ok {
+		mask, ok = v.Uint8()
+	}
+	if !ok {
+		return tcpip.AddressWithPrefix{}, syserr.ErrInvalidArgument
+	}
+	addr, ok := attrs[linux.WGALLOWEDIP_A_IPADDR]
+	if !ok {
+		return tcpip.AddressWithPrefix{}, syserr.ErrInvalidArgument
+	}
+	switch {
+	case family == linux.AF_INET && len(addr) == header.IPv4AddressSize && mask <= 32:
+	case family == linux.AF_INET6 && len(addr) == header.IPv6AddressSize && mask <= 128:
+	default:
+		return tcpip.AddressWithPrefix{}, syserr.ErrInvalidArgument
+	}
+	return tcpip.AddressWithPrefix{
+		Address:   tcpip.AddrFromSlice(addr),
+		PrefixLen: int(mask),
+	}, nil
+}
+
+// init registers the WireGuard family.
+func init() {
+	RegisterFamily(wireguardFamily{})
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
+	"gvisor.dev/gvisor/pkg/sentry/socket/unix/transport"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/syserr"
+)
+
+// maxGroups is the maximum number of multicast groups a protocol may have.
+// Group memberships are stored in a single uint64 bitmap.
+const maxGroups = 64
+
+// groupBit returns the bit corresponding to multicast group g, which is
+// numbered from 1.
+func groupBit(g uint32) uint64 {
+	return 1 << (g - 1)
+}
+
+// multicastTable tracks the netlink sockets that are members of at least one
+// multicast group, so that protocols can broadcast messages to them.
+//
+// multicastTable is not saved. Sockets register themselves again on restore.
+type multicastTable struct {
+	mu sync.Mutex
+
+	// members maps netlink protocols to their member sockets, along with the
+	// groups they are members of. It is protected by mu.
+	members map[int]map[*Socket]uint64
+}
+
+// multicast is the global multicastTable.
+var multicast = multicastTable{
+	members: make(map[int]map[*Socket]uint64),
+}
+
+// update records that s is a member of groups, replacing any previous
+// memberships.
+func (mt *multicastTable) update(s *Socket, groups uint64) {
+	protocol := s.protocol.Protocol()
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if groups == 0 {
+		if sockets, ok := mt.members[protocol]; ok {
+			delete(sockets, s)
+		}
+		return
+	}
+	sockets, ok := mt.members[protocol]
+	if !ok {
+		sockets = make(map[*Socket]uint64)
+		mt.members[protocol] = sockets
+	}
+	sockets[s] = groups
+}
+
+// sockets returns the sockets in ns that are members of group for protocol.
+// If ns is nil, sockets in all network namespaces are returned.
+func (mt *multicastTable) sockets(ns *inet.Namespace, protocol int, group uint32) []*Socket {
+	bit := groupBit(group)
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	var sockets []*Socket
+	for s, groups := range mt.members[protocol] {
+		if groups&bit != 0 && (ns == nil || s.netns == ns) {
+			sockets = append(sockets, s)
+		}
+	}
+	return sockets
+}
+
+// Broadcast sends m to all sockets of the given protocol that are members of
+// group in network namespace ns. If ns is nil, m is sent to members in all
+// network namespaces.
+//
+// m must not be modified or broadcast again after calling Broadcast.
+func Broadcast(ctx context.Context, ns *inet.Namespace, protocol int, group uint32, m *nlmsg.Message) {
+	BroadcastBytes(ctx, ns, protocol, group, m.Finalize())
+}
+
+// BroadcastBytes is like Broadcast, but sends buf as is. It is used by
+// protocols whose messages do not have netlink headers, e.g. uevents.
+//
+// As in Linux, the message is dropped for members whose receive buffer is
+// full.
+func BroadcastBytes(ctx context.Context, ns *inet.Namespace, protocol int, group uint32, buf []byte) {
+	if group == 0 || group > maxGroups {
+		return
+	}
+	cms := transport.ControlMessages{
+		Credentials: kernelCreds,
+	}
+	for _, s := range multicast.sockets(ns, protocol, group) {
+		_, notify, err := s.connection.Send(ctx, [][]byte{buf}, cms, transport.Address{})
+		if err != nil && err != syserr.ErrWouldBlock {
+			ctx.Debugf("netlink: failed to broadcast to port %d: %v", s.portID, err)
+			continue
+		}
+		if notify {
+			s.connection.SendNotify()
+		}
+	}
+}
+
+// checkGroupPermission returns an error if t may not join multicast groups
+// of s's protocol.
+func (s *Socket) checkGroupPermission(t *kernel.Task) *syserr.Error {
+	if s.protocol.NonRootRecv() || t.HasCapabilityIn(linux.CAP_NET_ADMIN, s.netns.UserNamespace()) {
+		return nil
+	}
+	return syserr.ErrPermissionDenied
+}
+
+// setGroupsLocked replaces s's multicast group memberships with groups.
+//
+// Preconditions: s.mu must be locked.
+func (s *Socket) setGroupsLocked(groups uint64) {
+	s.groups = groups
+	multicast.update(s, groups)
+}
+
+// groupsMask returns the mask of valid multicast groups for s's protocol.
+func (s *Socket) groupsMask() uint64 {
+	n := s.protocol.Groups()
+	if n >= maxGroups {
+		return ^uint64(0)
+	}
+	return groupBit(n+1) - 1
+}
+
+// afterLoad is invoked by stateify.
+func (s *Socket) afterLoad(context.Context) {
+	if s.groups != 0 {
+		multicast.update(s, s.groups)
+	}
+}
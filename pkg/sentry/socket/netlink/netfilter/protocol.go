@@ -60,6 +60,16 @@ func (p *Protocol) CanSend() bool {
 	return true
 }
 
+// Groups implements netlink.Protocol.Groups.
+func (p *Protocol) Groups() uint32 {
+	return uint32(linux.NFNLGRP_MAX)
+}
+
+// NonRootRecv implements netlink.Protocol.NonRootRecv.
+func (p *Protocol) NonRootRecv() bool {
+	return false
+}
+
 // ProcessMessage implements netlink.Protocol.ProcessMessage.
 func (p *Protocol) ProcessMessage(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
 	hdr := msg.Header()
@@ -191,6 +191,28 @@ func (m *Message) PutAttrString(atype uint16, s string) {
 	m.putZeros(aligned - l)
 }
 
+// BeginNestedAttr starts a nested netlink attribute. Attributes added to the
+// message until EndNestedAttr is called with the returned offset are nested in
+// it.
+func (m *Message) BeginNestedAttr(atype uint16) int {
+	off := len(m.buf)
+	m.Put(&linux.NetlinkAttrHeader{
+		Type: atype | linux.NLA_F_NESTED,
+	})
+	return off
+}
+
+// EndNestedAttr finishes the nested attribute that starts at off.
+//
+// Preconditions: The nested attribute fits in math.MaxUint16 bytes.
+func (m *Message) EndNestedAttr(off int) {
+	l := len(m.buf) - off
+	if l > math.MaxUint16 {
+		panic(fmt.Sprintf("attribute too large: %d", l))
+	}
+	hostarch.ByteOrder.PutUint16(m.buf[off:], uint16(l))
+}
+
 // MessageSet contains a series of netlink messages.
 type MessageSet struct {
 	// Multi indicates that this a multi-part message, to be terminated by
@@ -284,7 +306,7 @@ func (v AttrsView) Parse() (map[uint16]BytesView, bool) {
 			return nil, false
 		}
 		attrsView = rest
-		attrs[ahdr.Type] = BytesView(value)
+		attrs[ahdr.Type&linux.NLA_TYPE_MASK] = BytesView(value)
 	}
 	return attrs, true
 
@@ -316,6 +338,28 @@ func (v *BytesView) String() string {
 	return string(b)
 }
 
+// Uint8 converts the raw attribute value to uint8.
+func (v *BytesView) Uint8() (uint8, bool) {
+	attr := []byte(*v)
+	val := primitive.Uint8(0)
+	if len(attr) != val.SizeBytes() {
+		return 0, false
+	}
+	val.UnmarshalBytes(attr)
+	return uint8(val), true
+}
+
+// Uint16 converts the raw attribute value to uint16.
+func (v *BytesView) Uint16() (uint16, bool) {
+	attr := []byte(*v)
+	val := primitive.Uint16(0)
+	if len(attr) != val.SizeBytes() {
+		return 0, false
+	}
+	val.UnmarshalBytes(attr)
+	return uint16(val), true
+}
+
 // Uint32 converts the raw attribute value to uint32.
 func (v *BytesView) Uint32() (uint32, bool) {
 	attr := []byte(*v)
@@ -373,3 +373,50 @@ func TestBytesView(t *testing.T) {
 		}
 	}
 }
+
+func TestNestedAttr(t *testing.T) {
+	m := nlmsg.NewMessage(linux.NetlinkMessageHeader{Type: linux.NLMSG_MIN_TYPE})
+	hdr := primitive.Uint32(0)
+	m.Put(&hdr)
+	off := m.BeginNestedAttr(1)
+	inner := primitive.Uint16(0x3130)
+	m.PutAttr(2, &inner)
+	m.EndNestedAttr(off)
+
+	msg, _, ok := nlmsg.ParseMessage(m.Finalize())
+	if !ok {
+		t.Fatalf("ParseMessage failed")
+	}
+	attrsView, ok := msg.GetData(&hdr)
+	if !ok {
+		t.Fatalf("GetData failed")
+	}
+	ahdr, value, _, ok := attrsView.ParseFirst()
+	if !ok {
+		t.Fatalf("ParseFirst failed")
+	}
+	if want := uint16(1 | linux.NLA_F_NESTED); ahdr.Type != want {
+		t.Errorf("got nested attribute type %#x, want %#x", ahdr.Type, want)
+	}
+	if want := uint16(linux.NetlinkAttrHeaderSize*2 + 4); ahdr.Length != want {
+		t.Errorf("got nested attribute length %d, want %d", ahdr.Length, want)
+	}
+
+	// The flags are masked out by Parse.
+	attrs, ok := attrsView.Parse()
+	if !ok {
+		t.Fatalf("Parse failed")
+	}
+	nested, ok := attrs[1]
+	if !ok {
+		t.Fatalf("nested attribute not found in %v", attrs)
+	}
+	innerAttrs, ok := nlmsg.AttrsView(nested).Parse()
+	if !ok {
+		t.Fatalf("Parse of nested attributes failed")
+	}
+	v := innerAttrs[2]
+	if got, ok := v.Uint16(); !ok || got != uint16(inner) {
+		t.Errorf("got inner attribute = (%#x, %t), want (%#x, true)", got, ok, uint16(inner))
+	}
+}
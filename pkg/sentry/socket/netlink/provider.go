@@ -39,6 +39,16 @@ type Protocol interface {
 	// that will never send messages, thus making those features no-ops.
 	CanSend() bool
 
+	// Groups returns the number of multicast groups supported by this
+	// protocol. Sockets may join groups 1 through Groups(), which must not
+	// exceed 64.
+	Groups() uint32
+
+	// NonRootRecv returns true if sockets may join this protocol's multicast
+	// groups without CAP_NET_ADMIN. This is analogous to Linux's
+	// NL_CFG_F_NONROOT_RECV.
+	NonRootRecv() bool
+
 	// ProcessMessage processes a single message from userspace.
 	//
 	// If err == nil, any messages added to ms will be sent back to the
@@ -0,0 +1,293 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	"bytes"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
+)
+
+// snapshot is the state of a network stack that is reported to RTNLGRP_*
+// multicast groups.
+type snapshot struct {
+	links  map[int32]inet.Interface
+	addrs  map[addrKey]inet.InterfaceAddr
+	routes map[routeKey]inet.Route
+	rules  map[ruleKey]inet.RoutingRule
+	neighs map[neighKey]inet.Neighbor
+}
+
+// addrKey identifies an interface address.
+type addrKey struct {
+	idx       int32
+	family    uint8
+	prefixLen uint8
+	addr      string
+}
+
+// routeKey identifies a route.
+type routeKey struct {
+	family   uint8
+	dstLen   uint8
+	srcLen   uint8
+	tos      uint8
+	protocol uint8
+	scope    uint8
+	typ      uint8
+	dst      string
+	src      string
+	oif      int32
+	gateway  string
+	table    uint32
+	priority uint32
+}
+
+// ruleKey identifies a routing rule.
+type ruleKey struct {
+	family      uint8
+	dstLen      uint8
+	srcLen      uint8
+	action      uint8
+	flags       uint32
+	table       uint32
+	priority    uint32
+	dst         string
+	src         string
+	iif         string
+	oif         string
+	mark        uint32
+	markMask    uint32
+	uidRange    linux.FibRuleUIDRange
+	hasUIDRange bool
+}
+
+// neighKey identifies a neighbor table entry.
+type neighKey struct {
+	idx    int32
+	family uint8
+	addr   string
+}
+
+// takeSnapshot returns the current state of stack.
+func takeSnapshot(stack inet.Stack) snapshot {
+	sn := snapshot{
+		links:  stack.Interfaces(),
+		addrs:  make(map[addrKey]inet.InterfaceAddr),
+		routes: make(map[routeKey]inet.Route),
+		rules:  make(map[ruleKey]inet.RoutingRule),
+		neighs: make(map[neighKey]inet.Neighbor),
+	}
+	for idx, as := range stack.InterfaceAddrs() {
+		for _, a := range as {
+			sn.addrs[addrKey{idx, a.Family, a.PrefixLen, string(a.Addr)}] = a
+		}
+	}
+	for _, rt := range stack.RouteTable() {
+		sn.routes[routeKey{
+			family:   rt.Family,
+			dstLen:   rt.DstLen,
+			srcLen:   rt.SrcLen,
+			tos:      rt.TOS,
+			protocol: rt.Protocol,
+			scope:    rt.Scope,
+			typ:      rt.Type,
+			dst:      string(rt.DstAddr),
+			src:      string(rt.SrcAddr),
+			oif:      rt.OutputInterface,
+			gateway:  string(rt.GatewayAddr),
+			table:    rt.Table,
+			priority: rt.Priority,
+		}] = rt
+	}
+	for _, rule := range stack.RoutingRules() {
+		// Rules that apply to both IPv4 and IPv6 are reported separately for
+		// each.
+		for _, f := range ruleFamilies(rule, linux.AF_UNSPEC) {
+			rule.Family = f
+			sn.rules[ruleKey{
+				family:      rule.Family,
+				dstLen:      rule.DstLen,
+				srcLen:      rule.SrcLen,
+				action:      rule.Action,
+				flags:       rule.Flags,
+				table:       rule.Table,
+				priority:    rule.Priority,
+				dst:         string(rule.DstAddr),
+				src:         string(rule.SrcAddr),
+				iif:         rule.InputInterface,
+				oif:         rule.OutputInterface,
+				mark:        rule.Mark,
+				markMask:    rule.MarkMask,
+				uidRange:    rule.UIDRange,
+				hasUIDRange: rule.HasUIDRange,
+			}] = rule
+		}
+	}
+	for idx, ns := range stack.Neighbors() {
+		for _, n := range ns {
+			sn.neighs[neighKey{idx, n.Family, string(n.Addr)}] = n
+		}
+	}
+	return sn
+}
+
+// linkChanged returns true if the attributes reported in RTM_NEWLINK messages
+// differ between a and b.
+func linkChanged(a, b inet.Interface) bool {
+	return a.DeviceType != b.DeviceType || a.Flags != b.Flags || a.Name != b.Name ||
+		a.MTU != b.MTU || a.TxQueueLen != b.TxQueueLen || !bytes.Equal(a.Addr, b.Addr)
+}
+
+// neighborChanged returns true if the attributes reported in RTM_NEWNEIGH
+// messages differ between a and b.
+func neighborChanged(a, b inet.Neighbor) bool {
+	return a.State != b.State || a.Flags != b.Flags || !bytes.Equal(a.LinkAddr, b.LinkAddr)
+}
+
+// addrGroup returns the multicast group for address notifications of the
+// given family.
+func addrGroup(family uint8) uint32 {
+	if family == linux.AF_INET6 {
+		return linux.RTNLGRP_IPV6_IFADDR
+	}
+	return linux.RTNLGRP_IPV4_IFADDR
+}
+
+// routeGroup returns the multicast group for route notifications of the given
+// family.
+func routeGroup(family uint8) uint32 {
+	if family == linux.AF_INET6 {
+		return linux.RTNLGRP_IPV6_ROUTE
+	}
+	return linux.RTNLGRP_IPV4_ROUTE
+}
+
+// ruleGroup returns the multicast group for rule notifications of the given
+// family.
+func ruleGroup(family uint8) uint32 {
+	if family == linux.AF_INET6 {
+		return linux.RTNLGRP_IPV6_RULE
+	}
+	return linux.RTNLGRP_IPV4_RULE
+}
+
+// notifyChanges broadcasts the differences between before and after to the
+// RTNLGRP_* multicast groups of ns. ms is the response to the request that
+// caused the changes; as in Linux, notifications carry its port ID and
+// sequence number.
+//
+// Additions are reported before removals, and links are added before their
+// addresses, routes and neighbors and removed after them.
+func notifyChanges(ctx context.Context, ns *inet.Namespace, ms *nlmsg.MessageSet, before, after snapshot) {
+	broadcast := func(group uint32, add func(*nlmsg.MessageSet)) {
+		nms := nlmsg.NewMessageSet(ms.PortID, ms.Seq)
+		add(nms)
+		for _, m := range nms.Messages {
+			netlink.Broadcast(ctx, ns, linux.NETLINK_ROUTE, group, m)
+		}
+	}
+
+	for idx, i := range after.links {
+		if old, ok := before.links[idx]; !ok || linkChanged(old, i) {
+			broadcast(linux.RTNLGRP_LINK, func(nms *nlmsg.MessageSet) {
+				addLinkMessage(nms, linux.RTM_NEWLINK, idx, i)
+			})
+		}
+	}
+	for k, a := range after.addrs {
+		if _, ok := before.addrs[k]; !ok {
+			broadcast(addrGroup(a.Family), func(nms *nlmsg.MessageSet) {
+				addAddrMessage(nms, linux.RTM_NEWADDR, k.idx, a)
+			})
+		}
+	}
+	for k, rt := range after.routes {
+		if _, ok := before.routes[k]; !ok {
+			broadcast(routeGroup(rt.Family), func(nms *nlmsg.MessageSet) {
+				addRouteMessage(nms, linux.RTM_NEWROUTE, rt)
+			})
+		}
+	}
+	for k, rule := range after.rules {
+		if _, ok := before.rules[k]; !ok {
+			broadcast(ruleGroup(rule.Family), func(nms *nlmsg.MessageSet) {
+				addRuleMessage(nms, linux.RTM_NEWRULE, rule)
+			})
+		}
+	}
+	for k, n := range after.neighs {
+		if old, ok := before.neighs[k]; !ok || neighborChanged(old, n) {
+			broadcast(linux.RTNLGRP_NEIGH, func(nms *nlmsg.MessageSet) {
+				addNeighborMessage(nms, linux.RTM_NEWNEIGH, k.idx, n)
+			})
+		}
+	}
+
+	for k, n := range before.neighs {
+		if _, ok := after.neighs[k]; !ok {
+			broadcast(linux.RTNLGRP_NEIGH, func(nms *nlmsg.MessageSet) {
+				addNeighborMessage(nms, linux.RTM_DELNEIGH, k.idx, n)
+			})
+		}
+	}
+	for k, rule := range before.rules {
+		if _, ok := after.rules[k]; !ok {
+			broadcast(ruleGroup(rule.Family), func(nms *nlmsg.MessageSet) {
+				addRuleMessage(nms, linux.RTM_DELRULE, rule)
+			})
+		}
+	}
+	for k, rt := range before.routes {
+		if _, ok := after.routes[k]; !ok {
+			broadcast(routeGroup(rt.Family), func(nms *nlmsg.MessageSet) {
+				addRouteMessage(nms, linux.RTM_DELROUTE, rt)
+			})
+		}
+	}
+	for k, a := range before.addrs {
+		if _, ok := after.addrs[k]; !ok {
+			broadcast(addrGroup(a.Family), func(nms *nlmsg.MessageSet) {
+				addAddrMessage(nms, linux.RTM_DELADDR, k.idx, a)
+			})
+		}
+	}
+	for idx, i := range before.links {
+		if _, ok := after.links[idx]; !ok {
+			broadcast(linux.RTNLGRP_LINK, func(nms *nlmsg.MessageSet) {
+				addLinkMessage(nms, linux.RTM_DELLINK, idx, i)
+			})
+		}
+	}
+}
+
+// NotifyChanges runs fn, which modifies the stack of ns outside of netlink
+// (e.g. on behalf of the sandbox controller), and broadcasts the resulting
+// changes to the RTNLGRP_* multicast groups of ns. As for changes made by the
+// kernel in Linux, notifications carry a port ID and sequence number of 0.
+func NotifyChanges(ctx context.Context, ns *inet.Namespace, fn func() error) error {
+	stack := ns.Stack()
+	if stack == nil {
+		return fn()
+	}
+	before := takeSnapshot(stack)
+	err := fn()
+	notifyChanges(ctx, ns, nlmsg.NewMessageSet(0, 0), before, takeSnapshot(stack))
+	return err
+}
@@ -69,6 +69,16 @@ func (p *Protocol) CanSend() bool {
 	return true
 }
 
+// Groups implements netlink.Protocol.Groups.
+func (p *Protocol) Groups() uint32 {
+	return linux.RTNLGRP_MAX
+}
+
+// NonRootRecv implements netlink.Protocol.NonRootRecv.
+func (p *Protocol) NonRootRecv() bool {
+	return true
+}
+
 // dumpLinks handles RTM_GETLINK dump requests.
 func (p *Protocol) dumpLinks(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
 	// NLM_F_DUMP + RTM_GETLINK messages are supposed to include an
@@ -95,7 +105,7 @@ func (p *Protocol) dumpLinks(ctx context.Context, s *netlink.Socket, msg *nlmsg.
 	}
 
 	for idx, i := range stack.Interfaces() {
-		addNewLinkMessage(ms, idx, i)
+		addLinkMessage(ms, linux.RTM_NEWLINK, idx, i)
 	}
 
 	return nil
@@ -152,7 +162,7 @@ func (p *Protocol) getLink(ctx context.Context, s *netlink.Socket, msg *nlmsg.Me
 			return syserr.ErrInvalidArgument
 		}
 
-		addNewLinkMessage(ms, idx, i)
+		addLinkMessage(ms, linux.RTM_NEWLINK, idx, i)
 		found = true
 		break
 	}
@@ -229,11 +239,11 @@ func (p *Protocol) delLink(ctx context.Context, s *netlink.Socket, msg *nlmsg.Me
 	return syserr.FromError(stack.RemoveInterface(ifinfomsg.Index))
 }
 
-// addNewLinkMessage appends RTM_NEWLINK message for the given interface into
-// the message set.
-func addNewLinkMessage(ms *nlmsg.MessageSet, idx int32, i inet.Interface) {
+// addLinkMessage appends an RTM_NEWLINK or RTM_DELLINK message for the given
+// interface into the message set.
+func addLinkMessage(ms *nlmsg.MessageSet, msgType uint16, idx int32, i inet.Interface) {
 	m := ms.AddMessage(linux.NetlinkMessageHeader{
-		Type: linux.RTM_NEWLINK,
+		Type: msgType,
 	})
 
 	m.Put(&linux.InterfaceInfoMessage{
@@ -245,6 +255,7 @@ func addNewLinkMessage(ms *nlmsg.MessageSet, idx int32, i inet.Interface) {
 
 	m.PutAttrString(linux.IFLA_IFNAME, i.Name)
 	m.PutAttr(linux.IFLA_MTU, primitive.AllocateUint32(i.MTU))
+	m.PutAttr(linux.IFLA_TXQLEN, primitive.AllocateUint32(i.TxQueueLen))
 
 	mac := make([]byte, 6)
 	brd := mac
@@ -281,27 +292,33 @@ func (p *Protocol) dumpAddrs(ctx context.Context, s *netlink.Socket, msg *nlmsg.
 
 	for id, as := range stack.InterfaceAddrs() {
 		for _, a := range as {
-			m := ms.AddMessage(linux.NetlinkMessageHeader{
-				Type: linux.RTM_NEWADDR,
-			})
-
-			m.Put(&linux.InterfaceAddrMessage{
-				Family:    a.Family,
-				PrefixLen: a.PrefixLen,
-				Index:     uint32(id),
-			})
-
-			addr := primitive.ByteSlice([]byte(a.Addr))
-			m.PutAttr(linux.IFA_LOCAL, &addr)
-			m.PutAttr(linux.IFA_ADDRESS, &addr)
-
-			// TODO(gvisor.dev/issue/578): There are many more attributes.
+			addAddrMessage(ms, linux.RTM_NEWADDR, id, a)
 		}
 	}
 
 	return nil
 }
 
+// addAddrMessage appends an RTM_NEWADDR or RTM_DELADDR message for the given
+// interface address into the message set.
+func addAddrMessage(ms *nlmsg.MessageSet, msgType uint16, idx int32, a inet.InterfaceAddr) {
+	m := ms.AddMessage(linux.NetlinkMessageHeader{
+		Type: msgType,
+	})
+
+	m.Put(&linux.InterfaceAddrMessage{
+		Family:    a.Family,
+		PrefixLen: a.PrefixLen,
+		Index:     uint32(idx),
+	})
+
+	addr := primitive.ByteSlice([]byte(a.Addr))
+	m.PutAttr(linux.IFA_LOCAL, &addr)
+	m.PutAttr(linux.IFA_ADDRESS, &addr)
+
+	// TODO(gvisor.dev/issue/578): There are many more attributes.
+}
+
 // commonPrefixLen reports the length of the longest IP address prefix.
 // This is a simplified version from Golang's src/net/addrselect.go.
 func commonPrefixLen(a, b []byte) (cpl int) {
@@ -458,44 +475,295 @@ func (p *Protocol) dumpRoutes(ctx context.Context, s *netlink.Socket, msg *nlmsg
 	}
 
 	for _, rt := range routeTables {
-		m := ms.AddMessage(linux.NetlinkMessageHeader{
-			Type: linux.RTM_NEWROUTE,
-		})
-
-		m.Put(&linux.RouteMessage{
-			Family: rt.Family,
-			DstLen: rt.DstLen,
-			SrcLen: rt.SrcLen,
-			TOS:    rt.TOS,
-
-			// Always return the main table since we don't have multiple
-			// routing tables.
-			Table:    linux.RT_TABLE_MAIN,
-			Protocol: rt.Protocol,
-			Scope:    rt.Scope,
-			Type:     rt.Type,
-
-			Flags: rt.Flags,
-		})
-
-		m.PutAttr(254, primitive.AsByteSlice([]byte{123}))
-		if rt.DstLen > 0 {
-			m.PutAttr(linux.RTA_DST, primitive.AsByteSlice(rt.DstAddr))
+		addRouteMessage(ms, linux.RTM_NEWROUTE, rt)
+	}
+
+	return nil
+}
+
+// addRouteMessage appends an RTM_NEWROUTE or RTM_DELROUTE message for the
+// given route into the message set.
+func addRouteMessage(ms *nlmsg.MessageSet, msgType uint16, rt inet.Route) {
+	m := ms.AddMessage(linux.NetlinkMessageHeader{
+		Type: msgType,
+	})
+
+	table := rt.Table
+	if table == linux.RT_TABLE_UNSPEC {
+		table = linux.RT_TABLE_MAIN
+	}
+	m.Put(&linux.RouteMessage{
+		Family: rt.Family,
+		DstLen: rt.DstLen,
+		SrcLen: rt.SrcLen,
+		TOS:    rt.TOS,
+
+		Table:    compatTable(table),
+		Protocol: rt.Protocol,
+		Scope:    rt.Scope,
+		Type:     rt.Type,
+
+		Flags: rt.Flags,
+	})
+
+	m.PutAttr(254, primitive.AsByteSlice([]byte{123}))
+	m.PutAttr(linux.RTA_TABLE, primitive.AllocateUint32(table))
+	if rt.Priority != 0 {
+		m.PutAttr(linux.RTA_PRIORITY, primitive.AllocateUint32(rt.Priority))
+	}
+	if rt.DstLen > 0 {
+		m.PutAttr(linux.RTA_DST, primitive.AsByteSlice(rt.DstAddr))
+	}
+	if rt.SrcLen > 0 {
+		m.PutAttr(linux.RTA_SRC, primitive.AsByteSlice(rt.SrcAddr))
+	}
+	if rt.OutputInterface != 0 {
+		m.PutAttr(linux.RTA_OIF, primitive.AllocateInt32(rt.OutputInterface))
+	}
+	if len(rt.GatewayAddr) > 0 {
+		m.PutAttr(linux.RTA_GATEWAY, primitive.AsByteSlice(rt.GatewayAddr))
+	}
+
+	// TODO(gvisor.dev/issue/578): There are many more attributes.
+}
+
+// compatTable returns the table ID to report in the 8-bit table fields of
+// netlink messages. Larger IDs are only reported in attributes.
+func compatTable(table uint32) uint8 {
+	if table > 0xff {
+		return linux.RT_TABLE_COMPAT
+	}
+	return uint8(table)
+}
+
+// ruleFamilies returns the families that rule is reported for in response to
+// a request for family. Rules with an unspecified family apply to both IPv4
+// and IPv6.
+func ruleFamilies(rule inet.RoutingRule, family uint8) []uint8 {
+	switch {
+	case rule.Family == linux.AF_UNSPEC && family == linux.AF_UNSPEC:
+		return []uint8{linux.AF_INET, linux.AF_INET6}
+	case rule.Family == linux.AF_UNSPEC:
+		return []uint8{family}
+	case family == linux.AF_UNSPEC || family == rule.Family:
+		return []uint8{rule.Family}
+	default:
+		return nil
+	}
+}
+
+// dumpRules handles RTM_GETRULE dump requests.
+func (p *Protocol) dumpRules(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	// RTM_GETRULE dump requests need not contain anything more than the
+	// netlink header and 1 byte protocol family common to all
+	// NETLINK_ROUTE requests.
+	var family primitive.Uint8
+	if _, ok := msg.GetData(&family); !ok {
+		return syserr.ErrInvalidArgument
+	}
+
+	// We always send back an NLMSG_DONE.
+	ms.Multi = true
+
+	stack := s.Stack()
+	if stack == nil {
+		// No routing rules.
+		return nil
+	}
+
+	for _, rule := range stack.RoutingRules() {
+		for _, f := range ruleFamilies(rule, uint8(family)) {
+			rule.Family = f
+			addRuleMessage(ms, linux.RTM_NEWRULE, rule)
 		}
-		if rt.SrcLen > 0 {
-			m.PutAttr(linux.RTA_SRC, primitive.AsByteSlice(rt.SrcAddr))
+	}
+	return nil
+}
+
+// addRuleMessage appends an RTM_NEWRULE or RTM_DELRULE message for the given
+// routing rule into the message set.
+func addRuleMessage(ms *nlmsg.MessageSet, msgType uint16, rule inet.RoutingRule) {
+	m := ms.AddMessage(linux.NetlinkMessageHeader{
+		Type: msgType,
+	})
+
+	m.Put(&linux.FibRuleHeader{
+		Family: rule.Family,
+		DstLen: rule.DstLen,
+		SrcLen: rule.SrcLen,
+		Table:  compatTable(rule.Table),
+		Action: rule.Action,
+		Flags:  rule.Flags,
+	})
+
+	m.PutAttr(linux.FRA_TABLE, primitive.AllocateUint32(rule.Table))
+	if rule.Priority != 0 {
+		m.PutAttr(linux.FRA_PRIORITY, primitive.AllocateUint32(rule.Priority))
+	}
+	if len(rule.SrcAddr) > 0 {
+		m.PutAttr(linux.FRA_SRC, primitive.AsByteSlice(rule.SrcAddr))
+	}
+	if len(rule.DstAddr) > 0 {
+		m.PutAttr(linux.FRA_DST, primitive.AsByteSlice(rule.DstAddr))
+	}
+	if rule.InputInterface != "" {
+		m.PutAttrString(linux.FRA_IIFNAME, rule.InputInterface)
+	}
+	if rule.OutputInterface != "" {
+		m.PutAttrString(linux.FRA_OIFNAME, rule.OutputInterface)
+	}
+	if rule.Mark != 0 || rule.MarkMask != 0 {
+		m.PutAttr(linux.FRA_FWMARK, primitive.AllocateUint32(rule.Mark))
+		m.PutAttr(linux.FRA_FWMASK, primitive.AllocateUint32(rule.MarkMask))
+	}
+	if rule.HasUIDRange {
+		m.PutAttr(linux.FRA_UID_RANGE, &rule.UIDRange)
+	}
+}
+
+// newRule handles RTM_NEWRULE requests.
+func (p *Protocol) newRule(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	stack := s.Stack()
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+	return stack.NewRoutingRule(ctx, msg)
+}
+
+// delRule handles RTM_DELRULE requests.
+func (p *Protocol) delRule(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	stack := s.Stack()
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+	return stack.RemoveRoutingRule(ctx, msg)
+}
+
+// dumpNeighbors handles RTM_GETNEIGH dump requests.
+func (p *Protocol) dumpNeighbors(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	// RTM_GETNEIGH dump requests need not contain anything more than the
+	// netlink header and 1 byte protocol family common to all
+	// NETLINK_ROUTE requests. If a full ndmsg is passed, its interface
+	// index is used as a filter.
+	var (
+		family primitive.Uint8
+		idx    int32
+	)
+	if _, ok := msg.GetData(&family); !ok {
+		return syserr.ErrInvalidArgument
+	}
+	var ndm linux.NeighborMessage
+	if _, ok := msg.GetData(&ndm); ok {
+		idx = ndm.Index
+	}
+
+	// We always send back an NLMSG_DONE.
+	ms.Multi = true
+
+	stack := s.Stack()
+	if stack == nil {
+		// No network devices.
+		return nil
+	}
+
+	for id, ns := range stack.Neighbors() {
+		if idx != 0 && idx != id {
+			continue
+		}
+		for _, n := range ns {
+			if uint8(family) != linux.AF_UNSPEC && uint8(family) != n.Family {
+				continue
+			}
+			addNeighborMessage(ms, linux.RTM_NEWNEIGH, id, n)
+		}
+	}
+	return nil
+}
+
+// getNeighbor handles RTM_GETNEIGH requests for a single neighbor entry.
+func (p *Protocol) getNeighbor(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	stack := s.Stack()
+	if stack == nil {
+		// No network devices.
+		return syserr.ErrNoDevice
+	}
+
+	var ndm linux.NeighborMessage
+	attrs, ok := msg.GetData(&ndm)
+	if !ok {
+		return syserr.ErrInvalidArgument
+	}
+	var dst []byte
+	for !attrs.Empty() {
+		ahdr, value, rest, ok := attrs.ParseFirst()
+		if !ok {
+			return syserr.ErrInvalidArgument
 		}
-		if rt.OutputInterface != 0 {
-			m.PutAttr(linux.RTA_OIF, primitive.AllocateInt32(rt.OutputInterface))
+		attrs = rest
+		if ahdr.Type == linux.NDA_DST {
+			dst = value
 		}
-		if len(rt.GatewayAddr) > 0 {
-			m.PutAttr(linux.RTA_GATEWAY, primitive.AsByteSlice(rt.GatewayAddr))
+	}
+	if dst == nil {
+		return syserr.ErrInvalidArgument
+	}
+
+	ns, ok := stack.Neighbors()[ndm.Index]
+	if !ok {
+		if _, ok := stack.Interfaces()[ndm.Index]; !ok {
+			return syserr.ErrNoDevice
+		}
+	}
+	for _, n := range ns {
+		if n.Family == ndm.Family && bytes.Equal(n.Addr, dst) {
+			addNeighborMessage(ms, linux.RTM_NEWNEIGH, ndm.Index, n)
+			return nil
 		}
+	}
+	return syserr.ErrNoFileOrDir
+}
 
-		// TODO(gvisor.dev/issue/578): There are many more attributes.
+// addNeighborMessage appends an RTM_NEWNEIGH or RTM_DELNEIGH message for the
+// given neighbor entry into the message set.
+func addNeighborMessage(ms *nlmsg.MessageSet, msgType uint16, idx int32, n inet.Neighbor) {
+	m := ms.AddMessage(linux.NetlinkMessageHeader{
+		Type: msgType,
+	})
+
+	m.Put(&linux.NeighborMessage{
+		Family: n.Family,
+		Index:  idx,
+		State:  n.State,
+		Flags:  n.Flags,
+		Type:   linux.RTN_UNICAST,
+	})
+
+	m.PutAttr(linux.NDA_DST, primitive.AsByteSlice(n.Addr))
+	if len(n.LinkAddr) > 0 {
+		m.PutAttr(linux.NDA_LLADDR, primitive.AsByteSlice(n.LinkAddr))
 	}
+}
 
-	return nil
+// newNeighbor handles RTM_NEWNEIGH requests.
+func (p *Protocol) newNeighbor(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	stack := s.Stack()
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+	return stack.NewNeighbor(ctx, msg)
+}
+
+// delNeighbor handles RTM_DELNEIGH requests.
+func (p *Protocol) delNeighbor(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
+	stack := s.Stack()
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+	return stack.RemoveNeighbor(ctx, msg)
 }
 
 // newAddr handles RTM_NEWADDR requests.
@@ -629,10 +897,23 @@ func (p *Protocol) ProcessMessage(ctx context.Context, s *netlink.Socket, msg *n
 			return p.dumpAddrs(ctx, s, msg, ms)
 		case linux.RTM_GETROUTE:
 			return p.dumpRoutes(ctx, s, msg, ms)
+		case linux.RTM_GETRULE:
+			return p.dumpRules(ctx, s, msg, ms)
+		case linux.RTM_GETNEIGH:
+			return p.dumpNeighbors(ctx, s, msg, ms)
 		default:
 			return syserr.ErrNotSupported
 		}
 	} else if hdr.Flags&linux.NLM_F_REQUEST == linux.NLM_F_REQUEST {
+		if typeKind(hdr.Type) != kindGet {
+			// Notify listeners of any changes made by this request.
+			if stack := s.Stack(); stack != nil {
+				before := takeSnapshot(stack)
+				defer func() {
+					notifyChanges(ctx, s.NetworkNamespace(), ms, before, takeSnapshot(stack))
+				}()
+			}
+		}
 		switch hdr.Type {
 		case linux.RTM_NEWLINK:
 			return p.newLink(ctx, s, msg, ms)
@@ -653,6 +934,16 @@ func (p *Protocol) ProcessMessage(ctx context.Context, s *netlink.Socket, msg *n
 			return p.newAddr(ctx, s, msg, ms)
 		case linux.RTM_DELADDR:
 			return p.delAddr(ctx, s, msg, ms)
+		case linux.RTM_NEWRULE:
+			return p.newRule(ctx, s, msg, ms)
+		case linux.RTM_DELRULE:
+			return p.delRule(ctx, s, msg, ms)
+		case linux.RTM_NEWNEIGH:
+			return p.newNeighbor(ctx, s, msg, ms)
+		case linux.RTM_GETNEIGH:
+			return p.getNeighbor(ctx, s, msg, ms)
+		case linux.RTM_DELNEIGH:
+			return p.delNeighbor(ctx, s, msg, ms)
 		default:
 			return syserr.ErrNotSupported
 		}
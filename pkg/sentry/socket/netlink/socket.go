@@ -111,6 +111,10 @@ type Socket struct {
 	// this is just bookkeeping for tracking add/remove.
 	filter bool
 
+	// groups is the bitmap of multicast groups this socket is a member of.
+	// Bit n represents group n+1.
+	groups uint64
+
 	// netns is the network namespace associated with the socket.
 	netns *inet.Namespace
 }
@@ -155,10 +159,23 @@ func (s *Socket) Stack() inet.Stack {
 	return s.netns.Stack()
 }
 
+// NetworkNamespace returns the network namespace associated with the socket.
+func (s *Socket) NetworkNamespace() *inet.Namespace {
+	return s.netns
+}
+
 // Release implements vfs.FileDescriptionImpl.Release.
 func (s *Socket) Release(ctx context.Context) {
 	t := kernel.TaskFromContext(ctx)
 	t.Kernel().DeleteSocket(&s.vfsfd)
+
+	// Stop receiving broadcasts before the connection is released.
+	s.mu.Lock()
+	if s.groups != 0 {
+		s.setGroupsLocked(0)
+	}
+	s.mu.Unlock()
+
 	s.connection.Release(ctx)
 	s.ep.Close(ctx)
 
@@ -311,15 +328,25 @@ func (s *Socket) Bind(t *kernel.Task, sockaddr []byte) *syserr.Error {
 		return err
 	}
 
-	// No support for multicast groups yet.
 	if a.Groups != 0 {
-		return syserr.ErrPermissionDenied
+		if err := s.checkGroupPermission(t); err != nil {
+			return err
+		}
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s.bindPort(t, int32(a.PortID))
+	if err := s.bindPort(t, int32(a.PortID)); err != nil {
+		return err
+	}
+
+	// bind(2) replaces memberships in the first 32 groups, which are the only
+	// ones that sockaddr_nl can represent. See net/netlink/af_netlink.c:
+	// netlink_bind.
+	groups := uint64(a.Groups) & s.groupsMask()
+	s.setGroupsLocked(s.groups&^uint64(0xffffffff) | groups)
+	return nil
 }
 
 // Connect implements socket.Socket.Connect.
@@ -329,7 +356,9 @@ func (s *Socket) Connect(t *kernel.Task, sockaddr []byte, blocking bool) *syserr
 		return err
 	}
 
-	// No support for multicast groups yet.
+	// Sending to multicast groups is not supported. Linux returns EPERM if
+	// applications attempt to do this without NL_CFG_F_NONROOT_SEND, so we
+	// emulate that.
 	if a.Groups != 0 {
 		return syserr.ErrPermissionDenied
 	}
@@ -417,11 +446,26 @@ func (s *Socket) GetSockOpt(t *kernel.Task, level int, name int, outPtr hostarch
 		}
 	case linux.SOL_NETLINK:
 		switch name {
+		case linux.NETLINK_LIST_MEMBERSHIPS:
+			// The memberships are returned as an array of uint32 bitmaps,
+			// truncated to the size of the user buffer.
+			s.mu.Lock()
+			groups := s.groups
+			s.mu.Unlock()
+			words := (int(s.protocol.Groups()) + 31) / 32
+			if n := outLen / sizeOfInt32; n < words {
+				words = n
+			}
+			memberships := make([]byte, words*sizeOfInt32)
+			for i := 0; i < words; i++ {
+				hostarch.ByteOrder.PutUint32(memberships[i*sizeOfInt32:], uint32(groups>>(32*i)))
+			}
+			return primitive.AsByteSlice(memberships), nil
+
 		case linux.NETLINK_BROADCAST_ERROR,
 			linux.NETLINK_CAP_ACK,
 			linux.NETLINK_DUMP_STRICT_CHK,
 			linux.NETLINK_EXT_ACK,
-			linux.NETLINK_LIST_MEMBERSHIPS,
 			linux.NETLINK_NO_ENOBUFS,
 			linux.NETLINK_PKTINFO:
 			// Not supported.
@@ -528,10 +572,28 @@ func (s *Socket) SetSockOpt(t *kernel.Task, level int, name int, opt []byte) *sy
 		}
 	case linux.SOL_NETLINK:
 		switch name {
-		case linux.NETLINK_ADD_MEMBERSHIP,
-			linux.NETLINK_BROADCAST_ERROR,
+		case linux.NETLINK_ADD_MEMBERSHIP, linux.NETLINK_DROP_MEMBERSHIP:
+			if len(opt) < sizeOfInt32 {
+				return syserr.ErrInvalidArgument
+			}
+			if err := s.checkGroupPermission(t); err != nil {
+				return err
+			}
+			group := hostarch.ByteOrder.Uint32(opt)
+			if group == 0 || group > s.protocol.Groups() {
+				return syserr.ErrInvalidArgument
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if name == linux.NETLINK_ADD_MEMBERSHIP {
+				s.setGroupsLocked(s.groups | groupBit(group))
+			} else {
+				s.setGroupsLocked(s.groups &^ groupBit(group))
+			}
+			return nil
+
+		case linux.NETLINK_BROADCAST_ERROR,
 			linux.NETLINK_CAP_ACK,
-			linux.NETLINK_DROP_MEMBERSHIP,
 			linux.NETLINK_DUMP_STRICT_CHK,
 			linux.NETLINK_EXT_ACK,
 			linux.NETLINK_LISTEN_ALL_NSID,
@@ -553,6 +615,7 @@ func (s *Socket) GetSockName(t *kernel.Task) (linux.SockAddr, uint32, *syserr.Er
 	sa := &linux.SockAddrNetlink{
 		Family: linux.AF_NETLINK,
 		PortID: uint32(s.portID),
+		Groups: uint32(s.groups),
 	}
 	return sa, uint32(sa.SizeBytes()), nil
 }
@@ -779,7 +842,8 @@ func (s *Socket) sendMsg(ctx context.Context, src usermem.IOSequence, to []byte,
 			return 0, err
 		}
 
-		// No support for multicast groups yet.
+		// Sending to multicast groups is not supported, as if
+		// NL_CFG_F_NONROOT_SEND is not set.
 		if a.Groups != 0 {
 			return 0, syserr.ErrPermissionDenied
 		}
@@ -14,12 +14,17 @@
 
 // Package uevent provides a NETLINK_KOBJECT_UEVENT socket protocol.
 //
-// NETLINK_KOBJECT_UEVENT sockets send udev-style device events. gVisor does
-// not support any device events, so these sockets never send any messages.
+// NETLINK_KOBJECT_UEVENT sockets send udev-style device events. Events are
+// only delivered to sockets that joined the kernel multicast group; messages
+// sent by userspace are ignored.
 package uevent
 
 import (
+	"fmt"
+	"strings"
+
 	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/atomicbitops"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/socket/netlink"
@@ -49,12 +54,47 @@ func (p *Protocol) CanSend() bool {
 	return false
 }
 
+// Groups implements netlink.Protocol.Groups.
+func (p *Protocol) Groups() uint32 {
+	// Linux uses a single group for kernel uevents, and udev rebroadcasts
+	// them to a second group.
+	return 2
+}
+
+// NonRootRecv implements netlink.Protocol.NonRootRecv.
+func (p *Protocol) NonRootRecv() bool {
+	return true
+}
+
 // ProcessMessage implements netlink.Protocol.ProcessMessage.
 func (p *Protocol) ProcessMessage(ctx context.Context, s *netlink.Socket, msg *nlmsg.Message, ms *nlmsg.MessageSet) *syserr.Error {
 	// Silently ignore all messages.
 	return nil
 }
 
+// kernelGroup is the multicast group of uevents sent by the kernel. Linux has
+// no uapi name for it.
+const kernelGroup = 1
+
+// seqnum is the sequence number of the last uevent sent.
+var seqnum atomicbitops.Uint64
+
+// Send broadcasts a uevent for the device at devpath (relative to /sys) to
+// sockets in all network namespaces. env holds additional KEY=VALUE pairs,
+// e.g. SUBSYSTEM. See lib/kobject_uevent.c:kobject_uevent_env.
+func Send(ctx context.Context, action, devpath string, env []string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s@%s\x00", action, devpath)
+	fmt.Fprintf(&b, "ACTION=%s\x00", action)
+	fmt.Fprintf(&b, "DEVPATH=%s\x00", devpath)
+	for _, e := range env {
+		b.WriteString(e)
+		b.WriteByte(0)
+	}
+	fmt.Fprintf(&b, "SEQNUM=%d\x00", seqnum.Add(1))
+	netlink.BroadcastBytes(ctx, nil /* ns */, linux.NETLINK_KOBJECT_UEVENT, kernelGroup, []byte(b.String()))
+}
+
 // init registers the NETLINK_KOBJECT_UEVENT provider.
 func init() {
 	netlink.RegisterProvider(linux.NETLINK_KOBJECT_UEVENT, NewProtocol)
@@ -1041,6 +1041,14 @@ func getSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, family
 		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetReusePort()))
 		return &v, nil
 
+	case linux.SO_MARK:
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+
+		v := primitive.Int32(ep.SocketOptions().GetMark())
+		return &v, nil
+
 	case linux.SO_BINDTODEVICE:
 		v := ep.SocketOptions().GetBindToDevice()
 		if v == 0 {
@@ -2016,6 +2024,19 @@ func setSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, name i
 		ep.SocketOptions().SetReceiveBufferSize(clamped, true /* notify */)
 		return nil
 
+	case linux.SO_MARK:
+		if len(optVal) < sizeOfInt32 {
+			return syserr.ErrInvalidArgument
+		}
+
+		creds := auth.CredentialsFromContext(t)
+		if !creds.HasCapability(linux.CAP_NET_ADMIN) && !creds.HasCapability(linux.CAP_NET_RAW) {
+			return syserr.ErrNotPermitted
+		}
+
+		ep.SocketOptions().SetMark(hostarch.ByteOrder.Uint32(optVal))
+		return nil
+
 	case linux.SO_REUSEADDR:
 		if len(optVal) < sizeOfInt32 {
 			return syserr.ErrInvalidArgument
@@ -2170,7 +2191,6 @@ func setSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, name i
 		linux.SO_SNDBUFFORCE,
 		linux.SO_PASSSEC,
 		linux.SO_TIMESTAMPNS,
-		linux.SO_MARK,
 		linux.SO_TIMESTAMPING,
 		linux.SO_PROTOCOL,
 		linux.SO_DOMAIN,
@@ -3626,7 +3646,7 @@ func interfaceIoctl(ctx context.Context, _ usermem.IO, arg int, ifr *linux.IFReq
 
 	case linux.SIOCGIFTXQLEN:
 		// Gets the transmit queue length of the device.
-		// TODO(gvisor.dev/issue/505): Implement.
+		hostarch.ByteOrder.PutUint32(ifr.Data[:4], iface.TxQueueLen)
 
 	case linux.SIOCGIFDSTADDR:
 		// Gets the destination address of a point-to-point device.
@@ -15,7 +15,12 @@
 package netstack
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"slices"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
@@ -24,12 +29,16 @@ import (
 	"gvisor.dev/gvisor/pkg/refs"
 	"gvisor.dev/gvisor/pkg/sentry/inet"
 	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
+	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/syserr"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
 	"gvisor.dev/gvisor/pkg/tcpip/link/ethernet"
 	"gvisor.dev/gvisor/pkg/tcpip/link/packetsocket"
 	"gvisor.dev/gvisor/pkg/tcpip/link/veth"
+	"gvisor.dev/gvisor/pkg/tcpip/link/vlan"
+	"gvisor.dev/gvisor/pkg/tcpip/link/vxlan"
+	"gvisor.dev/gvisor/pkg/tcpip/link/wireguard"
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
@@ -41,8 +50,44 @@ import (
 // +stateify savable
 type Stack struct {
 	Stack *stack.Stack `state:".(*stack.Stack)"`
+
+	// routingRulesMu serializes changes to the routing rules, which are made
+	// by reading, modifying and replacing them.
+	routingRulesMu sync.Mutex `state:"nosave"`
+
+	// txQueueLenMu protects txQueueLens.
+	txQueueLenMu sync.Mutex `state:"nosave"`
+
+	// txQueueLens holds the transmit queue lengths of NICs that were changed
+	// from defaultTxQueueLen. netstack doesn't queue outbound packets, so the
+	// value is only reported back to the application.
+	//
+	// +checklocks:txQueueLenMu
+	txQueueLens map[tcpip.NICID]uint32
+
+	// sysctlMu protects somaxconn.
+	sysctlMu sync.Mutex `state:"nosave"`
+
+	// somaxconn is the value of net.core.somaxconn if it was changed from
+	// defaultSomaxconn. The listen backlog is limited by listen(2) rather
+	// than by netstack.
+	//
+	// +checklocks:sysctlMu
+	somaxconn *int32
 }
 
+// defaultTxQueueLen is the default transmit queue length of a NIC, see Linux's
+// include/uapi/linux/if_ether.h:DEFAULT_TX_QUEUE_LEN.
+const defaultTxQueueLen = 1000
+
+// minMTU is the minimum MTU of a non-loopback NIC, see Linux's
+// include/uapi/linux/if_ether.h:ETH_MIN_MTU.
+const minMTU = 68
+
+// maxMTU is the maximum MTU of a non-loopback NIC, see Linux's
+// include/uapi/linux/if_ether.h:ETH_MAX_MTU.
+const maxMTU = 0xffff
+
 // EnableSaveRestore enables netstack s/r.
 func (s *Stack) EnableSaveRestore() error {
 	s.Stack.EnableSaveRestore()
@@ -89,18 +134,52 @@ func toLinuxARPHardwareType(t header.ARPHardwareType) uint16 {
 // Interfaces implements inet.Stack.Interfaces.
 func (s *Stack) Interfaces() map[int32]inet.Interface {
 	is := make(map[int32]inet.Interface)
+	s.txQueueLenMu.Lock()
+	defer s.txQueueLenMu.Unlock()
 	for id, ni := range s.Stack.NICInfo() {
+		qlen, ok := s.txQueueLens[id]
+		if !ok {
+			qlen = defaultTxQueueLen
+		}
 		is[int32(id)] = inet.Interface{
 			Name:       ni.Name,
 			Addr:       []byte(ni.LinkAddress),
 			Flags:      uint32(nicStateFlagsToLinux(ni.Flags)),
 			DeviceType: toLinuxARPHardwareType(ni.ARPHardwareType),
 			MTU:        ni.MTU,
+			TxQueueLen: qlen,
 		}
 	}
 	return is
 }
 
+// SetInterfaceMTU implements inet.Stack.SetInterfaceMTU.
+func (s *Stack) SetInterfaceMTU(idx int32, mtu uint32) error {
+	nicInfo, ok := s.Stack.NICInfo()[tcpip.NICID(idx)]
+	if !ok {
+		return linuxerr.ENODEV
+	}
+	if !nicInfo.Flags.Loopback && (mtu < minMTU || mtu > maxMTU) {
+		return linuxerr.EINVAL
+	}
+	return syserr.TranslateNetstackError(s.Stack.SetNICMTU(tcpip.NICID(idx), mtu)).ToError()
+}
+
+// SetInterfaceTxQueueLen implements inet.Stack.SetInterfaceTxQueueLen.
+func (s *Stack) SetInterfaceTxQueueLen(idx int32, qlen uint32) error {
+	id := tcpip.NICID(idx)
+	s.txQueueLenMu.Lock()
+	defer s.txQueueLenMu.Unlock()
+	if !s.Stack.HasNIC(id) {
+		return linuxerr.ENODEV
+	}
+	if s.txQueueLens == nil {
+		s.txQueueLens = make(map[tcpip.NICID]uint32)
+	}
+	s.txQueueLens[id] = qlen
+	return nil
+}
+
 // RemoveInterface implements inet.Stack.RemoveInterface.
 func (s *Stack) RemoveInterface(idx int32) error {
 	nic := tcpip.NICID(idx)
@@ -115,7 +194,16 @@ func (s *Stack) RemoveInterface(idx int32) error {
 		return syserr.ErrNotSupported.ToError()
 	}
 
-	return syserr.TranslateNetstackError(s.Stack.RemoveNIC(nic)).ToError()
+	// Hold txQueueLenMu so that the NIC's transmit queue length can't be set
+	// between its removal and the removal of its entry, which would otherwise
+	// be inherited by a NIC that reuses its ID.
+	s.txQueueLenMu.Lock()
+	defer s.txQueueLenMu.Unlock()
+	if err := s.Stack.RemoveNIC(nic); err != nil {
+		return syserr.TranslateNetstackError(err).ToError()
+	}
+	delete(s.txQueueLens, nic)
+	return nil
 }
 
 // SetInterface implements inet.Stack.SetInterface.
@@ -147,6 +235,7 @@ func (s *Stack) SetInterface(ctx context.Context, msg *nlmsg.Message) *syserr.Er
 				}
 			}
 		case linux.IFLA_MASTER:
+		case linux.IFLA_LINK:
 		case linux.IFLA_LINKINFO:
 		case linux.IFLA_ADDRESS:
 		case linux.IFLA_MTU:
@@ -237,11 +326,17 @@ func (s *Stack) setLink(ctx context.Context, id tcpip.NICID, linkAttrs map[uint1
 			if !ok {
 				return syserr.ErrInvalidArgument
 			}
-			if err := s.Stack.SetNICMTU(id, mtu); err != nil {
-				return syserr.TranslateNetstackError(err)
+			if err := s.SetInterfaceMTU(int32(id), mtu); err != nil {
+				return syserr.FromError(err)
 			}
 		case linux.IFLA_TXQLEN:
-			// TODO(b/340388892): support IFLA_TXQLEN.
+			qlen, ok := v.Uint32()
+			if !ok {
+				return syserr.ErrInvalidArgument
+			}
+			if err := s.SetInterfaceTxQueueLen(int32(id), qlen); err != nil {
+				return syserr.FromError(err)
+			}
 		}
 	}
 	return nil
@@ -357,6 +452,236 @@ func (s *Stack) newBridge(ctx context.Context, linkAttrs map[uint16]nlmsg.BytesV
 	return nil
 }
 
+func (s *Stack) newVLAN(ctx context.Context, linkAttrs map[uint16]nlmsg.BytesView, linkInfoAttrs map[uint16]nlmsg.BytesView) *syserr.Error {
+	v, ok := linkAttrs[linux.IFLA_LINK]
+	if !ok {
+		return syserr.ErrInvalidArgument
+	}
+	link, ok := v.Uint32()
+	if !ok {
+		return syserr.ErrInvalidArgument
+	}
+	parentID := tcpip.NICID(link)
+	parent, ok := s.Stack.NICInfo()[parentID]
+	if !ok {
+		return syserr.ErrNoDevice
+	}
+
+	var (
+		vlanID uint16
+		hasID  bool
+	)
+	protocol := header.VLANProtocol8021Q
+	if value, ok := linkInfoAttrs[linux.IFLA_INFO_DATA]; ok {
+		linkInfoData, ok := nlmsg.AttrsView(value).Parse()
+		if !ok {
+			return syserr.ErrInvalidArgument
+		}
+		for attr, v := range linkInfoData {
+			switch attr {
+			case linux.IFLA_VLAN_ID:
+				vlanID, ok = v.Uint16()
+				if !ok {
+					return syserr.ErrInvalidArgument
+				}
+				hasID = true
+			case linux.IFLA_VLAN_PROTOCOL:
+				// The protocol is in network byte order.
+				if len(v) != 2 {
+					return syserr.ErrInvalidArgument
+				}
+				protocol = tcpip.NetworkProtocolNumber(binary.BigEndian.Uint16(v))
+			case linux.IFLA_VLAN_FLAGS:
+			default:
+				ctx.Warningf("unexpected vlan attribute: %x", attr)
+				return syserr.ErrNotSupported
+			}
+		}
+	}
+	if !hasID || vlanID > header.VLANMaxID {
+		return syserr.ErrInvalidArgument
+	}
+
+	ep, err := vlan.New(s.Stack, parentID, protocol, vlanID, parent.MTU, parent.LinkAddress)
+	if err != nil {
+		return syserr.TranslateNetstackError(err)
+	}
+	id := s.Stack.NextNICID()
+	ifname := fmt.Sprintf("vlan%d", id)
+	if v, ok := linkAttrs[linux.IFLA_IFNAME]; ok {
+		ifname = v.String()
+	}
+	err = s.Stack.CreateNICWithOptions(id, packetsocket.New(ethernet.New(ep)), stack.NICOptions{
+		Name: ifname,
+	})
+	if err != nil {
+		ep.Close()
+		return syserr.TranslateNetstackError(err)
+	}
+	if err := s.setLink(ctx, id, linkAttrs); err != nil {
+		s.Stack.RemoveNIC(id)
+		return err
+	}
+	return nil
+}
+
+func (s *Stack) newVXLAN(ctx context.Context, linkAttrs map[uint16]nlmsg.BytesView, linkInfoAttrs map[uint16]nlmsg.BytesView) *syserr.Error {
+	opts := vxlan.Options{
+		Learning: true,
+	}
+	hasID := false
+	if value, ok := linkInfoAttrs[linux.IFLA_INFO_DATA]; ok {
+		linkInfoData, ok := nlmsg.AttrsView(value).Parse()
+		if !ok {
+			return syserr.ErrInvalidArgument
+		}
+		for attr, v := range linkInfoData {
+			switch attr {
+			case linux.IFLA_VXLAN_ID:
+				opts.VNI, ok = v.Uint32()
+				if !ok || opts.VNI > header.VXLANMaxVNI {
+					return syserr.ErrInvalidArgument
+				}
+				hasID = true
+			case linux.IFLA_VXLAN_GROUP, linux.IFLA_VXLAN_LOCAL:
+				if len(v) != header.IPv4AddressSize {
+					return syserr.ErrInvalidArgument
+				}
+				if attr == linux.IFLA_VXLAN_GROUP {
+					opts.Remote = tcpip.AddrFrom4Slice(v)
+				} else {
+					opts.Local = tcpip.AddrFrom4Slice(v)
+				}
+			case linux.IFLA_VXLAN_GROUP6, linux.IFLA_VXLAN_LOCAL6:
+				if len(v) != header.IPv6AddressSize {
+					return syserr.ErrInvalidArgument
+				}
+				if attr == linux.IFLA_VXLAN_GROUP6 {
+					opts.Remote = tcpip.AddrFrom16Slice(v)
+				} else {
+					opts.Local = tcpip.AddrFrom16Slice(v)
+				}
+			case linux.IFLA_VXLAN_LINK:
+				link, ok := v.Uint32()
+				if !ok {
+					return syserr.ErrInvalidArgument
+				}
+				if !s.Stack.HasNIC(tcpip.NICID(link)) {
+					return syserr.ErrNoDevice
+				}
+				opts.NIC = tcpip.NICID(link)
+			case linux.IFLA_VXLAN_TTL:
+				opts.TTL, ok = v.Uint8()
+				if !ok {
+					return syserr.ErrInvalidArgument
+				}
+			case linux.IFLA_VXLAN_LEARNING:
+				learning, ok := v.Uint8()
+				if !ok {
+					return syserr.ErrInvalidArgument
+				}
+				opts.Learning = learning != 0
+			case linux.IFLA_VXLAN_AGEING:
+				ageing, ok := v.Uint32()
+				if !ok {
+					return syserr.ErrInvalidArgument
+				}
+				// An ageing time of zero disables ageing.
+				opts.Ageing = -1
+				if ageing != 0 {
+					opts.Ageing = time.Duration(ageing) * time.Second
+				}
+			case linux.IFLA_VXLAN_PORT:
+				// The port is in network byte order.
+				if len(v) != 2 {
+					return syserr.ErrInvalidArgument
+				}
+				opts.Port = binary.BigEndian.Uint16(v)
+			case linux.IFLA_VXLAN_LIMIT, linux.IFLA_VXLAN_PORT_RANGE:
+				// The FDB size isn't limited and encapsulated packets are
+				// always sent from the VXLAN port.
+			case linux.IFLA_VXLAN_TOS, linux.IFLA_VXLAN_PROXY, linux.IFLA_VXLAN_RSC,
+				linux.IFLA_VXLAN_L2MISS, linux.IFLA_VXLAN_L3MISS, linux.IFLA_VXLAN_UDP_CSUM,
+				linux.IFLA_VXLAN_UDP_ZERO_CSUM6_TX, linux.IFLA_VXLAN_UDP_ZERO_CSUM6_RX,
+				linux.IFLA_VXLAN_REMCSUM_TX, linux.IFLA_VXLAN_REMCSUM_RX,
+				linux.IFLA_VXLAN_COLLECT_METADATA, linux.IFLA_VXLAN_TTL_INHERIT, linux.IFLA_VXLAN_DF:
+				// iproute2 sends these options with their default values,
+				// which are the only ones supported.
+				if f, ok := v.Uint8(); !ok || f != 0 {
+					ctx.Warningf("unsupported vxlan attribute: %x", attr)
+					return syserr.ErrNotSupported
+				}
+			default:
+				ctx.Warningf("unexpected vxlan attribute: %x", attr)
+				return syserr.ErrNotSupported
+			}
+		}
+	}
+	if !hasID {
+		return syserr.ErrInvalidArgument
+	}
+
+	// Like Linux, use a random locally administered address.
+	addr := make([]byte, tcpip.LinkAddressSize)
+	if _, err := io.ReadFull(s.Stack.SecureRNG().Reader, addr); err != nil {
+		return syserr.FromError(err)
+	}
+	addr[0] = (addr[0] &^ 0x01) | 0x02
+	opts.LinkAddress = tcpip.LinkAddress(addr)
+
+	ep, err := vxlan.New(s.Stack, opts)
+	if err != nil {
+		return syserr.TranslateNetstackError(err)
+	}
+	id := s.Stack.NextNICID()
+	ifname := fmt.Sprintf("vxlan%d", id)
+	if v, ok := linkAttrs[linux.IFLA_IFNAME]; ok {
+		ifname = v.String()
+	}
+	err = s.Stack.CreateNICWithOptions(id, packetsocket.New(ethernet.New(ep)), stack.NICOptions{
+		Name: ifname,
+	})
+	if err != nil {
+		ep.Close()
+		return syserr.TranslateNetstackError(err)
+	}
+	if err := s.setLink(ctx, id, linkAttrs); err != nil {
+		s.Stack.RemoveNIC(id)
+		return err
+	}
+	return nil
+}
+
+func (s *Stack) newWireGuard(ctx context.Context, linkAttrs map[uint16]nlmsg.BytesView, linkInfoAttrs map[uint16]nlmsg.BytesView) *syserr.Error {
+	if v, ok := linkInfoAttrs[linux.IFLA_INFO_DATA]; ok && len(v) != 0 {
+		// WireGuard devices are configured with generic netlink.
+		return syserr.ErrInvalidArgument
+	}
+
+	ep, err := wireguard.New(s.Stack, wireguard.Options{})
+	if err != nil {
+		return syserr.TranslateNetstackError(err)
+	}
+	id := s.Stack.NextNICID()
+	ifname := fmt.Sprintf("wg%d", id)
+	if v, ok := linkAttrs[linux.IFLA_IFNAME]; ok {
+		ifname = v.String()
+	}
+	// WireGuard devices are layer 3 devices, so there is no link header.
+	err = s.Stack.CreateNICWithOptions(id, packetsocket.New(ep), stack.NICOptions{
+		Name: ifname,
+	})
+	if err != nil {
+		ep.Close()
+		return syserr.TranslateNetstackError(err)
+	}
+	if err := s.setLink(ctx, id, linkAttrs); err != nil {
+		s.Stack.RemoveNIC(id)
+		return err
+	}
+	return nil
+}
+
 func (s *Stack) newInterface(ctx context.Context, msg *nlmsg.Message, linkAttrs map[uint16]nlmsg.BytesView) *syserr.Error {
 	var (
 		linkInfoAttrs map[uint16]nlmsg.BytesView
@@ -388,6 +713,12 @@ func (s *Stack) newInterface(ctx context.Context, msg *nlmsg.Message, linkAttrs
 		return s.newBridge(ctx, linkAttrs, linkInfoAttrs)
 	case "veth":
 		return s.newVeth(ctx, linkAttrs, linkInfoAttrs)
+	case "vlan":
+		return s.newVLAN(ctx, linkAttrs, linkInfoAttrs)
+	case "vxlan":
+		return s.newVXLAN(ctx, linkAttrs, linkInfoAttrs)
+	case "wireguard":
+		return s.newWireGuard(ctx, linkAttrs, linkInfoAttrs)
 	}
 	return syserr.ErrNotSupported
 }
@@ -422,6 +753,43 @@ func (s *Stack) InterfaceAddrs() map[int32][]inet.InterfaceAddr {
 	return nicAddrs
 }
 
+// MulticastGroups implements inet.Stack.MulticastGroups.
+func (s *Stack) MulticastGroups() map[int32][]inet.MulticastGroup {
+	protocols := []struct {
+		protocol tcpip.NetworkProtocolNumber
+		family   uint8
+	}{
+		{ipv4.ProtocolNumber, linux.AF_INET},
+		{ipv6.ProtocolNumber, linux.AF_INET6},
+	}
+	nicGroups := make(map[int32][]inet.MulticastGroup)
+	for id := range s.Stack.NICInfo() {
+		var groups []inet.MulticastGroup
+		for _, p := range protocols {
+			joined, err := s.Stack.JoinedGroups(p.protocol, id)
+			if err != nil {
+				// The protocol isn't enabled on the NIC.
+				continue
+			}
+			for addr, users := range joined {
+				groups = append(groups, inet.MulticastGroup{
+					Family: p.family,
+					Addr:   addr.AsSlice(),
+					Users:  users,
+				})
+			}
+		}
+		slices.SortFunc(groups, func(a, b inet.MulticastGroup) int {
+			if a.Family != b.Family {
+				return int(a.Family) - int(b.Family)
+			}
+			return bytes.Compare(a.Addr, b.Addr)
+		})
+		nicGroups[int32(id)] = groups
+	}
+	return nicGroups
+}
+
 // convertAddr converts an InterfaceAddr to a ProtocolAddress.
 func convertAddr(addr inet.InterfaceAddr) (tcpip.ProtocolAddress, error) {
 	var (
@@ -712,12 +1080,120 @@ func (s *Stack) Statistics(stat any, arg string) error {
 			udp.ChecksumErrors.Value(),      // Udp/InCsumErrors.
 			0,                               // Udp/IgnoredMulti.
 		}
+	case *inet.StatSNMP6IP:
+		// netStats.IP aggregates IPv4 and IPv6, so sum the stats of the IPv6
+		// endpoints instead.
+		var (
+			inReceives, inHdrErrors, inTooBigErrors, inNoRoutes uint64
+			inAddrErrors, inDelivers, outRequests, outDiscards  uint64
+		)
+		for _, ni := range s.Stack.NICInfo() {
+			st, ok := ni.NetworkStats[ipv6.ProtocolNumber].(*ipv6.Stats)
+			if !ok {
+				continue
+			}
+			ip := &st.IP
+			inReceives += ip.PacketsReceived.Value()
+			inHdrErrors += ip.MalformedPacketsReceived.Value()
+			inTooBigErrors += ip.Forwarding.PacketTooBig.Value()
+			inNoRoutes += ip.Forwarding.Unrouteable.Value()
+			inAddrErrors += ip.InvalidDestinationAddressesReceived.Value()
+			inDelivers += ip.PacketsDelivered.Value()
+			outRequests += ip.PacketsSent.Value()
+			outDiscards += ip.OutgoingPacketErrors.Value()
+		}
+		// TODO(gvisor.dev/issue/969) Support stubbed stats.
+		*stats = inet.StatSNMP6IP{
+			inReceives,     // Ip6InReceives.
+			inHdrErrors,    // Ip6InHdrErrors.
+			inTooBigErrors, // Ip6InTooBigErrors.
+			inNoRoutes,     // Ip6InNoRoutes.
+			inAddrErrors,   // Ip6InAddrErrors.
+			0,              // Ip6InUnknownProtos.
+			0,              // Ip6InTruncatedPkts.
+			0,              // Ip6InDiscards.
+			inDelivers,     // Ip6InDelivers.
+			0,              // Ip6OutForwDatagrams.
+			outRequests,    // Ip6OutRequests.
+			outDiscards,    // Ip6OutDiscards.
+			0,              // Ip6OutNoRoutes.
+			0,              // Ip6ReasmTimeout.
+			0,              // Ip6ReasmReqds.
+			0,              // Ip6ReasmOKs.
+			0,              // Ip6ReasmFails.
+			0,              // Ip6FragOKs.
+			0,              // Ip6FragFails.
+			0,              // Ip6FragCreates.
+			0,              // Ip6InMcastPkts.
+			0,              // Ip6OutMcastPkts.
+			0,              // Ip6InOctets.
+			0,              // Ip6OutOctets.
+			0,              // Ip6InMcastOctets.
+			0,              // Ip6OutMcastOctets.
+			0,              // Ip6InBcastOctets.
+			0,              // Ip6OutBcastOctets.
+			0,              // Ip6InNoECTPkts.
+			0,              // Ip6InECT1Pkts.
+			0,              // Ip6InECT0Pkts.
+			0,              // Ip6InCEPkts.
+		}
+	case *inet.StatSNMP6ICMP:
+		icmp := netStats.ICMP.V6
+		in := icmp.PacketsReceived.ICMPv6PacketStats
+		out := icmp.PacketsSent.ICMPv6PacketStats
+		inTypes := icmpv6TypeStats(in)
+		outTypes := icmpv6TypeStats(out)
+		inMsgs := icmp.PacketsReceived.Invalid.Value() + icmp.PacketsReceived.Unrecognized.Value()
+		var outMsgs uint64
+		for i := range inTypes {
+			inMsgs += inTypes[i]
+			outMsgs += outTypes[i]
+		}
+		*stats = inet.StatSNMP6ICMP{
+			inMsgs,                               // Icmp6InMsgs.
+			icmp.PacketsReceived.Invalid.Value(), // Icmp6InErrors.
+			outMsgs,                              // Icmp6OutMsgs.
+			icmp.PacketsSent.Dropped.Value(),     // Icmp6OutErrors.
+			0,                                    // Icmp6InCsumErrors.
+		}
+		copy(stats[5:], inTypes[:])
+		copy(stats[5+len(inTypes):], outTypes[:])
+	case *inet.StatSNMP6UDP:
+		// netStats.UDP aggregates IPv4 and IPv6 and is already reported in
+		// /proc/net/snmp, so IPv6-only counters aren't available.
+		// TODO(gvisor.dev/issue/969) Split UDP stats by network protocol.
+		return syserr.ErrEndpointOperation.ToError()
+	case *inet.StatSNMP6UDPLite:
+		// netstack doesn't support UDP-Lite, so all counters are 0.
+		*stats = inet.StatSNMP6UDPLite{}
 	default:
 		return syserr.ErrEndpointOperation.ToError()
 	}
 	return nil
 }
 
+// icmpv6TypeStats returns the per-type ICMPv6 counters in the order of the
+// Icmp6In* and Icmp6Out* lines of /proc/net/snmp6.
+func icmpv6TypeStats(s tcpip.ICMPv6PacketStats) [15]uint64 {
+	return [15]uint64{
+		s.DstUnreachable.Value(),            // DestUnreachs.
+		s.PacketTooBig.Value(),              // PktTooBigs.
+		s.TimeExceeded.Value(),              // TimeExcds.
+		s.ParamProblem.Value(),              // ParmProblems.
+		s.EchoRequest.Value(),               // Echos.
+		s.EchoReply.Value(),                 // EchoReplies.
+		s.MulticastListenerQuery.Value(),    // GroupMembQueries.
+		s.MulticastListenerReport.Value(),   // GroupMembResponses.
+		s.MulticastListenerDone.Value(),     // GroupMembReductions.
+		s.RouterSolicit.Value(),             // RouterSolicits.
+		s.RouterAdvert.Value(),              // RouterAdvertisements.
+		s.NeighborSolicit.Value(),           // NeighborSolicits.
+		s.NeighborAdvert.Value(),            // NeighborAdvertisements.
+		s.RedirectMsg.Value(),               // Redirects.
+		s.MulticastListenerReportV2.Value(), // MLDv2Reports.
+	}
+}
+
 // Stats implements inet.Stack.Stats.
 func (s *Stack) Stats() tcpip.Stats {
 	return s.Stack.Stats()
@@ -756,6 +1232,8 @@ func (s *Stack) RouteTable() []inet.Route {
 			DstAddr:         dstAddr.AsSlice(),
 			OutputInterface: int32(rt.NIC),
 			GatewayAddr:     rt.Gateway.AsSlice(),
+			Table:           rt.TableID(),
+			Priority:        rt.Metric,
 		})
 	}
 
@@ -775,7 +1253,7 @@ func (s *Stack) localRoute(msg *nlmsg.Message) (tcpip.Route, *syserr.Error) {
 		DstLen:   rtMsg.DstLen,
 		SrcLen:   rtMsg.SrcLen,
 		TOS:      rtMsg.TOS,
-		Table:    rtMsg.Table,
+		Table:    uint32(rtMsg.Table),
 		Protocol: rtMsg.Protocol,
 		Scope:    rtMsg.Scope,
 		Type:     rtMsg.Type,
@@ -816,6 +1294,19 @@ func (s *Stack) localRoute(msg *nlmsg.Message) (tcpip.Route, *syserr.Error) {
 			}
 			route.GatewayAddr = value
 		case linux.RTA_PRIORITY:
+			v := nlmsg.BytesView(value)
+			priority, ok := v.Uint32()
+			if !ok {
+				return tcpip.Route{}, syserr.ErrInvalidArgument
+			}
+			route.Priority = priority
+		case linux.RTA_TABLE:
+			v := nlmsg.BytesView(value)
+			table, ok := v.Uint32()
+			if !ok {
+				return tcpip.Route{}, syserr.ErrInvalidArgument
+			}
+			route.Table = table
 		default:
 			log.Warningf("Unknown attribute: %v", ahdr.Type)
 			return tcpip.Route{}, syserr.ErrNotSupported
@@ -853,6 +1344,8 @@ func (s *Stack) localRoute(msg *nlmsg.Message) (tcpip.Route, *syserr.Error) {
 		Destination: dest,
 		Gateway:     tcpip.AddrFromSlice(route.GatewayAddr),
 		NIC:         tcpip.NICID(route.OutputInterface),
+		Table:       route.Table,
+		Metric:      route.Priority,
 	}
 
 	if len(route.SrcAddr) != 0 {
@@ -877,6 +1370,12 @@ func (s *Stack) RemoveRoute(ctx context.Context, msg *nlmsg.Message) *syserr.Err
 		if localRoute.NIC > 0 && localRoute.NIC != rt.NIC {
 			return false
 		}
+		if localRoute.Metric > 0 && localRoute.Metric != rt.Metric {
+			return false
+		}
+		if localRoute.TableID() != rt.TableID() {
+			return false
+		}
 		return rt.Destination.Equal(localRoute.Destination)
 	}); removed == 0 {
 		return syserr.ErrNoProcess
@@ -910,6 +1409,424 @@ func (s *Stack) NewRoute(ctx context.Context, msg *nlmsg.Message) *syserr.Error
 	return nil
 }
 
+// RoutingRules implements inet.Stack.RoutingRules.
+func (s *Stack) RoutingRules() []inet.RoutingRule {
+	var rules []inet.RoutingRule
+	for _, r := range s.Stack.GetRoutingRules() {
+		rule := inet.RoutingRule{
+			Family:   linux.AF_UNSPEC,
+			Action:   uint8(r.Action),
+			Table:    r.Table,
+			Priority: r.Priority,
+			Mark:     r.Mark,
+			MarkMask: r.MarkMask,
+		}
+		switch r.Protocol {
+		case ipv4.ProtocolNumber:
+			rule.Family = linux.AF_INET
+		case ipv6.ProtocolNumber:
+			rule.Family = linux.AF_INET6
+		}
+		if r.Invert {
+			rule.Flags |= linux.FIB_RULE_INVERT
+		}
+		if prefix := r.Source.Prefix(); prefix != 0 {
+			addr := r.Source.ID()
+			rule.SrcLen = uint8(prefix)
+			rule.SrcAddr = addr.AsSlice()
+		}
+		if prefix := r.Destination.Prefix(); prefix != 0 {
+			addr := r.Destination.ID()
+			rule.DstLen = uint8(prefix)
+			rule.DstAddr = addr.AsSlice()
+		}
+		if r.InputNIC != 0 {
+			rule.InputInterface = s.Stack.FindNICNameFromID(r.InputNIC)
+		}
+		if r.OutputNIC != 0 {
+			rule.OutputInterface = s.Stack.FindNICNameFromID(r.OutputNIC)
+		}
+		if r.MatchUID {
+			rule.HasUIDRange = true
+			rule.UIDRange = linux.FibRuleUIDRange{Start: r.UIDStart, End: r.UIDEnd}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// nicIDByName returns the ID of the NIC with the given netlink attribute
+// name.
+func (s *Stack) nicIDByName(value []byte) (tcpip.NICID, *syserr.Error) {
+	name := string(bytes.TrimRight(value, "\x00"))
+	for id, iface := range s.Interfaces() {
+		if iface.Name == name {
+			return tcpip.NICID(id), nil
+		}
+	}
+	return 0, syserr.ErrNoDevice
+}
+
+// routingRule constructs a routing rule from the netlink message. It also
+// returns whether the message specified the rule priority.
+func (s *Stack) routingRule(msg *nlmsg.Message) (tcpip.RoutingRule, bool, *syserr.Error) {
+	var hdr linux.FibRuleHeader
+	attrs, ok := msg.GetData(&hdr)
+	if !ok {
+		return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+	}
+
+	rule := tcpip.RoutingRule{
+		Action: tcpip.RoutingRuleAction(hdr.Action),
+		Table:  uint32(hdr.Table),
+		Invert: hdr.Flags&linux.FIB_RULE_INVERT != 0,
+	}
+	var addrLen int
+	switch hdr.Family {
+	case linux.AF_INET:
+		rule.Protocol = ipv4.ProtocolNumber
+		addrLen = header.IPv4AddressSize
+	case linux.AF_INET6:
+		rule.Protocol = ipv6.ProtocolNumber
+		addrLen = header.IPv6AddressSize
+	default:
+		return tcpip.RoutingRule{}, false, syserr.ErrAddressFamilyNotSupported
+	}
+
+	hasPriority := false
+	for !attrs.Empty() {
+		ahdr, value, rest, ok := attrs.ParseFirst()
+		if !ok {
+			return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+		}
+		attrs = rest
+
+		v := nlmsg.BytesView(value)
+		switch ahdr.Type {
+		case linux.FRA_SRC, linux.FRA_DST:
+			prefixLen := hdr.SrcLen
+			if ahdr.Type == linux.FRA_DST {
+				prefixLen = hdr.DstLen
+			}
+			if len(value) != addrLen || int(prefixLen) > addrLen*8 {
+				return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+			}
+			subnet := tcpip.AddressWithPrefix{
+				Address:   tcpip.AddrFromSlice(value),
+				PrefixLen: int(prefixLen),
+			}.Subnet()
+			if ahdr.Type == linux.FRA_SRC {
+				rule.Source = subnet
+			} else {
+				rule.Destination = subnet
+			}
+		case linux.FRA_IIFNAME:
+			id, err := s.nicIDByName(value)
+			if err != nil {
+				return tcpip.RoutingRule{}, false, err
+			}
+			rule.InputNIC = id
+		case linux.FRA_OIFNAME:
+			id, err := s.nicIDByName(value)
+			if err != nil {
+				return tcpip.RoutingRule{}, false, err
+			}
+			rule.OutputNIC = id
+		case linux.FRA_PRIORITY:
+			if rule.Priority, ok = v.Uint32(); !ok {
+				return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+			}
+			hasPriority = true
+		case linux.FRA_TABLE:
+			if rule.Table, ok = v.Uint32(); !ok {
+				return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+			}
+		case linux.FRA_FWMARK:
+			if rule.Mark, ok = v.Uint32(); !ok {
+				return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+			}
+		case linux.FRA_FWMASK:
+			if rule.MarkMask, ok = v.Uint32(); !ok {
+				return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+			}
+		case linux.FRA_UID_RANGE:
+			var uidRange linux.FibRuleUIDRange
+			if len(value) < uidRange.SizeBytes() {
+				return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+			}
+			uidRange.UnmarshalUnsafe(value)
+			if uidRange.Start > uidRange.End {
+				return tcpip.RoutingRule{}, false, syserr.ErrInvalidArgument
+			}
+			rule.MatchUID = true
+			rule.UIDStart = uidRange.Start
+			rule.UIDEnd = uidRange.End
+		case linux.FRA_PROTOCOL:
+			// The rule origin is informational only.
+		default:
+			log.Warningf("Unknown routing rule attribute: %v", ahdr.Type)
+			return tcpip.RoutingRule{}, false, syserr.ErrNotSupported
+		}
+	}
+	return rule, hasPriority, nil
+}
+
+// NewRoutingRule implements inet.Stack.NewRoutingRule.
+func (s *Stack) NewRoutingRule(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	rule, hasPriority, err := s.routingRule(msg)
+	if err != nil {
+		return err
+	}
+	switch rule.Action {
+	case tcpip.RoutingRuleLookup:
+		if rule.Table == linux.RT_TABLE_UNSPEC {
+			return syserr.ErrInvalidArgument
+		}
+	case tcpip.RoutingRuleBlackhole, tcpip.RoutingRuleUnreachable, tcpip.RoutingRuleProhibit:
+	default:
+		return syserr.ErrNotSupported
+	}
+
+	s.routingRulesMu.Lock()
+	defer s.routingRulesMu.Unlock()
+	rules := s.Stack.GetRoutingRules()
+	if !hasPriority {
+		// As in Linux, rules without a priority are inserted before the
+		// first rule with a non-zero priority. See
+		// net/core/fib_rules.c:fib_default_rule_pref.
+		for _, r := range rules {
+			if (r.Protocol == 0 || r.Protocol == rule.Protocol) && r.Priority != 0 {
+				rule.Priority = r.Priority - 1
+				break
+			}
+		}
+	}
+	if msg.Header().Flags&linux.NLM_F_EXCL != 0 {
+		for _, r := range rules {
+			if r == rule {
+				return syserr.ErrExists
+			}
+		}
+	}
+	s.Stack.AddRoutingRule(rule)
+	return nil
+}
+
+// RemoveRoutingRule implements inet.Stack.RemoveRoutingRule.
+func (s *Stack) RemoveRoutingRule(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	rule, hasPriority, err := s.routingRule(msg)
+	if err != nil {
+		return err
+	}
+
+	s.routingRulesMu.Lock()
+	defer s.routingRulesMu.Unlock()
+	rules := s.Stack.GetRoutingRules()
+	// Attributes that are not specified in the message match any rule. Only
+	// the first matching rule is removed.
+	for i, r := range rules {
+		switch {
+		case r.Protocol != 0 && r.Protocol != rule.Protocol,
+			hasPriority && r.Priority != rule.Priority,
+			rule.Action != 0 && r.Action != rule.Action,
+			rule.Table != 0 && r.Table != rule.Table,
+			rule.Source.Prefix() != 0 && r.Source != rule.Source,
+			rule.Destination.Prefix() != 0 && r.Destination != rule.Destination,
+			rule.InputNIC != 0 && r.InputNIC != rule.InputNIC,
+			rule.OutputNIC != 0 && r.OutputNIC != rule.OutputNIC,
+			rule.Mark != 0 && r.Mark != rule.Mark,
+			rule.MarkMask != 0 && r.MarkMask != rule.MarkMask,
+			rule.MatchUID && (r.UIDStart != rule.UIDStart || r.UIDEnd != rule.UIDEnd),
+			r.Invert != rule.Invert:
+			continue
+		}
+		if r.Protocol == 0 {
+			// The default rules apply to all protocols. Keep the rule for
+			// the other IP protocol.
+			r.Protocol = ipv4.ProtocolNumber
+			if rule.Protocol == ipv4.ProtocolNumber {
+				r.Protocol = ipv6.ProtocolNumber
+			}
+			rules[i] = r
+		} else {
+			rules = append(rules[:i], rules[i+1:]...)
+		}
+		s.Stack.SetRoutingRules(rules)
+		return nil
+	}
+	return syserr.ErrNoFileOrDir
+}
+
+// neighborStates maps netstack neighbor states to Linux NUD_* states.
+var neighborStates = map[stack.NeighborState]uint16{
+	stack.Unknown:     linux.NUD_NONE,
+	stack.Incomplete:  linux.NUD_INCOMPLETE,
+	stack.Reachable:   linux.NUD_REACHABLE,
+	stack.Stale:       linux.NUD_STALE,
+	stack.Delay:       linux.NUD_DELAY,
+	stack.Probe:       linux.NUD_PROBE,
+	stack.Static:      linux.NUD_PERMANENT,
+	stack.Unreachable: linux.NUD_FAILED,
+}
+
+// Neighbors implements inet.Stack.Neighbors.
+func (s *Stack) Neighbors() map[int32][]inet.Neighbor {
+	protocols := []struct {
+		protocol tcpip.NetworkProtocolNumber
+		family   uint8
+	}{
+		{ipv4.ProtocolNumber, linux.AF_INET},
+		{ipv6.ProtocolNumber, linux.AF_INET6},
+	}
+	nicNeighs := make(map[int32][]inet.Neighbor)
+	for id := range s.Stack.NICInfo() {
+		var neighs []inet.Neighbor
+		for _, p := range protocols {
+			entries, err := s.Stack.Neighbors(id, p.protocol)
+			if err != nil {
+				// The NIC doesn't resolve link addresses for the protocol.
+				continue
+			}
+			for _, e := range entries {
+				neighs = append(neighs, inet.Neighbor{
+					Family:   p.family,
+					State:    neighborStates[e.State],
+					Addr:     e.Addr.AsSlice(),
+					LinkAddr: []byte(e.LinkAddr),
+				})
+			}
+		}
+		if len(neighs) == 0 {
+			continue
+		}
+		slices.SortFunc(neighs, func(a, b inet.Neighbor) int {
+			if a.Family != b.Family {
+				return int(a.Family) - int(b.Family)
+			}
+			return bytes.Compare(a.Addr, b.Addr)
+		})
+		nicNeighs[int32(id)] = neighs
+	}
+	return nicNeighs
+}
+
+// neighbor parses a RTM_NEWNEIGH or RTM_DELNEIGH message. It returns the
+// NIC, network protocol, state, and network and link addresses of the entry.
+func (s *Stack) neighbor(msg *nlmsg.Message) (tcpip.NICID, tcpip.NetworkProtocolNumber, uint16, tcpip.Address, tcpip.LinkAddress, *syserr.Error) {
+	var ndMsg linux.NeighborMessage
+	attrs, ok := msg.GetData(&ndMsg)
+	if !ok {
+		return 0, 0, 0, tcpip.Address{}, "", syserr.ErrInvalidArgument
+	}
+
+	var (
+		protocol tcpip.NetworkProtocolNumber
+		addrLen  int
+	)
+	switch ndMsg.Family {
+	case linux.AF_INET:
+		protocol, addrLen = ipv4.ProtocolNumber, header.IPv4AddressSize
+	case linux.AF_INET6:
+		protocol, addrLen = ipv6.ProtocolNumber, header.IPv6AddressSize
+	default:
+		return 0, 0, 0, tcpip.Address{}, "", syserr.ErrAddressFamilyNotSupported
+	}
+	if ndMsg.Flags&linux.NTF_PROXY != 0 {
+		// Proxy entries are not supported.
+		return 0, 0, 0, tcpip.Address{}, "", syserr.ErrNotSupported
+	}
+	id := tcpip.NICID(ndMsg.Index)
+	if ndMsg.Index <= 0 || !s.Stack.HasNIC(id) {
+		return 0, 0, 0, tcpip.Address{}, "", syserr.ErrNoDevice
+	}
+
+	var (
+		addr     tcpip.Address
+		linkAddr tcpip.LinkAddress
+	)
+	for !attrs.Empty() {
+		ahdr, value, rest, ok := attrs.ParseFirst()
+		if !ok {
+			return 0, 0, 0, tcpip.Address{}, "", syserr.ErrInvalidArgument
+		}
+		attrs = rest
+
+		switch ahdr.Type {
+		case linux.NDA_DST:
+			if len(value) != addrLen {
+				return 0, 0, 0, tcpip.Address{}, "", syserr.ErrInvalidArgument
+			}
+			addr = tcpip.AddrFromSlice(value)
+		case linux.NDA_LLADDR:
+			linkAddr = tcpip.LinkAddress(value)
+		case linux.NDA_PROTOCOL:
+			// The origin of the entry isn't tracked.
+		default:
+			log.Warningf("Unknown neighbor attribute: %v", ahdr.Type)
+			return 0, 0, 0, tcpip.Address{}, "", syserr.ErrNotSupported
+		}
+	}
+	if addr.Len() == 0 {
+		return 0, 0, 0, tcpip.Address{}, "", syserr.ErrInvalidArgument
+	}
+	return id, protocol, ndMsg.State, addr, linkAddr, nil
+}
+
+// NewNeighbor implements inet.Stack.NewNeighbor.
+func (s *Stack) NewNeighbor(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	id, protocol, state, addr, linkAddr, err := s.neighbor(msg)
+	if err != nil {
+		return err
+	}
+	// Netstack's neighbor cache manages the states of dynamic entries
+	// itself, so only permanent entries can be added.
+	if state&(linux.NUD_PERMANENT|linux.NUD_NOARP) == 0 {
+		return syserr.ErrNotSupported
+	}
+	if len(linkAddr) == 0 {
+		return syserr.ErrInvalidArgument
+	}
+
+	entries, tcpipErr := s.Stack.Neighbors(id, protocol)
+	if tcpipErr != nil {
+		return syserr.TranslateNetstackError(tcpipErr)
+	}
+	found := false
+	for _, e := range entries {
+		if e.Addr == addr {
+			found = true
+			break
+		}
+	}
+	flags := msg.Header().Flags
+	switch {
+	case found && flags&linux.NLM_F_EXCL != 0:
+		return syserr.ErrExists
+	case !found && flags&linux.NLM_F_CREATE == 0:
+		return syserr.ErrNoFileOrDir
+	}
+	if tcpipErr := s.Stack.AddStaticNeighbor(id, protocol, addr, linkAddr); tcpipErr != nil {
+		return syserr.TranslateNetstackError(tcpipErr)
+	}
+	return nil
+}
+
+// RemoveNeighbor implements inet.Stack.RemoveNeighbor.
+func (s *Stack) RemoveNeighbor(ctx context.Context, msg *nlmsg.Message) *syserr.Error {
+	id, protocol, _, addr, _, err := s.neighbor(msg)
+	if err != nil {
+		return err
+	}
+	switch tcpipErr := s.Stack.RemoveNeighbor(id, protocol, addr); tcpipErr.(type) {
+	case nil:
+		return nil
+	case *tcpip.ErrBadAddress:
+		return syserr.ErrNoFileOrDir
+	default:
+		return syserr.TranslateNetstackError(tcpipErr)
+	}
+}
+
 // IPTables returns the stack's iptables.
 func (s *Stack) IPTables() (*stack.IPTables, error) {
 	return s.Stack.IPTables(), nil
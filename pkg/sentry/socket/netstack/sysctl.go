@@ -0,0 +1,258 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netstack
+
+import (
+	"time"
+
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/syserr"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
+)
+
+// defaultSomaxconn is the default value of net.core.somaxconn. It matches the
+// listen backlog limit used when the stack doesn't implement the sysctl.
+const defaultSomaxconn = 1024
+
+// Sysctl implements inet.Stack.Sysctl.
+func (s *Stack) Sysctl(name string) (inet.SysctlValue, error) {
+	switch name {
+	case "core/rmem_default", "core/rmem_max":
+		var opt tcpip.ReceiveBufferSizeOption
+		if err := s.Stack.Option(&opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		if name == "core/rmem_default" {
+			return inet.IntSysctl(int64(opt.Default)), nil
+		}
+		return inet.IntSysctl(int64(opt.Max)), nil
+
+	case "core/wmem_default", "core/wmem_max":
+		var opt tcpip.SendBufferSizeOption
+		if err := s.Stack.Option(&opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		if name == "core/wmem_default" {
+			return inet.IntSysctl(int64(opt.Default)), nil
+		}
+		return inet.IntSysctl(int64(opt.Max)), nil
+
+	case "core/somaxconn":
+		s.sysctlMu.Lock()
+		defer s.sysctlMu.Unlock()
+		if s.somaxconn == nil {
+			return inet.IntSysctl(defaultSomaxconn), nil
+		}
+		return inet.IntSysctl(int64(*s.somaxconn)), nil
+
+	case "ipv4/ip_default_ttl":
+		var opt tcpip.DefaultTTLOption
+		if err := s.Stack.NetworkProtocolOption(ipv4.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(opt)), nil
+
+	case "ipv4/tcp_available_congestion_control":
+		var opt tcpip.TCPAvailableCongestionControlOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.SysctlValue{Str: string(opt)}, nil
+
+	case "ipv4/tcp_congestion_control":
+		var opt tcpip.CongestionControlOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.SysctlValue{Str: string(opt)}, nil
+
+	case "ipv4/tcp_fin_timeout":
+		var opt tcpip.TCPLingerTimeoutOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(time.Duration(opt) / time.Second)), nil
+
+	case "ipv4/tcp_keepalive_intvl":
+		var opt tcpip.KeepaliveIntervalOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(time.Duration(opt) / time.Second)), nil
+
+	case "ipv4/tcp_keepalive_probes":
+		var opt tcpip.TCPKeepaliveCountOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(opt)), nil
+
+	case "ipv4/tcp_keepalive_time":
+		var opt tcpip.KeepaliveIdleOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(time.Duration(opt) / time.Second)), nil
+
+	case "ipv4/tcp_moderate_rcvbuf":
+		var opt tcpip.TCPModerateReceiveBufferOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(boolToInt32(bool(opt)))), nil
+
+	case "ipv4/tcp_retries2":
+		var opt tcpip.TCPMaxRetriesOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(opt)), nil
+
+	case "ipv4/tcp_syn_retries":
+		var opt tcpip.TCPSynRetriesOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(opt)), nil
+
+	case "ipv4/tcp_syncookies":
+		// netstack always falls back to SYN cookies when the accept queue
+		// overflows, which is Linux's default (1); 2 sends them
+		// unconditionally. SYN cookies can't be disabled (0), so writing 0
+		// is rejected by the sysctl's minimum.
+		var opt tcpip.TCPAlwaysUseSynCookies
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(1 + int64(boolToInt32(bool(opt)))), nil
+
+	case "ipv4/tcp_timestamps":
+		var opt tcpip.TCPTimestampsEnabled
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(boolToInt32(bool(opt)))), nil
+
+	case "ipv4/tcp_tw_reuse":
+		var opt tcpip.TCPTimeWaitReuseOption
+		if err := s.Stack.TransportProtocolOption(tcp.ProtocolNumber, &opt); err != nil {
+			return inet.SysctlValue{}, syserr.TranslateNetstackError(err).ToError()
+		}
+		return inet.IntSysctl(int64(opt)), nil
+
+	default:
+		return inet.SysctlValue{}, linuxerr.EOPNOTSUPP
+	}
+}
+
+// SetSysctl implements inet.Stack.SetSysctl.
+func (s *Stack) SetSysctl(name string, v inet.SysctlValue) error {
+	var n int64
+	if len(v.Ints) > 0 {
+		n = v.Ints[0]
+	}
+	switch name {
+	case "core/rmem_default", "core/rmem_max":
+		var opt tcpip.ReceiveBufferSizeOption
+		if err := s.Stack.Option(&opt); err != nil {
+			return syserr.TranslateNetstackError(err).ToError()
+		}
+		// Like Linux, clamp values below the minimum buffer size rather than
+		// failing.
+		size := max(int(n), opt.Min)
+		if name == "core/rmem_default" {
+			opt.Default = size
+		} else {
+			opt.Max = size
+			opt.Default = min(opt.Default, size)
+		}
+		return syserr.TranslateNetstackError(s.Stack.SetOption(opt)).ToError()
+
+	case "core/wmem_default", "core/wmem_max":
+		var opt tcpip.SendBufferSizeOption
+		if err := s.Stack.Option(&opt); err != nil {
+			return syserr.TranslateNetstackError(err).ToError()
+		}
+		size := max(int(n), opt.Min)
+		if name == "core/wmem_default" {
+			opt.Default = size
+		} else {
+			opt.Max = size
+			opt.Default = min(opt.Default, size)
+		}
+		return syserr.TranslateNetstackError(s.Stack.SetOption(opt)).ToError()
+
+	case "core/somaxconn":
+		somaxconn := int32(n)
+		s.sysctlMu.Lock()
+		s.somaxconn = &somaxconn
+		s.sysctlMu.Unlock()
+		return nil
+
+	case "ipv4/ip_default_ttl":
+		opt := tcpip.DefaultTTLOption(n)
+		return syserr.TranslateNetstackError(s.Stack.SetNetworkProtocolOption(ipv4.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_congestion_control":
+		opt := tcpip.CongestionControlOption(v.Str)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_fin_timeout":
+		opt := tcpip.TCPLingerTimeoutOption(time.Duration(n) * time.Second)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_keepalive_intvl":
+		opt := tcpip.KeepaliveIntervalOption(time.Duration(n) * time.Second)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_keepalive_probes":
+		opt := tcpip.TCPKeepaliveCountOption(n)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_keepalive_time":
+		opt := tcpip.KeepaliveIdleOption(time.Duration(n) * time.Second)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_moderate_rcvbuf":
+		opt := tcpip.TCPModerateReceiveBufferOption(n != 0)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_retries2":
+		opt := tcpip.TCPMaxRetriesOption(n)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_syn_retries":
+		opt := tcpip.TCPSynRetriesOption(n)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_syncookies":
+		opt := tcpip.TCPAlwaysUseSynCookies(n == 2)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_timestamps":
+		opt := tcpip.TCPTimestampsEnabled(n != 0)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	case "ipv4/tcp_tw_reuse":
+		opt := tcpip.TCPTimeWaitReuseOption(n)
+		return syserr.TranslateNetstackError(s.Stack.SetTransportProtocolOption(tcp.ProtocolNumber, &opt)).ToError()
+
+	default:
+		return linuxerr.EOPNOTSUPP
+	}
+}
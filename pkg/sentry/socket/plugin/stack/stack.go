@@ -21,10 +21,13 @@ package stack
 import (
 	"fmt"
 
+	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netlink/nlmsg"
 	"gvisor.dev/gvisor/pkg/sentry/socket/plugin"
 	"gvisor.dev/gvisor/pkg/sentry/socket/plugin/cgo"
+	"gvisor.dev/gvisor/pkg/syserr"
 )
 
 // Stack is a struct that interacts with third-party network stack.
@@ -64,6 +67,11 @@ func (s *Stack) InterfaceAddrs() map[int32][]inet.InterfaceAddr {
 	return make(map[int32][]inet.InterfaceAddr)
 }
 
+// MulticastGroups implements inet.Stack.MulticastGroups.
+func (s *Stack) MulticastGroups() map[int32][]inet.MulticastGroup {
+	return make(map[int32][]inet.MulticastGroup)
+}
+
 // AddInterfaceAddr implements inet.Stack.AddInterfaceAddr.
 func (s *Stack) AddInterfaceAddr(idx int32, addr inet.InterfaceAddr) error {
 	return linuxerr.EACCES
@@ -74,6 +82,56 @@ func (s *Stack) RemoveInterfaceAddr(int32, inet.InterfaceAddr) error {
 	return linuxerr.EACCES
 }
 
+// SetInterfaceMTU implements inet.Stack.SetInterfaceMTU.
+func (s *Stack) SetInterfaceMTU(int32, uint32) error {
+	return linuxerr.EACCES
+}
+
+// SetInterfaceTxQueueLen implements inet.Stack.SetInterfaceTxQueueLen.
+func (s *Stack) SetInterfaceTxQueueLen(int32, uint32) error {
+	return linuxerr.EACCES
+}
+
+// RoutingRules implements inet.Stack.RoutingRules.
+func (s *Stack) RoutingRules() []inet.RoutingRule {
+	return nil
+}
+
+// NewRoutingRule implements inet.Stack.NewRoutingRule.
+func (s *Stack) NewRoutingRule(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
+// RemoveRoutingRule implements inet.Stack.RemoveRoutingRule.
+func (s *Stack) RemoveRoutingRule(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
+// Neighbors implements inet.Stack.Neighbors.
+func (s *Stack) Neighbors() map[int32][]inet.Neighbor {
+	return make(map[int32][]inet.Neighbor)
+}
+
+// NewNeighbor implements inet.Stack.NewNeighbor.
+func (s *Stack) NewNeighbor(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
+// RemoveNeighbor implements inet.Stack.RemoveNeighbor.
+func (s *Stack) RemoveNeighbor(context.Context, *nlmsg.Message) *syserr.Error {
+	return syserr.ErrNotSupported
+}
+
+// Sysctl implements inet.Stack.Sysctl.
+func (s *Stack) Sysctl(string) (inet.SysctlValue, error) {
+	return inet.SysctlValue{}, linuxerr.EOPNOTSUPP
+}
+
+// SetSysctl implements inet.Stack.SetSysctl.
+func (s *Stack) SetSysctl(string, inet.SysctlValue) error {
+	return linuxerr.EOPNOTSUPP
+}
+
 // SupportsIPv6 implements Stack.SupportsIPv6.
 func (s *Stack) SupportsIPv6() bool {
 	return true
@@ -85,6 +85,10 @@ var CloneFlagSet = abi.FlagSet{
 		Flag: linux.CLONE_CHILD_SETTID,
 		Name: "CLONE_CHILD_SETTID",
 	},
+	{
+		Flag: linux.CLONE_NEWCGROUP,
+		Name: "CLONE_NEWCGROUP",
+	},
 	{
 		Flag: linux.CLONE_NEWUTS,
 		Name: "CLONE_NEWUTS",
@@ -109,4 +113,8 @@ var CloneFlagSet = abi.FlagSet{
 		Flag: linux.CLONE_IO,
 		Name: "CLONE_IO",
 	},
+	{
+		Flag: linux.CLONE_NEWTIME,
+		Name: "CLONE_NEWTIME",
+	},
 }
@@ -108,7 +108,7 @@ var AMD64 = &kernel.SyscallTable{
 		53:  syscalls.SupportedPoint("socketpair", SocketPair, PointSocketpair),
 		54:  syscalls.Supported("setsockopt", SetSockOpt),
 		55:  syscalls.Supported("getsockopt", GetSockOpt),
-		56:  syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_PARENT, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
+		56:  syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PIDFD, CLONE_PARENT, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
 		57:  syscalls.SupportedPoint("fork", Fork, PointFork),
 		58:  syscalls.SupportedPoint("vfork", Vfork, PointVfork),
 		59:  syscalls.SupportedPoint("execve", Execve, PointExecve),
@@ -305,9 +305,9 @@ var AMD64 = &kernel.SyscallTable{
 		250: syscalls.PartiallySupported("keyctl", Keyctl, "Only supports session keyrings with zero keys in them.", nil),
 		251: syscalls.CapError("ioprio_set", linux.CAP_SYS_ADMIN, "", nil), // requires cap_sys_nice or cap_sys_admin (depending)
 		252: syscalls.CapError("ioprio_get", linux.CAP_SYS_ADMIN, "", nil), // requires cap_sys_nice or cap_sys_admin (depending)
-		253: syscalls.PartiallySupportedPoint("inotify_init", InotifyInit, PointInotifyInit, "inotify events are only available inside the sandbox.", nil),
-		254: syscalls.PartiallySupportedPoint("inotify_add_watch", InotifyAddWatch, PointInotifyAddWatch, "inotify events are only available inside the sandbox.", nil),
-		255: syscalls.PartiallySupportedPoint("inotify_rm_watch", InotifyRmWatch, PointInotifyRmWatch, "inotify events are only available inside the sandbox.", nil),
+		253: syscalls.PartiallySupportedPoint("inotify_init", InotifyInit, PointInotifyInit, "Host changes are only reported for gofer mounts in shared file access mode.", nil),
+		254: syscalls.PartiallySupportedPoint("inotify_add_watch", InotifyAddWatch, PointInotifyAddWatch, "Host changes are only reported for gofer mounts in shared file access mode.", nil),
+		255: syscalls.PartiallySupportedPoint("inotify_rm_watch", InotifyRmWatch, PointInotifyRmWatch, "Host changes are only reported for gofer mounts in shared file access mode.", nil),
 		256: syscalls.CapError("migrate_pages", linux.CAP_SYS_NICE, "", nil),
 		257: syscalls.SupportedPoint("openat", Openat, PointOpenat),
 		258: syscalls.Supported("mkdirat", Mkdirat),
@@ -324,14 +324,14 @@ var AMD64 = &kernel.SyscallTable{
 		269: syscalls.Supported("faccessat", Faccessat),
 		270: syscalls.Supported("pselect6", Pselect6),
 		271: syscalls.Supported("ppoll", Ppoll),
-		272: syscalls.PartiallySupported("unshare", Unshare, "Time, cgroup namespaces not supported.", nil),
+		272: syscalls.Supported("unshare", Unshare),
 		273: syscalls.Supported("set_robust_list", SetRobustList),
 		274: syscalls.Supported("get_robust_list", GetRobustList),
 		275: syscalls.Supported("splice", Splice),
 		276: syscalls.Supported("tee", Tee),
 		277: syscalls.Supported("sync_file_range", SyncFileRange),
-		278: syscalls.ErrorWithEvent("vmsplice", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/138"}), // TODO(b/29354098)
-		279: syscalls.CapError("move_pages", linux.CAP_SYS_NICE, "", nil),                               // requires cap_sys_nice (mostly)
+		278: syscalls.Supported("vmsplice", Vmsplice),
+		279: syscalls.CapError("move_pages", linux.CAP_SYS_NICE, "", nil), // requires cap_sys_nice (mostly)
 		280: syscalls.Supported("utimensat", Utimensat),
 		281: syscalls.Supported("epoll_pwait", EpollPwait),
 		282: syscalls.SupportedPoint("signalfd", Signalfd, PointSignalfd),
@@ -346,7 +346,7 @@ var AMD64 = &kernel.SyscallTable{
 		291: syscalls.Supported("epoll_create1", EpollCreate1),
 		292: syscalls.SupportedPoint("dup3", Dup3, PointDup3),
 		293: syscalls.SupportedPoint("pipe2", Pipe2, PointPipe2),
-		294: syscalls.PartiallySupportedPoint("inotify_init1", InotifyInit1, PointInotifyInit1, "inotify events are only available inside the sandbox.", nil),
+		294: syscalls.PartiallySupportedPoint("inotify_init1", InotifyInit1, PointInotifyInit1, "Host changes are only reported for gofer mounts in shared file access mode.", nil),
 		295: syscalls.SupportedPoint("preadv", Preadv, PointPreadv),
 		296: syscalls.SupportedPoint("pwritev", Pwritev, PointPwritev),
 		297: syscalls.Supported("rt_tgsigqueueinfo", RtTgsigqueueinfo),
@@ -375,7 +375,7 @@ var AMD64 = &kernel.SyscallTable{
 		320: syscalls.CapError("kexec_file_load", linux.CAP_SYS_BOOT, "", nil),
 		321: syscalls.CapError("bpf", linux.CAP_SYS_ADMIN, "", nil),
 		322: syscalls.SupportedPoint("execveat", Execveat, PointExecveat),
-		323: syscalls.ErrorWithEvent("userfaultfd", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/266"}), // TODO(b/118906345)
+		323: syscalls.PartiallySupported("userfaultfd", Userfaultfd, "Only private anonymous mappings can be registered. Non-cooperative events (fork, remap, remove and unmap) are not delivered, and UFFDIO_CONTINUE, UFFDIO_MOVE and UFFDIO_POISON are not supported.", nil),
 		324: syscalls.PartiallySupported("membarrier", Membarrier, "Not supported on all platforms.", nil),
 		325: syscalls.PartiallySupported("mlock2", Mlock2, "Stub implementation. The sandbox lacks appropriate permissions.", nil),
 
@@ -384,9 +384,9 @@ var AMD64 = &kernel.SyscallTable{
 		326: syscalls.ErrorWithEvent("copy_file_range", linuxerr.ENOSYS, "", nil),
 		327: syscalls.SupportedPoint("preadv2", Preadv2, PointPreadv2),
 		328: syscalls.SupportedPoint("pwritev2", Pwritev2, PointPwritev2),
-		329: syscalls.ErrorWithEvent("pkey_mprotect", linuxerr.ENOSYS, "", nil),
-		330: syscalls.ErrorWithEvent("pkey_alloc", linuxerr.ENOSYS, "", nil),
-		331: syscalls.ErrorWithEvent("pkey_free", linuxerr.ENOSYS, "", nil),
+		329: syscalls.PartiallySupported("pkey_mprotect", PkeyMprotect, "Protection keys are not enforced, so no keys can be allocated and pkey_alloc always fails with ENOSPC.", nil),
+		330: syscalls.PartiallySupported("pkey_alloc", PkeyAlloc, "Protection keys are not enforced, so no keys can be allocated and pkey_alloc always fails with ENOSPC.", nil),
+		331: syscalls.PartiallySupported("pkey_free", PkeyFree, "Protection keys are not enforced, so no keys can be allocated and pkey_alloc always fails with ENOSPC.", nil),
 		332: syscalls.Supported("statx", Statx),
 		333: syscalls.ErrorWithEvent("io_pgetevents", linuxerr.ENOSYS, "", nil),
 		334: syscalls.PartiallySupported("rseq", RSeq, "Not supported on all platforms.", nil),
@@ -403,7 +403,7 @@ var AMD64 = &kernel.SyscallTable{
 		432: syscalls.ErrorWithEvent("fsmount", linuxerr.ENOSYS, "", nil),
 		433: syscalls.ErrorWithEvent("fspick", linuxerr.ENOSYS, "", nil),
 		434: syscalls.ErrorWithEvent("pidfd_open", linuxerr.ENOSYS, "", nil),
-		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_INTO_CGROUP, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and, SetTid are not supported.", nil),
+		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_PIDFD, CLONE_INTO_CGROUP, CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and, SetTid are not supported.", nil),
 		436: syscalls.Supported("close_range", CloseRange),
 		439: syscalls.Supported("faccessat2", Faccessat2),
 		441: syscalls.Supported("epoll_pwait2", EpollPwait2),
@@ -457,9 +457,9 @@ var ARM64 = &kernel.SyscallTable{
 		23:  syscalls.SupportedPoint("dup", Dup, PointDup),
 		24:  syscalls.SupportedPoint("dup3", Dup3, PointDup3),
 		25:  syscalls.SupportedPoint("fcntl", Fcntl, PointFcntl),
-		26:  syscalls.PartiallySupportedPoint("inotify_init1", InotifyInit1, PointInotifyInit1, "inotify events are only available inside the sandbox.", nil),
-		27:  syscalls.PartiallySupportedPoint("inotify_add_watch", InotifyAddWatch, PointInotifyAddWatch, "inotify events are only available inside the sandbox.", nil),
-		28:  syscalls.PartiallySupportedPoint("inotify_rm_watch", InotifyRmWatch, PointInotifyRmWatch, "inotify events are only available inside the sandbox.", nil),
+		26:  syscalls.PartiallySupportedPoint("inotify_init1", InotifyInit1, PointInotifyInit1, "Host changes are only reported for gofer mounts in shared file access mode.", nil),
+		27:  syscalls.PartiallySupportedPoint("inotify_add_watch", InotifyAddWatch, PointInotifyAddWatch, "Host changes are only reported for gofer mounts in shared file access mode.", nil),
+		28:  syscalls.PartiallySupportedPoint("inotify_rm_watch", InotifyRmWatch, PointInotifyRmWatch, "Host changes are only reported for gofer mounts in shared file access mode.", nil),
 		29:  syscalls.Supported("ioctl", Ioctl),
 		30:  syscalls.CapError("ioprio_set", linux.CAP_SYS_ADMIN, "", nil), // requires cap_sys_nice or cap_sys_admin (depending)
 		31:  syscalls.CapError("ioprio_get", linux.CAP_SYS_ADMIN, "", nil), // requires cap_sys_nice or cap_sys_admin (depending)
@@ -506,7 +506,7 @@ var ARM64 = &kernel.SyscallTable{
 		72:  syscalls.Supported("pselect6", Pselect6),
 		73:  syscalls.Supported("ppoll", Ppoll),
 		74:  syscalls.SupportedPoint("signalfd4", Signalfd4, PointSignalfd4),
-		75:  syscalls.ErrorWithEvent("vmsplice", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/138"}), // TODO(b/29354098)
+		75:  syscalls.Supported("vmsplice", Vmsplice),
 		76:  syscalls.Supported("splice", Splice),
 		77:  syscalls.Supported("tee", Tee),
 		78:  syscalls.Supported("readlinkat", Readlinkat),
@@ -528,7 +528,7 @@ var ARM64 = &kernel.SyscallTable{
 		94:  syscalls.Supported("exit_group", ExitGroup),
 		95:  syscalls.Supported("waitid", Waitid),
 		96:  syscalls.Supported("set_tid_address", SetTidAddress),
-		97:  syscalls.PartiallySupported("unshare", Unshare, "Time, cgroup namespaces not supported.", nil),
+		97:  syscalls.Supported("unshare", Unshare),
 		98:  syscalls.PartiallySupported("futex", Futex, "Robust futexes not supported.", nil),
 		99:  syscalls.Supported("set_robust_list", SetRobustList),
 		100: syscalls.Supported("get_robust_list", GetRobustList),
@@ -651,7 +651,7 @@ var ARM64 = &kernel.SyscallTable{
 		217: syscalls.Error("add_key", linuxerr.EACCES, "Not available to user.", nil),
 		218: syscalls.Error("request_key", linuxerr.EACCES, "Not available to user.", nil),
 		219: syscalls.PartiallySupported("keyctl", Keyctl, "Only supports session keyrings with zero keys in them.", nil),
-		220: syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_PARENT, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
+		220: syscalls.PartiallySupportedPoint("clone", Clone, PointClone, "Options CLONE_PIDFD, CLONE_PARENT, CLONE_CLEAR_SIGHAND, and CLONE_SYSVSEM not supported.", nil),
 		221: syscalls.SupportedPoint("execve", Execve, PointExecve),
 		222: syscalls.Supported("mmap", Mmap),
 		223: syscalls.PartiallySupported("fadvise64", Fadvise64, "Not all options are supported.", nil),
@@ -697,7 +697,7 @@ var ARM64 = &kernel.SyscallTable{
 		279: syscalls.Supported("memfd_create", MemfdCreate),
 		280: syscalls.CapError("bpf", linux.CAP_SYS_ADMIN, "", nil),
 		281: syscalls.SupportedPoint("execveat", Execveat, PointExecveat),
-		282: syscalls.ErrorWithEvent("userfaultfd", linuxerr.ENOSYS, "", []string{"gvisor.dev/issue/266"}), // TODO(b/118906345)
+		282: syscalls.PartiallySupported("userfaultfd", Userfaultfd, "Only private anonymous mappings can be registered. Non-cooperative events (fork, remap, remove and unmap) are not delivered, and UFFDIO_CONTINUE, UFFDIO_MOVE and UFFDIO_POISON are not supported.", nil),
 		283: syscalls.PartiallySupported("membarrier", Membarrier, "Not supported on all platforms.", nil),
 		284: syscalls.PartiallySupported("mlock2", Mlock2, "Stub implementation. The sandbox lacks appropriate permissions.", nil),
 
@@ -705,9 +705,9 @@ var ARM64 = &kernel.SyscallTable{
 		285: syscalls.ErrorWithEvent("copy_file_range", linuxerr.ENOSYS, "", nil),
 		286: syscalls.SupportedPoint("preadv2", Preadv2, PointPreadv2),
 		287: syscalls.SupportedPoint("pwritev2", Pwritev2, PointPwritev2),
-		288: syscalls.ErrorWithEvent("pkey_mprotect", linuxerr.ENOSYS, "", nil),
-		289: syscalls.ErrorWithEvent("pkey_alloc", linuxerr.ENOSYS, "", nil),
-		290: syscalls.ErrorWithEvent("pkey_free", linuxerr.ENOSYS, "", nil),
+		288: syscalls.PartiallySupported("pkey_mprotect", PkeyMprotect, "Protection keys are not enforced, so no keys can be allocated and pkey_alloc always fails with ENOSPC.", nil),
+		289: syscalls.PartiallySupported("pkey_alloc", PkeyAlloc, "Protection keys are not enforced, so no keys can be allocated and pkey_alloc always fails with ENOSPC.", nil),
+		290: syscalls.PartiallySupported("pkey_free", PkeyFree, "Protection keys are not enforced, so no keys can be allocated and pkey_alloc always fails with ENOSPC.", nil),
 		291: syscalls.Supported("statx", Statx),
 		292: syscalls.ErrorWithEvent("io_pgetevents", linuxerr.ENOSYS, "", nil),
 		293: syscalls.PartiallySupported("rseq", RSeq, "Not supported on all platforms.", nil),
@@ -724,7 +724,7 @@ var ARM64 = &kernel.SyscallTable{
 		432: syscalls.ErrorWithEvent("fsmount", linuxerr.ENOSYS, "", nil),
 		433: syscalls.ErrorWithEvent("fspick", linuxerr.ENOSYS, "", nil),
 		434: syscalls.ErrorWithEvent("pidfd_open", linuxerr.ENOSYS, "", nil),
-		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_PIDFD, CLONE_NEWCGROUP, CLONE_INTO_CGROUP, CLONE_NEWTIME, CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and clone_args.set_tid are not supported.", nil),
+		435: syscalls.PartiallySupported("clone3", Clone3, "Options CLONE_PIDFD, CLONE_INTO_CGROUP, CLONE_CLEAR_SIGHAND, CLONE_PARENT, CLONE_SYSVSEM and clone_args.set_tid are not supported.", nil),
 		436: syscalls.Supported("close_range", CloseRange),
 		439: syscalls.Supported("faccessat2", Faccessat2),
 		441: syscalls.Supported("epoll_pwait2", EpollPwait2),
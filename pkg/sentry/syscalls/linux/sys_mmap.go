@@ -180,6 +180,49 @@ func Mprotect(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintpt
 	return 0, nil, err
 }
 
+// PkeyMprotect implements linux syscall pkey_mprotect(2).
+func PkeyMprotect(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	length := args[1].Uint64()
+	prot := args[2].Int()
+	pkey := args[3].Int()
+	err := t.MemoryManager().PkeyMProtect(args[0].Pointer(), length, hostarch.AccessType{
+		Read:    linux.PROT_READ&prot != 0,
+		Write:   linux.PROT_WRITE&prot != 0,
+		Execute: linux.PROT_EXEC&prot != 0,
+	}, linux.PROT_GROWSDOWN&prot != 0, pkey)
+	return 0, nil, err
+}
+
+// PkeyAlloc implements linux syscall pkey_alloc(2).
+func PkeyAlloc(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	flags := args[0].Uint()
+	accessRights := args[1].Uint()
+	if flags != 0 || accessRights&^linux.PKEY_ACCESS_MASK != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	// Protection keys are recorded in vmas but not propagated to platform
+	// mappings, so a key allocated here would not restrict access to memory
+	// assigned to it. Report that no keys are available, as Linux does on
+	// hardware without pkeys, rather than silently failing to enforce them.
+	pkey, err := t.MemoryManager().PkeyAlloc(0 /* numPkeys */)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Set the calling thread's initial access rights for the new key. Other
+	// threads are unaffected, as in Linux.
+	fp := t.Arch().FloatingPointData()
+	shift := 2 * uint32(pkey)
+	fp.SetPKRU(fp.PKRU()&^(linux.PKEY_ACCESS_MASK<<shift) | accessRights<<shift)
+	return uintptr(pkey), nil, nil
+}
+
+// PkeyFree implements linux syscall pkey_free(2).
+func PkeyFree(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	return 0, nil, t.MemoryManager().PkeyFree(args[0].Int())
+}
+
 // Madvise implements linux syscall madvise(2).
 func Madvise(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
 	addr := args[0].Pointer()
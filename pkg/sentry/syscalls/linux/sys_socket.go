@@ -49,7 +49,8 @@ const maxOptLen = 1024 * 8
 // buffers upto INT_MAX.
 const maxControlLen = 10 * 1024 * 1024
 
-// maxListenBacklog is the maximum limit of listen backlog supported.
+// maxListenBacklog is the limit of listen backlog used if the network stack
+// doesn't implement net.core.somaxconn.
 const maxListenBacklog = 1024
 
 // nameLenOffset is the offset from the start of the MessageHeader64 struct to
@@ -386,11 +387,15 @@ func Listen(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 		return 0, nil, linuxerr.ENOTSOCK
 	}
 
-	if backlog > maxListenBacklog {
-		// Linux treats incoming backlog as uint with a limit defined by
-		// sysctl_somaxconn.
-		// https://github.com/torvalds/linux/blob/7acac4b3196/net/socket.c#L1666
-		backlog = maxListenBacklog
+	// Linux treats incoming backlog as uint with a limit defined by
+	// sysctl_somaxconn.
+	// https://github.com/torvalds/linux/blob/7acac4b3196/net/socket.c#L1666
+	somaxconn := uint32(maxListenBacklog)
+	if v, err := t.NetworkNamespace().Sysctl("core/somaxconn"); err == nil {
+		somaxconn = uint32(v.Ints[0])
+	}
+	if backlog > somaxconn {
+		backlog = somaxconn
 	}
 
 	// Accept one more than the configured listen backlog to keep in parity with
@@ -266,6 +266,85 @@ func Tee(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *k
 	return uintptr(n), nil, HandleIOError(t, n != 0, err, linuxerr.ERESTARTSYS, "tee", inFile)
 }
 
+// Vmsplice implements Linux syscall vmsplice(2).
+func Vmsplice(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	fd := args[0].Int()
+	iovAddr := args[1].Pointer()
+	iovcnt := args[2].Uint64()
+	flags := args[3].Int()
+
+	// Check for invalid flags.
+	if flags&^(linux.SPLICE_F_MOVE|linux.SPLICE_F_NONBLOCK|linux.SPLICE_F_MORE|linux.SPLICE_F_GIFT) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	file := t.GetFile(fd)
+	if file == nil {
+		return 0, nil, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+
+	// The file description must represent a pipe. As in Linux, the direction
+	// of the transfer is determined by the file description's mode.
+	pipeFD, ok := file.Impl().(*pipe.VFSPipeFD)
+	if !ok {
+		return 0, nil, linuxerr.EBADF
+	}
+	toPipe := file.IsWritable()
+	if !toPipe && !file.IsReadable() {
+		return 0, nil, linuxerr.EBADF
+	}
+
+	if iovcnt > linux.UIO_MAXIOV {
+		return 0, nil, linuxerr.EINVAL
+	}
+	iov, err := t.IovecsIOSequence(iovAddr, int(iovcnt), usermem.IOOpts{
+		AddressSpaceActive: true,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if iov.NumBytes() == 0 {
+		return 0, nil, nil
+	}
+	iov = iov.TakeFirst64(int64(kernel.MAX_RW_COUNT))
+
+	nonBlock := file.StatusFlags()&linux.O_NONBLOCK != 0 || flags&linux.SPLICE_F_NONBLOCK != 0
+	mask := eventMaskRead
+	if toPipe {
+		mask = eventMaskWrite
+	}
+	var (
+		n  int64
+		w  waiter.Entry
+		ch chan struct{}
+	)
+	for {
+		if toPipe {
+			n, err = pipeFD.VMSplice(t, t.MemoryManager(), iov)
+		} else {
+			n, err = file.Read(t, iov, vfs.ReadOptions{})
+		}
+		if n != 0 || !linuxerr.Equals(linuxerr.ErrWouldBlock, err) || nonBlock {
+			break
+		}
+		if ch == nil {
+			// Register for notifications and retry before blocking.
+			w, ch = waiter.NewChannelEntry(mask)
+			if err = file.EventRegister(&w); err != nil {
+				break
+			}
+			defer file.EventUnregister(&w)
+			continue
+		}
+		if err = t.Block(ch); err != nil {
+			break
+		}
+	}
+
+	return uintptr(n), nil, HandleIOError(t, n != 0, err, linuxerr.ERESTARTSYS, "vmsplice", file)
+}
+
 // Sendfile implements linux system call sendfile(2).
 func Sendfile(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
 	outFD := args[0].Int()
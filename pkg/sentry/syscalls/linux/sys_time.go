@@ -124,15 +124,18 @@ func getClock(t *kernel.Task, clockID int32) (ktime.Clock, error) {
 	case linux.CLOCK_REALTIME, linux.CLOCK_REALTIME_COARSE:
 		return t.Kernel().RealtimeClock(), nil
 	case linux.CLOCK_MONOTONIC, linux.CLOCK_MONOTONIC_COARSE,
-		linux.CLOCK_MONOTONIC_RAW, linux.CLOCK_BOOTTIME:
+		linux.CLOCK_MONOTONIC_RAW:
 		// CLOCK_MONOTONIC approximates CLOCK_MONOTONIC_RAW.
-		// CLOCK_BOOTTIME is internally mapped to CLOCK_MONOTONIC, as:
+		return t.TimeNamespace().MonotonicClock(), nil
+	case linux.CLOCK_BOOTTIME:
+		// CLOCK_BOOTTIME is internally based on CLOCK_MONOTONIC, as:
 		//	- CLOCK_BOOTTIME should behave as CLOCK_MONOTONIC while also
 		//		including suspend time.
 		//	- gVisor has no concept of suspend/resume.
 		//	- CLOCK_MONOTONIC already includes save/restore time, which is
 		//		the closest to suspend time.
-		return t.Kernel().MonotonicClock(), nil
+		// The two clocks still differ by their time namespace offsets.
+		return t.TimeNamespace().BoottimeClock(), nil
 	case linux.CLOCK_PROCESS_CPUTIME_ID:
 		return t.ThreadGroup().CPUClock(), nil
 	case linux.CLOCK_THREAD_CPUTIME_ID:
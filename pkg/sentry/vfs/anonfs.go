@@ -334,3 +334,6 @@ func (d *anonDentry) Watches() *Watches {
 
 // OnZeroWatches implements Dentry.OnZeroWatches.
 func (d *anonDentry) OnZeroWatches(context.Context) {}
+
+// OnFirstWatch implements Dentry.OnFirstWatch.
+func (d *anonDentry) OnFirstWatch(context.Context) {}
@@ -138,6 +138,18 @@ type DentryImpl interface {
 	// may acquire inotify locks, so to prevent deadlock, no inotify locks should
 	// be held by the caller.
 	OnZeroWatches(ctx context.Context)
+
+	// OnFirstWatch is called whenever the number of watches on a dentry rises
+	// from zero to one. This is needed by some FilesystemImpls (e.g. gofer) to
+	// subscribe to external change notifications for the watched file.
+	//
+	// The caller must hold a reference on the dentry. OnFirstWatch may acquire
+	// inotify locks, so to prevent deadlock, no inotify locks should be held by
+	// the caller. Consequently, the watch may be removed, and OnZeroWatches
+	// called, before or concurrently with OnFirstWatch; implementations must
+	// check that the dentry still has watches after subscribing, synchronized
+	// with OnZeroWatches.
+	OnFirstWatch(ctx context.Context)
 }
 
 // IncRef increments d's reference count.
@@ -199,6 +211,12 @@ func (d *Dentry) OnZeroWatches(ctx context.Context) {
 	d.impl.OnZeroWatches(ctx)
 }
 
+// OnFirstWatch performs setup tasks whenever the number of watches on a dentry
+// rises from zero to one.
+func (d *Dentry) OnFirstWatch(ctx context.Context) {
+	d.impl.OnFirstWatch(ctx)
+}
+
 // The following functions are exported so that filesystem implementations can
 // use them. The vfs package, and users of VFS, should not call these
 // functions.
@@ -533,6 +533,18 @@ type TarSerializer interface {
 	TarUpperLayer(ctx context.Context, outFD *os.File) error
 }
 
+// ShowPathExtension is an optional extension of FilesystemImpl for
+// filesystems whose mount roots are displayed in /proc/[pid]/mountinfo
+// relative to some context-dependent root, rather than the filesystem root.
+type ShowPathExtension interface {
+	// ShowPath returns the path of d to display in field (4) of
+	// /proc/[pid]/mountinfo, as read by the task in ctx. If ok is false, the
+	// path relative to the filesystem root is displayed instead.
+	//
+	// Preconditions: d is a dentry in this filesystem.
+	ShowPath(ctx context.Context, d *Dentry) (path string, ok bool)
+}
+
 // PrependPathAtVFSRootError is returned by implementations of
 // FilesystemImpl.PrependPath() when they encounter the contextual VFS root.
 //
@@ -296,10 +296,11 @@ func (i *Inotify) queueEvent(ev *Event) {
 	i.queue.Notify(waiter.ReadableEvents)
 }
 
-// newWatchLocked creates and adds a new watch to target.
+// newWatchLocked creates and adds a new watch to target. It also reports
+// whether the new watch is the first one in ws.
 //
 // Precondition: i.mu must be locked. ws must be the watch set for target d.
-func (i *Inotify) newWatchLocked(d *Dentry, ws *Watches, mask uint32) *Watch {
+func (i *Inotify) newWatchLocked(d *Dentry, ws *Watches, mask uint32) (*Watch, bool) {
 	w := &Watch{
 		owner:  i,
 		wd:     i.nextWatchIDLocked(),
@@ -310,8 +311,8 @@ func (i *Inotify) newWatchLocked(d *Dentry, ws *Watches, mask uint32) *Watch {
 	// Hold the watch in this inotify instance as well as the watch set on the
 	// target.
 	i.watches[w.wd] = w
-	ws.Add(w)
-	return w
+	first := ws.Add(w)
+	return w, first
 }
 
 // newWatchIDLocked allocates and returns a new watch descriptor.
@@ -326,13 +327,12 @@ func (i *Inotify) nextWatchIDLocked() int32 {
 // returns the watch descriptor returned by inotify_add_watch(2).
 //
 // The caller must hold a reference on target.
-func (i *Inotify) AddWatch(target *Dentry, mask uint32) int32 {
+func (i *Inotify) AddWatch(ctx context.Context, target *Dentry, mask uint32) int32 {
 	// Note: Locking this inotify instance protects the result returned by
 	// Lookup() below. With the lock held, we know for sure the lookup result
 	// won't become stale because it's impossible for *this* instance to
 	// add/remove watches on target.
 	i.mu.Lock()
-	defer i.mu.Unlock()
 
 	ws := target.Watches()
 	// Does the target already have a watch from this inotify instance?
@@ -344,11 +344,20 @@ func (i *Inotify) AddWatch(target *Dentry, mask uint32) int32 {
 			newmask |= existing.mask.Load()
 		}
 		existing.mask.Store(newmask)
+		i.mu.Unlock()
 		return existing.wd
 	}
 
 	// No existing watch, create a new watch.
-	w := i.newWatchLocked(target, ws, mask)
+	w, first := i.newWatchLocked(target, ws, mask)
+	i.mu.Unlock()
+
+	// OnFirstWatch may acquire inotify locks, so i.mu must not be held. The
+	// watch may be removed before OnFirstWatch is called; see
+	// DentryImpl.OnFirstWatch.
+	if first {
+		target.OnFirstWatch(ctx)
+	}
 	return w.wd
 }
 
@@ -418,10 +427,11 @@ func (w *Watches) Lookup(id uint64) *Watch {
 	return w.ws[id]
 }
 
-// Add adds watch into this set of watches.
+// Add adds watch into this set of watches. It reports whether watch is the
+// first watch in the set.
 //
 // Precondition: the inotify instance with the given id must be locked.
-func (w *Watches) Add(watch *Watch) {
+func (w *Watches) Add(watch *Watch) bool {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
@@ -435,6 +445,7 @@ func (w *Watches) Add(watch *Watch) {
 		w.ws = make(map[uint64]*Watch)
 	}
 	w.ws[owner] = watch
+	return len(w.ws) == 1
 }
 
 // Remove removes a watch with the given id from this set of watches and
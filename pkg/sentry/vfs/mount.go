@@ -34,9 +34,10 @@ import (
 // configured by the user at /proc/sys/fs/mount-max, but the default is
 // 100,000. We set the gVisor limit to 10,000.
 const (
-	MountMax     = 10000
-	nsfsName     = "nsfs"
-	cgroupFsName = "cgroup"
+	MountMax      = 10000
+	nsfsName      = "nsfs"
+	cgroupFsName  = "cgroup"
+	cgroup2FsName = "cgroup2"
 )
 
 // A Mount is a replacement of a Dentry (Mount.key.point) from one Filesystem
@@ -576,7 +577,7 @@ func (vfs *VirtualFilesystem) BindAt(ctx context.Context, creds *auth.Credential
 	defer cleanup.Clean()
 	// Namespace mounts can be binded to other mount points.
 	fsName := sourceVd.mount.Filesystem().FilesystemType().Name()
-	if !vfs.validInMountNS(ctx, sourceVd.mount) && fsName != nsfsName && fsName != cgroupFsName {
+	if !vfs.validInMountNS(ctx, sourceVd.mount) && fsName != nsfsName && fsName != cgroupFsName && fsName != cgroup2FsName {
 		return linuxerr.EINVAL
 	}
 	if !vfs.validInMountNS(ctx, mp.mount) {
@@ -1463,6 +1464,11 @@ func (vfs *VirtualFilesystem) GenerateProcMountInfo(ctx context.Context, taskRoo
 			// The path is not reachable from root.
 			continue
 		}
+		if spe, ok := mnt.fs.Impl().(ShowPathExtension); ok {
+			if p, ok := spe.ShowPath(ctx, mnt.root); ok {
+				pathFromFS = p
+			}
+		}
 		// Stat the mount root to get the major/minor device numbers.
 		pop := &PathOperation{
 			Root:  mntRootVD,
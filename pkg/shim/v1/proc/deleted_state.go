@@ -57,3 +57,7 @@ func (s *deletedState) State(context.Context) (string, error) {
 func (s *deletedState) Stats(context.Context, string) (*runc.Stats, error) {
 	return nil, fmt.Errorf("cannot stat a stopped container/process")
 }
+
+func (*deletedState) Checkpoint(context.Context, *CheckpointConfig) error {
+	return fmt.Errorf("cannot checkpoint a deleted container")
+}
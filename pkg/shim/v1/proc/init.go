@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -76,6 +77,10 @@ type Init struct {
 	Sandbox  bool
 	UserLog  string
 	Monitor  ProcessMonitor
+
+	// checkpointPath is the path to the checkpoint image the container is
+	// restored from when started, if any.
+	checkpointPath string
 }
 
 // NewRunsc returns a new runsc instance for a process.
@@ -170,6 +175,7 @@ func (p *Init) Create(ctx context.Context, r *CreateConfig) (err error) {
 		return fmt.Errorf("failed to retrieve OCI runtime container pid: %w", err)
 	}
 	p.pid = pid
+	p.checkpointPath = r.Checkpoint
 	return nil
 }
 
@@ -226,7 +232,18 @@ func (p *Init) Start(ctx context.Context) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.initState.Start(ctx, nil /* restoreConf */)
+	var restoreConf *extension.RestoreConfig
+	if p.checkpointPath != "" {
+		// The container was created from a checkpoint image, so it's restored
+		// from the image instead of being started.
+		restoreConf = &extension.RestoreConfig{ImagePath: p.checkpointPath}
+	}
+	return p.initState.Start(ctx, restoreConf)
+}
+
+// Restored returns true if the container was created from a checkpoint image.
+func (p *Init) Restored() bool {
+	return p.checkpointPath != ""
 }
 
 func (p *Init) start(ctx context.Context, restoreConf *extension.RestoreConfig) error {
@@ -272,6 +289,30 @@ func (p *Init) Restore(ctx context.Context, conf *extension.RestoreConfig) error
 	return p.initState.Start(ctx, conf)
 }
 
+// Checkpoint checkpoints the container into a checkpoint image.
+func (p *Init) Checkpoint(ctx context.Context, conf *CheckpointConfig) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.initState.Checkpoint(ctx, conf)
+}
+
+func (p *Init) checkpoint(ctx context.Context, conf *CheckpointConfig) error {
+	if err := os.MkdirAll(conf.Path, 0700); err != nil {
+		return fmt.Errorf("creating checkpoint directory: %w", err)
+	}
+
+	// The image holds the whole sandbox state, including the rootfs overlay
+	// upper layer, so restoring from it only needs the container's bundle.
+	if err := p.runtime.Checkpoint(ctx, p.id, &runsccmd.CheckpointOpts{
+		ImagePath:    conf.Path,
+		LeaveRunning: !conf.Exit,
+	}); err != nil {
+		return p.runtimeError(err, "OCI runtime checkpoint failed")
+	}
+	return nil
+}
+
 // SetExited set the exit status of the init process.
 func (p *Init) SetExited(status int) {
 	p.mu.Lock()
@@ -57,6 +57,7 @@ type initState interface {
 	Stats(context.Context, string) (*runc.Stats, error)
 	Kill(context.Context, uint32, bool) error
 	SetExited(int)
+	Checkpoint(context.Context, *CheckpointConfig) error
 }
 
 type createdState struct {
@@ -132,6 +133,10 @@ func (s *createdState) Stats(ctx context.Context, id string) (*runc.Stats, error
 	return s.p.stats(ctx, id)
 }
 
+func (s *createdState) Checkpoint(context.Context, *CheckpointConfig) error {
+	return fmt.Errorf("cannot checkpoint a created container")
+}
+
 type runningState struct {
 	p *Init
 }
@@ -182,6 +187,10 @@ func (s *runningState) Stats(ctx context.Context, id string) (*runc.Stats, error
 	return s.p.stats(ctx, id)
 }
 
+func (s *runningState) Checkpoint(ctx context.Context, conf *CheckpointConfig) error {
+	return s.p.checkpoint(ctx, conf)
+}
+
 type stoppedState struct {
 	process *Init
 }
@@ -231,6 +240,10 @@ func (s *stoppedState) Stats(context.Context, string) (*runc.Stats, error) {
 	return nil, fmt.Errorf("cannot stat a stopped container")
 }
 
+func (s *stoppedState) Checkpoint(context.Context, *CheckpointConfig) error {
+	return fmt.Errorf("cannot checkpoint a stopped container")
+}
+
 func handleStoppedKill(signal uint32) error {
 	switch unix.Signal(signal) {
 	case unix.SIGTERM, unix.SIGKILL:
@@ -40,6 +40,19 @@ type CreateConfig struct {
 	Stdin    string
 	Stdout   string
 	Stderr   string
+
+	// Checkpoint is the path to a checkpoint image to restore the container
+	// from when it's started. If empty, the container is started normally.
+	Checkpoint string
+}
+
+// CheckpointConfig holds task checkpoint configuration.
+type CheckpointConfig struct {
+	// Path is the directory to write the checkpoint image to.
+	Path string
+
+	// Exit indicates that the container should exit after the checkpoint.
+	Exit bool
 }
 
 // ExecConfig holds exec creation configuration.
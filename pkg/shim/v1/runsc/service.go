@@ -129,6 +129,10 @@ type runscService struct {
 
 	// oomPoller monitors the sandbox's cgroup for OOM notifications.
 	oomPoller oomPoller
+
+	// oomPid is the pid of the sandbox process whose cgroup is monitored by
+	// oomPoller, or 0 if none is. Protected by mu.
+	oomPid int
 }
 
 var _ extension.TaskServiceExt = (*runscService)(nil)
@@ -359,6 +363,8 @@ func (s *runscService) Create(ctx context.Context, r *taskAPI.CreateTaskRequest)
 		Stdin:    r.Stdin,
 		Stdout:   r.Stdout,
 		Stderr:   r.Stderr,
+		// The container is restored from the checkpoint on start, if any.
+		Checkpoint: r.Checkpoint,
 	}
 	process, err := newInit(r.Bundle, filepath.Join(r.Bundle, "work"), ns, s.platform, config, &s.opts, st.Rootfs)
 	if err != nil {
@@ -370,27 +376,8 @@ func (s *runscService) Create(ctx context.Context, r *taskAPI.CreateTaskRequest)
 
 	// Set up OOM notification on the sandbox's cgroup. This is done on
 	// sandbox create since the sandbox process will be created here.
-	pid := process.Pid()
-	if pid > 0 {
-		var (
-			cg  any
-			err error
-		)
-		if cgroups.Mode() == cgroups.Unified {
-			var cgPath string
-			cgPath, err = cgroupsv2.PidGroupPath(pid)
-			if err == nil {
-				cg, err = cgroupsv2.LoadManager("/sys/fs/cgroup", cgPath)
-			}
-		} else {
-			cg, err = cgroups.Load(cgroups.V1, cgroups.PidPath(pid))
-		}
-		if err != nil {
-			return nil, fmt.Errorf("loading cgroup for %d: %w", pid, err)
-		}
-		if err := s.oomPoller.add(s.id, cg); err != nil {
-			return nil, fmt.Errorf("add cg to OOM monitor: %w", err)
-		}
+	if err := s.monitorOOMLocked(process.Pid()); err != nil {
+		return nil, err
 	}
 
 	// Success
@@ -410,8 +397,11 @@ func (s *runscService) Start(ctx context.Context, r *taskAPI.StartRequest) (*tas
 	if err := p.Start(ctx); err != nil {
 		return nil, err
 	}
-	// TODO: Set the cgroup and oom notifications on restore.
-	// https://github.com/google/gvisor-containerd-shim/issues/58
+	if ip, ok := p.(*proc.Init); ok && ip.Restored() {
+		if err := s.monitorOOM(ip.Pid()); err != nil {
+			return nil, err
+		}
+	}
 	return &taskAPI.StartResponse{
 		Pid: uint32(p.Pid()),
 	}, nil
@@ -607,9 +597,68 @@ func (s *runscService) CloseIO(ctx context.Context, r *taskAPI.CloseIORequest) (
 	return empty, nil
 }
 
-// Checkpoint checkpoints the container.
+// Checkpoint checkpoints the container. The checkpoint image written to
+// r.Path contains the runsc state files. Containerd packs this directory into
+// the checkpoint image and passes it back unpacked in
+// CreateTaskRequest.Checkpoint on restore.
 func (s *runscService) Checkpoint(ctx context.Context, r *taskAPI.CheckpointTaskRequest) (*types.Empty, error) {
-	return empty, errdefs.ErrNotImplemented
+	s.mu.Lock()
+	p := s.task
+	s.mu.Unlock()
+	if p == nil {
+		return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "container must be created")
+	}
+	if err := s.checkpoint(ctx, p, r); err != nil {
+		return nil, err
+	}
+	return empty, nil
+}
+
+// checkpointer checkpoints a container.
+type checkpointer interface {
+	Checkpoint(ctx context.Context, conf *proc.CheckpointConfig) error
+}
+
+// checkpoint checkpoints the container with p, as requested by r, and
+// publishes a TaskCheckpointed event if it succeeds.
+func (s *runscService) checkpoint(ctx context.Context, p checkpointer, r *taskAPI.CheckpointTaskRequest) error {
+	conf, err := checkpointConfig(r)
+	if err != nil {
+		return err
+	}
+	if err := p.Checkpoint(ctx, conf); err != nil {
+		return errdefs.ToGRPC(err)
+	}
+	s.events <- &events.TaskCheckpointed{
+		ContainerID: s.id,
+	}
+	return nil
+}
+
+// checkpointConfig returns the checkpoint configuration requested by r.
+func checkpointConfig(r *taskAPI.CheckpointTaskRequest) (*proc.CheckpointConfig, error) {
+	conf := &proc.CheckpointConfig{
+		Path: r.Path,
+	}
+	if r.Options != nil {
+		v, err := typeurl.UnmarshalAny(r.Options)
+		if err != nil {
+			return nil, err
+		}
+		switch o := v.(type) {
+		case *runctypes.CheckpointOptions:
+			if o.ImagePath != "" {
+				conf.Path = o.ImagePath
+			}
+			conf.Exit = o.Exit
+		default:
+			log.L.Warningf("Ignoring unsupported checkpoint options type %T", v)
+		}
+	}
+	if conf.Path == "" {
+		return nil, errdefs.ToGRPCf(errdefs.ErrInvalidArgument, "checkpoint path must be provided")
+	}
+	return conf, nil
 }
 
 // Restore restores the container.
@@ -621,13 +670,50 @@ func (s *runscService) Restore(ctx context.Context, r *extension.RestoreRequest)
 	if err := p.Restore(ctx, &r.Conf); err != nil {
 		return nil, err
 	}
-	// TODO: Set the cgroup and oom notifications on restore.
-	// https://github.com/google/gvisor-containerd-shim/issues/58
+	if err := s.monitorOOM(p.Pid()); err != nil {
+		return nil, err
+	}
 	return &taskAPI.StartResponse{
 		Pid: uint32(p.Pid()),
 	}, nil
 }
 
+// monitorOOM sets up OOM notifications on the cgroup of the sandbox process
+// pid, unless they are already set up.
+func (s *runscService) monitorOOM(pid int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.monitorOOMLocked(pid)
+}
+
+// monitorOOMLocked is like monitorOOM, but s.mu must be held.
+func (s *runscService) monitorOOMLocked(pid int) error {
+	if pid <= 0 || pid == s.oomPid {
+		return nil
+	}
+	var (
+		cg  any
+		err error
+	)
+	if cgroups.Mode() == cgroups.Unified {
+		var cgPath string
+		cgPath, err = cgroupsv2.PidGroupPath(pid)
+		if err == nil {
+			cg, err = cgroupsv2.LoadManager("/sys/fs/cgroup", cgPath)
+		}
+	} else {
+		cg, err = cgroups.Load(cgroups.V1, cgroups.PidPath(pid))
+	}
+	if err != nil {
+		return fmt.Errorf("loading cgroup for %d: %w", pid, err)
+	}
+	if err := s.oomPoller.add(s.id, cg); err != nil {
+		return fmt.Errorf("add cg to OOM monitor: %w", err)
+	}
+	s.oomPid = pid
+	return nil
+}
+
 // Connect returns shim information such as the shim's pid.
 func (s *runscService) Connect(ctx context.Context, r *taskAPI.ConnectRequest) (*taskAPI.ConnectResponse, error) {
 	var pid int
@@ -891,6 +977,8 @@ func getTopic(e any) string {
 		return runtime.TaskExecAddedEventTopic
 	case *events.TaskExecStarted:
 		return runtime.TaskExecStartedEventTopic
+	case *events.TaskCheckpointed:
+		return runtime.TaskCheckpointedEventTopic
 	default:
 		log.L.Infof("no topic for type %#v", e)
 	}
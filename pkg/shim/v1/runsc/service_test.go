@@ -15,9 +15,19 @@
 package runsc
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"testing"
 
+	"github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/containerd/runtime/linux/runctypes"
+	taskAPI "github.com/containerd/containerd/runtime/v2/task"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/typeurl"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/shim/v1/proc"
 	"gvisor.dev/gvisor/pkg/shim/v1/utils"
 )
 
@@ -119,3 +129,175 @@ func TestCgroupNoUpdate(t *testing.T) {
 		})
 	}
 }
+
+// fakeCheckpointer records checkpoint requests.
+type fakeCheckpointer struct {
+	conf *proc.CheckpointConfig
+	err  error
+}
+
+// Checkpoint implements checkpointer.Checkpoint.
+func (f *fakeCheckpointer) Checkpoint(_ context.Context, conf *proc.CheckpointConfig) error {
+	f.conf = conf
+	return f.err
+}
+
+func TestCheckpointConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		path    string
+		opts    any
+		want    proc.CheckpointConfig
+		wantErr bool
+	}{
+		{
+			name: "path",
+			path: "/checkpoint",
+			want: proc.CheckpointConfig{Path: "/checkpoint"},
+		},
+		{
+			name: "exit",
+			path: "/checkpoint",
+			opts: &runctypes.CheckpointOptions{Exit: true},
+			want: proc.CheckpointConfig{Path: "/checkpoint", Exit: true},
+		},
+		{
+			name: "image-path",
+			path: "/checkpoint",
+			opts: &runctypes.CheckpointOptions{ImagePath: "/image"},
+			want: proc.CheckpointConfig{Path: "/image"},
+		},
+		{
+			name: "image-path-only",
+			opts: &runctypes.CheckpointOptions{ImagePath: "/image", Exit: true},
+			want: proc.CheckpointConfig{Path: "/image", Exit: true},
+		},
+		{
+			name: "unsupported-options",
+			path: "/checkpoint",
+			opts: &runctypes.CreateOptions{},
+			want: proc.CheckpointConfig{Path: "/checkpoint"},
+		},
+		{
+			name:    "no-path",
+			opts:    &runctypes.CheckpointOptions{Exit: true},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &taskAPI.CheckpointTaskRequest{Path: tc.path}
+			if tc.opts != nil {
+				opts, err := typeurl.MarshalAny(tc.opts)
+				if err != nil {
+					t.Fatalf("MarshalAny(%+v): %v", tc.opts, err)
+				}
+				r.Options = opts
+			}
+			got, err := checkpointConfig(r)
+			if tc.wantErr {
+				if !errdefs.IsInvalidArgument(errdefs.FromGRPC(err)) {
+					t.Fatalf("checkpointConfig(%+v) got err: %v, want: %v", r, err, errdefs.ErrInvalidArgument)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkpointConfig(%+v): %v", r, err)
+			}
+			if *got != tc.want {
+				t.Errorf("checkpointConfig(%+v), got: %+v, want: %+v", r, *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointEvent(t *testing.T) {
+	s := &runscService{
+		id:     "container",
+		events: make(chan any, 1),
+	}
+	r := &taskAPI.CheckpointTaskRequest{Path: "/checkpoint"}
+
+	// No event is published if the checkpoint fails.
+	p := &fakeCheckpointer{err: fmt.Errorf("checkpoint failed")}
+	if err := s.checkpoint(context.Background(), p, r); err == nil {
+		t.Fatalf("checkpoint succeeded, want error")
+	}
+	if len(s.events) != 0 {
+		t.Fatalf("checkpoint failure published event: %+v", <-s.events)
+	}
+
+	p.err = nil
+	if err := s.checkpoint(context.Background(), p, r); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	if p.conf == nil || p.conf.Path != r.Path {
+		t.Errorf("checkpoint got config: %+v, want path: %q", p.conf, r.Path)
+	}
+	select {
+	case e := <-s.events:
+		ev, ok := e.(*events.TaskCheckpointed)
+		if !ok {
+			t.Fatalf("checkpoint published event %T, want *events.TaskCheckpointed", e)
+		}
+		if ev.ContainerID != s.id {
+			t.Errorf("TaskCheckpointed container ID, got: %q, want: %q", ev.ContainerID, s.id)
+		}
+		if got := getTopic(e); got != runtime.TaskCheckpointedEventTopic {
+			t.Errorf("TaskCheckpointed topic, got: %q, want: %q", got, runtime.TaskCheckpointedEventTopic)
+		}
+	default:
+		t.Fatalf("checkpoint published no event")
+	}
+}
+
+// fakeOOMPoller records the cgroups added to it.
+type fakeOOMPoller struct {
+	added []any
+}
+
+// Close implements io.Closer.Close.
+func (*fakeOOMPoller) Close() error {
+	return nil
+}
+
+// add implements oomPoller.add.
+func (p *fakeOOMPoller) add(_ string, cg any) error {
+	p.added = append(p.added, cg)
+	return nil
+}
+
+// run implements oomPoller.run.
+func (*fakeOOMPoller) run(context.Context) {}
+
+func TestMonitorOOM(t *testing.T) {
+	poller := &fakeOOMPoller{}
+	s := &runscService{
+		id:        "container",
+		oomPoller: poller,
+	}
+
+	// A restore replaces the sandbox process, so monitoring is re-established
+	// for each new sandbox pid, but only once per pid.
+	for i, tc := range []struct {
+		pid       int
+		wantAdded int
+	}{
+		{pid: 0, wantAdded: 0},
+		{pid: os.Getpid(), wantAdded: 1},
+		{pid: os.Getpid(), wantAdded: 1},
+		{pid: os.Getppid(), wantAdded: 2},
+	} {
+		if err := s.monitorOOM(tc.pid); err != nil {
+			if i == 1 {
+				t.Skipf("cgroups unavailable: %v", err)
+			}
+			t.Fatalf("monitorOOM(%d): %v", tc.pid, err)
+		}
+		if got := len(poller.added); got != tc.wantAdded {
+			t.Errorf("monitorOOM(%d) added %d cgroups in total, want: %d", tc.pid, got, tc.wantAdded)
+		}
+	}
+	if s.oomPid != os.Getppid() {
+		t.Errorf("oomPid, got: %d, want: %d", s.oomPid, os.Getppid())
+	}
+}
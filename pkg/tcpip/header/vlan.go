@@ -0,0 +1,85 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package header
+
+import (
+	"encoding/binary"
+
+	"gvisor.dev/gvisor/pkg/tcpip"
+)
+
+const (
+	vlanTCI  = 0
+	vlanType = 2
+)
+
+const (
+	// VLANTagSize is the size of an IEEE 802.1Q tag, not including the tag
+	// protocol identifier which takes the place of the EtherType in the
+	// Ethernet header.
+	VLANTagSize = 4
+
+	// VLANProtocol8021Q is the tag protocol identifier of 802.1Q tagged
+	// frames.
+	VLANProtocol8021Q tcpip.NetworkProtocolNumber = 0x8100
+
+	// VLANProtocol8021AD is the tag protocol identifier of 802.1ad (QinQ)
+	// service tagged frames.
+	VLANProtocol8021AD tcpip.NetworkProtocolNumber = 0x88a8
+
+	// VLANIDMask is the mask of the VLAN identifier in the tag control
+	// information.
+	VLANIDMask = 0x0fff
+
+	// VLANMaxID is the largest valid VLAN identifier. 0xfff is reserved.
+	VLANMaxID = 4094
+)
+
+// VLANFields contains the fields of an 802.1Q tag. It is used to describe the
+// fields of a tag that needs to be encoded.
+type VLANFields struct {
+	// TCI is the tag control information, which contains the priority code
+	// point, drop eligible indicator and VLAN identifier.
+	TCI uint16
+
+	// Type is the EtherType of the encapsulated payload.
+	Type tcpip.NetworkProtocolNumber
+}
+
+// VLAN represents an IEEE 802.1Q tag stored in a byte array. It follows the
+// tag protocol identifier that takes the place of the EtherType of an
+// Ethernet header.
+type VLAN []byte
+
+// TCI returns the tag control information.
+func (b VLAN) TCI() uint16 {
+	return binary.BigEndian.Uint16(b[vlanTCI:])
+}
+
+// ID returns the VLAN identifier.
+func (b VLAN) ID() uint16 {
+	return b.TCI() & VLANIDMask
+}
+
+// Type returns the EtherType of the encapsulated payload.
+func (b VLAN) Type() tcpip.NetworkProtocolNumber {
+	return tcpip.NetworkProtocolNumber(binary.BigEndian.Uint16(b[vlanType:]))
+}
+
+// Encode encodes all the fields of the tag.
+func (b VLAN) Encode(f *VLANFields) {
+	binary.BigEndian.PutUint16(b[vlanTCI:], f.TCI)
+	binary.BigEndian.PutUint16(b[vlanType:], uint16(f.Type))
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package header
+
+import "encoding/binary"
+
+const (
+	vxlanFlags = 0
+	vxlanVNI   = 4
+)
+
+const (
+	// VXLANHeaderSize is the size of a VXLAN header.
+	VXLANHeaderSize = 8
+
+	// VXLANPort is the IANA assigned UDP port of VXLAN.
+	VXLANPort = 4789
+
+	// VXLANFlagVNI is the "I" flag, which indicates that the VNI is valid.
+	VXLANFlagVNI = 0x08
+
+	// VXLANMaxVNI is the largest VXLAN network identifier.
+	VXLANMaxVNI = 1<<24 - 1
+)
+
+// VXLAN represents a VXLAN header stored in a byte array, the fields are
+// described in RFC 7348 section 5.
+type VXLAN []byte
+
+// Flags returns the flags of the VXLAN header.
+func (b VXLAN) Flags() uint8 {
+	return b[vxlanFlags]
+}
+
+// VNI returns the VXLAN network identifier.
+func (b VXLAN) VNI() uint32 {
+	return binary.BigEndian.Uint32(b[vxlanVNI:]) >> 8
+}
+
+// Encode encodes a VXLAN header with the given VXLAN network identifier.
+func (b VXLAN) Encode(vni uint32) {
+	clear(b[:VXLANHeaderSize])
+	b[vxlanFlags] = VXLANFlagVNI
+	binary.BigEndian.PutUint32(b[vxlanVNI:], vni<<8)
+}
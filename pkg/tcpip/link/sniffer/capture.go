@@ -0,0 +1,281 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sniffer
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/bpf"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+const (
+	// DefaultCaptureSnapLen is the default maximum number of bytes captured
+	// per packet.
+	DefaultCaptureSnapLen = 262144
+
+	// captureQueueLen is the number of encoded packets that may be waiting to
+	// be written. Packets captured while the queue is full are dropped, so that
+	// a slow reader never blocks the network stack.
+	captureQueueLen = 1024
+)
+
+// CaptureOptions configures a capture.
+type CaptureOptions struct {
+	// Filter, if not empty, is a classic BPF program run on each packet,
+	// starting at its link header, like a packet socket filter. Packets for
+	// which it returns 0 are not captured, and at most as many bytes as it
+	// returns are captured of other packets.
+	Filter []bpf.Instruction
+
+	// SnapLen is the maximum number of bytes captured per packet. If zero,
+	// DefaultCaptureSnapLen is used.
+	SnapLen uint32
+
+	// Annotate, if set, is called with the owner of the socket that sent or
+	// receives each packet, i.e. the task that created it, and returns a
+	// comment that is attached to the packet. It is not called for packets
+	// without a local socket.
+	Annotate func(owner tcpip.PacketOwner) string
+}
+
+// Capture captures the packets sent and received by a NIC of a running stack
+// and streams them in pcapng format. Unlike Endpoint, it doesn't need to be
+// installed when the NIC is created: it is attached to and detached from the
+// NIC as a packet endpoint.
+//
+// Captures don't survive save/restore; a restored capture drops all packets.
+//
+// +stateify savable
+type Capture struct {
+	stack   *stack.Stack   `state:"nosave"`
+	nicID   tcpip.NICID    `state:"nosave"`
+	filter  bpf.Program    `state:"nosave"`
+	snapLen uint32         `state:"nosave"`
+	opts    CaptureOptions `state:"nosave"`
+	w       io.WriteCloser `state:"nosave"`
+	start   time.Time      `state:"nosave"`
+	blocks  chan []byte    `state:"nosave"`
+
+	// done is closed when all blocks have been written, after which err holds
+	// the first write error.
+	done chan struct{} `state:"nosave"`
+	err  error         `state:"nosave"`
+
+	// mu protects against sending on blocks after it is closed.
+	mu sync.RWMutex `state:"nosave"`
+	// +checklocks:mu
+	stopped bool `state:"nosave"`
+
+	// received is the number of packets that passed the filter, and dropped
+	// the number of them that were dropped because the queue was full.
+	received atomicbitops.Uint64 `state:"nosave"`
+	dropped  atomicbitops.Uint64 `state:"nosave"`
+}
+
+var _ stack.PacketEndpoint = (*Capture)(nil)
+
+// StartCapture starts capturing packets of the given NIC of s to w, which is
+// closed when the capture is stopped or fails to start. name is the interface
+// name recorded in the capture.
+func StartCapture(s *stack.Stack, nicID tcpip.NICID, name string, w io.WriteCloser, opts CaptureOptions) (*Capture, error) {
+	info, ok := s.NICInfo()[nicID]
+	if !ok {
+		return nil, fmt.Errorf("unknown NIC %d", nicID)
+	}
+	c := &Capture{
+		stack:   s,
+		nicID:   nicID,
+		snapLen: opts.SnapLen,
+		opts:    opts,
+		w:       w,
+		start:   time.Now(),
+		done:    make(chan struct{}),
+		blocks:  make(chan []byte, captureQueueLen),
+	}
+	if c.snapLen == 0 {
+		c.snapLen = DefaultCaptureSnapLen
+	}
+	if len(opts.Filter) > 0 {
+		var err error
+		if c.filter, err = bpf.Compile(opts.Filter, true /* optimize */); err != nil {
+			return nil, fmt.Errorf("invalid capture filter: %w", err)
+		}
+	}
+	linkType := uint16(pcapngLinkTypeRaw)
+	if info.ARPHardwareType == header.ARPHardwareEther {
+		linkType = pcapngLinkTypeEthernet
+	}
+	c.blocks <- pcapngSectionHeader()
+	c.blocks <- pcapngInterfaceDescription(linkType, c.snapLen, name)
+	go c.writeLoop() // S/R-SAFE: captures are not saved.
+	if err := s.RegisterPacketEndpoint(nicID, header.EthernetProtocolAll, c); err != nil {
+		c.mu.Lock()
+		c.stopped = true
+		close(c.blocks)
+		c.mu.Unlock()
+		<-c.done
+		return nil, fmt.Errorf("attaching capture to NIC %d: %s", nicID, err)
+	}
+	return c, nil
+}
+
+// Stop detaches the capture from its NIC and waits until all captured packets
+// have been written. It returns the first error encountered while writing.
+func (c *Capture) Stop() error {
+	c.stack.UnregisterPacketEndpoint(c.nicID, header.EthernetProtocolAll, c)
+	// Packets may still be in flight in HandlePacket after the endpoint is
+	// unregistered; mu keeps them from sending on the closed channel.
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		<-c.done
+		return c.err
+	}
+	c.stopped = true
+	c.blocks <- pcapngInterfaceStatistics(0, c.start, time.Now(), c.received.Load(), c.dropped.Load())
+	close(c.blocks)
+	c.mu.Unlock()
+	<-c.done
+	return c.err
+}
+
+// writeLoop writes queued blocks until the queue is closed. After a write
+// error, remaining blocks are discarded.
+func (c *Capture) writeLoop() {
+	defer close(c.done)
+	for b := range c.blocks {
+		if c.err != nil {
+			continue
+		}
+		if _, err := c.w.Write(b); err != nil {
+			log.Warningf("Packet capture on NIC %d failed: %v", c.nicID, err)
+			c.err = err
+		}
+	}
+	if err := c.w.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+// HandlePacket implements stack.PacketEndpoint.HandlePacket.
+func (c *Capture) HandlePacket(nicID tcpip.NICID, netProto tcpip.NetworkProtocolNumber, pkt *stack.PacketBuffer) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.stopped || c.blocks == nil {
+		return
+	}
+
+	v := pkt.ToView()
+	defer v.Release()
+	data := v.AsSlice()
+	capLen := uint32(len(data))
+	if c.filter.Length() > 0 {
+		ret, err := bpf.Exec[bpf.BigEndian](c.filter, bpf.Input(data))
+		if err != nil || ret == 0 {
+			return
+		}
+		capLen = min(capLen, ret)
+	}
+	capLen = min(capLen, c.snapLen)
+	c.received.Add(1)
+
+	outbound := pkt.PktType == tcpip.PacketOutgoing
+	var comment string
+	if c.opts.Annotate != nil {
+		owner := pkt.Owner
+		if !outbound {
+			owner = c.receiver(nicID, netProto, data[len(pkt.LinkHeader().Slice()):])
+		}
+		if owner != nil {
+			comment = c.opts.Annotate(owner)
+		}
+	}
+
+	b := pcapngEnhancedPacket(0, time.Now(), data[:capLen], uint32(len(data)), outbound, comment)
+	select {
+	case c.blocks <- b:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// packetOwnerEndpoint is implemented by transport endpoints that know the
+// owner of their packets.
+type packetOwnerEndpoint interface {
+	Owner() tcpip.PacketOwner
+}
+
+// receiver returns the owner of the socket that receives the TCP or UDP
+// packet with the given network header and payload, or nil if unknown.
+func (c *Capture) receiver(nicID tcpip.NICID, netProto tcpip.NetworkProtocolNumber, b []byte) tcpip.PacketOwner {
+	var (
+		id         stack.TransportEndpointID
+		transProto tcpip.TransportProtocolNumber
+		payload    []byte
+	)
+	switch netProto {
+	case header.IPv4ProtocolNumber:
+		h := header.IPv4(b)
+		if !h.IsValid(len(b)) || h.FragmentOffset() != 0 {
+			return nil
+		}
+		id.LocalAddress = h.DestinationAddress()
+		id.RemoteAddress = h.SourceAddress()
+		transProto = h.TransportProtocol()
+		payload = b[h.HeaderLength():]
+	case header.IPv6ProtocolNumber:
+		h := header.IPv6(b)
+		if !h.IsValid(len(b)) {
+			return nil
+		}
+		id.LocalAddress = h.DestinationAddress()
+		id.RemoteAddress = h.SourceAddress()
+		transProto = h.TransportProtocol()
+		payload = b[header.IPv6MinimumSize:]
+	default:
+		return nil
+	}
+	switch transProto {
+	case header.TCPProtocolNumber:
+		if len(payload) < header.TCPMinimumSize {
+			return nil
+		}
+		h := header.TCP(payload)
+		id.LocalPort = h.DestinationPort()
+		id.RemotePort = h.SourcePort()
+	case header.UDPProtocolNumber:
+		if len(payload) < header.UDPMinimumSize {
+			return nil
+		}
+		h := header.UDP(payload)
+		id.LocalPort = h.DestinationPort()
+		id.RemotePort = h.SourcePort()
+	default:
+		return nil
+	}
+	ep, ok := c.stack.FindTransportEndpoint(netProto, transProto, id, nicID).(packetOwnerEndpoint)
+	if !ok {
+		return nil
+	}
+	return ep.Owner()
+}
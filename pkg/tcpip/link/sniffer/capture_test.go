@@ -0,0 +1,241 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sniffer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gvisor.dev/gvisor/pkg/bpf"
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+const (
+	nicID      = 1
+	defaultMTU = 1500
+)
+
+// bufferCloser is an io.WriteCloser that writes to a buffer.
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+// Close implements io.Closer.Close.
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+// pcapngTestBlock is a block of a pcapng stream.
+type pcapngTestBlock struct {
+	blockType uint32
+	body      []byte
+}
+
+// parsePCAPNG splits a pcapng stream into blocks.
+func parsePCAPNG(t *testing.T, b []byte) []pcapngTestBlock {
+	t.Helper()
+	var blocks []pcapngTestBlock
+	for len(b) > 0 {
+		if len(b) < 12 {
+			t.Fatalf("truncated block: %x", b)
+		}
+		length := binary.LittleEndian.Uint32(b[4:])
+		if length%4 != 0 || length < 12 || int(length) > len(b) {
+			t.Fatalf("invalid block length %d with %d bytes left", length, len(b))
+		}
+		if trailer := binary.LittleEndian.Uint32(b[length-4:]); trailer != length {
+			t.Fatalf("got trailing block length %d, want %d", trailer, length)
+		}
+		blocks = append(blocks, pcapngTestBlock{
+			blockType: binary.LittleEndian.Uint32(b),
+			body:      b[8 : length-4],
+		})
+		b = b[length:]
+	}
+	return blocks
+}
+
+// parsePCAPNGOptions returns the options in b, keyed by code.
+func parsePCAPNGOptions(t *testing.T, b []byte) map[uint16][]byte {
+	t.Helper()
+	opts := make(map[uint16][]byte)
+	for {
+		if len(b) < 4 {
+			t.Fatalf("options are not terminated")
+		}
+		code := binary.LittleEndian.Uint16(b)
+		length := int(binary.LittleEndian.Uint16(b[2:]))
+		if code == pcapngOptEndOfOpt {
+			return opts
+		}
+		padded := (length + 3) &^ 3
+		if 4+padded > len(b) {
+			t.Fatalf("truncated option %d", code)
+		}
+		opts[code] = b[4 : 4+length]
+		b = b[4+padded:]
+	}
+}
+
+// ipv4Packet returns an IPv4 packet with the given transport protocol and
+// payload length.
+func ipv4Packet(proto tcpip.TransportProtocolNumber, payloadLen int) []byte {
+	b := make([]byte, header.IPv4MinimumSize+payloadLen)
+	ip := header.IPv4(b)
+	ip.Encode(&header.IPv4Fields{
+		TotalLength: uint16(len(b)),
+		TTL:         64,
+		Protocol:    uint8(proto),
+		SrcAddr:     tcpip.AddrFrom4([4]byte{10, 0, 0, 2}),
+		DstAddr:     tcpip.AddrFrom4([4]byte{10, 0, 0, 1}),
+	})
+	ip.SetChecksum(^ip.CalculateChecksum())
+	return b
+}
+
+// TestCapture tests that a capture attached to a NIC writes the packets that
+// pass its filter in pcapng format.
+func TestCapture(t *testing.T) {
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+	})
+	defer s.Destroy()
+	ep := channel.New(1, defaultMTU, "")
+	if err := s.CreateNICWithOptions(nicID, ep, stack.NICOptions{DeliverLinkPackets: true}); err != nil {
+		t.Fatalf("CreateNICWithOptions(%d, _, _): %s", nicID, err)
+	}
+
+	// Capture the first 64 bytes of UDP packets. Packets on the channel
+	// endpoint have no link header.
+	const filterLen = 64
+	w := &bufferCloser{}
+	c, err := StartCapture(s, nicID, "eth0", w, CaptureOptions{
+		Filter: []bpf.Instruction{
+			bpf.Stmt(bpf.Ld|bpf.B|bpf.Abs, 9),
+			bpf.Jump(bpf.Jmp|bpf.Jeq|bpf.K, uint32(header.UDPProtocolNumber), 0, 1),
+			bpf.Stmt(bpf.Ret|bpf.K, filterLen),
+			bpf.Stmt(bpf.Ret|bpf.K, 0),
+		},
+		SnapLen: 100,
+	})
+	if err != nil {
+		t.Fatalf("StartCapture: %v", err)
+	}
+
+	udp := ipv4Packet(header.UDPProtocolNumber, 100)
+	for _, b := range [][]byte{ipv4Packet(header.ICMPv4ProtocolNumber, 100), udp} {
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{Payload: buffer.MakeWithData(b)})
+		ep.InjectInbound(header.IPv4ProtocolNumber, pkt)
+		pkt.DecRef()
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if !w.closed {
+		t.Errorf("capture output was not closed")
+	}
+
+	blocks := parsePCAPNG(t, w.Bytes())
+	var types []uint32
+	for _, b := range blocks {
+		types = append(types, b.blockType)
+	}
+	wantTypes := []uint32{
+		pcapngSectionHeaderBlock,
+		pcapngInterfaceDescriptionBlock,
+		pcapngEnhancedPacketBlock,
+		pcapngInterfaceStatisticsBlock,
+	}
+	if diff := cmp.Diff(wantTypes, types); diff != "" {
+		t.Fatalf("unexpected blocks (-want +got):\n%s", diff)
+	}
+
+	if got := binary.LittleEndian.Uint32(blocks[0].body); got != pcapngByteOrderMagic {
+		t.Errorf("got byte-order magic %#x, want %#x", got, pcapngByteOrderMagic)
+	}
+
+	idb := blocks[1].body
+	if got := binary.LittleEndian.Uint16(idb); got != pcapngLinkTypeRaw {
+		t.Errorf("got link type %d, want %d", got, pcapngLinkTypeRaw)
+	}
+	if got := binary.LittleEndian.Uint32(idb[4:]); got != 100 {
+		t.Errorf("got snapshot length %d, want 100", got)
+	}
+	idbOpts := parsePCAPNGOptions(t, idb[8:])
+	if got := string(idbOpts[pcapngIfName]); got != "eth0" {
+		t.Errorf("got interface name %q, want %q", got, "eth0")
+	}
+	if diff := cmp.Diff([]byte{pcapngTsresolNanoseconds}, idbOpts[pcapngIfTsresol]); diff != "" {
+		t.Errorf("unexpected timestamp resolution (-want +got):\n%s", diff)
+	}
+
+	epb := blocks[2].body
+	capLen := binary.LittleEndian.Uint32(epb[12:])
+	origLen := binary.LittleEndian.Uint32(epb[16:])
+	if capLen != filterLen || origLen != uint32(len(udp)) {
+		t.Errorf("got captured/original length %d/%d, want %d/%d", capLen, origLen, filterLen, len(udp))
+	}
+	if diff := cmp.Diff(udp[:filterLen], epb[20:20+capLen]); diff != "" {
+		t.Errorf("unexpected packet data (-want +got):\n%s", diff)
+	}
+	epbOpts := parsePCAPNGOptions(t, epb[20+capLen:])
+	if got := binary.LittleEndian.Uint32(epbOpts[pcapngEPBFlags]); got != pcapngEPBFlagsInbound {
+		t.Errorf("got packet flags %d, want %d", got, pcapngEPBFlagsInbound)
+	}
+
+	isbOpts := parsePCAPNGOptions(t, blocks[3].body[12:])
+	if got := binary.LittleEndian.Uint64(isbOpts[pcapngISBIfRecv]); got != 1 {
+		t.Errorf("got %d received packets, want 1", got)
+	}
+	if got := binary.LittleEndian.Uint64(isbOpts[pcapngISBIfDrop]); got != 0 {
+		t.Errorf("got %d dropped packets, want 0", got)
+	}
+
+	// Packets after the capture is stopped are not captured.
+	n := w.Len()
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{Payload: buffer.MakeWithData(udp)})
+	ep.InjectInbound(header.IPv4ProtocolNumber, pkt)
+	pkt.DecRef()
+	if w.Len() != n {
+		t.Errorf("packet was captured after the capture was stopped")
+	}
+}
+
+// TestCaptureInvalid tests that invalid captures are rejected.
+func TestCaptureInvalid(t *testing.T) {
+	s := stack.New(stack.Options{})
+	defer s.Destroy()
+	if err := s.CreateNIC(nicID, channel.New(1, defaultMTU, "")); err != nil {
+		t.Fatalf("CreateNIC(%d, _): %s", nicID, err)
+	}
+
+	if _, err := StartCapture(s, nicID+1, "", &bufferCloser{}, CaptureOptions{}); err == nil {
+		t.Errorf("StartCapture on unknown NIC succeeded")
+	}
+	// A filter must end with a return.
+	filter := []bpf.Instruction{bpf.Stmt(bpf.Ld|bpf.B|bpf.Abs, 9)}
+	if _, err := StartCapture(s, nicID, "", &bufferCloser{}, CaptureOptions{Filter: filter}); err == nil {
+		t.Errorf("StartCapture with invalid filter succeeded")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sniffer
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// pcapng block types and option codes, as defined by
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html.
+const (
+	pcapngSectionHeaderBlock        = 0x0A0D0D0A
+	pcapngInterfaceDescriptionBlock = 0x00000001
+	pcapngInterfaceStatisticsBlock  = 0x00000005
+	pcapngEnhancedPacketBlock       = 0x00000006
+
+	pcapngByteOrderMagic = 0x1A2B3C4D
+
+	pcapngOptEndOfOpt = 0
+	pcapngOptComment  = 1
+
+	pcapngSHBUserAppl = 4
+
+	pcapngIfName    = 2
+	pcapngIfTsresol = 9
+
+	pcapngEPBFlags = 2
+
+	pcapngISBStartTime = 2
+	pcapngISBEndTime   = 3
+	pcapngISBIfRecv    = 4
+	pcapngISBIfDrop    = 5
+
+	// pcapngTsresolNanoseconds is the if_tsresol value for timestamps in
+	// nanoseconds.
+	pcapngTsresolNanoseconds = 9
+
+	// pcapngEPBFlagsInbound and pcapngEPBFlagsOutbound are the epb_flags values
+	// for the direction of a packet.
+	pcapngEPBFlagsInbound  = 1
+	pcapngEPBFlagsOutbound = 2
+)
+
+// Link types, as defined by https://www.tcpdump.org/linktypes.html.
+const (
+	pcapngLinkTypeEthernet = 1
+	pcapngLinkTypeRaw      = 101
+)
+
+// pcapngOptions builds the options of a pcapng block.
+type pcapngOptions []byte
+
+// add appends an option with the given code and value.
+func (o *pcapngOptions) add(code uint16, value []byte) {
+	b := binary.LittleEndian.AppendUint16(*o, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	*o = pcapngPad(b)
+}
+
+// addString appends an option with a string value.
+func (o *pcapngOptions) addString(code uint16, value string) {
+	o.add(code, []byte(value))
+}
+
+// addUint32 appends an option with a 32-bit value.
+func (o *pcapngOptions) addUint32(code uint16, value uint32) {
+	o.add(code, binary.LittleEndian.AppendUint32(nil, value))
+}
+
+// addUint64 appends an option with a 64-bit value.
+func (o *pcapngOptions) addUint64(code uint16, value uint64) {
+	o.add(code, binary.LittleEndian.AppendUint64(nil, value))
+}
+
+// addTimestamp appends an option with a timestamp value, in the resolution
+// of pcapngTsresolNanoseconds.
+func (o *pcapngOptions) addTimestamp(code uint16, t time.Time) {
+	ts := uint64(t.UnixNano())
+	b := binary.LittleEndian.AppendUint32(nil, uint32(ts>>32))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts))
+	o.add(code, b)
+}
+
+// appendTo appends the options to b, terminated by opt_endofopt. Nothing is
+// appended if there are no options.
+func (o pcapngOptions) appendTo(b []byte) []byte {
+	if len(o) == 0 {
+		return b
+	}
+	b = append(b, o...)
+	return binary.LittleEndian.AppendUint32(b, pcapngOptEndOfOpt)
+}
+
+// pcapngPad pads b with zeroes to a multiple of 32 bits.
+func pcapngPad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// pcapngBlock returns a pcapng block of the given type. body must be padded
+// to a multiple of 32 bits.
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	length := uint32(12 + len(body))
+	b := make([]byte, 0, length)
+	b = binary.LittleEndian.AppendUint32(b, blockType)
+	b = binary.LittleEndian.AppendUint32(b, length)
+	b = append(b, body...)
+	return binary.LittleEndian.AppendUint32(b, length)
+}
+
+// pcapngSectionHeader returns a section header block, which starts a pcapng
+// stream. The section length is unspecified, so that the stream can be
+// written without seeking.
+func pcapngSectionHeader() []byte {
+	b := binary.LittleEndian.AppendUint32(nil, pcapngByteOrderMagic)
+	b = binary.LittleEndian.AppendUint16(b, 1) // Major version.
+	b = binary.LittleEndian.AppendUint16(b, 0) // Minor version.
+	b = binary.LittleEndian.AppendUint64(b, ^uint64(0))
+	var opts pcapngOptions
+	opts.addString(pcapngSHBUserAppl, "gVisor")
+	return pcapngBlock(pcapngSectionHeaderBlock, opts.appendTo(b))
+}
+
+// pcapngInterfaceDescription returns an interface description block for an
+// interface with the given link type, snapshot length and name. Packet
+// timestamps are in nanoseconds.
+func pcapngInterfaceDescription(linkType uint16, snapLen uint32, name string) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, linkType)
+	b = binary.LittleEndian.AppendUint16(b, 0) // Reserved.
+	b = binary.LittleEndian.AppendUint32(b, snapLen)
+	var opts pcapngOptions
+	if name != "" {
+		opts.addString(pcapngIfName, name)
+	}
+	opts.add(pcapngIfTsresol, []byte{pcapngTsresolNanoseconds})
+	return pcapngBlock(pcapngInterfaceDescriptionBlock, opts.appendTo(b))
+}
+
+// pcapngEnhancedPacket returns an enhanced packet block for a packet captured
+// on the interface with the given ID. data holds the captured bytes of the
+// packet, and origLen is its length on the wire. comment is an optional
+// annotation.
+func pcapngEnhancedPacket(ifaceID uint32, t time.Time, data []byte, origLen uint32, outbound bool, comment string) []byte {
+	ts := uint64(t.UnixNano())
+	b := make([]byte, 0, 20+len(data)+32+len(comment))
+	b = binary.LittleEndian.AppendUint32(b, ifaceID)
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts>>32))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	b = binary.LittleEndian.AppendUint32(b, origLen)
+	b = pcapngPad(append(b, data...))
+	var opts pcapngOptions
+	if outbound {
+		opts.addUint32(pcapngEPBFlags, pcapngEPBFlagsOutbound)
+	} else {
+		opts.addUint32(pcapngEPBFlags, pcapngEPBFlagsInbound)
+	}
+	if comment != "" {
+		opts.addString(pcapngOptComment, comment)
+	}
+	return pcapngBlock(pcapngEnhancedPacketBlock, opts.appendTo(b))
+}
+
+// pcapngInterfaceStatistics returns an interface statistics block for the
+// interface with the given ID, with the number of packets received and
+// dropped by the capture between start and end.
+func pcapngInterfaceStatistics(ifaceID uint32, start, end time.Time, received, dropped uint64) []byte {
+	ts := uint64(end.UnixNano())
+	b := binary.LittleEndian.AppendUint32(nil, ifaceID)
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts>>32))
+	b = binary.LittleEndian.AppendUint32(b, uint32(ts))
+	var opts pcapngOptions
+	opts.addTimestamp(pcapngISBStartTime, start)
+	opts.addTimestamp(pcapngISBEndTime, end)
+	opts.addUint64(pcapngISBIfRecv, received)
+	opts.addUint64(pcapngISBIfDrop, dropped)
+	return pcapngBlock(pcapngInterfaceStatisticsBlock, opts.appendTo(b))
+}
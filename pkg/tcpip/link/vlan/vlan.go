@@ -0,0 +1,272 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vlan provides the implementation of IEEE 802.1Q VLAN devices.
+//
+// A VLAN device is stacked on top of a parent Ethernet NIC. Like a veth
+// device, it carries whole Ethernet frames and must be wrapped by an
+// ethernet.Endpoint. Outgoing frames are tagged with the VLAN ID and written
+// to the parent NIC. Incoming frames are received from the parent NIC as
+// packets of the tag protocol, so the parent's link endpoint must deliver
+// link packets (e.g. by being wrapped by a packetsocket.Endpoint).
+package vlan
+
+import (
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+var _ stack.LinkEndpoint = (*Endpoint)(nil)
+var _ stack.PacketEndpoint = (*Endpoint)(nil)
+
+// Endpoint is a link endpoint of a VLAN device.
+//
+// +stateify savable
+type Endpoint struct {
+	stack    *stack.Stack
+	parent   tcpip.NICID
+	protocol tcpip.NetworkProtocolNumber
+	id       uint16
+
+	mu endpointRWMutex `state:"nosave"`
+	// +checklocks:mu
+	dispatcher stack.NetworkDispatcher
+	// +checklocks:mu
+	linkAddr tcpip.LinkAddress
+	// +checklocks:mu
+	mtu uint32
+	// +checklocks:mu
+	closed bool
+	// +checklocks:mu
+	onCloseAction func() `state:"nosave"`
+}
+
+// New creates a VLAN endpoint with the given VLAN ID on top of the parent NIC
+// of s. protocol is the tag protocol identifier, either
+// header.VLANProtocol8021Q or header.VLANProtocol8021AD.
+func New(s *stack.Stack, parent tcpip.NICID, protocol tcpip.NetworkProtocolNumber, id uint16, mtu uint32, linkAddr tcpip.LinkAddress) (*Endpoint, tcpip.Error) {
+	switch protocol {
+	case header.VLANProtocol8021Q, header.VLANProtocol8021AD:
+	default:
+		return nil, &tcpip.ErrNotSupported{}
+	}
+	if id > header.VLANMaxID || parent == 0 {
+		return nil, &tcpip.ErrInvalidOptionValue{}
+	}
+	e := &Endpoint{
+		stack:    s,
+		parent:   parent,
+		protocol: protocol,
+		id:       id,
+		linkAddr: linkAddr,
+		mtu:      mtu,
+	}
+	if err := s.RegisterPacketEndpoint(parent, protocol, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ID returns the VLAN ID of the endpoint.
+func (e *Endpoint) ID() uint16 {
+	return e.id
+}
+
+// Parent returns the ID of the parent NIC.
+func (e *Endpoint) Parent() tcpip.NICID {
+	return e.parent
+}
+
+// Protocol returns the tag protocol identifier of the endpoint.
+func (e *Endpoint) Protocol() tcpip.NetworkProtocolNumber {
+	return e.protocol
+}
+
+// HandlePacket implements stack.PacketEndpoint.HandlePacket. It is called
+// for tagged frames received by the parent NIC.
+func (e *Endpoint) HandlePacket(nicID tcpip.NICID, _ tcpip.NetworkProtocolNumber, pkt *stack.PacketBuffer) {
+	if nicID != e.parent {
+		return
+	}
+	eth := header.Ethernet(pkt.LinkHeader().Slice())
+	if len(eth) < header.EthernetMinimumSize {
+		return
+	}
+	b, ok := pkt.Data().PullUp(header.VLANTagSize)
+	if !ok {
+		return
+	}
+	tag := header.VLAN(b)
+	if tag.ID() != e.id {
+		return
+	}
+
+	e.mu.RLock()
+	d := e.dispatcher
+	e.mu.RUnlock()
+	if d == nil {
+		return
+	}
+
+	// Remove the tag and deliver the frame with the encapsulated EtherType.
+	hdr := make([]byte, header.EthernetMinimumSize)
+	header.Ethernet(hdr).Encode(&header.EthernetFields{
+		SrcAddr: eth.SourceAddress(),
+		DstAddr: eth.DestinationAddress(),
+		Type:    tag.Type(),
+	})
+	pkt.Data().TrimFront(header.VLANTagSize)
+	payload := buffer.MakeWithData(hdr)
+	data := pkt.Data().ToBuffer()
+	payload.Merge(&data)
+	newPkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: payload,
+	})
+	defer newPkt.DecRef()
+	d.DeliverNetworkPacket(tag.Type(), newPkt)
+}
+
+// WritePackets implements stack.LinkEndpoint.WritePackets. Frames are tagged
+// and written to the parent NIC.
+func (e *Endpoint) WritePackets(pkts stack.PacketBufferList) (int, tcpip.Error) {
+	e.mu.RLock()
+	closed := e.closed
+	e.mu.RUnlock()
+	if closed {
+		return 0, &tcpip.ErrClosedForSend{}
+	}
+
+	n := 0
+	for _, pkt := range pkts.AsSlice() {
+		frame := pkt.ToBuffer()
+		hdr := make([]byte, header.EthernetMinimumSize+header.VLANTagSize)
+		if c, _ := frame.ReadAt(hdr[:header.EthernetMinimumSize], 0); c != header.EthernetMinimumSize {
+			frame.Release()
+			return n, &tcpip.ErrMalformedHeader{}
+		}
+		eth := header.Ethernet(hdr)
+		tagFields := header.VLANFields{
+			TCI:  e.id,
+			Type: eth.Type(),
+		}
+		eth.Encode(&header.EthernetFields{
+			SrcAddr: eth.SourceAddress(),
+			DstAddr: eth.DestinationAddress(),
+			Type:    e.protocol,
+		})
+		header.VLAN(hdr[header.EthernetMinimumSize:]).Encode(&tagFields)
+		frame.TrimFront(header.EthernetMinimumSize)
+		payload := buffer.MakeWithData(hdr)
+		payload.Merge(&frame)
+		if err := e.stack.WriteRawPacket(e.parent, e.protocol, payload); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Attach implements stack.LinkEndpoint.Attach.
+func (e *Endpoint) Attach(dispatcher stack.NetworkDispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dispatcher = dispatcher
+}
+
+// IsAttached implements stack.LinkEndpoint.IsAttached.
+func (e *Endpoint) IsAttached() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.dispatcher != nil
+}
+
+// MTU implements stack.LinkEndpoint.MTU.
+func (e *Endpoint) MTU() uint32 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.mtu
+}
+
+// SetMTU implements stack.LinkEndpoint.SetMTU.
+func (e *Endpoint) SetMTU(mtu uint32) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.mtu = mtu
+}
+
+// Capabilities implements stack.LinkEndpoint.Capabilities.
+func (*Endpoint) Capabilities() stack.LinkEndpointCapabilities {
+	return stack.CapabilitySaveRestore
+}
+
+// MaxHeaderLength implements stack.LinkEndpoint.MaxHeaderLength. The tag is
+// inserted into a new buffer, so no space has to be reserved for it.
+func (*Endpoint) MaxHeaderLength() uint16 {
+	return 0
+}
+
+// LinkAddress implements stack.LinkEndpoint.LinkAddress.
+func (e *Endpoint) LinkAddress() tcpip.LinkAddress {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.linkAddr
+}
+
+// SetLinkAddress implements stack.LinkEndpoint.SetLinkAddress.
+func (e *Endpoint) SetLinkAddress(addr tcpip.LinkAddress) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.linkAddr = addr
+}
+
+// Wait implements stack.LinkEndpoint.Wait.
+func (*Endpoint) Wait() {}
+
+// ARPHardwareType implements stack.LinkEndpoint.ARPHardwareType.
+func (*Endpoint) ARPHardwareType() header.ARPHardwareType {
+	return header.ARPHardwareNone
+}
+
+// AddHeader implements stack.LinkEndpoint.AddHeader.
+func (*Endpoint) AddHeader(*stack.PacketBuffer) {}
+
+// ParseHeader implements stack.LinkEndpoint.ParseHeader.
+func (*Endpoint) ParseHeader(*stack.PacketBuffer) bool { return true }
+
+// Close implements stack.LinkEndpoint.Close.
+func (e *Endpoint) Close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	action := e.onCloseAction
+	e.onCloseAction = nil
+	e.mu.Unlock()
+
+	e.stack.UnregisterPacketEndpoint(e.parent, e.protocol, e)
+	if action != nil {
+		action()
+	}
+}
+
+// SetOnCloseAction implements stack.LinkEndpoint.SetOnCloseAction.
+func (e *Endpoint) SetOnCloseAction(action func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onCloseAction = action
+}
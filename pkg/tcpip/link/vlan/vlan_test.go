@@ -0,0 +1,172 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vlan_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/refs"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/link/ethernet"
+	"gvisor.dev/gvisor/pkg/tcpip/link/packetsocket"
+	"gvisor.dev/gvisor/pkg/tcpip/link/vlan"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+const (
+	parentNICID = 1
+	vlanNICID   = 2
+	vlanID      = 100
+
+	localLinkAddr  = tcpip.LinkAddress("\x02\x02\x03\x04\x05\x06")
+	remoteLinkAddr = tcpip.LinkAddress("\x02\x02\x03\x04\x05\x07")
+)
+
+// packetSink is a stack.PacketEndpoint that stores received packets.
+type packetSink struct {
+	pkts []*stack.PacketBuffer
+}
+
+func (s *packetSink) HandlePacket(_ tcpip.NICID, _ tcpip.NetworkProtocolNumber, pkt *stack.PacketBuffer) {
+	s.pkts = append(s.pkts, pkt.Clone())
+}
+
+func newTestStack(t *testing.T) (*stack.Stack, *channel.Endpoint) {
+	t.Helper()
+
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+	})
+	t.Cleanup(s.Destroy)
+	ch := channel.New(1, 1500, localLinkAddr)
+	if err := s.CreateNIC(parentNICID, packetsocket.New(ethernet.New(ch))); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", parentNICID, err)
+	}
+	ep, err := vlan.New(s, parentNICID, header.VLANProtocol8021Q, vlanID, 1500, localLinkAddr)
+	if err != nil {
+		t.Fatalf("vlan.New(_, %d, _, %d, _, _): %s", parentNICID, vlanID, err)
+	}
+	if err := s.CreateNIC(vlanNICID, packetsocket.New(ethernet.New(ep))); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", vlanNICID, err)
+	}
+	return s, ch
+}
+
+func TestWritePacket(t *testing.T) {
+	s, ch := newTestStack(t)
+
+	data := []byte{1, 2, 3, 4}
+	frame := make([]byte, header.EthernetMinimumSize)
+	header.Ethernet(frame).Encode(&header.EthernetFields{
+		SrcAddr: localLinkAddr,
+		DstAddr: remoteLinkAddr,
+		Type:    ipv4.ProtocolNumber,
+	})
+	frame = append(frame, data...)
+	if err := s.WriteRawPacket(vlanNICID, ipv4.ProtocolNumber, buffer.MakeWithData(frame)); err != nil {
+		t.Fatalf("s.WriteRawPacket(%d, _, _): %s", vlanNICID, err)
+	}
+
+	pkt := ch.Read()
+	if pkt == nil {
+		t.Fatalf("no packet was written to the parent NIC")
+	}
+	defer pkt.DecRef()
+	buf := pkt.ToBuffer()
+	defer buf.Release()
+	got := buf.Flatten()
+	if len(got) != header.EthernetMinimumSize+header.VLANTagSize+len(data) {
+		t.Fatalf("got frame of %d bytes, want %d bytes", len(got), header.EthernetMinimumSize+header.VLANTagSize+len(data))
+	}
+	eth := header.Ethernet(got)
+	if eth.Type() != header.VLANProtocol8021Q {
+		t.Errorf("got EtherType = %#x, want %#x", eth.Type(), header.VLANProtocol8021Q)
+	}
+	if eth.DestinationAddress() != remoteLinkAddr {
+		t.Errorf("got destination = %s, want %s", eth.DestinationAddress(), remoteLinkAddr)
+	}
+	tag := header.VLAN(got[header.EthernetMinimumSize:])
+	if tag.ID() != vlanID {
+		t.Errorf("got VLAN ID = %d, want %d", tag.ID(), vlanID)
+	}
+	if tag.Type() != ipv4.ProtocolNumber {
+		t.Errorf("got encapsulated EtherType = %#x, want %#x", tag.Type(), ipv4.ProtocolNumber)
+	}
+	if payload := got[header.EthernetMinimumSize+header.VLANTagSize:]; !bytes.Equal(payload, data) {
+		t.Errorf("got payload = %x, want %x", payload, data)
+	}
+}
+
+func TestDeliverPacket(t *testing.T) {
+	s, ch := newTestStack(t)
+
+	var sink packetSink
+	if err := s.RegisterPacketEndpoint(vlanNICID, ipv4.ProtocolNumber, &sink); err != nil {
+		t.Fatalf("s.RegisterPacketEndpoint(%d, _, _): %s", vlanNICID, err)
+	}
+	defer func() {
+		for _, pkt := range sink.pkts {
+			pkt.DecRef()
+		}
+	}()
+
+	data := []byte{1, 2, 3, 4}
+	for _, id := range []uint16{vlanID, vlanID + 1} {
+		frame := make([]byte, header.EthernetMinimumSize+header.VLANTagSize)
+		header.Ethernet(frame).Encode(&header.EthernetFields{
+			SrcAddr: remoteLinkAddr,
+			DstAddr: localLinkAddr,
+			Type:    header.VLANProtocol8021Q,
+		})
+		header.VLAN(frame[header.EthernetMinimumSize:]).Encode(&header.VLANFields{
+			TCI:  id,
+			Type: ipv4.ProtocolNumber,
+		})
+		frame = append(frame, data...)
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(frame),
+		})
+		ch.InjectInbound(header.VLANProtocol8021Q, pkt)
+		pkt.DecRef()
+	}
+
+	// Only the frame with the VLAN ID of the device is delivered.
+	if got := len(sink.pkts); got != 1 {
+		t.Fatalf("got %d packets delivered to the VLAN NIC, want 1", got)
+	}
+	pkt := sink.pkts[0]
+	eth := header.Ethernet(pkt.LinkHeader().Slice())
+	if eth.Type() != ipv4.ProtocolNumber {
+		t.Errorf("got EtherType = %#x, want %#x", eth.Type(), ipv4.ProtocolNumber)
+	}
+	buf := pkt.Data().ToBuffer()
+	defer buf.Release()
+	if got := buf.Flatten(); !bytes.Equal(got, data) {
+		t.Errorf("got payload = %x, want %x", got, data)
+	}
+}
+
+func TestMain(m *testing.M) {
+	refs.SetLeakMode(refs.LeaksPanic)
+	code := m.Run()
+	refs.DoLeakCheck()
+	os.Exit(code)
+}
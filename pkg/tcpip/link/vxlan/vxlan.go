@@ -0,0 +1,624 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vxlan provides the implementation of VXLAN devices as described in
+// RFC 7348.
+//
+// A VXLAN device carries whole Ethernet frames and must be wrapped by an
+// ethernet.Endpoint. Frames are encapsulated in UDP datagrams that are sent
+// through a UDP endpoint of the same stack, so they are routed like any other
+// traffic of the stack. All VXLAN devices that use the same local address and
+// port share a single UDP endpoint and are demultiplexed by their VXLAN
+// network identifier (VNI).
+//
+// Like Linux, a VXLAN device maintains a forwarding database (FDB) which maps
+// the link addresses of remote hosts to the addresses of the VXLAN tunnel end
+// points behind which they are. The FDB is populated by learning from
+// received frames. Frames to unknown, broadcast and multicast link addresses
+// are sent to the default remote, which is usually a multicast group.
+package vxlan
+
+import (
+	"bytes"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const (
+	// Overhead is the number of bytes added by the encapsulation of a frame
+	// over IPv4: the outer IPv4, UDP and VXLAN headers and the inner Ethernet
+	// header.
+	Overhead = header.IPv4MinimumSize + header.UDPMinimumSize + header.VXLANHeaderSize + header.EthernetMinimumSize
+
+	// OverheadIPv6 is the encapsulation overhead when the outer network
+	// protocol is IPv6.
+	OverheadIPv6 = header.IPv6MinimumSize + header.UDPMinimumSize + header.VXLANHeaderSize + header.EthernetMinimumSize
+
+	// DefaultAgeing is the default time after which learned FDB entries
+	// expire.
+	DefaultAgeing = 300 * time.Second
+)
+
+var _ stack.LinkEndpoint = (*Endpoint)(nil)
+
+// Options specify the configuration of a VXLAN device.
+type Options struct {
+	// VNI is the VXLAN network identifier of the device.
+	VNI uint32
+
+	// Remote is the address of the default remote tunnel end point. If it is
+	// a multicast address, the group is joined on NIC. If it is empty, frames
+	// to destinations that are not in the FDB are dropped.
+	Remote tcpip.Address
+
+	// Local is the source address of encapsulated packets. If it is empty,
+	// the source address is selected by the stack.
+	Local tcpip.Address
+
+	// Port is the UDP destination port. If it is zero, header.VXLANPort is
+	// used.
+	Port uint16
+
+	// NIC is the NIC through which encapsulated packets are sent. If it is
+	// zero, packets are routed by the stack.
+	NIC tcpip.NICID
+
+	// TTL is the TTL or hop limit of encapsulated packets. If it is zero, the
+	// default TTL of the stack is used.
+	TTL uint8
+
+	// Learning enables learning of remote link addresses from received
+	// frames.
+	Learning bool
+
+	// Ageing is the time after which learned FDB entries expire. If it is
+	// zero, DefaultAgeing is used. If it is negative, entries never expire.
+	Ageing time.Duration
+
+	// MTU is the MTU of the device. If it is zero, the MTU is derived from the
+	// MTU of NIC.
+	MTU uint32
+
+	// LinkAddress is the link address of the device.
+	LinkAddress tcpip.LinkAddress
+}
+
+// fdbEntry is an entry of the forwarding database.
+type fdbEntry struct {
+	remote  tcpip.Address
+	static  bool
+	updated tcpip.MonotonicTime
+}
+
+// Endpoint is a link endpoint of a VXLAN device.
+type Endpoint struct {
+	stack    *stack.Stack
+	vni      uint32
+	remote   tcpip.Address
+	port     uint16
+	netProto tcpip.NetworkProtocolNumber
+	addrLen  int
+	ttl      uint8
+	learning bool
+	ageing   time.Duration
+	sock     *socket
+
+	mu endpointRWMutex
+	// +checklocks:mu
+	dispatcher stack.NetworkDispatcher
+	// +checklocks:mu
+	linkAddr tcpip.LinkAddress
+	// +checklocks:mu
+	mtu uint32
+	// +checklocks:mu
+	fdb map[tcpip.LinkAddress]fdbEntry
+	// +checklocks:mu
+	closed bool
+	// +checklocks:mu
+	onCloseAction func()
+}
+
+// New creates a VXLAN endpoint on s. s must support UDP.
+func New(s *stack.Stack, opts Options) (*Endpoint, tcpip.Error) {
+	if opts.VNI > header.VXLANMaxVNI {
+		return nil, &tcpip.ErrInvalidOptionValue{}
+	}
+	netProto, addrLen := ipv4.ProtocolNumber, header.IPv4AddressSize
+	if opts.Remote.Len() == header.IPv6AddressSize || opts.Local.Len() == header.IPv6AddressSize {
+		netProto, addrLen = ipv6.ProtocolNumber, header.IPv6AddressSize
+	}
+	if (opts.Remote.Len() != 0 && opts.Remote.Len() != addrLen) ||
+		(opts.Local.Len() != 0 && opts.Local.Len() != addrLen) {
+		return nil, &tcpip.ErrInvalidOptionValue{}
+	}
+	if opts.Port == 0 {
+		opts.Port = header.VXLANPort
+	}
+	switch {
+	case opts.Ageing == 0:
+		opts.Ageing = DefaultAgeing
+	case opts.Ageing < 0:
+		opts.Ageing = 0
+	}
+	if opts.MTU == 0 {
+		overhead := uint32(Overhead)
+		if netProto == ipv6.ProtocolNumber {
+			overhead = OverheadIPv6
+		}
+		opts.MTU = 1500 - overhead
+		if info, ok := s.NICInfo()[opts.NIC]; ok && info.MTU > overhead {
+			opts.MTU = info.MTU - overhead
+		}
+	}
+
+	e := &Endpoint{
+		stack:    s,
+		vni:      opts.VNI,
+		remote:   opts.Remote,
+		port:     opts.Port,
+		netProto: netProto,
+		addrLen:  addrLen,
+		ttl:      opts.TTL,
+		learning: opts.Learning,
+		ageing:   opts.Ageing,
+		linkAddr: opts.LinkAddress,
+		mtu:      opts.MTU,
+		fdb:      make(map[tcpip.LinkAddress]fdbEntry),
+	}
+	sock, err := getSocket(socketKey{
+		stack:    s,
+		netProto: netProto,
+		local:    opts.Local,
+		port:     opts.Port,
+		nic:      opts.NIC,
+	}, e)
+	if err != nil {
+		return nil, err
+	}
+	e.sock = sock
+	return e, nil
+}
+
+// VNI returns the VXLAN network identifier of the endpoint.
+func (e *Endpoint) VNI() uint32 {
+	return e.vni
+}
+
+// Remote returns the default remote tunnel end point of the endpoint.
+func (e *Endpoint) Remote() tcpip.Address {
+	return e.remote
+}
+
+// Port returns the UDP port of the endpoint.
+func (e *Endpoint) Port() uint16 {
+	return e.port
+}
+
+// AddFDBEntry adds a static FDB entry that maps linkAddr to the tunnel end
+// point remote.
+func (e *Endpoint) AddFDBEntry(linkAddr tcpip.LinkAddress, remote tcpip.Address) tcpip.Error {
+	if remote.Len() != e.addrLen {
+		return &tcpip.ErrBadAddress{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fdb[linkAddr] = fdbEntry{remote: remote, static: true}
+	return nil
+}
+
+// RemoveFDBEntry removes the FDB entry of linkAddr.
+func (e *Endpoint) RemoveFDBEntry(linkAddr tcpip.LinkAddress) tcpip.Error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.fdb[linkAddr]; !ok {
+		return &tcpip.ErrBadAddress{}
+	}
+	delete(e.fdb, linkAddr)
+	return nil
+}
+
+// FDB returns the unexpired entries of the forwarding database, keyed by link
+// address.
+func (e *Endpoint) FDB() map[tcpip.LinkAddress]tcpip.Address {
+	now := e.stack.Clock().NowMonotonic()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	fdb := make(map[tcpip.LinkAddress]tcpip.Address, len(e.fdb))
+	for linkAddr, ent := range e.fdb {
+		if !e.expiredLocked(ent, now) {
+			fdb[linkAddr] = ent.remote
+		}
+	}
+	return fdb
+}
+
+// +checklocksread:e.mu
+func (e *Endpoint) expiredLocked(ent fdbEntry, now tcpip.MonotonicTime) bool {
+	return !ent.static && e.ageing != 0 && now.Sub(ent.updated) > e.ageing
+}
+
+// lookup returns the tunnel end point for frames to dst.
+func (e *Endpoint) lookup(dst tcpip.LinkAddress) tcpip.Address {
+	if header.IsMulticastEthernetAddress(dst) {
+		return e.remote
+	}
+	now := e.stack.Clock().NowMonotonic()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ent, ok := e.fdb[dst]
+	if !ok {
+		return e.remote
+	}
+	if e.expiredLocked(ent, now) {
+		delete(e.fdb, dst)
+		return e.remote
+	}
+	return ent.remote
+}
+
+// deliver is called by the shared socket for frames received from the tunnel
+// end point remote.
+func (e *Endpoint) deliver(remote tcpip.Address, frame []byte) {
+	if len(frame) < header.EthernetMinimumSize {
+		return
+	}
+	eth := header.Ethernet(frame)
+
+	e.mu.Lock()
+	d := e.dispatcher
+	if d == nil || e.closed {
+		e.mu.Unlock()
+		return
+	}
+	if src := eth.SourceAddress(); e.learning && header.IsValidUnicastEthernetAddress(src) {
+		if ent, ok := e.fdb[src]; !ok || !ent.static {
+			e.fdb[src] = fdbEntry{
+				remote:  remote,
+				updated: e.stack.Clock().NowMonotonic(),
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: buffer.MakeWithData(frame),
+	})
+	defer pkt.DecRef()
+	d.DeliverNetworkPacket(eth.Type(), pkt)
+}
+
+// WritePackets implements stack.LinkEndpoint.WritePackets. Frames are
+// encapsulated and sent to the tunnel end point behind which their
+// destination is.
+func (e *Endpoint) WritePackets(pkts stack.PacketBufferList) (int, tcpip.Error) {
+	e.mu.RLock()
+	closed := e.closed
+	e.mu.RUnlock()
+	if closed {
+		return 0, &tcpip.ErrClosedForSend{}
+	}
+
+	n := 0
+	for _, pkt := range pkts.AsSlice() {
+		frame := pkt.ToBuffer()
+		if frame.Size() < header.EthernetMinimumSize {
+			frame.Release()
+			return n, &tcpip.ErrMalformedHeader{}
+		}
+		b := make([]byte, header.VXLANHeaderSize+int(frame.Size()))
+		header.VXLAN(b).Encode(e.vni)
+		frame.ReadAt(b[header.VXLANHeaderSize:], 0)
+		frame.Release()
+
+		remote := e.lookup(header.Ethernet(b[header.VXLANHeaderSize:]).DestinationAddress())
+		if remote.Len() == 0 {
+			// There is nowhere to send the frame; drop it silently like
+			// Linux does.
+			n++
+			continue
+		}
+		if err := e.sock.write(remote, e.port, e.ttl, b); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Attach implements stack.LinkEndpoint.Attach.
+func (e *Endpoint) Attach(dispatcher stack.NetworkDispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dispatcher = dispatcher
+}
+
+// IsAttached implements stack.LinkEndpoint.IsAttached.
+func (e *Endpoint) IsAttached() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.dispatcher != nil
+}
+
+// MTU implements stack.LinkEndpoint.MTU.
+func (e *Endpoint) MTU() uint32 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.mtu
+}
+
+// SetMTU implements stack.LinkEndpoint.SetMTU.
+func (e *Endpoint) SetMTU(mtu uint32) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.mtu = mtu
+}
+
+// Capabilities implements stack.LinkEndpoint.Capabilities.
+func (*Endpoint) Capabilities() stack.LinkEndpointCapabilities {
+	return 0
+}
+
+// MaxHeaderLength implements stack.LinkEndpoint.MaxHeaderLength. The VXLAN
+// header is inserted into a new buffer, so no space has to be reserved for
+// it.
+func (*Endpoint) MaxHeaderLength() uint16 {
+	return 0
+}
+
+// LinkAddress implements stack.LinkEndpoint.LinkAddress.
+func (e *Endpoint) LinkAddress() tcpip.LinkAddress {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.linkAddr
+}
+
+// SetLinkAddress implements stack.LinkEndpoint.SetLinkAddress.
+func (e *Endpoint) SetLinkAddress(addr tcpip.LinkAddress) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.linkAddr = addr
+}
+
+// Wait implements stack.LinkEndpoint.Wait.
+func (*Endpoint) Wait() {}
+
+// ARPHardwareType implements stack.LinkEndpoint.ARPHardwareType.
+func (*Endpoint) ARPHardwareType() header.ARPHardwareType {
+	return header.ARPHardwareNone
+}
+
+// AddHeader implements stack.LinkEndpoint.AddHeader.
+func (*Endpoint) AddHeader(*stack.PacketBuffer) {}
+
+// ParseHeader implements stack.LinkEndpoint.ParseHeader.
+func (*Endpoint) ParseHeader(*stack.PacketBuffer) bool { return true }
+
+// Close implements stack.LinkEndpoint.Close.
+func (e *Endpoint) Close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	action := e.onCloseAction
+	e.onCloseAction = nil
+	e.mu.Unlock()
+
+	e.sock.release(e)
+	if action != nil {
+		action()
+	}
+}
+
+// SetOnCloseAction implements stack.LinkEndpoint.SetOnCloseAction.
+func (e *Endpoint) SetOnCloseAction(action func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onCloseAction = action
+}
+
+// socketKey identifies a UDP endpoint that is shared by VXLAN devices.
+type socketKey struct {
+	stack    *stack.Stack
+	netProto tcpip.NetworkProtocolNumber
+	local    tcpip.Address
+	port     uint16
+	nic      tcpip.NICID
+}
+
+// socket is a UDP endpoint that is shared by VXLAN devices.
+type socket struct {
+	key  socketKey
+	ep   tcpip.Endpoint
+	wq   waiter.Queue
+	done chan struct{}
+
+	// The following fields are protected by socketsMu.
+
+	// +checklocks:socketsMu
+	refs int
+	// +checklocks:socketsMu
+	endpoints map[uint32]*Endpoint
+	// +checklocks:socketsMu
+	groups map[tcpip.Address]int
+}
+
+var (
+	socketsMu socketsMutex
+
+	// +checklocks:socketsMu
+	sockets = make(map[socketKey]*socket)
+)
+
+// getSocket returns the shared socket for key and registers e with it.
+func getSocket(key socketKey, e *Endpoint) (*socket, tcpip.Error) {
+	socketsMu.Lock()
+	defer socketsMu.Unlock()
+	s, ok := sockets[key]
+	if !ok {
+		var err tcpip.Error
+		if s, err = newSocket(key); err != nil {
+			return nil, err
+		}
+	} else if _, ok := s.endpoints[e.vni]; ok {
+		return nil, &tcpip.ErrPortInUse{}
+	}
+	if header.IsV4MulticastAddress(e.remote) || header.IsV6MulticastAddress(e.remote) {
+		if s.groups[e.remote] == 0 {
+			if err := s.ep.SetSockOpt(&tcpip.AddMembershipOption{
+				NIC:           key.nic,
+				MulticastAddr: e.remote,
+			}); err != nil {
+				if !ok {
+					s.ep.Close()
+				}
+				return nil, err
+			}
+		}
+		s.groups[e.remote]++
+	}
+	if !ok {
+		sockets[key] = s
+		go s.loop() // S/R-SAFE: VXLAN devices are not saved.
+	}
+	s.refs++
+	s.endpoints[e.vni] = e
+	return s, nil
+}
+
+// newSocket creates and binds a UDP endpoint for key.
+//
+// +checklocks:socketsMu
+func newSocket(key socketKey) (*socket, tcpip.Error) {
+	s := &socket{
+		key:       key,
+		done:      make(chan struct{}),
+		endpoints: make(map[uint32]*Endpoint),
+		groups:    make(map[tcpip.Address]int),
+	}
+	ep, err := key.stack.NewEndpoint(udp.ProtocolNumber, key.netProto, &s.wq)
+	if err != nil {
+		return nil, err
+	}
+	if key.nic != 0 {
+		if err := ep.SocketOptions().SetBindToDevice(int32(key.nic)); err != nil {
+			ep.Close()
+			return nil, err
+		}
+		if err := ep.SetSockOpt(&tcpip.MulticastInterfaceOption{NIC: key.nic}); err != nil {
+			ep.Close()
+			return nil, err
+		}
+	}
+	ep.SocketOptions().SetMulticastLoop(false)
+	if err := ep.Bind(tcpip.FullAddress{Addr: key.local, Port: key.port}); err != nil {
+		ep.Close()
+		return nil, err
+	}
+	s.ep = ep
+	return s, nil
+}
+
+// release unregisters e from s and closes s if it is no longer used.
+func (s *socket) release(e *Endpoint) {
+	socketsMu.Lock()
+	delete(s.endpoints, e.vni)
+	if n, ok := s.groups[e.remote]; ok {
+		if n == 1 {
+			delete(s.groups, e.remote)
+			s.ep.SetSockOpt(&tcpip.RemoveMembershipOption{
+				NIC:           s.key.nic,
+				MulticastAddr: e.remote,
+			})
+		} else {
+			s.groups[e.remote] = n - 1
+		}
+	}
+	s.refs--
+	last := s.refs == 0
+	if last {
+		delete(sockets, s.key)
+	}
+	socketsMu.Unlock()
+
+	if last {
+		s.ep.Close()
+		<-s.done
+	}
+}
+
+// write sends an encapsulated frame to the tunnel end point remote.
+func (s *socket) write(remote tcpip.Address, port uint16, ttl uint8, b []byte) tcpip.Error {
+	opts := tcpip.WriteOptions{
+		To: &tcpip.FullAddress{Addr: remote, Port: port},
+	}
+	if ttl != 0 {
+		if s.key.netProto == ipv6.ProtocolNumber {
+			opts.ControlMessages.HasHopLimit = true
+			opts.ControlMessages.HopLimit = ttl
+		} else {
+			opts.ControlMessages.HasTTL = true
+			opts.ControlMessages.TTL = ttl
+		}
+	}
+	_, err := s.ep.Write(bytes.NewReader(b), opts)
+	return err
+}
+
+// loop receives encapsulated frames until the UDP endpoint is closed.
+func (s *socket) loop() {
+	defer close(s.done)
+	waitEntry, notifyCh := waiter.NewChannelEntry(waiter.ReadableEvents)
+	s.wq.EventRegister(&waitEntry)
+	defer s.wq.EventUnregister(&waitEntry)
+	for {
+		var b bytes.Buffer
+		res, err := s.ep.Read(&b, tcpip.ReadOptions{NeedRemoteAddr: true})
+		switch err.(type) {
+		case nil:
+			s.handle(res.RemoteAddr.Addr, b.Bytes())
+		case *tcpip.ErrWouldBlock:
+			<-notifyCh
+		default:
+			return
+		}
+	}
+}
+
+// handle demultiplexes a received VXLAN packet to the device of its VNI.
+func (s *socket) handle(remote tcpip.Address, b []byte) {
+	if len(b) < header.VXLANHeaderSize {
+		return
+	}
+	h := header.VXLAN(b)
+	if h.Flags()&header.VXLANFlagVNI == 0 {
+		return
+	}
+	socketsMu.Lock()
+	e, ok := s.endpoints[h.VNI()]
+	socketsMu.Unlock()
+	if !ok {
+		return
+	}
+	e.deliver(remote, b[header.VXLANHeaderSize:])
+}
@@ -0,0 +1,272 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vxlan_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/refs"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/link/vxlan"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+)
+
+const (
+	nicID = 1
+	vni   = 42
+
+	localLinkAddr  = tcpip.LinkAddress("\x02\x02\x03\x04\x05\x06")
+	remoteLinkAddr = tcpip.LinkAddress("\x02\x02\x03\x04\x05\x07")
+)
+
+var (
+	localAddr  = tcpip.AddrFrom4([4]byte{192, 168, 1, 1})
+	remoteAddr = tcpip.AddrFrom4([4]byte{192, 168, 1, 2})
+	peerAddr   = tcpip.AddrFrom4([4]byte{192, 168, 1, 3})
+)
+
+// frameSink is a stack.NetworkDispatcher that forwards delivered frames to a
+// channel.
+type frameSink chan []byte
+
+func (s frameSink) DeliverNetworkPacket(_ tcpip.NetworkProtocolNumber, pkt *stack.PacketBuffer) {
+	buf := pkt.ToBuffer()
+	defer buf.Release()
+	s <- buf.Flatten()
+}
+
+func (frameSink) DeliverLinkPacket(tcpip.NetworkProtocolNumber, *stack.PacketBuffer) {}
+
+func newTestStack(t *testing.T) (*stack.Stack, *channel.Endpoint) {
+	t.Helper()
+
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{udp.NewProtocol},
+	})
+	t.Cleanup(s.Destroy)
+	ch := channel.New(4, 1500, "")
+	if err := s.CreateNIC(nicID, ch); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+	protoAddr := tcpip.ProtocolAddress{
+		Protocol:          ipv4.ProtocolNumber,
+		AddressWithPrefix: localAddr.WithPrefix(),
+	}
+	if err := s.AddProtocolAddress(nicID, protoAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", nicID, protoAddr, err)
+	}
+	s.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: nicID}})
+	return s, ch
+}
+
+func newTestEndpoint(t *testing.T, s *stack.Stack, vni uint32) (*vxlan.Endpoint, tcpip.Error) {
+	t.Helper()
+
+	ep, err := vxlan.New(s, vxlan.Options{
+		VNI:         vni,
+		Remote:      remoteAddr,
+		NIC:         nicID,
+		Learning:    true,
+		LinkAddress: localLinkAddr,
+	})
+	if err == nil {
+		t.Cleanup(ep.Close)
+	}
+	return ep, err
+}
+
+func makeFrame(src, dst tcpip.LinkAddress, data []byte) []byte {
+	frame := make([]byte, header.EthernetMinimumSize)
+	header.Ethernet(frame).Encode(&header.EthernetFields{
+		SrcAddr: src,
+		DstAddr: dst,
+		Type:    ipv4.ProtocolNumber,
+	})
+	return append(frame, data...)
+}
+
+func writeFrame(t *testing.T, ep *vxlan.Endpoint, frame []byte) {
+	t.Helper()
+
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: buffer.MakeWithData(frame),
+	})
+	defer pkt.DecRef()
+	var pkts stack.PacketBufferList
+	pkts.PushBack(pkt)
+	if n, err := ep.WritePackets(pkts); err != nil || n != 1 {
+		t.Fatalf("ep.WritePackets(_) = (%d, %v), want (1, nil)", n, err)
+	}
+}
+
+// readEncapsulated reads a packet written by the stack and returns its
+// destination address, UDP destination port and UDP payload.
+func readEncapsulated(t *testing.T, ch *channel.Endpoint) (tcpip.Address, uint16, []byte) {
+	t.Helper()
+
+	pkt := ch.Read()
+	if pkt == nil {
+		t.Fatalf("no packet was written to the underlying NIC")
+	}
+	defer pkt.DecRef()
+	buf := pkt.ToBuffer()
+	defer buf.Release()
+	ip := header.IPv4(buf.Flatten())
+	if !ip.IsValid(len(ip)) {
+		t.Fatalf("got invalid IPv4 packet %x", []byte(ip))
+	}
+	if got := ip.TransportProtocol(); got != udp.ProtocolNumber {
+		t.Fatalf("got transport protocol = %d, want %d", got, udp.ProtocolNumber)
+	}
+	u := header.UDP(ip.Payload())
+	return ip.DestinationAddress(), u.DestinationPort(), u.Payload()
+}
+
+func TestEncapsulate(t *testing.T) {
+	s, ch := newTestStack(t)
+	ep, err := newTestEndpoint(t, s, vni)
+	if err != nil {
+		t.Fatalf("vxlan.New(_, _): %s", err)
+	}
+
+	data := []byte{1, 2, 3, 4}
+	frame := makeFrame(localLinkAddr, remoteLinkAddr, data)
+	writeFrame(t, ep, frame)
+
+	dst, port, payload := readEncapsulated(t, ch)
+	if dst != remoteAddr {
+		t.Errorf("got destination = %s, want %s", dst, remoteAddr)
+	}
+	if port != header.VXLANPort {
+		t.Errorf("got destination port = %d, want %d", port, header.VXLANPort)
+	}
+	if len(payload) < header.VXLANHeaderSize {
+		t.Fatalf("got UDP payload of %d bytes, want at least %d bytes", len(payload), header.VXLANHeaderSize)
+	}
+	h := header.VXLAN(payload)
+	if h.Flags() != header.VXLANFlagVNI {
+		t.Errorf("got flags = %#x, want %#x", h.Flags(), header.VXLANFlagVNI)
+	}
+	if h.VNI() != vni {
+		t.Errorf("got VNI = %d, want %d", h.VNI(), vni)
+	}
+	if got := payload[header.VXLANHeaderSize:]; !bytes.Equal(got, frame) {
+		t.Errorf("got encapsulated frame = %x, want %x", got, frame)
+	}
+}
+
+func TestDecapsulateAndLearn(t *testing.T) {
+	s, ch := newTestStack(t)
+	ep, err := newTestEndpoint(t, s, vni)
+	if err != nil {
+		t.Fatalf("vxlan.New(_, _): %s", err)
+	}
+	sink := make(frameSink, 2)
+	ep.Attach(sink)
+
+	data := []byte{1, 2, 3, 4}
+	for _, id := range []uint32{vni + 1, vni} {
+		frame := makeFrame(remoteLinkAddr, localLinkAddr, data)
+		b := make([]byte, header.IPv4MinimumSize+header.UDPMinimumSize+header.VXLANHeaderSize+len(frame))
+		ip := header.IPv4(b)
+		ip.Encode(&header.IPv4Fields{
+			TotalLength: uint16(len(b)),
+			TTL:         64,
+			Protocol:    uint8(udp.ProtocolNumber),
+			SrcAddr:     peerAddr,
+			DstAddr:     localAddr,
+		})
+		ip.SetChecksum(^ip.CalculateChecksum())
+		header.UDP(ip.Payload()).Encode(&header.UDPFields{
+			SrcPort: 1234,
+			DstPort: header.VXLANPort,
+			Length:  uint16(len(b) - header.IPv4MinimumSize),
+		})
+		vx := b[header.IPv4MinimumSize+header.UDPMinimumSize:]
+		header.VXLAN(vx).Encode(id)
+		copy(vx[header.VXLANHeaderSize:], frame)
+
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(b),
+		})
+		ch.InjectInbound(ipv4.ProtocolNumber, pkt)
+		pkt.DecRef()
+	}
+
+	// Only the frame with the VNI of the device is delivered.
+	select {
+	case got := <-sink:
+		if want := makeFrame(remoteLinkAddr, localLinkAddr, data); !bytes.Equal(got, want) {
+			t.Errorf("got delivered frame = %x, want %x", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a frame to be delivered")
+	}
+	select {
+	case got := <-sink:
+		t.Fatalf("got unexpected frame %x", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The source of the frame was learned.
+	if got := ep.FDB()[remoteLinkAddr]; got != peerAddr {
+		t.Errorf("got FDB entry for %s = %s, want %s", remoteLinkAddr, got, peerAddr)
+	}
+	writeFrame(t, ep, makeFrame(localLinkAddr, remoteLinkAddr, data))
+	if dst, _, _ := readEncapsulated(t, ch); dst != peerAddr {
+		t.Errorf("got destination = %s, want %s", dst, peerAddr)
+	}
+
+	// Broadcast frames are still sent to the default remote.
+	writeFrame(t, ep, makeFrame(localLinkAddr, header.EthernetBroadcastAddress, data))
+	if dst, _, _ := readEncapsulated(t, ch); dst != remoteAddr {
+		t.Errorf("got destination = %s, want %s", dst, remoteAddr)
+	}
+}
+
+func TestSharedSocket(t *testing.T) {
+	s, _ := newTestStack(t)
+	if _, err := newTestEndpoint(t, s, vni); err != nil {
+		t.Fatalf("vxlan.New(_, _): %s", err)
+	}
+
+	// Devices with different VNIs share the UDP port.
+	if _, err := newTestEndpoint(t, s, vni+1); err != nil {
+		t.Fatalf("vxlan.New(_, _) with VNI %d: %s", vni+1, err)
+	}
+
+	// A VNI can only be used by one device.
+	if _, err := newTestEndpoint(t, s, vni); err == nil {
+		t.Fatalf("vxlan.New(_, _) with duplicate VNI %d succeeded", vni)
+	} else if _, ok := err.(*tcpip.ErrPortInUse); !ok {
+		t.Fatalf("vxlan.New(_, _) with duplicate VNI %d: got %s, want %s", vni, err, &tcpip.ErrPortInUse{})
+	}
+}
+
+func TestMain(m *testing.M) {
+	refs.SetLeakMode(refs.LeaksPanic)
+	code := m.Run()
+	refs.DoLeakCheck()
+	os.Exit(code)
+}
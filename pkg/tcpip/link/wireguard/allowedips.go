@@ -0,0 +1,84 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"sort"
+
+	"gvisor.dev/gvisor/pkg/tcpip"
+)
+
+// allowedIP assigns a subnet to a peer.
+type allowedIP struct {
+	subnet tcpip.Subnet
+	peer   *Peer
+}
+
+// allowedIPs is the cryptokey routing table of a device. It maps each subnet
+// to the peer that packets to addresses in the subnet are sent to, and from
+// which packets with source addresses in the subnet are accepted. A subnet is
+// assigned to at most one peer.
+type allowedIPs struct {
+	// entries is sorted by decreasing prefix length, so that the first match
+	// is the longest prefix match.
+	entries []allowedIP
+}
+
+// insert assigns subnet to peer, replacing any previous assignment.
+func (a *allowedIPs) insert(subnet tcpip.Subnet, peer *Peer) {
+	for i := range a.entries {
+		if a.entries[i].subnet == subnet {
+			a.entries[i].peer = peer
+			return
+		}
+	}
+	a.entries = append(a.entries, allowedIP{subnet: subnet, peer: peer})
+	sort.SliceStable(a.entries, func(i, j int) bool {
+		return a.entries[i].subnet.Prefix() > a.entries[j].subnet.Prefix()
+	})
+}
+
+// lookup returns the peer that addr is assigned to, or nil.
+func (a *allowedIPs) lookup(addr tcpip.Address) *Peer {
+	for _, e := range a.entries {
+		if e.subnet.Contains(addr) {
+			return e.peer
+		}
+	}
+	return nil
+}
+
+// removePeer removes all subnets assigned to peer.
+func (a *allowedIPs) removePeer(peer *Peer) {
+	entries := a.entries[:0]
+	for _, e := range a.entries {
+		if e.peer != peer {
+			entries = append(entries, e)
+		}
+	}
+	clear(a.entries[len(entries):])
+	a.entries = entries
+}
+
+// subnets returns the subnets assigned to peer.
+func (a *allowedIPs) subnets(peer *Peer) []tcpip.Subnet {
+	var subnets []tcpip.Subnet
+	for _, e := range a.entries {
+		if e.peer == peer {
+			subnets = append(subnets, e.subnet)
+		}
+	}
+	return subnets
+}
@@ -0,0 +1,501 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+	"gvisor.dev/gvisor/pkg/tcpip"
+)
+
+// This file implements the Noise_IKpsk2 handshake and the message formats of
+// the WireGuard protocol, as described in section 5 of the WireGuard paper.
+
+const (
+	noiseConstruction = "Noise_IKpsk2_25519_ChaChaPoly_BLAKE2s"
+	wgIdentifier      = "WireGuard v1 zx2c4 Jason@zx2c4.com"
+	labelMAC1         = "mac1----"
+	labelCookie       = "cookie--"
+)
+
+// Message types.
+const (
+	messageInitiationType  = 1
+	messageResponseType    = 2
+	messageCookieReplyType = 3
+	messageTransportType   = 4
+)
+
+// Message sizes.
+const (
+	messageInitiationSize      = 148
+	messageResponseSize        = 92
+	messageCookieReplySize     = 64
+	messageTransportHeaderSize = 16
+	messageTransportMinSize    = messageTransportHeaderSize + chacha20poly1305.Overhead
+
+	macSize       = 16
+	cookieSize    = 16
+	timestampSize = 12
+
+	// paddingMultiple is the multiple to which the plaintext of transport
+	// messages is padded.
+	paddingMultiple = 16
+)
+
+// Protocol limits, from section 6.1 of the WireGuard paper.
+const (
+	rekeyAfterMessages  = 1 << 60
+	rejectAfterMessages = 1<<64 - 1<<13 - 1
+	rekeyAfterTime      = 120 * time.Second
+	rejectAfterTime     = 180 * time.Second
+	rekeyAttemptTime    = 90 * time.Second
+	rekeyTimeout        = 5 * time.Second
+	keepaliveTimeout    = 10 * time.Second
+	cookieRefreshTime   = 120 * time.Second
+
+	// handshakeInitiationRate is the minimum interval between initiations
+	// from a peer that are consumed.
+	handshakeInitiationRate = time.Second / 50
+)
+
+var (
+	initialChainKey [blake2s.Size]byte
+	initialHash     [blake2s.Size]byte
+)
+
+func init() {
+	initialChainKey = blake2s.Sum256([]byte(noiseConstruction))
+	initialHash = mixHash(initialChainKey, []byte(wgIdentifier))
+}
+
+// mixHash returns HASH(h || data).
+func mixHash(h [blake2s.Size]byte, data []byte) [blake2s.Size]byte {
+	return hashOf(h[:], data)
+}
+
+// hashOf returns the BLAKE2s hash of the concatenation of parts.
+func hashOf(parts ...[]byte) [blake2s.Size]byte {
+	h, _ := blake2s.New256(nil)
+	for _, p := range parts {
+		h.Write(p)
+	}
+	var sum [blake2s.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// mac returns the keyed 128-bit BLAKE2s MAC of data.
+func mac(key []byte, data []byte) [macSize]byte {
+	h, _ := blake2s.New128(key)
+	h.Write(data)
+	var sum [macSize]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+func newBLAKE2s() hash.Hash {
+	h, _ := blake2s.New256(nil)
+	return h
+}
+
+// hmacOf returns HMAC-BLAKE2s(key, parts...).
+func hmacOf(key []byte, parts ...[]byte) [blake2s.Size]byte {
+	h := hmac.New(newBLAKE2s, key)
+	for _, p := range parts {
+		h.Write(p)
+	}
+	var sum [blake2s.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// kdf1 returns the first output of the HKDF of input keyed with key.
+func kdf1(key [blake2s.Size]byte, input []byte) [blake2s.Size]byte {
+	t0 := hmacOf(key[:], input)
+	return hmacOf(t0[:], []byte{1})
+}
+
+// kdf2 returns the first two outputs of the HKDF of input keyed with key.
+func kdf2(key [blake2s.Size]byte, input []byte) ([blake2s.Size]byte, [blake2s.Size]byte) {
+	t0 := hmacOf(key[:], input)
+	t1 := hmacOf(t0[:], []byte{1})
+	t2 := hmacOf(t0[:], t1[:], []byte{2})
+	return t1, t2
+}
+
+// kdf3 returns the first three outputs of the HKDF of input keyed with key.
+func kdf3(key [blake2s.Size]byte, input []byte) ([blake2s.Size]byte, [blake2s.Size]byte, [blake2s.Size]byte) {
+	t0 := hmacOf(key[:], input)
+	t1 := hmacOf(t0[:], []byte{1})
+	t2 := hmacOf(t0[:], t1[:], []byte{2})
+	t3 := hmacOf(t0[:], t2[:], []byte{3})
+	return t1, t2, t3
+}
+
+// newAEAD returns the ChaCha20-Poly1305 AEAD keyed with key.
+func newAEAD(key [chacha20poly1305.KeySize]byte) cipher.AEAD {
+	aead, err := chacha20poly1305.New(key[:])
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// counterNonce returns the AEAD nonce of the given counter.
+func counterNonce(counter uint64) []byte {
+	var nonce [chacha20poly1305.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], counter)
+	return nonce[:]
+}
+
+// seal appends the encryption of plaintext with a zero nonce to dst.
+func seal(dst []byte, key [chacha20poly1305.KeySize]byte, plaintext, ad []byte) []byte {
+	return newAEAD(key).Seal(dst, counterNonce(0), plaintext, ad)
+}
+
+// open decrypts ciphertext that was encrypted with a zero nonce.
+func open(key [chacha20poly1305.KeySize]byte, ciphertext, ad []byte) ([]byte, bool) {
+	plaintext, err := newAEAD(key).Open(nil, counterNonce(0), ciphertext, ad)
+	return plaintext, err == nil
+}
+
+// dh returns the Curve25519 shared secret of priv and pub. It fails if the
+// shared secret is zero.
+func dh(priv *ecdh.PrivateKey, pub []byte) ([KeySize]byte, bool) {
+	var secret [KeySize]byte
+	pk, err := ecdh.X25519().NewPublicKey(pub)
+	if err != nil {
+		return secret, false
+	}
+	s, err := priv.ECDH(pk)
+	if err != nil {
+		return secret, false
+	}
+	copy(secret[:], s)
+	return secret, true
+}
+
+// tai64n returns the TAI64N timestamp of t. The sub-second part is rounded
+// down to about 16ms, so that timestamps don't reveal the precise time.
+func tai64n(t time.Time) [timestampSize]byte {
+	const (
+		base         = uint64(0x400000000000000a)
+		whitenerMask = uint32(0x1000000 - 1)
+	)
+	var ts [timestampSize]byte
+	binary.BigEndian.PutUint64(ts[:], base+uint64(t.Unix()))
+	binary.BigEndian.PutUint32(ts[8:], uint32(t.Nanosecond())&^whitenerMask)
+	return ts
+}
+
+// identity is the static key pair of a device.
+type identity struct {
+	priv *ecdh.PrivateKey
+	pub  Key
+
+	// mac1Key is HASH(LABEL_MAC1 || pub), the key of the MAC1 field of
+	// handshake messages sent to the device.
+	mac1Key [blake2s.Size]byte
+}
+
+// newIdentity returns the identity with the private key k.
+func newIdentity(k Key) *identity {
+	priv, err := ecdh.X25519().NewPrivateKey(k[:])
+	if err != nil {
+		panic(err)
+	}
+	id := &identity{priv: priv}
+	copy(id.pub[:], priv.PublicKey().Bytes())
+	id.mac1Key = hashOf([]byte(labelMAC1), id.pub[:])
+	return id
+}
+
+// handshakeState is the state of a handshake.
+type handshakeState int
+
+const (
+	handshakeZeroed handshakeState = iota
+	handshakeInitiationCreated
+	handshakeInitiationConsumed
+	handshakeResponseCreated
+	handshakeResponseConsumed
+)
+
+// handshake is the state of a handshake with a peer.
+type handshake struct {
+	state           handshakeState
+	hash            [blake2s.Size]byte
+	chainKey        [blake2s.Size]byte
+	localEphemeral  *ecdh.PrivateKey
+	remoteEphemeral [KeySize]byte
+	localIndex      uint32
+	remoteIndex     uint32
+
+	// lastTimestamp is the greatest timestamp of a consumed initiation.
+	lastTimestamp [timestampSize]byte
+
+	// lastInitiationConsumed is when the last initiation was consumed.
+	lastInitiationConsumed tcpip.MonotonicTime
+}
+
+// clear resets the handshake, except for the state that protects against
+// replayed initiations.
+func (hs *handshake) clear() {
+	*hs = handshake{
+		lastTimestamp:          hs.lastTimestamp,
+		lastInitiationConsumed: hs.lastInitiationConsumed,
+	}
+}
+
+// createInitiation returns a handshake initiation message to the peer with
+// the static public key remote. The MAC fields are not set.
+func (hs *handshake) createInitiation(rng io.Reader, local *identity, remote Key, staticStatic [KeySize]byte, now time.Time) ([]byte, bool) {
+	eph, err := ecdh.X25519().GenerateKey(rng)
+	if err != nil {
+		return nil, false
+	}
+	ephPub := eph.PublicKey().Bytes()
+	ss, ok := dh(eph, remote[:])
+	if !ok {
+		return nil, false
+	}
+
+	msg := make([]byte, messageInitiationSize)
+	msg[0] = messageInitiationType
+	binary.LittleEndian.PutUint32(msg[4:], hs.localIndex)
+	copy(msg[8:40], ephPub)
+
+	hs.localEphemeral = eph
+	hs.hash = mixHash(initialHash, remote[:])
+	hs.chainKey = kdf1(initialChainKey, ephPub)
+	hs.hash = mixHash(hs.hash, ephPub)
+	var key [blake2s.Size]byte
+	hs.chainKey, key = kdf2(hs.chainKey, ss[:])
+	seal(msg[40:40], key, local.pub[:], hs.hash[:])
+	hs.hash = mixHash(hs.hash, msg[40:88])
+	hs.chainKey, key = kdf2(hs.chainKey, staticStatic[:])
+	ts := tai64n(now)
+	seal(msg[88:88], key, ts[:], hs.hash[:])
+	hs.hash = mixHash(hs.hash, msg[88:116])
+	hs.state = handshakeInitiationCreated
+	return msg, true
+}
+
+// initiationStatic is the result of the first stage of consuming an
+// initiation, which doesn't depend on the initiating peer.
+type initiationStatic struct {
+	hash     [blake2s.Size]byte
+	chainKey [blake2s.Size]byte
+	remote   Key
+}
+
+// decryptInitiationStatic decrypts the static public key of the initiator of
+// the initiation msg.
+func decryptInitiationStatic(local *identity, msg []byte) (initiationStatic, bool) {
+	var is initiationStatic
+	ephPub := msg[8:40]
+	ss, ok := dh(local.priv, ephPub)
+	if !ok {
+		return is, false
+	}
+	is.hash = mixHash(initialHash, local.pub[:])
+	is.chainKey = kdf1(initialChainKey, ephPub)
+	is.hash = mixHash(is.hash, ephPub)
+	var key [blake2s.Size]byte
+	is.chainKey, key = kdf2(is.chainKey, ss[:])
+	static, ok := open(key, msg[40:88], is.hash[:])
+	if !ok {
+		return is, false
+	}
+	is.hash = mixHash(is.hash, msg[40:88])
+	copy(is.remote[:], static)
+	return is, true
+}
+
+// consumeInitiation finishes consuming the initiation msg from the peer with
+// the precomputed static-static secret staticStatic.
+func (hs *handshake) consumeInitiation(is initiationStatic, msg []byte, staticStatic [KeySize]byte, now tcpip.MonotonicTime) bool {
+	chainKey, key := kdf2(is.chainKey, staticStatic[:])
+	ts, ok := open(key, msg[88:116], is.hash[:])
+	if !ok {
+		return false
+	}
+
+	// Reject replayed initiations and initiation floods.
+	if bytes.Compare(ts, hs.lastTimestamp[:]) <= 0 {
+		return false
+	}
+	if hs.lastInitiationConsumed != (tcpip.MonotonicTime{}) && now.Sub(hs.lastInitiationConsumed) < handshakeInitiationRate {
+		return false
+	}
+
+	hs.clear()
+	hs.hash = mixHash(is.hash, msg[88:116])
+	hs.chainKey = chainKey
+	copy(hs.remoteEphemeral[:], msg[8:40])
+	hs.remoteIndex = binary.LittleEndian.Uint32(msg[4:])
+	copy(hs.lastTimestamp[:], ts)
+	hs.lastInitiationConsumed = now
+	hs.state = handshakeInitiationConsumed
+	return true
+}
+
+// createResponse returns the response to a consumed initiation from the peer
+// with the static public key remote. The MAC fields are not set.
+func (hs *handshake) createResponse(rng io.Reader, remote, psk Key) ([]byte, bool) {
+	eph, err := ecdh.X25519().GenerateKey(rng)
+	if err != nil {
+		return nil, false
+	}
+	ephPub := eph.PublicKey().Bytes()
+	ee, ok := dh(eph, hs.remoteEphemeral[:])
+	if !ok {
+		return nil, false
+	}
+	se, ok := dh(eph, remote[:])
+	if !ok {
+		return nil, false
+	}
+
+	msg := make([]byte, messageResponseSize)
+	msg[0] = messageResponseType
+	binary.LittleEndian.PutUint32(msg[4:], hs.localIndex)
+	binary.LittleEndian.PutUint32(msg[8:], hs.remoteIndex)
+	copy(msg[12:44], ephPub)
+
+	hs.localEphemeral = eph
+	hs.chainKey = kdf1(hs.chainKey, ephPub)
+	hs.hash = mixHash(hs.hash, ephPub)
+	hs.chainKey = kdf1(hs.chainKey, ee[:])
+	hs.chainKey = kdf1(hs.chainKey, se[:])
+	var tau, key [blake2s.Size]byte
+	hs.chainKey, tau, key = kdf3(hs.chainKey, psk[:])
+	hs.hash = mixHash(hs.hash, tau[:])
+	seal(msg[44:44], key, nil, hs.hash[:])
+	hs.hash = mixHash(hs.hash, msg[44:60])
+	hs.state = handshakeResponseCreated
+	return msg, true
+}
+
+// consumeResponse consumes the response msg to an initiation created by hs.
+func (hs *handshake) consumeResponse(local *identity, psk Key, msg []byte) bool {
+	ephPub := msg[12:44]
+	ee, ok := dh(hs.localEphemeral, ephPub)
+	if !ok {
+		return false
+	}
+	se, ok := dh(local.priv, ephPub)
+	if !ok {
+		return false
+	}
+
+	chainKey := kdf1(hs.chainKey, ephPub)
+	hash := mixHash(hs.hash, ephPub)
+	chainKey = kdf1(chainKey, ee[:])
+	chainKey = kdf1(chainKey, se[:])
+	chainKey, tau, key := kdf3(chainKey, psk[:])
+	hash = mixHash(hash, tau[:])
+	if _, ok := open(key, msg[44:60], hash[:]); !ok {
+		return false
+	}
+
+	hs.hash = mixHash(hash, msg[44:60])
+	hs.chainKey = chainKey
+	hs.remoteIndex = binary.LittleEndian.Uint32(msg[4:])
+	hs.state = handshakeResponseConsumed
+	return true
+}
+
+// deriveKeypair derives the transport keys of a completed handshake and
+// resets it.
+func (hs *handshake) deriveKeypair(initiator bool, now tcpip.MonotonicTime) *keypair {
+	send, recv := kdf2(hs.chainKey, nil)
+	if !initiator {
+		send, recv = recv, send
+	}
+	kp := &keypair{
+		send:        newAEAD(send),
+		recv:        newAEAD(recv),
+		created:     now,
+		initiator:   initiator,
+		localIndex:  hs.localIndex,
+		remoteIndex: hs.remoteIndex,
+	}
+	hs.clear()
+	return kp
+}
+
+// keypair holds the transport keys of a session with a peer.
+type keypair struct {
+	send        cipher.AEAD
+	recv        cipher.AEAD
+	sendCounter uint64
+	replay      replayFilter
+	created     tcpip.MonotonicTime
+	initiator   bool
+	localIndex  uint32
+	remoteIndex uint32
+}
+
+// encrypt returns a transport message that holds packet.
+func (kp *keypair) encrypt(packet []byte, mtu int) []byte {
+	// Pad the packet to a multiple of 16 bytes, without exceeding the MTU.
+	padded := (len(packet) + paddingMultiple - 1) / paddingMultiple * paddingMultiple
+	if padded > mtu {
+		padded = max(mtu, len(packet))
+	}
+	plaintext := make([]byte, padded)
+	copy(plaintext, packet)
+
+	counter := kp.sendCounter
+	kp.sendCounter++
+	msg := make([]byte, messageTransportHeaderSize, messageTransportHeaderSize+padded+chacha20poly1305.Overhead)
+	msg[0] = messageTransportType
+	binary.LittleEndian.PutUint32(msg[4:], kp.remoteIndex)
+	binary.LittleEndian.PutUint64(msg[8:], counter)
+	return kp.send.Seal(msg, counterNonce(counter), plaintext, nil)
+}
+
+// decrypt returns the plaintext of the transport message msg. Replayed
+// messages are rejected.
+func (kp *keypair) decrypt(msg []byte) ([]byte, bool) {
+	counter := binary.LittleEndian.Uint64(msg[8:])
+	plaintext, err := kp.recv.Open(nil, counterNonce(counter), msg[messageTransportHeaderSize:], nil)
+	if err != nil {
+		return nil, false
+	}
+	if !kp.replay.accept(counter) {
+		return nil, false
+	}
+	return plaintext, true
+}
+
+// checkMAC1 returns true if the MAC1 field of the handshake message msg is
+// valid for the receiver with the MAC1 key mac1Key.
+func checkMAC1(mac1Key [blake2s.Size]byte, msg []byte) bool {
+	off := len(msg) - 2*macSize
+	want := mac(mac1Key[:], msg[:off])
+	return hmac.Equal(msg[off:off+macSize], want[:])
+}
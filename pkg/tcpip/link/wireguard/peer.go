@@ -0,0 +1,591 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"encoding/binary"
+	"time"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+	"gvisor.dev/gvisor/pkg/tcpip"
+)
+
+const (
+	// maxStagedPackets is the maximum number of packets that are queued for
+	// a peer while a handshake is in progress.
+	maxStagedPackets = 128
+
+	// maxHandshakeAttempts is the number of initiations that are sent before
+	// a handshake is given up.
+	maxHandshakeAttempts = int(rekeyAttemptTime / rekeyTimeout)
+)
+
+// outgoing is a batch of messages to be sent to a peer. Messages are only sent
+// after the peer lock is released, since the UDP packets may be routed back
+// through the device.
+type outgoing struct {
+	to   tcpip.FullAddress
+	msgs [][]byte
+}
+
+// peerTimer is a timer of a peer. Timers can't be stopped reliably, so each
+// arming bumps a generation number and stale expirations are ignored.
+type peerTimer struct {
+	timer   tcpip.Timer
+	gen     uint64
+	pending bool
+}
+
+// Peer is a peer of a WireGuard device.
+type Peer struct {
+	ep  *Endpoint
+	pub Key
+
+	// mac1Key is HASH(LABEL_MAC1 || pub), the key of the MAC1 field of
+	// handshake messages sent to the peer.
+	mac1Key [blake2s.Size]byte
+
+	// cookieKey is HASH(LABEL_COOKIE || pub), the key of cookie replies
+	// from the peer.
+	cookieKey [blake2s.Size]byte
+
+	mu peerMutex
+	// local is the identity of the device. It is nil if the device has no
+	// private key.
+	//
+	// +checklocks:mu
+	local *identity
+	// staticStatic is the DH of the private key of the device and pub.
+	//
+	// +checklocks:mu
+	staticStatic [KeySize]byte
+	// +checklocks:mu
+	psk Key
+	// +checklocks:mu
+	endpoint tcpip.FullAddress
+	// +checklocks:mu
+	persistentKeepalive time.Duration
+	// +checklocks:mu
+	hs handshake
+	// +checklocks:mu
+	current *keypair
+	// +checklocks:mu
+	previous *keypair
+	// next is a keypair derived as responder which is only used for sending
+	// once the initiator has used it.
+	//
+	// +checklocks:mu
+	next *keypair
+	// staged holds packets that wait for a session to be established.
+	//
+	// +checklocks:mu
+	staged [][]byte
+	// +checklocks:mu
+	lastHandshake time.Time
+	// +checklocks:mu
+	lastInitiationSent tcpip.MonotonicTime
+	// +checklocks:mu
+	handshakeAttempts int
+	// +checklocks:mu
+	lastSentMAC1 [macSize]byte
+	// +checklocks:mu
+	cookie [cookieSize]byte
+	// +checklocks:mu
+	cookieSet tcpip.MonotonicTime
+	// +checklocks:mu
+	rxBytes uint64
+	// +checklocks:mu
+	txBytes uint64
+	// +checklocks:mu
+	removed bool
+
+	// Timers, see section 6 of the WireGuard paper.
+
+	// +checklocks:mu
+	retransmitHandshake peerTimer
+	// +checklocks:mu
+	sendKeepalive peerTimer
+	// +checklocks:mu
+	newHandshake peerTimer
+	// +checklocks:mu
+	persistentKeepaliveTimer peerTimer
+}
+
+func newPeer(e *Endpoint, pub Key) *Peer {
+	return &Peer{
+		ep:        e,
+		pub:       pub,
+		mac1Key:   hashOf([]byte(labelMAC1), pub[:]),
+		cookieKey: hashOf([]byte(labelCookie), pub[:]),
+	}
+}
+
+// setLocalLocked sets the identity of the device and resets the sessions
+// with the peer.
+//
+// +checklocks:p.mu
+func (p *Peer) setLocalLocked(local *identity) {
+	p.resetLocked()
+	p.local = nil
+	p.staticStatic = [KeySize]byte{}
+	if local == nil {
+		return
+	}
+	ss, ok := dh(local.priv, p.pub[:])
+	if !ok {
+		// The public key of the peer is invalid; handshakes with it
+		// are impossible.
+		return
+	}
+	p.local = local
+	p.staticStatic = ss
+}
+
+// resetLocked drops the sessions and the handshake with the peer.
+//
+// +checklocks:p.mu
+func (p *Peer) resetLocked() {
+	idx := &p.ep.index
+	for _, kp := range []*keypair{p.current, p.previous, p.next} {
+		if kp != nil {
+			idx.remove(kp.localIndex)
+		}
+	}
+	p.current, p.previous, p.next = nil, nil, nil
+	if p.hs.localIndex != 0 {
+		idx.remove(p.hs.localIndex)
+	}
+	p.hs.clear()
+	p.staged = nil
+	p.handshakeAttempts = 0
+	p.stopLocked(&p.retransmitHandshake)
+	p.stopLocked(&p.sendKeepalive)
+	p.stopLocked(&p.newHandshake)
+}
+
+// removeLocked disables the peer after it was removed from the device.
+//
+// +checklocks:p.mu
+func (p *Peer) removeLocked() {
+	p.resetLocked()
+	p.stopLocked(&p.persistentKeepaliveTimer)
+	p.local = nil
+	p.staticStatic = [KeySize]byte{}
+	p.removed = true
+}
+
+// armLocked arms t to call fn after d.
+//
+// +checklocks:p.mu
+func (p *Peer) armLocked(t *peerTimer, d time.Duration, fn func(*Peer) []outgoing) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.gen++
+	t.pending = true
+	gen := t.gen
+	t.timer = p.ep.stack.Clock().AfterFunc(d, func() {
+		p.fire(t, gen, fn)
+	})
+}
+
+// stopLocked disarms t.
+//
+// +checklocks:p.mu
+func (p *Peer) stopLocked(t *peerTimer) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.gen++
+	t.pending = false
+}
+
+// fire runs fn for an expiration of t, unless t was rearmed or stopped since.
+func (p *Peer) fire(t *peerTimer, gen uint64, fn func(*Peer) []outgoing) {
+	p.mu.Lock()
+	if t.gen != gen || p.removed {
+		p.mu.Unlock()
+		return
+	}
+	t.pending = false
+	out := fn(p)
+	p.mu.Unlock()
+	p.ep.send(out)
+}
+
+// onRetransmitHandshake is called when no response to an initiation was
+// received within rekeyTimeout.
+//
+// +checklocks:p.mu
+func (p *Peer) onRetransmitHandshake() []outgoing {
+	if p.handshakeAttempts >= maxHandshakeAttempts {
+		// Give up; the next packet to the peer starts a new handshake.
+		p.handshakeAttempts = 0
+		p.staged = nil
+		if p.hs.localIndex != 0 {
+			p.ep.index.remove(p.hs.localIndex)
+		}
+		p.hs.clear()
+		return nil
+	}
+	return p.initiateLocked(true)
+}
+
+// onSendKeepalive is called when data was received from the peer but nothing
+// was sent back for keepaliveTimeout.
+//
+// +checklocks:p.mu
+func (p *Peer) onSendKeepalive() []outgoing {
+	return p.sendLocked(nil)
+}
+
+// onNewHandshake is called when data was sent to the peer but nothing was
+// received back for keepaliveTimeout + rekeyTimeout.
+//
+// +checklocks:p.mu
+func (p *Peer) onNewHandshake() []outgoing {
+	return p.initiateLocked(false)
+}
+
+// onPersistentKeepalive is called when nothing was sent to or received from
+// the peer for the persistent keepalive interval.
+//
+// +checklocks:p.mu
+func (p *Peer) onPersistentKeepalive() []outgoing {
+	return p.sendLocked(nil)
+}
+
+// authenticatedTraversedLocked is called when an authenticated message was
+// sent to or received from the peer.
+//
+// +checklocks:p.mu
+func (p *Peer) authenticatedTraversedLocked() {
+	if p.persistentKeepalive != 0 {
+		p.armLocked(&p.persistentKeepaliveTimer, p.persistentKeepalive, (*Peer).onPersistentKeepalive)
+	}
+}
+
+// usable returns true if kp can still be used at time now.
+func usable(kp *keypair, now tcpip.MonotonicTime) bool {
+	return kp != nil && now.Sub(kp.created) < rejectAfterTime && kp.sendCounter < rejectAfterMessages
+}
+
+// initiateLocked starts a handshake with the peer. If retry is false, no
+// initiation is sent if one was sent recently.
+//
+// +checklocks:p.mu
+func (p *Peer) initiateLocked(retry bool) []outgoing {
+	if p.local == nil || p.endpoint.Addr.Len() == 0 {
+		return nil
+	}
+	now := p.ep.stack.Clock().NowMonotonic()
+	if !retry && p.hs.state == handshakeInitiationCreated && now.Sub(p.lastInitiationSent) < rekeyTimeout {
+		return nil
+	}
+	if retry {
+		p.handshakeAttempts++
+	} else {
+		p.handshakeAttempts = 0
+	}
+
+	idx := &p.ep.index
+	if p.hs.localIndex != 0 {
+		idx.remove(p.hs.localIndex)
+	}
+	p.hs.clear()
+	p.hs.localIndex = idx.add(p.ep.rng(), indexEntry{peer: p})
+	msg, ok := p.hs.createInitiation(p.ep.rng(), p.local, p.pub, p.staticStatic, p.ep.stack.Clock().Now())
+	if !ok {
+		idx.remove(p.hs.localIndex)
+		p.hs.clear()
+		return nil
+	}
+	p.addMACsLocked(msg, now)
+	p.lastInitiationSent = now
+	p.armLocked(&p.retransmitHandshake, rekeyTimeout, (*Peer).onRetransmitHandshake)
+	p.authenticatedTraversedLocked()
+	return []outgoing{{to: p.endpoint, msgs: [][]byte{msg}}}
+}
+
+// addMACsLocked sets the MAC fields of the handshake message msg.
+//
+// +checklocks:p.mu
+func (p *Peer) addMACsLocked(msg []byte, now tcpip.MonotonicTime) {
+	off := len(msg) - 2*macSize
+	mac1 := mac(p.mac1Key[:], msg[:off])
+	copy(msg[off:], mac1[:])
+	p.lastSentMAC1 = mac1
+	if p.cookieSet != (tcpip.MonotonicTime{}) && now.Sub(p.cookieSet) < cookieRefreshTime {
+		mac2 := mac(p.cookie[:], msg[:off+macSize])
+		copy(msg[off+macSize:], mac2[:])
+	}
+}
+
+// sendLocked encrypts packet and returns the transport message for the peer.
+// If there is no usable session, the packet is staged and a handshake is
+// started. A nil packet is a keepalive.
+//
+// +checklocks:p.mu
+func (p *Peer) sendLocked(packet []byte) []outgoing {
+	now := p.ep.stack.Clock().NowMonotonic()
+	kp := p.current
+	if !usable(kp, now) || p.endpoint.Addr.Len() == 0 {
+		if packet != nil {
+			if len(p.staged) == maxStagedPackets {
+				p.staged = p.staged[1:]
+			}
+			p.staged = append(p.staged, packet)
+		}
+		return p.initiateLocked(false)
+	}
+
+	msg := kp.encrypt(packet, int(p.ep.MTU()))
+	p.txBytes += uint64(len(msg))
+	p.stopLocked(&p.sendKeepalive)
+	if packet != nil && !p.newHandshake.pending {
+		p.armLocked(&p.newHandshake, keepaliveTimeout+rekeyTimeout, (*Peer).onNewHandshake)
+	}
+	p.authenticatedTraversedLocked()
+	out := []outgoing{{to: p.endpoint, msgs: [][]byte{msg}}}
+
+	// The initiator of a session renews it when it gets old.
+	if kp.initiator && (now.Sub(kp.created) >= rekeyAfterTime || kp.sendCounter >= rekeyAfterMessages) {
+		out = append(out, p.initiateLocked(false)...)
+	}
+	return out
+}
+
+// flushLocked sends the staged packets, or a keepalive to confirm a new
+// session if there are none and confirm is true.
+//
+// +checklocks:p.mu
+func (p *Peer) flushLocked(confirm bool) []outgoing {
+	staged := p.staged
+	p.staged = nil
+	if len(staged) == 0 {
+		if confirm {
+			return p.sendLocked(nil)
+		}
+		return nil
+	}
+	var out []outgoing
+	for _, packet := range staged {
+		out = append(out, p.sendLocked(packet)...)
+	}
+	return out
+}
+
+// handshakeCompleteLocked is called when a session with the peer was
+// established.
+//
+// +checklocks:p.mu
+func (p *Peer) handshakeCompleteLocked() {
+	p.stopLocked(&p.retransmitHandshake)
+	p.handshakeAttempts = 0
+	p.lastHandshake = p.ep.stack.Clock().Now()
+}
+
+// consumeInitiation finishes consuming the initiation msg received from from,
+// and returns the response to it.
+func (p *Peer) consumeInitiation(local *identity, is initiationStatic, msg []byte, from tcpip.FullAddress) []outgoing {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.local != local || p.removed {
+		// The private key of the device changed concurrently.
+		return nil
+	}
+	now := p.ep.stack.Clock().NowMonotonic()
+	idx := &p.ep.index
+	oldIndex := p.hs.localIndex
+	if !p.hs.consumeInitiation(is, msg, p.staticStatic, now) {
+		return nil
+	}
+	if oldIndex != 0 {
+		idx.remove(oldIndex)
+	}
+	p.hs.localIndex = idx.add(p.ep.rng(), indexEntry{peer: p})
+	resp, ok := p.hs.createResponse(p.ep.rng(), p.pub, p.psk)
+	if !ok {
+		idx.remove(p.hs.localIndex)
+		p.hs.clear()
+		return nil
+	}
+	p.addMACsLocked(resp, now)
+	kp := p.hs.deriveKeypair(false /* initiator */, now)
+	idx.set(kp.localIndex, indexEntry{peer: p, kp: kp})
+
+	// The new keypair is only used for sending once the initiator has
+	// confirmed it.
+	if p.next != nil {
+		idx.remove(p.next.localIndex)
+	}
+	if p.previous != nil {
+		idx.remove(p.previous.localIndex)
+		p.previous = nil
+	}
+	p.next = kp
+	p.endpoint = from
+	p.authenticatedTraversedLocked()
+	return []outgoing{{to: from, msgs: [][]byte{resp}}}
+}
+
+// consumeResponse consumes the response msg to an initiation, received from
+// from.
+func (p *Peer) consumeResponse(msg []byte, from tcpip.FullAddress) []outgoing {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.local == nil || p.removed || p.hs.state != handshakeInitiationCreated {
+		return nil
+	}
+	if binary.LittleEndian.Uint32(msg[8:]) != p.hs.localIndex || !checkMAC1(p.local.mac1Key, msg) {
+		return nil
+	}
+	if !p.hs.consumeResponse(p.local, p.psk, msg) {
+		return nil
+	}
+	now := p.ep.stack.Clock().NowMonotonic()
+	kp := p.hs.deriveKeypair(true /* initiator */, now)
+	idx := &p.ep.index
+	idx.set(kp.localIndex, indexEntry{peer: p, kp: kp})
+
+	if p.previous != nil {
+		idx.remove(p.previous.localIndex)
+	}
+	if p.next != nil {
+		p.previous = p.next
+		p.next = nil
+		if p.current != nil {
+			idx.remove(p.current.localIndex)
+		}
+	} else {
+		p.previous = p.current
+	}
+	p.current = kp
+	p.endpoint = from
+	p.handshakeCompleteLocked()
+	p.authenticatedTraversedLocked()
+	return p.flushLocked(true /* confirm */)
+}
+
+// consumeCookieReply consumes a cookie reply to a handshake message sent to
+// the peer.
+func (p *Peer) consumeCookieReply(msg []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lastSentMAC1 == ([macSize]byte{}) {
+		return
+	}
+	aead, err := chacha20poly1305.NewX(p.cookieKey[:])
+	if err != nil {
+		return
+	}
+	cookie, err := aead.Open(nil, msg[8:32], msg[32:64], p.lastSentMAC1[:])
+	if err != nil {
+		return
+	}
+	copy(p.cookie[:], cookie)
+	p.cookieSet = p.ep.stack.Clock().NowMonotonic()
+}
+
+// consumeTransport decrypts the transport message msg received from from with
+// kp. It returns the decapsulated packet, if any, and the messages to send in
+// response.
+func (p *Peer) consumeTransport(kp *keypair, msg []byte, from tcpip.FullAddress) ([]byte, []outgoing) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.removed || (kp != p.current && kp != p.previous && kp != p.next) {
+		return nil, nil
+	}
+	now := p.ep.stack.Clock().NowMonotonic()
+	if now.Sub(kp.created) >= rejectAfterTime {
+		return nil, nil
+	}
+	packet, ok := kp.decrypt(msg)
+	if !ok {
+		return nil, nil
+	}
+
+	var out []outgoing
+	p.endpoint = from
+	p.rxBytes += uint64(len(msg))
+	if kp == p.next {
+		// The initiator confirmed the session.
+		idx := &p.ep.index
+		if p.previous != nil {
+			idx.remove(p.previous.localIndex)
+		}
+		p.previous = p.current
+		p.current = kp
+		p.next = nil
+		p.handshakeCompleteLocked()
+		out = p.flushLocked(false /* confirm */)
+	}
+	p.stopLocked(&p.newHandshake)
+	p.authenticatedTraversedLocked()
+
+	// The initiator renews a session that will expire soon even if it only
+	// receives data, so that the responder doesn't have to.
+	if kp == p.current && kp.initiator && now.Sub(kp.created) >= rejectAfterTime-keepaliveTimeout-rekeyTimeout {
+		out = append(out, p.initiateLocked(false)...)
+	}
+
+	if len(packet) == 0 {
+		// Keepalive.
+		return nil, out
+	}
+	if !p.sendKeepalive.pending {
+		p.armLocked(&p.sendKeepalive, keepaliveTimeout, (*Peer).onSendKeepalive)
+	}
+	return packet, out
+}
+
+// info returns the state of the peer.
+func (p *Peer) info() PeerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PeerInfo{
+		PublicKey:           p.pub,
+		PresharedKey:        p.psk,
+		Endpoint:            p.endpoint,
+		PersistentKeepalive: p.persistentKeepalive,
+		LastHandshake:       p.lastHandshake,
+		RxBytes:             p.rxBytes,
+		TxBytes:             p.txBytes,
+	}
+}
+
+// configureLocked applies the peer attributes of c.
+//
+// +checklocks:p.mu
+func (p *Peer) configureLocked(c *PeerConfig) []outgoing {
+	if c.HasPresharedKey {
+		p.psk = c.PresharedKey
+	}
+	if c.HasEndpoint {
+		p.endpoint = c.Endpoint
+	}
+	if c.HasPersistentKeepalive {
+		p.persistentKeepalive = c.PersistentKeepalive
+		if p.persistentKeepalive == 0 {
+			p.stopLocked(&p.persistentKeepaliveTimer)
+		} else {
+			// Like Linux, send a keepalive right away so that the
+			// peer learns our endpoint.
+			return p.sendLocked(nil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+const (
+	replayBlockBits  = 64
+	replayRingBlocks = 128
+
+	// replayWindowSize is the number of counters behind the greatest received
+	// counter that are still accepted.
+	replayWindowSize = (replayRingBlocks - 1) * replayBlockBits
+)
+
+// replayFilter rejects transport messages whose counter was already received
+// or is too old, as described in RFC 6479.
+type replayFilter struct {
+	// last is the greatest counter that was accepted.
+	last uint64
+
+	// ring is a bitmap of the accepted counters in the window.
+	ring [replayRingBlocks]uint64
+}
+
+// accept returns true if counter wasn't received before and marks it as
+// received.
+func (f *replayFilter) accept(counter uint64) bool {
+	if counter >= rejectAfterMessages {
+		return false
+	}
+	block := counter / replayBlockBits
+	if counter > f.last {
+		// Move the window forward and clear the blocks that enter it.
+		current := f.last / replayBlockBits
+		diff := block - current
+		if diff > replayRingBlocks {
+			diff = replayRingBlocks
+		}
+		for i := current + 1; i <= current+diff; i++ {
+			f.ring[i%replayRingBlocks] = 0
+		}
+		f.last = counter
+	} else if f.last-counter > replayWindowSize {
+		return false
+	}
+	bit := uint64(1) << (counter % replayBlockBits)
+	old := f.ring[block%replayRingBlocks]
+	f.ring[block%replayRingBlocks] = old | bit
+	return old&bit == 0
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// indexEntry is the session or handshake that a local index refers to.
+type indexEntry struct {
+	peer *Peer
+
+	// kp is the keypair of the session, or nil for a handshake.
+	kp *keypair
+}
+
+// indexTable maps the local indices of handshakes and sessions, which are
+// chosen randomly, to their peers.
+type indexTable struct {
+	mu indexMutex
+	// +checklocks:mu
+	entries map[uint32]indexEntry
+}
+
+// add returns a new random index that refers to ent.
+func (t *indexTable) add(rng io.Reader, ent indexEntry) uint32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries == nil {
+		t.entries = make(map[uint32]indexEntry)
+	}
+	for {
+		var b [4]byte
+		if _, err := io.ReadFull(rng, b[:]); err != nil {
+			panic(err)
+		}
+		i := binary.LittleEndian.Uint32(b[:])
+		if _, ok := t.entries[i]; i != 0 && !ok {
+			t.entries[i] = ent
+			return i
+		}
+	}
+}
+
+// set changes the entry of index i.
+func (t *indexTable) set(i uint32, ent indexEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries[i] = ent
+}
+
+// remove releases index i.
+func (t *indexTable) remove(i uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, i)
+}
+
+// lookup returns the entry of index i.
+func (t *indexTable) lookup(i uint32) (indexEntry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ent, ok := t.entries[i]
+	return ent, ok
+}
+
+// socket is a UDP endpoint through which a device exchanges messages with its
+// peers.
+type socket struct {
+	e        *Endpoint
+	netProto tcpip.NetworkProtocolNumber
+	ep       tcpip.Endpoint
+	wq       waiter.Queue
+	done     chan struct{}
+}
+
+// openSockets creates and binds the IPv4 and IPv6 UDP endpoints of e to port,
+// or to a random port if port is zero. It returns the sockets and the bound
+// port. The receive loops of the sockets must be started with start.
+func openSockets(e *Endpoint, port uint16, fwmark uint32) ([]*socket, uint16, tcpip.Error) {
+	var socks []*socket
+	closeAll := func() {
+		for _, sock := range socks {
+			sock.ep.Close()
+		}
+	}
+	for _, netProto := range []tcpip.NetworkProtocolNumber{ipv4.ProtocolNumber, ipv6.ProtocolNumber} {
+		if e.stack.NetworkProtocolInstance(netProto) == nil {
+			continue
+		}
+		sock := &socket{e: e, netProto: netProto, done: make(chan struct{})}
+		ep, err := e.stack.NewEndpoint(udp.ProtocolNumber, netProto, &sock.wq)
+		if err != nil {
+			closeAll()
+			return nil, 0, err
+		}
+		if netProto == ipv6.ProtocolNumber {
+			ep.SocketOptions().SetV6Only(true)
+		}
+		ep.SocketOptions().SetMark(fwmark)
+		if err := ep.Bind(tcpip.FullAddress{Port: port}); err != nil {
+			ep.Close()
+			closeAll()
+			return nil, 0, err
+		}
+		if port == 0 {
+			// Use the same random port for both protocols.
+			addr, err := ep.GetLocalAddress()
+			if err != nil {
+				ep.Close()
+				closeAll()
+				return nil, 0, err
+			}
+			port = addr.Port
+		}
+		sock.ep = ep
+		socks = append(socks, sock)
+	}
+	if len(socks) == 0 {
+		return nil, 0, &tcpip.ErrUnknownProtocol{}
+	}
+	return socks, port, nil
+}
+
+// start starts the receive loop of s.
+func (s *socket) start() {
+	go s.loop() // S/R-SAFE: WireGuard devices are not saved.
+}
+
+// close closes s and waits for its receive loop to exit.
+func (s *socket) close() {
+	s.ep.Close()
+	<-s.done
+}
+
+// write sends b to the address to.
+func (s *socket) write(to tcpip.FullAddress, b []byte) tcpip.Error {
+	_, err := s.ep.Write(bytes.NewReader(b), tcpip.WriteOptions{To: &to})
+	return err
+}
+
+// loop receives messages until the UDP endpoint is closed.
+func (s *socket) loop() {
+	defer close(s.done)
+	waitEntry, notifyCh := waiter.NewChannelEntry(waiter.ReadableEvents)
+	s.wq.EventRegister(&waitEntry)
+	defer s.wq.EventUnregister(&waitEntry)
+	for {
+		var b bytes.Buffer
+		res, err := s.ep.Read(&b, tcpip.ReadOptions{NeedRemoteAddr: true})
+		switch err.(type) {
+		case nil:
+			from := res.RemoteAddr
+			from.NIC = 0
+			s.e.receive(from, b.Bytes())
+		case *tcpip.ErrWouldBlock:
+			<-notifyCh
+		default:
+			return
+		}
+	}
+}
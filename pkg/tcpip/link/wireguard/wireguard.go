@@ -0,0 +1,653 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wireguard provides the implementation of a WireGuard link endpoint.
+//
+// A WireGuard device is a layer 3 tunnel: IP packets written to it are
+// encrypted and sent in UDP datagrams to the peer whose allowed IPs contain
+// their destination, and packets received from a peer are decrypted and
+// delivered if their source is in the allowed IPs of the peer. The UDP
+// traffic goes through the same stack as the tunneled traffic.
+//
+// See https://www.wireguard.com/papers/wireguard.pdf for a description of the
+// protocol.
+package wireguard
+
+import (
+	"crypto/ecdh"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+const (
+	// KeySize is the size of WireGuard keys.
+	KeySize = 32
+
+	// DefaultMTU is the default MTU of WireGuard devices. It leaves room for
+	// the encapsulation overhead over IPv6 in a 1500 byte packet.
+	DefaultMTU = 1420
+)
+
+var _ stack.LinkEndpoint = (*Endpoint)(nil)
+
+// Key is a Curve25519 private or public key, or a preshared key.
+type Key [KeySize]byte
+
+// IsZero returns true if k is all zeros.
+func (k Key) IsZero() bool {
+	return k == Key{}
+}
+
+// PublicKey returns the public key of the private key k.
+func (k Key) PublicKey() Key {
+	return newIdentity(k).pub
+}
+
+// GeneratePrivateKey returns a new private key read from rng.
+func GeneratePrivateKey(rng io.Reader) (Key, error) {
+	priv, err := ecdh.X25519().GenerateKey(rng)
+	if err != nil {
+		return Key{}, err
+	}
+	var k Key
+	copy(k[:], priv.Bytes())
+	return k, nil
+}
+
+// Options specify the configuration of a WireGuard device.
+type Options struct {
+	// MTU is the MTU of the device. If it is zero, DefaultMTU is used.
+	MTU uint32
+
+	// ListenPort is the UDP port of the device. If it is zero, a random
+	// port is used.
+	ListenPort uint16
+}
+
+// PeerConfig describes a change to a peer of a device.
+type PeerConfig struct {
+	// PublicKey identifies the peer.
+	PublicKey Key
+
+	// Remove removes the peer. All other fields are ignored.
+	Remove bool
+
+	// UpdateOnly only changes an existing peer; the peer isn't created if it
+	// doesn't exist.
+	UpdateOnly bool
+
+	// HasPresharedKey indicates that PresharedKey is set. A zero key disables
+	// the preshared key.
+	HasPresharedKey bool
+	PresharedKey    Key
+
+	// HasEndpoint indicates that Endpoint is set.
+	HasEndpoint bool
+	Endpoint    tcpip.FullAddress
+
+	// HasPersistentKeepalive indicates that PersistentKeepalive is set. A
+	// zero interval disables persistent keepalives.
+	HasPersistentKeepalive bool
+	PersistentKeepalive    time.Duration
+
+	// ReplaceAllowedIPs removes the allowed IPs of the peer before
+	// AllowedIPs are added.
+	ReplaceAllowedIPs bool
+
+	// AllowedIPs are assigned to the peer. Subnets that are assigned to other
+	// peers are moved to this one.
+	AllowedIPs []tcpip.AddressWithPrefix
+}
+
+// PeerInfo describes a peer of a device.
+type PeerInfo struct {
+	PublicKey           Key
+	PresharedKey        Key
+	Endpoint            tcpip.FullAddress
+	PersistentKeepalive time.Duration
+
+	// LastHandshake is the time of the last completed handshake, or the zero
+	// time if there was none.
+	LastHandshake time.Time
+
+	// RxBytes and TxBytes count the bytes of the messages received from and
+	// sent to the peer.
+	RxBytes uint64
+	TxBytes uint64
+
+	AllowedIPs []tcpip.Subnet
+}
+
+// Endpoint is a link endpoint of a WireGuard device.
+type Endpoint struct {
+	stack *stack.Stack
+	index indexTable
+
+	// mtu is accessed atomically since it is needed by peers while their
+	// locks are held.
+	mtu atomicbitops.Uint32
+
+	// mu protects the configuration of the device. It is ordered before the
+	// locks of the peers.
+	mu endpointRWMutex
+	// identity is the static key pair of the device, or nil if no private
+	// key is set.
+	//
+	// +checklocks:mu
+	identity *identity
+	// +checklocks:mu
+	privateKey Key
+	// +checklocks:mu
+	peers map[Key]*Peer
+	// +checklocks:mu
+	allowedIPs allowedIPs
+	// +checklocks:mu
+	port uint16
+	// +checklocks:mu
+	fwmark uint32
+	// +checklocks:mu
+	sockets []*socket
+	// +checklocks:mu
+	dispatcher stack.NetworkDispatcher
+	// +checklocks:mu
+	closed bool
+	// +checklocks:mu
+	onCloseAction func()
+}
+
+// New creates a WireGuard endpoint on s. s must support UDP. The device has no
+// private key and no peers until they are configured.
+func New(s *stack.Stack, opts Options) (*Endpoint, tcpip.Error) {
+	if opts.MTU == 0 {
+		opts.MTU = DefaultMTU
+	}
+	e := &Endpoint{
+		stack: s,
+		peers: make(map[Key]*Peer),
+		mtu:   atomicbitops.FromUint32(opts.MTU),
+	}
+	socks, port, err := openSockets(e, opts.ListenPort, 0)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.sockets = socks
+	e.port = port
+	e.mu.Unlock()
+	for _, sock := range socks {
+		sock.start()
+	}
+	return e, nil
+}
+
+// rng returns the source of randomness of keys and indices.
+func (e *Endpoint) rng() io.Reader {
+	return e.stack.SecureRNG().Reader
+}
+
+// PrivateKey returns the private key of the device.
+func (e *Endpoint) PrivateKey() Key {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.privateKey
+}
+
+// PublicKey returns the public key of the device, or a zero key if the device
+// has no private key.
+func (e *Endpoint) PublicKey() Key {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.identity == nil {
+		return Key{}
+	}
+	return e.identity.pub
+}
+
+// SetPrivateKey sets the private key of the device. A zero key removes the
+// private key. Changing the key resets all sessions, and removes the peer
+// with the new public key if there is one.
+func (e *Endpoint) SetPrivateKey(k Key) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if k == e.privateKey {
+		return
+	}
+	e.privateKey = k
+	e.identity = nil
+	if !k.IsZero() {
+		e.identity = newIdentity(k)
+		if p, ok := e.peers[e.identity.pub]; ok {
+			e.removePeerLocked(p)
+		}
+	}
+	for _, p := range e.peers {
+		p.mu.Lock()
+		p.setLocalLocked(e.identity)
+		p.mu.Unlock()
+	}
+}
+
+// ListenPort returns the UDP port of the device.
+func (e *Endpoint) ListenPort() uint16 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.port
+}
+
+// SetListenPort changes the UDP port of the device. If port is zero, a random
+// port is used.
+func (e *Endpoint) SetListenPort(port uint16) tcpip.Error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return &tcpip.ErrClosedForSend{}
+	}
+	if port != 0 && port == e.port {
+		e.mu.Unlock()
+		return nil
+	}
+	socks, port, err := openSockets(e, port, e.fwmark)
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	old := e.sockets
+	e.sockets = socks
+	e.port = port
+	e.mu.Unlock()
+
+	for _, sock := range old {
+		sock.close()
+	}
+	for _, sock := range socks {
+		sock.start()
+	}
+	return nil
+}
+
+// Fwmark returns the mark of the UDP packets sent by the device.
+func (e *Endpoint) Fwmark() uint32 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.fwmark
+}
+
+// SetFwmark sets the mark of the UDP packets sent by the device.
+func (e *Endpoint) SetFwmark(mark uint32) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fwmark = mark
+	for _, sock := range e.sockets {
+		sock.ep.SocketOptions().SetMark(mark)
+	}
+}
+
+// Peers returns the peers of the device.
+func (e *Endpoint) Peers() []PeerInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	infos := make([]PeerInfo, 0, len(e.peers))
+	for _, p := range e.peers {
+		info := p.info()
+		info.AllowedIPs = e.allowedIPs.subnets(p)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// RemoveAllPeers removes all peers of the device.
+func (e *Endpoint) RemoveAllPeers() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, p := range e.peers {
+		e.removePeerLocked(p)
+	}
+}
+
+// +checklocks:e.mu
+func (e *Endpoint) removePeerLocked(p *Peer) {
+	delete(e.peers, p.pub)
+	e.allowedIPs.removePeer(p)
+	p.mu.Lock()
+	p.removeLocked()
+	p.mu.Unlock()
+}
+
+// ConfigurePeer creates, changes or removes a peer of the device.
+func (e *Endpoint) ConfigurePeer(c PeerConfig) tcpip.Error {
+	for _, a := range c.AllowedIPs {
+		if l := a.Address.Len(); (l != header.IPv4AddressSize && l != header.IPv6AddressSize) || a.PrefixLen < 0 || a.PrefixLen > l*8 {
+			return &tcpip.ErrBadAddress{}
+		}
+	}
+	if c.HasEndpoint {
+		if l := c.Endpoint.Addr.Len(); l != header.IPv4AddressSize && l != header.IPv6AddressSize {
+			return &tcpip.ErrBadAddress{}
+		}
+	}
+
+	e.mu.Lock()
+	p, ok := e.peers[c.PublicKey]
+	if c.Remove {
+		if ok {
+			e.removePeerLocked(p)
+		}
+		e.mu.Unlock()
+		return nil
+	}
+	if !ok {
+		if c.UpdateOnly || (e.identity != nil && c.PublicKey == e.identity.pub) {
+			// Like Linux, silently ignore the peer.
+			e.mu.Unlock()
+			return nil
+		}
+		p = newPeer(e, c.PublicKey)
+		p.mu.Lock()
+		p.setLocalLocked(e.identity)
+		p.mu.Unlock()
+		e.peers[c.PublicKey] = p
+	}
+	if c.ReplaceAllowedIPs {
+		e.allowedIPs.removePeer(p)
+	}
+	for _, a := range c.AllowedIPs {
+		e.allowedIPs.insert(a.Subnet(), p)
+	}
+	p.mu.Lock()
+	out := p.configureLocked(&c)
+	p.mu.Unlock()
+	e.mu.Unlock()
+
+	e.send(out)
+	return nil
+}
+
+// send sends messages to peers.
+func (e *Endpoint) send(out []outgoing) {
+	if len(out) == 0 {
+		return
+	}
+	e.mu.RLock()
+	socks := e.sockets
+	e.mu.RUnlock()
+	for _, o := range out {
+		netProto := ipv4.ProtocolNumber
+		if o.to.Addr.Len() == header.IPv6AddressSize {
+			netProto = ipv6.ProtocolNumber
+		}
+		for _, sock := range socks {
+			if sock.netProto != netProto {
+				continue
+			}
+			for _, msg := range o.msgs {
+				// Errors are handled like lost packets.
+				sock.write(o.to, msg)
+			}
+		}
+	}
+}
+
+// receive handles the message msg received from the UDP address from.
+func (e *Endpoint) receive(from tcpip.FullAddress, msg []byte) {
+	if len(msg) < 4 || msg[1] != 0 || msg[2] != 0 || msg[3] != 0 {
+		return
+	}
+	switch msg[0] {
+	case messageInitiationType:
+		if len(msg) != messageInitiationSize {
+			return
+		}
+		e.mu.RLock()
+		local := e.identity
+		if local == nil || !checkMAC1(local.mac1Key, msg) {
+			e.mu.RUnlock()
+			return
+		}
+		is, ok := decryptInitiationStatic(local, msg)
+		if !ok {
+			e.mu.RUnlock()
+			return
+		}
+		p := e.peers[is.remote]
+		e.mu.RUnlock()
+		if p == nil {
+			return
+		}
+		e.send(p.consumeInitiation(local, is, msg, from))
+
+	case messageResponseType:
+		if len(msg) != messageResponseSize {
+			return
+		}
+		ent, ok := e.index.lookup(binary.LittleEndian.Uint32(msg[8:]))
+		if !ok || ent.kp != nil {
+			return
+		}
+		e.send(ent.peer.consumeResponse(msg, from))
+
+	case messageCookieReplyType:
+		if len(msg) != messageCookieReplySize {
+			return
+		}
+		if ent, ok := e.index.lookup(binary.LittleEndian.Uint32(msg[4:])); ok {
+			ent.peer.consumeCookieReply(msg)
+		}
+
+	case messageTransportType:
+		if len(msg) < messageTransportMinSize {
+			return
+		}
+		ent, ok := e.index.lookup(binary.LittleEndian.Uint32(msg[4:]))
+		if !ok || ent.kp == nil {
+			return
+		}
+		packet, out := ent.peer.consumeTransport(ent.kp, msg, from)
+		e.send(out)
+		if packet != nil {
+			e.deliver(ent.peer, packet)
+		}
+	}
+}
+
+// deliver delivers the decrypted packet received from p.
+func (e *Endpoint) deliver(p *Peer, packet []byte) {
+	var (
+		netProto tcpip.NetworkProtocolNumber
+		src      tcpip.Address
+		length   int
+	)
+	switch header.IPVersion(packet) {
+	case header.IPv4Version:
+		ip := header.IPv4(packet)
+		if len(packet) < header.IPv4MinimumSize || int(ip.TotalLength()) < header.IPv4MinimumSize {
+			return
+		}
+		netProto, src, length = ipv4.ProtocolNumber, ip.SourceAddress(), int(ip.TotalLength())
+	case header.IPv6Version:
+		ip := header.IPv6(packet)
+		if len(packet) < header.IPv6MinimumSize {
+			return
+		}
+		netProto, src, length = ipv6.ProtocolNumber, ip.SourceAddress(), header.IPv6MinimumSize+int(ip.PayloadLength())
+	default:
+		return
+	}
+	if length > len(packet) {
+		return
+	}
+
+	e.mu.RLock()
+	d := e.dispatcher
+	allowed := e.allowedIPs.lookup(src) == p
+	e.mu.RUnlock()
+	if d == nil || !allowed {
+		return
+	}
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: buffer.MakeWithData(packet[:length]),
+	})
+	defer pkt.DecRef()
+	d.DeliverNetworkPacket(netProto, pkt)
+}
+
+// WritePackets implements stack.LinkEndpoint.WritePackets. Packets are sent
+// to the peer whose allowed IPs contain their destination.
+func (e *Endpoint) WritePackets(pkts stack.PacketBufferList) (int, tcpip.Error) {
+	e.mu.RLock()
+	closed := e.closed
+	e.mu.RUnlock()
+	if closed {
+		return 0, &tcpip.ErrClosedForSend{}
+	}
+
+	n := 0
+	for _, pkt := range pkts.AsSlice() {
+		buf := pkt.ToBuffer()
+		packet := buf.Flatten()
+		buf.Release()
+
+		var dst tcpip.Address
+		switch pkt.NetworkProtocolNumber {
+		case ipv4.ProtocolNumber:
+			if len(packet) < header.IPv4MinimumSize {
+				return n, &tcpip.ErrMalformedHeader{}
+			}
+			dst = header.IPv4(packet).DestinationAddress()
+		case ipv6.ProtocolNumber:
+			if len(packet) < header.IPv6MinimumSize {
+				return n, &tcpip.ErrMalformedHeader{}
+			}
+			dst = header.IPv6(packet).DestinationAddress()
+		default:
+			// Only IP packets can be tunneled.
+			n++
+			continue
+		}
+
+		e.mu.RLock()
+		p := e.allowedIPs.lookup(dst)
+		e.mu.RUnlock()
+		if p == nil {
+			// There is no peer for the destination; drop the packet
+			// silently.
+			n++
+			continue
+		}
+		p.mu.Lock()
+		out := p.sendLocked(packet)
+		p.mu.Unlock()
+		e.send(out)
+		n++
+	}
+	return n, nil
+}
+
+// Attach implements stack.LinkEndpoint.Attach.
+func (e *Endpoint) Attach(dispatcher stack.NetworkDispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dispatcher = dispatcher
+}
+
+// IsAttached implements stack.LinkEndpoint.IsAttached.
+func (e *Endpoint) IsAttached() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.dispatcher != nil
+}
+
+// MTU implements stack.LinkEndpoint.MTU.
+func (e *Endpoint) MTU() uint32 {
+	return e.mtu.Load()
+}
+
+// SetMTU implements stack.LinkEndpoint.SetMTU.
+func (e *Endpoint) SetMTU(mtu uint32) {
+	e.mtu.Store(mtu)
+}
+
+// Capabilities implements stack.LinkEndpoint.Capabilities.
+func (*Endpoint) Capabilities() stack.LinkEndpointCapabilities {
+	return 0
+}
+
+// MaxHeaderLength implements stack.LinkEndpoint.MaxHeaderLength. Packets are
+// encrypted into new buffers, so no space has to be reserved.
+func (*Endpoint) MaxHeaderLength() uint16 {
+	return 0
+}
+
+// LinkAddress implements stack.LinkEndpoint.LinkAddress. WireGuard devices
+// have no link address.
+func (*Endpoint) LinkAddress() tcpip.LinkAddress {
+	return ""
+}
+
+// SetLinkAddress implements stack.LinkEndpoint.SetLinkAddress.
+func (*Endpoint) SetLinkAddress(tcpip.LinkAddress) {}
+
+// Wait implements stack.LinkEndpoint.Wait.
+func (*Endpoint) Wait() {}
+
+// ARPHardwareType implements stack.LinkEndpoint.ARPHardwareType.
+func (*Endpoint) ARPHardwareType() header.ARPHardwareType {
+	return header.ARPHardwareNone
+}
+
+// AddHeader implements stack.LinkEndpoint.AddHeader.
+func (*Endpoint) AddHeader(*stack.PacketBuffer) {}
+
+// ParseHeader implements stack.LinkEndpoint.ParseHeader.
+func (*Endpoint) ParseHeader(*stack.PacketBuffer) bool { return true }
+
+// Close implements stack.LinkEndpoint.Close.
+func (e *Endpoint) Close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	for _, p := range e.peers {
+		e.removePeerLocked(p)
+	}
+	socks := e.sockets
+	e.sockets = nil
+	action := e.onCloseAction
+	e.onCloseAction = nil
+	e.mu.Unlock()
+
+	for _, sock := range socks {
+		sock.close()
+	}
+	if action != nil {
+		action()
+	}
+}
+
+// SetOnCloseAction implements stack.LinkEndpoint.SetOnCloseAction.
+func (e *Endpoint) SetOnCloseAction(action func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onCloseAction = action
+}
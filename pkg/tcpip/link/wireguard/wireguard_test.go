@@ -0,0 +1,315 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wireguard
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/refs"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/pipe"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+)
+
+const (
+	underlayNICID = 1
+	tunnelNICID   = 2
+)
+
+// testHost is a stack with a WireGuard device.
+type testHost struct {
+	stack      *stack.Stack
+	wg         *Endpoint
+	underlay   tcpip.Address
+	tunnel     tcpip.Address
+	privateKey Key
+}
+
+func newTestHost(t *testing.T, link stack.LinkEndpoint, underlay, tunnel tcpip.Address, port uint16) *testHost {
+	t.Helper()
+
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{udp.NewProtocol},
+	})
+	t.Cleanup(s.Destroy)
+	if err := s.CreateNIC(underlayNICID, link); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", underlayNICID, err)
+	}
+	wg, err := New(s, Options{ListenPort: port})
+	if err != nil {
+		t.Fatalf("New(_, _): %s", err)
+	}
+	if err := s.CreateNIC(tunnelNICID, wg); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", tunnelNICID, err)
+	}
+	for _, a := range []struct {
+		nicID tcpip.NICID
+		addr  tcpip.AddressWithPrefix
+	}{
+		{underlayNICID, tcpip.AddressWithPrefix{Address: underlay, PrefixLen: 24}},
+		{tunnelNICID, tcpip.AddressWithPrefix{Address: tunnel, PrefixLen: 24}},
+	} {
+		protoAddr := tcpip.ProtocolAddress{Protocol: ipv4.ProtocolNumber, AddressWithPrefix: a.addr}
+		if err := s.AddProtocolAddress(a.nicID, protoAddr, stack.AddressProperties{}); err != nil {
+			t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", a.nicID, protoAddr, err)
+		}
+	}
+	s.SetRouteTable([]tcpip.Route{
+		{Destination: tcpip.AddressWithPrefix{Address: underlay, PrefixLen: 24}.Subnet(), NIC: underlayNICID},
+		{Destination: tcpip.AddressWithPrefix{Address: tunnel, PrefixLen: 24}.Subnet(), NIC: tunnelNICID},
+	})
+
+	k, genErr := GeneratePrivateKey(rand.Reader)
+	if genErr != nil {
+		t.Fatalf("GeneratePrivateKey(_): %s", genErr)
+	}
+	wg.SetPrivateKey(k)
+	return &testHost{
+		stack:      s,
+		wg:         wg,
+		underlay:   underlay,
+		tunnel:     tunnel,
+		privateKey: k,
+	}
+}
+
+// newTestHosts returns two hosts whose underlay NICs are connected.
+func newTestHosts(t *testing.T) (*testHost, *testHost) {
+	t.Helper()
+
+	link1, link2 := pipe.New("", "", header.IPv4MinimumMTU*2)
+	h1 := newTestHost(t, link1, tcpip.AddrFrom4([4]byte{10, 0, 0, 1}), tcpip.AddrFrom4([4]byte{192, 168, 0, 1}), 51820)
+	h2 := newTestHost(t, link2, tcpip.AddrFrom4([4]byte{10, 0, 0, 2}), tcpip.AddrFrom4([4]byte{192, 168, 0, 2}), 51821)
+	return h1, h2
+}
+
+// addPeer adds peer as a peer of h.
+func (h *testHost) addPeer(t *testing.T, peer *testHost, psk Key) {
+	t.Helper()
+
+	c := PeerConfig{
+		PublicKey:       peer.privateKey.PublicKey(),
+		HasPresharedKey: true,
+		PresharedKey:    psk,
+		HasEndpoint:     true,
+		Endpoint:        tcpip.FullAddress{Addr: peer.underlay, Port: peer.wg.ListenPort()},
+		AllowedIPs:      []tcpip.AddressWithPrefix{peer.tunnel.WithPrefix()},
+	}
+	if err := h.wg.ConfigurePeer(c); err != nil {
+		t.Fatalf("h.wg.ConfigurePeer(%+v): %s", c, err)
+	}
+}
+
+// exchange sends a datagram from src to dst through the tunnel and returns
+// whether it was received.
+func exchange(t *testing.T, src, dst *testHost, timeout time.Duration) bool {
+	t.Helper()
+
+	dstAddr := tcpip.FullAddress{Addr: dst.tunnel, Port: 1234}
+	recv, err := gonet.DialUDP(dst.stack, &dstAddr, nil, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("gonet.DialUDP(_, %+v, nil, _): %s", dstAddr, err)
+	}
+	defer recv.Close()
+	send, err := gonet.DialUDP(src.stack, nil, &dstAddr, ipv4.ProtocolNumber)
+	if err != nil {
+		t.Fatalf("gonet.DialUDP(_, nil, %+v, _): %s", dstAddr, err)
+	}
+	defer send.Close()
+
+	data := []byte("hello through the tunnel")
+	if _, err := send.Write(data); err != nil {
+		t.Fatalf("send.Write(_): %s", err)
+	}
+	recv.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 100)
+	n, err := recv.Read(buf)
+	if err != nil {
+		return false
+	}
+	if got := buf[:n]; !bytes.Equal(got, data) {
+		t.Fatalf("got datagram %q, want %q", got, data)
+	}
+	return true
+}
+
+func TestHandshakeAndTransport(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		psk  Key
+	}{
+		{name: "no preshared key"},
+		{name: "preshared key", psk: Key{1, 2, 3}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h1, h2 := newTestHosts(t)
+			h1.addPeer(t, h2, test.psk)
+			h2.addPeer(t, h1, test.psk)
+
+			// The first datagram is staged until the handshake
+			// completes.
+			if !exchange(t, h1, h2, 5*time.Second) {
+				t.Fatalf("datagram from h1 to h2 wasn't received")
+			}
+			if !exchange(t, h2, h1, 5*time.Second) {
+				t.Fatalf("datagram from h2 to h1 wasn't received")
+			}
+
+			for _, h := range []*testHost{h1, h2} {
+				peers := h.wg.Peers()
+				if len(peers) != 1 {
+					t.Fatalf("got %d peers, want 1", len(peers))
+				}
+				p := peers[0]
+				if p.LastHandshake.IsZero() {
+					t.Errorf("got zero last handshake time")
+				}
+				if p.RxBytes == 0 || p.TxBytes == 0 {
+					t.Errorf("got RxBytes = %d, TxBytes = %d, want non-zero", p.RxBytes, p.TxBytes)
+				}
+			}
+		})
+	}
+}
+
+func TestPresharedKeyMismatch(t *testing.T) {
+	h1, h2 := newTestHosts(t)
+	h1.addPeer(t, h2, Key{1})
+	h2.addPeer(t, h1, Key{2})
+
+	if exchange(t, h1, h2, time.Second) {
+		t.Fatalf("datagram was received despite mismatched preshared keys")
+	}
+	for _, p := range h1.wg.Peers() {
+		if !p.LastHandshake.IsZero() {
+			t.Errorf("got last handshake time = %s, want zero", p.LastHandshake)
+		}
+	}
+}
+
+func TestAllowedIPsSource(t *testing.T) {
+	h1, h2 := newTestHosts(t)
+	h1.addPeer(t, h2, Key{})
+	h2.addPeer(t, h1, Key{})
+
+	// Packets from h1 are dropped by h2 once the tunnel address of h1 isn't
+	// an allowed IP of h1 anymore.
+	other := tcpip.AddrFrom4([4]byte{192, 168, 0, 100})
+	c := PeerConfig{
+		PublicKey:         h1.privateKey.PublicKey(),
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        []tcpip.AddressWithPrefix{other.WithPrefix()},
+	}
+	if err := h2.wg.ConfigurePeer(c); err != nil {
+		t.Fatalf("h2.wg.ConfigurePeer(%+v): %s", c, err)
+	}
+	if exchange(t, h1, h2, time.Second) {
+		t.Fatalf("datagram from a source that isn't an allowed IP was received")
+	}
+}
+
+func TestRemovePeer(t *testing.T) {
+	h1, h2 := newTestHosts(t)
+	h1.addPeer(t, h2, Key{})
+	pub := h2.privateKey.PublicKey()
+	if err := h1.wg.ConfigurePeer(PeerConfig{PublicKey: pub, Remove: true}); err != nil {
+		t.Fatalf("h1.wg.ConfigurePeer(_): %s", err)
+	}
+	if peers := h1.wg.Peers(); len(peers) != 0 {
+		t.Fatalf("got peers %+v after removal, want none", peers)
+	}
+
+	// A peer with the public key of the device is ignored.
+	if err := h1.wg.ConfigurePeer(PeerConfig{PublicKey: h1.privateKey.PublicKey()}); err != nil {
+		t.Fatalf("h1.wg.ConfigurePeer(_): %s", err)
+	}
+	if peers := h1.wg.Peers(); len(peers) != 0 {
+		t.Fatalf("got peers %+v, want none", peers)
+	}
+}
+
+func TestReplayFilter(t *testing.T) {
+	var f replayFilter
+	for _, test := range []struct {
+		counter uint64
+		want    bool
+	}{
+		{0, true},
+		{0, false},
+		{1, true},
+		{5, true},
+		{3, true},
+		{5, false},
+		{replayWindowSize, true},
+		{4, true},
+		{3, false},
+		{replayWindowSize + 100, true},
+		{4, false},
+		{rejectAfterMessages, false},
+	} {
+		if got := f.accept(test.counter); got != test.want {
+			t.Errorf("f.accept(%d) = %t, want %t", test.counter, got, test.want)
+		}
+	}
+}
+
+func TestAllowedIPsLookup(t *testing.T) {
+	var a allowedIPs
+	p1, p2 := &Peer{}, &Peer{}
+	subnet := func(addr [4]byte, prefix int) tcpip.Subnet {
+		return tcpip.AddressWithPrefix{Address: tcpip.AddrFrom4(addr), PrefixLen: prefix}.Subnet()
+	}
+	a.insert(subnet([4]byte{10, 0, 0, 0}, 8), p1)
+	a.insert(subnet([4]byte{10, 1, 0, 0}, 16), p2)
+
+	for _, test := range []struct {
+		addr [4]byte
+		want *Peer
+	}{
+		{[4]byte{10, 0, 0, 1}, p1},
+		{[4]byte{10, 1, 0, 1}, p2},
+		{[4]byte{11, 0, 0, 1}, nil},
+	} {
+		if got := a.lookup(tcpip.AddrFrom4(test.addr)); got != test.want {
+			t.Errorf("a.lookup(%v) = %p, want %p", test.addr, got, test.want)
+		}
+	}
+
+	// Assigning a subnet to another peer moves it.
+	a.insert(subnet([4]byte{10, 0, 0, 0}, 8), p2)
+	if got := a.lookup(tcpip.AddrFrom4([4]byte{10, 0, 0, 1})); got != p2 {
+		t.Errorf("got peer %p after reassignment, want %p", got, p2)
+	}
+	a.removePeer(p2)
+	if got := a.subnets(p2); len(got) != 0 {
+		t.Errorf("got subnets %v after removal, want none", got)
+	}
+}
+
+func TestMain(m *testing.M) {
+	refs.SetLeakMode(refs.LeaksPanic)
+	code := m.Run()
+	refs.DoLeakCheck()
+	os.Exit(code)
+}
@@ -793,7 +793,10 @@ func (e *endpoint) forwardUnicastPacket(pkt *stack.PacketBuffer) ip.ForwardingEr
 		return nil
 	}
 
-	r, err := stk.FindRoute(0, tcpip.Address{}, dstAddr, ProtocolNumber, false /* multicastLoop */)
+	r, err := stk.FindRouteWithSelector(0, tcpip.Address{}, dstAddr, ProtocolNumber, false /* multicastLoop */, tcpip.RouteSelector{
+		Source:   h.SourceAddress(),
+		InputNIC: e.nic.ID(),
+	})
 	switch err.(type) {
 	case nil:
 	// TODO(https://gvisor.dev/issues/8105): We should not observe ErrHostUnreachable from route
@@ -1495,6 +1498,13 @@ func (e *endpoint) IsInGroup(addr tcpip.Address) bool {
 	return e.igmp.isInGroup(addr) // +checklocksforce: e.mu==e.igmp.ep.mu.
 }
 
+// JoinedGroups implements stack.GroupAddressableEndpoint.
+func (e *endpoint) JoinedGroups() map[tcpip.Address]uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.igmp.joinedGroups() // +checklocksforce: e.mu==e.igmp.ep.mu.
+}
+
 // Stats implements stack.NetworkEndpoint.
 func (e *endpoint) Stats() stack.NetworkEndpointStats {
 	return &e.stats.localStats
@@ -1015,7 +1015,10 @@ func (e *endpoint) forwardUnicastPacket(pkt *stack.PacketBuffer) ip.ForwardingEr
 		return &ip.ErrParameterProblem{}
 	}
 
-	r, err := stk.FindRoute(0, tcpip.Address{}, dstAddr, ProtocolNumber, false /* multicastLoop */)
+	r, err := stk.FindRouteWithSelector(0, tcpip.Address{}, dstAddr, ProtocolNumber, false /* multicastLoop */, tcpip.RouteSelector{
+		Source:   h.SourceAddress(),
+		InputNIC: e.nic.ID(),
+	})
 	switch err.(type) {
 	case nil:
 	// TODO(https://gvisor.dev/issues/8105): We should not observe ErrHostUnreachable from route
@@ -2286,6 +2289,13 @@ func (e *endpoint) IsInGroup(addr tcpip.Address) bool {
 	return e.mu.mld.isInGroup(addr)
 }
 
+// JoinedGroups implements stack.GroupAddressableEndpoint.
+func (e *endpoint) JoinedGroups() map[tcpip.Address]uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.mu.mld.joinedGroups()
+}
+
 // Stats implements stack.NetworkEndpoint.
 func (e *endpoint) Stats() stack.NetworkEndpointStats {
 	return &e.stats.localStats
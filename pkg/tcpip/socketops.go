@@ -237,6 +237,9 @@ type SocketOptions struct {
 	// bindToDevice determines the device to which the socket is bound.
 	bindToDevice atomicbitops.Int32
 
+	// mark is the SO_MARK of the socket. It is used to select routing tables.
+	mark atomicbitops.Uint32
+
 	// getSendBufferLimits provides the handler to get the min, default and max
 	// size for send buffer. It is initialized at the creation time and will not
 	// change.
@@ -703,6 +706,16 @@ func (so *SocketOptions) SetBindToDevice(bindToDevice int32) Error {
 	return nil
 }
 
+// GetMark gets value for SO_MARK option.
+func (so *SocketOptions) GetMark() uint32 {
+	return so.mark.Load()
+}
+
+// SetMark sets value for SO_MARK option.
+func (so *SocketOptions) SetMark(v uint32) {
+	so.mark.Store(v)
+}
+
 // GetSendBufferSize gets value for SO_SNDBUF option.
 func (so *SocketOptions) GetSendBufferSize() int64 {
 	return so.sendBufferSize.Load()
@@ -755,6 +755,22 @@ func (n *nic) isInGroup(addr tcpip.Address) bool {
 	return false
 }
 
+// joinedGroups returns the multicast groups n has joined for the given
+// protocol.
+func (n *nic) joinedGroups(protocol tcpip.NetworkProtocolNumber) (map[tcpip.Address]uint64, tcpip.Error) {
+	ep := n.getNetworkEndpoint(protocol)
+	if ep == nil {
+		return nil, &tcpip.ErrNotSupported{}
+	}
+
+	gep, ok := ep.(GroupAddressableEndpoint)
+	if !ok {
+		return nil, &tcpip.ErrNotSupported{}
+	}
+
+	return gep.JoinedGroups(), nil
+}
+
 // DeliverNetworkPacket finds the appropriate network protocol endpoint and
 // hands the packet over for further processing. This function is called when
 // the NIC receives a packet from the link endpoint.
@@ -812,6 +828,7 @@ func (n *nic) DeliverLinkPacket(protocol tcpip.NetworkProtocolNumber, pkt *Packe
 			// packet endpoints inspect link headers.
 			packetEPPkt.LinkHeader().Consume(len(pkt.LinkHeader().Slice()))
 			packetEPPkt.PktType = pkt.PktType
+			packetEPPkt.Owner = pkt.Owner
 			// Assume the packet is for us if the packet type is unset.
 			// The packet type is set to PacketOutgoing when sending packets so
 			// this may only be unset for incoming packets where link endpoints
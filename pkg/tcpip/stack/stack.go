@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sort"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -88,10 +89,17 @@ type Stack struct {
 	// routeMu protects annotated fields below.
 	routeMu routeStackRWMutex `state:"nosave"`
 
-	// routeTable is a list of routes sorted by prefix length, longest (most specific) first.
+	// routeTable is a list of routes sorted by prefix length, longest (most
+	// specific) first, and then by metric, lowest first. It holds the routes
+	// of all routing tables.
 	// +checklocks:routeMu
 	routeTable tcpip.RouteList `state:"nosave"`
 
+	// routingRules is the list of policy routing rules sorted by priority. If
+	// nil, defaultRoutingRules are used.
+	// +checklocks:routeMu
+	routingRules []tcpip.RoutingRule `state:"nosave"`
+
 	mu stackRWMutex `state:"nosave"`
 	// +checklocks:mu
 	nics map[tcpip.NICID]*nic `state:"nosave"`
@@ -774,7 +782,7 @@ func (s *Stack) addRouteLocked(route *tcpip.Route) {
 	routePrefix := route.Destination.Prefix()
 	n := s.routeTable.Front()
 	for ; n != nil; n = n.Next() {
-		if n.Destination.Prefix() < routePrefix {
+		if n.Destination.Prefix() < routePrefix || (n.Destination.Prefix() == routePrefix && n.Metric > route.Metric) {
 			s.routeTable.InsertBefore(n, route)
 			return
 		}
@@ -782,6 +790,78 @@ func (s *Stack) addRouteLocked(route *tcpip.Route) {
 	s.routeTable.PushBack(route)
 }
 
+// defaultRoutingRules are the routing rules used until rules are modified.
+// They match Linux's default rules, but apply to all network protocols.
+var defaultRoutingRules = []tcpip.RoutingRule{
+	{Priority: 0, Action: tcpip.RoutingRuleLookup, Table: tcpip.LocalRouteTable},
+	{Priority: 32766, Action: tcpip.RoutingRuleLookup, Table: tcpip.MainRouteTable},
+	{Priority: 32767, Action: tcpip.RoutingRuleLookup, Table: tcpip.DefaultRouteTable},
+}
+
+// +checklocksread:s.routeMu
+func (s *Stack) routingRulesRLocked() []tcpip.RoutingRule {
+	if s.routingRules == nil {
+		return defaultRoutingRules
+	}
+	return s.routingRules
+}
+
+// GetRoutingRules returns the policy routing rules, sorted by priority.
+func (s *Stack) GetRoutingRules() []tcpip.RoutingRule {
+	s.routeMu.RLock()
+	defer s.routeMu.RUnlock()
+	return append([]tcpip.RoutingRule(nil), s.routingRulesRLocked()...)
+}
+
+// SetRoutingRules replaces the policy routing rules.
+func (s *Stack) SetRoutingRules(rules []tcpip.RoutingRule) {
+	s.routeMu.Lock()
+	defer s.routeMu.Unlock()
+	s.routingRules = make([]tcpip.RoutingRule, 0, len(rules))
+	for _, r := range rules {
+		s.addRoutingRuleLocked(r)
+	}
+}
+
+// AddRoutingRule adds a policy routing rule. Rules with equal priorities are
+// evaluated in the order they were added.
+func (s *Stack) AddRoutingRule(rule tcpip.RoutingRule) {
+	s.routeMu.Lock()
+	defer s.routeMu.Unlock()
+	if s.routingRules == nil {
+		s.routingRules = append([]tcpip.RoutingRule(nil), defaultRoutingRules...)
+	}
+	s.addRoutingRuleLocked(rule)
+}
+
+// +checklocks:s.routeMu
+func (s *Stack) addRoutingRuleLocked(rule tcpip.RoutingRule) {
+	i := sort.Search(len(s.routingRules), func(i int) bool {
+		return s.routingRules[i].Priority > rule.Priority
+	})
+	s.routingRules = append(s.routingRules, tcpip.RoutingRule{})
+	copy(s.routingRules[i+1:], s.routingRules[i:])
+	s.routingRules[i] = rule
+}
+
+// RemoveRoutingRules removes matching policy routing rules. It returns the
+// number of rules that are removed.
+func (s *Stack) RemoveRoutingRules(match func(tcpip.RoutingRule) bool) int {
+	s.routeMu.Lock()
+	defer s.routeMu.Unlock()
+	rules := make([]tcpip.RoutingRule, 0, len(s.routingRulesRLocked()))
+	for _, r := range s.routingRulesRLocked() {
+		if !match(r) {
+			rules = append(rules, r)
+		}
+	}
+	removed := len(s.routingRulesRLocked()) - len(rules)
+	if removed != 0 {
+		s.routingRules = rules
+	}
+	return removed
+}
+
 // RemoveRoutes removes matching routes from the route table, it
 // returns the number of routes that are removed.
 func (s *Stack) RemoveRoutes(match func(tcpip.Route) bool) int {
@@ -1457,6 +1537,17 @@ func (s *Stack) findRouteWithLocalAddrFromAnyInterfaceRLocked(outgoingNIC *nic,
 // FindRoute creates a route to the given destination address, leaving through
 // the given NIC and local address (if provided).
 //
+// FindRoute is equivalent to FindRouteWithSelector with an empty selector.
+func (s *Stack) FindRoute(id tcpip.NICID, localAddr, remoteAddr tcpip.Address, netProto tcpip.NetworkProtocolNumber, multicastLoop bool) (*Route, tcpip.Error) {
+	return s.FindRouteWithSelector(id, localAddr, remoteAddr, netProto, multicastLoop, tcpip.RouteSelector{})
+}
+
+// FindRouteWithSelector creates a route to the given destination address,
+// leaving through the given NIC and local address (if provided).
+//
+// The routing tables consulted are chosen by evaluating the policy routing
+// rules against the addresses, the NIC and sel.
+//
 // If a NIC is not specified, the returned route will leave through the same
 // NIC as the NIC that has the local address assigned when forwarding is
 // disabled. If forwarding is enabled and the NIC is unspecified, the route may
@@ -1465,7 +1556,7 @@ func (s *Stack) findRouteWithLocalAddrFromAnyInterfaceRLocked(outgoingNIC *nic,
 // If no local address is provided, the stack will select a local address. If no
 // remote address is provided, the stack will use a remote address equal to the
 // local address.
-func (s *Stack) FindRoute(id tcpip.NICID, localAddr, remoteAddr tcpip.Address, netProto tcpip.NetworkProtocolNumber, multicastLoop bool) (*Route, tcpip.Error) {
+func (s *Stack) FindRouteWithSelector(id tcpip.NICID, localAddr, remoteAddr tcpip.Address, netProto tcpip.NetworkProtocolNumber, multicastLoop bool, sel tcpip.RouteSelector) (*Route, tcpip.Error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -1514,63 +1605,103 @@ func (s *Stack) FindRoute(id tcpip.NICID, localAddr, remoteAddr tcpip.Address, n
 
 	onlyGlobalAddresses := !header.IsV6LinkLocalUnicastAddress(localAddr) && !isLinkLocal
 
-	// Find a route to the remote with the route table.
+	// Find a route to the remote with the route tables chosen by the routing
+	// rules.
 	var chosenRoute tcpip.Route
-	if r := func() *Route {
+	src := sel.Source
+	if src.BitLen() == 0 {
+		src = localAddr
+	}
+	if r, err := func() (*Route, tcpip.Error) {
 		s.routeMu.RLock()
 		defer s.routeMu.RUnlock()
 
-		for route := s.routeTable.Front(); route != nil; route = route.Next() {
-			if remoteAddr.BitLen() != 0 && !route.Destination.Contains(remoteAddr) {
-				continue
-			}
+		lookupTable := func(table uint32) *Route {
+			for route := s.routeTable.Front(); route != nil; route = route.Next() {
+				if route.TableID() != table {
+					continue
+				}
+				if remoteAddr.BitLen() != 0 && !route.Destination.Contains(remoteAddr) {
+					continue
+				}
 
-			nic, ok := s.nics[route.NIC]
-			if !ok || !nic.Enabled() {
-				continue
-			}
+				nic, ok := s.nics[route.NIC]
+				if !ok || !nic.Enabled() {
+					continue
+				}
 
-			if id == 0 || id == route.NIC {
-				if addressEndpoint := s.getAddressEP(nic, localAddr, remoteAddr, route.SourceHint, netProto); addressEndpoint != nil {
-					var gateway tcpip.Address
-					if needRoute {
-						gateway = route.Gateway
+				if id == 0 || id == route.NIC {
+					if addressEndpoint := s.getAddressEP(nic, localAddr, remoteAddr, route.SourceHint, netProto); addressEndpoint != nil {
+						var gateway tcpip.Address
+						if needRoute {
+							gateway = route.Gateway
+						}
+						r := constructAndValidateRoute(netProto, addressEndpoint, nic /* outgoingNIC */, nic /* outgoingNIC */, gateway, localAddr, remoteAddr, s.handleLocal, multicastLoop, route.MTU)
+						if r == nil {
+							panic(fmt.Sprintf("non-forwarding route validation failed with route table entry = %#v, id = %d, localAddr = %s, remoteAddr = %s", route, id, localAddr, remoteAddr))
+						}
+						return r
 					}
-					r := constructAndValidateRoute(netProto, addressEndpoint, nic /* outgoingNIC */, nic /* outgoingNIC */, gateway, localAddr, remoteAddr, s.handleLocal, multicastLoop, route.MTU)
-					if r == nil {
-						panic(fmt.Sprintf("non-forwarding route validation failed with route table entry = %#v, id = %d, localAddr = %s, remoteAddr = %s", route, id, localAddr, remoteAddr))
+				}
+
+				// If the stack has forwarding enabled, we haven't found a valid route to
+				// the remote address yet, and we are routing locally generated traffic,
+				// keep track of the first valid route. We keep iterating because we
+				// prefer routes that let us use a local address that is assigned to the
+				// outgoing interface. There is no requirement to do this from any RFC
+				// but simply a choice made to better follow a strong host model which
+				// the netstack follows at the time of writing.
+				//
+				// Note that for incoming traffic that we are forwarding (for which the
+				// NIC and local address are unspecified), we do not keep iterating, as
+				// there is no reason to prefer routes that let us use a local address
+				// when routing forwarded (as opposed to locally-generated) traffic.
+				locallyGenerated := (id != 0 || localAddr != tcpip.Address{})
+				if onlyGlobalAddresses && chosenRoute.Equal(tcpip.Route{}) && isNICForwarding(nic, netProto) {
+					if locallyGenerated {
+						chosenRoute = *route
+						continue
+					}
+
+					if r := s.findRouteWithLocalAddrFromAnyInterfaceRLocked(nic, localAddr, remoteAddr, route.SourceHint, route.Gateway, netProto, multicastLoop, route.MTU); r != nil {
+						return r
 					}
-					return r
 				}
 			}
 
-			// If the stack has forwarding enabled, we haven't found a valid route to
-			// the remote address yet, and we are routing locally generated traffic,
-			// keep track of the first valid route. We keep iterating because we
-			// prefer routes that let us use a local address that is assigned to the
-			// outgoing interface. There is no requirement to do this from any RFC
-			// but simply a choice made to better follow a strong host model which
-			// the netstack follows at the time of writing.
-			//
-			// Note that for incoming traffic that we are forwarding (for which the
-			// NIC and local address are unspecified), we do not keep iterating, as
-			// there is no reason to prefer routes that let us use a local address
-			// when routing forwarded (as opposed to locally-generated) traffic.
-			locallyGenerated := (id != 0 || localAddr != tcpip.Address{})
-			if onlyGlobalAddresses && chosenRoute.Equal(tcpip.Route{}) && isNICForwarding(nic, netProto) {
-				if locallyGenerated {
-					chosenRoute = *route
-					continue
-				}
+			return nil
+		}
 
-				if r := s.findRouteWithLocalAddrFromAnyInterfaceRLocked(nic, localAddr, remoteAddr, route.SourceHint, route.Gateway, netProto, multicastLoop, route.MTU); r != nil {
-					return r
+		rules := s.routingRulesRLocked()
+		for i := range rules {
+			rule := &rules[i]
+			if rule.Protocol != 0 && rule.Protocol != netProto {
+				continue
+			}
+			if !rule.Matches(src, remoteAddr, id, sel) {
+				continue
+			}
+			switch rule.Action {
+			case tcpip.RoutingRuleLookup:
+				if r := lookupTable(rule.Table); r != nil {
+					return r, nil
 				}
+				if !chosenRoute.Equal(tcpip.Route{}) {
+					// The table has a route that requires forwarding.
+					return nil, nil
+				}
+			case tcpip.RoutingRuleBlackhole:
+				return nil, &tcpip.ErrInvalidEndpointState{}
+			case tcpip.RoutingRuleUnreachable:
+				return nil, &tcpip.ErrHostUnreachable{}
+			case tcpip.RoutingRuleProhibit:
+				return nil, &tcpip.ErrNotPermitted{}
 			}
 		}
-
-		return nil
-	}(); r != nil {
+		return nil, nil
+	}(); err != nil {
+		return nil, err
+	} else if r != nil {
 		return r, nil
 	}
 
@@ -2191,6 +2322,18 @@ func (s *Stack) IsInGroup(nicID tcpip.NICID, multicastAddr tcpip.Address) (bool,
 	return false, &tcpip.ErrUnknownNICID{}
 }
 
+// JoinedGroups returns the multicast groups the NIC with ID nicID has joined
+// for the given protocol, mapped to the number of times each group was joined.
+func (s *Stack) JoinedGroups(protocol tcpip.NetworkProtocolNumber, nicID tcpip.NICID) (map[tcpip.Address]uint64, tcpip.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if nic, ok := s.nics[nicID]; ok {
+		return nic.joinedGroups(protocol)
+	}
+	return nil, &tcpip.ErrUnknownNICID{}
+}
+
 // IPTables returns the stack's iptables.
 func (s *Stack) IPTables() *IPTables {
 	return s.tables
@@ -20,6 +20,7 @@ package stack_test
 import (
 	"bytes"
 	"fmt"
+	"maps"
 	"math"
 	"net"
 	"sort"
@@ -3718,6 +3719,52 @@ func TestLeaveIPv6SolicitedNodeAddrBeforeAddrRemoval(t *testing.T) {
 	}
 }
 
+func TestJoinedGroups(t *testing.T) {
+	const nicID = 1
+	group := tcpip.AddrFrom4([4]byte{224, 0, 0, 5})
+
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+	})
+	e := channel.New(10, defaultMTU, linkAddr1)
+	if err := s.CreateNIC(nicID, e); err != nil {
+		t.Fatalf("CreateNIC(%d, _): %s", nicID, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.JoinGroup(ipv4.ProtocolNumber, nicID, group); err != nil {
+			t.Fatalf("JoinGroup(%d, %d, %s): %s", ipv4.ProtocolNumber, nicID, group, err)
+		}
+	}
+	want := map[tcpip.Address]uint64{
+		header.IPv4AllSystems: 1,
+		group:                 2,
+	}
+	got, err := s.JoinedGroups(ipv4.ProtocolNumber, nicID)
+	if err != nil {
+		t.Fatalf("JoinedGroups(%d, %d): %s", ipv4.ProtocolNumber, nicID, err)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got JoinedGroups(%d, %d) = %v, want = %v", ipv4.ProtocolNumber, nicID, got, want)
+	}
+
+	if err := s.LeaveGroup(ipv4.ProtocolNumber, nicID, group); err != nil {
+		t.Fatalf("LeaveGroup(%d, %d, %s): %s", ipv4.ProtocolNumber, nicID, group, err)
+	}
+	want[group] = 1
+	got, err = s.JoinedGroups(ipv4.ProtocolNumber, nicID)
+	if err != nil {
+		t.Fatalf("JoinedGroups(%d, %d): %s", ipv4.ProtocolNumber, nicID, err)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got JoinedGroups(%d, %d) = %v, want = %v", ipv4.ProtocolNumber, nicID, got, want)
+	}
+
+	if _, err := s.JoinedGroups(ipv4.ProtocolNumber, nicID+1); err == nil {
+		t.Errorf("JoinedGroups(%d, %d) succeeded for an unknown NIC", ipv4.ProtocolNumber, nicID+1)
+	}
+}
+
 func TestJoinLeaveMulticastOnNICEnableDisable(t *testing.T) {
 	const nicID = 1
 
@@ -4478,6 +4525,154 @@ func TestRemoveRoutes(t *testing.T) {
 	}
 }
 
+// TestAddRouteMetric tests that routes with equal prefixes are ordered by
+// metric.
+func TestAddRouteMetric(t *testing.T) {
+	s := stack.New(stack.Options{})
+
+	subnet, err := tcpip.NewSubnet(tcpip.AddrFromSlice([]byte("\x00\x00\x00\x00")), tcpip.MaskFrom("\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.AddRoute(tcpip.Route{Destination: subnet, NIC: 1, Metric: 200})
+	s.AddRoute(tcpip.Route{Destination: subnet, NIC: 2, Metric: 100})
+
+	rt := s.GetRouteTable()
+	if got, want := len(rt), 2; got != want {
+		t.Fatalf("Unexpected route table length got = %d, want = %d", got, want)
+	}
+	if got, want := rt[0].NIC, tcpip.NICID(2); got != want {
+		t.Errorf("Unexpected NIC of first route got = %d, want = %d", got, want)
+	}
+}
+
+func TestPolicyRouting(t *testing.T) {
+	const (
+		nicID1 = 1
+		nicID2 = 2
+		table  = 100
+	)
+
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{fakeNetFactory},
+	})
+
+	addr1 := tcpip.AddrFromSlice([]byte("\x01\x00\x00\x00"))
+	addr2 := tcpip.AddrFromSlice([]byte("\x02\x00\x00\x00"))
+	for _, nic := range []struct {
+		id   tcpip.NICID
+		addr tcpip.Address
+	}{
+		{nicID1, addr1},
+		{nicID2, addr2},
+	} {
+		if err := s.CreateNIC(nic.id, channel.New(10, defaultMTU, "")); err != nil {
+			t.Fatalf("CreateNIC(%d, _): %s", nic.id, err)
+		}
+		protocolAddr := tcpip.ProtocolAddress{
+			Protocol:          fakeNetNumber,
+			AddressWithPrefix: nic.addr.WithPrefix(),
+		}
+		if err := s.AddProtocolAddress(nic.id, protocolAddr, stack.AddressProperties{}); err != nil {
+			t.Fatalf("AddProtocolAddress(%d, %+v, {}): %s", nic.id, protocolAddr, err)
+		}
+	}
+
+	anySubnet, err := tcpip.NewSubnet(tcpip.AddrFromSlice([]byte("\x00\x00\x00\x00")), tcpip.MaskFrom("\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachableSubnet, err := tcpip.NewSubnet(tcpip.AddrFromSlice([]byte("\x09\x00\x00\x00")), tcpip.MaskFrom("\xff\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The main table routes everything through the first NIC, and the other
+	// table routes everything through the second NIC.
+	s.SetRouteTable([]tcpip.Route{
+		{Destination: anySubnet, NIC: nicID1},
+		{Destination: anySubnet, NIC: nicID2, Table: table},
+	})
+	s.AddRoutingRule(tcpip.RoutingRule{Priority: 100, Action: tcpip.RoutingRuleLookup, Table: table, Mark: 0x10})
+	s.AddRoutingRule(tcpip.RoutingRule{Priority: 200, Action: tcpip.RoutingRuleLookup, Table: table, MatchUID: true, UIDStart: 1000, UIDEnd: 1999})
+	s.AddRoutingRule(tcpip.RoutingRule{Priority: 300, Action: tcpip.RoutingRuleUnreachable, Destination: unreachableSubnet})
+
+	dst := tcpip.AddrFromSlice([]byte("\x05\x00\x00\x00"))
+	tests := []struct {
+		name     string
+		dst      tcpip.Address
+		sel      tcpip.RouteSelector
+		wantNIC  tcpip.NICID
+		wantAddr tcpip.Address
+		wantErr  tcpip.Error
+	}{
+		{
+			name:     "main table",
+			dst:      dst,
+			wantNIC:  nicID1,
+			wantAddr: addr1,
+		},
+		{
+			name:     "fwmark",
+			dst:      dst,
+			sel:      tcpip.RouteSelector{Mark: 0x10},
+			wantNIC:  nicID2,
+			wantAddr: addr2,
+		},
+		{
+			name:     "other fwmark",
+			dst:      dst,
+			sel:      tcpip.RouteSelector{Mark: 0x20},
+			wantNIC:  nicID1,
+			wantAddr: addr1,
+		},
+		{
+			name:     "uid range",
+			dst:      dst,
+			sel:      tcpip.RouteSelector{UID: 1500},
+			wantNIC:  nicID2,
+			wantAddr: addr2,
+		},
+		{
+			name:    "unreachable",
+			dst:     tcpip.AddrFromSlice([]byte("\x09\x00\x00\x01")),
+			wantErr: &tcpip.ErrHostUnreachable{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := s.FindRouteWithSelector(0, tcpip.Address{}, test.dst, fakeNetNumber, false /* multicastLoop */, test.sel)
+			if err != test.wantErr {
+				t.Fatalf("FindRouteWithSelector(...) = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer r.Release()
+			if got := r.NICID(); got != test.wantNIC {
+				t.Errorf("got r.NICID() = %d, want %d", got, test.wantNIC)
+			}
+			if got := r.LocalAddress(); got != test.wantAddr {
+				t.Errorf("got r.LocalAddress() = %s, want %s", got, test.wantAddr)
+			}
+		})
+	}
+
+	// Removing the rules restores the default behavior.
+	if got, want := s.RemoveRoutingRules(func(r tcpip.RoutingRule) bool { return r.Table == table }), 2; got != want {
+		t.Errorf("RemoveRoutingRules(_) = %d, want %d", got, want)
+	}
+	r, err := s.FindRouteWithSelector(0, tcpip.Address{}, dst, fakeNetNumber, false /* multicastLoop */, tcpip.RouteSelector{Mark: 0x10})
+	if err != nil {
+		t.Fatalf("FindRouteWithSelector(...): %s", err)
+	}
+	defer r.Release()
+	if got := r.NICID(); got != nicID1 {
+		t.Errorf("got r.NICID() = %d, want %d", got, nicID1)
+	}
+}
+
 func TestFindRouteWithForwarding(t *testing.T) {
 	const (
 		nicID1 = 1
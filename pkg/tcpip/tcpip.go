@@ -1281,6 +1281,10 @@ func (*KeepaliveIdleOption) isGettableSocketOption() {}
 
 func (*KeepaliveIdleOption) isSettableSocketOption() {}
 
+func (*KeepaliveIdleOption) isGettableTransportProtocolOption() {}
+
+func (*KeepaliveIdleOption) isSettableTransportProtocolOption() {}
+
 // KeepaliveIntervalOption is used by SetSockOpt/GetSockOpt to specify the
 // interval between sending TCP keepalive packets.
 type KeepaliveIntervalOption time.Duration
@@ -1289,6 +1293,27 @@ func (*KeepaliveIntervalOption) isGettableSocketOption() {}
 
 func (*KeepaliveIntervalOption) isSettableSocketOption() {}
 
+func (*KeepaliveIntervalOption) isGettableTransportProtocolOption() {}
+
+func (*KeepaliveIntervalOption) isSettableTransportProtocolOption() {}
+
+// TCPKeepaliveCountOption is used by stack.(*Stack).TransportProtocolOption
+// to specify the stack-wide default number of unacknowledged TCP keepalive
+// probes sent before the connection is dropped.
+type TCPKeepaliveCountOption int
+
+func (*TCPKeepaliveCountOption) isGettableTransportProtocolOption() {}
+
+func (*TCPKeepaliveCountOption) isSettableTransportProtocolOption() {}
+
+// TCPTimestampsEnabled is used by stack.(*Stack).TransportProtocolOption to
+// enable/disable the TCP timestamps option (RFC 7323) on new connections.
+type TCPTimestampsEnabled bool
+
+func (*TCPTimestampsEnabled) isGettableTransportProtocolOption() {}
+
+func (*TCPTimestampsEnabled) isSettableTransportProtocolOption() {}
+
 // TCPUserTimeoutOption is used by SetSockOpt/GetSockOpt to specify a user
 // specified timeout for a given TCP connection.
 // See: RFC5482 for details.
@@ -1574,6 +1599,37 @@ type Route struct {
 	// If MTU is 0, this field is ignored and the MTU of the NIC for which this route
 	// is configured is used for egress packets.
 	MTU uint32
+
+	// Table is the ID of the routing table this route belongs to. Zero is
+	// treated as MainRouteTable.
+	Table uint32
+
+	// Metric is the priority of this route. Among routes with the same prefix
+	// length, routes with lower metrics are preferred.
+	Metric uint32
+}
+
+// Well-known routing table IDs, as in Linux.
+const (
+	// DefaultRouteTable is the table looked up by the lowest priority default
+	// rule. It is empty unless routes are added to it.
+	DefaultRouteTable uint32 = 253
+
+	// MainRouteTable is the table that routes are added to unless a table is
+	// specified.
+	MainRouteTable uint32 = 254
+
+	// LocalRouteTable is reserved for local and broadcast routes. Netstack
+	// handles local traffic without consulting routing tables.
+	LocalRouteTable uint32 = 255
+)
+
+// TableID returns the ID of the routing table r belongs to.
+func (r Route) TableID() uint32 {
+	if r.Table == 0 {
+		return MainRouteTable
+	}
+	return r.Table
 }
 
 // String implements the fmt.Stringer interface.
@@ -1590,7 +1646,126 @@ func (r Route) String() string {
 // Equal returns true if the given Route is equal to this Route.
 func (r Route) Equal(to Route) bool {
 	// NOTE: This relies on the fact that r.Destination == to.Destination
-	return r.Destination.Equal(to.Destination) && r.NIC == to.NIC
+	return r.Destination.Equal(to.Destination) && r.NIC == to.NIC && r.TableID() == to.TableID() && r.Metric == to.Metric
+}
+
+// RoutingRuleAction is the action taken when a RoutingRule matches.
+type RoutingRuleAction uint8
+
+// Routing rule actions. The values match Linux's FR_ACT_* constants.
+const (
+	// RoutingRuleLookup looks up the route in the rule's table. If the table
+	// has no matching route, the next rule is considered.
+	RoutingRuleLookup RoutingRuleAction = 1
+
+	// RoutingRuleBlackhole silently discards matching packets.
+	RoutingRuleBlackhole RoutingRuleAction = 6
+
+	// RoutingRuleUnreachable fails matching lookups with
+	// ErrHostUnreachable.
+	RoutingRuleUnreachable RoutingRuleAction = 7
+
+	// RoutingRuleProhibit fails matching lookups with
+	// ErrNetworkUnreachable.
+	RoutingRuleProhibit RoutingRuleAction = 8
+)
+
+// RoutingRule is a policy routing rule. Rules are evaluated in order of
+// increasing priority to choose the routing table used for a lookup.
+//
+// A rule matches a lookup if all of its selectors match. Selectors left at
+// their zero value match everything.
+//
+// +stateify savable
+type RoutingRule struct {
+	// Priority determines the order in which rules are evaluated. Lower
+	// values are evaluated first.
+	Priority uint32
+
+	// Protocol, if non-zero, is the network protocol the rule applies to.
+	Protocol NetworkProtocolNumber
+
+	// Action is the action taken if the rule matches.
+	Action RoutingRuleAction
+
+	// Table is the routing table looked up if Action is RoutingRuleLookup.
+	Table uint32
+
+	// Invert negates the result of matching the selectors below.
+	Invert bool
+
+	// Source, if its prefix is non-zero, must contain the source address.
+	Source Subnet
+
+	// Destination, if its prefix is non-zero, must contain the destination
+	// address.
+	Destination Subnet
+
+	// InputNIC, if non-zero, must be the NIC forwarded packets arrived on.
+	// Locally generated traffic does not match rules with an InputNIC.
+	InputNIC NICID
+
+	// OutputNIC, if non-zero, must be the NIC the socket is bound to.
+	OutputNIC NICID
+
+	// Mark is compared against the masked SO_MARK of the socket. MarkMask
+	// defaults to all ones if Mark is non-zero and MarkMask is zero.
+	Mark     uint32
+	MarkMask uint32
+
+	// MatchUID indicates that the socket owner's UID must be within
+	// [UIDStart, UIDEnd].
+	MatchUID bool
+	UIDStart uint32
+	UIDEnd   uint32
+}
+
+// RouteSelector holds the attributes of a route lookup that routing rules
+// may match on, in addition to the addresses and NIC passed to the lookup.
+type RouteSelector struct {
+	// Source is the source address of forwarded packets. If empty, the local
+	// address of the lookup is used.
+	Source Address
+
+	// InputNIC is the NIC forwarded packets arrived on.
+	InputNIC NICID
+
+	// Mark is the SO_MARK of the socket performing the lookup.
+	Mark uint32
+
+	// UID is the owner of the socket performing the lookup.
+	UID uint32
+}
+
+// Matches returns true if the rule applies to a lookup from src to dst via
+// outputNIC with the given selector.
+func (r *RoutingRule) Matches(src, dst Address, outputNIC NICID, sel RouteSelector) bool {
+	matches := func() bool {
+		if r.Source.Prefix() != 0 && (src.BitLen() == 0 || !r.Source.Contains(src)) {
+			return false
+		}
+		if r.Destination.Prefix() != 0 && !r.Destination.Contains(dst) {
+			return false
+		}
+		if r.InputNIC != 0 && r.InputNIC != sel.InputNIC {
+			return false
+		}
+		if r.OutputNIC != 0 && r.OutputNIC != outputNIC {
+			return false
+		}
+		mask := r.MarkMask
+		if mask == 0 && r.Mark != 0 {
+			mask = ^uint32(0)
+		}
+		if (sel.Mark^r.Mark)&mask != 0 {
+			return false
+		}
+		if r.MatchUID && (sel.UID < r.UIDStart || sel.UID > r.UIDEnd) {
+			return false
+		}
+		return true
+	}()
+	return matches != r.Invert
 }
 
 // TransportProtocolNumber is the number of a transport protocol.
@@ -200,6 +200,13 @@ func (e *Endpoint) SetOwner(owner tcpip.PacketOwner) {
 	e.owner = owner
 }
 
+// Owner returns the owner of transmitted packets.
+func (e *Endpoint) Owner() tcpip.PacketOwner {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.owner
+}
+
 // +checklocksread:e.mu
 func (e *Endpoint) calculateTTL(route *stack.Route) uint8 {
 	remoteAddress := route.RemoteAddress()
@@ -624,7 +631,11 @@ func (e *Endpoint) connectRouteRLocked(nicID tcpip.NICID, localAddr tcpip.Addres
 	}
 
 	// Find a route to the desired destination.
-	r, err := e.stack.FindRoute(nicID, localAddr, addr.Addr, netProto, e.ops.GetMulticastLoop())
+	sel := tcpip.RouteSelector{Mark: e.ops.GetMark()}
+	if e.owner != nil {
+		sel.UID = e.owner.KUID()
+	}
+	r, err := e.stack.FindRouteWithSelector(nicID, localAddr, addr.Addr, netProto, e.ops.GetMulticastLoop(), sel)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -514,11 +514,11 @@ func (e *Endpoint) handleListenSegment(ctx *listenContext, s *segment) tcpip.Err
 		// new connections, if available.
 		synOpts := header.TCPSynOptions{
 			WS:    -1,
-			TS:    opts.TS,
+			TS:    opts.TS && e.protocol.timestamps(),
 			TSEcr: opts.TSVal,
 			MSS:   calculateAdvertisedMSS(e.userMSS, route),
 		}
-		if opts.TS {
+		if synOpts.TS {
 			offset := e.protocol.tsOffset(net.DestinationAddress(), net.SourceAddress())
 			now := e.stack.Clock().NowMonotonic()
 			synOpts.TSVal = offset.TSVal(now)
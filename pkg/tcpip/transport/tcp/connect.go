@@ -350,7 +350,7 @@ func (h *handshake) synSentState(s *segment) tcpip.Error {
 	h.ep.setEndpointState(StateSynRecv)
 	synOpts := header.TCPSynOptions{
 		WS:    int(h.effectiveRcvWndScale()),
-		TS:    rcvSynOpts.TS,
+		TS:    h.ep.SendTSOk,
 		TSVal: h.ep.tsValNow(),
 		TSEcr: h.ep.recentTimestamp(),
 
@@ -568,7 +568,7 @@ func (h *handshake) start() {
 
 	synOpts := header.TCPSynOptions{
 		WS:            h.rcvWndScale,
-		TS:            true,
+		TS:            h.ep.protocol.timestamps(),
 		TSVal:         h.ep.tsValNow(),
 		TSEcr:         h.ep.recentTimestamp(),
 		SACKPermitted: bool(sackEnabled),
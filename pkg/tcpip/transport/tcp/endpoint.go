@@ -914,6 +914,21 @@ func newEndpoint(s *stack.Stack, protocol *protocol, netProto tcpip.NetworkProto
 		e.maxSynRetries = uint8(synRetries)
 	}
 
+	var keepaliveIdle tcpip.KeepaliveIdleOption
+	if err := s.TransportProtocolOption(ProtocolNumber, &keepaliveIdle); err == nil {
+		e.keepalive.idle = time.Duration(keepaliveIdle)
+	}
+
+	var keepaliveInterval tcpip.KeepaliveIntervalOption
+	if err := s.TransportProtocolOption(ProtocolNumber, &keepaliveInterval); err == nil {
+		e.keepalive.interval = time.Duration(keepaliveInterval)
+	}
+
+	var keepaliveCount tcpip.TCPKeepaliveCountOption
+	if err := s.TransportProtocolOption(ProtocolNumber, &keepaliveCount); err == nil {
+		e.keepalive.count = int(keepaliveCount)
+	}
+
 	e.probe = protocol.probe
 	e.segmentQueue.ep = e
 
@@ -1367,6 +1382,11 @@ func (e *Endpoint) SetOwner(owner tcpip.PacketOwner) {
 	e.owner = owner
 }
 
+// Owner returns the owner of the endpoint's packets, as set by SetOwner.
+func (e *Endpoint) Owner() tcpip.PacketOwner {
+	return e.owner
+}
+
 // +checklocks:e.mu
 func (e *Endpoint) hardErrorLocked() tcpip.Error {
 	err := e.hardError
@@ -2447,7 +2467,11 @@ func (e *Endpoint) connect(addr tcpip.FullAddress, handshake bool) tcpip.Error {
 	}
 
 	// Find a route to the desired destination.
-	r, err := e.stack.FindRoute(nicID, e.TransportEndpointInfo.ID.LocalAddress, addr.Addr, netProto, false /* multicastLoop */)
+	sel := tcpip.RouteSelector{Mark: e.ops.GetMark()}
+	if e.owner != nil {
+		sel.UID = e.owner.KUID()
+	}
+	r, err := e.stack.FindRouteWithSelector(nicID, e.TransportEndpointInfo.ID.LocalAddress, addr.Addr, netProto, false /* multicastLoop */, sel)
 	if err != nil {
 		return err
 	}
@@ -3122,7 +3146,7 @@ func (e *Endpoint) updateRecentTimestamp(tsVal uint32, maxSentAck seqnum.Value,
 // the SYN options indicate that timestamp option was negotiated. It also
 // initializes the recentTS with the value provided in synOpts.TSval.
 func (e *Endpoint) maybeEnableTimestamp(synOpts header.TCPSynOptions) {
-	if synOpts.TS {
+	if synOpts.TS && e.protocol.timestamps() {
 		e.SendTSOk = true
 		e.setRecentTimestamp(synOpts.TSVal)
 	}
@@ -91,6 +91,7 @@ type protocol struct {
 
 	mu                         protocolRWMutex `state:"nosave"`
 	sackEnabled                bool
+	timestampsEnabled          bool
 	recovery                   tcpip.TCPRecovery
 	delayEnabled               bool
 	alwaysUseSynCookies        bool
@@ -106,6 +107,9 @@ type protocol struct {
 	maxRTO                     time.Duration
 	maxRetries                 uint32
 	synRetries                 uint8
+	keepaliveIdle              time.Duration
+	keepaliveInterval          time.Duration
+	keepaliveCount             int
 	dispatcher                 dispatcher
 
 	// probe, if not nil, will be invoked any time an endpoint receives a
@@ -179,6 +183,14 @@ func (p *protocol) HandleUnknownDestinationPacket(id stack.TransportEndpointID,
 	return stack.UnknownDestinationPacketHandled
 }
 
+// timestamps returns whether the TCP timestamps option should be negotiated
+// on new connections.
+func (p *protocol) timestamps() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.timestampsEnabled
+}
+
 func (p *protocol) tsOffset(src, dst tcpip.Address) tcp.TSOffset {
 	// Initialize a random tsOffset that will be added to the recentTS
 	// everytime the timestamp is sent when the Timestamp option is enabled.
@@ -387,6 +399,39 @@ func (p *protocol) SetOption(option tcpip.SettableTransportProtocolOption) tcpip
 		p.mu.Unlock()
 		return nil
 
+	case *tcpip.TCPTimestampsEnabled:
+		p.mu.Lock()
+		p.timestampsEnabled = bool(*v)
+		p.mu.Unlock()
+		return nil
+
+	case *tcpip.KeepaliveIdleOption:
+		if *v <= 0 {
+			return &tcpip.ErrInvalidOptionValue{}
+		}
+		p.mu.Lock()
+		p.keepaliveIdle = time.Duration(*v)
+		p.mu.Unlock()
+		return nil
+
+	case *tcpip.KeepaliveIntervalOption:
+		if *v <= 0 {
+			return &tcpip.ErrInvalidOptionValue{}
+		}
+		p.mu.Lock()
+		p.keepaliveInterval = time.Duration(*v)
+		p.mu.Unlock()
+		return nil
+
+	case *tcpip.TCPKeepaliveCountOption:
+		if *v < 1 {
+			return &tcpip.ErrInvalidOptionValue{}
+		}
+		p.mu.Lock()
+		p.keepaliveCount = int(*v)
+		p.mu.Unlock()
+		return nil
+
 	default:
 		return &tcpip.ErrUnknownProtocolOption{}
 	}
@@ -491,6 +536,30 @@ func (p *protocol) Option(option tcpip.GettableTransportProtocolOption) tcpip.Er
 		p.mu.RUnlock()
 		return nil
 
+	case *tcpip.TCPTimestampsEnabled:
+		p.mu.RLock()
+		*v = tcpip.TCPTimestampsEnabled(p.timestampsEnabled)
+		p.mu.RUnlock()
+		return nil
+
+	case *tcpip.KeepaliveIdleOption:
+		p.mu.RLock()
+		*v = tcpip.KeepaliveIdleOption(p.keepaliveIdle)
+		p.mu.RUnlock()
+		return nil
+
+	case *tcpip.KeepaliveIntervalOption:
+		p.mu.RLock()
+		*v = tcpip.KeepaliveIntervalOption(p.keepaliveInterval)
+		p.mu.RUnlock()
+		return nil
+
+	case *tcpip.TCPKeepaliveCountOption:
+		p.mu.RLock()
+		*v = tcpip.TCPKeepaliveCountOption(p.keepaliveCount)
+		p.mu.RUnlock()
+		return nil
+
 	default:
 		return &tcpip.ErrUnknownProtocolOption{}
 	}
@@ -581,6 +650,7 @@ func newProtocol(s *stack.Stack, cc string, probe TCPProbeFunc) stack.TransportP
 			Max:     MaxBufferSize,
 		},
 		sackEnabled:                true,
+		timestampsEnabled:          true,
 		congestionControl:          cc,
 		availableCongestionControl: []string{ccReno, ccCubic},
 		moderateReceiveBuffer:      true,
@@ -588,6 +658,9 @@ func newProtocol(s *stack.Stack, cc string, probe TCPProbeFunc) stack.TransportP
 		timeWaitTimeout:            DefaultTCPTimeWaitTimeout,
 		timeWaitReuse:              tcpip.TCPTimeWaitReuseLoopbackOnly,
 		synRetries:                 DefaultSynRetries,
+		keepaliveIdle:              DefaultKeepaliveIdle,
+		keepaliveInterval:          DefaultKeepaliveInterval,
+		keepaliveCount:             DefaultKeepaliveCount,
 		minRTO:                     MinRTO,
 		maxRTO:                     MaxRTO,
 		maxRetries:                 MaxRetries,
@@ -1077,6 +1077,11 @@ func (e *endpoint) SetOwner(owner tcpip.PacketOwner) {
 	e.net.SetOwner(owner)
 }
 
+// Owner returns the owner of the endpoint's packets, as set by SetOwner.
+func (e *endpoint) Owner() tcpip.PacketOwner {
+	return e.net.Owner()
+}
+
 // SocketOptions implements tcpip.Endpoint.
 func (e *endpoint) SocketOptions() *tcpip.SocketOptions {
 	return &e.ops
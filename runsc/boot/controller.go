@@ -61,6 +61,10 @@ const (
 	// ContMgrEvent gets stats about the container used by "runsc events".
 	ContMgrEvent = "containerManager.Event"
 
+	// ContMgrContainerStats gets the resource usage of all containers, as
+	// accounted by the sentry.
+	ContMgrContainerStats = "containerManager.ContainerStats"
+
 	// ContMgrExecuteAsync executes a command in a container.
 	ContMgrExecuteAsync = "containerManager.ExecuteAsync"
 
@@ -131,6 +135,24 @@ const (
 	// NetworkInitPluginStack initializes third-party network stack.
 	NetworkInitPluginStack = "Network.InitPluginStack"
 
+	// NetworkAddLink adds a link to a running network stack.
+	NetworkAddLink = "Network.AddLink"
+
+	// NetworkRemoveLink removes a link from a running network stack.
+	NetworkRemoveLink = "Network.RemoveLink"
+
+	// NetworkUpdateRoutes replaces the routes of a link in a running network
+	// stack.
+	NetworkUpdateRoutes = "Network.UpdateRoutes"
+
+	// NetworkStartCapture starts capturing the packets of a link of a running
+	// network stack.
+	NetworkStartCapture = "Network.StartCapture"
+
+	// NetworkStopCapture stops a packet capture started by
+	// NetworkStartCapture.
+	NetworkStopCapture = "Network.StopCapture"
+
 	// DebugStacks collects sandbox stacks for debugging.
 	DebugStacks = "debug.Stacks"
 )
@@ -474,11 +496,15 @@ type PortForwardOpts struct {
 	ContainerID string
 	// Port is the port to to forward.
 	Port uint16
+	// UDP forwards datagrams to a UDP port instead of a stream to a TCP
+	// port. The donated FD must then be a SOCK_SEQPACKET Unix socket, each
+	// message of which carries one datagram.
+	UDP bool
 }
 
 // PortForward initiates a port forward to the container.
 func (cm *containerManager) PortForward(opts *PortForwardOpts, _ *struct{}) error {
-	log.Debugf("containerManager.PortForward, cid: %s, port: %d", opts.ContainerID, opts.Port)
+	log.Debugf("containerManager.PortForward, cid: %s, port: %d, udp: %t", opts.ContainerID, opts.Port, opts.UDP)
 	if err := cm.l.portForward(opts); err != nil {
 		log.Debugf("containerManager.PortForward failed, opts: %+v, err: %v", opts, err)
 		return err
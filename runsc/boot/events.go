@@ -17,6 +17,7 @@ package boot
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/control"
@@ -43,6 +44,11 @@ type EventOut struct {
 
 	// ContainerUsage maps each container ID to its total CPU usage.
 	ContainerUsage map[string]uint64 `json:"containerUsage"`
+
+	// OOMKills is the number of tasks in the container killed by the
+	// sentry's OOM killer because the container's memory cgroup exceeded its
+	// limit.
+	OOMKills uint64 `json:"oomKills"`
 }
 
 // Event struct for encoding the event data to JSON. Corresponds to runc's
@@ -99,7 +105,44 @@ type CPUUsage struct {
 	PerCPU []uint64 `json:"percpu,omitempty"`
 }
 
-func (cm *containerManager) getUsageFromCgroups(file control.CgroupControlFile) (uint64, error) {
+// ContainerStats is the resource usage of a single container, as accounted
+// by the sentry rather than by host cgroups, which only see the whole
+// sandbox.
+type ContainerStats struct {
+	// ID is the container ID.
+	ID string `json:"id"`
+
+	// Name is the container name, if known.
+	Name string `json:"name,omitempty"`
+
+	// CPUUsage is the total CPU time used by the container, in nanoseconds.
+	CPUUsage uint64 `json:"cpuUsage"`
+
+	// MemoryUsage is the memory used by the container, in bytes.
+	MemoryUsage uint64 `json:"memoryUsage"`
+
+	// ReadBytes and WriteBytes are the number of bytes read and written by
+	// read and write syscalls of processes in the container.
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+
+	// Reads and Writes are the number of read and write syscalls of
+	// processes in the container.
+	Reads  uint64 `json:"reads"`
+	Writes uint64 `json:"writes"`
+}
+
+// ContainerStatsOut is the return type of the ContainerStats command.
+type ContainerStatsOut struct {
+	// Containers holds the stats of each container in the sandbox.
+	Containers []ContainerStats `json:"containers"`
+
+	// NetworkInterfaces holds the stats of the sandbox's network interfaces,
+	// which are shared by all containers.
+	NetworkInterfaces []*NetworkInterface `json:"network_interfaces"`
+}
+
+func (cm *containerManager) readCgroupFile(file control.CgroupControlFile) (string, error) {
 	var out control.CgroupsResults
 	args := control.CgroupsReadArgs{
 		Args: []control.CgroupsReadArg{
@@ -110,12 +153,16 @@ func (cm *containerManager) getUsageFromCgroups(file control.CgroupControlFile)
 	}
 	cgroups := control.Cgroups{Kernel: cm.l.k}
 	if err := cgroups.ReadControlFiles(&args, &out); err != nil {
-		return 0, err
+		return "", err
 	}
 	if len(out.Results) != 1 {
-		return 0, fmt.Errorf("expected 1 result, got %d, raw: %+v", len(out.Results), out)
+		return "", fmt.Errorf("expected 1 result, got %d, raw: %+v", len(out.Results), out)
 	}
-	val, err := out.Results[0].Unpack()
+	return out.Results[0].Unpack()
+}
+
+func (cm *containerManager) getUsageFromCgroups(file control.CgroupControlFile) (uint64, error) {
+	val, err := cm.readCgroupFile(file)
 	if err != nil {
 		return 0, err
 	}
@@ -126,6 +173,34 @@ func (cm *containerManager) getUsageFromCgroups(file control.CgroupControlFile)
 	return usage, nil
 }
 
+// getOOMKillsFromCgroups returns the number of OOM kills in the container's
+// memory cgroup, from memory.oom_control on cgroup v1 or memory.events on
+// cgroup v2.
+func (cm *containerManager) getOOMKillsFromCgroups(cid string) (uint64, error) {
+	var lastErr error
+	for _, name := range []string{"memory.oom_control", "memory.events"} {
+		val, err := cm.readCgroupFile(control.CgroupControlFile{"memory", "/" + cid, name})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return parseOOMKills(val)
+	}
+	return 0, lastErr
+}
+
+// parseOOMKills parses the "oom_kill" entry of memory.oom_control or
+// memory.events.
+func parseOOMKills(val string) (uint64, error) {
+	for _, line := range strings.Split(val, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no oom_kill entry in %q", val)
+}
+
 // Event gets the events from the container.
 func (cm *containerManager) Event(cid *string, out *EventOut) error {
 	*out = EventOut{
@@ -154,33 +229,13 @@ func (cm *containerManager) Event(cid *string, out *EventOut) error {
 	}
 
 	// Memory usage.
-	memFile := control.CgroupControlFile{"memory", "/" + *cid, "memory.usage_in_bytes"}
-	memUsage, err := cm.getUsageFromCgroups(memFile)
-	if err != nil {
-		// Cgroups is not installed or there was an error to get usage
-		// from the cgroups. Fall back to the old method of getting the
-		// usage from the sentry.
-		log.Warningf("could not get container memory usage from cgroups, error:  %v", err)
-
-		mem := cm.l.k.MemoryFile()
-		_ = mem.UpdateUsage(nil) // best effort to update.
-		_, totalUsage := usage.MemoryAccounting.Copy()
-		if numContainers == 1 {
-			memUsage = totalUsage
-		} else {
-			// In the multi-container case, reports 0 for the root (pause)
-			// container, since it's small and idle. Then equally split the
-			// usage to the other containers. At least the sum of all
-			// containers will correctly account for the memory used by the
-			// sandbox.
-			if *cid == cm.l.sandboxID {
-				memUsage = 0
-			} else {
-				memUsage = totalUsage / uint64(numContainers-1)
-			}
-		}
+	out.Event.Data.Memory.Usage.Usage = cm.containerMemoryUsage(*cid, numContainers)
+
+	if oomKills, err := cm.getOOMKillsFromCgroups(*cid); err != nil {
+		log.Debugf("could not get container OOM kills from cgroups, error: %v", err)
+	} else {
+		out.OOMKills = oomKills
 	}
-	out.Event.Data.Memory.Usage.Usage = memUsage
 
 	// CPU usage by container.
 	cpuacctFile := control.CgroupControlFile{"cpuacct", "/" + *cid, "cpuacct.usage"}
@@ -196,3 +251,83 @@ func (cm *containerManager) Event(cid *string, out *EventOut) error {
 	}
 	return nil
 }
+
+// containerMemoryUsage returns the memory usage of container cid from its
+// memory cgroup, or an estimate from the sandbox's total memory usage if
+// memory cgroups are not available. numContainers is the number of containers
+// in the sandbox.
+func (cm *containerManager) containerMemoryUsage(cid string, numContainers int) uint64 {
+	memFile := control.CgroupControlFile{"memory", "/" + cid, "memory.usage_in_bytes"}
+	memUsage, err := cm.getUsageFromCgroups(memFile)
+	if err != nil {
+		// The unified hierarchy reports usage in memory.current instead.
+		memFile.Name = "memory.current"
+		memUsage, err = cm.getUsageFromCgroups(memFile)
+	}
+	if err == nil {
+		return memUsage
+	}
+	// Cgroups is not installed or there was an error to get usage
+	// from the cgroups. Fall back to the old method of getting the
+	// usage from the sentry.
+	log.Warningf("could not get container memory usage from cgroups, error:  %v", err)
+
+	mem := cm.l.k.MemoryFile()
+	_ = mem.UpdateUsage(nil) // best effort to update.
+	_, totalUsage := usage.MemoryAccounting.Copy()
+	if numContainers == 1 {
+		return totalUsage
+	}
+	// In the multi-container case, reports 0 for the root (pause)
+	// container, since it's small and idle. Then equally split the
+	// usage to the other containers. At least the sum of all
+	// containers will correctly account for the memory used by the
+	// sandbox.
+	if cid == cm.l.sandboxID {
+		return 0
+	}
+	return totalUsage / uint64(numContainers-1)
+}
+
+// ContainerStats gets the resource usage of all containers in the sandbox.
+func (cm *containerManager) ContainerStats(_ *struct{}, out *ContainerStatsOut) error {
+	cids := cm.l.containerIDsWithInit()
+	if len(cids) == 0 {
+		return fmt.Errorf("no container was found")
+	}
+	networkStats, err := cm.l.networkStats()
+	if err != nil {
+		return err
+	}
+	*out = ContainerStatsOut{
+		Containers:        make([]ContainerStats, 0, len(cids)),
+		NetworkInterfaces: networkStats,
+	}
+
+	// cpuFallback holds the CPU usage of all containers, computed from the
+	// CPU usage of their processes if cpuacct cgroups are not available.
+	var cpuFallback map[string]uint64
+	for _, cid := range cids {
+		stats := ContainerStats{
+			ID:          cid,
+			Name:        cm.l.k.ContainerName(cid),
+			MemoryUsage: cm.containerMemoryUsage(cid, len(cids)),
+		}
+		cpuacctFile := control.CgroupControlFile{"cpuacct", "/" + cid, "cpuacct.usage"}
+		if cpuUsage, err := cm.getUsageFromCgroups(cpuacctFile); err == nil {
+			stats.CPUUsage = cpuUsage
+		} else {
+			if cpuFallback == nil {
+				cpuFallback = control.ContainerUsage(cm.l.k)
+			}
+			stats.CPUUsage = cpuFallback[cid]
+		}
+		io := cm.l.k.TaskSet().ContainerIOUsage(cid)
+		stats.ReadBytes = io.CharsRead.Load()
+		stats.WriteBytes = io.CharsWritten.Load()
+		stats.Reads = io.ReadSyscalls.Load()
+		stats.Writes = io.WriteSyscalls.Load()
+		out.Containers = append(out.Containers, stats)
+	}
+	return nil
+}
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	gtime "time"
 
 	"github.com/moby/sys/capability"
@@ -32,6 +34,7 @@ import (
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/coverage"
 	"gvisor.dev/gvisor/pkg/cpuid"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/fd"
 	"gvisor.dev/gvisor/pkg/gomaxprocs"
 	"gvisor.dev/gvisor/pkg/log"
@@ -91,6 +94,7 @@ import (
 
 	// Include other supported socket providers.
 	_ "gvisor.dev/gvisor/pkg/sentry/socket/netlink"
+	_ "gvisor.dev/gvisor/pkg/sentry/socket/netlink/genetlink"
 	_ "gvisor.dev/gvisor/pkg/sentry/socket/netlink/netfilter"
 	_ "gvisor.dev/gvisor/pkg/sentry/socket/netlink/route"
 	_ "gvisor.dev/gvisor/pkg/sentry/socket/netlink/uevent"
@@ -228,6 +232,10 @@ type Loader struct {
 	// /sys/devices/virtual/dmi/id/product_name.
 	productName string
 
+	// cgroupV2 indicates that containers get the cgroup v2 unified hierarchy
+	// at /sys/fs/cgroup instead of the v1 controller hierarchies.
+	cgroupV2 bool
+
 	hostTHP HostTHP
 
 	// mu guards the fields below.
@@ -374,6 +382,9 @@ type Args struct {
 	NvidiaDriverVersion nvconf.DriverVersion
 	// HostTHP contains host transparent hugepage settings.
 	HostTHP HostTHP
+	// CgroupV2 indicates that the host uses cgroup v2, and containers should
+	// get the unified hierarchy too.
+	CgroupV2 bool
 
 	SaveFDs []*fd.FD
 }
@@ -462,6 +473,7 @@ func New(args Args) (*Loader, error) {
 		sharedMounts:   make(map[string]*vfs.Mount),
 		stopProfiling:  stopProfiling,
 		productName:    args.ProductName,
+		cgroupV2:       args.CgroupV2,
 		hostTHP:        args.HostTHP,
 		containerIDs:   make(map[string]string),
 		containerSpecs: make(map[string]*specs.Spec),
@@ -576,6 +588,11 @@ func New(args Args) (*Loader, error) {
 			return nil, fmt.Errorf("enable s/r: %w", err)
 		}
 	}
+	if args.Spec.Linux != nil {
+		if err := setNetworkSysctls(netns, args.Spec.Linux.Sysctl); err != nil {
+			return nil, err
+		}
+	}
 
 	if args.NumCPU == 0 {
 		args.NumCPU = runtime.NumCPU()
@@ -1575,6 +1592,39 @@ func newRootNetworkNamespace(conf *config.Config, clock tcpip.Clock, userns *aut
 
 }
 
+// setNetworkSysctls sets the net.* sysctls from the OCI spec in netns. Sysctls
+// that the network stack doesn't implement are ignored with a warning.
+func setNetworkSysctls(netns *inet.Namespace, sysctls map[string]string) error {
+	for key, val := range sysctls {
+		name, ok := strings.CutPrefix(key, "net.")
+		if !ok {
+			continue
+		}
+		name = strings.ReplaceAll(name, ".", "/")
+		sysctl := inet.LookupSysctl(name)
+		if sysctl == nil || sysctl.ReadOnly {
+			log.Warningf("Ignoring unsupported sysctl %s=%s", key, val)
+			continue
+		}
+		cur, err := netns.Sysctl(name)
+		if err == linuxerr.EOPNOTSUPP {
+			log.Warningf("Ignoring sysctl %s=%s, not supported by the network stack", key, val)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		v, err := sysctl.Parse(val, cur)
+		if err != nil {
+			return fmt.Errorf("setting %s=%s: %w", key, val, err)
+		}
+		if err := netns.SetSysctl(name, v); err != nil {
+			return fmt.Errorf("setting %s=%s: %w", key, val, err)
+		}
+	}
+	return nil
+}
+
 func newEmptySandboxNetworkStack(clock tcpip.Clock, allowPacketEndpointWrite bool) (*netstack.Stack, error) {
 	netProtos := []stack.NetworkProtocolFactory{ipv4.NewProtocol, ipv6.NewProtocol, arp.NewProtocol}
 	transProtos := []stack.TransportProtocolFactory{
@@ -1892,18 +1942,26 @@ func (l *Loader) portForward(opts *PortForwardOpts) error {
 	fdConn := pf.NewFileDescriptionConn(fd)
 
 	// Create a proxy to forward data between the fdConn and the sandboxed application.
-	pair := pf.ProxyPair{To: fdConn}
+	pair := pf.ProxyPair{To: fdConn, Datagram: opts.UDP}
 
 	switch l.root.conf.Network {
 	case config.NetworkSandbox:
 		stack := l.k.RootNetworkNamespace().Stack().(*netstack.Stack).Stack
-		nsConn, err := pf.NewNetstackConn(stack, opts.Port)
+		newConn := pf.NewNetstackConn
+		if opts.UDP {
+			newConn = pf.NewNetstackUDPConn
+		}
+		nsConn, err := newConn(stack, opts.Port)
 		if err != nil {
 			return fmt.Errorf("creating netstack port forward connection: %w", err)
 		}
 		pair.From = nsConn
 	case config.NetworkHost:
-		hConn, err := pf.NewHostInetConn(opts.Port)
+		newConn := pf.NewHostInetConn
+		if opts.UDP {
+			newConn = pf.NewHostInetUDPConn
+		}
+		hConn, err := newConn(opts.Port)
 		if err != nil {
 			return fmt.Errorf("creating hostinet port forward connection: %w", err)
 		}
@@ -1969,6 +2027,22 @@ func (l *Loader) containerCount() int {
 	return containers
 }
 
+// containerIDsWithInit returns the IDs of all containers that have an init
+// process, in sorted order.
+func (l *Loader) containerIDsWithInit() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var cids []string
+	for id := range l.processes {
+		if id.pid == 0 {
+			cids = append(cids, id.cid)
+		}
+	}
+	sort.Strings(cids)
+	return cids
+}
+
 func (l *Loader) pidsCount(cid string) (int, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
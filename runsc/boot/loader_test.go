@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -30,6 +31,7 @@ import (
 	"gvisor.dev/gvisor/pkg/cpuid"
 	"gvisor.dev/gvisor/pkg/fspath"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/seccheck"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
@@ -291,6 +293,62 @@ func TestHostnetWithRawSockets(t *testing.T) {
 	}
 }
 
+// Test that net.* sysctls from the spec are applied to the network namespace.
+func TestSetNetworkSysctls(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sysctls map[string]string
+		want    map[string]inet.SysctlValue
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			sysctls: map[string]string{
+				"net.ipv4.tcp_keepalive_time": "600",
+				"net.core.somaxconn":          "4096",
+				"fs.nr_open":                  "1024",
+			},
+			want: map[string]inet.SysctlValue{
+				"ipv4/tcp_keepalive_time": inet.IntSysctl(600),
+				"core/somaxconn":          inet.IntSysctl(4096),
+			},
+		},
+		{
+			name: "unsupported",
+			sysctls: map[string]string{
+				"net.ipv4.tcp_fack": "1",
+			},
+			want: map[string]inet.SysctlValue{
+				"ipv4/tcp_keepalive_time": inet.IntSysctl(7200),
+			},
+		},
+		{
+			name: "invalid",
+			sysctls: map[string]string{
+				"net.ipv4.tcp_keepalive_time": "-1",
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := inet.NewTestStack()
+			s.SysctlMap["ipv4/tcp_keepalive_time"] = inet.IntSysctl(7200)
+			s.SysctlMap["core/somaxconn"] = inet.IntSysctl(1024)
+			netns := inet.NewRootNamespace(s, nil, auth.NewRootUserNamespace())
+
+			err := setNetworkSysctls(netns, tc.sysctls)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("setNetworkSysctls(%v) = %v, want error: %t", tc.sysctls, err, tc.wantErr)
+			}
+			for name, want := range tc.want {
+				if got := s.SysctlMap[name]; !reflect.DeepEqual(got, want) {
+					t.Errorf("sysctl %s = %+v, want %+v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
 type CreateMountTestcase struct {
 	name string
 	// Spec that will be used to create the mount manager.  Note
@@ -21,13 +21,16 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/hostos"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/socket/netfilter"
+	rtnetlink "gvisor.dev/gvisor/pkg/sentry/socket/netlink/route"
 	"gvisor.dev/gvisor/pkg/sentry/socket/plugin"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/link/ethernet"
@@ -75,6 +78,17 @@ type Network struct {
 	// PluginStack is a third-party network stack to use in place of
 	// netstack when non-nil.
 	PluginStack plugin.PluginStack
+
+	// mu protects linkFDs and captures.
+	mu sync.Mutex
+
+	// linkFDs holds the host FDs owned by the fdbased and XDP links of the
+	// stack, keyed by link name. They are closed when the link is removed.
+	linkFDs map[string][]int
+
+	// captures holds the packet captures started by StartCapture, keyed by
+	// link name.
+	captures map[string]*sniffer.Capture
 }
 
 // Route represents a route in the network stack.
@@ -189,6 +203,43 @@ type CreateLinksAndRoutesArgs struct {
 	DisconnectOk bool
 }
 
+// AddLinkArgs are arguments to AddLink.
+type AddLinkArgs struct {
+	// FilePayload contains the fds associated with the link, in the same
+	// order as for CreateLinksAndRoutesArgs.
+	urpc.FilePayload
+
+	// Exactly one of FDBasedLink and XDPLink must be set.
+	FDBasedLink *FDBasedLink
+	XDPLink     *XDPLink
+
+	// PCAP indicates that FilePayload also contains a PCAP log file.
+	PCAP bool
+
+	// LogPackets indicates that packets should be logged.
+	LogPackets bool
+
+	// DisconnectOk indicates that the link endpoint should have the
+	// capability CapabilityDisconnectOk set.
+	DisconnectOk bool
+}
+
+// RemoveLinkArgs are arguments to RemoveLink.
+type RemoveLinkArgs struct {
+	// Name is the name of the link to remove.
+	Name string
+}
+
+// UpdateRoutesArgs are arguments to UpdateRoutes.
+type UpdateRoutesArgs struct {
+	// Name is the name of the link whose routes are updated.
+	Name string
+
+	// Routes replace all routes through the link in the main routing table.
+	// Default routes are given with a zero destination.
+	Routes []Route
+}
+
 // InitPluginStackArgs are arguments to InitPluginStack.
 type InitPluginStackArgs struct {
 	urpc.FilePayload
@@ -322,199 +373,38 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 
 	// Setup fdbased or XDP links.
 	fdOffset := 0
+	lopts := linkOpts{
+		pcap:         args.PCAP,
+		logPackets:   args.LogPackets,
+		disconnectOk: args.DisconnectOk,
+	}
 	if len(args.FDBasedLinks) > 0 {
-		// Choose a dispatch mode.
-		dispatchMode := fdbased.RecvMMsg
-		version, err := hostos.KernelVersion()
+		dispatchMode, err := fdbasedDispatchMode()
 		if err != nil {
 			return err
 		}
-		if version.AtLeast(5, 6) {
-			// TODO(b/333120887): Switch back to using the packet mmap dispatcher when
-			// we have the performance data to justify it.
-			// dispatchMode = fdbased.PacketMMap
-			// log.Infof("Host kernel version >= 5.6, using to packet mmap to dispatch")
-		} else {
-			log.Infof("Host kernel version < 5.6, using to RecvMMsg to dispatch")
-		}
-
-		for _, link := range args.FDBasedLinks {
-			nicID := n.Stack.NextNICID()
-			nicids[link.Name] = nicID
-
-			FDs := make([]int, 0, link.NumChannels)
-			for j := 0; j < link.NumChannels; j++ {
-				// Copy the underlying FD.
-				oldFD := args.FilePayload.Files[fdOffset].Fd()
-				newFD, err := unix.Dup(int(oldFD))
-				if err != nil {
-					return fmt.Errorf("failed to dup FD %v: %v", oldFD, err)
-				}
-				FDs = append(FDs, newFD)
-				fdOffset++
-			}
-
-			mac := tcpip.LinkAddress(link.LinkAddress)
-			log.Infof("gso max size is: %d", link.GSOMaxSize)
-
-			linkEP, err := fdbased.New(&fdbased.Options{
-				FDs:                  FDs,
-				MTU:                  uint32(link.MTU),
-				EthernetHeader:       mac != "",
-				Address:              mac,
-				PacketDispatchMode:   dispatchMode,
-				GSOMaxSize:           link.GSOMaxSize,
-				GVisorGSOEnabled:     link.GVisorGSOEnabled,
-				TXChecksumOffload:    link.TXChecksumOffload,
-				RXChecksumOffload:    link.RXChecksumOffload,
-				GRO:                  link.GVisorGRO,
-				ProcessorsPerChannel: link.ProcessorsPerChannel,
-				DisconnectOk:         args.DisconnectOk,
-			})
+		for i := range args.FDBasedLinks {
+			link := &args.FDBasedLinks[i]
+			nicID, linkRoutes, used, err := n.createFDBasedLink(link, args.FilePayload.Files[fdOffset:], dispatchMode, lopts)
 			if err != nil {
 				return err
 			}
-
-			// Setup packet logging if requested.
-			if args.PCAP {
-				newFD, err := unix.Dup(int(args.FilePayload.Files[fdOffset].Fd()))
-				if err != nil {
-					return fmt.Errorf("failed to dup pcap FD: %v", err)
-				}
-				const packetTruncateSize = 4096
-				linkEP, err = sniffer.NewWithWriter(linkEP, os.NewFile(uintptr(newFD), "pcap-file"), packetTruncateSize)
-				if err != nil {
-					return fmt.Errorf("failed to create PCAP logger: %v", err)
-				}
-				fdOffset++
-			} else if args.LogPackets {
-				linkEP = sniffer.New(linkEP)
-			}
-
-			var qDisc stack.QueueingDiscipline
-			switch link.QDisc {
-			case config.QDiscNone:
-			case config.QDiscFIFO:
-				log.Infof("Enabling FIFO QDisc on %q", link.Name)
-				qDisc = fifo.New(linkEP, runtime.GOMAXPROCS(0), 1000)
-			}
-
-			log.Infof("Enabling interface %q with id %d on addresses %+v (%v) w/ %d channels", link.Name, nicID, link.Addresses, mac, link.NumChannels)
-			opts := stack.NICOptions{
-				Name:               link.Name,
-				QDisc:              qDisc,
-				DeliverLinkPackets: true,
-			}
-			if err := n.createNICWithAddrs(nicID, linkEP, opts, link.Addresses); err != nil {
-				return err
-			}
-
-			// Collect the routes from this link.
-			for _, r := range link.Routes {
-				route, err := r.toTcpipRoute(nicID)
-				if err != nil {
-					return err
-				}
-				routes = append(routes, route)
-			}
-
-			for _, neigh := range link.Neighbors {
-				proto, tcpipAddr := ipToAddressAndProto(neigh.IP)
-				n.Stack.AddStaticNeighbor(nicID, proto, tcpipAddr, tcpip.LinkAddress(neigh.HardwareAddr))
-			}
+			nicids[link.Name] = nicID
+			routes = append(routes, linkRoutes...)
+			fdOffset += used
 		}
 	} else if len(args.XDPLinks) > 0 {
 		if nlinks := len(args.XDPLinks); nlinks > 1 {
 			return fmt.Errorf("XDP only supports one link device, but got %d", nlinks)
 		}
-		link := args.XDPLinks[0]
-		nicID := n.Stack.NextNICID()
-		nicids[link.Name] = nicID
-
-		// Get the AF_XDP socket.
-		oldFD := args.FilePayload.Files[fdOffset].Fd()
-		fd, err := unix.Dup(int(oldFD))
-		if err != nil {
-			return fmt.Errorf("failed to dup AF_XDP fd %v: %v", oldFD, err)
-		}
-		fdOffset++
-
-		// When the sentry is responsible for binding, the runsc
-		// process sends several other FDs in order to keep them open
-		// and alive. These are for BPF programs and maps that, if
-		// closed, will break the dispatcher.
-		if link.Bind == BindSentry {
-			for _, fdName := range []string{"program-fd", "sockmap-fd", "link-fd"} {
-				oldFD := args.FilePayload.Files[fdOffset].Fd()
-				if _, err := unix.Dup(int(oldFD)); err != nil {
-					return fmt.Errorf("failed to dup %s with FD %d: %v", fdName, oldFD, err)
-				}
-				fdOffset++
-			}
-		}
-
-		// Setup packet logging if requested.
-		mac := tcpip.LinkAddress(link.LinkAddress)
-		linkEP, err := xdp.New(&xdp.Options{
-			FD:                fd,
-			Address:           mac,
-			TXChecksumOffload: link.TXChecksumOffload,
-			RXChecksumOffload: link.RXChecksumOffload,
-			InterfaceIndex:    link.InterfaceIndex,
-			Bind:              link.Bind == BindSentry,
-			GRO:               link.GVisorGRO,
-			DisconnectOk:      args.DisconnectOk,
-		})
+		link := &args.XDPLinks[0]
+		nicID, linkRoutes, used, err := n.createXDPLink(link, args.FilePayload.Files[fdOffset:], lopts)
 		if err != nil {
 			return err
 		}
-
-		if args.PCAP {
-			newFD, err := unix.Dup(int(args.FilePayload.Files[fdOffset].Fd()))
-			if err != nil {
-				return fmt.Errorf("failed to dup pcap FD: %v", err)
-			}
-			const packetTruncateSize = 4096
-			linkEP, err = sniffer.NewWithWriter(linkEP, os.NewFile(uintptr(newFD), "pcap-file"), packetTruncateSize)
-			if err != nil {
-				return fmt.Errorf("failed to create PCAP logger: %v", err)
-			}
-			fdOffset++
-		} else if args.LogPackets {
-			linkEP = sniffer.New(linkEP)
-		}
-
-		var qDisc stack.QueueingDiscipline
-		switch link.QDisc {
-		case config.QDiscNone:
-		case config.QDiscFIFO:
-			log.Infof("Enabling FIFO QDisc on %q", link.Name)
-			qDisc = fifo.New(linkEP, runtime.GOMAXPROCS(0), 1000)
-		}
-
-		log.Infof("Enabling interface %q with id %d on addresses %+v (%v) w/ %d channels", link.Name, nicID, link.Addresses, mac, link.NumChannels)
-		opts := stack.NICOptions{
-			Name:               link.Name,
-			QDisc:              qDisc,
-			DeliverLinkPackets: true,
-		}
-		if err := n.createNICWithAddrs(nicID, linkEP, opts, link.Addresses); err != nil {
-			return err
-		}
-
-		// Collect the routes from this link.
-		for _, r := range link.Routes {
-			route, err := r.toTcpipRoute(nicID)
-			if err != nil {
-				return err
-			}
-			routes = append(routes, route)
-		}
-
-		for _, neigh := range link.Neighbors {
-			proto, tcpipAddr := ipToAddressAndProto(neigh.IP)
-			n.Stack.AddStaticNeighbor(nicID, proto, tcpipAddr, tcpip.LinkAddress(neigh.HardwareAddr))
-		}
+		nicids[link.Name] = nicID
+		routes = append(routes, linkRoutes...)
+		fdOffset += used
 	}
 
 	if !args.Defaultv4Gateway.Route.Empty() {
@@ -560,6 +450,378 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 	return nil
 }
 
+// AddLink creates a link and its routes in a running network stack, e.g.
+// when an interface is added to the sandbox network namespace after start.
+func (n *Network) AddLink(args *AddLinkArgs, _ *struct{}) error {
+	if (args.FDBasedLink == nil) == (args.XDPLink == nil) {
+		return fmt.Errorf("exactly one of FDBasedLink and XDPLink must be set")
+	}
+	lopts := linkOpts{
+		pcap:         args.PCAP,
+		logPackets:   args.LogPackets,
+		disconnectOk: args.DisconnectOk,
+	}
+	return n.notifyChanges(func() error {
+		var (
+			routes []tcpip.Route
+			used   int
+			err    error
+		)
+		if link := args.FDBasedLink; link != nil {
+			if _, ok := n.nicIDByName(link.Name); ok {
+				return fmt.Errorf("link %q already exists", link.Name)
+			}
+			var dispatchMode fdbased.PacketDispatchMode
+			if dispatchMode, err = fdbasedDispatchMode(); err != nil {
+				return err
+			}
+			_, routes, used, err = n.createFDBasedLink(link, args.FilePayload.Files, dispatchMode, lopts)
+		} else {
+			link := args.XDPLink
+			if _, ok := n.nicIDByName(link.Name); ok {
+				return fmt.Errorf("link %q already exists", link.Name)
+			}
+			_, routes, used, err = n.createXDPLink(link, args.FilePayload.Files, lopts)
+		}
+		if err != nil {
+			return err
+		}
+		if got := len(args.FilePayload.Files); got != used {
+			log.Warningf("AddLink received %d FDs but only used %d", got, used)
+		}
+
+		log.Infof("Adding routes %+v", routes)
+		for _, route := range routes {
+			n.Stack.AddRoute(route)
+		}
+		return nil
+	})
+}
+
+// RemoveLink removes a link, along with its addresses, routes and neighbors,
+// from a running network stack.
+func (n *Network) RemoveLink(args *RemoveLinkArgs, _ *struct{}) error {
+	return n.notifyChanges(func() error {
+		nicID, ok := n.nicIDByName(args.Name)
+		if !ok {
+			return fmt.Errorf("link %q not found", args.Name)
+		}
+		if nicID == linux.LOOPBACK_IFINDEX {
+			return fmt.Errorf("cannot remove loopback link %q", args.Name)
+		}
+		if c := n.removeCapture(args.Name); c != nil {
+			if err := c.Stop(); err != nil {
+				log.Warningf("Packet capture on interface %q failed: %v", args.Name, err)
+			}
+		}
+		log.Infof("Removing interface %q with id %d", args.Name, nicID)
+		if err := n.Stack.RemoveNIC(nicID); err != nil {
+			return fmt.Errorf("RemoveNIC(%d) failed: %v", nicID, err)
+		}
+		n.setLinkFDs(args.Name, nil)
+		return nil
+	})
+}
+
+// UpdateRoutes replaces the routes through a link in the main routing table
+// of a running network stack.
+func (n *Network) UpdateRoutes(args *UpdateRoutesArgs, _ *struct{}) error {
+	return n.notifyChanges(func() error {
+		nicID, ok := n.nicIDByName(args.Name)
+		if !ok {
+			return fmt.Errorf("link %q not found", args.Name)
+		}
+		routes := make([]tcpip.Route, 0, len(args.Routes))
+		for _, r := range args.Routes {
+			route, err := r.toTcpipRoute(nicID)
+			if err != nil {
+				return err
+			}
+			routes = append(routes, route)
+		}
+
+		log.Infof("Replacing routes of interface %q with %+v", args.Name, routes)
+		n.Stack.RemoveRoutes(func(r tcpip.Route) bool {
+			return r.NIC == nicID && r.TableID() == tcpip.MainRouteTable
+		})
+		for _, route := range routes {
+			n.Stack.AddRoute(route)
+		}
+		return nil
+	})
+}
+
+// notifyChanges runs fn and notifies rtnetlink listeners in the root network
+// namespace of the changes it made to the stack.
+func (n *Network) notifyChanges(fn func() error) error {
+	if n.Kernel == nil {
+		return fn()
+	}
+	return rtnetlink.NotifyChanges(n.Kernel.SupervisorContext(), n.Kernel.RootNetworkNamespace(), fn)
+}
+
+// nicIDByName returns the ID of the NIC with the given name.
+func (n *Network) nicIDByName(name string) (tcpip.NICID, bool) {
+	for id, info := range n.Stack.NICInfo() {
+		if info.Name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// setLinkFDs records the host FDs owned by the link with the given name,
+// closing the ones previously recorded for it.
+func (n *Network) setLinkFDs(name string, fds []int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, fd := range n.linkFDs[name] {
+		_ = unix.Close(fd)
+	}
+	if len(fds) == 0 {
+		delete(n.linkFDs, name)
+		return
+	}
+	if n.linkFDs == nil {
+		n.linkFDs = make(map[string][]int)
+	}
+	n.linkFDs[name] = fds
+}
+
+// linkOpts are options that apply to all links created by one request.
+type linkOpts struct {
+	// pcap indicates that a PCAP log file follows the FDs of each link.
+	pcap bool
+	// logPackets indicates that packets should be logged.
+	logPackets bool
+	// disconnectOk indicates that link endpoints should have the
+	// capability CapabilityDisconnectOk set.
+	disconnectOk bool
+}
+
+// fdbasedDispatchMode chooses the dispatch mode of fdbased links.
+func fdbasedDispatchMode() (fdbased.PacketDispatchMode, error) {
+	dispatchMode := fdbased.RecvMMsg
+	version, err := hostos.KernelVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version.AtLeast(5, 6) {
+		// TODO(b/333120887): Switch back to using the packet mmap dispatcher when
+		// we have the performance data to justify it.
+		// dispatchMode = fdbased.PacketMMap
+		// log.Infof("Host kernel version >= 5.6, using to packet mmap to dispatch")
+	} else {
+		log.Infof("Host kernel version < 5.6, using to RecvMMsg to dispatch")
+	}
+	return dispatchMode, nil
+}
+
+// wrapLinkEndpoint sets up packet logging for linkEP if requested. If
+// opts.pcap is set, pcapFile is the PCAP log file.
+func wrapLinkEndpoint(linkEP stack.LinkEndpoint, pcapFile *os.File, opts linkOpts) (stack.LinkEndpoint, error) {
+	if opts.pcap {
+		newFD, err := unix.Dup(int(pcapFile.Fd()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to dup pcap FD: %v", err)
+		}
+		const packetTruncateSize = 4096
+		linkEP, err = sniffer.NewWithWriter(linkEP, os.NewFile(uintptr(newFD), "pcap-file"), packetTruncateSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PCAP logger: %v", err)
+		}
+	} else if opts.logPackets {
+		linkEP = sniffer.New(linkEP)
+	}
+	return linkEP, nil
+}
+
+// newQDisc returns the queueing discipline for a link.
+func newQDisc(name string, linkEP stack.LinkEndpoint, qdisc config.QueueingDiscipline) stack.QueueingDiscipline {
+	switch qdisc {
+	case config.QDiscFIFO:
+		log.Infof("Enabling FIFO QDisc on %q", name)
+		return fifo.New(linkEP, runtime.GOMAXPROCS(0), 1000)
+	default:
+		return nil
+	}
+}
+
+// linkRoutesAndNeighbors converts the routes of the link with the given NIC
+// to tcpip.Routes and adds its static neighbors to the stack.
+func (n *Network) linkRoutesAndNeighbors(nicID tcpip.NICID, routes []Route, neighbors []Neighbor) ([]tcpip.Route, error) {
+	var tcpipRoutes []tcpip.Route
+	for _, r := range routes {
+		route, err := r.toTcpipRoute(nicID)
+		if err != nil {
+			return nil, err
+		}
+		tcpipRoutes = append(tcpipRoutes, route)
+	}
+	for _, neigh := range neighbors {
+		proto, tcpipAddr := ipToAddressAndProto(neigh.IP)
+		n.Stack.AddStaticNeighbor(nicID, proto, tcpipAddr, tcpip.LinkAddress(neigh.HardwareAddr))
+	}
+	return tcpipRoutes, nil
+}
+
+// createFDBasedLink creates an fdbased NIC for link. files holds the link's
+// channel FDs, followed by the PCAP log file if opts.pcap is set. It returns
+// the NIC's ID, its routes and the number of files used.
+func (n *Network) createFDBasedLink(link *FDBasedLink, files []*os.File, dispatchMode fdbased.PacketDispatchMode, opts linkOpts) (tcpip.NICID, []tcpip.Route, int, error) {
+	want := link.NumChannels
+	if opts.pcap {
+		want++
+	}
+	if len(files) < want {
+		return 0, nil, 0, fmt.Errorf("link %q needs %d FDs but only %d are left", link.Name, want, len(files))
+	}
+
+	nicID := n.Stack.NextNICID()
+	FDs := make([]int, 0, link.NumChannels)
+	for j := 0; j < link.NumChannels; j++ {
+		// Copy the underlying FD.
+		oldFD := files[j].Fd()
+		newFD, err := unix.Dup(int(oldFD))
+		if err != nil {
+			return 0, nil, 0, fmt.Errorf("failed to dup FD %v: %v", oldFD, err)
+		}
+		FDs = append(FDs, newFD)
+	}
+
+	mac := tcpip.LinkAddress(link.LinkAddress)
+	log.Infof("gso max size is: %d", link.GSOMaxSize)
+
+	linkEP, err := fdbased.New(&fdbased.Options{
+		FDs:                  FDs,
+		MTU:                  uint32(link.MTU),
+		EthernetHeader:       mac != "",
+		Address:              mac,
+		PacketDispatchMode:   dispatchMode,
+		GSOMaxSize:           link.GSOMaxSize,
+		GVisorGSOEnabled:     link.GVisorGSOEnabled,
+		TXChecksumOffload:    link.TXChecksumOffload,
+		RXChecksumOffload:    link.RXChecksumOffload,
+		GRO:                  link.GVisorGRO,
+		ProcessorsPerChannel: link.ProcessorsPerChannel,
+		DisconnectOk:         opts.disconnectOk,
+	})
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	// Setup packet logging if requested.
+	var pcapFile *os.File
+	if opts.pcap {
+		pcapFile = files[link.NumChannels]
+	}
+	if linkEP, err = wrapLinkEndpoint(linkEP, pcapFile, opts); err != nil {
+		return 0, nil, 0, err
+	}
+
+	log.Infof("Enabling interface %q with id %d on addresses %+v (%v) w/ %d channels", link.Name, nicID, link.Addresses, mac, link.NumChannels)
+	nicOpts := stack.NICOptions{
+		Name:               link.Name,
+		QDisc:              newQDisc(link.Name, linkEP, link.QDisc),
+		DeliverLinkPackets: true,
+	}
+	if err := n.createNICWithAddrs(nicID, linkEP, nicOpts, link.Addresses); err != nil {
+		return 0, nil, 0, err
+	}
+	n.setLinkFDs(link.Name, FDs)
+
+	routes, err := n.linkRoutesAndNeighbors(nicID, link.Routes, link.Neighbors)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	return nicID, routes, want, nil
+}
+
+// createXDPLink creates an XDP NIC for link. files holds the AF_XDP socket,
+// followed by the FDs of the BPF program and maps if the sentry binds the
+// socket, and the PCAP log file if opts.pcap is set. It returns the NIC's ID,
+// its routes and the number of files used.
+func (n *Network) createXDPLink(link *XDPLink, files []*os.File, opts linkOpts) (tcpip.NICID, []tcpip.Route, int, error) {
+	want := 1
+	if link.Bind == BindSentry {
+		want += 3
+	}
+	if opts.pcap {
+		want++
+	}
+	if len(files) < want {
+		return 0, nil, 0, fmt.Errorf("link %q needs %d FDs but only %d are left", link.Name, want, len(files))
+	}
+
+	nicID := n.Stack.NextNICID()
+
+	// Get the AF_XDP socket.
+	oldFD := files[0].Fd()
+	fd, err := unix.Dup(int(oldFD))
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("failed to dup AF_XDP fd %v: %v", oldFD, err)
+	}
+	fds := []int{fd}
+	used := 1
+
+	// When the sentry is responsible for binding, the runsc
+	// process sends several other FDs in order to keep them open
+	// and alive. These are for BPF programs and maps that, if
+	// closed, will break the dispatcher.
+	if link.Bind == BindSentry {
+		for _, fdName := range []string{"program-fd", "sockmap-fd", "link-fd"} {
+			oldFD := files[used].Fd()
+			newFD, err := unix.Dup(int(oldFD))
+			if err != nil {
+				return 0, nil, 0, fmt.Errorf("failed to dup %s with FD %d: %v", fdName, oldFD, err)
+			}
+			fds = append(fds, newFD)
+			used++
+		}
+	}
+
+	mac := tcpip.LinkAddress(link.LinkAddress)
+	linkEP, err := xdp.New(&xdp.Options{
+		FD:                fd,
+		Address:           mac,
+		TXChecksumOffload: link.TXChecksumOffload,
+		RXChecksumOffload: link.RXChecksumOffload,
+		InterfaceIndex:    link.InterfaceIndex,
+		Bind:              link.Bind == BindSentry,
+		GRO:               link.GVisorGRO,
+		DisconnectOk:      opts.disconnectOk,
+	})
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	// Setup packet logging if requested.
+	var pcapFile *os.File
+	if opts.pcap {
+		pcapFile = files[used]
+	}
+	if linkEP, err = wrapLinkEndpoint(linkEP, pcapFile, opts); err != nil {
+		return 0, nil, 0, err
+	}
+
+	log.Infof("Enabling interface %q with id %d on addresses %+v (%v) w/ %d channels", link.Name, nicID, link.Addresses, mac, link.NumChannels)
+	nicOpts := stack.NICOptions{
+		Name:               link.Name,
+		QDisc:              newQDisc(link.Name, linkEP, link.QDisc),
+		DeliverLinkPackets: true,
+	}
+	if err := n.createNICWithAddrs(nicID, linkEP, nicOpts, link.Addresses); err != nil {
+		return 0, nil, 0, err
+	}
+	n.setLinkFDs(link.Name, fds)
+
+	routes, err := n.linkRoutesAndNeighbors(nicID, link.Routes, link.Neighbors)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	return nicID, routes, want, nil
+}
+
 // createNICWithAddrs creates a NIC in the network stack and adds the given
 // addresses.
 func (n *Network) createNICWithAddrs(id tcpip.NICID, ep stack.LinkEndpoint, opts stack.NICOptions, addrs []IPWithPrefix) error {
@@ -0,0 +1,127 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/bpf"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/link/sniffer"
+	"gvisor.dev/gvisor/pkg/urpc"
+)
+
+// StartCaptureArgs are arguments to StartCapture.
+type StartCaptureArgs struct {
+	// FilePayload contains the file that the capture is written to, in pcapng
+	// format.
+	urpc.FilePayload
+
+	// Name is the name of the link to capture.
+	Name string
+
+	// Filter is an optional classic BPF program that selects the packets to
+	// capture, as with tcpdump.
+	Filter []bpf.Instruction
+
+	// SnapLen is the maximum number of bytes captured per packet. If zero, a
+	// default is used.
+	SnapLen uint32
+
+	// Annotate indicates that packets are annotated with the PID and container
+	// ID of the task that owns the socket sending or receiving them.
+	Annotate bool
+}
+
+// StopCaptureArgs are arguments to StopCapture.
+type StopCaptureArgs struct {
+	// Name is the name of the link whose capture is stopped.
+	Name string
+}
+
+// StartCapture starts capturing the packets of a link of a running network
+// stack. There can be at most one capture per link.
+func (n *Network) StartCapture(args *StartCaptureArgs, _ *struct{}) error {
+	if len(args.Files) != 1 {
+		return fmt.Errorf("StartCapture expects 1 file, got %d", len(args.Files))
+	}
+	f := args.Files[0]
+	if n.Stack == nil {
+		f.Close()
+		return fmt.Errorf("packet capture is not supported with a third-party network stack")
+	}
+	nicID, ok := n.nicIDByName(args.Name)
+	if !ok {
+		f.Close()
+		return fmt.Errorf("link %q not found", args.Name)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.captures[args.Name]; ok {
+		f.Close()
+		return fmt.Errorf("link %q is already being captured", args.Name)
+	}
+	opts := sniffer.CaptureOptions{
+		Filter:  args.Filter,
+		SnapLen: args.SnapLen,
+	}
+	if args.Annotate {
+		opts.Annotate = captureAnnotation
+	}
+	c, err := sniffer.StartCapture(n.Stack, nicID, args.Name, f, opts)
+	if err != nil {
+		return err
+	}
+	if n.captures == nil {
+		n.captures = make(map[string]*sniffer.Capture)
+	}
+	n.captures[args.Name] = c
+	log.Infof("Started packet capture on interface %q", args.Name)
+	return nil
+}
+
+// StopCapture stops the capture of a link started by StartCapture, and waits
+// until all captured packets have been written.
+func (n *Network) StopCapture(args *StopCaptureArgs, _ *struct{}) error {
+	c := n.removeCapture(args.Name)
+	if c == nil {
+		return fmt.Errorf("link %q is not being captured", args.Name)
+	}
+	log.Infof("Stopping packet capture on interface %q", args.Name)
+	return c.Stop()
+}
+
+// removeCapture removes and returns the capture of a link, or nil if the link
+// is not being captured.
+func (n *Network) removeCapture(name string) *sniffer.Capture {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	c := n.captures[name]
+	delete(n.captures, name)
+	return c
+}
+
+// captureAnnotation returns the annotation of a captured packet whose socket
+// is owned by owner.
+func captureAnnotation(owner tcpip.PacketOwner) string {
+	t, ok := owner.(*kernel.Task)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("pid=%d container=%s", t.TGIDInRoot(), t.ContainerID())
+}
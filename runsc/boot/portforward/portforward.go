@@ -52,28 +52,46 @@ type Proxy struct {
 	cancelTo   chan struct{}
 	wg         sync.WaitGroup
 	cu         cleanup.Cleanup
+	// bufSize is the size of the buffers used to copy between the connections.
+	bufSize int
 }
 
+// streamBufSize is the buffer size used to copy stream connections.
+const streamBufSize = 16384 // 16kb
+
+// datagramBufSize is the buffer size used to copy datagram connections. It
+// must hold the largest possible UDP payload so that datagrams are never
+// truncated.
+const datagramBufSize = 65535
+
 // ProxyPair wraps the to/from arguments for NewProxy so that the user explicitly labels to/from.
 type ProxyPair struct {
 	To   proxyConn
 	From proxyConn
+	// Datagram indicates that To and From preserve message boundaries, i.e.
+	// each Read returns one datagram and each Write sends one datagram.
+	Datagram bool
 }
 
 // NewProxy returns a new Proxy.
 func NewProxy(pair ProxyPair, cid string) *Proxy {
-	return &Proxy{
+	p := &Proxy{
 		to:         pair.To,
 		from:       pair.From,
 		cid:        cid,
 		cancelTo:   make(chan struct{}, 1),
 		cancelFrom: make(chan struct{}, 1),
+		bufSize:    streamBufSize,
+	}
+	if pair.Datagram {
+		p.bufSize = datagramBufSize
 	}
+	return p
 }
 
 // readFrom reads from the application's vfs.FileDescription and writes to the shim.
 func (pf *Proxy) readFrom(ctx context.Context) error {
-	buf := make([]byte, 16384 /* 16kb buffer size */)
+	buf := make([]byte, pf.bufSize)
 	for ctx.Err() == nil {
 		if err := doCopy(ctx, pf.to, pf.from, buf, pf.cancelFrom); err != nil {
 			return fmt.Errorf("readFrom failed on container %q: %v", pf.cid, err)
@@ -84,7 +102,7 @@ func (pf *Proxy) readFrom(ctx context.Context) error {
 
 // writeTo writes to the application's vfs.FileDescription and reads from the shim.
 func (pf *Proxy) readTo(ctx context.Context) error {
-	buf := make([]byte, 16384 /* 16kb buffer size */)
+	buf := make([]byte, pf.bufSize)
 	for ctx.Err() == nil {
 		if err := doCopy(ctx, pf.from, pf.to, buf, pf.cancelTo); err != nil {
 			return fmt.Errorf("readTo failed on container %q: %v", pf.cid, err)
@@ -41,20 +41,38 @@ type hostInetConn struct {
 	fd *fileDescriptor.FD
 	// port is the port on which to connect.
 	port uint16
+	// udp is true if fd is a UDP socket.
+	udp bool
 	// once makes sure we close only once.
 	once sync.Once
 }
 
 // NewHostInetConn creates a hostInetConn backed by a host socket on the localhost address.
 func NewHostInetConn(port uint16) (proxyConn, error) {
+	return newHostInetConn(port, false /* udp */)
+}
+
+// NewHostInetUDPConn creates a hostInetConn backed by a host UDP socket
+// connected to the localhost address. Each Read and Write of the connection
+// transfers a single datagram.
+func NewHostInetUDPConn(port uint16) (proxyConn, error) {
+	return newHostInetConn(port, true /* udp */)
+}
+
+func newHostInetConn(port uint16, udp bool) (proxyConn, error) {
 	// NOTE: Options must match sandbox seccomp filters. See filter/config.go
-	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, unix.IPPROTO_TCP)
+	stype, proto := unix.SOCK_STREAM, unix.IPPROTO_TCP
+	if udp {
+		stype, proto = unix.SOCK_DGRAM, unix.IPPROTO_UDP
+	}
+	fd, err := unix.Socket(unix.AF_INET, stype|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, proto)
 	if err != nil {
 		return nil, err
 	}
 	s := hostInetConn{
 		fd:   fileDescriptor.New(fd),
 		port: port,
+		udp:  udp,
 	}
 
 	cu := cleanup.Make(func() {
@@ -101,6 +119,9 @@ func NewHostInetConn(port uint16) (proxyConn, error) {
 }
 
 func (s *hostInetConn) Name() string {
+	if s.udp {
+		return fmt.Sprintf("localhost:udp:port:%d", s.port)
+	}
 	return fmt.Sprintf("localhost:port:%d", s.port)
 }
 
@@ -243,3 +243,53 @@ func doHostinetTest(t *testing.T, name string, requests map[string]string) {
 		}
 	}
 }
+
+func TestLocalHostUDPSocket(t *testing.T) {
+	ctx := contexttest.Context(t)
+	clientData := [][]byte{
+		[]byte("do what must be done"),
+		[]byte("do not hesitate"),
+	}
+
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.ListenPacket failed: %v", err)
+	}
+	defer pc.Close()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	sock, err := NewHostInetUDPConn(uint16(port))
+	if err != nil {
+		t.Fatalf("could not create local host UDP socket: %v", err)
+	}
+	defer sock.Close(ctx)
+
+	for _, want := range clientData {
+		if _, err := sock.Write(ctx, want, nil); err != nil {
+			t.Fatalf("could not write to local host UDP socket: %v", err)
+		}
+
+		// Each datagram must arrive on its own, and replies must be routed
+		// back to the connected socket.
+		data := make([]byte, 1024)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, peer, err := pc.ReadFrom(data)
+		if err != nil {
+			t.Fatalf("could not read datagram: %v", err)
+		}
+		if !slices.Equal(data[:n], want) {
+			t.Fatalf("server mismatch data received: got: %s want: %s", data[:n], want)
+		}
+		if _, err := pc.WriteTo(data[:n], peer); err != nil {
+			t.Fatalf("could not write datagram: %v", err)
+		}
+
+		n, err = sock.Read(ctx, data, nil)
+		if err != nil {
+			t.Fatalf("could not read from local host UDP socket: %v", err)
+		}
+		if !slices.Equal(data[:n], want) {
+			t.Fatalf("client mismatch data received: got: %s want: %s", data[:n], want)
+		}
+	}
+}
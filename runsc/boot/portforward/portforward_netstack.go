@@ -25,6 +25,7 @@ import (
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
 	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
 	"gvisor.dev/gvisor/pkg/waiter"
 )
 
@@ -35,6 +36,8 @@ type netstackConn struct {
 	ep tcpip.Endpoint
 	// port is the port on which to connect.
 	port uint16
+	// transProto is the transport protocol of ep.
+	transProto tcpip.TransportProtocolNumber
 	// wq is the WaitQueue for this connection to wait on notifications.
 	wq *waiter.Queue
 	// once makes sure Close is called once.
@@ -44,15 +47,27 @@ type netstackConn struct {
 // NewNetstackConn creates a new port forwarding connection to the given
 // port in netstack mode.
 func NewNetstackConn(stack *stack.Stack, port uint16) (proxyConn, error) {
+	return newNetstackConn(stack, tcp.ProtocolNumber, port)
+}
+
+// NewNetstackUDPConn creates a new port forwarding connection to the given UDP
+// port in netstack mode. Each Read and Write of the connection transfers a
+// single datagram.
+func NewNetstackUDPConn(stack *stack.Stack, port uint16) (proxyConn, error) {
+	return newNetstackConn(stack, udp.ProtocolNumber, port)
+}
+
+func newNetstackConn(stack *stack.Stack, transProto tcpip.TransportProtocolNumber, port uint16) (proxyConn, error) {
 	var wq waiter.Queue
-	ep, tcpErr := stack.NewEndpoint(tcp.ProtocolNumber, ipv4.ProtocolNumber, &wq)
+	ep, tcpErr := stack.NewEndpoint(transProto, ipv4.ProtocolNumber, &wq)
 	if tcpErr != nil {
 		return nil, fmt.Errorf("creating endpoint: %v", tcpErr)
 	}
 	n := &netstackConn{
-		ep:   ep,
-		port: port,
-		wq:   &wq,
+		ep:         ep,
+		port:       port,
+		transProto: transProto,
+		wq:         &wq,
 	}
 	waitEntry, notifyCh := waiter.NewChannelEntry(waiter.WritableEvents)
 	n.wq.EventRegister(&waitEntry)
@@ -67,6 +82,7 @@ func NewNetstackConn(stack *stack.Stack, port uint16) (proxyConn, error) {
 		tcpErr = n.ep.LastError()
 	}
 	if tcpErr != nil {
+		ep.Close()
 		return nil, fmt.Errorf("connecting endpoint: %v", tcpErr)
 	}
 	return n, nil
@@ -74,6 +90,9 @@ func NewNetstackConn(stack *stack.Stack, port uint16) (proxyConn, error) {
 
 // Name implements proxyConn.Name.
 func (n *netstackConn) Name() string {
+	if n.transProto == udp.ProtocolNumber {
+		return fmt.Sprintf("netstack:udp:port:%d", n.port)
+	}
 	return fmt.Sprintf("netstack:port:%d", n.port)
 }
 
@@ -42,6 +42,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/devices/tpuproxy/vfio"
 	"gvisor.dev/gvisor/pkg/sentry/devices/ttydev"
 	"gvisor.dev/gvisor/pkg/sentry/devices/tundev"
+	"gvisor.dev/gvisor/pkg/sentry/devices/userfaultfddev"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/cgroupfs"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/dev"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/devpts"
@@ -98,6 +99,10 @@ func registerFilesystems(k *kernel.Kernel, info *containerInfo) error {
 		AllowUserMount: true,
 		AllowUserList:  true,
 	})
+	vfsObj.MustRegisterFilesystemType(cgroupfs.V2Name, &cgroupfs.V2FilesystemType{}, &vfs.RegisterFilesystemTypeOptions{
+		AllowUserMount: true,
+		AllowUserList:  true,
+	})
 	vfsObj.MustRegisterFilesystemType(devpts.Name, &devpts.FilesystemType{}, &vfs.RegisterFilesystemTypeOptions{
 		AllowUserList:  true,
 		AllowUserMount: true,
@@ -154,6 +159,9 @@ func registerFilesystems(k *kernel.Kernel, info *containerInfo) error {
 	if err := fuse.Register(vfsObj); err != nil {
 		return fmt.Errorf("registering fusedev: %w", err)
 	}
+	if err := userfaultfddev.Register(vfsObj); err != nil {
+		return fmt.Errorf("registering userfaultfddev: %w", err)
+	}
 
 	if err := nvproxyRegisterDevices(info, vfsObj, k.NvidiaDriverVersion); err != nil {
 		return err
@@ -183,13 +191,23 @@ func setupContainerVFS(ctx context.Context, info *containerInfo, mntr *container
 	// container. Otherwise the root cgroups will be enabled.
 	if mntr.cgroupsMounted {
 		cgroupRegistry := mntr.k.CgroupRegistry()
-		for _, ctrl := range kernel.CgroupCtrls {
+		ctrls := kernel.CgroupCtrls
+		if cgroupRegistry.HasV2Hierarchy() {
+			ctrls = kernel.CgroupCtrlsV2
+		}
+		for _, ctrl := range ctrls {
 			cg, err := cgroupRegistry.FindCgroup(ctx, ctrl, "/"+mntr.containerID)
 			if err != nil {
 				return fmt.Errorf("cgroup mount for controller %v not found", ctrl)
 			}
 			if procArgs.InitialCgroups == nil {
-				procArgs.InitialCgroups = make(map[kernel.Cgroup]struct{}, len(kernel.CgroupCtrls))
+				procArgs.InitialCgroups = make(map[kernel.Cgroup]struct{}, len(ctrls))
+			}
+			if _, ok := procArgs.InitialCgroups[cg]; ok {
+				// All controllers share a single cgroup on the unified
+				// hierarchy.
+				cg.DecRef(ctx)
+				continue
 			}
 			procArgs.InitialCgroups[cg] = struct{}{}
 		}
@@ -237,7 +255,7 @@ func compileMounts(spec *specs.Spec, conf *config.Config, containerID string) []
 		// Mount all the cgroup controllers when "/sys/fs/cgroup" mount
 		// is present. If any other cgroup controller mounts are there,
 		// it will be a no-op, drop them.
-		if m.Type == cgroupfs.Name && cgroupsMounted {
+		if (m.Type == cgroupfs.Name || m.Type == cgroupfs.V2Name) && cgroupsMounted {
 			continue
 		}
 
@@ -743,7 +761,7 @@ func (c *containerMounter) mountSubmounts(ctx context.Context, spec *specs.Spec,
 			if err != nil {
 				return fmt.Errorf("mount shared mount %q to %q: %v", submount.hint.Name, submount.mount.Destination, err)
 			}
-		} else if submount.mount.Type == cgroupfs.Name {
+		} else if submount.mount.Type == cgroupfs.Name || submount.mount.Type == cgroupfs.V2Name {
 			// Mount all the cgroups controllers.
 			if err := c.mountCgroupSubmounts(ctx, spec, conf, mns, creds, submount); err != nil {
 				return fmt.Errorf("mount cgroup %q: %w", submount.mount.Destination, err)
@@ -1142,6 +1160,26 @@ func (c *containerMounter) getSharedMount(ctx context.Context, spec *specs.Spec,
 // Postcondition: Initialized k.cgroupMounts on success.
 func (l *Loader) mountCgroupMounts(conf *config.Config, creds *auth.Credentials) error {
 	ctx := l.k.SupervisorContext()
+	if l.cgroupV2 {
+		mopts := &vfs.MountOptions{
+			GetFilesystemOptions: vfs.GetFilesystemOptions{
+				InternalMount: true,
+			},
+		}
+		fs, root, err := l.k.VFS().NewFilesystem(ctx, creds, "cgroup2", cgroupfs.V2Name, mopts)
+		if err != nil {
+			return err
+		}
+		mount := l.k.VFS().NewDisconnectedMount(fs, root, mopts)
+		l.k.VFS().SetMountPropagation(mount, linux.MS_PRIVATE, false)
+		l.k.AddCgroupMount(cgroupfs.V2Name, &kernel.CgroupMount{
+			Fs:    fs,
+			Root:  root,
+			Mount: mount,
+		})
+		log.Infof("created cgroup2 mount")
+		return nil
+	}
 	for _, sopts := range kernel.CgroupCtrls {
 		mopts := &vfs.MountOptions{
 			GetFilesystemOptions: vfs.GetFilesystemOptions{
@@ -1176,6 +1214,10 @@ func (c *containerMounter) mountCgroupSubmounts(ctx context.Context, spec *specs
 	root := mns.Root(ctx)
 	defer root.DecRef(ctx)
 
+	if cgroupMnt := c.k.GetCgroupMount(cgroupfs.V2Name); cgroupMnt != nil {
+		return c.mountCgroup2Submount(ctx, mns, creds, submount, cgroupMnt)
+	}
+
 	// Mount "/sys/fs/cgroup" in the container's mount namespace.
 	submount.mount.Type = tmpfs.Name
 	mnt, err := c.mountSubmount(ctx, spec, conf, mns, creds, submount)
@@ -1239,6 +1281,46 @@ func (c *containerMounter) mountCgroupSubmounts(ctx context.Context, spec *specs
 	return nil
 }
 
+// mountCgroup2Submount is the cgroup v2 counterpart of mountCgroupSubmounts.
+// The container's cgroup is created under the root of the unified hierarchy
+// and bind mounted directly at the submount destination.
+func (c *containerMounter) mountCgroup2Submount(ctx context.Context, mns *vfs.MountNamespace, creds *auth.Credentials, submount *mountInfo, cgroupMnt *kernel.CgroupMount) error {
+	root := mns.Root(ctx)
+	defer root.DecRef(ctx)
+
+	mountCtx := vfs.WithRoot(vfs.WithMountNamespace(ctx, mns), root)
+	cgroupMntVD := vfs.MakeVirtualDentry(cgroupMnt.Mount, cgroupMnt.Root)
+	sourcePop := vfs.PathOperation{
+		Root:  cgroupMntVD,
+		Start: cgroupMntVD,
+		// Use the containerID as the cgroup path.
+		Path: fspath.Parse(c.containerID),
+	}
+	if err := c.k.VFS().MkdirAt(mountCtx, creds, &sourcePop, &vfs.MkdirOptions{
+		Mode: 0755,
+	}); err != nil {
+		log.Infof("error in creating directory %v", err)
+		return err
+	}
+
+	destination := submount.mount.Destination
+	if err := c.k.VFS().MakeSyntheticMountpoint(mountCtx, destination, root, creds); err != nil {
+		// Log a warning, but attempt the mount anyway.
+		log.Warningf("Failed to create mount point %q: %v", destination, err)
+	}
+	target := &vfs.PathOperation{
+		Root:  root,
+		Start: root,
+		Path:  fspath.Parse(destination),
+	}
+	if err := c.k.VFS().BindAt(mountCtx, creds, &sourcePop, target, false); err != nil {
+		log.Infof("error in bind mounting %v", err)
+		return err
+	}
+	c.cgroupsMounted = true
+	return nil
+}
+
 // mountSharedMaster mounts the master of a volume that is shared among
 // containers in a pod.
 func (c *containerMounter) mountSharedMaster(ctx context.Context, spec *specs.Spec, conf *config.Config, mntInfo *mountInfo, creds *auth.Credentials) (*vfs.Mount, error) {
@@ -278,6 +278,7 @@ func forEachCmd(cb func(cmd subcommands.Command, group string)) {
 	const helperGroup = "helpers"
 	cb(new(cmd.Install), helperGroup)
 	cb(new(cmd.Mitigate), helperGroup)
+	cb(new(cmd.Network), helperGroup)
 	cb(new(cmd.Uninstall), helperGroup)
 	cb(new(nvproxy.Nvproxy), helperGroup)
 	cb(new(trace.Trace), helperGroup)
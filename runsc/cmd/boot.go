@@ -132,6 +132,9 @@ type Boot struct {
 	// totalHostMem is the total memory reported by host /proc/meminfo.
 	totalHostMem uint64
 
+	// cgroupV2 indicates that the host uses cgroup v2 only.
+	cgroupV2 bool
+
 	// userLogFD is the file descriptor to write user logs to.
 	userLogFD int
 
@@ -214,6 +217,7 @@ func (b *Boot) SetFlags(f *flag.FlagSet) {
 	f.IntVar(&b.syncUsernsFD, "sync-userns-fd", -1, "file descriptor used to synchronize rootless user namespace initialization.")
 	f.Uint64Var(&b.totalMem, "total-memory", 0, "sets the initial amount of total memory to report back to the container")
 	f.Uint64Var(&b.totalHostMem, "total-host-memory", 0, "total memory reported by host /proc/meminfo")
+	f.BoolVar(&b.cgroupV2, "cgroup-v2", false, "if true, mount the cgroup v2 unified hierarchy in containers instead of cgroup v1 controllers")
 	f.BoolVar(&b.attached, "attached", false, "if attached is true, kills the sandbox process when the parent process terminates")
 	f.StringVar(&b.productName, "product-name", "", "value to show in /sys/devices/virtual/dmi/id/product_name")
 	f.StringVar(&b.nvidiaDriverVersion, "nvidia-driver-version", "", "Nvidia driver version on the host")
@@ -526,6 +530,7 @@ func (b *Boot) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcomma
 		ProfileOpts:         b.profileFDs.ToOpts(),
 		NvidiaDriverVersion: nvidiaDriverVersion,
 		HostTHP:             b.hostTHP,
+		CgroupV2:            b.cgroupV2,
 		SaveFDs:             b.saveFDs.GetFDs(),
 	}
 	l, err := boot.New(bootArgs)
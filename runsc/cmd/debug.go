@@ -16,6 +16,7 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"strconv"
@@ -25,8 +26,10 @@ import (
 
 	"github.com/google/subcommands"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/bpf"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/cmd/util"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
@@ -50,6 +53,12 @@ type Debug struct {
 	duration     time.Duration
 	ps           bool
 	mount        string
+
+	capture         string
+	captureFile     string
+	captureFilter   string
+	captureSnapLen  uint
+	captureAnnotate bool
 }
 
 // Name implements subcommands.Command.
@@ -84,6 +93,11 @@ func (d *Debug) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&d.logPackets, "log-packets", "", "A boolean value to enable or disable packet logging: true or false.")
 	f.BoolVar(&d.ps, "ps", false, "lists processes")
 	f.StringVar(&d.mount, "mount", "", "Mount a filesystem (-mount fstype:source:destination).")
+	f.StringVar(&d.capture, "capture", "", "captures the packets of the given network interface for -duration, or until interrupted.")
+	f.StringVar(&d.captureFile, "capture-file", "", "writes the packets captured by -capture to the given file, in pcapng format.")
+	f.StringVar(&d.captureFilter, "capture-filter", "", `only captures packets matching the given classic BPF program, in "tcpdump -ddd" format. Instructions may be separated by newlines or commas.`)
+	f.UintVar(&d.captureSnapLen, "capture-snaplen", 0, "maximum number of bytes captured per packet. 0 uses the default.")
+	f.BoolVar(&d.captureAnnotate, "capture-annotate", false, "annotates captured packets with the PID and container ID of the task owning the socket that sends or receives them.")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -286,15 +300,40 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcomm
 		}
 		traceFile = f
 	}
+	var captureArgs *boot.StartCaptureArgs
+	if d.capture != "" {
+		if d.captureFile == "" {
+			return util.Errorf("-capture requires -capture-file")
+		}
+		captureArgs = &boot.StartCaptureArgs{
+			Name:     d.capture,
+			SnapLen:  uint32(d.captureSnapLen),
+			Annotate: d.captureAnnotate,
+		}
+		if d.captureFilter != "" {
+			filter, err := parseCaptureFilter(d.captureFilter)
+			if err != nil {
+				return util.Errorf("invalid -capture-filter: %v", err)
+			}
+			captureArgs.Filter = filter
+		}
+		f, err := os.OpenFile(d.captureFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return util.Errorf("error opening capture output: %v", err)
+		}
+		defer f.Close()
+		captureArgs.FilePayload.Files = []*os.File{f}
+	}
 
 	// Collect profiles.
 	var (
-		wg       sync.WaitGroup
-		blockErr error
-		cpuErr   error
-		heapErr  error
-		mutexErr error
-		traceErr error
+		wg         sync.WaitGroup
+		blockErr   error
+		cpuErr     error
+		heapErr    error
+		mutexErr   error
+		traceErr   error
+		captureErr error
 	)
 	if blockFile != nil {
 		wg.Add(1)
@@ -332,6 +371,25 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcomm
 		}()
 	}
 
+	// The capture is stopped early on the first signal, so that it is
+	// complete even if interrupted.
+	stopCapture := make(chan struct{})
+	if captureArgs != nil {
+		util.Infof("Capturing packets of interface %q to %q", d.capture, d.captureFile)
+		if err := c.Sandbox.StartCapture(captureArgs); err != nil {
+			return util.Errorf("%s", err.Error())
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-time.After(d.duration):
+			case <-stopCapture:
+			}
+			captureErr = c.Sandbox.StopCapture(d.capture)
+		}()
+	}
+
 	// Before sleeping, allow us to catch signals and try to exit
 	// gracefully before just exiting. If we can't wait for wg, then
 	// we will not be able to read the errors below safely.
@@ -347,6 +405,7 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcomm
 		break // Safe to proceed.
 	case <-signals:
 		util.Infof("caught signal, waiting at most one more second.")
+		close(stopCapture)
 		select {
 		case <-signals:
 			util.Infof("caught second signal, exiting immediately.")
@@ -386,6 +445,10 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcomm
 		util.Infof("error collecting trace profile: %v", traceErr)
 		os.Remove(traceFile.Name())
 	}
+	if captureErr != nil {
+		errorCount++
+		util.Infof("error capturing packets: %v", captureErr)
+	}
 
 	if errorCount > 0 {
 		return subcommands.ExitFailure
@@ -393,3 +456,45 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcomm
 
 	return subcommands.ExitSuccess
 }
+
+// parseCaptureFilter parses a classic BPF program in the format printed by
+// "tcpdump -ddd": the number of instructions, followed by one instruction per
+// line as decimal "code jt jf k". Lines may also be separated by commas.
+func parseCaptureFilter(s string) ([]bpf.Instruction, error) {
+	var lines []string
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty program")
+	}
+	n, err := strconv.Atoi(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid instruction count %q: %v", lines[0], err)
+	}
+	if n != len(lines)-1 {
+		return nil, fmt.Errorf("program has %d instructions, but its count is %d", len(lines)-1, n)
+	}
+	insns := make([]bpf.Instruction, 0, n)
+	for i, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("instruction %d: %q doesn't have 4 fields", i, line)
+		}
+		var vals [4]uint64
+		for j, bits := range []int{16, 8, 8, 32} {
+			if vals[j], err = strconv.ParseUint(fields[j], 10, bits); err != nil {
+				return nil, fmt.Errorf("instruction %d: %q: %v", i, line, err)
+			}
+		}
+		insns = append(insns, bpf.Instruction{
+			OpCode:      uint16(vals[0]),
+			JumpIfTrue:  uint8(vals[1]),
+			JumpIfFalse: uint8(vals[2]),
+			K:           uint32(vals[3]),
+		})
+	}
+	return insns, nil
+}
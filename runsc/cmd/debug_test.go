@@ -0,0 +1,62 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gvisor.dev/gvisor/pkg/bpf"
+)
+
+func TestParseCaptureFilter(t *testing.T) {
+	// "tcpdump -ddd ip proto 6" on a raw IP interface.
+	want := []bpf.Instruction{
+		{OpCode: 48, JumpIfTrue: 0, JumpIfFalse: 0, K: 9},
+		{OpCode: 21, JumpIfTrue: 0, JumpIfFalse: 1, K: 6},
+		{OpCode: 6, JumpIfTrue: 0, JumpIfFalse: 0, K: 262144},
+		{OpCode: 6, JumpIfTrue: 0, JumpIfFalse: 0, K: 0},
+	}
+	for _, tc := range []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{name: "newlines", in: "4\n48 0 0 9\n21 0 1 6\n6 0 0 262144\n6 0 0 0\n"},
+		{name: "commas", in: "4,48 0 0 9,21 0 1 6,6 0 0 262144,6 0 0 0"},
+		{name: "spaces", in: " 4 ,\n 48 0 0 9 , 21 0 1 6\n\n6 0 0 262144, 6 0 0 0 "},
+		{name: "empty", in: "\n", wantErr: true},
+		{name: "bad count", in: "3\n48 0 0 9\n21 0 1 6\n6 0 0 262144\n6 0 0 0", wantErr: true},
+		{name: "missing field", in: "1\n6 0 0", wantErr: true},
+		{name: "overflow", in: "1\n6 256 0 0", wantErr: true},
+		{name: "not a number", in: "1\nret 0 0 0", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseCaptureFilter(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("parseCaptureFilter(%q) succeeded, want error", tc.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCaptureFilter(%q): %v", tc.in, err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("parseCaptureFilter(%q) mismatch (-want +got):\n%s", tc.in, diff)
+			}
+		})
+	}
+}
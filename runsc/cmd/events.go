@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/subcommands"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/cmd/util"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
@@ -80,6 +81,9 @@ func (evs *Events) Execute(_ context.Context, f *flag.FlagSet, args ...any) subc
 		util.Fatalf("loading sandbox: %v", err)
 	}
 
+	// oomKills is the number of OOM kills that have been reported.
+	var oomKills uint64
+
 	// Repeatedly get stats from the container. Sleep a bit after every loop
 	// except the first one.
 	for dur := time.Duration(evs.intervalSec) * time.Second; true; time.Sleep(dur) {
@@ -94,6 +98,16 @@ func (evs *Events) Execute(_ context.Context, f *flag.FlagSet, args ...any) subc
 		}
 		log.Debugf("Events: %+v", ev)
 
+		// Report an OOM event if tasks in the container have been OOM-killed
+		// since the previous event, or before the first one.
+		if ev.OOMKills > oomKills {
+			oomEv := boot.Event{Type: "oom", ID: ev.Event.ID}
+			if err := json.NewEncoder(os.Stdout).Encode(oomEv); err != nil {
+				log.Warningf("Error encoding event %+v: %v", oomEv, err)
+			}
+		}
+		oomKills = ev.OOMKills
+
 		if err := json.NewEncoder(os.Stdout).Encode(ev.Event); err != nil {
 			log.Warningf("Error encoding event %+v: %v", ev.Event, err)
 			if evs.stats {
@@ -50,6 +50,9 @@ func (c *cmd) Execute(ctx context.Context, f *flag.FlagSet, args ...any) subcomm
 		ExporterPrefix:         c.Cmd.ExporterPrefix,
 		ExposeProfileEndpoints: c.Cmd.ExposeProfileEndpoints,
 		AllowUnknownRoot:       c.Cmd.AllowUnknownRoot,
+		OTLPEndpoint:           c.Cmd.OTLPEndpoint,
+		OTLPInterval:           c.Cmd.OTLPInterval,
+		OTLPHeaders:            c.Cmd.OTLPHeaders,
 	}
 	if err := server.Run(ctx); err != nil {
 		return util.Errorf("%v", err)
@@ -16,6 +16,11 @@
 package metricservercmd
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"gvisor.dev/gvisor/runsc/flag"
 )
 
@@ -25,6 +30,43 @@ type Cmd struct {
 	PIDFile                string
 	ExposeProfileEndpoints bool
 	AllowUnknownRoot       bool
+	OTLPEndpoint           string
+	OTLPInterval           time.Duration
+	OTLPHeaders            Headers
+}
+
+// Headers is a repeatable flag of "key=value" HTTP headers.
+type Headers map[string]string
+
+// String implements flag.Value.String.
+func (h *Headers) String() string {
+	keys := make([]string, 0, len(*h))
+	for k := range *h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = fmt.Sprintf("%s=%s", k, (*h)[k])
+	}
+	return strings.Join(keys, ",")
+}
+
+// Set implements flag.Value.Set.
+func (h *Headers) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("invalid header %q, must be key=value", s)
+	}
+	if *h == nil {
+		*h = make(Headers)
+	}
+	(*h)[k] = v
+	return nil
+}
+
+// Get implements flag.Getter.Get.
+func (h *Headers) Get() any {
+	return *h
 }
 
 // Name implements subcommands.Command.Name.
@@ -48,4 +90,7 @@ func (c *Cmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.PIDFile, "pid-file", "", "If set, write the metric server's own PID to this file after binding to the --metric-server address. The parent directory of this file must already exist.")
 	f.BoolVar(&c.ExposeProfileEndpoints, "allow-profiling", false, "If true, expose /runsc-metrics/profile-cpu and /runsc-metrics/profile-heap to get profiling data about the metric server")
 	f.BoolVar(&c.AllowUnknownRoot, "allow-unknown-root", false, "if set, the metric server will keep running regardless of the existence of --root or the metric server's ability to access it.")
+	f.StringVar(&c.OTLPEndpoint, "otlp-endpoint", "", "If set, periodically push sandbox metrics to this OpenTelemetry collector URL using OTLP/HTTP with protobuf encoding. If the URL has no path, /v1/metrics is used.")
+	f.DurationVar(&c.OTLPInterval, "otlp-interval", time.Minute, "Interval at which metrics are pushed to --otlp-endpoint.")
+	f.Var(&c.OTLPHeaders, "otlp-header", "HTTP header to send to --otlp-endpoint, as key=value. May be repeated.")
 }
@@ -0,0 +1,242 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/cmd/util"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// Network implements subcommands.Command for the "network" command.
+type Network struct{}
+
+// Name implements subcommands.Command.
+func (*Network) Name() string {
+	return "network"
+}
+
+// Synopsis implements subcommands.Command.
+func (*Network) Synopsis() string {
+	return "manages the network links of a running sandbox"
+}
+
+// Usage implements subcommands.Command.
+func (*Network) Usage() string {
+	buf := bytes.Buffer{}
+	buf.WriteString("Usage: network <flags> <subcommand> <subcommand args>\n\n")
+
+	cdr := createNetworkCommander(&flag.FlagSet{})
+	cdr.VisitGroups(func(grp *subcommands.CommandGroup) {
+		cdr.ExplainGroup(&buf, grp)
+	})
+
+	return buf.String()
+}
+
+// SetFlags implements subcommands.Command.
+func (*Network) SetFlags(f *flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (*Network) Execute(ctx context.Context, f *flag.FlagSet, args ...any) subcommands.ExitStatus {
+	return createNetworkCommander(f).Execute(ctx, args...)
+}
+
+func createNetworkCommander(f *flag.FlagSet) *subcommands.Commander {
+	cdr := subcommands.NewCommander(f, "network")
+	cdr.Register(cdr.HelpCommand(), "")
+	cdr.Register(cdr.FlagsCommand(), "")
+	cdr.Register(new(networkAddLink), "")
+	cdr.Register(new(networkRemoveLink), "")
+	cdr.Register(new(networkUpdateRoutes), "")
+	return cdr
+}
+
+// loadNetworkContainer loads the container with the given ID.
+func loadNetworkContainer(conf *config.Config, id string) *container.Container {
+	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		util.Fatalf("loading container: %v", err)
+	}
+	return c
+}
+
+// networkAddLink implements subcommands.Command for the "network add-link"
+// command.
+type networkAddLink struct{}
+
+// Name implements subcommands.Command.
+func (*networkAddLink) Name() string {
+	return "add-link"
+}
+
+// Synopsis implements subcommands.Command.
+func (*networkAddLink) Synopsis() string {
+	return "moves an interface of the sandbox network namespace into the running sandbox"
+}
+
+// Usage implements subcommands.Command.
+func (*networkAddLink) Usage() string {
+	return `add-link <container id> <interface> - moves an interface into the sandbox.
+
+The interface must exist in the network namespace of the sandbox, e.g. after
+being added by a CNI plugin. As at sandbox start, its addresses, routes and
+static neighbors are copied into the sandbox and its addresses are removed from
+the host.
+`
+}
+
+// SetFlags implements subcommands.Command.
+func (*networkAddLink) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (*networkAddLink) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	conf := args[0].(*config.Config)
+	c := loadNetworkContainer(conf, f.Arg(0))
+	if err := c.AddNetworkLink(conf, f.Arg(1)); err != nil {
+		util.Fatalf("adding network link: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// networkRemoveLink implements subcommands.Command for the "network
+// remove-link" command.
+type networkRemoveLink struct{}
+
+// Name implements subcommands.Command.
+func (*networkRemoveLink) Name() string {
+	return "remove-link"
+}
+
+// Synopsis implements subcommands.Command.
+func (*networkRemoveLink) Synopsis() string {
+	return "removes a link from the running sandbox"
+}
+
+// Usage implements subcommands.Command.
+func (*networkRemoveLink) Usage() string {
+	return "remove-link <container id> <link>\n"
+}
+
+// SetFlags implements subcommands.Command.
+func (*networkRemoveLink) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (*networkRemoveLink) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	conf := args[0].(*config.Config)
+	c := loadNetworkContainer(conf, f.Arg(0))
+	if err := c.RemoveNetworkLink(f.Arg(1)); err != nil {
+		util.Fatalf("removing network link: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// networkUpdateRoutes implements subcommands.Command for the "network
+// update-routes" command.
+type networkUpdateRoutes struct{}
+
+// Name implements subcommands.Command.
+func (*networkUpdateRoutes) Name() string {
+	return "update-routes"
+}
+
+// Synopsis implements subcommands.Command.
+func (*networkUpdateRoutes) Synopsis() string {
+	return "replaces the routes through a link of the running sandbox"
+}
+
+// Usage implements subcommands.Command.
+func (*networkUpdateRoutes) Usage() string {
+	return `update-routes <container id> <link> [<destination>[,<gateway>]...] - replaces the routes through a link.
+
+Destinations are given in CIDR notation, or as "default" for a default route,
+which requires a gateway. All routes through the link in the main routing
+table are replaced, so passing no routes removes them.
+
+EXAMPLE:
+
+	# runsc network update-routes mypod eth1 10.1.0.0/16 default,10.1.0.1
+`
+}
+
+// SetFlags implements subcommands.Command.
+func (*networkUpdateRoutes) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (*networkUpdateRoutes) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcommands.ExitStatus {
+	if f.NArg() < 2 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	var routes []boot.Route
+	for _, arg := range f.Args()[2:] {
+		r, err := parseRoute(arg)
+		if err != nil {
+			util.Fatalf("invalid route %q: %v", arg, err)
+		}
+		routes = append(routes, r)
+	}
+	conf := args[0].(*config.Config)
+	c := loadNetworkContainer(conf, f.Arg(0))
+	if err := c.UpdateNetworkRoutes(f.Arg(1), routes); err != nil {
+		util.Fatalf("updating network routes: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// parseRoute parses a route of the form <destination>[,<gateway>].
+func parseRoute(s string) (boot.Route, error) {
+	dst, gw, hasGW := strings.Cut(s, ",")
+	var r boot.Route
+	if hasGW {
+		if r.Gateway = net.ParseIP(gw); r.Gateway == nil {
+			return boot.Route{}, fmt.Errorf("invalid gateway %q", gw)
+		}
+	}
+	if dst == "default" {
+		switch {
+		case r.Gateway == nil:
+			return boot.Route{}, fmt.Errorf("default route requires a gateway")
+		case r.Gateway.To4() != nil:
+			r.Destination = net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)}
+		default:
+			r.Destination = net.IPNet{IP: net.IPv6zero, Mask: net.IPMask(net.IPv6zero)}
+		}
+		return r, nil
+	}
+	_, ipNet, err := net.ParseCIDR(dst)
+	if err != nil {
+		return boot.Route{}, err
+	}
+	r.Destination = *ipNet
+	return r, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		dst     string
+		gateway string
+		wantErr bool
+	}{
+		{in: "10.1.0.0/16", dst: "10.1.0.0/16"},
+		{in: "10.1.2.3/16", dst: "10.1.0.0/16"},
+		{in: "10.1.0.0/16,10.0.0.1", dst: "10.1.0.0/16", gateway: "10.0.0.1"},
+		{in: "default,10.0.0.1", dst: "0.0.0.0/0", gateway: "10.0.0.1"},
+		{in: "default,fe80::1", dst: "::/0", gateway: "fe80::1"},
+		{in: "default", wantErr: true},
+		{in: "10.1.0.0", wantErr: true},
+		{in: "10.1.0.0/16,gateway", wantErr: true},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			r, err := parseRoute(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseRoute(%q) succeeded with %+v, want error", tc.in, r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRoute(%q) failed: %v", tc.in, err)
+			}
+			if got := r.Destination.String(); got != tc.dst {
+				t.Errorf("parseRoute(%q) destination = %s, want %s", tc.in, got, tc.dst)
+			}
+			var gw string
+			if r.Gateway != nil {
+				gw = r.Gateway.String()
+			}
+			if gw != tc.gateway {
+				t.Errorf("parseRoute(%q) gateway = %q, want %q", tc.in, gw, tc.gateway)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/google/subcommands"
 	"gvisor.dev/gvisor/pkg/log"
@@ -39,8 +40,11 @@ import (
 
 // PortForward implements subcommands.Command for the "portforward" command.
 type PortForward struct {
-	portNum int
-	stream  string
+	portNum    int
+	stream     string
+	listen     string
+	udp        bool
+	udpTimeout time.Duration
 }
 
 // Name implements subcommands.Command.Name.
@@ -57,9 +61,15 @@ func (*PortForward) Synopsis() string {
 func (*PortForward) Usage() string {
 	return `port-forward CONTAINER_ID [LOCAL_PORT:]REMOTE_PORT - port forward to gvisor container.
 
-Port forwarding has two modes. Local mode opens a local port and forwards
-connections to another port inside the specified container. Stream mode
-forwards a single connection on a UDS to the specified port in the container.
+Port forwarding has three modes. Local mode opens a local port and forwards
+connections to another port inside the specified container. Listen mode does the
+same on an arbitrary local address. Stream mode forwards a single connection on
+a UDS to the specified port in the container.
+
+With --udp, datagrams are forwarded to a UDP port instead. Each local peer gets
+its own flow into the container, which is torn down after --udp-timeout without
+traffic. In stream mode the UDS must then be a SOCK_SEQPACKET socket, each
+message of which carries one datagram.
 
 EXAMPLES:
 
@@ -73,6 +83,11 @@ The following will forward a single new connection on the unix domain socket at
 
 	# runsc port-forward --stream /tmp/pipe nginx 80
 
+The following will forward DNS queries received on 127.0.0.1:5353 to UDP port 53
+in the container named 'dns':
+
+	# runsc port-forward --udp --listen 127.0.0.1:5353 dns 53
+
 OPTIONS:
 `
 }
@@ -80,6 +95,9 @@ OPTIONS:
 // SetFlags implements subcommands.Command.SetFlags.
 func (p *PortForward) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&p.stream, "stream", "", "Stream mode - a Unix domain socket")
+	f.StringVar(&p.listen, "listen", "", "Listen mode - a local address to accept connections on, e.g. 127.0.0.1:8080")
+	f.BoolVar(&p.udp, "udp", false, "forward UDP datagrams instead of TCP connections")
+	f.DurationVar(&p.udpTimeout, "udp-timeout", time.Minute, "idle time after which a UDP flow is torn down")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -99,6 +117,10 @@ func (p *PortForward) Execute(ctx context.Context, f *flag.FlagSet, args ...any)
 		util.Fatalf("loading container: %v", err)
 	}
 
+	if p.stream != "" && p.listen != "" {
+		util.Fatalf("--stream and --listen are mutually exclusive")
+	}
+
 	if p.stream != "" {
 		if err := p.doStream(ctx, portStr, c); err != nil {
 			util.Fatalf("doStream: %v", err)
@@ -106,37 +128,54 @@ func (p *PortForward) Execute(ctx context.Context, f *flag.FlagSet, args ...any)
 		return subcommands.ExitSuccess
 	}
 
-	// Allow forwarding to a local port.
-	ports := strings.Split(portStr, ":")
-	if len(ports) != 2 {
-		util.Fatalf("invalid port string %q", portStr)
+	var localAddr string
+	if p.listen != "" {
+		// The local address is given explicitly, so only the container port
+		// is expected.
+		localAddr = p.listen
+	} else {
+		// Allow forwarding to a local port.
+		ports := strings.Split(portStr, ":")
+		if len(ports) != 2 {
+			util.Fatalf("invalid port string %q", portStr)
+		}
+		localPort, err := strconv.Atoi(ports[0])
+		if err != nil {
+			util.Fatalf("invalid port string %q: %v", portStr, err)
+		}
+		localAddr = ":" + strconv.Itoa(localPort)
+		portStr = ports[1]
 	}
 
-	localPort, err := strconv.Atoi(ports[0])
-	if err != nil {
-		util.Fatalf("invalid port string %q: %v", portStr, err)
-	}
-	portNum, err := strconv.Atoi(ports[1])
+	portNum, err := strconv.Atoi(portStr)
 	if err != nil {
 		util.Fatalf("invalid port string %q: %v", portStr, err)
 	}
 	if portNum <= 0 || portNum > math.MaxUint16 {
-		util.Fatalf("invalid port %d: %v", portNum, err)
+		util.Fatalf("invalid port %d", portNum)
 	}
 
-	// Start port forwarding with the local port.
+	// Start port forwarding with the local address.
 	var wg sync.WaitGroup
 	ctx, cancel := context.WithCancel(ctx)
 	wg.Add(1)
-	go func(localPort, portNum int) {
+	go func(localAddr string, portNum int) {
 		defer cancel()
 		defer wg.Done()
-		// Print message to local user.
-		fmt.Printf("Forwarding local port %d to %d...\n", localPort, portNum)
-		if err := localForward(ctx, c, localPort, uint16(portNum)); err != nil {
+		var err error
+		if p.udp {
+			// Print message to local user.
+			fmt.Printf("Forwarding local UDP address %s to %d...\n", localAddr, portNum)
+			err = localForwardUDP(ctx, c, localAddr, uint16(portNum), p.udpTimeout)
+		} else {
+			// Print message to local user.
+			fmt.Printf("Forwarding local address %s to %d...\n", localAddr, portNum)
+			err = localForward(ctx, c, localAddr, uint16(portNum))
+		}
+		if err != nil {
 			log.Warningf("port forwarding: %v", err)
 		}
-	}(localPort, portNum)
+	}(localAddr, portNum)
 
 	// Exit port forwarding if the container exits.
 	go func() {
@@ -160,9 +199,9 @@ func (p *PortForward) Execute(ctx context.Context, f *flag.FlagSet, args ...any)
 	return subcommands.ExitSuccess
 }
 
-// localForward starts port forwarding from the given local port.
-func localForward(ctx context.Context, c *container.Container, localPort int, containerPort uint16) error {
-	l, err := net.Listen("tcp", ":"+strconv.Itoa(localPort))
+// localForward starts port forwarding from the given local address.
+func localForward(ctx context.Context, c *container.Container, localAddr string, containerPort uint16) error {
+	l, err := net.Listen("tcp", localAddr)
 	if err != nil {
 		return err
 	}
@@ -220,7 +259,11 @@ func (p *PortForward) doStream(ctx context.Context, port string, c *container.Co
 		return fmt.Errorf("invalid port %d: %v", p.portNum, err)
 	}
 
-	f, err := openStream(p.stream)
+	stype := syscall.SOCK_STREAM
+	if p.udp {
+		stype = syscall.SOCK_SEQPACKET
+	}
+	f, err := openSocket(p.stream, stype)
 	if err != nil {
 		return fmt.Errorf("opening uds stream: %v", err)
 	}
@@ -229,6 +272,7 @@ func (p *PortForward) doStream(ctx context.Context, port string, c *container.Co
 	if err := c.PortForward(&boot.PortForwardOpts{
 		Port:        uint16(p.portNum),
 		ContainerID: c.ID,
+		UDP:         p.udp,
 		FilePayload: urpc.FilePayload{Files: []*os.File{f}},
 	}); err != nil {
 		return fmt.Errorf("PortForward: %v", err)
@@ -265,6 +309,7 @@ func portCopy(ctx context.Context, c *container.Container, localConn net.Conn, p
 	// handled via the UDS from then on.
 	if err := c.PortForward(&boot.PortForwardOpts{
 		Port:        port,
+		ContainerID: c.ID,
 		FilePayload: urpc.FilePayload{Files: []*os.File{streamFile}},
 	}); err != nil {
 		return fmt.Errorf("PortForward: %v", err)
@@ -319,6 +364,168 @@ func portCopy(ctx context.Context, c *container.Container, localConn net.Conn, p
 	}
 }
 
+// maxUDPPayload is the largest datagram that can be forwarded.
+const maxUDPPayload = 65535
+
+// udpFlow forwards the datagrams of a single local peer to the container.
+type udpFlow struct {
+	// conn is the local end of the SOCK_SEQPACKET socket pair whose other
+	// end was donated to the sentry.
+	conn *net.UnixConn
+	// peer is the local address that datagrams are sent back to.
+	peer net.Addr
+
+	mu sync.Mutex
+	// lastActive is the last time a datagram was forwarded in either
+	// direction.
+	lastActive time.Time
+}
+
+// newUDPFlow starts forwarding datagrams for peer to the given UDP port in
+// the container.
+func newUDPFlow(c *container.Container, peer net.Addr, port uint16) (*udpFlow, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("creating socket pair: %v", err)
+	}
+	local := os.NewFile(uintptr(fds[0]), "port-forward-udp-local")
+	defer local.Close()
+	remote := os.NewFile(uintptr(fds[1]), "port-forward-udp-remote")
+	defer remote.Close()
+
+	conn, err := net.FileConn(local)
+	if err != nil {
+		return nil, fmt.Errorf("creating conn from socket: %v", err)
+	}
+	if err := c.PortForward(&boot.PortForwardOpts{
+		Port:        port,
+		ContainerID: c.ID,
+		UDP:         true,
+		FilePayload: urpc.FilePayload{Files: []*os.File{remote}},
+	}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("PortForward: %v", err)
+	}
+	return &udpFlow{
+		conn:       conn.(*net.UnixConn),
+		peer:       peer,
+		lastActive: time.Now(),
+	}, nil
+}
+
+// touch records activity on the flow.
+func (f *udpFlow) touch() {
+	f.mu.Lock()
+	f.lastActive = time.Now()
+	f.mu.Unlock()
+}
+
+// idle returns how long the flow has been without traffic.
+func (f *udpFlow) idle() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Since(f.lastActive)
+}
+
+// copyToPeer copies datagrams from the container back to the peer through
+// pc. It returns once the flow has been idle for timeout or fails.
+func (f *udpFlow) copyToPeer(pc net.PacketConn, timeout time.Duration) error {
+	buf := make([]byte, maxUDPPayload)
+	for {
+		f.conn.SetReadDeadline(time.Now().Add(timeout))
+		n, err := f.conn.Read(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				if f.idle() >= timeout {
+					return nil
+				}
+				continue
+			}
+			return err
+		}
+		if n == 0 {
+			// The sentry closed its end of the flow.
+			return io.EOF
+		}
+		f.touch()
+		if _, err := pc.WriteTo(buf[:n], f.peer); err != nil {
+			return err
+		}
+	}
+}
+
+// localForwardUDP starts forwarding datagrams from the given local address.
+// Every local peer is tracked as a separate flow with its own socket in the
+// container, so that replies are sent back to the peer they belong to.
+func localForwardUDP(ctx context.Context, c *container.Container, localAddr string, containerPort uint16, timeout time.Duration) error {
+	pc, err := net.ListenPacket("udp", localAddr)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	var (
+		mu    sync.Mutex
+		flows = make(map[string]*udpFlow)
+		wg    sync.WaitGroup
+	)
+	defer func() {
+		mu.Lock()
+		for _, f := range flows {
+			f.conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	}()
+
+	// Unblock ReadFrom below when the context is done.
+	stop := context.AfterFunc(ctx, func() { pc.Close() })
+	defer stop()
+
+	buf := make([]byte, maxUDPPayload)
+	for {
+		n, peer, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		key := peer.String()
+		mu.Lock()
+		f, ok := flows[key]
+		if !ok {
+			f, err = newUDPFlow(c, peer, containerPort)
+			if err != nil {
+				mu.Unlock()
+				log.Warningf("creating UDP flow for %q: %v", key, err)
+				continue
+			}
+			flows[key] = f
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fmt.Printf("Forwarding new UDP flow from %s...\n", key)
+				if err := f.copyToPeer(pc, timeout); err != nil && ctx.Err() == nil {
+					log.Warningf("port forwarding UDP flow from %q: %v", key, err)
+				}
+				mu.Lock()
+				delete(flows, key)
+				mu.Unlock()
+				f.conn.Close()
+				fmt.Printf("Finished forwarding UDP flow from %s...\n", key)
+			}()
+		}
+		mu.Unlock()
+
+		f.touch()
+		if _, err := f.conn.Write(buf[:n]); err != nil {
+			log.Warningf("forwarding datagram from %q: %v", key, err)
+		}
+	}
+}
+
 // tmpUDS generates a temporary UDS addr.
 func tmpUDSAddr() (string, error) {
 	tmpFile, err := os.CreateTemp("", "runsc-port-forward")
@@ -335,8 +542,14 @@ func tmpUDSAddr() (string, error) {
 // openStream opens a UDS as a socket and returns the file descriptor in an
 // os.File object.
 func openStream(name string) (*os.File, error) {
+	return openSocket(name, syscall.SOCK_STREAM)
+}
+
+// openSocket opens a UDS of the given socket type and returns the file
+// descriptor in an os.File object.
+func openSocket(name string, stype int) (*os.File, error) {
 	// The net package will abstract the fd, so we use raw syscalls.
-	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	fd, err := syscall.Socket(syscall.AF_UNIX, stype, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -591,6 +591,33 @@ func (c *Container) PortForward(opts *boot.PortForwardOpts) error {
 	return c.Sandbox.PortForward(opts)
 }
 
+// AddNetworkLink moves the interface with the given name from the sandbox
+// network namespace into the running sandbox.
+func (c *Container) AddNetworkLink(conf *config.Config, name string) error {
+	if err := c.requireStatus("add network link", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.AddNetworkLink(conf, c.Spec, name)
+}
+
+// RemoveNetworkLink removes the link with the given name from the running
+// sandbox.
+func (c *Container) RemoveNetworkLink(name string) error {
+	if err := c.requireStatus("remove network link", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.RemoveNetworkLink(name)
+}
+
+// UpdateNetworkRoutes replaces the routes through the link with the given name
+// in the running sandbox.
+func (c *Container) UpdateNetworkRoutes(name string, routes []boot.Route) error {
+	if err := c.requireStatus("update network routes", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.UpdateNetworkRoutes(name, routes)
+}
+
 // SandboxPid returns the Getpid of the sandbox the container is running in, or -1 if the
 // container is not running.
 func (c *Container) SandboxPid() int {
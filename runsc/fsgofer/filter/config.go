@@ -42,6 +42,7 @@ var allowedSyscalls = seccomp.MakeSyscallRules(map[uintptr]seccomp.SyscallRule{
 	},
 	unix.SYS_EXIT:       seccomp.MatchAll{},
 	unix.SYS_EXIT_GROUP: seccomp.MatchAll{},
+	unix.SYS_FCHDIR:     seccomp.MatchAll{}, // Used by fsgofer.addWatchThread().
 	unix.SYS_FCHMOD:     seccomp.MatchAll{},
 	unix.SYS_FCHMODAT:   seccomp.MatchAll{},
 	unix.SYS_FCHOWNAT:   seccomp.MatchAll{},
@@ -63,6 +64,11 @@ var allowedSyscalls = seccomp.MakeSyscallRules(map[uintptr]seccomp.SyscallRule{
 			seccomp.AnyValue{},
 			seccomp.EqualTo(unix.F_ADD_SEALS),
 		},
+		// Used by lisafs.WatchInitHandler.
+		seccomp.PerArg{
+			seccomp.AnyValue{},
+			seccomp.EqualTo(unix.F_DUPFD_CLOEXEC),
+		},
 	},
 	unix.SYS_FSTAT: seccomp.MatchAll{},
 	unix.SYS_FSYNC: seccomp.MatchAll{},
@@ -106,10 +112,16 @@ var allowedSyscalls = seccomp.MakeSyscallRules(map[uintptr]seccomp.SyscallRule{
 	unix.SYS_GETRANDOM:    seccomp.MatchAll{},
 	unix.SYS_GETTID:       seccomp.MatchAll{},
 	unix.SYS_GETTIMEOFDAY: seccomp.MatchAll{},
-	unix.SYS_LGETXATTR:    seccomp.MatchAll{},
-	unix.SYS_LSEEK:        seccomp.MatchAll{},
-	unix.SYS_MADVISE:      seccomp.MatchAll{},
-	unix.SYS_MEMFD_CREATE: seccomp.MatchAll{}, // Used by flipcall.PacketWindowAllocator.Init().
+	// inotify is used to watch host files on behalf of the sandbox.
+	unix.SYS_INOTIFY_ADD_WATCH: seccomp.MatchAll{},
+	unix.SYS_INOTIFY_INIT1: seccomp.PerArg{
+		seccomp.EqualTo(unix.IN_NONBLOCK | unix.IN_CLOEXEC),
+	},
+	unix.SYS_INOTIFY_RM_WATCH: seccomp.MatchAll{},
+	unix.SYS_LGETXATTR:        seccomp.MatchAll{},
+	unix.SYS_LSEEK:            seccomp.MatchAll{},
+	unix.SYS_MADVISE:          seccomp.MatchAll{},
+	unix.SYS_MEMFD_CREATE:     seccomp.MatchAll{}, // Used by flipcall.PacketWindowAllocator.Init().
 	unix.SYS_MMAP: seccomp.Or{
 		seccomp.PerArg{
 			seccomp.AnyValue{},
@@ -195,6 +207,10 @@ var allowedSyscalls = seccomp.MakeSyscallRules(map[uintptr]seccomp.SyscallRule{
 	unix.SYS_TGKILL: seccomp.PerArg{
 		seccomp.EqualTo(uint64(os.Getpid())),
 	},
+	// Used by fsgofer.addWatchThread().
+	unix.SYS_UNSHARE: seccomp.PerArg{
+		seccomp.EqualTo(unix.CLONE_FS),
+	},
 	unix.SYS_WRITE: seccomp.MatchAll{},
 })
 
@@ -192,6 +192,9 @@ func (s *LisafsServer) SupportedMessages() []lisafs.MID {
 		lisafs.Listen,
 		lisafs.Accept,
 		lisafs.ConnectWithCreds,
+		lisafs.WatchInit,
+		lisafs.AddWatch,
+		lisafs.RemoveWatch,
 	}
 }
 
@@ -837,6 +840,74 @@ func (fd *controlFDLisa) Connect(sockType uint32) (int, error) {
 	return sock, nil
 }
 
+// AddWatch implements lisafs.ControlFDImpl.AddWatch.
+func (fd *controlFDLisa) AddWatch(inotifyFD int, mask uint32) (int32, error) {
+	addWatchThreadOnce.Do(func() {
+		addWatchRequests = make(chan *addWatchRequest)
+		go addWatchThread()
+	})
+	req := addWatchRequest{
+		inotifyFD: inotifyFD,
+		hostFD:    fd.hostFD,
+		// The watch is added through the FD's magic link in /proc/self/fd,
+		// which must be followed.
+		mask: mask &^ unix.IN_DONT_FOLLOW,
+		done: make(chan addWatchResult, 1),
+	}
+	addWatchRequests <- &req
+	res := <-req.done
+	if res.err != nil {
+		return -1, res.err
+	}
+	return int32(res.wd), nil
+}
+
+// addWatchRequest is a request to add an inotify watch on hostFD, which is
+// served by addWatchThread.
+type addWatchRequest struct {
+	inotifyFD int
+	hostFD    int
+	mask      uint32
+	done      chan addWatchResult
+}
+
+type addWatchResult struct {
+	wd  int
+	err error
+}
+
+var (
+	addWatchThreadOnce sync.Once
+	addWatchRequests   chan *addWatchRequest
+)
+
+// addWatchThread serves addWatchRequests.
+//
+// inotify_add_watch(2) only accepts a path, and /proc is not available inside
+// the gofer's chroot. addWatchThread therefore changes its working directory
+// to procSelfFD, so that each file descriptor can be watched through its
+// magic link, which refers to exactly the file represented by the FD.
+func addWatchThread() {
+	// This goroutine holds the current thread forever. It can't serve other
+	// goroutines, because it does unshare CLONE_FS.
+	runtime.LockOSThread()
+	err := unix.Unshare(unix.CLONE_FS)
+	if err == nil {
+		err = unix.Fchdir(int(procSelfFD.FD()))
+	}
+	if err != nil {
+		log.Warningf("Failed to set up inotify watch thread: %v", err)
+	}
+	for req := range addWatchRequests {
+		if err != nil {
+			req.done <- addWatchResult{wd: -1, err: err}
+			continue
+		}
+		wd, addErr := unix.InotifyAddWatch(req.inotifyFD, strconv.Itoa(req.hostFD), req.mask)
+		req.done <- addWatchResult{wd: wd, err: addErr}
+	}
+}
+
 // ConnectWithCreds implements lisafs.ControlFDImpl.ConnectWithCreds.
 func (fd *controlFDLisa) ConnectWithCreds(sockType uint32, uid lisafs.UID, gid lisafs.GID) (int, error) {
 	serverConfig := fd.Conn().ServerImpl().(*LisafsServer).config
@@ -15,8 +15,15 @@
 package lisafs_test
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
+	"unsafe"
 
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/lisafs"
 	"gvisor.dev/gvisor/pkg/lisafs/testsuite"
 	"gvisor.dev/gvisor/pkg/log"
@@ -61,3 +68,59 @@ func (tester) BindSupported() bool {
 func TestFSGofer(t *testing.T) {
 	testsuite.RunAllLocalFSTests(t, tester{})
 }
+
+// TestAddWatch checks that watches are added on the file represented by the
+// control FD, without changing the gofer's working directory.
+func TestAddWatch(t *testing.T) {
+	mountPath, err := os.MkdirTemp(os.Getenv("TEST_TMPDIR"), "")
+	if err != nil {
+		t.Fatalf("creation of temporary mountpoint failed: %v", err)
+	}
+	defer os.RemoveAll(mountPath)
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("os.Getwd() failed: %v", err)
+	}
+
+	testsuite.RunTest(t, tester{}, "AddWatch", func(ctx context.Context, t *testing.T, tester testsuite.Tester, root lisafs.ClientFD) {
+		inotifyFD, err := root.Client().WatchInit(ctx)
+		if err != nil {
+			t.Fatalf("WatchInit failed: %v", err)
+		}
+		defer unix.Close(inotifyFD)
+
+		wd, err := root.AddWatch(ctx, linux.IN_CREATE)
+		if err != nil {
+			t.Fatalf("AddWatch failed: %v", err)
+		}
+		defer root.Client().RemoveWatch(ctx, wd)
+		if got, err := os.Getwd(); err != nil || got != cwd {
+			t.Errorf("working directory after AddWatch: got %q (err: %v), want %q", got, err, cwd)
+		}
+
+		// Changes made on the host, outside of the gofer, are reported.
+		f, err := os.Create(filepath.Join(mountPath, "file"))
+		if err != nil {
+			t.Fatalf("os.Create() failed: %v", err)
+		}
+		f.Close()
+
+		pfd := []unix.PollFd{{Fd: int32(inotifyFD), Events: unix.POLLIN}}
+		if n, err := unix.Poll(pfd, 5000 /* ms */); err != nil || n != 1 {
+			t.Fatalf("poll on inotify FD returned n=%d, err=%v", n, err)
+		}
+		buf := make([]byte, unix.SizeofInotifyEvent+unix.NAME_MAX+1)
+		n, err := unix.Read(inotifyFD, buf)
+		if err != nil {
+			t.Fatalf("reading inotify event failed: %v", err)
+		}
+		if n < unix.SizeofInotifyEvent {
+			t.Fatalf("short inotify event read: %d bytes", n)
+		}
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+		name := string(bytes.TrimRight(buf[unix.SizeofInotifyEvent:unix.SizeofInotifyEvent+int(event.Len)], "\x00"))
+		if event.Wd != wd || event.Mask != linux.IN_CREATE || name != "file" {
+			t.Errorf("got event {wd: %d, mask: %#x, name: %q}, want {wd: %d, mask: %#x, name: %q}", event.Wd, event.Mask, name, wd, linux.IN_CREATE, "file")
+		}
+	}, mountPath)
+}
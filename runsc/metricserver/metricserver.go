@@ -39,6 +39,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/control"
 	"gvisor.dev/gvisor/pkg/state"
 	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
 	"gvisor.dev/gvisor/runsc/metricserver/containermetrics"
@@ -260,6 +261,10 @@ type metricServer struct {
 	// Used to efficiently reallocate a map of the right size during the next export.
 	lastMetricsWrittenSize atomicbitops.Uint32
 
+	// otlp, if set, periodically pushes metrics to an OTLP collector.
+	// Once set, it is immutable.
+	otlp *otlpExporter
+
 	// Pool of `prometheus.ReusableWriter`s. Used to avoid large buffer allocations for
 	// successive snapshots.
 	promWriterPool sync.Pool
@@ -536,6 +541,10 @@ type sandboxMetricsResult struct {
 	isRestored     bool
 	snapshot       *prometheus.Snapshot
 	err            error
+
+	// containerStats is the resource usage of the sandbox's containers.
+	// It is nil if it could not be queried.
+	containerStats *boot.ContainerStatsOut
 }
 
 // queryMultiSandboxMetrics queries metric data from multiple loaded sandboxes.
@@ -575,10 +584,17 @@ func queryMultiSandboxMetrics(ctx context.Context, loadedSandboxes []sandboxLoad
 				isCheckpointed := false
 				isRestored := false
 				var snapshot *prometheus.Snapshot
+				var containerStats *boot.ContainerStatsOut
 				err := s.err
 				if err == nil {
 					queryCtx, queryCtxCancel := context.WithTimeout(ctx, perSandboxTime)
 					snapshot, err = querySandboxMetrics(queryCtx, s.sandbox, s.verifier, metricsFilter)
+					if err == nil {
+						var statsErr error
+						if containerStats, statsErr = queryContainerStats(queryCtx, s.sandbox); statsErr != nil {
+							log.Warningf("Could not query container stats from sandbox %s: %v", s.served.rootContainerID.SandboxID, statsErr)
+						}
+					}
 					queryCtxCancel()
 					isRunning = s.sandbox.IsRunning()
 					isCheckpointed = s.sandbox.Checkpointed
@@ -591,6 +607,7 @@ func queryMultiSandboxMetrics(ctx context.Context, loadedSandboxes []sandboxLoad
 					isRestored:        isRestored,
 					snapshot:          snapshot,
 					err:               err,
+					containerStats:    containerStats,
 				})
 			}
 		}()
@@ -678,7 +695,7 @@ func (m *metricServer) serveMetrics(w *httpResponseWriter, req *http.Request) ht
 		snapshot *prometheus.Snapshot
 		options  prometheus.SnapshotExportOptions
 	}
-	snapshotCh := make(chan snapshotAndOptions, numSandboxes)
+	snapshotCh := make(chan snapshotAndOptions, 2*numSandboxes)
 
 	queryMultiSandboxMetrics(ctx, loadedSandboxes, metricsFilter, func(r sandboxMetricsResult) {
 		metricsMu.Lock()
@@ -731,10 +748,20 @@ func (m *metricServer) serveMetrics(w *httpResponseWriter, req *http.Request) ht
 				ExtraLabels:    r.served.extraLabels,
 			},
 		}
+		if r.containerStats != nil {
+			snapshotCh <- snapshotAndOptions{
+				snapshot: containerStatsSnapshot(r.containerStats, r.served.extraLabels, r.served.rootContainerID.ContainerID),
+				options: prometheus.SnapshotExportOptions{
+					// Per-container metrics follow cAdvisor's naming and are written
+					// without any prefix.
+					ExtraLabels: r.served.extraLabels,
+				},
+			}
+		}
 	})
 
 	// Build the map of all snapshots we will be rendering.
-	snapshotsToOptions := make(map[*prometheus.Snapshot]prometheus.SnapshotExportOptions, numSandboxes+2)
+	snapshotsToOptions := make(map[*prometheus.Snapshot]prometheus.SnapshotExportOptions, 2*numSandboxes+2)
 	snapshotsToOptions[selfMetrics] = prometheus.SnapshotExportOptions{
 		ExporterPrefix: fmt.Sprintf("%s%s", m.exporterPrefix, prometheus.MetaMetricPrefix),
 	}
@@ -838,6 +865,18 @@ type Server struct {
 	// AllowUnknownRoot causes the metric server to keep running regardless of the existence of the
 	// Config's root directory or the metric server's ability to access it.
 	AllowUnknownRoot bool
+
+	// OTLPEndpoint, if set, is the URL of an OpenTelemetry collector to which sandbox metrics are
+	// periodically pushed using OTLP/HTTP with protobuf encoding. If the URL has no path, metrics
+	// are pushed to /v1/metrics.
+	OTLPEndpoint string
+
+	// OTLPInterval is the interval at which metrics are pushed to OTLPEndpoint.
+	// If zero, DefaultOTLPInterval is used.
+	OTLPInterval time.Duration
+
+	// OTLPHeaders are extra HTTP headers sent to OTLPEndpoint, e.g. for authentication.
+	OTLPHeaders map[string]string
 }
 
 // Run runs the metric server.
@@ -857,6 +896,13 @@ func (s *Server) Run(ctx context.Context) error {
 			},
 		},
 	}
+	if s.OTLPEndpoint != "" {
+		otlp, err := newOTLPExporter(s.OTLPEndpoint, s.OTLPInterval, s.OTLPHeaders)
+		if err != nil {
+			return err
+		}
+		m.otlp = otlp
+	}
 	conf := s.Config
 	if conf.MetricServer == "" {
 		return errors.New("config does not specify the metric server address (--metric-server)")
@@ -952,6 +998,10 @@ func (s *Server) Run(ctx context.Context) error {
 	if err := m.startVerifyLoop(ctx); err != nil {
 		return fmt.Errorf("cannot start background loop: %w", err)
 	}
+	if m.otlp != nil {
+		log.Infof("Pushing metrics to OTLP endpoint %s every %v.", m.otlp.endpoint, m.otlp.interval)
+		m.startOTLPLoop(ctx)
+	}
 	if m.pidFile != "" {
 		if err := os.WriteFile(m.pidFile, []byte(fmt.Sprintf("%d", m.pid)), 0644); err != nil {
 			return fmt.Errorf("cannot write PID to file %q: %w", m.pidFile, err)
@@ -0,0 +1,143 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricserver
+
+import (
+	"context"
+
+	"gvisor.dev/gvisor/pkg/prometheus"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/sandbox"
+)
+
+const (
+	// maxContainersPerSandbox is the maximum number of containers per sandbox
+	// for which per-container metrics are exported. Container stats are
+	// reported by the sandbox, which is not trusted, so this bounds the
+	// number of time series that a sandbox can create.
+	maxContainersPerSandbox = 256
+
+	// maxInterfacesPerSandbox is the maximum number of network interfaces per
+	// sandbox for which network metrics are exported, for the same reason.
+	maxInterfacesPerSandbox = 64
+)
+
+// queryContainerStats queries the sandbox for the resource usage of its
+// containers.
+func queryContainerStats(ctx context.Context, sand *sandbox.Sandbox) (*boot.ContainerStatsOut, error) {
+	ch := make(chan struct {
+		stats *boot.ContainerStatsOut
+		err   error
+	}, 1)
+	go func() {
+		stats, err := sand.ContainerStats()
+		ch <- struct {
+			stats *boot.ContainerStatsOut
+			err   error
+		}{stats, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case ret := <-ch:
+		return ret.stats, ret.err
+	}
+}
+
+// containerStatsSnapshot returns a snapshot of per-container metrics from
+// the container stats of a sandbox. sandboxLabels are the sandbox's labels,
+// and rootContainerID is the ID of the sandbox's root container.
+//
+// Network interfaces are shared by all containers of a sandbox, so network
+// metrics are attributed to the root container, like cAdvisor attributes
+// them to the pod's infrastructure container.
+func containerStatsSnapshot(stats *boot.ContainerStatsOut, sandboxLabels map[string]string, rootContainerID string) *prometheus.Snapshot {
+	snapshot := prometheus.NewSnapshot()
+	seen := make(map[string]bool, len(stats.Containers))
+	var rootLabels map[string]string
+	for i := range stats.Containers {
+		c := &stats.Containers[i]
+		if c.ID == "" || seen[c.ID] {
+			continue
+		}
+		if len(seen) == maxContainersPerSandbox {
+			break
+		}
+		seen[c.ID] = true
+		labels := containerLabels(c.ID, c.Name, sandboxLabels)
+		if c.ID == rootContainerID {
+			rootLabels = labels
+		}
+		snapshot.Add(
+			prometheus.LabeledFloatData(&ContainerCPUUsageMetric, labels, float64(c.CPUUsage)/1e9),
+			prometheus.LabeledIntData(&ContainerMemoryUsageMetric, labels, int64(c.MemoryUsage)),
+			// The sentry doesn't track inactive file-backed memory separately, so
+			// the working set is the memory usage.
+			prometheus.LabeledIntData(&ContainerMemoryWorkingSetMetric, labels, int64(c.MemoryUsage)),
+			prometheus.LabeledIntData(&ContainerFSReadsBytesMetric, labels, int64(c.ReadBytes)),
+			prometheus.LabeledIntData(&ContainerFSWritesBytesMetric, labels, int64(c.WriteBytes)),
+			prometheus.LabeledIntData(&ContainerFSReadsMetric, labels, int64(c.Reads)),
+			prometheus.LabeledIntData(&ContainerFSWritesMetric, labels, int64(c.Writes)),
+		)
+	}
+	if rootLabels == nil {
+		rootLabels = containerLabels(rootContainerID, "", sandboxLabels)
+	}
+	seenInterfaces := make(map[string]bool, len(stats.NetworkInterfaces))
+	for _, iface := range stats.NetworkInterfaces {
+		if iface == nil || iface.Name == "" || seenInterfaces[iface.Name] {
+			continue
+		}
+		if len(seenInterfaces) == maxInterfacesPerSandbox {
+			break
+		}
+		seenInterfaces[iface.Name] = true
+		labels := make(map[string]string, len(rootLabels)+1)
+		for k, v := range rootLabels {
+			labels[k] = v
+		}
+		labels[ContainerInterfaceLabel] = iface.Name
+		snapshot.Add(
+			prometheus.LabeledIntData(&ContainerNetworkReceiveBytesMetric, labels, int64(iface.RxBytes)),
+			prometheus.LabeledIntData(&ContainerNetworkReceivePacketsMetric, labels, int64(iface.RxPackets)),
+			prometheus.LabeledIntData(&ContainerNetworkReceiveErrorsMetric, labels, int64(iface.RxErrors)),
+			prometheus.LabeledIntData(&ContainerNetworkReceiveDroppedMetric, labels, int64(iface.RxDropped)),
+			prometheus.LabeledIntData(&ContainerNetworkTransmitBytesMetric, labels, int64(iface.TxBytes)),
+			prometheus.LabeledIntData(&ContainerNetworkTransmitPacketsMetric, labels, int64(iface.TxPackets)),
+			prometheus.LabeledIntData(&ContainerNetworkTransmitErrorsMetric, labels, int64(iface.TxErrors)),
+			prometheus.LabeledIntData(&ContainerNetworkTransmitDroppedMetric, labels, int64(iface.TxDropped)),
+		)
+	}
+	return snapshot
+}
+
+// containerLabels returns the cAdvisor-style labels of a container. If the
+// container's name is unknown, its ID is used as name.
+func containerLabels(id, name string, sandboxLabels map[string]string) map[string]string {
+	if name == "" {
+		name = id
+	}
+	labels := map[string]string{
+		ContainerNameLabel: name,
+		ContainerIDLabel:   id,
+	}
+	if pod := sandboxLabels[prometheus.PodNameLabel]; pod != "" {
+		labels[ContainerPodLabel] = pod
+	}
+	if namespace := sandboxLabels[prometheus.NamespaceLabel]; namespace != "" {
+		labels[ContainerNamespaceLabel] = namespace
+	}
+	return labels
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricserver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gvisor.dev/gvisor/pkg/prometheus"
+	"gvisor.dev/gvisor/runsc/boot"
+)
+
+// snapshotValues returns the values in snapshot, keyed by metric name and
+// the given label.
+func snapshotValues(snapshot *prometheus.Snapshot, label string) map[string]float64 {
+	values := make(map[string]float64)
+	for _, d := range snapshot.Data {
+		values[fmt.Sprintf("%s{%s}", d.Metric.Name, d.Labels[label])] = d.Number.ToFloat()
+	}
+	return values
+}
+
+// TestContainerStatsSnapshot tests that per-container stats are exported as
+// cAdvisor-style metrics.
+func TestContainerStatsSnapshot(t *testing.T) {
+	sandboxLabels := map[string]string{
+		prometheus.SandboxIDLabel: "sandbox",
+		prometheus.PodNameLabel:   "pod",
+		prometheus.NamespaceLabel: "namespace",
+	}
+	stats := &boot.ContainerStatsOut{
+		Containers: []boot.ContainerStats{
+			{ID: "root", CPUUsage: 1e6},
+			{
+				ID:          "app",
+				Name:        "nginx",
+				CPUUsage:    2500e6,
+				MemoryUsage: 4096,
+				ReadBytes:   100,
+				WriteBytes:  200,
+				Reads:       3,
+				Writes:      4,
+			},
+			// Duplicates are ignored.
+			{ID: "app", Name: "impostor", CPUUsage: 1},
+		},
+		NetworkInterfaces: []*boot.NetworkInterface{
+			{Name: "eth0", RxBytes: 1000, TxBytes: 2000, RxPackets: 10, TxPackets: 20},
+		},
+	}
+	snapshot := containerStatsSnapshot(stats, sandboxLabels, "root")
+
+	want := map[string]float64{
+		"container_cpu_usage_seconds_total{root}":                1e-3,
+		"container_memory_usage_bytes{root}":                     0,
+		"container_memory_working_set_bytes{root}":               0,
+		"container_fs_reads_bytes_total{root}":                   0,
+		"container_fs_writes_bytes_total{root}":                  0,
+		"container_fs_reads_total{root}":                         0,
+		"container_fs_writes_total{root}":                        0,
+		"container_cpu_usage_seconds_total{nginx}":               2.5,
+		"container_memory_usage_bytes{nginx}":                    4096,
+		"container_memory_working_set_bytes{nginx}":              4096,
+		"container_fs_reads_bytes_total{nginx}":                  100,
+		"container_fs_writes_bytes_total{nginx}":                 200,
+		"container_fs_reads_total{nginx}":                        3,
+		"container_fs_writes_total{nginx}":                       4,
+		"container_network_receive_bytes_total{root}":            1000,
+		"container_network_receive_packets_total{root}":          10,
+		"container_network_receive_errors_total{root}":           0,
+		"container_network_receive_packets_dropped_total{root}":  0,
+		"container_network_transmit_bytes_total{root}":           2000,
+		"container_network_transmit_packets_total{root}":         20,
+		"container_network_transmit_errors_total{root}":          0,
+		"container_network_transmit_packets_dropped_total{root}": 0,
+	}
+	if diff := cmp.Diff(want, snapshotValues(snapshot, ContainerNameLabel)); diff != "" {
+		t.Errorf("unexpected metrics (-want +got):\n%s", diff)
+	}
+
+	for _, d := range snapshot.Data {
+		if got := d.Labels[ContainerPodLabel]; got != "pod" {
+			t.Errorf("%s: got pod label %q, want %q", d.Metric.Name, got, "pod")
+		}
+		if got := d.Labels[ContainerNamespaceLabel]; got != "namespace" {
+			t.Errorf("%s: got namespace label %q, want %q", d.Metric.Name, got, "namespace")
+		}
+		if d.Labels[ContainerNameLabel] == "nginx" && d.Labels[ContainerIDLabel] != "app" {
+			t.Errorf("%s: got id label %q, want %q", d.Metric.Name, d.Labels[ContainerIDLabel], "app")
+		}
+		if d.Metric.Name == ContainerNetworkReceiveBytesMetric.Name && d.Labels[ContainerInterfaceLabel] != "eth0" {
+			t.Errorf("%s: got interface label %q, want %q", d.Metric.Name, d.Labels[ContainerInterfaceLabel], "eth0")
+		}
+	}
+}
+
+// TestContainerStatsSnapshotLimit tests that a sandbox cannot create an
+// unbounded number of time series.
+func TestContainerStatsSnapshotLimit(t *testing.T) {
+	stats := &boot.ContainerStatsOut{}
+	for i := 0; i < 2*maxContainersPerSandbox; i++ {
+		stats.Containers = append(stats.Containers, boot.ContainerStats{ID: fmt.Sprintf("c%d", i)})
+	}
+	for i := 0; i < 2*maxInterfacesPerSandbox; i++ {
+		stats.NetworkInterfaces = append(stats.NetworkInterfaces, &boot.NetworkInterface{Name: fmt.Sprintf("eth%d", i)})
+	}
+	snapshot := containerStatsSnapshot(stats, nil, "c0")
+	containers := make(map[string]bool)
+	interfaces := make(map[string]bool)
+	for _, d := range snapshot.Data {
+		containers[d.Labels[ContainerIDLabel]] = true
+		if iface, ok := d.Labels[ContainerInterfaceLabel]; ok {
+			interfaces[iface] = true
+		}
+	}
+	if len(containers) != maxContainersPerSandbox {
+		t.Errorf("got metrics for %d containers, want %d", len(containers), maxContainersPerSandbox)
+	}
+	if len(interfaces) != maxInterfacesPerSandbox {
+		t.Errorf("got metrics for %d interfaces, want %d", len(interfaces), maxInterfacesPerSandbox)
+	}
+}
@@ -88,6 +88,94 @@ var (
 	}
 )
 
+// Per-container metrics, computed from the sentry's own accounting. They
+// follow cAdvisor's naming so that existing dashboards work with gVisor pods,
+// and are exported without the exporter prefix.
+var (
+	ContainerCPUUsageMetric = prometheus.Metric{
+		Name: "container_cpu_usage_seconds_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative cpu time consumed in seconds.",
+	}
+	ContainerMemoryUsageMetric = prometheus.Metric{
+		Name: "container_memory_usage_bytes",
+		Type: prometheus.TypeGauge,
+		Help: "Current memory usage in bytes.",
+	}
+	ContainerMemoryWorkingSetMetric = prometheus.Metric{
+		Name: "container_memory_working_set_bytes",
+		Type: prometheus.TypeGauge,
+		Help: "Current working set in bytes.",
+	}
+	ContainerFSReadsBytesMetric = prometheus.Metric{
+		Name: "container_fs_reads_bytes_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of bytes read.",
+	}
+	ContainerFSWritesBytesMetric = prometheus.Metric{
+		Name: "container_fs_writes_bytes_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of bytes written.",
+	}
+	ContainerFSReadsMetric = prometheus.Metric{
+		Name: "container_fs_reads_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of reads completed.",
+	}
+	ContainerFSWritesMetric = prometheus.Metric{
+		Name: "container_fs_writes_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of writes completed.",
+	}
+	ContainerNetworkReceiveBytesMetric = prometheus.Metric{
+		Name: "container_network_receive_bytes_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of bytes received.",
+	}
+	ContainerNetworkReceivePacketsMetric = prometheus.Metric{
+		Name: "container_network_receive_packets_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of packets received.",
+	}
+	ContainerNetworkReceiveErrorsMetric = prometheus.Metric{
+		Name: "container_network_receive_errors_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of errors encountered while receiving.",
+	}
+	ContainerNetworkReceiveDroppedMetric = prometheus.Metric{
+		Name: "container_network_receive_packets_dropped_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of packets dropped while receiving.",
+	}
+	ContainerNetworkTransmitBytesMetric = prometheus.Metric{
+		Name: "container_network_transmit_bytes_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of bytes transmitted.",
+	}
+	ContainerNetworkTransmitPacketsMetric = prometheus.Metric{
+		Name: "container_network_transmit_packets_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of packets transmitted.",
+	}
+	ContainerNetworkTransmitErrorsMetric = prometheus.Metric{
+		Name: "container_network_transmit_errors_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of errors encountered while transmitting.",
+	}
+	ContainerNetworkTransmitDroppedMetric = prometheus.Metric{
+		Name: "container_network_transmit_packets_dropped_total",
+		Type: prometheus.TypeCounter,
+		Help: "Cumulative count of packets dropped while transmitting.",
+	}
+
+	// Labels of per-container metrics, following cAdvisor.
+	ContainerNameLabel      = "container"
+	ContainerIDLabel        = "id"
+	ContainerPodLabel       = "pod"
+	ContainerNamespaceLabel = "namespace"
+	ContainerInterfaceLabel = "interface"
+)
+
 // Metrics is a list of metrics that the metric server generates.
 var Metrics = []*prometheus.Metric{
 	&SandboxPresenceMetric,
@@ -103,5 +191,20 @@ var Metrics = []*prometheus.Metric{
 	&NumTotalSandboxesMetric,
 	&NumCheckpointedSandboxesMetric,
 	&NumRestoredSandboxesMetric,
+	&ContainerCPUUsageMetric,
+	&ContainerMemoryUsageMetric,
+	&ContainerMemoryWorkingSetMetric,
+	&ContainerFSReadsBytesMetric,
+	&ContainerFSWritesBytesMetric,
+	&ContainerFSReadsMetric,
+	&ContainerFSWritesMetric,
+	&ContainerNetworkReceiveBytesMetric,
+	&ContainerNetworkReceivePacketsMetric,
+	&ContainerNetworkReceiveErrorsMetric,
+	&ContainerNetworkReceiveDroppedMetric,
+	&ContainerNetworkTransmitBytesMetric,
+	&ContainerNetworkTransmitPacketsMetric,
+	&ContainerNetworkTransmitErrorsMetric,
+	&ContainerNetworkTransmitDroppedMetric,
 	&prometheus.ProcessStartTimeSeconds,
 }
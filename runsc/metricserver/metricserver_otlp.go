@@ -0,0 +1,443 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/prometheus"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+const (
+	// DefaultOTLPInterval is the default interval at which metrics are pushed
+	// to an OTLP collector.
+	DefaultOTLPInterval = time.Minute
+
+	// otlpMetricsPath is the path to which metrics are pushed if the OTLP
+	// endpoint doesn't specify one, per the OTLP/HTTP specification.
+	otlpMetricsPath = "/v1/metrics"
+
+	// otlpContentType is the content type of OTLP/HTTP protobuf requests.
+	otlpContentType = "application/x-protobuf"
+
+	// otlpScopeName is the name of the instrumentation scope of exported
+	// metrics.
+	otlpScopeName = "gvisor.dev/gvisor/runsc/metricserver"
+
+	// otlpMaxErrorBody is the maximum number of bytes of a collector's error
+	// response that are logged.
+	otlpMaxErrorBody = 1024
+)
+
+// otlpResourceAttributes maps the per-sandbox labels attached to sandbox
+// metrics onto OTLP resource attributes. Labels that aren't in this map are
+// exported as resource attributes under their own name.
+var otlpResourceAttributes = map[string]string{
+	prometheus.SandboxIDLabel:   "gvisor.sandbox.id",
+	prometheus.IterationIDLabel: "gvisor.sandbox.iteration",
+	prometheus.PodNameLabel:     "k8s.pod.name",
+	prometheus.NamespaceLabel:   "k8s.namespace.name",
+}
+
+// OTLP protobuf field numbers, from
+// opentelemetry/proto/collector/metrics/v1/metrics_service.proto,
+// opentelemetry/proto/metrics/v1/metrics.proto,
+// opentelemetry/proto/resource/v1/resource.proto and
+// opentelemetry/proto/common/v1/common.proto.
+const (
+	otlpRequestResourceMetrics = 1
+
+	otlpResourceMetricsResource     = 1
+	otlpResourceMetricsScopeMetrics = 2
+
+	otlpResourceAttrs = 1
+
+	otlpScopeMetricsScope   = 1
+	otlpScopeMetricsMetrics = 2
+
+	otlpInstrumentationScopeName = 1
+
+	otlpKeyValueKey   = 1
+	otlpKeyValueValue = 2
+
+	otlpAnyValueString = 1
+
+	otlpMetricName        = 1
+	otlpMetricDescription = 2
+	otlpMetricGauge       = 5
+	otlpMetricSum         = 7
+	otlpMetricHistogram   = 9
+
+	otlpDataPoints             = 1
+	otlpAggregationTemporality = 2
+	otlpSumIsMonotonic         = 3
+
+	otlpNumberStartTime  = 2
+	otlpNumberTime       = 3
+	otlpNumberAsDouble   = 4
+	otlpNumberAsInt      = 6
+	otlpNumberAttributes = 7
+
+	otlpHistogramStartTime      = 2
+	otlpHistogramTime           = 3
+	otlpHistogramCount          = 4
+	otlpHistogramSum            = 5
+	otlpHistogramBucketCounts   = 6
+	otlpHistogramExplicitBounds = 7
+	otlpHistogramAttributes     = 9
+	otlpHistogramMin            = 11
+	otlpHistogramMax            = 12
+
+	// otlpTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+	otlpTemporalityCumulative = 2
+)
+
+// otlpExporter pushes sandbox metrics to an OTLP collector over HTTP.
+type otlpExporter struct {
+	// endpoint is the URL to which metrics are pushed.
+	endpoint string
+
+	// interval is the interval at which metrics are pushed.
+	interval time.Duration
+
+	// headers are extra HTTP headers sent with each request, e.g. for
+	// authentication.
+	headers map[string]string
+
+	// client is the HTTP client used to push metrics.
+	client http.Client
+}
+
+// newOTLPExporter returns an exporter that pushes metrics to the given
+// endpoint. If the endpoint has no path, the OTLP/HTTP default metrics path
+// is used.
+func newOTLPExporter(endpoint string, interval time.Duration, headers map[string]string) (*otlpExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: scheme must be http or https", endpoint)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: no host", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpMetricsPath
+	}
+	if interval <= 0 {
+		interval = DefaultOTLPInterval
+	}
+	return &otlpExporter{
+		endpoint: u.String(),
+		interval: interval,
+		headers:  headers,
+		client:   http.Client{Timeout: httpTimeout},
+	}, nil
+}
+
+// push sends an encoded ExportMetricsServiceRequest to the collector.
+func (e *otlpExporter) push(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", otlpContentType)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, otlpMaxErrorBody))
+		return fmt.Errorf("collector returned HTTP status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// startOTLPLoop runs in the background and periodically pushes metrics to
+// the OTLP collector.
+func (m *metricServer) startOTLPLoop(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.otlp.interval)
+		defer ticker.Stop()
+		for ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.exportOTLP(ctx); err != nil {
+					log.Warningf("Cannot push metrics to OTLP endpoint %s: %v", m.otlp.endpoint, err)
+				}
+			}
+		}
+	}()
+}
+
+// exportOTLP queries metrics from all sandboxes and pushes them to the OTLP
+// collector.
+func (m *metricServer) exportOTLP(ctx context.Context) error {
+	ctx, ctxCancel := context.WithTimeout(ctx, metricsExportTimeout)
+	defer ctxCancel()
+
+	m.mu.Lock()
+	if m.shuttingDown {
+		m.mu.Unlock()
+		return nil
+	}
+	loadedSandboxes := m.loadSandboxesLocked(ctx)
+	m.mu.Unlock()
+
+	var resourcesMu sync.Mutex
+	var resources []otlpResource // Protected by resourcesMu.
+	queryMultiSandboxMetrics(ctx, loadedSandboxes, "", func(r sandboxMetricsResult) {
+		if r.err != nil {
+			if r.isRunning {
+				log.Warningf("Could not export metrics from sandbox %s: %v", r.served.rootContainerID.SandboxID, r.err)
+			}
+			return
+		}
+		resourcesMu.Lock()
+		defer resourcesMu.Unlock()
+		resources = append(resources, otlpResource{
+			labels:    r.served.extraLabels,
+			startTime: r.served.createdAt,
+			snapshot:  r.snapshot,
+		})
+	})
+	if len(resources) == 0 {
+		return nil
+	}
+	return m.otlp.push(ctx, appendOTLPRequest(nil, m.exporterPrefix, resources))
+}
+
+// otlpResource is the metric data of a single sandbox, exported as an OTLP
+// resource.
+type otlpResource struct {
+	// labels are the sandbox's labels, mapped to resource attributes.
+	labels map[string]string
+
+	// startTime is the time from which the sandbox's cumulative metrics are
+	// accumulated.
+	startTime time.Time
+
+	// snapshot is the sandbox's metric data.
+	snapshot *prometheus.Snapshot
+}
+
+// appendOTLPRequest appends an ExportMetricsServiceRequest containing the
+// given resources to b. Metric names are prefixed with prefix.
+func appendOTLPRequest(b []byte, prefix string, resources []otlpResource) []byte {
+	for _, r := range resources {
+		b = protowire.AppendTag(b, otlpRequestResourceMetrics, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendOTLPResourceMetrics(nil, prefix, &r))
+	}
+	return b
+}
+
+// appendOTLPResourceMetrics appends a ResourceMetrics message for r to b.
+func appendOTLPResourceMetrics(b []byte, prefix string, r *otlpResource) []byte {
+	attrs := make(map[string]string, len(r.labels)+1)
+	attrs["service.name"] = "runsc"
+	for k, v := range r.labels {
+		if name, ok := otlpResourceAttributes[k]; ok {
+			k = name
+		}
+		attrs[k] = v
+	}
+	var resource []byte
+	resource = appendOTLPAttributes(resource, otlpResourceAttrs, attrs)
+	b = protowire.AppendTag(b, otlpResourceMetricsResource, protowire.BytesType)
+	b = protowire.AppendBytes(b, resource)
+
+	var scope []byte
+	scope = appendOTLPString(scope, otlpInstrumentationScopeName, otlpScopeName)
+	var scopeMetrics []byte
+	scopeMetrics = protowire.AppendTag(scopeMetrics, otlpScopeMetricsScope, protowire.BytesType)
+	scopeMetrics = protowire.AppendBytes(scopeMetrics, scope)
+
+	// Group data points by metric, preserving the order of the snapshot.
+	var metrics []*prometheus.Metric
+	points := make(map[*prometheus.Metric][]*prometheus.Data)
+	for _, d := range r.snapshot.Data {
+		if _, ok := points[d.Metric]; !ok {
+			metrics = append(metrics, d.Metric)
+		}
+		points[d.Metric] = append(points[d.Metric], d)
+	}
+	var startTime uint64
+	if !r.startTime.IsZero() {
+		startTime = uint64(r.startTime.UnixNano())
+	}
+	when := uint64(r.snapshot.When.UnixNano())
+	for _, metric := range metrics {
+		scopeMetrics = protowire.AppendTag(scopeMetrics, otlpScopeMetricsMetrics, protowire.BytesType)
+		scopeMetrics = protowire.AppendBytes(scopeMetrics, appendOTLPMetric(nil, prefix, metric, points[metric], startTime, when))
+	}
+	b = protowire.AppendTag(b, otlpResourceMetricsScopeMetrics, protowire.BytesType)
+	return protowire.AppendBytes(b, scopeMetrics)
+}
+
+// appendOTLPMetric appends a Metric message for the given data points of
+// metric to b. Counters are exported as cumulative monotonic sums,
+// histograms as cumulative explicit-bounds histograms, and all other metrics
+// as gauges.
+func appendOTLPMetric(b []byte, prefix string, metric *prometheus.Metric, data []*prometheus.Data, startTime, when uint64) []byte {
+	b = appendOTLPString(b, otlpMetricName, prefix+metric.Name)
+	b = appendOTLPString(b, otlpMetricDescription, metric.Help)
+	var body []byte
+	for _, d := range data {
+		var point []byte
+		if metric.Type == prometheus.TypeHistogram {
+			if d.HistogramValue == nil {
+				continue
+			}
+			point = appendOTLPHistogramPoint(nil, d, startTime, when)
+		} else {
+			if d.Number == nil {
+				continue
+			}
+			point = appendOTLPNumberPoint(nil, d, metric.Type == prometheus.TypeCounter, startTime, when)
+		}
+		body = protowire.AppendTag(body, otlpDataPoints, protowire.BytesType)
+		body = protowire.AppendBytes(body, point)
+	}
+	var field protowire.Number
+	switch metric.Type {
+	case prometheus.TypeCounter:
+		field = otlpMetricSum
+		body = protowire.AppendTag(body, otlpAggregationTemporality, protowire.VarintType)
+		body = protowire.AppendVarint(body, otlpTemporalityCumulative)
+		body = protowire.AppendTag(body, otlpSumIsMonotonic, protowire.VarintType)
+		body = protowire.AppendVarint(body, protowire.EncodeBool(true))
+	case prometheus.TypeHistogram:
+		field = otlpMetricHistogram
+		body = protowire.AppendTag(body, otlpAggregationTemporality, protowire.VarintType)
+		body = protowire.AppendVarint(body, otlpTemporalityCumulative)
+	default:
+		field = otlpMetricGauge
+	}
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, body)
+}
+
+// appendOTLPNumberPoint appends a NumberDataPoint message for d to b.
+// Only cumulative points carry a start time.
+func appendOTLPNumberPoint(b []byte, d *prometheus.Data, cumulative bool, startTime, when uint64) []byte {
+	b = appendOTLPAttributes(b, otlpNumberAttributes, d.Labels)
+	if cumulative && startTime != 0 {
+		b = protowire.AppendTag(b, otlpNumberStartTime, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, startTime)
+	}
+	b = protowire.AppendTag(b, otlpNumberTime, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, when)
+	if d.Number.Float == 0 {
+		b = protowire.AppendTag(b, otlpNumberAsInt, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, uint64(d.Number.Int))
+	}
+	b = protowire.AppendTag(b, otlpNumberAsDouble, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(d.Number.Float))
+}
+
+// appendOTLPHistogramPoint appends a HistogramDataPoint message for d to b.
+//
+// A prometheus.Histogram has an underflow and an overflow bucket around its
+// finite buckets, which matches OTLP's explicit bounds: the explicit bounds
+// are the upper bounds of all buckets but the overflow bucket.
+func appendOTLPHistogramPoint(b []byte, d *prometheus.Data, startTime, when uint64) []byte {
+	h := d.HistogramValue
+	b = appendOTLPAttributes(b, otlpHistogramAttributes, d.Labels)
+	if startTime != 0 {
+		b = protowire.AppendTag(b, otlpHistogramStartTime, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, startTime)
+	}
+	b = protowire.AppendTag(b, otlpHistogramTime, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, when)
+	var count uint64
+	var counts, bounds []byte
+	for i, bucket := range h.Buckets {
+		count += bucket.Samples
+		counts = protowire.AppendFixed64(counts, bucket.Samples)
+		if i != len(h.Buckets)-1 {
+			bounds = protowire.AppendFixed64(bounds, math.Float64bits(bucket.UpperBound.ToFloat()))
+		}
+	}
+	b = protowire.AppendTag(b, otlpHistogramCount, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, count)
+	b = protowire.AppendTag(b, otlpHistogramSum, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(h.Total.ToFloat()))
+	if len(counts) != 0 {
+		b = protowire.AppendTag(b, otlpHistogramBucketCounts, protowire.BytesType)
+		b = protowire.AppendBytes(b, counts)
+	}
+	if len(bounds) != 0 {
+		b = protowire.AppendTag(b, otlpHistogramExplicitBounds, protowire.BytesType)
+		b = protowire.AppendBytes(b, bounds)
+	}
+	if count != 0 {
+		b = protowire.AppendTag(b, otlpHistogramMin, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(h.Min.ToFloat()))
+		b = protowire.AppendTag(b, otlpHistogramMax, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(h.Max.ToFloat()))
+	}
+	return b
+}
+
+// appendOTLPAttributes appends attrs to b as repeated KeyValue fields with
+// the given field number, in key order.
+func appendOTLPAttributes(b []byte, field protowire.Number, attrs map[string]string) []byte {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var value []byte
+		value = protowire.AppendTag(value, otlpAnyValueString, protowire.BytesType)
+		value = protowire.AppendString(value, attrs[k])
+		var kv []byte
+		kv = appendOTLPString(kv, otlpKeyValueKey, k)
+		kv = protowire.AppendTag(kv, otlpKeyValueValue, protowire.BytesType)
+		kv = protowire.AppendBytes(kv, value)
+		b = protowire.AppendTag(b, field, protowire.BytesType)
+		b = protowire.AppendBytes(b, kv)
+	}
+	return b
+}
+
+// appendOTLPString appends s as a string field to b, unless s is empty.
+func appendOTLPString(b []byte, field protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
@@ -0,0 +1,352 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricserver
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+	"gvisor.dev/gvisor/pkg/prometheus"
+)
+
+// protoMessage is a decoded protobuf message, mapping field numbers to the
+// raw values of each occurrence of the field.
+type protoMessage map[protowire.Number][]protoValue
+
+// protoValue is the raw value of a protobuf field.
+type protoValue struct {
+	num   uint64
+	bytes []byte
+}
+
+// parseProto decodes a protobuf message without a schema.
+func parseProto(t *testing.T, b []byte) protoMessage {
+	t.Helper()
+	m := make(protoMessage)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v protoValue
+		switch typ {
+		case protowire.VarintType:
+			v.num, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v.num, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %d for field %d", typ, num)
+		}
+		if n < 0 {
+			t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		m[num] = append(m[num], v)
+	}
+	return m
+}
+
+// messages returns the occurrences of field num as messages.
+func (m protoMessage) messages(t *testing.T, num protowire.Number) []protoMessage {
+	t.Helper()
+	var msgs []protoMessage
+	for _, v := range m[num] {
+		msgs = append(msgs, parseProto(t, v.bytes))
+	}
+	return msgs
+}
+
+// message returns field num as a message, which must occur exactly once.
+func (m protoMessage) message(t *testing.T, num protowire.Number) protoMessage {
+	t.Helper()
+	msgs := m.messages(t, num)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d occurrences of field %d, want 1", len(msgs), num)
+	}
+	return msgs[0]
+}
+
+// string returns field num as a string, or "" if it is absent.
+func (m protoMessage) string(num protowire.Number) string {
+	if len(m[num]) == 0 {
+		return ""
+	}
+	return string(m[num][0].bytes)
+}
+
+// uint returns field num as a varint or fixed64, or 0 if it is absent.
+func (m protoMessage) uint(num protowire.Number) uint64 {
+	if len(m[num]) == 0 {
+		return 0
+	}
+	return m[num][0].num
+}
+
+// packedFixed64 returns field num as a packed repeated fixed64 field.
+func (m protoMessage) packedFixed64(t *testing.T, num protowire.Number) []uint64 {
+	t.Helper()
+	var vals []uint64
+	for _, v := range m[num] {
+		for b := v.bytes; len(b) > 0; {
+			val, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				t.Fatalf("invalid packed field %d: %v", num, protowire.ParseError(n))
+			}
+			vals = append(vals, val)
+			b = b[n:]
+		}
+	}
+	return vals
+}
+
+// attributes returns the KeyValue attributes in field num.
+func (m protoMessage) attributes(t *testing.T, num protowire.Number) map[string]string {
+	t.Helper()
+	attrs := make(map[string]string)
+	for _, kv := range m.messages(t, num) {
+		attrs[kv.string(otlpKeyValueKey)] = kv.message(t, otlpKeyValueValue).string(otlpAnyValueString)
+	}
+	return attrs
+}
+
+// collector is a stand-in OTLP/HTTP collector that records the requests it
+// receives.
+type collector struct {
+	srv      *httptest.Server
+	requests chan *collectedRequest
+	status   int
+}
+
+// collectedRequest is a request received by a collector.
+type collectedRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func newCollector(t *testing.T, status int) *collector {
+	c := &collector{
+		requests: make(chan *collectedRequest, 1),
+		status:   status,
+	}
+	c.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("cannot read request body: %v", err)
+		}
+		c.requests <- &collectedRequest{path: req.URL.Path, header: req.Header, body: body}
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(c.srv.Close)
+	return c
+}
+
+// TestNewOTLPExporter tests OTLP endpoint validation.
+func TestNewOTLPExporter(t *testing.T) {
+	for _, test := range []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "http://collector:4318", want: "http://collector:4318/v1/metrics"},
+		{endpoint: "https://collector:4318/", want: "https://collector:4318/v1/metrics"},
+		{endpoint: "http://collector:4318/custom/path", want: "http://collector:4318/custom/path"},
+		{endpoint: "collector:4318", wantErr: true},
+		{endpoint: "grpc://collector:4317", wantErr: true},
+		{endpoint: "http:///v1/metrics", wantErr: true},
+	} {
+		t.Run(test.endpoint, func(t *testing.T) {
+			e, err := newOTLPExporter(test.endpoint, 0, nil)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got endpoint %q, want error", e.endpoint)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newOTLPExporter: %v", err)
+			}
+			if e.endpoint != test.want {
+				t.Errorf("got endpoint %q, want %q", e.endpoint, test.want)
+			}
+			if e.interval != DefaultOTLPInterval {
+				t.Errorf("got interval %v, want %v", e.interval, DefaultOTLPInterval)
+			}
+		})
+	}
+}
+
+// TestOTLPExport tests that sandbox metrics are pushed to a collector in OTLP
+// format.
+func TestOTLPExport(t *testing.T) {
+	counter := &prometheus.Metric{Name: "fs_opens", Type: prometheus.TypeCounter, Help: "Number of opens."}
+	gauge := &prometheus.Metric{Name: "memory_usage", Type: prometheus.TypeGauge}
+	histogram := &prometheus.Metric{Name: "syscall_latency", Type: prometheus.TypeHistogram}
+
+	createdAt := time.Unix(1000, 0)
+	snapshot := &prometheus.Snapshot{When: time.Unix(2000, 0)}
+	snapshot.Add(
+		prometheus.LabeledIntData(counter, map[string]string{"type": "read"}, 42),
+		prometheus.LabeledIntData(counter, map[string]string{"type": "write"}, 7),
+		prometheus.NewFloatData(gauge, 1.5),
+		&prometheus.Data{
+			Metric: histogram,
+			// Exponential buckets with a width of 1 and a growth factor of 2,
+			// plus the underflow and overflow buckets.
+			HistogramValue: &prometheus.Histogram{
+				Total: prometheus.Number{Int: 30},
+				Min:   prometheus.Number{Int: 1},
+				Max:   prometheus.Number{Int: 10},
+				Buckets: []prometheus.Bucket{
+					{UpperBound: prometheus.Number{Int: 0}, Samples: 0},
+					{UpperBound: prometheus.Number{Int: 1}, Samples: 1},
+					{UpperBound: prometheus.Number{Int: 2}, Samples: 2},
+					{UpperBound: prometheus.Number{Int: 4}, Samples: 3},
+					{UpperBound: prometheus.Number{Float: math.Inf(1)}, Samples: 1},
+				},
+			},
+		},
+	)
+	resources := []otlpResource{{
+		labels: map[string]string{
+			prometheus.SandboxIDLabel:   "sandbox-id",
+			prometheus.IterationIDLabel: "iteration-id",
+			prometheus.PodNameLabel:     "pod",
+			prometheus.NamespaceLabel:   "namespace",
+		},
+		startTime: createdAt,
+		snapshot:  snapshot,
+	}}
+
+	c := newCollector(t, http.StatusOK)
+	e, err := newOTLPExporter(c.srv.URL, time.Second, map[string]string{"Authorization": "Bearer secret"})
+	if err != nil {
+		t.Fatalf("newOTLPExporter: %v", err)
+	}
+	if err := e.push(context.Background(), appendOTLPRequest(nil, "runsc_", resources)); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	req := <-c.requests
+	if req.path != otlpMetricsPath {
+		t.Errorf("got path %q, want %q", req.path, otlpMetricsPath)
+	}
+	if got := req.header.Get("Content-Type"); got != otlpContentType {
+		t.Errorf("got content type %q, want %q", got, otlpContentType)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("got authorization header %q, want %q", got, "Bearer secret")
+	}
+
+	rm := parseProto(t, req.body).message(t, otlpRequestResourceMetrics)
+	wantAttrs := map[string]string{
+		"service.name":             "runsc",
+		"gvisor.sandbox.id":        "sandbox-id",
+		"gvisor.sandbox.iteration": "iteration-id",
+		"k8s.pod.name":             "pod",
+		"k8s.namespace.name":       "namespace",
+	}
+	if diff := cmp.Diff(wantAttrs, rm.message(t, otlpResourceMetricsResource).attributes(t, otlpResourceAttrs)); diff != "" {
+		t.Errorf("unexpected resource attributes (-want +got):\n%s", diff)
+	}
+	sm := rm.message(t, otlpResourceMetricsScopeMetrics)
+	if got := sm.message(t, otlpScopeMetricsScope).string(otlpInstrumentationScopeName); got != otlpScopeName {
+		t.Errorf("got scope name %q, want %q", got, otlpScopeName)
+	}
+	metrics := sm.messages(t, otlpScopeMetricsMetrics)
+	var names []string
+	for _, m := range metrics {
+		names = append(names, m.string(otlpMetricName))
+	}
+	if diff := cmp.Diff([]string{"runsc_fs_opens", "runsc_memory_usage", "runsc_syscall_latency"}, names); diff != "" {
+		t.Fatalf("unexpected metrics (-want +got):\n%s", diff)
+	}
+
+	// Counters are cumulative monotonic sums.
+	if got := metrics[0].string(otlpMetricDescription); got != counter.Help {
+		t.Errorf("got counter description %q, want %q", got, counter.Help)
+	}
+	sum := metrics[0].message(t, otlpMetricSum)
+	if sum.uint(otlpAggregationTemporality) != otlpTemporalityCumulative || sum.uint(otlpSumIsMonotonic) != 1 {
+		t.Errorf("counter is not a cumulative monotonic sum")
+	}
+	gotCounts := make(map[string]int64)
+	for _, p := range sum.messages(t, otlpDataPoints) {
+		if got, want := p.uint(otlpNumberStartTime), uint64(createdAt.UnixNano()); got != want {
+			t.Errorf("got counter start time %d, want %d", got, want)
+		}
+		if got, want := p.uint(otlpNumberTime), uint64(snapshot.When.UnixNano()); got != want {
+			t.Errorf("got counter time %d, want %d", got, want)
+		}
+		gotCounts[p.attributes(t, otlpNumberAttributes)["type"]] = int64(p.uint(otlpNumberAsInt))
+	}
+	if diff := cmp.Diff(map[string]int64{"read": 42, "write": 7}, gotCounts); diff != "" {
+		t.Errorf("unexpected counter values (-want +got):\n%s", diff)
+	}
+
+	// Gauges carry their value as-is.
+	gp := metrics[1].message(t, otlpMetricGauge).message(t, otlpDataPoints)
+	if got := math.Float64frombits(gp.uint(otlpNumberAsDouble)); got != 1.5 {
+		t.Errorf("got gauge value %v, want 1.5", got)
+	}
+
+	// Histograms use the bucket upper bounds as explicit bounds.
+	h := metrics[2].message(t, otlpMetricHistogram)
+	if h.uint(otlpAggregationTemporality) != otlpTemporalityCumulative {
+		t.Errorf("histogram is not cumulative")
+	}
+	hp := h.message(t, otlpDataPoints)
+	if got := hp.uint(otlpHistogramCount); got != 7 {
+		t.Errorf("got histogram count %d, want 7", got)
+	}
+	if got := math.Float64frombits(hp.uint(otlpHistogramSum)); got != 30 {
+		t.Errorf("got histogram sum %v, want 30", got)
+	}
+	if got := math.Float64frombits(hp.uint(otlpHistogramMax)); got != 10 {
+		t.Errorf("got histogram max %v, want 10", got)
+	}
+	if diff := cmp.Diff([]uint64{0, 1, 2, 3, 1}, hp.packedFixed64(t, otlpHistogramBucketCounts)); diff != "" {
+		t.Errorf("unexpected bucket counts (-want +got):\n%s", diff)
+	}
+	var bounds []float64
+	for _, b := range hp.packedFixed64(t, otlpHistogramExplicitBounds) {
+		bounds = append(bounds, math.Float64frombits(b))
+	}
+	if diff := cmp.Diff([]float64{0, 1, 2, 4}, bounds); diff != "" {
+		t.Errorf("unexpected explicit bounds (-want +got):\n%s", diff)
+	}
+}
+
+// TestOTLPExportError tests that collector errors are reported.
+func TestOTLPExportError(t *testing.T) {
+	c := newCollector(t, http.StatusBadRequest)
+	e, err := newOTLPExporter(c.srv.URL, time.Second, nil)
+	if err != nil {
+		t.Fatalf("newOTLPExporter: %v", err)
+	}
+	if err := e.push(context.Background(), nil); err == nil {
+		t.Errorf("push succeeded despite collector error")
+	}
+	<-c.requests
+}
@@ -190,160 +190,357 @@ func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, conf *con
 			continue
 		}
 
-		var ipAddrs []*net.IPNet
-		for _, ifaddr := range allAddrs {
-			ipNet, ok := ifaddr.(*net.IPNet)
-			if !ok {
-				return fmt.Errorf("address is not IPNet: %+v", ifaddr)
-			}
-			// Do not add IPv6 addresses when IPv6 is disabled.
-			if disableIPv6 && ipNet.IP.To4() == nil {
-				continue
-			}
-			ipAddrs = append(ipAddrs, ipNet)
-		}
-		if len(ipAddrs) == 0 {
-			log.Warningf("No usable IP addresses found for interface %q, skipping", iface.Name)
-			continue
-		}
-
-		// Collect data from the ARP table.
-		dump, err := netlink.NeighList(iface.Index, 0)
+		link, err := linkFromIface(iface, allAddrs, conf, disableIPv6)
 		if err != nil {
-			return fmt.Errorf("fetching ARP table for %q: %w", iface.Name, err)
+			return err
 		}
-
-		var neighbors []boot.Neighbor
-		for _, n := range dump {
-			// There are only two "good" states NUD_PERMANENT and NUD_REACHABLE,
-			// but NUD_REACHABLE is fully dynamic and will be re-probed anyway.
-			if n.State == netlink.NUD_PERMANENT {
-				log.Debugf("Copying a static ARP entry: %+v %+v", n.IP, n.HardwareAddr)
-				// No flags are copied because Stack.AddStaticNeighbor does not support flags right now.
-				neighbors = append(neighbors, boot.Neighbor{IP: n.IP, HardwareAddr: n.HardwareAddr})
-			}
-		}
-
-		// Scrape the routes before removing the address, since that
-		// will remove the routes as well.
-		routes, defv4, defv6, err := routesForIface(iface, disableIPv6)
-		if err != nil {
-			return fmt.Errorf("getting routes for interface %q: %v", iface.Name, err)
+		if link == nil {
+			continue
 		}
-		if defv4 != nil {
+		if link.defv4 != nil {
 			if !args.Defaultv4Gateway.Route.Empty() {
-				return fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, defv4, args.Defaultv4Gateway)
+				return fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, link.defv4, args.Defaultv4Gateway)
 			}
-			args.Defaultv4Gateway.Route = *defv4
+			args.Defaultv4Gateway.Route = *link.defv4
 			args.Defaultv4Gateway.Name = iface.Name
 		}
 
-		if defv6 != nil {
+		if link.defv6 != nil {
 			if !args.Defaultv6Gateway.Route.Empty() {
-				return fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, defv6, args.Defaultv6Gateway)
+				return fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, link.defv6, args.Defaultv6Gateway)
 			}
-			args.Defaultv6Gateway.Route = *defv6
+			args.Defaultv6Gateway.Route = *link.defv6
 			args.Defaultv6Gateway.Name = iface.Name
 		}
 
-		// Get the link for the interface.
-		ifaceLink, err := netlink.LinkByName(iface.Name)
-		if err != nil {
-			return fmt.Errorf("getting link for interface %q: %w", iface.Name, err)
-		}
-		linkAddress := ifaceLink.Attrs().HardwareAddr
-
-		// Collect the addresses for the interface, enable forwarding,
-		// and remove them from the host.
-		var addresses []boot.IPWithPrefix
-		for _, addr := range ipAddrs {
-			prefix, _ := addr.Mask.Size()
-			addresses = append(addresses, boot.IPWithPrefix{Address: addr.IP, PrefixLen: prefix})
-
-			// Steal IP address from NIC.
-			if err := removeAddress(ifaceLink, addr.String()); err != nil {
-				// If we encounter an error while deleting the ip,
-				// verify the ip is still present on the interface.
-				if present, err := isAddressOnInterface(iface.Name, addr); err != nil {
-					return fmt.Errorf("checking if address %v is on interface %q: %w", addr, iface.Name, err)
-				} else if !present {
-					continue
-				}
-				return fmt.Errorf("removing address %v from device %q: %w", addr, iface.Name, err)
+		args.FilePayload.Files = append(args.FilePayload.Files, link.files...)
+		if link.xdp != nil {
+			args.XDPLinks = append(args.XDPLinks, *link.xdp)
+		} else {
+			args.FDBasedLinks = append(args.FDBasedLinks, *link.fdbased)
+		}
+	}
+
+	if err := pcapAndNAT(&args, conf); err != nil {
+		return err
+	}
+
+	log.Debugf("Setting up network, config: %+v", args)
+	if err := conn.Call(boot.NetworkCreateLinksAndRoutes, &args, nil); err != nil {
+		return fmt.Errorf("creating links and routes: %w", err)
+	}
+	return nil
+}
+
+// hostLink is a host interface that is moved into the sandbox.
+type hostLink struct {
+	// Exactly one of fdbased and xdp is set.
+	fdbased *boot.FDBasedLink
+	xdp     *boot.XDPLink
+
+	// files are the FDs backing the link in the sandbox.
+	files []*os.File
+
+	// defv4 and defv6 are the default routes through the link, if any.
+	defv4 *boot.Route
+	defv6 *boot.Route
+}
+
+// linkFromIface scrapes the addresses, routes and neighbors of iface, removes
+// its addresses from the host and creates the FDs that back the link in the
+// sandbox. It returns nil if iface has no usable addresses. It must be called
+// from the network namespace of iface.
+func linkFromIface(iface net.Interface, allAddrs []net.Addr, conf *config.Config, disableIPv6 bool) (*hostLink, error) {
+	var ipAddrs []*net.IPNet
+	for _, ifaddr := range allAddrs {
+		ipNet, ok := ifaddr.(*net.IPNet)
+		if !ok {
+			return nil, fmt.Errorf("address is not IPNet: %+v", ifaddr)
+		}
+		// Do not add IPv6 addresses when IPv6 is disabled.
+		if disableIPv6 && ipNet.IP.To4() == nil {
+			continue
+		}
+		ipAddrs = append(ipAddrs, ipNet)
+	}
+	if len(ipAddrs) == 0 {
+		log.Warningf("No usable IP addresses found for interface %q, skipping", iface.Name)
+		return nil, nil
+	}
+
+	// Collect data from the ARP table.
+	dump, err := netlink.NeighList(iface.Index, 0)
+	if err != nil {
+		return nil, fmt.Errorf("fetching ARP table for %q: %w", iface.Name, err)
+	}
+
+	var neighbors []boot.Neighbor
+	for _, n := range dump {
+		// There are only two "good" states NUD_PERMANENT and NUD_REACHABLE,
+		// but NUD_REACHABLE is fully dynamic and will be re-probed anyway.
+		if n.State == netlink.NUD_PERMANENT {
+			log.Debugf("Copying a static ARP entry: %+v %+v", n.IP, n.HardwareAddr)
+			// No flags are copied because Stack.AddStaticNeighbor does not support flags right now.
+			neighbors = append(neighbors, boot.Neighbor{IP: n.IP, HardwareAddr: n.HardwareAddr})
+		}
+	}
+
+	// Scrape the routes before removing the address, since that
+	// will remove the routes as well.
+	routes, defv4, defv6, err := routesForIface(iface, disableIPv6)
+	if err != nil {
+		return nil, fmt.Errorf("getting routes for interface %q: %v", iface.Name, err)
+	}
+	// Get the link for the interface.
+	ifaceLink, err := netlink.LinkByName(iface.Name)
+	if err != nil {
+		return nil, fmt.Errorf("getting link for interface %q: %w", iface.Name, err)
+	}
+	linkAddress := ifaceLink.Attrs().HardwareAddr
+
+	// Collect the addresses for the interface, enable forwarding,
+	// and remove them from the host.
+	var addresses []boot.IPWithPrefix
+	for _, addr := range ipAddrs {
+		prefix, _ := addr.Mask.Size()
+		addresses = append(addresses, boot.IPWithPrefix{Address: addr.IP, PrefixLen: prefix})
+
+		// Steal IP address from NIC.
+		if err := removeAddress(ifaceLink, addr.String()); err != nil {
+			// If we encounter an error while deleting the ip,
+			// verify the ip is still present on the interface.
+			if present, err := isAddressOnInterface(iface.Name, addr); err != nil {
+				return nil, fmt.Errorf("checking if address %v is on interface %q: %w", addr, iface.Name, err)
+			} else if !present {
+				continue
 			}
+			return nil, fmt.Errorf("removing address %v from device %q: %w", addr, iface.Name, err)
 		}
+	}
 
-		if conf.XDP.Mode == config.XDPModeNS {
-			xdpSockFDs, err := createSocketXDP(iface)
+	hl := &hostLink{
+		defv4: defv4,
+		defv6: defv6,
+	}
+	if conf.XDP.Mode == config.XDPModeNS {
+		xdpSockFDs, err := createSocketXDP(iface)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create XDP socket: %v", err)
+		}
+		hl.files = xdpSockFDs
+		hl.xdp = &boot.XDPLink{
+			Name:              iface.Name,
+			InterfaceIndex:    iface.Index,
+			Routes:            routes,
+			TXChecksumOffload: conf.TXChecksumOffload,
+			RXChecksumOffload: conf.RXChecksumOffload,
+			NumChannels:       conf.NumNetworkChannels,
+			QDisc:             conf.QDisc,
+			Neighbors:         neighbors,
+			LinkAddress:       linkAddress,
+			Addresses:         addresses,
+			GVisorGRO:         conf.GVisorGRO,
+		}
+	} else {
+		link := boot.FDBasedLink{
+			Name:                 iface.Name,
+			MTU:                  iface.MTU,
+			Routes:               routes,
+			TXChecksumOffload:    conf.TXChecksumOffload,
+			RXChecksumOffload:    conf.RXChecksumOffload,
+			NumChannels:          conf.NumNetworkChannels,
+			ProcessorsPerChannel: conf.NetworkProcessorsPerChannel,
+			QDisc:                conf.QDisc,
+			Neighbors:            neighbors,
+			LinkAddress:          linkAddress,
+			Addresses:            addresses,
+		}
+
+		log.Debugf("Setting up network channels")
+		// Create the socket for the device.
+		for i := 0; i < link.NumChannels; i++ {
+			log.Debugf("Creating Channel %d", i)
+			socketEntry, err := createSocket(iface, ifaceLink, conf.HostGSO)
 			if err != nil {
-				return fmt.Errorf("failed to create XDP socket: %v", err)
-			}
-			args.FilePayload.Files = append(args.FilePayload.Files, xdpSockFDs...)
-			args.XDPLinks = append(args.XDPLinks, boot.XDPLink{
-				Name:              iface.Name,
-				InterfaceIndex:    iface.Index,
-				Routes:            routes,
-				TXChecksumOffload: conf.TXChecksumOffload,
-				RXChecksumOffload: conf.RXChecksumOffload,
-				NumChannels:       conf.NumNetworkChannels,
-				QDisc:             conf.QDisc,
-				Neighbors:         neighbors,
-				LinkAddress:       linkAddress,
-				Addresses:         addresses,
-				GVisorGRO:         conf.GVisorGRO,
-			})
-		} else {
-			link := boot.FDBasedLink{
-				Name:                 iface.Name,
-				MTU:                  iface.MTU,
-				Routes:               routes,
-				TXChecksumOffload:    conf.TXChecksumOffload,
-				RXChecksumOffload:    conf.RXChecksumOffload,
-				NumChannels:          conf.NumNetworkChannels,
-				ProcessorsPerChannel: conf.NetworkProcessorsPerChannel,
-				QDisc:                conf.QDisc,
-				Neighbors:            neighbors,
-				LinkAddress:          linkAddress,
-				Addresses:            addresses,
+				return nil, fmt.Errorf("failed to createSocket for %s : %w", iface.Name, err)
 			}
-
-			log.Debugf("Setting up network channels")
-			// Create the socket for the device.
-			for i := 0; i < link.NumChannels; i++ {
-				log.Debugf("Creating Channel %d", i)
-				socketEntry, err := createSocket(iface, ifaceLink, conf.HostGSO)
-				if err != nil {
-					return fmt.Errorf("failed to createSocket for %s : %w", iface.Name, err)
+			if i == 0 {
+				link.GSOMaxSize = socketEntry.gsoMaxSize
+			} else {
+				if link.GSOMaxSize != socketEntry.gsoMaxSize {
+					return nil, fmt.Errorf("inconsistent gsoMaxSize %d and %d when creating multiple channels for same interface: %s",
+						link.GSOMaxSize, socketEntry.gsoMaxSize, iface.Name)
 				}
-				if i == 0 {
-					link.GSOMaxSize = socketEntry.gsoMaxSize
-				} else {
-					if link.GSOMaxSize != socketEntry.gsoMaxSize {
-						return fmt.Errorf("inconsistent gsoMaxSize %d and %d when creating multiple channels for same interface: %s",
-							link.GSOMaxSize, socketEntry.gsoMaxSize, iface.Name)
-					}
-				}
-				args.FilePayload.Files = append(args.FilePayload.Files, socketEntry.deviceFile)
 			}
+			hl.files = append(hl.files, socketEntry.deviceFile)
+		}
 
-			if link.GSOMaxSize == 0 && conf.GVisorGSO {
-				// Host GSO is disabled. Let's enable gVisor GSO.
-				link.GSOMaxSize = stack.GVisorGSOMaxSize
-				link.GVisorGSOEnabled = true
-			}
-			link.GVisorGRO = conf.GVisorGRO
+		if link.GSOMaxSize == 0 && conf.GVisorGSO {
+			// Host GSO is disabled. Let's enable gVisor GSO.
+			link.GSOMaxSize = stack.GVisorGSOMaxSize
+			link.GVisorGSOEnabled = true
+		}
+		link.GVisorGRO = conf.GVisorGRO
+		hl.fdbased = &link
+	}
+	return hl, nil
+}
+
+// AddNetworkLink moves the interface with the given name from the network
+// namespace of the sandbox process into the running sandbox, as is done for
+// all interfaces when the sandbox starts. It is used to attach interfaces
+// that are added after start, e.g. by chained CNI plugins.
+func (s *Sandbox) AddNetworkLink(conf *config.Config, spec *specs.Spec, name string) error {
+	log.Debugf("Adding network link %q to sandbox %q", name, s.ID)
+	if conf.Network != config.NetworkSandbox {
+		return fmt.Errorf("adding network links requires --network=%v, got %v", config.NetworkSandbox, conf.Network)
+	}
+	switch conf.XDP.Mode {
+	case config.XDPModeOff, config.XDPModeNS:
+	default:
+		return fmt.Errorf("adding network links is not supported with XDP mode %v", conf.XDP.Mode)
+	}
+	disableIPv6, err := getDisableIPv6(spec)
+	if err != nil {
+		return err
+	}
+
+	nsPath := filepath.Join("/proc", strconv.Itoa(s.Pid.load()), "ns/net")
+	args, err := addLinkArgsFromNS(nsPath, name, conf, disableIPv6)
+	if err != nil {
+		return fmt.Errorf("scraping interface %q from net namespace %q: %w", name, nsPath, err)
+	}
+
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	log.Debugf("Adding network link, config: %+v", args)
+	if err := conn.Call(boot.NetworkAddLink, args, nil); err != nil {
+		return fmt.Errorf("adding network link %q: %w", name, err)
+	}
+	return nil
+}
 
-			args.FDBasedLinks = append(args.FDBasedLinks, link)
+// addLinkArgsFromNS scrapes the interface with the given name from the net
+// namespace with the given path and removes its addresses from the host.
+func addLinkArgsFromNS(nsPath, name string, conf *config.Config, disableIPv6 bool) (*boot.AddLinkArgs, error) {
+	restore, err := joinNetNS(nsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("querying interface: %w", err)
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return nil, fmt.Errorf("interface is down")
+	}
+	if iface.Flags&net.FlagLoopback != 0 {
+		return nil, fmt.Errorf("loopback interfaces cannot be added")
+	}
+	allAddrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("fetching interface addresses: %w", err)
+	}
+	link, err := linkFromIface(*iface, allAddrs, conf, disableIPv6)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, fmt.Errorf("no usable IP addresses found")
+	}
+
+	// Default routes are routes like any other once the link exists.
+	var defaults []boot.Route
+	for _, r := range []*boot.Route{link.defv4, link.defv6} {
+		if r != nil {
+			defaults = append(defaults, *r)
 		}
 	}
+	args := &boot.AddLinkArgs{
+		FilePayload:  urpc.FilePayload{Files: link.files},
+		LogPackets:   conf.LogPackets,
+		DisconnectOk: conf.NetDisconnectOk,
+	}
+	if link.xdp != nil {
+		link.xdp.Routes = append(link.xdp.Routes, defaults...)
+		args.XDPLink = link.xdp
+	} else {
+		link.fdbased.Routes = append(link.fdbased.Routes, defaults...)
+		args.FDBasedLink = link.fdbased
+	}
+	return args, nil
+}
 
-	if err := pcapAndNAT(&args, conf); err != nil {
+// RemoveNetworkLink removes the link with the given name, along with its
+// addresses and routes, from the running sandbox.
+func (s *Sandbox) RemoveNetworkLink(name string) error {
+	log.Debugf("Removing network link %q from sandbox %q", name, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
 		return err
 	}
+	defer conn.Close()
 
-	log.Debugf("Setting up network, config: %+v", args)
-	if err := conn.Call(boot.NetworkCreateLinksAndRoutes, &args, nil); err != nil {
-		return fmt.Errorf("creating links and routes: %w", err)
+	if err := conn.Call(boot.NetworkRemoveLink, &boot.RemoveLinkArgs{Name: name}, nil); err != nil {
+		return fmt.Errorf("removing network link %q: %w", name, err)
+	}
+	return nil
+}
+
+// UpdateNetworkRoutes replaces the routes through the link with the given
+// name in the running sandbox.
+func (s *Sandbox) UpdateNetworkRoutes(name string, routes []boot.Route) error {
+	log.Debugf("Updating routes of network link %q in sandbox %q: %+v", name, s.ID, routes)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.UpdateRoutesArgs{
+		Name:   name,
+		Routes: routes,
+	}
+	if err := conn.Call(boot.NetworkUpdateRoutes, &args, nil); err != nil {
+		return fmt.Errorf("updating routes of network link %q: %w", name, err)
+	}
+	return nil
+}
+
+// StartCapture starts capturing the packets of a link in the running sandbox
+// to the file in args, in pcapng format.
+func (s *Sandbox) StartCapture(args *boot.StartCaptureArgs) error {
+	log.Debugf("Starting packet capture of network link %q in sandbox %q", args.Name, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Call(boot.NetworkStartCapture, args, nil); err != nil {
+		return fmt.Errorf("starting packet capture of network link %q: %w", args.Name, err)
+	}
+	return nil
+}
+
+// StopCapture stops the packet capture of the link with the given name in the
+// running sandbox, and waits until all captured packets have been written.
+func (s *Sandbox) StopCapture(name string) error {
+	log.Debugf("Stopping packet capture of network link %q in sandbox %q", name, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Call(boot.NetworkStopCapture, &boot.StopCaptureArgs{Name: name}, nil); err != nil {
+		return fmt.Errorf("stopping packet capture of network link %q: %w", name, err)
 	}
 	return nil
 }
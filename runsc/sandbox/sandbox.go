@@ -719,6 +719,17 @@ func (s *Sandbox) Event(cid string) (*boot.EventOut, error) {
 	return &e, nil
 }
 
+// ContainerStats retrieves the resource usage of all containers in the
+// sandbox, as accounted by the sentry.
+func (s *Sandbox) ContainerStats() (*boot.ContainerStatsOut, error) {
+	log.Debugf("Getting container stats in sandbox %q", s.ID)
+	var out boot.ContainerStatsOut
+	if err := s.call(boot.ContMgrContainerStats, nil, &out); err != nil {
+		return nil, fmt.Errorf("retrieving container stats from sandbox: %w", err)
+	}
+	return &out, nil
+}
+
 // PortForward starts port forwarding to the sandbox.
 func (s *Sandbox) PortForward(opts *boot.PortForwardOpts) error {
 	log.Debugf("Requesting port forward for container %q in sandbox %q: %+v", opts.ContainerID, s.ID, opts)
@@ -1201,6 +1212,12 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 	}
 	cmd.Args = append(cmd.Args, "--total-host-memory", strconv.FormatUint(totalSysMem, 10))
 
+	// Match the cgroup version of the host, which is what the container
+	// expects to find at /sys/fs/cgroup.
+	if cgroup.IsOnlyV2() {
+		cmd.Args = append(cmd.Args, "--cgroup-v2")
+	}
+
 	mem := totalSysMem
 	if s.CgroupJSON.Cgroup != nil {
 		cpuNum, err := s.CgroupJSON.Cgroup.NumCPU()
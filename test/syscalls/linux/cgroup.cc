@@ -17,8 +17,13 @@
 
 #include <limits.h>
 #include <linux/magic.h>
+#include <sched.h>
+#include <signal.h>
 #include <sys/mount.h>
+#include <sys/stat.h>
 #include <sys/statfs.h>
+#include <sys/syscall.h>
+#include <sys/wait.h>
 #include <unistd.h>
 
 #include <cerrno>
@@ -29,13 +34,17 @@
 #include "absl/container/flat_hash_map.h"
 #include "absl/container/flat_hash_set.h"
 #include "absl/strings/ascii.h"
+#include "absl/strings/str_cat.h"
 #include "absl/strings/str_split.h"
 #include "absl/synchronization/notification.h"
 #include "absl/time/time.h"
 #include "test/util/cgroup_util.h"
 #include "test/util/cleanup.h"
+#include "test/util/file_descriptor.h"
 #include "test/util/linux_capability_util.h"
+#include "test/util/logging.h"
 #include "test/util/mount_util.h"
+#include "test/util/multiprocess_util.h"
 #include "test/util/posix_error.h"
 #include "test/util/temp_path.h"
 #include "test/util/test_util.h"
@@ -1086,6 +1095,244 @@ TEST(DevicesCgroup, IgnorePartialMatchRule) {
               IsPosixErrorOkAndHolds("c 7:* rw\n"));
 }
 
+// CgroupPath returns the path of the cgroup of process pid in the hierarchy
+// with the given controllers, as shown in /proc/<pid>/cgroup.
+PosixErrorOr<std::string> CgroupPath(pid_t pid,
+                                     const std::string& controllers) {
+  ASSIGN_OR_RETURN_ERRNO(auto entries, ProcPIDCgroupEntries(pid));
+  auto it = entries.find(controllers);
+  if (it == entries.end()) {
+    return PosixError(ENOENT, absl::StrCat("no cgroup entry for ", controllers,
+                                           " in /proc/", pid, "/cgroup"));
+  }
+  return it->second.path;
+}
+
+// CloneNewCgroupNamespace creates a child process in a new cgroup namespace,
+// and returns like fork(2).
+pid_t CloneNewCgroupNamespace() {
+  return syscall(SYS_clone, CLONE_NEWCGROUP | SIGCHLD, 0, 0, 0, 0);
+}
+
+TEST(CgroupNamespace, UnshareRootsProcCgroup) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Cgroup c = Cgroup::RootCgroup("/sys/fs/cgroup/memory");
+  Cgroup nsroot = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("nsroot"));
+  Cgroup child = ASSERT_NO_ERRNO_AND_VALUE(nsroot.CreateChild("child"));
+  Cgroup sibling = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("sibling"));
+
+  const auto rest = [&] {
+    TEST_CHECK_NO_ERRNO(nsroot.Enter(getpid()));
+    TEST_PCHECK(unshare(CLONE_NEWCGROUP) == 0);
+
+    // The new namespace is rooted at the current cgroup in every hierarchy.
+    auto entries =
+        TEST_CHECK_NO_ERRNO_AND_VALUE(ProcPIDCgroupEntries(getpid()));
+    TEST_CHECK(!entries.empty());
+    for (const auto& [controllers, entry] : entries) {
+      TEST_CHECK_MSG(entry.path == "/", controllers.c_str());
+    }
+
+    // Cgroups are shown relative to the namespace root, including those
+    // outside of it.
+    TEST_CHECK_NO_ERRNO(child.Enter(getpid()));
+    TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory")) ==
+               "/child");
+    TEST_CHECK_NO_ERRNO(sibling.Enter(getpid()));
+    TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory")) ==
+               "/../sibling");
+    TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getppid(), "memory")) ==
+               "/..");
+  };
+  EXPECT_THAT(InForkedProcess(rest), IsPosixErrorOkAndHolds(0));
+}
+
+TEST(CgroupNamespace, ProcCgroupUsesReaderNamespace) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Cgroup c = Cgroup::RootCgroup("/sys/fs/cgroup/memory");
+  Cgroup nsroot = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("nsroot"));
+  Cgroup child = ASSERT_NO_ERRNO_AND_VALUE(nsroot.CreateChild("child"));
+  const std::string path =
+      ASSERT_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory"));
+
+  int fds[2];
+  ASSERT_THAT(pipe(fds), SyscallSucceeds());
+  FileDescriptor rfd(fds[0]);
+  FileDescriptor wfd(fds[1]);
+
+  pid_t pid = fork();
+  if (pid == 0) {
+    TEST_CHECK_NO_ERRNO(nsroot.Enter(getpid()));
+    TEST_PCHECK(unshare(CLONE_NEWCGROUP) == 0);
+    TEST_CHECK_NO_ERRNO(child.Enter(getpid()));
+    char ready = 0;
+    TEST_PCHECK(WriteFd(wfd.get(), &ready, 1) == 1);
+    while (true) {
+      pause();
+    }
+  }
+  ASSERT_THAT(pid, SyscallSucceeds());
+  auto cleanup = Cleanup([pid] {
+    EXPECT_THAT(kill(pid, SIGKILL), SyscallSucceeds());
+    int status;
+    EXPECT_THAT(RetryEINTR(waitpid)(pid, &status, 0),
+                SyscallSucceedsWithValue(pid));
+  });
+  wfd.reset();
+
+  char buf;
+  ASSERT_THAT(ReadFd(rfd.get(), &buf, 1), SyscallSucceedsWithValue(1));
+
+  // The child's cgroup is shown relative to the reader's namespace, not the
+  // child's.
+  EXPECT_THAT(CgroupPath(pid, "memory"),
+              IsPosixErrorOkAndHolds(JoinPath(path, "nsroot/child")));
+}
+
+TEST(CgroupNamespace, Clone) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Cgroup c = Cgroup::RootCgroup("/sys/fs/cgroup/memory");
+  Cgroup nsroot = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("nsroot"));
+
+  const auto rest = [&] {
+    TEST_CHECK_NO_ERRNO(nsroot.Enter(getpid()));
+    const std::string path =
+        TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory"));
+    struct stat st;
+    TEST_PCHECK(stat("/proc/self/ns/cgroup", &st) == 0);
+    const ino_t ino = st.st_ino;
+
+    pid_t child = CloneNewCgroupNamespace();
+    if (child == 0) {
+      // The child inherits its parent's cgroup, which is the root of its new
+      // namespace.
+      TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(
+                     CgroupPath(getpid(), "memory")) == "/");
+      TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(
+                     CgroupPath(getppid(), "memory")) == "/");
+      TEST_PCHECK(stat("/proc/self/ns/cgroup", &st) == 0);
+      TEST_CHECK(st.st_ino != ino);
+      _exit(0);
+    }
+    TEST_PCHECK(child > 0);
+    int status;
+    TEST_PCHECK(RetryEINTR(waitpid)(child, &status, 0) == child);
+    TEST_CHECK(WIFEXITED(status) && WEXITSTATUS(status) == 0);
+
+    // The parent's namespace is unchanged.
+    TEST_PCHECK(stat("/proc/self/ns/cgroup", &st) == 0);
+    TEST_CHECK(st.st_ino == ino);
+    TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory")) ==
+               path);
+  };
+  EXPECT_THAT(InForkedProcess(rest), IsPosixErrorOkAndHolds(0));
+}
+
+TEST(CgroupNamespace, Setns) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Cgroup c = Cgroup::RootCgroup("/sys/fs/cgroup/memory");
+  Cgroup nsroot = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("nsroot"));
+
+  const auto rest = [&] {
+    TEST_CHECK_NO_ERRNO(nsroot.Enter(getpid()));
+    const std::string path =
+        TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory"));
+    const FileDescriptor nsfd = TEST_CHECK_NO_ERRNO_AND_VALUE(
+        Open("/proc/self/ns/cgroup", O_RDONLY));
+
+    TEST_PCHECK(unshare(CLONE_NEWCGROUP) == 0);
+    TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory")) ==
+               "/");
+
+    // The namespace type must match if it is given.
+    TEST_CHECK_ERRNO(setns(nsfd.get(), CLONE_NEWIPC), EINVAL);
+
+    // Returning to the original namespace restores the original view.
+    TEST_PCHECK(setns(nsfd.get(), CLONE_NEWCGROUP) == 0);
+    TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory")) ==
+               path);
+  };
+  EXPECT_THAT(InForkedProcess(rest), IsPosixErrorOkAndHolds(0));
+}
+
+TEST(CgroupNamespace, RequiresCapSysAdmin) {
+  SKIP_IF(!CgroupsAvailable());
+
+  const auto rest = [] {
+    AutoCapability cap(CAP_SYS_ADMIN, false);
+    TEST_CHECK_ERRNO(unshare(CLONE_NEWCGROUP), EPERM);
+    TEST_CHECK_ERRNO(CloneNewCgroupNamespace(), EPERM);
+  };
+  EXPECT_THAT(InForkedProcess(rest), IsPosixErrorOkAndHolds(0));
+}
+
+TEST(CgroupNamespace, MountInfoRoot) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Mounter m(ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir()));
+  Cgroup c = Cgroup::RootCgroup("/sys/fs/cgroup/memory");
+  Cgroup nsroot = ASSERT_NO_ERRNO_AND_VALUE(c.CreateChild("nsroot"));
+  ASSERT_NO_ERRNO(nsroot.CreateChild("child"));
+
+  // A view mounted in the initial namespace is rooted at the hierarchy root,
+  // which is displayed relative to the namespace root inside the namespace.
+  Cgroup outside = ASSERT_NO_ERRNO_AND_VALUE(m.MountCgroupfs("memory"));
+  const std::string nsroot_path = JoinPath(
+      ASSERT_NO_ERRNO_AND_VALUE(CgroupPath(getpid(), "memory")), "nsroot");
+  std::vector<std::string> comps =
+      absl::StrSplit(nsroot_path, '/', absl::SkipEmpty());
+  std::string outside_root;
+  for (size_t i = 0; i < comps.size(); i++) {
+    outside_root += "/..";
+  }
+
+  const auto rest = [&] {
+    TEST_CHECK_NO_ERRNO(nsroot.Enter(getpid()));
+    TEST_PCHECK(unshare(CLONE_NEWCGROUP) == 0);
+
+    // A view mounted inside the namespace is rooted at the namespace root.
+    Mounter inner(TEST_CHECK_NO_ERRNO_AND_VALUE(TempPath::CreateDir()));
+    Cgroup inside =
+        TEST_CHECK_NO_ERRNO_AND_VALUE(inner.MountCgroupfs("memory"));
+    TEST_CHECK_NO_ERRNO(inside.ContainsCallingProcess());
+    TEST_CHECK(TEST_CHECK_NO_ERRNO_AND_VALUE(Exists(inside.Relpath("child"))));
+
+    bool found_inside = false;
+    bool found_outside = false;
+    for (const auto& e :
+         TEST_CHECK_NO_ERRNO_AND_VALUE(ProcSelfMountInfoEntries())) {
+      if (e.mount_point == inside.Path()) {
+        TEST_CHECK(e.root == "/");
+        found_inside = true;
+      } else if (e.mount_point == outside.Path()) {
+        TEST_CHECK(e.root == outside_root);
+        found_outside = true;
+      }
+    }
+    TEST_CHECK(found_inside);
+    TEST_CHECK(found_outside);
+  };
+  EXPECT_THAT(InForkedProcess(rest), IsPosixErrorOkAndHolds(0));
+}
+
+TEST(CgroupNamespace, NoNewHierarchies) {
+  SKIP_IF(!CgroupsAvailable());
+
+  const auto rest = [] {
+    TEST_PCHECK(unshare(CLONE_NEWCGROUP) == 0);
+
+    // New hierarchies can only be created in the initial cgroup namespace.
+    Mounter m(TEST_CHECK_NO_ERRNO_AND_VALUE(TempPath::CreateDir()));
+    PosixErrorOr<Cgroup> c = m.MountCgroupfs("none,name=nstest");
+    TEST_CHECK(!c.ok() && c.error().errno_value() == EPERM);
+  };
+  EXPECT_THAT(InForkedProcess(rest), IsPosixErrorOkAndHolds(0));
+}
+
 }  // namespace
 }  // namespace testing
 }  // namespace gvisor
@@ -794,6 +794,61 @@ TEST(Inotify, MoveWatchedTargetGeneratesEvents) {
 
 // Tests that close events are only emitted when a file description drops its
 // last reference.
+// On gofer mounts in shared file access mode, changes to watched files are
+// also reported by the host. Changes made by the sandbox must still only be
+// reported once.
+TEST(Inotify, ChangesAreReportedOnce) {
+  const TempPath root = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const std::string path = JoinPath(root.path(), "file");
+  const std::string newpath = JoinPath(root.path(), "file2");
+  const FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(InotifyInit1(IN_NONBLOCK));
+  const uint32_t mask = IN_MODIFY | IN_ATTRIB | IN_MOVE | IN_CREATE |
+                        IN_DELETE | IN_DELETE_SELF | IN_MOVE_SELF;
+  const int root_wd =
+      ASSERT_NO_ERRNO_AND_VALUE(InotifyAddWatch(fd.get(), root.path(), mask));
+
+  // Events reported by the host arrive asynchronously, so give them time to
+  // arrive before checking for duplicates.
+  auto drain_events = [&]() {
+    absl::SleepFor(absl::Milliseconds(200));
+    return DrainEvents(fd.get());
+  };
+
+  FileDescriptor file =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(path, O_CREAT | O_WRONLY, 0644));
+  const int file_wd =
+      ASSERT_NO_ERRNO_AND_VALUE(InotifyAddWatch(fd.get(), path, mask));
+  std::vector<Event> events = ASSERT_NO_ERRNO_AND_VALUE(drain_events());
+  EXPECT_THAT(events, Are({Event(IN_CREATE, root_wd, "file")}));
+
+  ASSERT_THAT(WriteFd(file.get(), "x", 1), SyscallSucceedsWithValue(1));
+  file.reset();
+  events = ASSERT_NO_ERRNO_AND_VALUE(drain_events());
+  EXPECT_THAT(events, Are({Event(IN_MODIFY, root_wd, "file"),
+                           Event(IN_MODIFY, file_wd)}));
+
+  ASSERT_THAT(chmod(path.c_str(), 0600), SyscallSucceeds());
+  events = ASSERT_NO_ERRNO_AND_VALUE(drain_events());
+  EXPECT_THAT(events, Are({Event(IN_ATTRIB, root_wd, "file"),
+                           Event(IN_ATTRIB, file_wd)}));
+
+  ASSERT_THAT(rename(path.c_str(), newpath.c_str()), SyscallSucceeds());
+  events = ASSERT_NO_ERRNO_AND_VALUE(drain_events());
+  ASSERT_EQ(events.size(), 3);
+  EXPECT_THAT(events,
+              Are({Event(IN_MOVED_FROM, root_wd, "file", events[0].cookie),
+                   Event(IN_MOVED_TO, root_wd, "file2", events[0].cookie),
+                   Event(IN_MOVE_SELF, file_wd)}));
+
+  ASSERT_THAT(unlink(newpath.c_str()), SyscallSucceeds());
+  events = ASSERT_NO_ERRNO_AND_VALUE(drain_events());
+  EXPECT_THAT(events, AreUnordered({Event(IN_ATTRIB, file_wd),
+                                    Event(IN_DELETE_SELF, file_wd),
+                                    Event(IN_IGNORED, file_wd),
+                                    Event(IN_DELETE, root_wd, "file2")}));
+}
+
 TEST(Inotify, DupFD) {
   const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
   const FileDescriptor inotify_fd =
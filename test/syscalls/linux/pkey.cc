@@ -0,0 +1,90 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <errno.h>
+#include <sys/mman.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+#include "gtest/gtest.h"
+#include "test/util/memory_util.h"
+#include "test/util/test_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+// PKEY_DISABLE_ACCESS from linux/mman.h.
+constexpr unsigned int kPkeyDisableAccess = 0x1;
+
+// An unallocated protection key.
+constexpr int kUnallocatedPkey = 15;
+
+int PkeyAlloc(unsigned int flags, unsigned int access_rights) {
+  return syscall(SYS_pkey_alloc, flags, access_rights);
+}
+
+int PkeyFree(int pkey) { return syscall(SYS_pkey_free, pkey); }
+
+int PkeyMprotect(void* addr, size_t len, int prot, int pkey) {
+  return syscall(SYS_pkey_mprotect, addr, len, prot, pkey);
+}
+
+TEST(PkeyTest, AllocInvalidArguments) {
+  EXPECT_THAT(PkeyAlloc(1, 0), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(PkeyAlloc(0, ~0u), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PkeyTest, Alloc) {
+  int pkey = PkeyAlloc(0, kPkeyDisableAccess);
+  if (IsRunningOnGvisor()) {
+    // Protection keys are not enforced, so none can be allocated.
+    EXPECT_THAT(pkey, SyscallFailsWithErrno(ENOSPC));
+    return;
+  }
+  // Linux fails with ENOSPC on hardware without protection keys.
+  if (pkey < 0) {
+    EXPECT_EQ(errno, ENOSPC);
+    return;
+  }
+  EXPECT_GT(pkey, 0);
+  EXPECT_THAT(PkeyFree(pkey), SyscallSucceeds());
+}
+
+TEST(PkeyTest, FreeUnallocated) {
+  EXPECT_THAT(PkeyFree(kUnallocatedPkey), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(PkeyFree(-1), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PkeyTest, Mprotect) {
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+
+  // A pkey of -1 behaves like mprotect(2), and the default protection key 0
+  // is always allocated.
+  EXPECT_THAT(PkeyMprotect(m.ptr(), m.len(), PROT_READ, -1),
+              SyscallSucceeds());
+  EXPECT_THAT(PkeyMprotect(m.ptr(), m.len(), PROT_READ | PROT_WRITE, 0),
+              SyscallSucceeds());
+  *reinterpret_cast<volatile char*>(m.ptr()) = 1;
+
+  EXPECT_THAT(PkeyMprotect(m.ptr(), m.len(), PROT_READ, kUnallocatedPkey),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
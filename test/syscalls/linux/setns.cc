@@ -77,6 +77,29 @@ TEST(SetnsTest, ChangeUTSNamespace) {
   EXPECT_EQ(utsns1, utsns3);
 }
 
+TEST(SetnsTest, ChangeCgroupNamespace) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
+
+  struct stat st;
+  uint64_t cgroupns1, cgroupns2, cgroupns3;
+  const FileDescriptor nsfd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open("/proc/thread-self/ns/cgroup", O_RDONLY));
+  ASSERT_THAT(stat("/proc/thread-self/ns/cgroup", &st), SyscallSucceeds());
+  cgroupns1 = st.st_ino;
+
+  // Use unshare(CLONE_NEWCGROUP) to change into a new cgroup namespace.
+  ASSERT_THAT(unshare(CLONE_NEWCGROUP), SyscallSucceedsWithValue(0));
+  ASSERT_THAT(stat("/proc/thread-self/ns/cgroup", &st), SyscallSucceeds());
+  cgroupns2 = st.st_ino;
+  ASSERT_NE(cgroupns1, cgroupns2);
+
+  ASSERT_THAT(setns(nsfd.get(), CLONE_NEWUTS), SyscallFailsWithErrno(EINVAL));
+  ASSERT_THAT(setns(nsfd.get(), CLONE_NEWCGROUP), SyscallSucceedsWithValue(0));
+  ASSERT_THAT(stat("/proc/thread-self/ns/cgroup", &st), SyscallSucceeds());
+  cgroupns3 = st.st_ino;
+  EXPECT_EQ(cgroupns1, cgroupns3);
+}
+
 TEST(SetnsTest, ChangePIDNamespace) {
   SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_ADMIN)));
 
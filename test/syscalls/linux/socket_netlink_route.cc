@@ -1811,6 +1811,293 @@ TEST(NetlinkRouteTest, LookupAllAddrOrder) {
     freeifaddrs(if_addr_list);
   }
 }
+
+// NetlinkMulticastSocket returns a NETLINK_ROUTE socket bound to the multicast
+// groups in the groups bitmap. Receives on the socket time out, so that
+// missing notifications fail the test instead of blocking it.
+PosixErrorOr<FileDescriptor> NetlinkMulticastSocket(uint32_t groups) {
+  ASSIGN_OR_RETURN_ERRNO(FileDescriptor fd,
+                         Socket(AF_NETLINK, SOCK_RAW, NETLINK_ROUTE));
+
+  struct sockaddr_nl addr = {};
+  addr.nl_family = AF_NETLINK;
+  addr.nl_groups = groups;
+  RETURN_ERROR_IF_SYSCALL_FAIL(
+      bind(fd.get(), reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr)));
+
+  struct timeval tv = {};
+  tv.tv_sec = 5;
+  RETURN_ERROR_IF_SYSCALL_FAIL(
+      setsockopt(fd.get(), SOL_SOCKET, SO_RCVTIMEO, &tv, sizeof(tv)));
+  return std::move(fd);
+}
+
+// WaitForNotification reads messages from fd until fn returns true for one of
+// them.
+PosixError WaitForNotification(
+    const FileDescriptor& fd,
+    const std::function<bool(const struct nlmsghdr* hdr)>& fn) {
+  constexpr size_t kBufferSize = 4096;
+  std::vector<char> buf(kBufferSize);
+  while (true) {
+    int len;
+    RETURN_ERROR_IF_SYSCALL_FAIL(
+        len = recv(fd.get(), buf.data(), buf.size(), 0));
+    for (struct nlmsghdr* hdr = reinterpret_cast<struct nlmsghdr*>(buf.data());
+         NLMSG_OK(hdr, len); hdr = NLMSG_NEXT(hdr, len)) {
+      if (fn(hdr)) {
+        return NoError();
+      }
+    }
+  }
+}
+
+// Returns the first attribute of the given type in the len bytes of attributes
+// starting at rta, or nullptr if there is none.
+const struct rtattr* FindAttr(const struct rtattr* rta, int len, int type) {
+  for (; RTA_OK(rta, len); rta = RTA_NEXT(rta, len)) {
+    if (rta->rta_type == type) {
+      return rta;
+    }
+  }
+  return nullptr;
+}
+
+// Returns the membership bitmap of the first 32 groups of fd.
+PosixErrorOr<uint32_t> NetlinkMemberships(const FileDescriptor& fd) {
+  uint32_t groups = 0;
+  socklen_t len = sizeof(groups);
+  RETURN_ERROR_IF_SYSCALL_FAIL(getsockopt(
+      fd.get(), SOL_NETLINK, NETLINK_LIST_MEMBERSHIPS, &groups, &len));
+  return groups;
+}
+
+// Returns the groups reported by getsockname(2) for fd.
+PosixErrorOr<uint32_t> NetlinkSockNameGroups(const FileDescriptor& fd) {
+  struct sockaddr_nl addr = {};
+  socklen_t addrlen = sizeof(addr);
+  RETURN_ERROR_IF_SYSCALL_FAIL(getsockname(
+      fd.get(), reinterpret_cast<struct sockaddr*>(&addr), &addrlen));
+  return addr.nl_groups;
+}
+
+TEST(NetlinkRouteTest, AddAndDropMembership) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkBoundSocket(NETLINK_ROUTE));
+  EXPECT_THAT(NetlinkMemberships(fd), IsPosixErrorOkAndHolds(0));
+
+  int group = RTNLGRP_IPV4_IFADDR;
+  ASSERT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_ADD_MEMBERSHIP, &group,
+                         sizeof(group)),
+              SyscallSucceeds());
+  EXPECT_THAT(NetlinkMemberships(fd),
+              IsPosixErrorOkAndHolds(RTMGRP_IPV4_IFADDR));
+  EXPECT_THAT(NetlinkSockNameGroups(fd),
+              IsPosixErrorOkAndHolds(RTMGRP_IPV4_IFADDR));
+
+  // Adding a group twice is not an error.
+  ASSERT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_ADD_MEMBERSHIP, &group,
+                         sizeof(group)),
+              SyscallSucceeds());
+  group = RTNLGRP_LINK;
+  ASSERT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_ADD_MEMBERSHIP, &group,
+                         sizeof(group)),
+              SyscallSucceeds());
+  EXPECT_THAT(NetlinkMemberships(fd),
+              IsPosixErrorOkAndHolds(RTMGRP_LINK | RTMGRP_IPV4_IFADDR));
+
+  group = RTNLGRP_IPV4_IFADDR;
+  ASSERT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_DROP_MEMBERSHIP,
+                         &group, sizeof(group)),
+              SyscallSucceeds());
+  EXPECT_THAT(NetlinkMemberships(fd), IsPosixErrorOkAndHolds(RTMGRP_LINK));
+  EXPECT_THAT(NetlinkSockNameGroups(fd), IsPosixErrorOkAndHolds(RTMGRP_LINK));
+}
+
+TEST(NetlinkRouteTest, AddMembershipInvalid) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkBoundSocket(NETLINK_ROUTE));
+
+  int group = 0;
+  EXPECT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_ADD_MEMBERSHIP, &group,
+                         sizeof(group)),
+              SyscallFailsWithErrno(EINVAL));
+  group = 1 << 20;
+  EXPECT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_ADD_MEMBERSHIP, &group,
+                         sizeof(group)),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_DROP_MEMBERSHIP,
+                         &group, sizeof(group)),
+              SyscallFailsWithErrno(EINVAL));
+  char short_group = 0;
+  EXPECT_THAT(setsockopt(fd.get(), SOL_NETLINK, NETLINK_ADD_MEMBERSHIP,
+                         &short_group, sizeof(short_group)),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(NetlinkMemberships(fd), IsPosixErrorOkAndHolds(0));
+}
+
+TEST(NetlinkRouteTest, BindGroups) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(
+      NetlinkMulticastSocket(RTMGRP_LINK | RTMGRP_IPV4_IFADDR));
+  EXPECT_THAT(NetlinkSockNameGroups(fd),
+              IsPosixErrorOkAndHolds(RTMGRP_LINK | RTMGRP_IPV4_IFADDR));
+  EXPECT_THAT(NetlinkMemberships(fd),
+              IsPosixErrorOkAndHolds(RTMGRP_LINK | RTMGRP_IPV4_IFADDR));
+
+  // Binding again replaces the memberships, keeping the port ID.
+  uint32_t port = ASSERT_NO_ERRNO_AND_VALUE(NetlinkPortID(fd.get()));
+  struct sockaddr_nl addr = {};
+  addr.nl_family = AF_NETLINK;
+  addr.nl_pid = port;
+  addr.nl_groups = RTMGRP_IPV4_ROUTE;
+  ASSERT_THAT(
+      bind(fd.get(), reinterpret_cast<struct sockaddr*>(&addr), sizeof(addr)),
+      SyscallSucceeds());
+  EXPECT_THAT(NetlinkSockNameGroups(fd),
+              IsPosixErrorOkAndHolds(RTMGRP_IPV4_ROUTE));
+  EXPECT_THAT(NetlinkMemberships(fd),
+              IsPosixErrorOkAndHolds(RTMGRP_IPV4_ROUTE));
+}
+
+TEST(NetlinkRouteTest, NewAddrNotification) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_NET_ADMIN)));
+  SKIP_IF(IsRunningWithHostinet());
+  // Don't do cooperative save/restore because netstack state is not restored.
+  const DisableSave ds;
+
+  FileDescriptor listener =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkMulticastSocket(RTMGRP_IPV4_IFADDR));
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkBoundSocket(NETLINK_ROUTE));
+  uint32_t port = ASSERT_NO_ERRNO_AND_VALUE(NetlinkPortID(fd.get()));
+  Link loopback_link = ASSERT_NO_ERRNO_AND_VALUE(LoopbackLink());
+
+  struct in_addr addr;
+  ASSERT_EQ(inet_pton(AF_INET, "10.0.0.1", &addr), 1);
+  ASSERT_NO_ERRNO(LinkAddLocalAddr(fd, loopback_link.index, AF_INET,
+                                   /*prefixlen=*/24, &addr, sizeof(addr)));
+  Cleanup defer_addr_removal = Cleanup([&] {
+    EXPECT_NO_ERRNO(LinkDelLocalAddr(fd, loopback_link.index, AF_INET,
+                                     /*prefixlen=*/24, &addr, sizeof(addr)));
+  });
+
+  ASSERT_NO_ERRNO(
+      WaitForNotification(listener, [&](const struct nlmsghdr* hdr) {
+        if (hdr->nlmsg_type != RTM_NEWADDR) {
+          return false;
+        }
+        const struct ifaddrmsg* msg =
+            reinterpret_cast<const struct ifaddrmsg*>(NLMSG_DATA(hdr));
+        const struct rtattr* rta =
+            FindAttr(IFA_RTA(msg), IFA_PAYLOAD(hdr), IFA_ADDRESS);
+        if (rta == nullptr || RTA_PAYLOAD(rta) != sizeof(addr) ||
+            memcmp(RTA_DATA(rta), &addr, sizeof(addr)) != 0) {
+          return false;
+        }
+        EXPECT_EQ(msg->ifa_family, AF_INET);
+        EXPECT_EQ(msg->ifa_prefixlen, 24);
+        EXPECT_EQ(msg->ifa_index, static_cast<uint32_t>(loopback_link.index));
+        // The notification identifies the request that caused it.
+        EXPECT_EQ(hdr->nlmsg_pid, port);
+        return true;
+      }));
+}
+
+TEST(NetlinkRouteTest, NewLinkNotification) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_NET_ADMIN)));
+  SKIP_IF(IsRunningWithHostinet());
+  SKIP_IF(!IsRunningOnGvisor());
+  const DisableSave ds;
+
+  FileDescriptor listener =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkMulticastSocket(RTMGRP_LINK));
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkBoundSocket(NETLINK_ROUTE));
+  Link loopback_link = ASSERT_NO_ERRNO_AND_VALUE(LoopbackLink());
+
+  struct request {
+    struct nlmsghdr hdr;
+    struct ifinfomsg ifm;
+    struct rtattr rtattr;
+    uint32_t mtu;
+  };
+  auto set_mtu = [&](uint32_t mtu) {
+    struct request req = {};
+    req.hdr.nlmsg_len = sizeof(req);
+    req.hdr.nlmsg_type = RTM_NEWLINK;
+    req.hdr.nlmsg_flags = NLM_F_REQUEST | NLM_F_ACK;
+    req.hdr.nlmsg_seq = kSeq;
+    req.ifm.ifi_family = AF_UNSPEC;
+    req.ifm.ifi_index = loopback_link.index;
+    req.rtattr.rta_type = IFLA_MTU;
+    req.rtattr.rta_len = RTA_LENGTH(sizeof(uint32_t));
+    req.mtu = mtu;
+    return NetlinkRequestAckOrError(fd, kSeq, &req, sizeof(req));
+  };
+
+  const uint32_t mtu = loopback_link.mtu + 10;
+  ASSERT_NO_ERRNO(set_mtu(mtu));
+  Cleanup restore_mtu =
+      Cleanup([&] { EXPECT_NO_ERRNO(set_mtu(loopback_link.mtu)); });
+
+  ASSERT_NO_ERRNO(
+      WaitForNotification(listener, [&](const struct nlmsghdr* hdr) {
+        if (hdr->nlmsg_type != RTM_NEWLINK) {
+          return false;
+        }
+        const struct ifinfomsg* msg =
+            reinterpret_cast<const struct ifinfomsg*>(NLMSG_DATA(hdr));
+        if (msg->ifi_index != loopback_link.index) {
+          return false;
+        }
+        const struct rtattr* rta = FindRtAttr(hdr, msg, IFLA_MTU);
+        EXPECT_NE(rta, nullptr) << "IFLA_MTU not found in notification.";
+        if (rta != nullptr) {
+          EXPECT_EQ(*reinterpret_cast<const uint32_t*>(RTA_DATA(rta)), mtu);
+        }
+        return true;
+      }));
+}
+
+TEST(NetlinkRouteTest, NewRouteNotification) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_NET_ADMIN)));
+  SKIP_IF(!IsRunningOnGvisor());
+  SKIP_IF(IsRunningWithHostinet());
+  // Routes are not savable.
+  const DisableSave ds;
+
+  FileDescriptor listener =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkMulticastSocket(RTMGRP_IPV4_ROUTE));
+  Link loopback_link = ASSERT_NO_ERRNO_AND_VALUE(LoopbackLink());
+
+  struct in_addr dst;
+  ASSERT_EQ(inet_pton(AF_INET, "192.0.4.0", &dst), 1);
+  ASSERT_NO_ERRNO(AddUnicastRoute(loopback_link.index, AF_INET,
+                                  /*prefixlen=*/24, &dst, sizeof(dst)));
+  Cleanup defer_route_removal = Cleanup([&] {
+    EXPECT_NO_ERRNO(DelUnicastRoute(loopback_link.index, AF_INET,
+                                    /*prefixlen=*/24, &dst, sizeof(dst)));
+  });
+
+  ASSERT_NO_ERRNO(
+      WaitForNotification(listener, [&](const struct nlmsghdr* hdr) {
+        if (hdr->nlmsg_type != RTM_NEWROUTE) {
+          return false;
+        }
+        const struct rtmsg* msg =
+            reinterpret_cast<const struct rtmsg*>(NLMSG_DATA(hdr));
+        const struct rtattr* rta =
+            FindAttr(RTM_RTA(msg), RTM_PAYLOAD(hdr), RTA_DST);
+        if (msg->rtm_family != AF_INET || rta == nullptr ||
+            RTA_PAYLOAD(rta) != sizeof(dst) ||
+            memcmp(RTA_DATA(rta), &dst, sizeof(dst)) != 0) {
+          return false;
+        }
+        EXPECT_EQ(msg->rtm_dst_len, 24);
+        return true;
+      }));
+}
+
 }  // namespace
 
 }  // namespace testing
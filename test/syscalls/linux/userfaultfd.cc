@@ -0,0 +1,484 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <fcntl.h>
+#include <linux/userfaultfd.h>
+#include <poll.h>
+#include <sys/ioctl.h>
+#include <sys/mman.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+#include <atomic>
+#include <cstdint>
+#include <cstring>
+#include <string>
+#include <vector>
+
+#include "gmock/gmock.h"
+#include "gtest/gtest.h"
+#include "absl/strings/numbers.h"
+#include "absl/strings/str_cat.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/fs_util.h"
+#include "test/util/linux_capability_util.h"
+#include "test/util/memory_util.h"
+#include "test/util/posix_error.h"
+#include "test/util/save_util.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+#ifndef UFFD_USER_MODE_ONLY
+#define UFFD_USER_MODE_ONLY 1
+#endif
+
+#ifndef USERFAULTFD_IOC_NEW
+#define USERFAULTFD_IOC_NEW _IO(UFFDIO, 0x00)
+#endif
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+// Flags for userfaultfds that don't need CAP_SYS_PTRACE.
+constexpr int kUserModeOnly = UFFD_USER_MODE_ONLY | O_CLOEXEC | O_NONBLOCK;
+
+PosixErrorOr<FileDescriptor> NewUserfaultfd(int flags) {
+  int fd = syscall(SYS_userfaultfd, flags);
+  MaybeSave();
+  if (fd < 0) {
+    return PosixError(errno, absl::StrCat("userfaultfd(", flags, ")"));
+  }
+  return FileDescriptor(fd);
+}
+
+// NewInitializedUserfaultfd returns a userfaultfd that has completed the
+// UFFDIO_API handshake.
+PosixErrorOr<FileDescriptor> NewInitializedUserfaultfd(int flags) {
+  ASSIGN_OR_RETURN_ERRNO(FileDescriptor fd, NewUserfaultfd(flags));
+  struct uffdio_api api = {};
+  api.api = UFFD_API;
+  if (ioctl(fd.get(), UFFDIO_API, &api) < 0) {
+    return PosixError(errno, "UFFDIO_API");
+  }
+  return std::move(fd);
+}
+
+// Register registers the pages in m with fd in the given mode.
+PosixError Register(const FileDescriptor& fd, const Mapping& m,
+                    uint64_t mode) {
+  struct uffdio_register reg = {};
+  reg.range.start = m.addr();
+  reg.range.len = m.len();
+  reg.mode = mode;
+  if (ioctl(fd.get(), UFFDIO_REGISTER, &reg) < 0) {
+    return PosixError(errno, "UFFDIO_REGISTER");
+  }
+  return NoError();
+}
+
+// ReadFault waits for a fault on fd and returns its message.
+PosixErrorOr<struct uffd_msg> ReadFault(const FileDescriptor& fd) {
+  struct pollfd pfd = {.fd = fd.get(), .events = POLLIN};
+  if (RetryEINTR(poll)(&pfd, 1, 10000) != 1) {
+    return PosixError(ETIMEDOUT, "waiting for a userfault");
+  }
+  struct uffd_msg msg = {};
+  int n = ReadFd(fd.get(), &msg, sizeof(msg));
+  if (n < 0) {
+    return PosixError(errno, "read userfaultfd");
+  }
+  if (n != sizeof(msg)) {
+    return PosixError(EIO, absl::StrCat("short read of ", n, " bytes"));
+  }
+  return msg;
+}
+
+// UnprivilegedUserfaultfdAllowed returns true if userfaultfds handling kernel
+// faults can be created without CAP_SYS_PTRACE.
+bool UnprivilegedUserfaultfdAllowed() {
+  if (IsRunningOnGvisor()) {
+    return false;
+  }
+  std::string contents;
+  if (!GetContents("/proc/sys/vm/unprivileged_userfaultfd", &contents).ok()) {
+    return false;
+  }
+  int value;
+  return absl::SimpleAtoi(contents, &value) && value != 0;
+}
+
+TEST(UserfaultfdTest, InvalidFlags) {
+  EXPECT_THAT(syscall(SYS_userfaultfd, O_APPEND),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(syscall(SYS_userfaultfd, 0x100000),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(UserfaultfdTest, Flags) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(NewUserfaultfd(kUserModeOnly));
+  EXPECT_THAT(fcntl(fd.get(), F_GETFD), SyscallSucceedsWithValue(FD_CLOEXEC));
+  EXPECT_THAT(fcntl(fd.get(), F_GETFL),
+              SyscallSucceedsWithValue(O_RDWR | O_NONBLOCK));
+}
+
+TEST(UserfaultfdTest, KernelFaultsRequireCapSysPtrace) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_PTRACE)));
+  SKIP_IF(UnprivilegedUserfaultfdAllowed());
+
+  // With CAP_SYS_PTRACE, userfaultfds may handle kernel faults.
+  ASSERT_NO_ERRNO(NewUserfaultfd(O_CLOEXEC));
+
+  AutoCapability cap(CAP_SYS_PTRACE, false);
+  EXPECT_THAT(syscall(SYS_userfaultfd, O_CLOEXEC),
+              SyscallFailsWithErrno(EPERM));
+  // UFFD_USER_MODE_ONLY userfaultfds only handle faults from user mode, so
+  // they don't need CAP_SYS_PTRACE.
+  EXPECT_NO_ERRNO(NewUserfaultfd(kUserModeOnly));
+}
+
+TEST(UserfaultfdTest, API) {
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(NewUserfaultfd(kUserModeOnly));
+
+  // Other ioctls fail until the API handshake is done.
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  EXPECT_THAT(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING),
+              PosixErrorIs(EINVAL, ::testing::_));
+
+  // An unknown API version fails, and zeroes the struct.
+  struct uffdio_api api = {};
+  api.api = UFFD_API + 1;
+  api.features = 0;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_API, &api), SyscallFailsWithErrno(EINVAL));
+  EXPECT_EQ(api.api, 0);
+
+  api = {};
+  api.api = UFFD_API;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_API, &api), SyscallSucceeds());
+  EXPECT_EQ(api.api, UFFD_API);
+  constexpr uint64_t kAPIIoctls = 1ULL << _UFFDIO_REGISTER |
+                                  1ULL << _UFFDIO_UNREGISTER |
+                                  1ULL << _UFFDIO_API;
+  EXPECT_EQ(api.ioctls & kAPIIoctls, kAPIIoctls);
+
+  // The handshake can only be done once.
+  api = {};
+  api.api = UFFD_API;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_API, &api), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(UserfaultfdTest, UnknownIoctl) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  EXPECT_THAT(ioctl(fd.get(), _IO(UFFDIO, 0x3E), nullptr),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(UserfaultfdTest, Register) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(2 * kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+
+  struct uffdio_register reg = {};
+  reg.range.start = m.addr();
+  reg.range.len = m.len();
+  reg.mode = UFFDIO_REGISTER_MODE_MISSING;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_REGISTER, &reg), SyscallSucceeds());
+  constexpr uint64_t kRangeIoctls = 1ULL << _UFFDIO_WAKE |
+                                    1ULL << _UFFDIO_COPY |
+                                    1ULL << _UFFDIO_ZEROPAGE;
+  EXPECT_EQ(reg.ioctls & kRangeIoctls, kRangeIoctls);
+
+  // Ranges must be page-aligned and non-empty, with a valid mode.
+  reg = {};
+  reg.range.start = m.addr() + 1;
+  reg.range.len = kPageSize;
+  reg.mode = UFFDIO_REGISTER_MODE_MISSING;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_REGISTER, &reg),
+              SyscallFailsWithErrno(EINVAL));
+  reg.range.start = m.addr();
+  reg.range.len = 0;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_REGISTER, &reg),
+              SyscallFailsWithErrno(EINVAL));
+  reg.range.len = kPageSize;
+  reg.mode = 0;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_REGISTER, &reg),
+              SyscallFailsWithErrno(EINVAL));
+
+  // A range can only be registered with one userfaultfd at a time.
+  FileDescriptor fd2 =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  EXPECT_THAT(Register(fd2, m, UFFDIO_REGISTER_MODE_MISSING),
+              PosixErrorIs(EBUSY, ::testing::_));
+
+  struct uffdio_range range = {};
+  range.start = m.addr();
+  range.len = m.len();
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_UNREGISTER, &range), SyscallSucceeds());
+  EXPECT_NO_ERRNO(Register(fd2, m, UFFDIO_REGISTER_MODE_MISSING));
+}
+
+TEST(UserfaultfdTest, RegisterSharedMapping) {
+  // Only private anonymous mappings are supported in gVisor.
+  SKIP_IF(!IsRunningOnGvisor());
+
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_SHARED));
+  EXPECT_THAT(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING),
+              PosixErrorIs(EINVAL, ::testing::_));
+}
+
+TEST(UserfaultfdTest, ReadWithoutFaults) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+
+  struct uffd_msg msg;
+  EXPECT_THAT(ReadFd(fd.get(), &msg, sizeof(msg) - 1),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(ReadFd(fd.get(), &msg, sizeof(msg)),
+              SyscallFailsWithErrno(EAGAIN));
+
+  struct pollfd pfd = {.fd = fd.get(), .events = POLLIN};
+  EXPECT_THAT(poll(&pfd, 1, 0), SyscallSucceedsWithValue(0));
+}
+
+TEST(UserfaultfdTest, Copy) {
+  // Faulting threads block until the fault is resolved.
+  const DisableSave ds;
+
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  ASSERT_NO_ERRNO(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING));
+
+  std::atomic<char> got(0);
+  ScopedThread t([&] {
+    got.store(*reinterpret_cast<volatile char*>(m.ptr()));
+  });
+
+  struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadFault(fd));
+  EXPECT_EQ(msg.event, UFFD_EVENT_PAGEFAULT);
+  EXPECT_EQ(msg.arg.pagefault.address, m.addr());
+  EXPECT_EQ(msg.arg.pagefault.flags & UFFD_PAGEFAULT_FLAG_WRITE, 0);
+
+  std::vector<char> src(kPageSize, 'a');
+  struct uffdio_copy copy = {};
+  copy.dst = m.addr();
+  copy.src = reinterpret_cast<uint64_t>(src.data());
+  copy.len = kPageSize;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_COPY, &copy), SyscallSucceeds());
+  EXPECT_EQ(copy.copy, kPageSize);
+
+  t.Join();
+  EXPECT_EQ(got.load(), 'a');
+
+  // The page is no longer missing.
+  copy.copy = 0;
+  EXPECT_THAT(ioctl(fd.get(), UFFDIO_COPY, &copy),
+              SyscallFailsWithErrno(EEXIST));
+  EXPECT_EQ(copy.copy, -EEXIST);
+}
+
+TEST(UserfaultfdTest, Zeropage) {
+  const DisableSave ds;
+
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  ASSERT_NO_ERRNO(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING));
+
+  ScopedThread t([&] { *reinterpret_cast<volatile char*>(m.ptr()) = 'b'; });
+
+  struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadFault(fd));
+  EXPECT_EQ(msg.event, UFFD_EVENT_PAGEFAULT);
+  EXPECT_EQ(msg.arg.pagefault.address, m.addr());
+  EXPECT_NE(msg.arg.pagefault.flags & UFFD_PAGEFAULT_FLAG_WRITE, 0);
+
+  struct uffdio_zeropage zp = {};
+  zp.range.start = m.addr();
+  zp.range.len = kPageSize;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_ZEROPAGE, &zp), SyscallSucceeds());
+  EXPECT_EQ(zp.zeropage, kPageSize);
+
+  t.Join();
+  EXPECT_EQ(*reinterpret_cast<char*>(m.ptr()), 'b');
+  EXPECT_EQ(*(reinterpret_cast<char*>(m.ptr()) + 1), 0);
+}
+
+TEST(UserfaultfdTest, Unregister) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  ASSERT_NO_ERRNO(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING));
+
+  struct uffdio_range range = {};
+  range.start = m.addr();
+  range.len = m.len();
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_UNREGISTER, &range), SyscallSucceeds());
+
+  // Missing pages are zero-filled as usual.
+  EXPECT_EQ(*reinterpret_cast<volatile char*>(m.ptr()), 0);
+  struct uffd_msg msg;
+  EXPECT_THAT(ReadFd(fd.get(), &msg, sizeof(msg)),
+              SyscallFailsWithErrno(EAGAIN));
+}
+
+TEST(UserfaultfdTest, UserModeOnlyKernelFault) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NewInitializedUserfaultfd(kUserModeOnly));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  ASSERT_NO_ERRNO(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING));
+
+  // Faults caused by the kernel are not delivered to the userfaultfd, and
+  // fail instead.
+  int pipefds[2];
+  ASSERT_THAT(pipe(pipefds), SyscallSucceeds());
+  FileDescriptor rfd(pipefds[0]);
+  FileDescriptor wfd(pipefds[1]);
+  EXPECT_THAT(WriteFd(wfd.get(), m.ptr(), 1), SyscallFailsWithErrno(EFAULT));
+
+  struct uffd_msg msg;
+  EXPECT_THAT(ReadFd(fd.get(), &msg, sizeof(msg)),
+              SyscallFailsWithErrno(EAGAIN));
+}
+
+TEST(UserfaultfdTest, KernelFault) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_SYS_PTRACE)));
+  const DisableSave ds;
+
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(
+      NewInitializedUserfaultfd(O_CLOEXEC | O_NONBLOCK));
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  ASSERT_NO_ERRNO(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING));
+
+  int pipefds[2];
+  ASSERT_THAT(pipe(pipefds), SyscallSucceeds());
+  FileDescriptor rfd(pipefds[0]);
+  FileDescriptor wfd(pipefds[1]);
+
+  // The kernel's fault on the source buffer is delivered to the userfaultfd.
+  ScopedThread t([&] {
+    TEST_CHECK(WriteFd(wfd.get(), m.ptr(), 1) == 1);
+  });
+
+  struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadFault(fd));
+  EXPECT_EQ(msg.event, UFFD_EVENT_PAGEFAULT);
+  EXPECT_EQ(msg.arg.pagefault.address, m.addr());
+
+  std::vector<char> src(kPageSize, 'c');
+  struct uffdio_copy copy = {};
+  copy.dst = m.addr();
+  copy.src = reinterpret_cast<uint64_t>(src.data());
+  copy.len = kPageSize;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_COPY, &copy), SyscallSucceeds());
+  t.Join();
+
+  char c;
+  ASSERT_THAT(ReadFd(rfd.get(), &c, 1), SyscallSucceedsWithValue(1));
+  EXPECT_EQ(c, 'c');
+}
+
+TEST(UserfaultfdTest, WriteProtect) {
+  const DisableSave ds;
+
+  FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(NewUserfaultfd(kUserModeOnly));
+  struct uffdio_api api = {};
+  api.api = UFFD_API;
+  api.features = UFFD_FEATURE_PAGEFAULT_FLAG_WP;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_API, &api), SyscallSucceeds());
+
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  // Populate the page before registering it.
+  *reinterpret_cast<volatile char*>(m.ptr()) = 'd';
+  ASSERT_NO_ERRNO(Register(fd, m, UFFDIO_REGISTER_MODE_WP));
+
+  struct uffdio_writeprotect wp = {};
+  wp.range.start = m.addr();
+  wp.range.len = kPageSize;
+  wp.mode = UFFDIO_WRITEPROTECT_MODE_WP;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_WRITEPROTECT, &wp), SyscallSucceeds());
+
+  // Reads are unaffected.
+  EXPECT_EQ(*reinterpret_cast<volatile char*>(m.ptr()), 'd');
+
+  ScopedThread t([&] { *reinterpret_cast<volatile char*>(m.ptr()) = 'e'; });
+
+  struct uffd_msg msg = ASSERT_NO_ERRNO_AND_VALUE(ReadFault(fd));
+  EXPECT_EQ(msg.event, UFFD_EVENT_PAGEFAULT);
+  EXPECT_EQ(msg.arg.pagefault.address, m.addr());
+  EXPECT_NE(msg.arg.pagefault.flags & UFFD_PAGEFAULT_FLAG_WP, 0);
+
+  // Removing write protection wakes the faulting thread.
+  wp.mode = 0;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_WRITEPROTECT, &wp), SyscallSucceeds());
+  t.Join();
+  EXPECT_EQ(*reinterpret_cast<volatile char*>(m.ptr()), 'e');
+}
+
+TEST(UserfaultfdDevTest, IocNew) {
+  SKIP_IF(!IsRunningOnGvisor() &&
+          !ASSERT_NO_ERRNO_AND_VALUE(Exists("/dev/userfaultfd")));
+
+  FileDescriptor dev =
+      ASSERT_NO_ERRNO_AND_VALUE(Open("/dev/userfaultfd", O_RDWR));
+  EXPECT_THAT(ioctl(dev.get(), USERFAULTFD_IOC_NEW, O_APPEND),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(ioctl(dev.get(), _IO(UFFDIO, 0x01), 0),
+              SyscallFailsWithErrno(EINVAL));
+
+  int ufd;
+  ASSERT_THAT(ufd = ioctl(dev.get(), USERFAULTFD_IOC_NEW, O_CLOEXEC),
+              SyscallSucceeds());
+  FileDescriptor fd(ufd);
+  EXPECT_THAT(fcntl(fd.get(), F_GETFD), SyscallSucceedsWithValue(FD_CLOEXEC));
+
+  // The new userfaultfd outlives the device file.
+  dev.reset();
+  struct uffdio_api api = {};
+  api.api = UFFD_API;
+  ASSERT_THAT(ioctl(fd.get(), UFFDIO_API, &api), SyscallSucceeds());
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  EXPECT_NO_ERRNO(Register(fd, m, UFFDIO_REGISTER_MODE_MISSING));
+}
+
+TEST(UserfaultfdDevTest, IocNewWithoutCapSysPtrace) {
+  SKIP_IF(!IsRunningOnGvisor() &&
+          !ASSERT_NO_ERRNO_AND_VALUE(Exists("/dev/userfaultfd")));
+
+  // Access to /dev/userfaultfd is controlled by its file permissions instead.
+  FileDescriptor dev =
+      ASSERT_NO_ERRNO_AND_VALUE(Open("/dev/userfaultfd", O_RDWR));
+  AutoCapability cap(CAP_SYS_PTRACE, false);
+  int ufd;
+  ASSERT_THAT(ufd = ioctl(dev.get(), USERFAULTFD_IOC_NEW, O_CLOEXEC),
+              SyscallSucceeds());
+  FileDescriptor fd(ufd);
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
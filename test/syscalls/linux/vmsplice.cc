@@ -0,0 +1,325 @@
+// Copyright 2024 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <fcntl.h>
+#include <signal.h>
+#include <sys/mman.h>
+#include <sys/uio.h>
+#include <unistd.h>
+
+#include <cstring>
+#include <vector>
+
+#include "gmock/gmock.h"
+#include "gtest/gtest.h"
+#include "absl/time/clock.h"
+#include "absl/time/time.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/memory_util.h"
+#include "test/util/signal_util.h"
+#include "test/util/temp_path.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+// Pipe holds both ends of a pipe.
+struct Pipe {
+  FileDescriptor rfd;
+  FileDescriptor wfd;
+};
+
+PosixErrorOr<Pipe> NewPipe(int flags) {
+  int fds[2];
+  if (pipe2(fds, flags) < 0) {
+    return PosixError(errno, "pipe2");
+  }
+  return Pipe{FileDescriptor(fds[0]), FileDescriptor(fds[1])};
+}
+
+TEST(VmspliceTest, ToPipe) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  // A small buffer, which is copied, followed by a page-aligned one, which
+  // may be referenced by the pipe.
+  std::vector<char> small(100);
+  RandomizeBuffer(small.data(), small.size());
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(2 * kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  RandomizeBuffer(static_cast<char*>(m.ptr()), m.len());
+
+  struct iovec iov[2] = {
+      {.iov_base = small.data(), .iov_len = small.size()},
+      {.iov_base = m.ptr(), .iov_len = m.len()},
+  };
+  ASSERT_THAT(vmsplice(p.wfd.get(), iov, 2, 0),
+              SyscallSucceedsWithValue(small.size() + m.len()));
+
+  std::vector<char> rbuf(small.size() + m.len());
+  ASSERT_THAT(ReadFd(p.rfd.get(), rbuf.data(), rbuf.size()),
+              SyscallSucceedsWithValue(rbuf.size()));
+  EXPECT_EQ(memcmp(rbuf.data(), small.data(), small.size()), 0);
+  EXPECT_EQ(memcmp(rbuf.data() + small.size(), m.ptr(), m.len()), 0);
+}
+
+TEST(VmspliceTest, ToPipeUnaligned) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(3 * kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  RandomizeBuffer(static_cast<char*>(m.ptr()), m.len());
+
+  // Data written with write(2) before and after the vmspliced data must stay
+  // in order.
+  constexpr char kHead[] = "head";
+  constexpr char kTail[] = "tail";
+  ASSERT_THAT(WriteFd(p.wfd.get(), kHead, sizeof(kHead)),
+              SyscallSucceedsWithValue(sizeof(kHead)));
+  const size_t off = 17;
+  const size_t len = 2 * kPageSize + 33;
+  struct iovec iov = {
+      .iov_base = static_cast<char*>(m.ptr()) + off,
+      .iov_len = len,
+  };
+  ASSERT_THAT(vmsplice(p.wfd.get(), &iov, 1, 0),
+              SyscallSucceedsWithValue(len));
+  ASSERT_THAT(WriteFd(p.wfd.get(), kTail, sizeof(kTail)),
+              SyscallSucceedsWithValue(sizeof(kTail)));
+
+  std::vector<char> rbuf(sizeof(kHead) + len + sizeof(kTail));
+  ASSERT_THAT(ReadFd(p.rfd.get(), rbuf.data(), rbuf.size()),
+              SyscallSucceedsWithValue(rbuf.size()));
+  EXPECT_EQ(memcmp(rbuf.data(), kHead, sizeof(kHead)), 0);
+  EXPECT_EQ(memcmp(rbuf.data() + sizeof(kHead),
+                   static_cast<char*>(m.ptr()) + off, len),
+            0);
+  EXPECT_EQ(memcmp(rbuf.data() + sizeof(kHead) + len, kTail, sizeof(kTail)),
+            0);
+}
+
+TEST(VmspliceTest, ToPipeReferencesMemory) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  memset(m.ptr(), 'a', m.len());
+
+  struct iovec iov = {.iov_base = m.ptr(), .iov_len = m.len()};
+  ASSERT_THAT(vmsplice(p.wfd.get(), &iov, 1, 0),
+              SyscallSucceedsWithValue(m.len()));
+
+  // As in Linux, the pipe references the pages rather than a copy of them,
+  // so later writes to the memory are visible to the reader.
+  memset(m.ptr(), 'b', m.len());
+
+  std::vector<char> rbuf(m.len());
+  ASSERT_THAT(ReadFd(p.rfd.get(), rbuf.data(), rbuf.size()),
+              SyscallSucceedsWithValue(rbuf.size()));
+  EXPECT_EQ(memcmp(rbuf.data(), m.ptr(), m.len()), 0);
+}
+
+TEST(VmspliceTest, ToPipeUnmapMemory) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  memset(m.ptr(), 'a', m.len());
+
+  struct iovec iov = {.iov_base = m.ptr(), .iov_len = m.len()};
+  ASSERT_THAT(vmsplice(p.wfd.get(), &iov, 1, 0),
+              SyscallSucceedsWithValue(m.len()));
+
+  // The pipe keeps the data readable after the memory is unmapped.
+  ASSERT_THAT(munmap(m.ptr(), m.len()), SyscallSucceeds());
+  m.release();
+
+  std::vector<char> rbuf(kPageSize);
+  ASSERT_THAT(ReadFd(p.rfd.get(), rbuf.data(), rbuf.size()),
+              SyscallSucceedsWithValue(rbuf.size()));
+  EXPECT_EQ(rbuf, std::vector<char>(kPageSize, 'a'));
+}
+
+TEST(VmspliceTest, FromPipe) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  std::vector<char> buf(kPageSize + 100);
+  RandomizeBuffer(buf.data(), buf.size());
+  ASSERT_THAT(WriteFd(p.wfd.get(), buf.data(), buf.size()),
+              SyscallSucceedsWithValue(buf.size()));
+
+  // The read end of the pipe transfers data into the iovecs, in order.
+  std::vector<char> rbuf1(100);
+  std::vector<char> rbuf2(kPageSize);
+  struct iovec iov[2] = {
+      {.iov_base = rbuf1.data(), .iov_len = rbuf1.size()},
+      {.iov_base = rbuf2.data(), .iov_len = rbuf2.size()},
+  };
+  ASSERT_THAT(vmsplice(p.rfd.get(), iov, 2, 0),
+              SyscallSucceedsWithValue(buf.size()));
+  EXPECT_EQ(memcmp(rbuf1.data(), buf.data(), rbuf1.size()), 0);
+  EXPECT_EQ(memcmp(rbuf2.data(), buf.data() + rbuf1.size(), rbuf2.size()), 0);
+}
+
+TEST(VmspliceTest, FromPipePartial) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  constexpr char kData[] = "partial";
+  ASSERT_THAT(WriteFd(p.wfd.get(), kData, sizeof(kData)),
+              SyscallSucceedsWithValue(sizeof(kData)));
+
+  // Only the available data is transferred.
+  std::vector<char> rbuf(kPageSize);
+  struct iovec iov = {.iov_base = rbuf.data(), .iov_len = rbuf.size()};
+  ASSERT_THAT(vmsplice(p.rfd.get(), &iov, 1, 0),
+              SyscallSucceedsWithValue(sizeof(kData)));
+  EXPECT_EQ(memcmp(rbuf.data(), kData, sizeof(kData)), 0);
+}
+
+TEST(VmspliceTest, FromPipeBlocking) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  std::vector<char> buf(kPageSize);
+  RandomizeBuffer(buf.data(), buf.size());
+  ScopedThread t([&]() {
+    absl::SleepFor(absl::Milliseconds(100));
+    ASSERT_THAT(WriteFd(p.wfd.get(), buf.data(), buf.size()),
+                SyscallSucceedsWithValue(buf.size()));
+  });
+
+  // The pipe is empty, so vmsplice blocks until the thread writes.
+  std::vector<char> rbuf(kPageSize);
+  struct iovec iov = {.iov_base = rbuf.data(), .iov_len = rbuf.size()};
+  ASSERT_THAT(vmsplice(p.rfd.get(), &iov, 1, 0),
+              SyscallSucceedsWithValue(kPageSize));
+  t.Join();
+  EXPECT_EQ(rbuf, buf);
+}
+
+TEST(VmspliceTest, ToPipeBlocking) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+  const int size = fcntl(p.wfd.get(), F_GETPIPE_SZ);
+  ASSERT_THAT(size, SyscallSucceeds());
+
+  // Fill the pipe.
+  std::vector<char> fill(size);
+  ASSERT_THAT(WriteFd(p.wfd.get(), fill.data(), fill.size()),
+              SyscallSucceedsWithValue(size));
+
+  ScopedThread t([&]() {
+    absl::SleepFor(absl::Milliseconds(100));
+    ASSERT_THAT(ReadFd(p.rfd.get(), fill.data(), fill.size()),
+                SyscallSucceedsWithValue(size));
+  });
+
+  // The pipe is full, so vmsplice blocks until the thread reads.
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  struct iovec iov = {.iov_base = m.ptr(), .iov_len = m.len()};
+  ASSERT_THAT(vmsplice(p.wfd.get(), &iov, 1, 0),
+              SyscallSucceedsWithValue(kPageSize));
+  t.Join();
+}
+
+TEST(VmspliceTest, NonBlocking) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  // Reading from an empty pipe.
+  std::vector<char> buf(kPageSize);
+  struct iovec iov = {.iov_base = buf.data(), .iov_len = buf.size()};
+  EXPECT_THAT(vmsplice(p.rfd.get(), &iov, 1, SPLICE_F_NONBLOCK),
+              SyscallFailsWithErrno(EAGAIN));
+
+  // Writing to a full pipe.
+  const int size = fcntl(p.wfd.get(), F_GETPIPE_SZ);
+  ASSERT_THAT(size, SyscallSucceeds());
+  std::vector<char> fill(size);
+  ASSERT_THAT(WriteFd(p.wfd.get(), fill.data(), fill.size()),
+              SyscallSucceedsWithValue(size));
+  EXPECT_THAT(vmsplice(p.wfd.get(), &iov, 1, SPLICE_F_NONBLOCK),
+              SyscallFailsWithErrno(EAGAIN));
+}
+
+TEST(VmspliceTest, NonBlockingPipe) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(O_NONBLOCK));
+
+  std::vector<char> buf(kPageSize);
+  struct iovec iov = {.iov_base = buf.data(), .iov_len = buf.size()};
+  EXPECT_THAT(vmsplice(p.rfd.get(), &iov, 1, 0), SyscallFailsWithErrno(EAGAIN));
+}
+
+TEST(VmspliceTest, NoReaders) {
+  // Tests intentionally generate SIGPIPE.
+  struct sigaction sa = {};
+  sa.sa_handler = SIG_IGN;
+  auto cleanup = ASSERT_NO_ERRNO_AND_VALUE(ScopedSigaction(SIGPIPE, sa));
+
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+  p.rfd.reset();
+
+  std::vector<char> buf(kPageSize);
+  struct iovec iov = {.iov_base = buf.data(), .iov_len = buf.size()};
+  EXPECT_THAT(vmsplice(p.wfd.get(), &iov, 1, 0), SyscallFailsWithErrno(EPIPE));
+}
+
+TEST(VmspliceTest, ZeroLength) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  EXPECT_THAT(vmsplice(p.wfd.get(), nullptr, 0, 0),
+              SyscallSucceedsWithValue(0));
+  EXPECT_THAT(vmsplice(p.rfd.get(), nullptr, 0, 0),
+              SyscallSucceedsWithValue(0));
+}
+
+TEST(VmspliceTest, InvalidFlags) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  std::vector<char> buf(kPageSize);
+  struct iovec iov = {.iov_base = buf.data(), .iov_len = buf.size()};
+  EXPECT_THAT(vmsplice(p.wfd.get(), &iov, 1, 0x80),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(VmspliceTest, NotPipe) {
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  const FileDescriptor fd = ASSERT_NO_ERRNO_AND_VALUE(Open(file.path(), O_RDWR));
+
+  std::vector<char> buf(kPageSize);
+  struct iovec iov = {.iov_base = buf.data(), .iov_len = buf.size()};
+  EXPECT_THAT(vmsplice(fd.get(), &iov, 1, 0), SyscallFailsWithErrno(EBADF));
+}
+
+TEST(VmspliceTest, BadAddress) {
+  Pipe p = ASSERT_NO_ERRNO_AND_VALUE(NewPipe(0));
+
+  // Unmapped memory can't be transferred in either direction.
+  Mapping m = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_PRIVATE));
+  void* addr = m.ptr();
+  m.reset();
+  struct iovec iov = {.iov_base = addr, .iov_len = kPageSize};
+  EXPECT_THAT(vmsplice(p.wfd.get(), &iov, 1, 0), SyscallFailsWithErrno(EFAULT));
+
+  constexpr char kData[] = "data";
+  ASSERT_THAT(WriteFd(p.wfd.get(), kData, sizeof(kData)),
+              SyscallSucceedsWithValue(sizeof(kData)));
+  EXPECT_THAT(vmsplice(p.rfd.get(), &iov, 1, 0), SyscallFailsWithErrno(EFAULT));
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
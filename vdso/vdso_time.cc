@@ -41,6 +41,9 @@ struct params {
   int64_t realtime_base_cycles;
   int64_t realtime_base_ref;
   uint64_t realtime_frequency;
+
+  int64_t monotonic_offset;
+  int64_t boottime_offset;
 };
 
 // Returns a pointer to the global parameter page.
@@ -123,15 +126,22 @@ int ClockRealtime(struct timespec* ts) {
   return 0;
 }
 
-// ClockMonotonic() is the VDSO implementation of
-// clock_gettime(CLOCK_MONOTONIC).
-int ClockMonotonic(struct timespec* ts) {
+// ClockMonotonicOffset() returns CLOCK_MONOTONIC, without time namespace
+// offsets, in ts. It returns the time namespace offset of clock, which must be
+// CLOCK_MONOTONIC or CLOCK_BOOTTIME, in offset.
+//
+// If the parameters are not ready, it instead returns the result of the
+// clock_gettime syscall for clock, which includes the offset, and sets offset
+// to zero.
+int ClockMonotonicOffset(clockid_t clock, struct timespec* ts,
+                         int64_t* offset) {
   struct params* params = get_params();
   uint64_t seq;
   uint64_t ready;
   int64_t base_ref;
   int64_t base_cycles;
   uint64_t frequency;
+  int64_t clock_offset;
   int64_t now_cycles;
 
   do {
@@ -140,20 +150,49 @@ int ClockMonotonic(struct timespec* ts) {
     base_ref = params->monotonic_base_ref;
     base_cycles = params->monotonic_base_cycles;
     frequency = params->monotonic_frequency;
+    clock_offset = (clock == CLOCK_BOOTTIME) ? params->boottime_offset
+                                             : params->monotonic_offset;
     now_cycles = cycle_clock();
   } while (read_seqcount_retry(&params->seq_count, seq));
 
   if (!ready) {
     // The sandbox kernel ensures that we won't compute a time later than this
     // once the params are ready.
-    return sys_clock_gettime(CLOCK_MONOTONIC, ts);
+    *offset = 0;
+    return sys_clock_gettime(clock, ts);
   }
 
   int64_t delta_cycles =
       (now_cycles < base_cycles) ? 0 : now_cycles - base_cycles;
   int64_t now_ns = base_ref + cycles_to_ns(frequency, delta_cycles);
   *ts = ns_to_timespec(now_ns);
+  *offset = clock_offset;
+  return 0;
+}
+
+// ClockMonotonicWithOffset() applies the time namespace offset of clock to
+// the result of ClockMonotonicOffset().
+inline int ClockMonotonicWithOffset(clockid_t clock, struct timespec* ts) {
+  int64_t offset;
+  int ret = ClockMonotonicOffset(clock, ts, &offset);
+  if (ret || offset == 0) {
+    return ret;
+  }
+  uint64_t ns = ts->tv_sec * kNsecsPerSec + ts->tv_nsec + offset;
+  *ts = ns_to_timespec(ns);
   return 0;
 }
 
+// ClockMonotonic() is the VDSO implementation of
+// clock_gettime(CLOCK_MONOTONIC).
+int ClockMonotonic(struct timespec* ts) {
+  return ClockMonotonicWithOffset(CLOCK_MONOTONIC, ts);
+}
+
+// ClockBoottime() is the VDSO implementation of
+// clock_gettime(CLOCK_BOOTTIME).
+int ClockBoottime(struct timespec* ts) {
+  return ClockMonotonicWithOffset(CLOCK_BOOTTIME, ts);
+}
+
 }  // namespace vdso